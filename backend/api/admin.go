@@ -1,21 +1,24 @@
 package api
 
 import (
+	"errors"
+	"eylexander/bluraymanager/controller"
 	"eylexander/bluraymanager/models"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func (api *API) ListUsers(c *gin.Context) {
-	skip, _ := strconv.Atoi(c.DefaultQuery("skip", "0"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	opts, ok := api.listOptions(c, 20)
+	if !ok {
+		return
+	}
 
-	users, err := api.ctrl.ListUsers(c.Request.Context(), skip, limit)
+	users, page, err := api.ctrl.ListUsers(c.Request.Context(), opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		searchFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -24,7 +27,7 @@ func (api *API) ListUsers(c *gin.Context) {
 		user.PasswordHash = ""
 	}
 
-	c.JSON(http.StatusOK, gin.H{"users": users})
+	c.JSON(http.StatusOK, listResponse("users", users, page))
 }
 
 func (api *API) GetUser(c *gin.Context) {
@@ -61,6 +64,9 @@ func (api *API) CreateUser(c *gin.Context) {
 	if req.Role == "" {
 		req.Role = models.RoleUser
 	}
+	if !api.canGrantRole(c, req.Role) {
+		return
+	}
 
 	user, err := api.ctrl.RegisterUser(c.Request.Context(), req.Username, req.Email, req.Password, req.Role)
 	if err != nil {
@@ -98,6 +104,12 @@ func (api *API) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
+	if !api.canGrantRole(c, user.Role) {
+		return
+	}
+	if req.Role != "" && !api.canGrantRole(c, req.Role) {
+		return
+	}
 
 	// Update fields if provided
 	if req.Username != "" {
@@ -111,7 +123,7 @@ func (api *API) UpdateUser(c *gin.Context) {
 	}
 
 	if err := api.ctrl.UpdateUser(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -126,6 +138,15 @@ func (api *API) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	user, err := api.ctrl.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !api.canGrantRole(c, user.Role) {
+		return
+	}
+
 	if err := api.ctrl.DeleteUser(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -155,13 +176,99 @@ func (api *API) UpdateUserRole(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
+	if !api.canGrantRole(c, user.Role) || !api.canGrantRole(c, req.Role) {
+		return
+	}
 
 	user.Role = req.Role
 	if err := api.ctrl.UpdateUser(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	user.PasswordHash = ""
 	c.JSON(http.StatusOK, gin.H{"user": user})
 }
+
+// ResetUserTwoFactor turns two-factor authentication off for a user who lost
+// their authenticator app and recovery codes
+func (api *API) ResetUserTwoFactor(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+
+	user, err := api.ctrl.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("user.notFound")})
+		return
+	}
+	if !api.canGrantRole(c, user.Role) {
+		return
+	}
+
+	if err := api.ctrl.ResetTwoFactor(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("twoFactor.reset")})
+}
+
+// UnlockUser lifts the lock put on an account after too many failed logins
+func (api *API) UnlockUser(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+
+	user, err := api.ctrl.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("user.notFound")})
+		return
+	}
+	if !api.canGrantRole(c, user.Role) {
+		return
+	}
+
+	if err := api.ctrl.UnlockUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("lockout.accountUnlocked")})
+}
+
+// canGrantRole checks that the current user holds every permission of role,
+// which they are about to give to a user or take away from one. It writes
+// the error response when they do not.
+func (api *API) canGrantRole(c *gin.Context, role models.UserRole) bool {
+	granter, _ := c.Get("role")
+	return api.grantAllowed(c, api.ctrl.CanGrantRole(c.Request.Context(), granter.(models.UserRole), role))
+}
+
+// canGrantPermissions checks that the current user holds every permission
+// they are about to put in a role. It writes the error response when they
+// do not.
+func (api *API) canGrantPermissions(c *gin.Context, permissions []models.Permission) bool {
+	granter, _ := c.Get("role")
+	return api.grantAllowed(c, api.ctrl.CanGrantPermissions(c.Request.Context(), granter.(models.UserRole), permissions))
+}
+
+// grantAllowed writes the error response of a grant check that failed
+func (api *API) grantAllowed(c *gin.Context, err error) bool {
+	var grantErr *controller.RoleGrantError
+	switch {
+	case errors.As(err, &grantErr):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return false
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"eylexander/bluraymanager/controller"
 	"eylexander/bluraymanager/i18n"
+	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,3 +21,22 @@ func NewAPI(ctrl *controller.Controller) *API {
 func (api *API) GetI18n(c *gin.Context) *i18n.I18n {
 	return api.ctrl.GetI18n(c)
 }
+
+// pageParams reads the skip and limit query parameters, limit defaulting to
+// defaultLimit. Negative values get 400 Bad Request; it writes the error
+// response when it fails.
+func (api *API) pageParams(c *gin.Context, defaultLimit int) (skip, limit int, ok bool) {
+	skip, err := strconv.Atoi(c.Query("skip"))
+	if err != nil {
+		skip = 0
+	}
+	limit, err = strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		limit = defaultLimit
+	}
+	if skip < 0 || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": api.GetI18n(c).T("api.invalidPage")})
+		return 0, 0, false
+	}
+	return skip, limit, true
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (api *API) ListAPITokens(c *gin.Context) {
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := api.ctrl.ListAPITokens(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// CreateAPIToken creates a personal API token. The response is the only
+// time the token itself is shown.
+func (api *API) CreateAPIToken(c *gin.Context) {
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	token, raw, err := api.ctrl.CreateAPIToken(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_token": token, "token": raw})
+}
+
+func (api *API) RevokeAPIToken(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	tokenID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.RevokeAPIToken(c.Request.Context(), userID, tokenID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("apiToken.revokedSuccessfully")})
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"eylexander/bluraymanager/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListAuditEntries returns the audit log, newest first. It can be narrowed
+// down by actor_id, action, entity_type, entity_id and collection_id, and to
+// the entries from since and before until, given as RFC 3339 times or dates.
+func (api *API) ListAuditEntries(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	opts, ok := api.listOptions(c, 50)
+	if !ok {
+		return
+	}
+
+	filter := models.AuditFilter{
+		Action:     models.AuditAction(c.Query("action")),
+		EntityType: models.AuditEntityType(c.Query("entity_type")),
+		EntityID:   c.Query("entity_id"),
+	}
+	var actorErr, collectionErr, sinceErr, untilErr error
+	filter.ActorID, actorErr = optionalObjectID(c.Query("actor_id"))
+	filter.CollectionID, collectionErr = optionalObjectID(c.Query("collection_id"))
+	filter.Since, sinceErr = optionalTime(c.Query("since"))
+	filter.Until, untilErr = optionalTime(c.Query("until"))
+	if errors.Join(actorErr, collectionErr, sinceErr, untilErr) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("audit.invalidFilter")})
+		return
+	}
+
+	entries, page, err := api.ctrl.ListAuditEntries(c.Request.Context(), filter, opts)
+	if err != nil {
+		searchFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse("entries", entries, page))
+}
+
+// optionalObjectID parses an ID query parameter that may be empty
+func optionalObjectID(hex string) (*primitive.ObjectID, error) {
+	if hex == "" {
+		return nil, nil
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// optionalTime parses a query parameter holding an RFC 3339 time or a date,
+// which may be empty
+func optionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", value); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
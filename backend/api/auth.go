@@ -1,20 +1,21 @@
 package api
 
 import (
+	"errors"
+	"eylexander/bluraymanager/controller"
 	"eylexander/bluraymanager/models"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func (api *API) Register(c *gin.Context) {
-	i18n := api.GetI18n(c)
 	var req struct {
-		Username string          `json:"username" binding:"required"`
-		Email    string          `json:"email" binding:"required,email"`
-		Password string          `json:"password" binding:"required,min=6"`
-		Role     models.UserRole `json:"role"`
+		Username string `json:"username" binding:"required"`
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required,min=6"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -22,30 +23,15 @@ func (api *API) Register(c *gin.Context) {
 		return
 	}
 
-	// Default to user role if not specified
-	if req.Role == "" {
-		req.Role = models.RoleUser
-	}
-
-	user, err := api.ctrl.RegisterUser(c.Request.Context(), req.Username, req.Email, req.Password, req.Role)
+	// Signing up always gives the default role, other roles are given by
+	// user managers
+	user, err := api.ctrl.RegisterUser(c.Request.Context(), req.Username, req.Email, req.Password, models.RoleUser)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Don't send password in response
-	user.PasswordHash = ""
-
-	token, err := api.ctrl.GenerateToken(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T("api.failedToGenerateToken")})
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{
-		"user":  user,
-		"token": token,
-	})
+	api.signIn(c, http.StatusCreated, user)
 }
 
 func (api *API) Login(c *gin.Context) {
@@ -67,25 +53,99 @@ func (api *API) Login(c *gin.Context) {
 
 	user, err := api.ctrl.Login(c.Request.Context(), req.Identifier, req.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		api.loginFailed(c, err)
 		return
 	}
 
-	// Don't send password in response
-	user.PasswordHash = ""
+	api.signInOrChallenge(c, user)
+}
+
+// LoginTwoFactor is the second step of signing in with two-factor
+// authentication
+func (api *API) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := api.ctrl.CompleteTwoFactorLogin(c.Request.Context(), req.ChallengeToken, req.Code)
+	if err != nil {
+		api.loginFailed(c, err)
+		return
+	}
+
+	api.signIn(c, http.StatusOK, user)
+}
 
-	token, err := api.ctrl.GenerateToken(user)
+// loginFailed answers a failed login step. Locked accounts get 423 Locked
+// and when to try again.
+func (api *API) loginFailed(c *gin.Context, err error) {
+	var locked *controller.AccountLockedError
+	if errors.As(err, &locked) {
+		controller.SetRetryAfter(c, time.Until(locked.Until))
+		c.JSON(http.StatusLocked, gin.H{"error": err.Error(), "locked_until": locked.Until})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+}
+
+// signIn opens a session for the user and responds with the user and the
+// tokens of the session
+func (api *API) signIn(c *gin.Context, status int, user *models.User) {
+	i18n := api.GetI18n(c)
+	tokens, err := api.ctrl.StartSession(c.Request.Context(), user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T("api.failedToGenerateToken")})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": token,
+	// Don't send password in response
+	user.PasswordHash = ""
+
+	c.JSON(status, gin.H{
+		"user":          user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
 
+// signInOrChallenge signs the user in, unless they have two-factor
+// authentication: then the first step only earns a challenge to present
+// along with a code
+func (api *API) signInOrChallenge(c *gin.Context, user *models.User) {
+	i18n := api.GetI18n(c)
+	if user.TwoFactor.Enabled {
+		challenge, err := api.ctrl.NewTwoFactorChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T("api.failedToGenerateToken")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": challenge})
+		return
+	}
+
+	api.signIn(c, http.StatusOK, user)
+}
+
+// currentUser loads the user making the request. It writes the error
+// response when it fails.
+func (api *API) currentUser(c *gin.Context) (*models.User, bool) {
+	i18n := api.GetI18n(c)
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	user, err := api.ctrl.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("user.notFound")})
+		return nil, false
+	}
+	return user, true
+}
+
 func (api *API) GetCurrentUser(c *gin.Context) {
 	i18n := api.GetI18n(c)
 	userID, _ := c.Get("userID")
@@ -223,5 +283,11 @@ func (api *API) UpdatePassword(c *gin.Context) {
 		return
 	}
 
+	// Other devices have to sign in again with the new password
+	if err := api.ctrl.RevokeOtherSessions(c.Request.Context(), id, api.currentSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": i18n.T("api.passwordUpdatedSuccessfully")})
 }
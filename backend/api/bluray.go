@@ -1,12 +1,15 @@
 package api
 
 import (
+	"errors"
+	"eylexander/bluraymanager/controller"
 	"eylexander/bluraymanager/models"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 	"unicode/utf8"
 
@@ -79,6 +82,16 @@ func (api *API) UpdateBluray(c *gin.Context) {
 		return
 	}
 
+	existing, err := api.ctrl.GetBlurayByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bluray not found"})
+		return
+	}
+	// Removing copies takes the permission to delete them
+	if bluray.Copies != nil && existing.DropsCopies(bluray.Copies) && !api.ctrl.CheckPermission(c, models.PermBlurayDelete) {
+		return
+	}
+
 	bluray.ID = id
 	if err := api.ctrl.UpdateBluray(c.Request.Context(), &bluray); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -160,9 +173,6 @@ func (api *API) DeleteBluray(c *gin.Context) {
 }
 
 func (api *API) ListBlurays(c *gin.Context) {
-	skip, _ := strconv.Atoi(c.DefaultQuery("skip", "0"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-
 	filters := make(map[string]interface{})
 	if mediaType := c.Query("type"); mediaType != "" {
 		filters["type"] = mediaType
@@ -170,15 +180,25 @@ func (api *API) ListBlurays(c *gin.Context) {
 	if genre := c.Query("genre"); genre != "" {
 		filters["genre"] = genre
 	}
+	if format, ok := models.ParseReleaseFormat(c.Query("format")); ok {
+		filters["copies.format"] = string(format)
+	}
+	if !api.filterWatched(c, filters) {
+		return
+	}
 
-	blurays, err := api.ctrl.ListBlurays(c.Request.Context(), filters, skip, limit)
+	opts, ok := api.queryOptions(c)
+	if !ok {
+		return
+	}
+	blurays, page, err := api.ctrl.ListBlurays(c.Request.Context(), filters, opts)
 	if err != nil {
 		log.Printf("ERROR ListBlurays: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		searchFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"blurays": blurays})
+	c.JSON(http.StatusOK, listResponse("blurays", blurays, page))
 }
 
 func (api *API) SearchBlurays(c *gin.Context) {
@@ -188,27 +208,78 @@ func (api *API) SearchBlurays(c *gin.Context) {
 		return
 	}
 
-	skip, _ := strconv.Atoi(c.DefaultQuery("skip", "0"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-
-	blurays, err := api.ctrl.SearchBlurays(c.Request.Context(), query, skip, limit)
+	opts, ok := api.queryOptions(c)
+	if !ok {
+		return
+	}
+	blurays, page, err := api.ctrl.SearchBlurays(c.Request.Context(), query, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		searchFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"blurays": blurays})
+	c.JSON(http.StatusOK, listResponse("blurays", blurays, page))
+}
+
+// searchFailed answers a failed search or list with the given status. Queries
+// that do not parse get 400 Bad Request and tell what is wrong and where, as
+// do unknown sorts and unreadable cursors.
+func searchFailed(c *gin.Context, status int, err error) {
+	var queryErr *controller.SearchQueryError
+	if errors.As(err, &queryErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": queryErr.Code, "position": queryErr.Position, "token": queryErr.Token})
+		return
+	}
+	var optionsErr *controller.QueryOptionsError
+	if errors.As(err, &optionsErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// queryOptions reads the sort, cursor, skip and limit parameters of the
+// bluray list endpoints, 20 blurays a page by default. It writes the error
+// response when it fails.
+func (api *API) queryOptions(c *gin.Context) (*models.QueryOptions, bool) {
+	opts, ok := api.listOptions(c, 20)
+	if ok {
+		opts.Sort = models.SearchSort(c.Query("sort"))
+	}
+	return opts, ok
+}
+
+// listOptions reads the cursor, skip and limit parameters of the list
+// endpoints that cannot be sorted. It writes the error response when it
+// fails.
+func (api *API) listOptions(c *gin.Context, defaultLimit int) (*models.QueryOptions, bool) {
+	skip, limit, ok := api.pageParams(c, defaultLimit)
+	if !ok {
+		return nil, false
+	}
+	return &models.QueryOptions{Cursor: c.Query("cursor"), Skip: skip, Limit: limit}, true
+}
+
+// listResponse is the envelope of the list endpoints: a page of items under
+// key, the number of items in the whole list and the cursor of the next page,
+// null on the last page
+func listResponse(key string, items interface{}, page *models.PageInfo) gin.H {
+	var next interface{}
+	if page.NextCursor != "" {
+		next = page.NextCursor
+	}
+	return gin.H{key: items, "total": page.Total, "next_cursor": next}
 }
 
 func (api *API) ExportBlurays(c *gin.Context) {
-	blurays, err := api.ctrl.ListBlurays(c.Request.Context(), map[string]interface{}{}, 0, 0)
+	blurays, _, err := api.ctrl.ListBlurays(c.Request.Context(), map[string]interface{}{}, &models.QueryOptions{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Create CSV content with UTF-8 BOM
-	csv := "\xEF\xBB\xBF" + "Title,Type,GenreEn,GenreFr,DescriptionEn,DescriptionFr,Director,ReleaseYear,Runtime,Rating,PurchasePrice,PurchaseDate,CoverImageURL,BackdropURL,TMDBID,Tags,Seasons,TotalEpisodes\n"
+	csv := "\xEF\xBB\xBF" + "Title,Type,GenreEn,GenreFr,DescriptionEn,DescriptionFr,Director,ReleaseYear,Runtime,Rating,PurchasePrice,PurchaseDate,CoverImageURL,BackdropURL,TMDBID,Tags,Seasons,TotalEpisodes,Format,Edition,Packaging,Publisher,RegionCode,Barcode,DiscCount,AudioTracks,SubtitleTracks,Condition,Notes\n"
 
 	for _, bluray := range blurays {
 		// Escape and format fields
@@ -257,11 +328,6 @@ func (api *API) ExportBlurays(c *gin.Context) {
 			rating = strconv.FormatFloat(bluray.Rating, 'f', 1, 64)
 		}
 
-		purchasePrice := ""
-		if bluray.PurchasePrice != 0 {
-			purchasePrice = strconv.FormatFloat(bluray.PurchasePrice, 'f', 2, 64)
-		}
-
 		// Serialize seasons data (format: "number:episodeCount:year;number:episodeCount:year")
 		seasons := ""
 		if len(bluray.Seasons) > 0 {
@@ -281,11 +347,6 @@ func (api *API) ExportBlurays(c *gin.Context) {
 			totalEpisodes = strconv.Itoa(bluray.TotalEpisodes)
 		}
 
-		purchaseDate := ""
-		if !bluray.PurchaseDate.IsZero() {
-			purchaseDate = bluray.PurchaseDate.Format("2006-01-02")
-		}
-
 		// Escape quotes in strings
 		title := escapeCSV(bluray.Title)
 		descEn := escapeCSV(bluray.Description.En)
@@ -296,10 +357,43 @@ func (api *API) ExportBlurays(c *gin.Context) {
 		tmdbID := escapeCSV(bluray.TMDBID)
 		typeStr := string(bluray.Type)
 
-		csv += title + "," + typeStr + "," + genreEn + "," + genreFr + "," + descEn + "," + descFr + "," + director + "," +
-			releaseYear + "," + runtime + "," + rating + "," + purchasePrice + "," +
-			purchaseDate + "," + coverURL + "," + backdropURL + "," + tmdbID + "," + tags + "," +
-			seasons + "," + totalEpisodes + "\n"
+		// One row per copy; the import merges them back into one title
+		copies := bluray.Copies
+		if len(copies) == 0 {
+			copies = []models.Copy{{}}
+		}
+		for _, cp := range copies {
+			purchasePrice := ""
+			if cp.PurchasePrice != 0 {
+				purchasePrice = strconv.FormatFloat(cp.PurchasePrice, 'f', 2, 64)
+			}
+
+			purchaseDate := ""
+			if !cp.PurchaseDate.IsZero() {
+				purchaseDate = cp.PurchaseDate.Format("2006-01-02")
+			}
+
+			discCount := ""
+			if cp.DiscCount != 0 {
+				discCount = strconv.Itoa(cp.DiscCount)
+			}
+
+			// Tracks are separated with semicolons, like tags and genres
+			audioTracks := escapeCSV(strings.Join(cp.AudioTracks, ";"))
+			subtitleTracks := escapeCSV(strings.Join(cp.SubtitleTracks, ";"))
+
+			edition := escapeCSV(cp.Edition)
+			publisher := escapeCSV(cp.Publisher)
+			regionCode := escapeCSV(cp.RegionCode)
+			notes := escapeCSV(cp.Notes)
+
+			csv += title + "," + typeStr + "," + genreEn + "," + genreFr + "," + descEn + "," + descFr + "," + director + "," +
+				releaseYear + "," + runtime + "," + rating + "," + purchasePrice + "," +
+				purchaseDate + "," + coverURL + "," + backdropURL + "," + tmdbID + "," + tags + "," +
+				seasons + "," + totalEpisodes + "," + string(cp.Format) + "," + edition + "," +
+				string(cp.Packaging) + "," + publisher + "," + regionCode + "," + cp.Barcode + "," +
+				discCount + "," + audioTracks + "," + subtitleTracks + "," + string(cp.Condition) + "," + notes + "\n"
+		}
 	}
 
 	c.Header("Content-Type", "text/csv; charset=utf-8")
@@ -341,6 +435,12 @@ func (api *API) ImportBlurays(c *gin.Context) {
 		return
 	}
 
+	// Ratings of the imported blurays become the personal ratings of the importer
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
 	// Parse CSV
 	lines := parseCSVLines(csvContent)
 	if len(lines) < 2 {
@@ -436,6 +536,32 @@ func (api *API) ImportBlurays(c *gin.Context) {
 			mediaType = models.MediaTypeSeries
 		}
 
+		cp := models.Copy{
+			PurchasePrice: purchasePrice,
+			PurchaseDate:  purchaseDate,
+		}
+
+		// Release columns were added later and are missing from older exports
+		if len(fields) >= 27 {
+			cp.Format = models.ReleaseFormat(fields[18])
+			cp.Edition = fields[19]
+			cp.Packaging = models.Packaging(fields[20])
+			cp.Publisher = fields[21]
+			cp.RegionCode = fields[22]
+			cp.Barcode = fields[23]
+			cp.DiscCount, _ = strconv.Atoi(fields[24])
+			if fields[25] != "" {
+				cp.AudioTracks = parseCSVTags(fields[25])
+			}
+			if fields[26] != "" {
+				cp.SubtitleTracks = parseCSVTags(fields[26])
+			}
+		}
+		if len(fields) >= 29 {
+			cp.Condition = models.CopyCondition(fields[27])
+			cp.Notes = fields[28]
+		}
+
 		// Check for duplicates based on title, type, and release year
 		filters := map[string]interface{}{
 			"title": fields[0],
@@ -445,10 +571,21 @@ func (api *API) ImportBlurays(c *gin.Context) {
 			filters["release_year"] = releaseYear
 		}
 
-		existingBlurays, err := api.ctrl.ListBlurays(c.Request.Context(), filters, 0, 1)
-		if err == nil && len(existingBlurays) > 0 {
-			// Duplicate found, skip this entry
-			skipped++
+		existing, err := api.ctrl.FindBluray(c.Request.Context(), filters)
+		if err == nil && existing != nil {
+			if hasSameCopy(existing, &cp) {
+				// Duplicate found, skip this entry
+				skipped++
+				continue
+			}
+
+			// Another copy of a title we already have
+			if _, err := api.ctrl.AddCopy(c.Request.Context(), existing.ID, &cp); err != nil {
+				errors = append(errors, "Line "+strconv.Itoa(i+1)+": "+err.Error())
+				failed++
+			} else {
+				success++
+			}
 			continue
 		}
 
@@ -467,14 +604,14 @@ func (api *API) ImportBlurays(c *gin.Context) {
 			ReleaseYear:   releaseYear,
 			Runtime:       runtime,
 			Rating:        rating,
-			PurchasePrice: purchasePrice,
-			PurchaseDate:  purchaseDate,
 			CoverImageURL: fields[12],
 			BackdropURL:   fields[13],
 			TMDBID:        fields[14],
 			Tags:          tags,
 			Seasons:       seasons,
 			TotalEpisodes: totalEpisodes,
+			Copies:        []models.Copy{cp},
+			AddedBy:       userID,
 		}
 
 		if err := api.ctrl.CreateBluray(c.Request.Context(), bluray); err != nil {
@@ -494,9 +631,6 @@ func (api *API) ImportBlurays(c *gin.Context) {
 }
 
 func (api *API) ListSimplifiedBlurays(c *gin.Context) {
-	skip, _ := strconv.Atoi(c.DefaultQuery("skip", "0"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-
 	filters := make(map[string]interface{})
 	if mediaType := c.Query("type"); mediaType != "" {
 		filters["type"] = mediaType
@@ -504,13 +638,53 @@ func (api *API) ListSimplifiedBlurays(c *gin.Context) {
 	if genre := c.Query("genre"); genre != "" {
 		filters["genre"] = genre
 	}
+	if format, ok := models.ParseReleaseFormat(c.Query("format")); ok {
+		filters["copies.format"] = string(format)
+	}
+	if !api.filterWatched(c, filters) {
+		return
+	}
 
-	blurays, err := api.ctrl.ListSimplifiedBlurays(c.Request.Context(), filters, skip, limit)
+	opts, ok := api.queryOptions(c)
+	if !ok {
+		return
+	}
+	blurays, page, err := api.ctrl.ListSimplifiedBlurays(c.Request.Context(), filters, opts)
 	if err != nil {
 		log.Printf("ERROR ListBlurays: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		searchFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"blurays": blurays})
+	c.JSON(http.StatusOK, listResponse("blurays", blurays, page))
+}
+
+// filterWatched applies the ?watched=true|false filter, based on the history
+// of the current user. It writes the error response when it fails.
+func (api *API) filterWatched(c *gin.Context, filters map[string]interface{}) bool {
+	watched, err := strconv.ParseBool(c.Query("watched"))
+	if err != nil {
+		return true
+	}
+
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return false
+	}
+	if err := api.ctrl.FilterWatched(c.Request.Context(), filters, userID, watched); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// hasSameCopy reports whether the bluray already has a copy of the same
+// release bought at the same price and date
+func hasSameCopy(bluray *models.Bluray, cp *models.Copy) bool {
+	for i := range bluray.Copies {
+		if bluray.Copies[i].SameRelease(cp) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListCollections lists the collections the current user can work on. Users
+// who manage collections see every collection.
+func (api *API) ListCollections(c *gin.Context) {
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+	role, _ := c.Get("role")
+
+	collections, err := api.ctrl.ListCollections(c.Request.Context(), userID, role.(models.UserRole))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+// GetCurrentCollection returns the collection the request resolved to, and
+// the role and permissions of the current user in it
+func (api *API) GetCurrentCollection(c *gin.Context) {
+	collection, _ := c.Get("collection")
+	role, _ := c.Get("collectionRole")
+
+	permissions := []models.Permission{}
+	if granted, err := api.ctrl.GetRole(c.Request.Context(), role.(models.UserRole)); err == nil {
+		permissions = granted.Permissions
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": collection, "role": role, "permissions": permissions})
+}
+
+func (api *API) CreateCollection(c *gin.Context) {
+	var req models.CreateCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	collection := &models.Collection{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   userID,
+	}
+	if err := api.ctrl.CreateCollection(c.Request.Context(), collection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"collection": collection})
+}
+
+func (api *API) UpdateCollection(c *gin.Context) {
+	collection, ok := api.getCollection(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		collection.Name = *req.Name
+	}
+	if req.Description != nil {
+		collection.Description = *req.Description
+	}
+
+	if err := api.ctrl.UpdateCollection(c.Request.Context(), collection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": collection})
+}
+
+func (api *API) DeleteCollection(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	collection, ok := api.getCollection(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.DeleteCollection(c.Request.Context(), collection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("collection.deletedSuccessfully")})
+}
+
+// AddCollectionMember invites a user into the collection with a role
+func (api *API) AddCollectionMember(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	collection, ok := api.getCollection(c)
+	if !ok {
+		return
+	}
+
+	var req models.CollectionMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+		return
+	}
+	if !api.canGrantRole(c, req.Role) {
+		return
+	}
+
+	invitedBy, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.SetCollectionMember(c.Request.Context(), collection, userID, req.Role, invitedBy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"collection": collection})
+}
+
+// UpdateCollectionMember changes the role of a member of the collection
+func (api *API) UpdateCollectionMember(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	collection, userID, ok := api.getCollectionMember(c)
+	if !ok {
+		return
+	}
+
+	var req models.CollectionMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if collection.Member(userID) == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("collection.memberNotFound")})
+		return
+	}
+	if !api.canGrantRole(c, req.Role) {
+		return
+	}
+
+	invitedBy, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.SetCollectionMember(c.Request.Context(), collection, userID, req.Role, invitedBy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": collection})
+}
+
+func (api *API) RemoveCollectionMember(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	collection, userID, ok := api.getCollectionMember(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.RemoveCollectionMember(c.Request.Context(), collection, userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("collection.memberRemoved"), "collection": collection})
+}
+
+// getCollection loads the collection of the :id parameter. It writes the
+// error response when it fails.
+func (api *API) getCollection(c *gin.Context) (*models.Collection, bool) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return nil, false
+	}
+
+	collection, err := api.ctrl.GetCollectionByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("collection.notFound")})
+		return nil, false
+	}
+	return collection, true
+}
+
+// getCollectionMember loads the collection of the :id parameter and parses
+// the :user_id parameter. It writes the error response when it fails.
+func (api *API) getCollectionMember(c *gin.Context) (*models.Collection, primitive.ObjectID, bool) {
+	i18n := api.GetI18n(c)
+	collection, ok := api.getCollection(c)
+	if !ok {
+		return nil, primitive.NilObjectID, false
+	}
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+		return nil, primitive.NilObjectID, false
+	}
+	return collection, userID, true
+}
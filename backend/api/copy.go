@@ -0,0 +1,102 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (api *API) AddCopy(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+
+	var cp models.Copy
+	if err := c.ShouldBindJSON(&cp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := api.ctrl.GetBlurayByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bluray not found"})
+		return
+	}
+
+	bluray, err := api.ctrl.AddCopy(c.Request.Context(), id, &cp)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"bluray": bluray})
+}
+
+func (api *API) UpdateCopy(c *gin.Context) {
+	blurayID, copyID, ok := api.getBlurayCopy(c)
+	if !ok {
+		return
+	}
+
+	var cp models.Copy
+	if err := c.ShouldBindJSON(&cp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cp.ID = copyID
+	bluray, err := api.ctrl.UpdateCopy(c.Request.Context(), blurayID, &cp)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bluray": bluray})
+}
+
+func (api *API) DeleteCopy(c *gin.Context) {
+	blurayID, copyID, ok := api.getBlurayCopy(c)
+	if !ok {
+		return
+	}
+
+	bluray, err := api.ctrl.DeleteCopy(c.Request.Context(), blurayID, copyID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	i18n := api.GetI18n(c)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("copy.deletedSuccessfully"), "bluray": bluray})
+}
+
+// getBlurayCopy parses the :id and :copy_id parameters, making sure the copy
+// belongs to the bluray. It writes the error response when it fails.
+func (api *API) getBlurayCopy(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	i18n := api.GetI18n(c)
+	blurayID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	copyID, err := primitive.ObjectIDFromHex(c.Param("copy_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	bluray, err := api.ctrl.GetBlurayByID(c.Request.Context(), blurayID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bluray not found"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	if bluray.CopyByID(copyID) == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("copy.notFound")})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return blurayID, copyID, true
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (api *API) ListBlurayLoans(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+
+	if _, err := api.ctrl.GetBlurayByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bluray not found"})
+		return
+	}
+
+	loans, err := api.ctrl.ListBlurayLoans(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if loans == nil {
+		loans = []*models.Loan{}
+	}
+
+	c.JSON(http.StatusOK, listResponse("loans", loans, &models.PageInfo{Total: len(loans)}))
+}
+
+func (api *API) CreateLoan(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+
+	var req models.CreateLoanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := api.ctrl.GetBlurayByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bluray not found"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	lentBy, err := primitive.ObjectIDFromHex(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+		return
+	}
+
+	loan := &models.Loan{
+		BlurayID:        id,
+		BorrowerName:    req.BorrowerName,
+		BorrowerContact: req.BorrowerContact,
+		DueAt:           req.DueAt,
+		Notes:           req.Notes,
+		LentBy:          lentBy,
+	}
+	if req.LentAt != nil {
+		loan.LentAt = *req.LentAt
+	}
+	if req.CopyID != "" {
+		copyID, err := primitive.ObjectIDFromHex(req.CopyID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+			return
+		}
+		loan.CopyID = &copyID
+	}
+	if req.BorrowerID != "" {
+		borrowerID, err := primitive.ObjectIDFromHex(req.BorrowerID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+			return
+		}
+		loan.BorrowerID = &borrowerID
+	}
+
+	if err := api.ctrl.CreateLoan(c.Request.Context(), loan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"loan": loan})
+}
+
+func (api *API) UpdateLoan(c *gin.Context) {
+	loan, ok := api.getBlurayLoan(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateLoanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.BorrowerName != nil {
+		loan.BorrowerName = *req.BorrowerName
+	}
+	if req.BorrowerContact != nil {
+		loan.BorrowerContact = *req.BorrowerContact
+	}
+	if req.LentAt != nil {
+		loan.LentAt = *req.LentAt
+	}
+	if req.DueAt != nil {
+		loan.DueAt = req.DueAt
+	}
+	if req.Notes != nil {
+		loan.Notes = *req.Notes
+	}
+
+	if err := api.ctrl.UpdateLoan(c.Request.Context(), loan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loan": loan})
+}
+
+func (api *API) ReturnLoan(c *gin.Context) {
+	loan, ok := api.getBlurayLoan(c)
+	if !ok {
+		return
+	}
+
+	// The body is optional, the disc is returned now by default
+	var req models.ReturnLoanRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var returnedAt time.Time
+	if req.ReturnedAt != nil {
+		returnedAt = *req.ReturnedAt
+	}
+
+	if err := api.ctrl.ReturnLoan(c.Request.Context(), loan, returnedAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loan": loan})
+}
+
+func (api *API) DeleteLoan(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	loan, ok := api.getBlurayLoan(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.DeleteLoan(c.Request.Context(), loan.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("loan.deletedSuccessfully")})
+}
+
+// ListActiveLoans lists the blurays currently lent out, or only the overdue
+// ones with ?overdue=true
+func (api *API) ListActiveLoans(c *gin.Context) {
+	loans, err := api.ctrl.ListActiveLoans(c.Request.Context(), c.Query("overdue") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse("loans", loans, &models.PageInfo{Total: len(loans)}))
+}
+
+// getBlurayLoan loads the loan of the :loan_id parameter, making sure it
+// belongs to the :id bluray. It writes the error response when it fails.
+func (api *API) getBlurayLoan(c *gin.Context) (*models.Loan, bool) {
+	i18n := api.GetI18n(c)
+	blurayID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return nil, false
+	}
+	loanID, err := primitive.ObjectIDFromHex(c.Param("loan_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return nil, false
+	}
+
+	// The bluray lookup hides the loans of other collections
+	if _, err := api.ctrl.GetBlurayByID(c.Request.Context(), blurayID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("loan.notFound")})
+		return nil, false
+	}
+	loan, err := api.ctrl.GetLoanByID(c.Request.Context(), loanID)
+	if err != nil || loan.BlurayID != blurayID {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("loan.notFound")})
+		return nil, false
+	}
+	return loan, true
+}
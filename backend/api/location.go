@@ -0,0 +1,131 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (api *API) CreateLocation(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	var location models.Location
+	if err := c.ShouldBindJSON(&location); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDstr, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T("user.notFound")})
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(userIDstr.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+		return
+	}
+
+	location.CreatedBy = userID
+
+	if err := api.ctrl.CreateLocation(c.Request.Context(), &location); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"location": location})
+}
+
+func (api *API) GetLocation(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+
+	location, err := api.ctrl.GetLocationByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("location.notFound")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"location": location})
+}
+
+func (api *API) UpdateLocation(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+
+	var req models.Location
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	location, err := api.ctrl.GetLocationByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("location.notFound")})
+		return
+	}
+
+	location.Name = req.Name
+	location.Kind = req.Kind
+	location.ParentID = req.ParentID
+	location.Capacity = req.Capacity
+	location.Description = req.Description
+
+	if err := api.ctrl.UpdateLocation(c.Request.Context(), location); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"location": location})
+}
+
+func (api *API) DeleteLocation(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+
+	if err := api.ctrl.DeleteLocation(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("location.deletedSuccessfully")})
+}
+
+func (api *API) ListLocations(c *gin.Context) {
+	locations, err := api.ctrl.ListLocations(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if locations == nil {
+		locations = []*models.Location{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"locations": locations})
+}
+
+// GetLocationReport returns the capacity and fill level of every location
+func (api *API) GetLocationReport(c *gin.Context) {
+	report, err := api.ctrl.GetLocationReport(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
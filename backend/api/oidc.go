@@ -0,0 +1,74 @@
+package api
+
+import (
+	"eylexander/bluraymanager/controller"
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcBindingCookie ties a single sign-on attempt to the browser that
+// started it
+const oidcBindingCookie = "oidc_binding"
+
+// GetOIDCConfig tells the login page whether to offer single sign-on
+func (api *API) GetOIDCConfig(c *gin.Context) {
+	name, enabled := api.ctrl.OIDCProviderName()
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "name": name})
+}
+
+// StartOIDCLogin returns the identity provider page to send the user to,
+// and the state to check when they come back
+func (api *API) StartOIDCLogin(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	if _, enabled := api.ctrl.OIDCProviderName(); !enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("oidc.notConfigured")})
+		return
+	}
+
+	authURL, state, binding, err := api.ctrl.StartOIDCLogin(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	setOIDCBindingCookie(c, binding, int(controller.OIDCStateTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL, "state": state})
+}
+
+// CompleteOIDCLogin signs in the user the identity provider sent back. It
+// answers like Login.
+func (api *API) CompleteOIDCLogin(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	if _, enabled := api.ctrl.OIDCProviderName(); !enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("oidc.notConfigured")})
+		return
+	}
+
+	var req models.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The binding cookie only serves one attempt
+	binding, _ := c.Cookie(oidcBindingCookie)
+	setOIDCBindingCookie(c, "", -1)
+
+	user, err := api.ctrl.CompleteOIDCLogin(c.Request.Context(), req.Code, req.State, binding)
+	if err != nil {
+		api.loginFailed(c, err)
+		return
+	}
+
+	api.signInOrChallenge(c, user)
+}
+
+// setOIDCBindingCookie stores the binding secret where scripts cannot read
+// it, for the single sign-on routes only. A negative maxAge deletes it.
+func setOIDCBindingCookie(c *gin.Context, binding string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, binding, maxAge, "/api/v1/auth/oidc", "", secure, true)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetMyRating returns the personal rating of the bluray by the current user
+func (api *API) GetMyRating(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	userID, blurayID, ok := api.getRatedBluray(c)
+	if !ok {
+		return
+	}
+
+	rating, err := api.ctrl.GetUserRating(c.Request.Context(), userID, blurayID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("rating.notFound")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rating": rating})
+}
+
+// SetMyRating creates or replaces the personal rating of the bluray by the
+// current user
+func (api *API) SetMyRating(c *gin.Context) {
+	userID, blurayID, ok := api.getRatedBluray(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetUserRatingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rating, err := api.ctrl.SetUserRating(c.Request.Context(), userID, blurayID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rating": rating})
+}
+
+func (api *API) DeleteMyRating(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	userID, blurayID, ok := api.getRatedBluray(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.DeleteUserRating(c.Request.Context(), userID, blurayID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("rating.deletedSuccessfully")})
+}
+
+// getRatedBluray returns the current user and the bluray of the :id
+// parameter, checking that the bluray exists. It writes the error response
+// when it fails.
+func (api *API) getRatedBluray(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	i18n := api.GetI18n(c)
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	blurayID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	if _, err := api.ctrl.GetBlurayByID(c.Request.Context(), blurayID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bluray not found"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return userID, blurayID, true
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListBlurayRevisions returns the revisions of a bluray, newest first
+func (api *API) ListBlurayRevisions(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+	opts, ok := api.listOptions(c, 20)
+	if !ok {
+		return
+	}
+
+	revisions, page, err := api.ctrl.ListBlurayRevisions(c.Request.Context(), id, opts)
+	if err != nil {
+		searchFailed(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse("revisions", revisions, page))
+}
+
+func (api *API) GetBlurayRevision(c *gin.Context) {
+	id, number, ok := api.getRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	revision, err := api.ctrl.GetBlurayRevision(c.Request.Context(), id, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revision": revision})
+}
+
+// DiffBlurayRevisions compares the revisions given by the from and to query
+// parameters field by field
+func (api *API) DiffBlurayRevisions(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+	from, fromErr := strconv.Atoi(c.Query("from"))
+	to, toErr := strconv.Atoi(c.Query("to"))
+	if fromErr != nil || toErr != nil || from < 1 || to < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("revision.invalidNumber")})
+		return
+	}
+
+	diff, err := api.ctrl.DiffBlurayRevisions(c.Request.Context(), id, from, to)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"diff": diff})
+}
+
+// RestoreBlurayRevision puts a bluray back the way it was at a revision
+func (api *API) RestoreBlurayRevision(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	id, number, ok := api.getRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+		return
+	}
+
+	revision, err := api.ctrl.GetBlurayRevision(c.Request.Context(), id, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	current, err := api.ctrl.GetBlurayByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	// Going back to fewer copies deletes the others
+	if current.DropsCopies(revision.Bluray.Copies) && !api.ctrl.CheckPermission(c, models.PermBlurayDelete) {
+		return
+	}
+
+	// Restores are validated like any update
+	bluray, err := api.ctrl.RestoreBlurayRevision(c.Request.Context(), id, number)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notification := &models.Notification{
+		UserID:   userID,
+		Type:     models.NotificationBlurayReverted,
+		Message:  fmt.Sprintf(i18n.T("notification.bluray_reverted"), bluray.Title, number),
+		BlurayID: bluray.ID,
+	}
+	api.ctrl.CreateNotification(c.Request.Context(), notification)
+
+	c.JSON(http.StatusOK, gin.H{"bluray": bluray})
+}
+
+// getRevisionParams parses the :id and :number parameters
+func (api *API) getRevisionParams(c *gin.Context) (primitive.ObjectID, int, bool) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return primitive.NilObjectID, 0, false
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("revision.invalidNumber")})
+		return primitive.NilObjectID, 0, false
+	}
+	return id, number, true
+}
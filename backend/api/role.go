@@ -0,0 +1,114 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRoles lists the roles along with every permission a role can grant
+func (api *API) ListRoles(c *gin.Context) {
+	roles, err := api.ctrl.ListRoles(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles, "permissions": models.AllPermissions})
+}
+
+func (api *API) GetRole(c *gin.Context) {
+	role, ok := api.getRole(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+func (api *API) CreateRole(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !api.canGrantPermissions(c, req.Permissions) {
+		return
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+	if err := api.ctrl.CreateRole(c.Request.Context(), role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"role": role})
+}
+
+func (api *API) UpdateRole(c *gin.Context) {
+	role, ok := api.getRole(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only roles one could give out can be changed, and only to permissions
+	// one holds
+	if !api.canGrantRole(c, role.Name) {
+		return
+	}
+	if req.Permissions != nil && !api.canGrantPermissions(c, *req.Permissions) {
+		return
+	}
+
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		role.Permissions = *req.Permissions
+	}
+
+	if err := api.ctrl.UpdateRole(c.Request.Context(), role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+func (api *API) DeleteRole(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	role, ok := api.getRole(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.DeleteRole(c.Request.Context(), role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("role.deletedSuccessfully")})
+}
+
+// getRole loads the role of the :name parameter. It writes the error
+// response when it fails.
+func (api *API) getRole(c *gin.Context) (*models.Role, bool) {
+	role, err := api.ctrl.GetRole(c.Request.Context(), models.UserRole(c.Param("name")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return role, true
+}
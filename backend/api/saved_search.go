@@ -0,0 +1,182 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListSavedSearches lists the saved searches of the current user and the
+// smart collections of the collection, with the number of blurays each finds
+func (api *API) ListSavedSearches(c *gin.Context) {
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	searches, err := api.ctrl.ListSavedSearches(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"saved_searches": searches})
+}
+
+func (api *API) GetSavedSearch(c *gin.Context) {
+	search, ok := api.getSavedSearch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"saved_search": search})
+}
+
+// CreateSavedSearch saves a search for the current user
+func (api *API) CreateSavedSearch(c *gin.Context) {
+	api.createSavedSearch(c, false)
+}
+
+// CreateSmartCollection shares a saved search with every user of the
+// collection
+func (api *API) CreateSmartCollection(c *gin.Context) {
+	api.createSavedSearch(c, true)
+}
+
+func (api *API) createSavedSearch(c *gin.Context, shared bool) {
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateSavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	search, err := api.ctrl.CreateSavedSearch(c.Request.Context(), userID, &req, shared)
+	if err != nil {
+		searchFailed(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"saved_search": search})
+}
+
+// UpdateSavedSearch edits a saved search of the current user
+func (api *API) UpdateSavedSearch(c *gin.Context) {
+	if search, ok := api.getOwnSavedSearch(c, false); ok {
+		api.updateSavedSearch(c, search)
+	}
+}
+
+// UpdateSmartCollection edits a smart collection of the collection
+func (api *API) UpdateSmartCollection(c *gin.Context) {
+	if search, ok := api.getOwnSavedSearch(c, true); ok {
+		api.updateSavedSearch(c, search)
+	}
+}
+
+func (api *API) updateSavedSearch(c *gin.Context, search *models.SavedSearch) {
+	var req models.UpdateSavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := api.ctrl.UpdateSavedSearch(c.Request.Context(), search, &req); err != nil {
+		searchFailed(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"saved_search": search})
+}
+
+// DeleteSavedSearch deletes a saved search of the current user
+func (api *API) DeleteSavedSearch(c *gin.Context) {
+	if search, ok := api.getOwnSavedSearch(c, false); ok {
+		api.deleteSavedSearch(c, search)
+	}
+}
+
+// DeleteSmartCollection deletes a smart collection of the collection
+func (api *API) DeleteSmartCollection(c *gin.Context) {
+	if search, ok := api.getOwnSavedSearch(c, true); ok {
+		api.deleteSavedSearch(c, search)
+	}
+}
+
+func (api *API) deleteSavedSearch(c *gin.Context, search *models.SavedSearch) {
+	i18n := api.GetI18n(c)
+	if err := api.ctrl.DeleteSavedSearch(c.Request.Context(), search.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("savedSearch.deletedSuccessfully")})
+}
+
+// RunSavedSearch returns a page of the blurays a saved search or smart
+// collection finds, in the order it was saved with
+func (api *API) RunSavedSearch(c *gin.Context) {
+	search, ok := api.getSavedSearch(c)
+	if !ok {
+		return
+	}
+
+	opts, ok := api.queryOptions(c)
+	if !ok {
+		return
+	}
+	blurays, page, err := api.ctrl.RunSavedSearch(c.Request.Context(), search, opts)
+	if err != nil {
+		searchFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := listResponse("blurays", blurays, page)
+	response["saved_search"] = search
+	c.JSON(http.StatusOK, response)
+}
+
+// getSavedSearch loads the saved search of the :id parameter, a search of the
+// current user or a smart collection of the collection. It writes the error
+// response when it fails.
+func (api *API) getSavedSearch(c *gin.Context) (*models.SavedSearch, bool) {
+	i18n := api.GetI18n(c)
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return nil, false
+	}
+
+	search, err := api.ctrl.GetSavedSearch(c.Request.Context(), userID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return search, true
+}
+
+// getOwnSavedSearch loads the saved search of the :id parameter like
+// getSavedSearch, when it is a smart collection or a personal search as
+// asked. Smart collections are changed through their own routes.
+func (api *API) getOwnSavedSearch(c *gin.Context, shared bool) (*models.SavedSearch, bool) {
+	i18n := api.GetI18n(c)
+	search, ok := api.getSavedSearch(c)
+	if !ok {
+		return nil, false
+	}
+	if search.Shared != shared {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("savedSearch.notFound")})
+		return nil, false
+	}
+	return search, true
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshSession exchanges a refresh token for a new access token and a new
+// refresh token
+func (api *API) RefreshSession(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := api.ctrl.RefreshSession(c.Request.Context(), req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends the session of the refresh token. It does not need a valid
+// access token, so that clients can sign out after it expired.
+func (api *API) Logout(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := api.ctrl.EndSession(c.Request.Context(), req.RefreshToken); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("session.loggedOut")})
+}
+
+// ListSessions lists the devices the current user is signed in on
+func (api *API) ListSessions(c *gin.Context) {
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := api.ctrl.ListSessions(c.Request.Context(), userID, api.currentSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (api *API) RevokeSession(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return
+	}
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.RevokeSession(c.Request.Context(), userID, sessionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("session.revokedSuccessfully")})
+}
+
+// RevokeOtherSessions signs the current user out of every other device
+func (api *API) RevokeOtherSessions(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.RevokeOtherSessions(c.Request.Context(), userID, api.currentSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("session.revokedSuccessfully")})
+}
+
+// currentSessionID returns the session the access token of the request was
+// issued for
+func (api *API) currentSessionID(c *gin.Context) primitive.ObjectID {
+	sessionID, _ := c.Get("sessionID")
+	hex, _ := sessionID.(string)
+	id, _ := primitive.ObjectIDFromHex(hex)
+	return id
+}
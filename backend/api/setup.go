@@ -12,7 +12,7 @@ func (api *API) CheckSetup(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Try to find an admin user
-	users, err := api.ctrl.ListUsers(ctx, 0, 1)
+	users, _, err := api.ctrl.ListUsers(ctx, &models.QueryOptions{Limit: 1})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -21,7 +21,7 @@ func (api *API) CheckSetup(c *gin.Context) {
 	needsSetup := true
 	if len(users) > 0 {
 		// Check if any admin exists
-		allUsers, _ := api.ctrl.ListUsers(ctx, 0, 100)
+		allUsers, _, _ := api.ctrl.ListUsers(ctx, &models.QueryOptions{Limit: 100})
 		for _, user := range allUsers {
 			if user.Role == models.RoleAdmin {
 				needsSetup = false
@@ -39,7 +39,7 @@ func (api *API) InitialSetup(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Check if any admin already exists
-	allUsers, _ := api.ctrl.ListUsers(ctx, 0, 100)
+	allUsers, _, _ := api.ctrl.ListUsers(ctx, &models.QueryOptions{Limit: 100})
 	for _, user := range allUsers {
 		if user.Role == models.RoleAdmin {
 			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("setup.adminAlreadyExists")})
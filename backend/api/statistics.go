@@ -4,10 +4,33 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// GetStatistics returns the collection statistics. The rating statistics are
+// those of the household, or of one user with ?user_id=<id> or ?user_id=me.
 func (api *API) GetStatistics(c *gin.Context) {
-	stats, err := api.ctrl.GetStatistics(c.Request.Context())
+	i18n := api.GetI18n(c)
+
+	var ratedBy *primitive.ObjectID
+	switch userID := c.Query("user_id"); userID {
+	case "":
+	case "me":
+		id, ok := api.currentUserID(c)
+		if !ok {
+			return
+		}
+		ratedBy = &id
+	default:
+		id, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+			return
+		}
+		ratedBy = &id
+	}
+
+	stats, err := api.ctrl.GetStatistics(c.Request.Context(), ratedBy)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -86,6 +86,11 @@ func (api *API) DeleteTag(c *gin.Context) {
 		return
 	}
 
+	if _, err := api.ctrl.GetTagByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("tag.notFound")})
+		return
+	}
+
 	if err := api.ctrl.DeleteTag(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
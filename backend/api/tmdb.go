@@ -2,15 +2,33 @@ package api
 
 import (
 	"encoding/json"
+	"eylexander/bluraymanager/models"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-const tmdbBaseURL = "https://api.themoviedb.org/3"
+const defaultTMDBBaseURL = "https://api.themoviedb.org/3"
+
+// tmdbSeasonBatch is the most seasons TMDB appends to a series response
+const tmdbSeasonBatch = 20
+
+// tmdbClient makes the TMDB requests, giving up on a slow upstream
+var tmdbClient = &http.Client{Timeout: 10 * time.Second}
+
+// tmdbBaseURL returns the TMDB API root, which TMDB_API_URL can point at a
+// proxy or mirror
+func tmdbBaseURL() string {
+	if baseURL := os.Getenv("TMDB_API_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return defaultTMDBBaseURL
+}
 
 // SearchTMDB handles the search endpoint
 func (api *API) SearchTMDB(c *gin.Context) {
@@ -47,7 +65,7 @@ func (api *API) SearchTMDB(c *gin.Context) {
 		}
 	}
 
-	reqURL := fmt.Sprintf("%s/search/%s?%s", tmdbBaseURL, mediaType, params.Encode())
+	reqURL := fmt.Sprintf("%s/search/%s?%s", tmdbBaseURL(), mediaType, params.Encode())
 
 	result, err := api.fetchTMDB(reqURL)
 	if err != nil {
@@ -127,7 +145,7 @@ func (api *API) GetTMDBDetails(c *gin.Context) {
 		params.Add("append_to_response", "credits")
 	}
 
-	reqURL := fmt.Sprintf("%s/%s/%s?%s", tmdbBaseURL, mediaType, id, params.Encode())
+	reqURL := fmt.Sprintf("%s/%s/%s?%s", tmdbBaseURL(), mediaType, id, params.Encode())
 
 	result, err := api.fetchTMDB(reqURL)
 	if err != nil {
@@ -150,14 +168,93 @@ func (api *API) GetTMDBDetails(c *gin.Context) {
 		if name, ok := result["name"]; ok {
 			result["title"] = name
 		}
+		api.attachSeasonEpisodes(result, id, lang, apiKey)
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
+// attachSeasonEpisodes fetches the episodes of every regular season of a
+// series and adds them to the season entries, shaped like models.Episode.
+// The seasons are appended to series requests, tmdbSeasonBatch at a time.
+// Specials (season 0) are left out, and a season that cannot be fetched
+// keeps its episode count only.
+func (api *API) attachSeasonEpisodes(result map[string]interface{}, id, lang, apiKey string) {
+	seasons, ok := result["seasons"].([]interface{})
+	if !ok {
+		return
+	}
+
+	var numbers []int
+	byNumber := map[int]map[string]interface{}{}
+	for _, item := range seasons {
+		season, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		number, _ := season["season_number"].(float64)
+		if number <= 0 {
+			continue
+		}
+		numbers = append(numbers, int(number))
+		byNumber[int(number)] = season
+	}
+
+	params := url.Values{}
+	params.Add("api_key", apiKey)
+	params.Add("language", lang)
+	for start := 0; start < len(numbers); start += tmdbSeasonBatch {
+		batch := numbers[start:min(start+tmdbSeasonBatch, len(numbers))]
+		appended := make([]string, len(batch))
+		for i, number := range batch {
+			appended[i] = fmt.Sprintf("season/%d", number)
+		}
+		params.Set("append_to_response", strings.Join(appended, ","))
+
+		reqURL := fmt.Sprintf("%s/tv/%s?%s", tmdbBaseURL(), id, params.Encode())
+		details, err := api.fetchTMDB(reqURL)
+		if err != nil {
+			continue
+		}
+		for i, number := range batch {
+			if season, ok := details[appended[i]].(map[string]interface{}); ok {
+				byNumber[number]["episodes"] = parseTMDBEpisodes(season)
+			}
+		}
+	}
+}
+
+// parseTMDBEpisodes converts the episodes of a TMDB season
+func parseTMDBEpisodes(season map[string]interface{}) []models.Episode {
+	items, _ := season["episodes"].([]interface{})
+	episodes := make([]models.Episode, 0, len(items))
+	for _, item := range items {
+		raw, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		number, _ := raw["episode_number"].(float64)
+		runtime, _ := raw["runtime"].(float64)
+		episode := models.Episode{
+			Number:  int(number),
+			Runtime: int(runtime),
+		}
+		episode.Title, _ = raw["name"].(string)
+		episode.Overview, _ = raw["overview"].(string)
+		if airDate, ok := raw["air_date"].(string); ok {
+			if date, err := time.Parse("2006-01-02", airDate); err == nil {
+				episode.AirDate = &date
+			}
+		}
+		episodes = append(episodes, episode)
+	}
+	return episodes
+}
+
 // fetchTMDB handles the HTTP GET and JSON decoding
 func (api *API) fetchTMDB(url string) (map[string]interface{}, error) {
-	resp, err := http.Get(url)
+	resp, err := tmdbClient.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -189,7 +286,7 @@ func (api *API) enrichWithLocalization(result map[string]interface{}, mediaType,
 	params.Add("api_key", apiKey)
 	params.Add("language", otherLang)
 
-	urlLocalized := fmt.Sprintf("%s/%s/%s?%s", tmdbBaseURL, mediaType, id, params.Encode())
+	urlLocalized := fmt.Sprintf("%s/%s/%s?%s", tmdbBaseURL(), mediaType, id, params.Encode())
 
 	localizedResult, err := api.fetchTMDB(urlLocalized)
 	if err != nil {
@@ -302,7 +399,7 @@ func (api *API) FindByExternalID(c *gin.Context) {
 			params.Add("append_to_response", "credits")
 		}
 
-		reqURL := fmt.Sprintf("%s/%s/%s?%s", tmdbBaseURL, mediaType, externalID, params.Encode())
+		reqURL := fmt.Sprintf("%s/%s/%s?%s", tmdbBaseURL(), mediaType, externalID, params.Encode())
 		result, err := api.fetchTMDB(reqURL)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T("tmdb.failedToFetchDetails")})
@@ -338,7 +435,7 @@ func (api *API) FindByExternalID(c *gin.Context) {
 	params.Add("language", lang)
 	params.Add("external_source", source)
 
-	reqURL := fmt.Sprintf("%s/find/%s?%s", tmdbBaseURL, externalID, params.Encode())
+	reqURL := fmt.Sprintf("%s/find/%s?%s", tmdbBaseURL(), externalID, params.Encode())
 
 	result, err := api.fetchTMDB(reqURL)
 	if err != nil {
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (api *API) GetTwoFactorStatus(c *gin.Context) {
+	user, ok := api.currentUser(c)
+	if !ok {
+		return
+	}
+
+	status, err := api.ctrl.TwoFactorStatus(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"two_factor": status})
+}
+
+// EnrollTwoFactor starts setting up an authenticator app
+func (api *API) EnrollTwoFactor(c *gin.Context) {
+	user, ok := api.currentUser(c)
+	if !ok {
+		return
+	}
+
+	enrolment, err := api.ctrl.EnrollTwoFactor(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrolment)
+}
+
+// ConfirmTwoFactor turns two-factor authentication on with a first code from
+// the authenticator app. The recovery codes are only shown here.
+func (api *API) ConfirmTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := api.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := api.ctrl.ConfirmTwoFactor(c.Request.Context(), user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (api *API) DisableTwoFactor(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := api.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.DisableTwoFactor(c.Request.Context(), user, req.Password, req.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("twoFactor.disabled")})
+}
+
+func (api *API) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := api.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := api.ctrl.RegenerateRecoveryCodes(c.Request.Context(), user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListWatchEvents lists the viewing history of the current user, optionally
+// for a single bluray with ?bluray_id=
+func (api *API) ListWatchEvents(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	opts, ok := api.listOptions(c, 50)
+	if !ok {
+		return
+	}
+
+	var blurayID *primitive.ObjectID
+	if hex := c.Query("bluray_id"); hex != "" {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+			return
+		}
+		blurayID = &id
+	}
+
+	events, page, err := api.ctrl.ListWatchEvents(c.Request.Context(), userID, blurayID, opts)
+	if err != nil {
+		searchFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse("events", events, page))
+}
+
+// LogWatchEvent adds a viewing to the history of the current user
+func (api *API) LogWatchEvent(c *gin.Context) {
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateWatchEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := api.ctrl.LogWatchEvent(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"event": event})
+}
+
+func (api *API) UpdateWatchEvent(c *gin.Context) {
+	event, ok := api.getWatchEvent(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWatchEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.WatchedAt != nil {
+		event.WatchedAt = *req.WatchedAt
+	}
+	if req.Rating != nil {
+		event.Rating = *req.Rating
+	}
+	if req.Notes != nil {
+		event.Notes = *req.Notes
+	}
+
+	if err := api.ctrl.UpdateWatchEvent(c.Request.Context(), event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"event": event})
+}
+
+func (api *API) DeleteWatchEvent(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	event, ok := api.getWatchEvent(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.DeleteWatchEvent(c.Request.Context(), event.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("watch.deletedSuccessfully")})
+}
+
+// GetWatchStatistics returns the viewing statistics of the current user
+func (api *API) GetWatchStatistics(c *gin.Context) {
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	stats, err := api.ctrl.GetWatchStatistics(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"statistics": stats})
+}
+
+// getWatchEvent loads the watch event of the :id parameter. Events of other
+// users are reported as not found. It writes the error response when it fails.
+func (api *API) getWatchEvent(c *gin.Context) (*models.WatchEvent, bool) {
+	i18n := api.GetI18n(c)
+	userID, ok := api.currentUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return nil, false
+	}
+
+	event, err := api.ctrl.GetWatchEventByID(c.Request.Context(), id)
+	if err != nil || event.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("watch.notFound")})
+		return nil, false
+	}
+	return event, true
+}
+
+// currentUserID returns the ID of the authenticated user. It writes the error
+// response when it fails.
+func (api *API) currentUserID(c *gin.Context) (primitive.ObjectID, bool) {
+	i18n := api.GetI18n(c)
+	userID, _ := c.Get("userID")
+	id, err := primitive.ObjectIDFromHex(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"eylexander/bluraymanager/models"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListWishlistItems lists the items still wanted, or the acquired ones with
+// ?acquired=true
+func (api *API) ListWishlistItems(c *gin.Context) {
+	items, err := api.ctrl.ListWishlistItems(c.Request.Context(), c.Query("acquired") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if items == nil {
+		items = []*models.WishlistItem{}
+	}
+
+	c.JSON(http.StatusOK, listResponse("items", items, &models.PageInfo{Total: len(items)}))
+}
+
+// CreateWishlistItem adds an item to the wishlist. The title fields are the
+// ones returned by the TMDB search and details endpoints.
+func (api *API) CreateWishlistItem(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	var item models.WishlistItem
+	if err := c.ShouldBindJSON(&item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	addedBy, err := primitive.ObjectIDFromHex(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+		return
+	}
+	item.AddedBy = addedBy
+
+	if err := api.ctrl.CreateWishlistItem(c.Request.Context(), &item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"item": item})
+}
+
+func (api *API) GetWishlistItem(c *gin.Context) {
+	item, ok := api.getWishlistItem(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+func (api *API) UpdateWishlistItem(c *gin.Context) {
+	item, ok := api.getWishlistItem(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWishlistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.DesiredFormat != nil {
+		item.DesiredFormat = *req.DesiredFormat
+	}
+	if req.DesiredEdition != nil {
+		item.DesiredEdition = *req.DesiredEdition
+	}
+	if req.MaxPrice != nil {
+		item.MaxPrice = *req.MaxPrice
+	}
+	if req.Priority != nil {
+		item.Priority = *req.Priority
+	}
+	if req.Notes != nil {
+		item.Notes = *req.Notes
+	}
+
+	if err := api.ctrl.UpdateWishlistItem(c.Request.Context(), item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+func (api *API) DeleteWishlistItem(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	item, ok := api.getWishlistItem(c)
+	if !ok {
+		return
+	}
+
+	if err := api.ctrl.DeleteWishlistItem(c.Request.Context(), item.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T("wishlist.deletedSuccessfully")})
+}
+
+// AcquireWishlistItem marks the item as bought and adds the copy to the
+// collection
+func (api *API) AcquireWishlistItem(c *gin.Context) {
+	i18n := api.GetI18n(c)
+	item, ok := api.getWishlistItem(c)
+	if !ok {
+		return
+	}
+
+	// The body is optional, a copy bought today at no recorded price by default
+	var req models.AcquireWishlistItemRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID, _ := c.Get("userID")
+	addedBy, err := primitive.ObjectIDFromHex(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+		return
+	}
+
+	bluray, err := api.ctrl.AcquireWishlistItem(c.Request.Context(), item, &req, addedBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notification := &models.Notification{
+		UserID:   addedBy,
+		Type:     models.NotificationBlurayAdded,
+		Message:  fmt.Sprintf(i18n.T("notification.bluray_added"), bluray.Title),
+		BlurayID: bluray.ID,
+	}
+	api.ctrl.CreateNotification(c.Request.Context(), notification)
+
+	c.JSON(http.StatusOK, gin.H{"item": item, "bluray": bluray})
+}
+
+// getWishlistItem loads the wishlist item of the :id parameter. It writes the
+// error response when it fails.
+func (api *API) getWishlistItem(c *gin.Context) (*models.WishlistItem, bool) {
+	i18n := api.GetI18n(c)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidID")})
+		return nil, false
+	}
+
+	item, err := api.ctrl.GetWishlistItemByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T("wishlist.notFound")})
+		return nil, false
+	}
+	return item, true
+}
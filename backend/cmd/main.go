@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"eylexander/bluraymanager/datastore"
@@ -20,32 +23,34 @@ func main() {
 	}
 
 	// Get configuration from environment
-	mongoURI := os.Getenv("MONGODB_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
-	}
-
-	dbName := os.Getenv("DATABASE_NAME")
-	if dbName == "" {
-		dbName = "bluray_manager"
-	}
-
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	// Initialize MongoDB datastore
+	// Initialize datastore
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	ds, err := datastore.NewMongoDatastore(ctx, mongoURI, dbName)
-	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
-	}
+	ds := openDatastore(ctx)
 	defer ds.Close(context.Background())
 
-	log.Println("Successfully connected to MongoDB")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := runMigrate(ds, os.Args[2:])
+		ds.Close(context.Background())
+		os.Exit(code)
+	}
+
+	// Refuse to serve on an outdated schema
+	applied, err := datastore.PrepareSchema(context.Background(), ds, os.Getenv("AUTO_MIGRATE") == "true")
+	for _, m := range applied {
+		log.Printf("Applied migration %d: %s", m.Version, m.Description)
+	}
+	if errors.Is(err, datastore.ErrPendingMigrations) {
+		log.Fatalf("%v\nRun \"%s migrate up\" or set AUTO_MIGRATE=true", err, filepath.Base(os.Args[0]))
+	} else if err != nil {
+		log.Fatalf("Failed to check database schema: %v", err)
+	}
 
 	// Initialize guest user
 	created, err := ds.EnsureGuestUser(context.Background())
@@ -63,3 +68,49 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// openDatastore connects to the backend selected by DATASTORE
+func openDatastore(ctx context.Context) datastore.Datastore {
+	var ds datastore.Datastore
+	switch backend := strings.ToLower(os.Getenv("DATASTORE")); backend {
+	case "", "mongo", "mongodb":
+		mongoURI := os.Getenv("MONGODB_URI")
+		if mongoURI == "" {
+			mongoURI = "mongodb://localhost:27017"
+		}
+
+		dbName := os.Getenv("DATABASE_NAME")
+		if dbName == "" {
+			dbName = "bluray_manager"
+		}
+
+		mongoDS, err := datastore.NewMongoDatastore(ctx, mongoURI, dbName)
+		if err != nil {
+			log.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+		ds = mongoDS
+
+		log.Println("Successfully connected to MongoDB")
+	case "sqlite":
+		sqlitePath := os.Getenv("SQLITE_PATH")
+		if sqlitePath == "" {
+			sqlitePath = "bluray_manager.db"
+		}
+
+		sqliteDS, err := datastore.NewSQLiteDatastore(ctx, sqlitePath)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		ds = sqliteDS
+
+		log.Printf("Successfully opened SQLite database at %s", sqlitePath)
+	case "memory":
+		ds = datastore.NewMemoryDatastore()
+
+		log.Println("Using in-memory datastore, data will be lost on shutdown")
+	default:
+		log.Fatalf("Unknown DATASTORE %q (expected \"mongo\", \"sqlite\" or \"memory\")", backend)
+	}
+
+	return ds
+}
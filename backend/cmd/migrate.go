@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"eylexander/bluraymanager/datastore"
+)
+
+// runMigrate implements the "migrate up [steps]", "migrate down [steps]" and
+// "migrate status" commands and returns the process exit code
+func runMigrate(ds datastore.Datastore, args []string) int {
+	migrator, ok := ds.(datastore.Migrator)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "This datastore has no versioned schema, nothing to migrate")
+		return 1
+	}
+
+	if len(args) == 0 || len(args) > 2 {
+		return migrateUsage()
+	}
+
+	steps := 0
+	if len(args) == 2 {
+		var err error
+		if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+			return migrateUsage()
+		}
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.MigrateUp(ctx, steps)
+		for _, m := range applied {
+			fmt.Printf("Applied migration %d: %s\n", m.Version, m.Description)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+	case "down":
+		reverted, err := migrator.MigrateDown(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted migration %d: %s\n", m.Version, m.Description)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No migration to revert")
+		}
+	case "status":
+		if len(args) != 1 {
+			return migrateUsage()
+		}
+		statuses, err := migrator.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT\tDESCRIPTION")
+		for _, status := range statuses {
+			state, appliedAt := "pending", "-"
+			if status.Applied {
+				state = "applied"
+				appliedAt = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			if status.Unknown {
+				state = "unknown"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, state, appliedAt, status.Description)
+		}
+		w.Flush()
+	default:
+		return migrateUsage()
+	}
+	return 0
+}
+
+func migrateUsage() int {
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s migrate up [steps]    apply pending migrations (all by default)\n", name)
+	fmt.Fprintf(os.Stderr, "  %s migrate down [steps]  revert applied migrations (one by default)\n", name)
+	fmt.Fprintf(os.Stderr, "  %s migrate status        list migrations and whether they are applied\n", name)
+	return 2
+}
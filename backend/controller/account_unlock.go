@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"eylexander/bluraymanager/models"
+	"eylexander/bluraymanager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountUnlockHandler lets users lift the lock of their account through a
+// link sent by email, instead of waiting for the lock to end
+type AccountUnlockHandler struct {
+	emailService *services.EmailService
+	ctrl         *Controller
+}
+
+func (c *Controller) NewAccountUnlockHandler(emailService *services.EmailService) *AccountUnlockHandler {
+	return &AccountUnlockHandler{
+		emailService: emailService,
+		ctrl:         c,
+	}
+}
+
+// RequestUnlock sends an unlock link when the account of the email is locked
+func (h *AccountUnlockHandler) RequestUnlock(ctx *gin.Context) {
+	i18n := h.ctrl.GetI18n(ctx)
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.emailService.IsConfigured() {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": i18n.T("passwordReset.emailServiceNotConfigured")})
+		return
+	}
+
+	// The answer is the same whether the account exists and is locked or
+	// not, so that it reveals nothing
+	user, err := h.ctrl.ds.GetUserByEmail(ctx.Request.Context(), req.Email)
+	if err != nil || !user.Lockout.IsLocked(time.Now()) {
+		ctx.JSON(http.StatusOK, gin.H{"message": i18n.T("lockout.unlockLinkSent")})
+		return
+	}
+
+	token, err := h.ctrl.NewUnlockToken(ctx.Request.Context(), user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	appURL := ctx.GetHeader("Origin")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+	if err := h.emailService.SendAccountUnlockEmail(user.Email, token, appURL); err != nil {
+		log.Printf("ERROR RequestUnlock: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T("lockout.failedToSendUnlockEmail")})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": i18n.T("lockout.unlockLinkSent")})
+}
+
+// Unlock lifts the lock of the account the link was sent for
+func (h *AccountUnlockHandler) Unlock(ctx *gin.Context) {
+	i18n := h.ctrl.GetI18n(ctx)
+	var req models.UnlockAccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.ctrl.UnlockWithToken(ctx.Request.Context(), req.Token); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": i18n.T("lockout.accountUnlocked")})
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// apiTokenPrefix marks personal API tokens, so that AuthMiddleware can tell
+// them from JWTs and so that leaked tokens are easy to search for
+const apiTokenPrefix = "bmt_"
+
+// apiTokenTouchInterval limits how often the last use of a token is written
+const apiTokenTouchInterval = time.Minute
+
+// CreateAPIToken creates a personal API token for the user. The token itself
+// is returned only here.
+func (c *Controller) CreateAPIToken(ctx context.Context, userID primitive.ObjectID, req *models.CreateAPITokenRequest) (*models.APIToken, string, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", errors.New(i18n.T("apiToken.nameRequired"))
+	}
+	scopes := []models.TokenScope{}
+	seen := make(map[models.TokenScope]bool)
+	for _, scope := range req.Scopes {
+		if !scope.IsValid() {
+			return nil, "", errors.New(i18n.T("apiToken.invalidScope"))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = append(scopes, models.ScopeRead)
+	}
+
+	secret, err := newTokenSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	raw := apiTokenPrefix + secret
+	token := &models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(raw),
+		Prefix:    raw[:len(apiTokenPrefix)+8],
+		Scopes:    scopes,
+	}
+	if err := c.ds.CreateAPIToken(ctx, token); err != nil {
+		return nil, "", err
+	}
+	return token, raw, nil
+}
+
+func (c *Controller) ListAPITokens(ctx context.Context, userID primitive.ObjectID) ([]*models.APIToken, error) {
+	tokens, err := c.ds.ListUserAPITokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []*models.APIToken{}
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken deletes one of the tokens of the user
+func (c *Controller) RevokeAPIToken(ctx context.Context, userID, tokenID primitive.ObjectID) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	token, err := c.ds.GetAPITokenByID(ctx, tokenID)
+	if err != nil || token.UserID != userID {
+		return errors.New(i18n.T("apiToken.notFound"))
+	}
+	return c.ds.DeleteAPIToken(ctx, token.ID)
+}
+
+// IsAPIToken reports whether a bearer token is a personal API token rather
+// than a JWT
+func IsAPIToken(bearer string) bool {
+	return strings.HasPrefix(bearer, apiTokenPrefix)
+}
+
+// AuthenticateAPIToken returns a personal API token and its owner, and
+// records that the token was used
+func (c *Controller) AuthenticateAPIToken(ctx context.Context, raw string) (*models.APIToken, *models.User, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	token, err := c.ds.GetAPITokenByHash(ctx, hashToken(raw))
+	if err != nil {
+		return nil, nil, errors.New(i18n.T("apiToken.invalid"))
+	}
+	user, err := c.ds.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, nil, errors.New(i18n.T("apiToken.invalid"))
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenTouchInterval {
+		if err := c.ds.TouchAPIToken(ctx, token.ID, now); err != nil {
+			return nil, nil, err
+		}
+		token.LastUsedAt = &now
+	}
+	return token, user, nil
+}
+
+// tokenScopeAllows reports whether the scopes of an API token cover a
+// request needing the permission. Reading needs no particular scope, changes
+// need write, and administration needs admin.
+func tokenScopeAllows(token *models.APIToken, permission models.Permission, method string) bool {
+	if permission.IsAdmin() {
+		return token.HasScope(models.ScopeAdmin)
+	}
+	return isReadOnlyMethod(method) || token.HasScope(models.ScopeWrite)
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type auditContextKey struct{}
+
+// auditSource is who makes the changes of a request, and how
+type auditSource struct {
+	ActorID   *primitive.ObjectID
+	ActorName string
+	Request   models.AuditRequest
+}
+
+// auditSkippedFields are left out of the recorded changes: they are either
+// implied by the entry or change on every write
+var auditSkippedFields = map[string]bool{"id": true, "created_at": true, "updated_at": true}
+
+// auditSourceFromContext returns the source attached to ctx. Calls made
+// outside of a request, such as the background jobs, have none.
+func auditSourceFromContext(ctx context.Context) auditSource {
+	source, _ := ctx.Value(auditContextKey{}).(auditSource)
+	return source
+}
+
+// withAuditActor records the signed-in user, and the personal API token used
+// if any, as the author of the changes made with ctx
+func withAuditActor(ctx context.Context, user *models.User, token *models.APIToken) context.Context {
+	source := auditSourceFromContext(ctx)
+	source.ActorID = &user.ID
+	source.ActorName = user.Username
+	if token != nil {
+		source.Request.APITokenID = &token.ID
+	}
+	return context.WithValue(ctx, auditContextKey{}, source)
+}
+
+// AuditMiddleware attaches the client address and the route of the request
+// to the context, for the audit entries of the changes it makes.
+// AuthMiddleware adds the signed-in user.
+func (c *Controller) AuditMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		source := auditSource{Request: models.AuditRequest{
+			IPAddress: ctx.ClientIP(),
+			UserAgent: ctx.Request.UserAgent(),
+			Method:    ctx.Request.Method,
+			Path:      ctx.Request.URL.Path,
+		}}
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), auditContextKey{}, source))
+
+		ctx.Next()
+	}
+}
+
+// audit records a change to a bluray, tag, user or role. before is nil for
+// creations and after for deletions. The change is already made, so a
+// failure to record it is logged rather than returned.
+func (c *Controller) audit(ctx context.Context, action models.AuditAction, before, after interface{}) {
+	subject := after
+	if subject == nil {
+		subject = before
+	}
+	entry := &models.AuditEntry{Action: action}
+	switch record := subject.(type) {
+	case *models.Bluray:
+		entry.EntityType = models.AuditEntityBluray
+		entry.EntityID = record.ID.Hex()
+		entry.EntityName = record.Title
+		entry.CollectionID = auditCollection(record.CollectionID)
+	case *models.Tag:
+		entry.EntityType = models.AuditEntityTag
+		entry.EntityID = record.ID.Hex()
+		entry.EntityName = record.Name
+		entry.CollectionID = auditCollection(record.CollectionID)
+	case *models.User:
+		entry.EntityType = models.AuditEntityUser
+		entry.EntityID = record.ID.Hex()
+		entry.EntityName = record.Username
+	case *models.Role:
+		entry.EntityType = models.AuditEntityRole
+		entry.EntityID = string(record.Name)
+		entry.EntityName = string(record.Name)
+	default:
+		log.Printf("ERROR audit: cannot record changes to %T", subject)
+		return
+	}
+
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		log.Printf("ERROR audit %s %s %s: %v", action, entry.EntityType, entry.EntityID, err)
+		return
+	}
+	c.writeAuditEntry(ctx, entry, changes)
+}
+
+// auditSecret records that a secret of the user, such as their password,
+// was changed, without its value
+func (c *Controller) auditSecret(ctx context.Context, user *models.User, field string) {
+	entry := &models.AuditEntry{
+		Action:     models.AuditUpdate,
+		EntityType: models.AuditEntityUser,
+		EntityID:   user.ID.Hex(),
+		EntityName: user.Username,
+	}
+	c.writeAuditEntry(ctx, entry, []models.AuditChange{{Field: field}})
+}
+
+func (c *Controller) writeAuditEntry(ctx context.Context, entry *models.AuditEntry, changes []models.AuditChange) {
+	// Updates that change nothing leave no trace
+	if entry.Action == models.AuditUpdate && len(changes) == 0 {
+		return
+	}
+	source := auditSourceFromContext(ctx)
+	entry.ActorID = source.ActorID
+	entry.ActorName = source.ActorName
+	entry.Request = source.Request
+	entry.Changes = changes
+	if err := c.ds.CreateAuditEntry(ctx, entry); err != nil {
+		log.Printf("ERROR CreateAuditEntry: %v", err)
+	}
+}
+
+func auditCollection(id primitive.ObjectID) *primitive.ObjectID {
+	if id.IsZero() {
+		return nil
+	}
+	return &id
+}
+
+// auditChanges compares the JSON fields of two versions of a record. Fields
+// going from one empty value to another, such as from null to [], are not
+// changes, so that creations and deletions only list the fields set.
+func auditChanges(before, after interface{}) ([]models.AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []models.AuditChange{}
+	for _, name := range names {
+		prev, next := beforeFields[name], afterFields[name]
+		if bytes.Equal(prev, next) {
+			continue
+		}
+		if isEmptyJSON(prev) && isEmptyJSON(next) {
+			continue
+		}
+		changes = append(changes, models.AuditChange{Field: name, Before: prev, After: next})
+	}
+	return changes, nil
+}
+
+// auditFields returns the top-level JSON fields of a record, without the
+// ones computed when it is sent out, which are not stored
+func auditFields(record interface{}) (map[string]json.RawMessage, error) {
+	if record == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(record)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("bson") == "-" {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			delete(fields, name)
+		}
+	}
+	for name := range auditSkippedFields {
+		delete(fields, name)
+	}
+	return fields, nil
+}
+
+// isEmptyJSON reports whether a JSON value is missing or the zero value of
+// its type
+func isEmptyJSON(value json.RawMessage) bool {
+	switch string(value) {
+	case "", "null", `""`, "0", "false", "[]", "{}", `"0001-01-01T00:00:00Z"`:
+		return true
+	}
+	return false
+}
+
+// ListAuditEntries returns the page of the options of the audit log, newest
+// first
+func (c *Controller) ListAuditEntries(ctx context.Context, filter models.AuditFilter, opts *models.QueryOptions) ([]*models.AuditEntry, *models.PageInfo, error) {
+	return offsetPage(ctx, opts, func(skip, limit int) ([]*models.AuditEntry, error) {
+		return c.ds.ListAuditEntries(ctx, filter, skip, limit)
+	}, func() (int, error) {
+		return c.ds.CountAuditEntries(ctx, filter)
+	})
+}
+
+// WatchAuditRetention deletes the audit entries older than the retention
+// period of the settings every interval until ctx is done. A period of zero
+// keeps them forever.
+func (c *Controller) WatchAuditRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.PurgeAuditLog(ctx, time.Now()); err != nil {
+			log.Printf("ERROR PurgeAuditLog: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeAuditLog deletes the audit entries that are past the retention period
+// at the given time
+func (c *Controller) PurgeAuditLog(ctx context.Context, now time.Time) error {
+	settings, err := c.ds.GetSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if settings.AuditRetentionDays <= 0 {
+		return nil
+	}
+	return c.ds.DeleteAuditEntriesBefore(ctx, now.AddDate(0, 0, -settings.AuditRetentionDays))
+}
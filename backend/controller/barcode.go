@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"eylexander/bluraymanager/models"
 )
 
 // DVDFrResponse represents the XML response from DVDFr API
@@ -56,6 +58,8 @@ type BarcodeItem struct {
 	Title     string   `json:"title"`
 	Year      string   `json:"year,omitempty"`
 	Media     string   `json:"media,omitempty"`
+	Format    string   `json:"format,omitempty"`
+	Barcode   string   `json:"barcode,omitempty"`
 	Edition   string   `json:"edition,omitempty"`
 	Cover     string   `json:"cover,omitempty"`
 	Publisher string   `json:"publisher,omitempty"`
@@ -117,10 +121,15 @@ func (c *Controller) LookupBarcode(ctx context.Context, barcode string) ([]Barco
 			}
 		}
 
+		// Map the DVDFr media to a release format, so it can be saved as is
+		format, _ := models.ParseReleaseFormat(dvd.Media)
+
 		item := BarcodeItem{
 			Title:     title,
 			Year:      dvd.Annee,
 			Media:     dvd.Media,
+			Format:    string(format),
+			Barcode:   barcode,
 			Edition:   dvd.Edition,
 			Cover:     dvd.Cover,
 			Publisher: dvd.Editeur,
@@ -3,6 +3,8 @@ package controller
 import (
 	"context"
 	"errors"
+	"sort"
+	"time"
 
 	"eylexander/bluraymanager/i18n"
 	"eylexander/bluraymanager/models"
@@ -16,9 +18,14 @@ func (c *Controller) CreateBluray(ctx context.Context, bluray *models.Bluray) er
 		return errors.New(i18n.T("bluray.titleRequired"))
 	}
 
+	if id, ok := CollectionFromContext(ctx); ok {
+		bluray.CollectionID = id
+	}
+	bluray.DeletedAt = nil
+
 	// Check for duplicate TMDB ID
 	if bluray.TMDBID != "" {
-		existingBlurays, err := c.ds.ListBlurays(ctx, map[string]interface{}{"tmdb_id": bluray.TMDBID}, 0, 1)
+		existingBlurays, _, err := c.ds.PageBlurays(ctx, collectionFilter(ctx, map[string]interface{}{"tmdb_id": bluray.TMDBID}), newestPage(1))
 		if err != nil {
 			return err
 		}
@@ -27,11 +34,62 @@ func (c *Controller) CreateBluray(ctx context.Context, bluray *models.Bluray) er
 		}
 	}
 
-	return c.ds.CreateBluray(ctx, bluray)
+	normalizeSeasons(bluray)
+
+	// Every title starts out with at least one owned copy
+	if len(bluray.Copies) == 0 {
+		bluray.Copies = []models.Copy{{}}
+	}
+	if err := prepareCopies(ctx, bluray.Copies, nil); err != nil {
+		return err
+	}
+	if err := c.validateBlurayLocation(ctx, bluray); err != nil {
+		return err
+	}
+
+	// A rating given on creation is the personal rating of whoever adds it
+	var rating *models.UserRating
+	if bluray.Rating != 0 {
+		rating = &models.UserRating{UserID: bluray.AddedBy, Score: bluray.Rating}
+		if err := validateUserRating(ctx, rating); err != nil {
+			return err
+		}
+	}
+
+	if err := c.ds.CreateBluray(ctx, bluray); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditCreate, nil, bluray)
+	c.recordRevision(ctx, nil, bluray.ID)
+	if rating != nil {
+		rating.BlurayID = bluray.ID
+		if err := c.ds.SetUserRating(ctx, rating); err != nil {
+			return err
+		}
+	}
+	return c.annotateRatings(ctx, bluray)
 }
 
 func (c *Controller) GetBlurayByID(ctx context.Context, id primitive.ObjectID) (*models.Bluray, error) {
-	return c.ds.GetBlurayByID(ctx, id)
+	bluray, err := c.getBluray(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return bluray, c.annotateBlurays(ctx, bluray)
+}
+
+// getBluray returns a bluray of the library. Blurays of other collections
+// and those in the trash do not exist for the caller.
+func (c *Controller) getBluray(ctx context.Context, id primitive.ObjectID) (*models.Bluray, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	bluray, err := c.ds.GetBlurayByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !inActiveCollection(ctx, bluray.CollectionID) || bluray.DeletedAt != nil {
+		return nil, errors.New(i18n.T("bluray.notFound"))
+	}
+	return bluray, nil
 }
 
 func (c *Controller) UpdateBluray(ctx context.Context, bluray *models.Bluray) error {
@@ -39,21 +97,223 @@ func (c *Controller) UpdateBluray(ctx context.Context, bluray *models.Bluray) er
 	if bluray.Title == "" {
 		return errors.New(i18n.T("bluray.titleRequired"))
 	}
-	return c.ds.UpdateBluray(ctx, bluray)
+
+	normalizeSeasons(bluray)
+
+	existing, _ := c.ds.GetBlurayByID(ctx, bluray.ID)
+	if existing != nil {
+		if !inActiveCollection(ctx, existing.CollectionID) || existing.DeletedAt != nil {
+			return errors.New(i18n.T("bluray.notFound"))
+		}
+		bluray.CollectionID = existing.CollectionID
+	}
+
+	// Copies are left alone when the update does not list them
+	if bluray.Copies == nil && existing != nil {
+		bluray.Copies = existing.Copies
+	} else if len(bluray.Copies) == 0 {
+		return errors.New(i18n.T("copy.lastCopy"))
+	} else if err := prepareCopies(ctx, bluray.Copies, existing); err != nil {
+		return err
+	}
+
+	if err := c.validateBlurayLocation(ctx, bluray); err != nil {
+		return err
+	}
+	if err := c.ds.UpdateBluray(ctx, bluray); err != nil {
+		return err
+	}
+	if existing != nil {
+		c.audit(ctx, models.AuditUpdate, existing, bluray)
+		c.recordRevision(ctx, existing, bluray.ID)
+	}
+	return c.annotateBlurays(ctx, bluray)
 }
 
+// DeleteBluray moves a bluray to the trash, from where it can be restored
+// until it is purged
 func (c *Controller) DeleteBluray(ctx context.Context, id primitive.ObjectID) error {
-	return c.ds.DeleteBluray(ctx, id)
+	existing, err := c.getBluray(ctx, id)
+	if err != nil {
+		return err
+	}
+	deletedAt := time.Now()
+	if err := c.ds.SetBlurayDeletedAt(ctx, id, &deletedAt); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditDelete, existing, nil)
+	return nil
 }
 
-func (c *Controller) ListBlurays(ctx context.Context, filters map[string]interface{}, skip, limit int) ([]*models.Bluray, error) {
-	return c.ds.ListBlurays(ctx, filters, skip, limit)
+// ListBlurays returns a page of the blurays of the active collection matching
+// the filters, in the order of the options
+func (c *Controller) ListBlurays(ctx context.Context, filters map[string]interface{}, opts *models.QueryOptions) ([]*models.Bluray, *models.PageInfo, error) {
+	page, err := storePage(ctx, opts, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if page == nil {
+		// Ratings are not stored with the blurays, so every match is sorted here
+		blurays, _, err := c.ds.PageBlurays(ctx, collectionFilter(ctx, filters), newestPage(0))
+		if err != nil {
+			return nil, nil, err
+		}
+		return c.sortBlurays(ctx, blurays, opts)
+	}
+
+	blurays, total, err := c.ds.PageBlurays(ctx, collectionFilter(ctx, filters), page)
+	if err != nil {
+		return nil, nil, err
+	}
+	blurays, info := storedPage(blurays, total, page, blurayEntry, opts)
+	return blurays, info, c.annotateBlurays(ctx, blurays...)
 }
 
-func (c *Controller) SearchBlurays(ctx context.Context, query string, skip, limit int) ([]*models.Bluray, error) {
-	return c.ds.SearchBlurays(ctx, query, skip, limit)
+// FindBluray returns the newest bluray of the active collection matching the
+// filters, as stored, or nil when there is none
+func (c *Controller) FindBluray(ctx context.Context, filters map[string]interface{}) (*models.Bluray, error) {
+	blurays, _, err := c.ds.PageBlurays(ctx, collectionFilter(ctx, filters), newestPage(1))
+	if err != nil || len(blurays) == 0 {
+		return nil, err
+	}
+	return blurays[0], nil
 }
 
-func (c *Controller) ListSimplifiedBlurays(ctx context.Context, filters map[string]interface{}, skip, limit int) ([]*models.SimplifiedBluray, error) {
-	return c.ds.ListSimplifiedBlurays(ctx, filters, skip, limit)
+// SearchBlurays returns a page of the blurays of the active collection the
+// query finds, most relevant first unless the options sort them otherwise
+func (c *Controller) SearchBlurays(ctx context.Context, query string, opts *models.QueryOptions) ([]*models.Bluray, *models.PageInfo, error) {
+	page, err := storePage(ctx, opts, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if page == nil {
+		// Relevance and ratings are worked out on read, so every match is
+		// sorted here
+		blurays, _, err := c.ds.PageSearchBlurays(ctx, query, collectionFilter(ctx, nil), newestPage(0))
+		if err != nil {
+			return nil, nil, searchQueryError(ctx, err)
+		}
+		return c.sortBlurays(ctx, blurays, opts)
+	}
+
+	blurays, total, err := c.ds.PageSearchBlurays(ctx, query, collectionFilter(ctx, nil), page)
+	if err != nil {
+		return nil, nil, searchQueryError(ctx, err)
+	}
+	blurays, info := storedPage(blurays, total, page, blurayEntry, opts)
+	return blurays, info, c.annotateBlurays(ctx, blurays...)
+}
+
+// sortBlurays sorts blurays by rating or relevance, which the datastore
+// cannot do, and returns the page the options ask for annotated
+func (c *Controller) sortBlurays(ctx context.Context, blurays []*models.Bluray, opts *models.QueryOptions) ([]*models.Bluray, *models.PageInfo, error) {
+	if err := c.annotateRatings(ctx, blurays...); err != nil {
+		return nil, nil, err
+	}
+	blurays, info, err := paginate(ctx, blurays, blurayEntry, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.annotateLoans(ctx, blurays...); err != nil {
+		return nil, nil, err
+	}
+	return blurays, info, c.annotateLocations(ctx, blurays...)
+}
+
+// ListSimplifiedBlurays is ListBlurays for the lighter simplified blurays
+func (c *Controller) ListSimplifiedBlurays(ctx context.Context, filters map[string]interface{}, opts *models.QueryOptions) ([]*models.SimplifiedBluray, *models.PageInfo, error) {
+	page, err := storePage(ctx, opts, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var blurays []*models.SimplifiedBluray
+	var info *models.PageInfo
+	if page == nil {
+		all, _, err := c.ds.PageSimplifiedBlurays(ctx, collectionFilter(ctx, filters), newestPage(0))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := c.annotateSimplifiedRatings(ctx, all...); err != nil {
+			return nil, nil, err
+		}
+		if blurays, info, err = paginate(ctx, all, simplifiedEntry, opts); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		found, total, err := c.ds.PageSimplifiedBlurays(ctx, collectionFilter(ctx, filters), page)
+		if err != nil {
+			return nil, nil, err
+		}
+		blurays, info = storedPage(found, total, page, simplifiedEntry, opts)
+		if err := c.annotateSimplifiedRatings(ctx, blurays...); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	loans, err := c.activeLoansByBluray(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	locations, err := c.locationsByID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, bluray := range blurays {
+		bluray.OnLoan = markCopiesOnLoan(bluray.Copies, loans[bluray.ID])
+		if bluray.LocationID != nil {
+			bluray.LocationPath = locationPath(locations, *bluray.LocationID)
+		}
+	}
+	return blurays, info, nil
+}
+
+// annotateSimplifiedRatings fills in the household average rating of
+// simplified blurays
+func (c *Controller) annotateSimplifiedRatings(ctx context.Context, blurays ...*models.SimplifiedBluray) error {
+	averages, counts, err := c.ratingAverages(ctx)
+	if err != nil {
+		return err
+	}
+	for _, bluray := range blurays {
+		bluray.Rating = averages[bluray.ID]
+		bluray.RatingCount = counts[bluray.ID]
+	}
+	return nil
+}
+
+// normalizeSeasons orders the episodes of each season and, for the seasons
+// listing them, derives the episode counts from the episodes
+func normalizeSeasons(bluray *models.Bluray) {
+	listed := false
+	for i := range bluray.Seasons {
+		season := &bluray.Seasons[i]
+		if len(season.Episodes) == 0 {
+			continue
+		}
+		sort.SliceStable(season.Episodes, func(a, b int) bool {
+			return season.Episodes[a].Number < season.Episodes[b].Number
+		})
+		season.EpisodeCount = len(season.Episodes)
+		listed = true
+	}
+
+	if listed {
+		bluray.TotalEpisodes = 0
+		for i := range bluray.Seasons {
+			bluray.TotalEpisodes += bluray.Seasons[i].EpisodeTotal()
+		}
+	}
+}
+
+// annotateBlurays fills in the fields derived from other records, the loan
+// status, household rating and location path, before blurays are sent out
+func (c *Controller) annotateBlurays(ctx context.Context, blurays ...*models.Bluray) error {
+	if err := c.annotateLoans(ctx, blurays...); err != nil {
+		return err
+	}
+	if err := c.annotateRatings(ctx, blurays...); err != nil {
+		return err
+	}
+	return c.annotateLocations(ctx, blurays...)
 }
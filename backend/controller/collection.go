@@ -0,0 +1,378 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type collectionContextKey struct{}
+
+// WithCollection scopes the controller calls made with ctx to a collection
+func WithCollection(ctx context.Context, id primitive.ObjectID) context.Context {
+	return context.WithValue(ctx, collectionContextKey{}, id)
+}
+
+// CollectionFromContext returns the collection ctx is scoped to. Calls made
+// without one, such as the background jobs, see every collection.
+func CollectionFromContext(ctx context.Context) (primitive.ObjectID, bool) {
+	id, ok := ctx.Value(collectionContextKey{}).(primitive.ObjectID)
+	return id, ok
+}
+
+// inActiveCollection reports whether a record of the collection can be seen
+// from ctx
+func inActiveCollection(ctx context.Context, collectionID primitive.ObjectID) bool {
+	id, ok := CollectionFromContext(ctx)
+	return !ok || id == collectionID
+}
+
+// activeCollection returns the active collection as the datastore listings
+// take it, nil when ctx is not scoped to one
+func activeCollection(ctx context.Context) *primitive.ObjectID {
+	if id, ok := CollectionFromContext(ctx); ok {
+		return &id
+	}
+	return nil
+}
+
+// collectionFilter returns the filter of the blurays of the active
+// collection outside the trash with the given field values
+func collectionFilter(ctx context.Context, fields map[string]interface{}) models.BlurayFilter {
+	return models.BlurayFilter{CollectionID: activeCollection(ctx), Fields: fields}
+}
+
+// activeBlurayIDs returns the IDs of the blurays of the active collection, or
+// nil when ctx is not scoped to one
+func (c *Controller) activeBlurayIDs(ctx context.Context) (map[primitive.ObjectID]bool, error) {
+	if _, ok := CollectionFromContext(ctx); !ok {
+		return nil, nil
+	}
+	blurays, _, err := c.ds.PageSimplifiedBlurays(ctx, collectionFilter(ctx, nil), newestPage(0))
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[primitive.ObjectID]bool, len(blurays))
+	for _, bluray := range blurays {
+		ids[bluray.ID] = true
+	}
+	return ids, nil
+}
+
+// ResolveCollection picks the collection a user works in and returns their
+// role in it. A requested collection must be one the user is a member of,
+// unless they manage collections; otherwise it is the first collection they
+// were invited into. Users who are members of no collection work in the
+// default collection with their own role. Users who manage collections keep
+// their own role in every collection.
+func (c *Controller) ResolveCollection(ctx context.Context, userID primitive.ObjectID, role models.UserRole, requested string) (*models.Collection, models.UserRole, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	manager, err := c.HasPermission(ctx, role, models.PermCollectionManage)
+	if err != nil {
+		return nil, "", err
+	}
+	memberships, err := c.ds.ListCollections(ctx, &userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var collection *models.Collection
+	switch {
+	case requested != "":
+		id, err := primitive.ObjectIDFromHex(requested)
+		if err != nil {
+			return nil, "", errors.New(i18n.T("collection.notFound"))
+		}
+		if collection, err = c.ds.GetCollectionByID(ctx, id); err != nil {
+			return nil, "", errors.New(i18n.T("collection.notFound"))
+		}
+	case len(memberships) > 0:
+		collection = memberships[0]
+	default:
+		if collection, err = c.ds.EnsureDefaultCollection(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+
+	switch member := collection.Member(userID); {
+	case manager:
+		return collection, role, nil
+	case member != nil:
+		return collection, member.Role, nil
+	case collection.IsDefault && len(memberships) == 0:
+		return collection, role, nil
+	}
+	return nil, "", errors.New(i18n.T("collection.notFound"))
+}
+
+// ListCollections returns the collections the user can work in: every
+// collection for those who manage collections, and otherwise those they are a
+// member of or, failing that, the default collection
+func (c *Controller) ListCollections(ctx context.Context, userID primitive.ObjectID, role models.UserRole) ([]*models.Collection, error) {
+	manager, err := c.HasPermission(ctx, role, models.PermCollectionManage)
+	if err != nil {
+		return nil, err
+	}
+	var memberID *primitive.ObjectID
+	if !manager {
+		memberID = &userID
+	}
+	collections, err := c.ds.ListCollections(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(collections) == 0 || manager && !hasDefaultCollection(collections) {
+		collection, err := c.ds.EnsureDefaultCollection(ctx)
+		if err != nil {
+			return nil, err
+		}
+		collections = append([]*models.Collection{collection}, collections...)
+	}
+	c.annotateMembers(ctx, collections...)
+	return collections, nil
+}
+
+func hasDefaultCollection(collections []*models.Collection) bool {
+	for _, collection := range collections {
+		if collection.IsDefault {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Controller) GetCollectionByID(ctx context.Context, id primitive.ObjectID) (*models.Collection, error) {
+	collection, err := c.ds.GetCollectionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.annotateMembers(ctx, collection)
+	return collection, nil
+}
+
+func (c *Controller) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	if err := validateCollection(ctx, collection); err != nil {
+		return err
+	}
+	collection.Members = []models.CollectionMember{}
+	return c.ds.CreateCollection(ctx, collection)
+}
+
+func (c *Controller) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	if err := validateCollection(ctx, collection); err != nil {
+		return err
+	}
+	if err := c.ds.UpdateCollection(ctx, collection); err != nil {
+		return err
+	}
+	c.annotateMembers(ctx, collection)
+	return nil
+}
+
+// DeleteCollection removes a collection with no blurays left outside of the
+// trash, along with its trash, tags and smart collections. The default
+// collection cannot be removed.
+func (c *Controller) DeleteCollection(ctx context.Context, collection *models.Collection) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if collection.IsDefault {
+		return errors.New(i18n.T("collection.cannotDeleteDefault"))
+	}
+
+	live, _, err := c.ds.PageSimplifiedBlurays(ctx, models.BlurayFilter{CollectionID: &collection.ID}, newestPage(1))
+	if err != nil {
+		return err
+	}
+	if len(live) > 0 {
+		return errors.New(i18n.T("collection.notEmpty"))
+	}
+
+	// The trash of the collection goes with it, unless some of its discs
+	// are still lent out
+	trashed, _, err := c.ds.PageBlurays(ctx, models.BlurayFilter{CollectionID: &collection.ID, Trashed: true}, newestPage(0))
+	if err != nil {
+		return err
+	}
+	for _, bluray := range trashed {
+		loans, err := c.activeBlurayLoans(ctx, bluray.ID)
+		if err != nil {
+			return err
+		}
+		if len(loans) > 0 {
+			return errors.New(i18n.T("collection.loansOut"))
+		}
+	}
+	for _, bluray := range trashed {
+		if err := c.purgeBluray(ctx, bluray); err != nil {
+			return err
+		}
+	}
+
+	tags, err := c.ds.ListTags(ctx, &collection.ID)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := c.ds.DeleteTag(ctx, tag.ID); err != nil {
+			return err
+		}
+	}
+
+	// So do its locations and wishlist
+	locations, err := c.ds.ListLocations(ctx, &collection.ID)
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		if err := c.ds.DeleteLocation(ctx, location.ID); err != nil {
+			return err
+		}
+	}
+	for _, acquired := range []bool{false, true} {
+		items, err := c.ds.ListWishlistItems(ctx, &collection.ID, acquired)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := c.ds.DeleteWishlistItem(ctx, item.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// And its smart collections
+	shared, err := c.ds.ListSavedSearches(ctx, primitive.NilObjectID, &collection.ID)
+	if err != nil {
+		return err
+	}
+	for _, search := range shared {
+		if err := c.ds.DeleteSavedSearch(ctx, search.ID); err != nil {
+			return err
+		}
+	}
+	return c.ds.DeleteCollection(ctx, collection.ID)
+}
+
+// SetCollectionMember invites the user into the collection with the given
+// role, or changes their role when they already are a member. Invited users
+// are notified.
+func (c *Controller) SetCollectionMember(ctx context.Context, collection *models.Collection, userID primitive.ObjectID, role models.UserRole, invitedBy primitive.ObjectID) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if _, err := c.GetRole(ctx, role); err != nil {
+		return err
+	}
+	user, err := c.ds.GetUserByID(ctx, userID)
+	if err != nil {
+		return errors.New(i18n.T("user.notFound"))
+	}
+
+	if member := collection.Member(userID); member != nil {
+		member.Role = role
+		if err := c.ds.UpdateCollection(ctx, collection); err != nil {
+			return err
+		}
+		c.annotateMembers(ctx, collection)
+		return nil
+	}
+
+	collection.Members = append(collection.Members, models.CollectionMember{
+		UserID:    userID,
+		Role:      role,
+		InvitedBy: invitedBy,
+		AddedAt:   time.Now(),
+	})
+	if err := c.ds.UpdateCollection(ctx, collection); err != nil {
+		return err
+	}
+	c.notifyCollectionInvite(ctx, user, collection)
+	c.annotateMembers(ctx, collection)
+	return nil
+}
+
+// RemoveCollectionMember removes the user from the collection
+func (c *Controller) RemoveCollectionMember(ctx context.Context, collection *models.Collection, userID primitive.ObjectID) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	for i, member := range collection.Members {
+		if member.UserID == userID {
+			collection.Members = append(collection.Members[:i], collection.Members[i+1:]...)
+			if err := c.ds.UpdateCollection(ctx, collection); err != nil {
+				return err
+			}
+			c.annotateMembers(ctx, collection)
+			return nil
+		}
+	}
+	return errors.New(i18n.T("collection.memberNotFound"))
+}
+
+// removeUserMemberships takes a deleted user out of every collection
+func (c *Controller) removeUserMemberships(ctx context.Context, userID primitive.ObjectID) error {
+	collections, err := c.ds.ListCollections(ctx, &userID)
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		if err := c.RemoveCollectionMember(ctx, collection, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyCollectionInvite tells the user, in their language, that they were
+// added to the collection. The notification is shown in every collection, as
+// the user is usually looking at another one.
+func (c *Controller) notifyCollectionInvite(ctx context.Context, user *models.User, collection *models.Collection) {
+	lang := "en-US"
+	if user.Settings.Language != "" {
+		lang = user.Settings.Language
+	}
+	i18n := i18n.NewModule(lang)
+
+	notification := &models.Notification{
+		UserID:  user.ID,
+		Type:    models.NotificationCollectionInvite,
+		Message: fmt.Sprintf(i18n.T("notification.collection_invite"), collection.Name),
+	}
+	if err := c.ds.CreateNotification(ctx, notification); err != nil {
+		log.Printf("ERROR SetCollectionMember: %v", err)
+	}
+}
+
+// annotateMembers fills in the usernames of the members of collections
+// before they are sent out
+func (c *Controller) annotateMembers(ctx context.Context, collections ...*models.Collection) {
+	usernames := make(map[primitive.ObjectID]string)
+	for _, collection := range collections {
+		for i := range collection.Members {
+			member := &collection.Members[i]
+			username, ok := usernames[member.UserID]
+			if !ok {
+				if user, err := c.ds.GetUserByID(ctx, member.UserID); err == nil {
+					username = user.Username
+				}
+				usernames[member.UserID] = username
+			}
+			member.Username = username
+		}
+	}
+}
+
+func validateCollection(ctx context.Context, collection *models.Collection) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	collection.Name = strings.TrimSpace(collection.Name)
+	collection.Description = strings.TrimSpace(collection.Description)
+	if collection.Name == "" {
+		return errors.New(i18n.T("collection.nameRequired"))
+	}
+	return nil
+}
@@ -3,20 +3,29 @@ package controller
 import (
 	"eylexander/bluraymanager/datastore"
 	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Controller struct {
-	ds datastore.Datastore
+	ds      datastore.Datastore
+	oidc    *services.OIDCProvider
+	limiter RateLimitStore
 }
 
 func NewController(ds datastore.Datastore) *Controller {
 	return &Controller{
-		ds: ds,
+		ds:      ds,
+		limiter: NewMemoryRateLimitStore(),
 	}
 }
 
+// SetOIDCProvider enables single sign-on with the identity provider
+func (c *Controller) SetOIDCProvider(provider *services.OIDCProvider) {
+	c.oidc = provider
+}
+
 // GetI18n retrieves the i18n instance from the context
 func (c *Controller) GetI18n(ctx *gin.Context) *i18n.I18n {
 	if i18nInterface, exists := ctx.Get("i18n"); exists {
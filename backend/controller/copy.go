@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AddCopy records another owned copy of a bluray
+func (c *Controller) AddCopy(ctx context.Context, blurayID primitive.ObjectID, cp *models.Copy) (*models.Bluray, error) {
+	bluray, err := c.getBluray(ctx, blurayID)
+	if err != nil {
+		return nil, err
+	}
+	before := copyBluray(bluray)
+
+	cp.ID = primitive.NilObjectID
+	if err := prepareCopy(ctx, cp, nil); err != nil {
+		return nil, err
+	}
+	bluray.Copies = append(bluray.Copies, *cp)
+
+	if err := c.ds.UpdateBluray(ctx, bluray); err != nil {
+		return nil, err
+	}
+	c.audit(ctx, models.AuditUpdate, before, bluray)
+	c.recordRevision(ctx, before, bluray.ID)
+	return bluray, c.annotateBlurays(ctx, bluray)
+}
+
+// UpdateCopy replaces a copy of a bluray, keeping its creation time
+func (c *Controller) UpdateCopy(ctx context.Context, blurayID primitive.ObjectID, cp *models.Copy) (*models.Bluray, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	bluray, err := c.getBluray(ctx, blurayID)
+	if err != nil {
+		return nil, err
+	}
+	before := copyBluray(bluray)
+
+	existing := bluray.CopyByID(cp.ID)
+	if existing == nil {
+		return nil, errors.New(i18n.T("copy.notFound"))
+	}
+	if err := prepareCopy(ctx, cp, existing); err != nil {
+		return nil, err
+	}
+	*existing = *cp
+
+	if err := c.ds.UpdateBluray(ctx, bluray); err != nil {
+		return nil, err
+	}
+	c.audit(ctx, models.AuditUpdate, before, bluray)
+	c.recordRevision(ctx, before, bluray.ID)
+	return bluray, c.annotateBlurays(ctx, bluray)
+}
+
+// DeleteCopy removes a copy of a bluray. The last copy cannot be removed;
+// delete the bluray instead.
+func (c *Controller) DeleteCopy(ctx context.Context, blurayID, copyID primitive.ObjectID) (*models.Bluray, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	bluray, err := c.getBluray(ctx, blurayID)
+	if err != nil {
+		return nil, err
+	}
+	before := copyBluray(bluray)
+	if bluray.CopyByID(copyID) == nil {
+		return nil, errors.New(i18n.T("copy.notFound"))
+	}
+	if len(bluray.Copies) == 1 {
+		return nil, errors.New(i18n.T("copy.lastCopy"))
+	}
+	loans, err := c.activeBlurayLoans(ctx, blurayID)
+	if err != nil {
+		return nil, err
+	}
+	for _, loan := range loans {
+		if loan.CopyID != nil && *loan.CopyID == copyID {
+			return nil, errors.New(i18n.T("copy.onLoan"))
+		}
+	}
+
+	copies := make([]models.Copy, 0, len(bluray.Copies)-1)
+	for _, cp := range bluray.Copies {
+		if cp.ID != copyID {
+			copies = append(copies, cp)
+		}
+	}
+	bluray.Copies = copies
+
+	if err := c.ds.UpdateBluray(ctx, bluray); err != nil {
+		return nil, err
+	}
+	c.audit(ctx, models.AuditUpdate, before, bluray)
+	c.recordRevision(ctx, before, bluray.ID)
+	return bluray, c.annotateBlurays(ctx, bluray)
+}
+
+// copyBluray returns a copy of bluray whose copies can be changed without
+// touching the original
+func copyBluray(bluray *models.Bluray) *models.Bluray {
+	copied := *bluray
+	copied.Copies = append([]models.Copy(nil), bluray.Copies...)
+	return &copied
+}
+
+// prepareCopies validates the copies of a bluray, giving new ones an ID and
+// keeping the creation time of the ones already stored in existing
+func prepareCopies(ctx context.Context, copies []models.Copy, existing *models.Bluray) error {
+	for i := range copies {
+		var stored *models.Copy
+		if existing != nil && !copies[i].ID.IsZero() {
+			stored = existing.CopyByID(copies[i].ID)
+		}
+		if err := prepareCopy(ctx, &copies[i], stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareCopy validates a copy and sets its ID and creation time, from
+// stored when the copy already exists
+func prepareCopy(ctx context.Context, cp *models.Copy, stored *models.Copy) error {
+	if err := validateCopy(ctx, cp); err != nil {
+		return err
+	}
+
+	if stored != nil {
+		cp.ID = stored.ID
+		cp.CreatedAt = stored.CreatedAt
+		return nil
+	}
+	if cp.ID.IsZero() {
+		cp.ID = primitive.NewObjectID()
+	}
+	cp.CreatedAt = time.Now()
+	return nil
+}
+
+// validateCopy checks the release and purchase fields of a copy and brings
+// them to their stored form: canonical format and packaging, digits-only
+// barcode, upper-case region and trimmed track lists
+func validateCopy(ctx context.Context, cp *models.Copy) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	if cp.Format != "" {
+		format, ok := models.ParseReleaseFormat(string(cp.Format))
+		if !ok {
+			return errors.New(i18n.T("copy.invalidFormat"))
+		}
+		cp.Format = format
+	}
+	if cp.Packaging != "" {
+		packaging, ok := models.ParsePackaging(string(cp.Packaging))
+		if !ok {
+			return errors.New(i18n.T("copy.invalidPackaging"))
+		}
+		cp.Packaging = packaging
+	}
+	if cp.Condition != "" && !cp.Condition.IsValid() {
+		return errors.New(i18n.T("copy.invalidCondition"))
+	}
+
+	cp.Barcode = strings.NewReplacer(" ", "", "-", "").Replace(cp.Barcode)
+	if cp.Barcode != "" && !isValidBarcode(cp.Barcode) {
+		return errors.New(i18n.T("copy.invalidBarcode"))
+	}
+	if cp.DiscCount < 0 {
+		return errors.New(i18n.T("copy.invalidDiscCount"))
+	}
+	if cp.PurchasePrice < 0 {
+		return errors.New(i18n.T("copy.invalidPrice"))
+	}
+
+	cp.Edition = strings.TrimSpace(cp.Edition)
+	cp.Publisher = strings.TrimSpace(cp.Publisher)
+	cp.RegionCode = strings.ToUpper(strings.TrimSpace(cp.RegionCode))
+	cp.AudioTracks = trimTracks(cp.AudioTracks)
+	cp.SubtitleTracks = trimTracks(cp.SubtitleTracks)
+	return nil
+}
+
+// isValidBarcode checks the length and check digit of an EAN-13 or UPC-A
+// code. A UPC-A code is an EAN-13 code with a leading zero.
+func isValidBarcode(barcode string) bool {
+	if len(barcode) == 12 {
+		barcode = "0" + barcode
+	}
+	if len(barcode) != 13 {
+		return false
+	}
+
+	sum := 0
+	for i, r := range barcode {
+		if r < '0' || r > '9' {
+			return false
+		}
+		digit := int(r - '0')
+		if i == 12 {
+			return (10-sum%10)%10 == digit
+		}
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return false
+}
+
+func trimTracks(tracks []string) []string {
+	var trimmed []string
+	for _, track := range tracks {
+		if track = strings.TrimSpace(track); track != "" {
+			trimmed = append(trimmed, track)
+		}
+	}
+	return trimmed
+}
@@ -10,34 +10,65 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Default token lifetimes, overridden by ACCESS_TOKEN_TTL and
+// REFRESH_TOKEN_TTL
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
 type Claims struct {
-	UserID   string          `json:"user_id"`
-	Username string          `json:"username"`
-	Email    string          `json:"email"`
-	Role     models.UserRole `json:"role"`
+	UserID    string          `json:"user_id"`
+	Username  string          `json:"username"`
+	Email     string          `json:"email"`
+	Role      models.UserRole `json:"role"`
+	SessionID string          `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func (c *Controller) GenerateToken(user *models.User) (string, error) {
+func jwtSecret() []byte {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		secret = "your-secret-key-change-this-in-production"
 	}
+	return []byte(secret)
+}
+
+// tokenTTL reads a duration such as "15m" or "720h" from the environment
+func tokenTTL(name string, fallback time.Duration) time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || ttl <= 0 {
+		return fallback
+	}
+	return ttl
+}
+
+func accessTokenTTL() time.Duration {
+	return tokenTTL("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
+func refreshTokenTTL() time.Duration {
+	return tokenTTL("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+// generateAccessToken signs a short-lived token for the user, bound to one
+// of their sessions
+func (c *Controller) generateAccessToken(user *models.User, sessionID primitive.ObjectID) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID:   user.ID.Hex(),
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
+		UserID:    user.ID.Hex(),
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID.Hex(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := token.SignedString(jwtSecret())
 	if err != nil {
 		return "", err
 	}
@@ -46,14 +77,9 @@ func (c *Controller) GenerateToken(user *models.User) (string, error) {
 }
 
 func (c *Controller) ValidateToken(tokenString string) (*Claims, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-this-in-production"
-	}
-
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
+		return jwtSecret(), nil
 	})
 
 	if err != nil {
@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (c *Controller) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	// A borrower with an account defaults to their username
+	if loan.BorrowerID != nil {
+		borrower, err := c.ds.GetUserByID(ctx, *loan.BorrowerID)
+		if err != nil {
+			return errors.New(i18n.T("user.notFound"))
+		}
+		if loan.BorrowerName == "" {
+			loan.BorrowerName = borrower.Username
+		}
+	}
+
+	if loan.LentAt.IsZero() {
+		loan.LentAt = time.Now()
+	}
+	if err := validateLoan(ctx, loan); err != nil {
+		return err
+	}
+	if loan.CopyID != nil {
+		bluray, err := c.getBluray(ctx, loan.BlurayID)
+		if err != nil {
+			return err
+		}
+		if bluray.CopyByID(*loan.CopyID) == nil {
+			return errors.New(i18n.T("copy.notFound"))
+		}
+	}
+
+	// A copy can only be lent to one person at a time
+	active, err := c.activeBlurayLoans(ctx, loan.BlurayID)
+	if err != nil {
+		return err
+	}
+	for _, current := range active {
+		if current.Overlaps(loan) {
+			return errors.New(i18n.T("loan.alreadyLent"))
+		}
+	}
+
+	loan.ReturnedAt = nil
+	loan.OverdueNotifiedAt = nil
+	return c.ds.CreateLoan(ctx, loan)
+}
+
+func (c *Controller) GetLoanByID(ctx context.Context, id primitive.ObjectID) (*models.Loan, error) {
+	return c.ds.GetLoanByID(ctx, id)
+}
+
+func (c *Controller) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	if err := validateLoan(ctx, loan); err != nil {
+		return err
+	}
+	// Moving the due date into the future re-arms the overdue notification
+	if !loan.IsOverdue(time.Now()) {
+		loan.OverdueNotifiedAt = nil
+	}
+	return c.ds.UpdateLoan(ctx, loan)
+}
+
+// ReturnLoan marks the bluray as returned, at returnedAt or now when zero
+func (c *Controller) ReturnLoan(ctx context.Context, loan *models.Loan, returnedAt time.Time) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if !loan.IsActive() {
+		return errors.New(i18n.T("loan.alreadyReturned"))
+	}
+
+	if returnedAt.IsZero() {
+		returnedAt = time.Now()
+	}
+	if returnedAt.Before(loan.LentAt) {
+		return errors.New(i18n.T("loan.returnedBeforeLent"))
+	}
+
+	loan.ReturnedAt = &returnedAt
+	return c.ds.UpdateLoan(ctx, loan)
+}
+
+func (c *Controller) DeleteLoan(ctx context.Context, id primitive.ObjectID) error {
+	return c.ds.DeleteLoan(ctx, id)
+}
+
+func (c *Controller) ListBlurayLoans(ctx context.Context, blurayID primitive.ObjectID) ([]*models.Loan, error) {
+	return c.ds.ListBlurayLoans(ctx, blurayID)
+}
+
+// ListActiveLoans returns the blurays currently lent out, longest out first,
+// optionally only the overdue ones
+func (c *Controller) ListActiveLoans(ctx context.Context, overdueOnly bool) ([]*models.Loan, error) {
+	loans, err := c.ds.ListActiveLoans(ctx, activeCollection(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := []*models.Loan{}
+	for _, loan := range loans {
+		if !overdueOnly || loan.IsOverdue(now) {
+			result = append(result, loan)
+		}
+	}
+	return result, nil
+}
+
+// CheckOverdueLoans notifies the lender, and the borrower when they have an
+// account, once for every loan past its due date. It returns the number of
+// loans that became overdue.
+func (c *Controller) CheckOverdueLoans(ctx context.Context) (int, error) {
+	loans, err := c.ds.ListActiveLoans(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	overdue := 0
+	for _, loan := range loans {
+		if !loan.IsOverdue(now) || loan.OverdueNotifiedAt != nil {
+			continue
+		}
+
+		recipients := []primitive.ObjectID{loan.LentBy}
+		if loan.BorrowerID != nil && *loan.BorrowerID != loan.LentBy {
+			recipients = append(recipients, *loan.BorrowerID)
+		}
+		for _, userID := range recipients {
+			c.notifyOverdueLoan(ctx, userID, loan)
+		}
+
+		loan.OverdueNotifiedAt = &now
+		if err := c.ds.UpdateLoan(ctx, loan); err != nil {
+			return overdue, err
+		}
+		overdue++
+	}
+	return overdue, nil
+}
+
+// notifyOverdueLoan creates the overdue notification in the user's language
+func (c *Controller) notifyOverdueLoan(ctx context.Context, userID primitive.ObjectID, loan *models.Loan) {
+	lang := "en-US"
+	if user, err := c.ds.GetUserByID(ctx, userID); err == nil && user.Settings.Language != "" {
+		lang = user.Settings.Language
+	}
+	i18n := i18n.NewModule(lang)
+
+	notification := &models.Notification{
+		UserID:       userID,
+		Type:         models.NotificationLoanOverdue,
+		Message:      fmt.Sprintf(i18n.T("notification.loan_overdue"), loan.BlurayTitle, loan.BorrowerName, loan.DueAt.Format("2006-01-02")),
+		BlurayID:     loan.BlurayID,
+		CollectionID: loan.CollectionID,
+	}
+	if err := c.ds.CreateNotification(ctx, notification); err != nil {
+		log.Printf("ERROR CheckOverdueLoans: %v", err)
+	}
+}
+
+// WatchOverdueLoans runs CheckOverdueLoans every interval until ctx is done
+func (c *Controller) WatchOverdueLoans(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.CheckOverdueLoans(ctx); err != nil {
+			log.Printf("ERROR CheckOverdueLoans: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// activeBlurayLoans returns the loans of a bluray still out
+func (c *Controller) activeBlurayLoans(ctx context.Context, blurayID primitive.ObjectID) ([]*models.Loan, error) {
+	loans, err := c.ds.ListBlurayLoans(ctx, blurayID)
+	if err != nil {
+		return nil, err
+	}
+	var active []*models.Loan
+	for _, loan := range loans {
+		if loan.IsActive() {
+			active = append(active, loan)
+		}
+	}
+	return active, nil
+}
+
+// activeLoansByBluray indexes the loans currently out by bluray ID, longest
+// out first
+func (c *Controller) activeLoansByBluray(ctx context.Context) (map[primitive.ObjectID][]*models.Loan, error) {
+	loans, err := c.ds.ListActiveLoans(ctx, activeCollection(ctx))
+	if err != nil {
+		return nil, err
+	}
+	byBluray := make(map[primitive.ObjectID][]*models.Loan, len(loans))
+	for _, loan := range loans {
+		byBluray[loan.BlurayID] = append(byBluray[loan.BlurayID], loan)
+	}
+	return byBluray, nil
+}
+
+// annotateLoans fills in the loan status of blurays before they are sent out
+func (c *Controller) annotateLoans(ctx context.Context, blurays ...*models.Bluray) error {
+	loans, err := c.activeLoansByBluray(ctx)
+	if err != nil {
+		return err
+	}
+	for _, bluray := range blurays {
+		bluray.CurrentLoan = nil
+		if out := loans[bluray.ID]; len(out) > 0 {
+			bluray.CurrentLoan = out[0]
+		}
+		bluray.OnLoan = markCopiesOnLoan(bluray.Copies, loans[bluray.ID])
+	}
+	return nil
+}
+
+// markCopiesOnLoan flags the copies the loans take out and reports whether
+// none is left
+func markCopiesOnLoan(copies []models.Copy, loans []*models.Loan) bool {
+	if len(copies) == 0 {
+		return len(loans) > 0
+	}
+	allOut := true
+	for i := range copies {
+		copies[i].OnLoan = false
+		for _, loan := range loans {
+			if loan.Covers(copies[i].ID) {
+				copies[i].OnLoan = true
+				break
+			}
+		}
+		allOut = allOut && copies[i].OnLoan
+	}
+	return allOut
+}
+
+func validateLoan(ctx context.Context, loan *models.Loan) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if loan.BorrowerName == "" {
+		return errors.New(i18n.T("loan.borrowerRequired"))
+	}
+	if loan.DueAt != nil && loan.DueAt.Before(loan.LentAt) {
+		return errors.New(i18n.T("loan.dueBeforeLent"))
+	}
+	if loan.ReturnedAt != nil && loan.ReturnedAt.Before(loan.LentAt) {
+		return errors.New(i18n.T("loan.returnedBeforeLent"))
+	}
+	return nil
+}
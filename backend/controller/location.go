@@ -0,0 +1,329 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (c *Controller) CreateLocation(ctx context.Context, location *models.Location) error {
+	location.CollectionID, _ = CollectionFromContext(ctx)
+	if err := c.validateLocation(ctx, location); err != nil {
+		return err
+	}
+	return c.ds.CreateLocation(ctx, location)
+}
+
+func (c *Controller) GetLocationByID(ctx context.Context, id primitive.ObjectID) (*models.Location, error) {
+	location, err := c.getLocation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	locations, err := c.locationsByID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	location.Path = locationPath(locations, location.ID)
+	return location, nil
+}
+
+func (c *Controller) UpdateLocation(ctx context.Context, location *models.Location) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if err := c.validateLocation(ctx, location); err != nil {
+		return err
+	}
+
+	// Sub-locations must still fit under the location
+	locations, err := c.ds.ListLocations(ctx, &location.CollectionID)
+	if err != nil {
+		return err
+	}
+	for _, child := range locations {
+		if child.ParentID != nil && *child.ParentID == location.ID && child.Kind.ParentKind() != location.Kind {
+			return errors.New(i18n.T("location.hasChildren"))
+		}
+	}
+
+	return c.ds.UpdateLocation(ctx, location)
+}
+
+// DeleteLocation only deletes empty locations, without sub-locations or discs
+func (c *Controller) DeleteLocation(ctx context.Context, id primitive.ObjectID) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	existing, err := c.getLocation(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	locations, err := c.ds.ListLocations(ctx, &existing.CollectionID)
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		if location.ParentID != nil && *location.ParentID == id {
+			return errors.New(i18n.T("location.hasChildren"))
+		}
+	}
+
+	counts, err := c.ds.CountBluraysByLocation(ctx, &existing.CollectionID)
+	if err != nil {
+		return err
+	}
+	if counts[id] > 0 {
+		return errors.New(i18n.T("location.notEmpty"))
+	}
+
+	return c.ds.DeleteLocation(ctx, id)
+}
+
+// ListLocations returns every location of the active collection with its
+// full path
+func (c *Controller) ListLocations(ctx context.Context) ([]*models.Location, error) {
+	locations, err := c.ds.ListLocations(ctx, activeCollection(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]*models.Location, len(locations))
+	for _, location := range locations {
+		byID[location.ID] = location
+	}
+	for _, location := range locations {
+		location.Path = locationPath(byID, location.ID)
+	}
+	return locations, nil
+}
+
+// GetLocationReport returns the capacity and fill level of every location,
+// ordered by path so that sub-locations follow their parent
+func (c *Controller) GetLocationReport(ctx context.Context) ([]*models.LocationReport, error) {
+	locations, err := c.ListLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := c.ds.CountBluraysByLocation(ctx, activeCollection(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[primitive.ObjectID][]*models.Location{}
+	for _, location := range locations {
+		if location.ParentID != nil {
+			children[*location.ParentID] = append(children[*location.ParentID], location)
+		}
+	}
+
+	reports := make(map[primitive.ObjectID]*models.LocationReport, len(locations))
+	var build func(location *models.Location) *models.LocationReport
+	build = func(location *models.Location) *models.LocationReport {
+		if report, ok := reports[location.ID]; ok {
+			return report
+		}
+
+		report := &models.LocationReport{
+			ID:               location.ID,
+			Name:             location.Name,
+			Kind:             location.Kind,
+			ParentID:         location.ParentID,
+			Path:             location.Path,
+			BlurayCount:      counts[location.ID],
+			TotalBlurayCount: counts[location.ID],
+			Capacity:         location.Capacity,
+		}
+		reports[location.ID] = report
+
+		childCapacity := 0
+		for _, child := range children[location.ID] {
+			childReport := build(child)
+			report.TotalBlurayCount += childReport.TotalBlurayCount
+			childCapacity += childReport.Capacity
+		}
+		if report.Capacity == 0 {
+			report.Capacity = childCapacity
+		}
+		if report.Capacity > 0 {
+			report.FillRate = float64(report.TotalBlurayCount) / float64(report.Capacity)
+		}
+		return report
+	}
+
+	result := make([]*models.LocationReport, 0, len(locations))
+	for _, location := range locations {
+		result = append(result, build(location))
+	}
+	sortLocationReports(result)
+	return result, nil
+}
+
+// validateLocation checks the name, kind and parent of a location and sets its slug
+func (c *Controller) validateLocation(ctx context.Context, location *models.Location) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	location.Name = strings.TrimSpace(location.Name)
+	if location.Name == "" {
+		return errors.New(i18n.T("location.nameRequired"))
+	}
+	if !location.Kind.IsValid() {
+		return errors.New(i18n.T("location.invalidKind"))
+	}
+	if location.Capacity < 0 {
+		return errors.New(i18n.T("location.invalidCapacity"))
+	}
+
+	if location.ParentID != nil && location.ParentID.IsZero() {
+		location.ParentID = nil
+	}
+
+	// Rooms are top-level, everything else goes in the level above it
+	parentKind := location.Kind.ParentKind()
+	if parentKind == "" {
+		if location.ParentID != nil {
+			return errors.New(i18n.T("location.invalidParent"))
+		}
+	} else {
+		if location.ParentID == nil {
+			return errors.New(i18n.T("location.invalidParent"))
+		}
+		parent, err := c.ds.GetLocationByID(ctx, *location.ParentID)
+		if err != nil || parent.Kind != parentKind || parent.CollectionID != location.CollectionID {
+			return errors.New(i18n.T("location.invalidParent"))
+		}
+	}
+
+	// Siblings need distinct names to be told apart
+	locations, err := c.ds.ListLocations(ctx, &location.CollectionID)
+	if err != nil {
+		return err
+	}
+	for _, other := range locations {
+		if other.ID != location.ID && sameParent(other.ParentID, location.ParentID) &&
+			strings.EqualFold(other.Name, location.Name) {
+			return errors.New(i18n.T("location.duplicateName"))
+		}
+	}
+
+	location.Slug = slugify(location.Name)
+	return nil
+}
+
+// validateBlurayLocation makes sure the location of a bluray exists in the
+// collection of the bluray
+func (c *Controller) validateBlurayLocation(ctx context.Context, bluray *models.Bluray) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if bluray.LocationID != nil && bluray.LocationID.IsZero() {
+		bluray.LocationID = nil
+	}
+	if bluray.LocationID == nil {
+		return nil
+	}
+	location, err := c.ds.GetLocationByID(ctx, *bluray.LocationID)
+	if err != nil || location.CollectionID != bluray.CollectionID {
+		return errors.New(i18n.T("location.notFound"))
+	}
+	return nil
+}
+
+// getLocation returns a location of the active collection, those of other
+// collections do not exist for the caller
+func (c *Controller) getLocation(ctx context.Context, id primitive.ObjectID) (*models.Location, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	location, err := c.ds.GetLocationByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !inActiveCollection(ctx, location.CollectionID) {
+		return nil, errors.New(i18n.T("location.notFound"))
+	}
+	return location, nil
+}
+
+// annotateLocations fills in the location path of blurays before they are sent out
+func (c *Controller) annotateLocations(ctx context.Context, blurays ...*models.Bluray) error {
+	stored := false
+	for _, bluray := range blurays {
+		stored = stored || bluray.LocationID != nil
+	}
+	if !stored {
+		return nil
+	}
+
+	locations, err := c.locationsByID(ctx)
+	if err != nil {
+		return err
+	}
+	for _, bluray := range blurays {
+		if bluray.LocationID != nil {
+			bluray.LocationPath = locationPath(locations, *bluray.LocationID)
+		}
+	}
+	return nil
+}
+
+func (c *Controller) locationsByID(ctx context.Context) (map[primitive.ObjectID]*models.Location, error) {
+	locations, err := c.ds.ListLocations(ctx, activeCollection(ctx))
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]*models.Location, len(locations))
+	for _, location := range locations {
+		byID[location.ID] = location
+	}
+	return byID, nil
+}
+
+// locationPath joins the names from the room down to the location, e.g.
+// "Living room > Billy > Shelf 2"
+func locationPath(locations map[primitive.ObjectID]*models.Location, id primitive.ObjectID) string {
+	var names []string
+	for depth := 0; depth < 4; depth++ {
+		location, ok := locations[id]
+		if !ok {
+			break
+		}
+		names = append([]string{location.Name}, names...)
+		if location.ParentID == nil {
+			break
+		}
+		id = *location.ParentID
+	}
+	return strings.Join(names, " > ")
+}
+
+func sortLocationReports(reports []*models.LocationReport) {
+	sort.SliceStable(reports, func(i, j int) bool {
+		return strings.ToLower(reports[i].Path) < strings.ToLower(reports[j].Path)
+	})
+}
+
+func sameParent(a, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// slugify turns a name into the lowercase, dash-separated form used by
+// location: searches, e.g. "Living Room" becomes "living-room"
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccountLockedError is returned by the login steps while the account is
+// locked after too many failed attempts
+type AccountLockedError struct {
+	Until   time.Time
+	message string
+}
+
+func (e *AccountLockedError) Error() string {
+	return e.message
+}
+
+// loginMaxFailures is the number of failed logins in a row that lock an
+// account, LOGIN_MAX_FAILURES
+func loginMaxFailures() int {
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES")); err == nil && n > 0 {
+		return n
+	}
+	return 5
+}
+
+// loginLockoutDuration is how long an account stays locked,
+// LOGIN_LOCKOUT_DURATION
+func loginLockoutDuration() time.Duration {
+	return tokenTTL("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+}
+
+func lockedError(ctx context.Context, user *models.User) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	return &AccountLockedError{Until: *user.Lockout.LockedUntil, message: i18n.T("user.accountLocked")}
+}
+
+// recordFailedLogin counts a wrong password or code, and locks the account
+// when there were too many in a row
+func (c *Controller) recordFailedLogin(ctx context.Context, user *models.User) error {
+	user.Lockout.FailedAttempts++
+	if user.Lockout.FailedAttempts >= loginMaxFailures() {
+		until := time.Now().Add(loginLockoutDuration())
+		user.Lockout = models.Lockout{LockedUntil: &until}
+	}
+	return c.ds.UpdateUser(ctx, user)
+}
+
+// recordSuccessfulLogin forgets the failed attempts before a login
+func (c *Controller) recordSuccessfulLogin(ctx context.Context, user *models.User) error {
+	if user.Lockout == (models.Lockout{}) {
+		return nil
+	}
+	user.Lockout = models.Lockout{}
+	return c.ds.UpdateUser(ctx, user)
+}
+
+// UnlockUser lifts the lock of an account and forgets its failed logins
+func (c *Controller) UnlockUser(ctx context.Context, user *models.User) error {
+	before := *user
+	user.Lockout = models.Lockout{}
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditUpdate, &before, user)
+	return nil
+}
+
+// NewUnlockToken returns the token of an unlock email for a locked account.
+// Only the last token sent works, until the lock ends.
+func (c *Controller) NewUnlockToken(ctx context.Context, user *models.User) (string, error) {
+	secret, err := newTokenSecret()
+	if err != nil {
+		return "", err
+	}
+	user.Lockout.UnlockTokenHash = hashToken(secret)
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return "", err
+	}
+	return user.ID.Hex() + "." + secret, nil
+}
+
+// UnlockWithToken lifts the lock of the account the unlock token was sent for
+func (c *Controller) UnlockWithToken(ctx context.Context, token string) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	invalid := errors.New(i18n.T("lockout.invalidUnlockToken"))
+
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return invalid
+	}
+	userID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return invalid
+	}
+	user, err := c.ds.GetUserByID(ctx, userID)
+	if err != nil || !user.Lockout.IsLocked(time.Now()) || user.Lockout.UnlockTokenHash == "" {
+		return invalid
+	}
+	if subtle.ConstantTimeCompare([]byte(user.Lockout.UnlockTokenHash), []byte(hashToken(secret))) != 1 {
+		return invalid
+	}
+	return c.UnlockUser(ctx, user)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // LocaleMiddleware detects language from Accept-Language header and sets i18n in context
@@ -25,7 +26,9 @@ func (c *Controller) LocaleMiddleware() gin.HandlerFunc {
 	}
 }
 
-// AuthMiddleware validates JWT token and attaches claims to context
+// AuthMiddleware validates the JWT or personal API token of the request and
+// attaches claims to context. JWTs of revoked sessions, and JWTs carrying a
+// role the user no longer has, are rejected.
 func (c *Controller) AuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		i18n := c.GetI18n(ctx)
@@ -45,11 +48,39 @@ func (c *Controller) AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := tokenParts[1]
-		claims, err := c.ValidateToken(tokenString)
-		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T("jwt.invalid")})
-			ctx.Abort()
-			return
+		var claims *Claims
+		var user *models.User
+		var apiToken *models.APIToken
+		if IsAPIToken(tokenString) {
+			// Personal API tokens act with the current role of their owner
+			token, owner, err := c.AuthenticateAPIToken(ctx.Request.Context(), tokenString)
+			if err != nil {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				ctx.Abort()
+				return
+			}
+			if !isReadOnlyMethod(ctx.Request.Method) && !token.HasScope(models.ScopeWrite) && !token.HasScope(models.ScopeAdmin) {
+				ctx.JSON(http.StatusForbidden, gin.H{"error": i18n.T("apiToken.insufficientScope")})
+				ctx.Abort()
+				return
+			}
+			user = owner
+			apiToken = token
+			claims = &Claims{UserID: user.ID.Hex(), Username: user.Username, Email: user.Email, Role: user.Role}
+			ctx.Set("apiToken", token)
+		} else {
+			var err error
+			claims, err = c.ValidateToken(tokenString)
+			if err != nil {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T("jwt.invalid")})
+				ctx.Abort()
+				return
+			}
+			if user, err = c.CheckSession(ctx.Request.Context(), claims); err != nil {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				ctx.Abort()
+				return
+			}
 		}
 
 		// Attach claims to context
@@ -58,13 +89,19 @@ func (c *Controller) AuthMiddleware() gin.HandlerFunc {
 		ctx.Set("username", claims.Username)
 		ctx.Set("email", claims.Email)
 		ctx.Set("role", claims.Role)
+		ctx.Set("sessionID", claims.SessionID)
+		ctx.Set("twoFactorEnabled", user.TwoFactor.Enabled)
+		ctx.Request = ctx.Request.WithContext(withAuditActor(ctx.Request.Context(), user, apiToken))
 
 		ctx.Next()
 	}
 }
 
-// RequireRole checks if user has the required role
-func (c *Controller) RequireRole(allowedRoles ...models.UserRole) gin.HandlerFunc {
+// CollectionMiddleware resolves the collection the request works on, from the
+// X-Collection-ID header or the collection_id query parameter, and scopes the
+// request context to it. Staff of the collection are held to the two-factor
+// policy like global staff. It must run after AuthMiddleware.
+func (c *Controller) CollectionMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		i18n := c.GetI18n(ctx)
 		claimsInterface, exists := ctx.Get("claims")
@@ -73,20 +110,125 @@ func (c *Controller) RequireRole(allowedRoles ...models.UserRole) gin.HandlerFun
 			ctx.Abort()
 			return
 		}
-
 		claims := claimsInterface.(*Claims)
 
-		// Check if user role is in allowed roles
-		allowed := false
-		for _, role := range allowedRoles {
-			if claims.Role == role {
-				allowed = true
-				break
-			}
+		userID, err := primitive.ObjectIDFromHex(claims.UserID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": i18n.T("api.invalidUserID")})
+			ctx.Abort()
+			return
 		}
 
-		if !allowed {
-			ctx.JSON(http.StatusForbidden, gin.H{"error": i18n.T("jwt.insufficientPermissions")})
+		requested := ctx.GetHeader("X-Collection-ID")
+		if requested == "" {
+			requested = ctx.Query("collection_id")
+		}
+
+		collection, role, err := c.ResolveCollection(ctx.Request.Context(), userID, claims.Role, requested)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
+
+		if !c.checkTwoFactor(ctx, role) {
+			return
+		}
+
+		ctx.Set("collection", collection)
+		ctx.Set("collectionRole", role)
+		ctx.Request = ctx.Request.WithContext(WithCollection(ctx.Request.Context(), collection.ID))
+
+		ctx.Next()
+	}
+}
+
+// RequirePermission checks that the role of the user grants the permission.
+// Within a collection, the role of the user in that collection is checked.
+// Personal API tokens must also have a scope covering the permission.
+func (c *Controller) RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !c.CheckPermission(ctx, permission) {
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// CheckPermission makes the checks of RequirePermission from within a
+// handler, for requests that only need the permission for some changes. It
+// writes the error response and aborts the request when they fail.
+func (c *Controller) CheckPermission(ctx *gin.Context, permission models.Permission) bool {
+	i18n := c.GetI18n(ctx)
+	claimsInterface, exists := ctx.Get("claims")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T("jwt.unauthorized")})
+		ctx.Abort()
+		return false
+	}
+
+	claims := claimsInterface.(*Claims)
+	userRole := claims.Role
+	if collectionRole, ok := ctx.Get("collectionRole"); ok {
+		userRole = collectionRole.(models.UserRole)
+	}
+
+	allowed, err := c.HasPermission(ctx.Request.Context(), userRole, permission)
+	if err != nil || !allowed {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": i18n.T("jwt.insufficientPermissions")})
+		ctx.Abort()
+		return false
+	}
+	if token, ok := ctx.Get("apiToken"); ok && !tokenScopeAllows(token.(*models.APIToken), permission, ctx.Request.Method) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": i18n.T("apiToken.insufficientScope")})
+		ctx.Abort()
+		return false
+	}
+	return true
+}
+
+// RequireTwoFactor blocks staff members without two-factor authentication
+// when the settings require it, so that they set it up first. It must run
+// after AuthMiddleware.
+func (c *Controller) RequireTwoFactor() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		role, _ := ctx.Get("role")
+		if !c.checkTwoFactor(ctx, role.(models.UserRole)) {
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// checkTwoFactor makes the checks of RequireTwoFactor for the given role,
+// writing the error response and aborting the request when they fail
+func (c *Controller) checkTwoFactor(ctx *gin.Context, role models.UserRole) bool {
+	if enabled, _ := ctx.Get("twoFactorEnabled"); enabled == true {
+		return true
+	}
+
+	required, err := c.TwoFactorRequired(ctx.Request.Context(), role)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Abort()
+		return false
+	}
+	if required {
+		i18n := c.GetI18n(ctx)
+		ctx.JSON(http.StatusForbidden, gin.H{"error": i18n.T("twoFactor.setupRequired"), "two_factor_setup_required": true})
+		ctx.Abort()
+		return false
+	}
+	return true
+}
+
+// RequireSession keeps personal API tokens away from account security
+// routes, such as changing the password or creating more tokens
+func (c *Controller) RequireSession() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := ctx.Get("apiToken"); ok {
+			i18n := c.GetI18n(ctx)
+			ctx.JSON(http.StatusForbidden, gin.H{"error": i18n.T("apiToken.sessionRequired")})
 			ctx.Abort()
 			return
 		}
@@ -100,7 +242,7 @@ func (c *Controller) CORSMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Collection-ID")
 		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if ctx.Request.Method == "OPTIONS" {
@@ -7,12 +7,22 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// CreateNotification records a notification about the active collection,
+// unless it names another one
 func (c *Controller) CreateNotification(ctx context.Context, notification *models.Notification) error {
+	if notification.CollectionID.IsZero() {
+		notification.CollectionID, _ = CollectionFromContext(ctx)
+	}
 	return c.ds.CreateNotification(ctx, notification)
 }
 
+// GetUserNotifications returns the latest notifications of the user about the
+// active collection, along with those about no collection in particular
 func (c *Controller) GetUserNotifications(ctx context.Context, userID primitive.ObjectID, limit int) ([]*models.Notification, error) {
-	return c.ds.GetUserNotifications(ctx, userID, limit)
+	if id, ok := CollectionFromContext(ctx); ok {
+		return c.ds.GetUserNotifications(ctx, userID, &id, limit)
+	}
+	return c.ds.GetUserNotifications(ctx, userID, nil, limit)
 }
 
 func (c *Controller) MarkNotificationAsRead(ctx context.Context, notificationID primitive.ObjectID) error {
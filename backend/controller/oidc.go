@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+	"eylexander/bluraymanager/services"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// oidcStateAudience keeps login states from being used as anything else
+	oidcStateAudience = "oidc-login"
+	// OIDCStateTTL is how long a single sign-on attempt may take
+	OIDCStateTTL = 10 * time.Minute
+)
+
+// usernameUnsafe matches what is dropped from identity provider usernames
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// oidcStateClaims is the state of a single sign-on attempt. It travels
+// through the identity provider and comes back with the authorization code,
+// so the backend does not need to remember pending logins. Binding is the
+// hash of a secret kept by the browser that started the attempt, so that
+// nobody can finish it in another browser.
+type oidcStateClaims struct {
+	Nonce   string `json:"nonce"`
+	Binding string `json:"binding"`
+	jwt.RegisteredClaims
+}
+
+// OIDCProviderName returns the name of the identity provider to show on the
+// login page, and whether single sign-on is enabled at all
+func (c *Controller) OIDCProviderName() (string, bool) {
+	if c.oidc == nil || !c.oidc.IsConfigured() {
+		return "", false
+	}
+	return c.oidc.DisplayName, true
+}
+
+// StartOIDCLogin returns the address of the identity provider login page,
+// the state the frontend hands back along with the authorization code and
+// the binding secret the browser must keep, out of reach of scripts, until
+// then. The frontend should check that the provider returned the same state.
+func (c *Controller) StartOIDCLogin(ctx context.Context) (string, string, string, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if _, ok := c.OIDCProviderName(); !ok {
+		return "", "", "", errors.New(i18n.T("oidc.notConfigured"))
+	}
+
+	nonce, err := newTokenSecret()
+	if err != nil {
+		return "", "", "", err
+	}
+	binding, err := newTokenSecret()
+	if err != nil {
+		return "", "", "", err
+	}
+	now := time.Now()
+	claims := &oidcStateClaims{
+		Nonce:   nonce,
+		Binding: hashToken(binding),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oidcStateAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCStateTTL)),
+		},
+	}
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret())
+	if err != nil {
+		return "", "", "", err
+	}
+
+	authURL, err := c.oidc.AuthorizationURL(ctx, state, nonce)
+	if err != nil {
+		log.Printf("ERROR StartOIDCLogin: %v", err)
+		return "", "", "", errors.New(i18n.T("oidc.unavailable"))
+	}
+	return authURL, state, binding, nil
+}
+
+// CompleteOIDCLogin exchanges the authorization code for the identity of the
+// user and returns their account. The binding is the secret the browser got
+// from StartOIDCLogin. Accounts are linked by verified email the first time,
+// and created for verified emails when nothing matches.
+func (c *Controller) CompleteOIDCLogin(ctx context.Context, code, state, binding string) (*models.User, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if _, ok := c.OIDCProviderName(); !ok {
+		return nil, errors.New(i18n.T("oidc.notConfigured"))
+	}
+
+	claims := &oidcStateClaims{}
+	_, err := jwt.ParseWithClaims(state, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret(), nil
+	}, jwt.WithAudience(oidcStateAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || claims.Nonce == "" {
+		return nil, errors.New(i18n.T("oidc.invalidState"))
+	}
+	// A login started in another browser must not sign this one in
+	if binding == "" || subtle.ConstantTimeCompare([]byte(claims.Binding), []byte(hashToken(binding))) != 1 {
+		return nil, errors.New(i18n.T("oidc.invalidState"))
+	}
+
+	identity, err := c.oidc.Exchange(ctx, code, claims.Nonce)
+	if err != nil {
+		log.Printf("ERROR CompleteOIDCLogin: %v", err)
+		return nil, errors.New(i18n.T("oidc.loginFailed"))
+	}
+	user, err := c.oidcUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signing in at the provider does not get around a lock, but it does
+	// forget the failed attempts unless a second factor is still to come,
+	// as a password login does
+	if user.Lockout.IsLocked(time.Now()) {
+		return nil, lockedError(ctx, user)
+	}
+	if !user.TwoFactor.Enabled {
+		if err := c.recordSuccessfulLogin(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// oidcUser finds, links or creates the account of the identity. The role
+// mapping is applied on every login, so that changes at the provider show up.
+func (c *Controller) oidcUser(ctx context.Context, identity *services.OIDCIdentity) (*models.User, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	link := models.ExternalIdentity{Issuer: identity.Issuer, Subject: identity.Subject}
+	role := c.oidcMappedRole(ctx, identity)
+
+	user, err := c.ds.GetUserByExternalIdentity(ctx, link)
+	if err != nil {
+		if identity.Email == "" {
+			return nil, errors.New(i18n.T("oidc.emailRequired"))
+		}
+		// Whoever controls an unverified address at the provider must not
+		// get into the account that registered it here, nor take the
+		// address for a new one
+		if !identity.EmailVerified {
+			return nil, errors.New(i18n.T("oidc.emailNotVerified"))
+		}
+		user, err = c.ds.GetUserByEmail(ctx, identity.Email)
+		if err != nil {
+			if !c.oidc.AutoProvision {
+				return nil, errors.New(i18n.T("oidc.signupDisabled"))
+			}
+			return c.provisionOIDCUser(ctx, identity, link, role)
+		}
+		if user.OIDC != nil {
+			return nil, errors.New(i18n.T("oidc.alreadyLinked"))
+		}
+	} else if role == "" || user.Role == role {
+		return user, nil
+	}
+
+	before := *user
+	user.OIDC = &link
+	if role != "" {
+		user.Role = role
+	}
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	c.audit(ctx, models.AuditUpdate, &before, user)
+	return user, nil
+}
+
+// provisionOIDCUser creates the account of someone signing in for the first
+// time. It has no password; one can be set with a password reset.
+func (c *Controller) provisionOIDCUser(ctx context.Context, identity *services.OIDCIdentity, link models.ExternalIdentity, role models.UserRole) (*models.User, error) {
+	if role == "" {
+		role = models.UserRole(c.oidc.DefaultRole)
+	}
+	if _, err := c.GetRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	username, err := c.availableUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username: username,
+		Email:    identity.Email,
+		Role:     role,
+		OIDC:     &link,
+	}
+	if err := c.ds.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	c.audit(ctx, models.AuditCreate, nil, user)
+	return user, nil
+}
+
+// oidcMappedRole returns the role the mapping gives to the identity, or ""
+// when no mapping applies or it names a role that does not exist
+func (c *Controller) oidcMappedRole(ctx context.Context, identity *services.OIDCIdentity) models.UserRole {
+	role := models.UserRole(c.oidc.MappedRole(identity))
+	if role == "" {
+		return ""
+	}
+	if _, err := c.GetRole(ctx, role); err != nil {
+		log.Printf("ERROR OIDC role mapping: unknown role %q", role)
+		return ""
+	}
+	return role
+}
+
+// availableUsername derives a free username from the preferred username or
+// the email of the identity
+func (c *Controller) availableUsername(ctx context.Context, identity *services.OIDCIdentity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = usernameUnsafe.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 2; ; i++ {
+		if _, err := c.ds.GetUserByUsername(ctx, candidate); err != nil {
+			return candidate, nil
+		}
+		if i > 100 {
+			suffix, err := newTokenSecret()
+			if err != nil {
+				return "", err
+			}
+			return base + "-" + suffix[:8], nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+}
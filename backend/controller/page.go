@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+
+	"eylexander/bluraymanager/datastore"
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QueryOptionsError is returned for list requests with an unknown sort or a
+// cursor that cannot be read
+type QueryOptionsError struct {
+	message string
+}
+
+func (e *QueryOptionsError) Error() string {
+	return e.message
+}
+
+// pageCursor is the position of the last bluray of a page, with the sort it
+// was taken in. The next page starts right after it, wherever blurays added
+// or deleted since then moved it.
+type pageCursor struct {
+	Sort    models.SearchSort  `json:"s,omitempty"`
+	Text    string             `json:"t,omitempty"`
+	Number  float64            `json:"n,omitempty"`
+	Missing bool               `json:"m,omitempty"`
+	Created int64              `json:"c"`
+	ID      primitive.ObjectID `json:"i"`
+}
+
+func encodeCursor(order models.SearchSort, position datastore.SortPosition) string {
+	data, _ := json.Marshal(pageCursor{
+		Sort:    order,
+		Text:    position.Key.Text,
+		Number:  position.Key.Number,
+		Missing: position.Key.Missing,
+		Created: position.Created,
+		ID:      position.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads a cursor, which must have been taken in the same sort
+func decodeCursor(cursor string, order models.SearchSort) (datastore.SortPosition, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return datastore.SortPosition{}, false
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != order || c.ID.IsZero() {
+		return datastore.SortPosition{}, false
+	}
+	return datastore.SortPosition{
+		Key:     datastore.SortKey{Text: c.Text, Number: c.Number, Missing: c.Missing},
+		Created: c.Created,
+		ID:      c.ID,
+	}, true
+}
+
+// offsetCursor is the position of the next page of the lists that are not
+// sorted on request, such as the audit log: how many items come before it
+type offsetCursor struct {
+	Offset int `json:"o"`
+}
+
+// offsetPage returns the page of the options from a list that is not sorted
+// on request, read with list, with the total that count returns and the
+// cursor of the next page. The sort of the options is ignored.
+func offsetPage[T any](ctx context.Context, opts *models.QueryOptions, list func(skip, limit int) ([]T, error), count func() (int, error)) ([]T, *models.PageInfo, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	start := max(opts.Skip, 0)
+	if opts.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+		var c offsetCursor
+		if err != nil || json.Unmarshal(data, &c) != nil || c.Offset <= 0 {
+			return nil, nil, &QueryOptionsError{message: i18n.T("query.invalidCursor")}
+		}
+		start += c.Offset
+	}
+
+	items, err := list(start, opts.Limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	total, err := count()
+	if err != nil {
+		return nil, nil, err
+	}
+	info := &models.PageInfo{Total: total}
+	if len(items) > 0 && start+len(items) < total {
+		data, _ := json.Marshal(offsetCursor{Offset: start + len(items)})
+		info.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	if items == nil {
+		items = []T{}
+	}
+	return items, info, nil
+}
+
+// storePage turns the options into a page for the datastore to sort, or nil
+// when the blurays have to be sorted here: by rating, which is not stored,
+// or by relevance to a search. Lists without a sort are newest first.
+func storePage(ctx context.Context, opts *models.QueryOptions, search bool) (*datastore.BlurayPage, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if !opts.Sort.IsValid() {
+		return nil, &QueryOptionsError{message: i18n.T("query.invalidSort")}
+	}
+	field, desc := opts.Sort.Field()
+	if field == "" && !search {
+		field, desc = models.SortAdded, true
+	}
+	if !datastore.IsStoredSortField(field) {
+		return nil, nil
+	}
+
+	page := &datastore.BlurayPage{Field: field, Desc: desc, Skip: max(opts.Skip, 0)}
+	if opts.Limit > 0 {
+		// One more bluray tells whether there is a next page
+		page.Limit = opts.Limit + 1
+	}
+	if opts.Cursor != "" {
+		after, ok := decodeCursor(opts.Cursor, opts.Sort)
+		if !ok {
+			return nil, &QueryOptionsError{message: i18n.T("query.invalidCursor")}
+		}
+		page.After = &after
+	}
+	return page, nil
+}
+
+// newestPage asks the datastore for blurays newest first, the order of the
+// listings without a sort. A limit of 0 returns every bluray.
+func newestPage(limit int) *datastore.BlurayPage {
+	return &datastore.BlurayPage{Field: models.SortAdded, Desc: true, Limit: limit}
+}
+
+// storedPage cuts the page the datastore returned to the limit of the
+// options and returns it with the total and the cursor of the next page
+func storedPage[T any](items []T, total int, page *datastore.BlurayPage, entry func(T, models.SortField) datastore.SortPosition, opts *models.QueryOptions) ([]T, *models.PageInfo) {
+	info := &models.PageInfo{Total: total}
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		items = items[:opts.Limit]
+		info.NextCursor = encodeCursor(opts.Sort, entry(items[len(items)-1], page.Field))
+	}
+	return items, info
+}
+
+// paginate sorts the items, filled in with everything they are sorted by,
+// and returns the page the options ask for with the total and the cursor of
+// the next page
+func paginate[T any](ctx context.Context, items []T, entry func(T, models.SortField) datastore.SortPosition, opts *models.QueryOptions) ([]T, *models.PageInfo, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if !opts.Sort.IsValid() {
+		return nil, nil, &QueryOptionsError{message: i18n.T("query.invalidSort")}
+	}
+	field, desc := opts.Sort.Field()
+	if field == "" {
+		// Most relevant first
+		desc = true
+	}
+	// Ties are newest first, unless sorting by date added
+	newest := field != models.SortAdded || desc
+
+	entries := make([]datastore.SortPosition, len(items))
+	for i, item := range items {
+		entries[i] = entry(item, field)
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return entries[order[i]].Compare(entries[order[j]], desc, newest) < 0
+	})
+
+	start := 0
+	if opts.Cursor != "" {
+		after, ok := decodeCursor(opts.Cursor, opts.Sort)
+		if !ok {
+			return nil, nil, &QueryOptionsError{message: i18n.T("query.invalidCursor")}
+		}
+		start = sort.Search(len(order), func(i int) bool {
+			return entries[order[i]].Compare(after, desc, newest) > 0
+		})
+	}
+	start = min(start+max(opts.Skip, 0), len(order))
+	end := len(order)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, end)
+	}
+
+	page := &models.PageInfo{Total: len(items)}
+	if end < len(order) && end > start {
+		page.NextCursor = encodeCursor(opts.Sort, entries[order[end-1]])
+	}
+	var sorted []T
+	for _, i := range order[start:end] {
+		sorted = append(sorted, items[i])
+	}
+	if sorted == nil && items != nil {
+		sorted = []T{}
+	}
+	return sorted, page, nil
+}
+
+// blurayEntry places a bluray for a sort field: the household rating for
+// the rating, the relevance to the search without a field and the stored
+// sort key otherwise
+func blurayEntry(bluray *models.Bluray, field models.SortField) datastore.SortPosition {
+	position := datastore.SortPosition{Created: bluray.CreatedAt.UnixMilli(), ID: bluray.ID}
+	switch field {
+	case "":
+		position.Key = datastore.SortKey{Number: bluray.Score}
+	case models.SortRating:
+		position.Key = datastore.SortKey{Number: bluray.Rating, Missing: bluray.RatingCount == 0}
+	default:
+		position.Key = datastore.StoredSortKey(field, bluray.Title, bluray.ReleaseYear, bluray.Copies)
+	}
+	return position
+}
+
+// simplifiedEntry places a simplified bluray as blurayEntry does. Simplified
+// blurays are never searched, so they are newest first without a field.
+func simplifiedEntry(bluray *models.SimplifiedBluray, field models.SortField) datastore.SortPosition {
+	position := datastore.SortPosition{Created: bluray.CreatedAt.UnixMilli(), ID: bluray.ID}
+	if field == models.SortRating {
+		position.Key = datastore.SortKey{Number: bluray.Rating, Missing: bluray.RatingCount == 0}
+	} else {
+		position.Key = datastore.StoredSortKey(field, bluray.Title, bluray.ReleaseYear, bluray.Copies)
+	}
+	return position
+}
@@ -10,6 +10,7 @@ import (
 	"eylexander/bluraymanager/services"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type PasswordResetHandler struct {
@@ -110,6 +111,11 @@ func (h *PasswordResetHandler) ResetPassword(ctx *gin.Context) {
 	// Delete used token
 	h.store.DeletePasswordResetToken(req.Token)
 
+	// Whoever knew the old password is signed out
+	if id, err := primitive.ObjectIDFromHex(userID); err == nil {
+		h.store.RevokeUserSessions(ctx.Request.Context(), id, primitive.NilObjectID)
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{"message": i18n.T("passwordReset.passwordResetSuccessfully")})
 }
 
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit allows Requests per Period, in bursts of up to Requests. The
+// zero value allows everything.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Every returns the limit of n requests per period
+func Every(period time.Duration, n int) RateLimit {
+	return RateLimit{Requests: n, Period: period}
+}
+
+func (l RateLimit) enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// ParseRateLimit reads limits written like "20/1m". "off" and "0" disable
+// the limit.
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return RateLimit{}, nil
+	}
+	count, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q is not written like 20/1m", s)
+	}
+	requests, err := strconv.Atoi(count)
+	if err != nil || requests < 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q has an invalid request count", s)
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q has an invalid period", s)
+	}
+	return RateLimit{Requests: requests, Period: duration}, nil
+}
+
+// RateLimitRule limits a group of routes per client IP and per account.
+// The limits can be changed with RATE_LIMIT_<NAME>_IP and
+// RATE_LIMIT_<NAME>_ACCOUNT.
+type RateLimitRule struct {
+	Name       string
+	PerIP      RateLimit
+	PerAccount RateLimit
+}
+
+// RateLimitStore holds the token buckets of the rate limits. The in-process
+// store suits a single instance; running several needs a shared store.
+type RateLimitStore interface {
+	// Take removes a token from the bucket of the key. When the bucket is
+	// empty it returns false and how long until a token is available.
+	Take(key string, limit RateLimit, now time.Time) (bool, time.Duration)
+}
+
+// MemoryRateLimitStore keeps the buckets in memory. Buckets that filled up
+// again are dropped from time to time.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.sweep(now)
+	}
+
+	capacity := float64(limit.Requests)
+	perToken := limit.Period / time.Duration(limit.Requests)
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updated: now, period: limit.Period}
+		s.buckets[key] = bucket
+	} else if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens = math.Min(capacity, bucket.tokens+float64(elapsed)/float64(perToken))
+		bucket.updated = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) * float64(perToken))
+}
+
+// sweep drops the buckets that had the time to fill up again
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) >= bucket.period {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// RateLimit rejects requests over the limits of the rule with 429 Too Many
+// Requests and a Retry-After header. Signed-in requests count against their
+// user; sign-in requests against the email or username in their body.
+func (c *Controller) RateLimit(rule RateLimitRule) gin.HandlerFunc {
+	perIP := rateLimitFromEnv("RATE_LIMIT_"+strings.ToUpper(rule.Name)+"_IP", rule.PerIP)
+	perAccount := rateLimitFromEnv("RATE_LIMIT_"+strings.ToUpper(rule.Name)+"_ACCOUNT", rule.PerAccount)
+
+	return func(ctx *gin.Context) {
+		now := time.Now()
+		if perIP.enabled() {
+			if ok, wait := c.limiter.Take(rule.Name+":ip:"+ctx.ClientIP(), perIP, now); !ok {
+				c.tooManyRequests(ctx, wait)
+				return
+			}
+		}
+		if perAccount.enabled() {
+			if account := requestAccount(ctx); account != "" {
+				if ok, wait := c.limiter.Take(rule.Name+":account:"+account, perAccount, now); !ok {
+					c.tooManyRequests(ctx, wait)
+					return
+				}
+			}
+		}
+		ctx.Next()
+	}
+}
+
+func (c *Controller) tooManyRequests(ctx *gin.Context, wait time.Duration) {
+	i18n := c.GetI18n(ctx)
+	SetRetryAfter(ctx, wait)
+	ctx.JSON(http.StatusTooManyRequests, gin.H{"error": i18n.T("rateLimit.tooManyRequests")})
+	ctx.Abort()
+}
+
+// SetRetryAfter tells the client how many seconds to wait, rounded up
+func SetRetryAfter(ctx *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+}
+
+// requestAccount names the account a request works on: the signed-in user,
+// or the identifier or email of a sign-in request body
+func requestAccount(ctx *gin.Context) string {
+	if userID := ctx.GetString("userID"); userID != "" {
+		return userID
+	}
+	if ctx.Request.Body == nil || !strings.HasPrefix(ctx.ContentType(), "application/json") {
+		return ""
+	}
+
+	// Only the start of the body is read, and it is put back for the handler
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, 1<<16))
+	ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+	if err != nil {
+		return ""
+	}
+
+	var fields struct {
+		Identifier string `json:"identifier"`
+		Email      string `json:"email"`
+	}
+	if json.Unmarshal(body, &fields) != nil {
+		return ""
+	}
+	if fields.Identifier != "" {
+		return strings.ToLower(strings.TrimSpace(fields.Identifier))
+	}
+	return strings.ToLower(strings.TrimSpace(fields.Email))
+}
+
+func rateLimitFromEnv(name string, fallback RateLimit) RateLimit {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	limit, err := ParseRateLimit(value)
+	if err != nil {
+		log.Printf("WARNING %s: %v, using the default", name, err)
+		return fallback
+	}
+	return limit
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// topRatedCount is the number of blurays listed in the top rated statistics
+const topRatedCount = 10
+
+// SetUserRating creates or replaces the personal rating of a bluray by the user
+func (c *Controller) SetUserRating(ctx context.Context, userID, blurayID primitive.ObjectID, req *models.SetUserRatingRequest) (*models.UserRating, error) {
+	rating := &models.UserRating{
+		UserID:   userID,
+		BlurayID: blurayID,
+		Score:    req.Score,
+		Review:   strings.TrimSpace(req.Review),
+	}
+	if err := validateUserRating(ctx, rating); err != nil {
+		return nil, err
+	}
+	if err := c.ds.SetUserRating(ctx, rating); err != nil {
+		return nil, err
+	}
+	return rating, nil
+}
+
+func (c *Controller) GetUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) (*models.UserRating, error) {
+	return c.ds.GetUserRating(ctx, userID, blurayID)
+}
+
+func (c *Controller) DeleteUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) error {
+	return c.ds.DeleteUserRating(ctx, userID, blurayID)
+}
+
+// ratingAverages indexes the household average and number of ratings by
+// bluray ID
+func (c *Controller) ratingAverages(ctx context.Context) (map[primitive.ObjectID]float64, map[primitive.ObjectID]int, error) {
+	ratings, err := c.ds.ListUserRatings(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	totals := make(map[primitive.ObjectID]float64)
+	counts := make(map[primitive.ObjectID]int)
+	for _, rating := range ratings {
+		totals[rating.BlurayID] += rating.Score
+		counts[rating.BlurayID]++
+	}
+	for id, total := range totals {
+		totals[id] = total / float64(counts[id])
+	}
+	return totals, counts, nil
+}
+
+// annotateRatings fills in the household average rating of blurays before
+// they are sent out
+func (c *Controller) annotateRatings(ctx context.Context, blurays ...*models.Bluray) error {
+	averages, counts, err := c.ratingAverages(ctx)
+	if err != nil {
+		return err
+	}
+	for _, bluray := range blurays {
+		bluray.Rating = averages[bluray.ID]
+		bluray.RatingCount = counts[bluray.ID]
+	}
+	return nil
+}
+
+// fillRatingStatistics computes the average rating and the top rated blurays,
+// from the ratings of one user or, when userID is nil, from the household
+// averages
+func (c *Controller) fillRatingStatistics(ctx context.Context, stats *models.Statistics, userID *primitive.ObjectID) error {
+	all, err := c.ds.ListUserRatings(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Only the blurays of the active collection count
+	blurays, err := c.activeBlurayIDs(ctx)
+	if err != nil {
+		return err
+	}
+	var ratings []*models.UserRating
+	for _, rating := range all {
+		if blurays == nil || blurays[rating.BlurayID] {
+			ratings = append(ratings, rating)
+		}
+	}
+
+	stats.RatingCount = len(ratings)
+	stats.AverageRating = 0
+	if len(ratings) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	scores := make(map[primitive.ObjectID]float64)
+	counts := make(map[primitive.ObjectID]int)
+	var order []primitive.ObjectID
+	for _, rating := range ratings {
+		total += rating.Score
+		if counts[rating.BlurayID] == 0 {
+			order = append(order, rating.BlurayID)
+		}
+		scores[rating.BlurayID] += rating.Score
+		counts[rating.BlurayID]++
+	}
+	stats.AverageRating = total / float64(len(ratings))
+	for id := range scores {
+		scores[id] /= float64(counts[id])
+	}
+
+	// Ties go to the bluray rated by more people, then to the latest rated
+	sort.SliceStable(order, func(i, j int) bool {
+		if scores[order[i]] != scores[order[j]] {
+			return scores[order[i]] > scores[order[j]]
+		}
+		return counts[order[i]] > counts[order[j]]
+	})
+
+	stats.TopRated = []models.BlurayStats{}
+	for _, id := range order {
+		if len(stats.TopRated) == topRatedCount {
+			break
+		}
+		bluray, err := c.ds.GetBlurayByID(ctx, id)
+		if err != nil || bluray.DeletedAt != nil {
+			continue
+		}
+		stats.TopRated = append(stats.TopRated, models.BlurayStats{
+			ID:     bluray.ID.Hex(),
+			Title:  bluray.Title,
+			Type:   string(bluray.Type),
+			Rating: scores[id],
+		})
+	}
+	return nil
+}
+
+func validateUserRating(ctx context.Context, rating *models.UserRating) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if rating.Score <= 0 || rating.Score > models.RatingMaxScore {
+		return errors.New(i18n.T("rating.invalidScore"))
+	}
+	if utf8.RuneCountInString(rating.Review) > models.RatingMaxReviewLen {
+		return errors.New(i18n.T("rating.reviewTooLong"))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordRevision saves the stored state of a bluray after a write as its
+// next revision. Blurays added before revisions were kept first get one of
+// their state before the write, so that it can be restored. Writes that
+// change nothing save no revision. The write is already made, so a failure
+// is logged rather than returned.
+func (c *Controller) recordRevision(ctx context.Context, before *models.Bluray, blurayID primitive.ObjectID) {
+	stored, err := c.ds.GetBlurayByID(ctx, blurayID)
+	if err != nil {
+		log.Printf("ERROR recordRevision %s: %v", blurayID.Hex(), err)
+		return
+	}
+	latest, err := c.ds.ListBlurayRevisions(ctx, blurayID, 0, 1)
+	if err != nil {
+		log.Printf("ERROR ListBlurayRevisions %s: %v", blurayID.Hex(), err)
+		return
+	}
+
+	previous := before
+	if len(latest) > 0 {
+		previous = &latest[0].Bluray
+	} else if before != nil {
+		if err := c.ds.CreateBlurayRevision(ctx, &models.BlurayRevision{BlurayID: blurayID, Bluray: *before}); err != nil {
+			log.Printf("ERROR CreateBlurayRevision %s: %v", blurayID.Hex(), err)
+			return
+		}
+	}
+	if previous != nil {
+		changes, err := auditChanges(previous, stored)
+		if err != nil {
+			log.Printf("ERROR recordRevision %s: %v", blurayID.Hex(), err)
+			return
+		}
+		if len(changes) == 0 {
+			return
+		}
+	}
+
+	source := auditSourceFromContext(ctx)
+	revision := &models.BlurayRevision{
+		BlurayID:     blurayID,
+		EditedBy:     source.ActorID,
+		EditedByName: source.ActorName,
+		Bluray:       *stored,
+	}
+	if err := c.ds.CreateBlurayRevision(ctx, revision); err != nil {
+		log.Printf("ERROR CreateBlurayRevision %s: %v", blurayID.Hex(), err)
+	}
+}
+
+// ListBlurayRevisions returns the page of the options of the revisions of a
+// bluray of the library, newest first
+func (c *Controller) ListBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID, opts *models.QueryOptions) ([]*models.BlurayRevision, *models.PageInfo, error) {
+	if _, err := c.getBluray(ctx, blurayID); err != nil {
+		return nil, nil, err
+	}
+	return offsetPage(ctx, opts, func(skip, limit int) ([]*models.BlurayRevision, error) {
+		return c.ds.ListBlurayRevisions(ctx, blurayID, skip, limit)
+	}, func() (int, error) {
+		return c.ds.CountBlurayRevisions(ctx, blurayID)
+	})
+}
+
+// GetBlurayRevision returns a revision of a bluray of the library
+func (c *Controller) GetBlurayRevision(ctx context.Context, blurayID primitive.ObjectID, number int) (*models.BlurayRevision, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if _, err := c.getBluray(ctx, blurayID); err != nil {
+		return nil, err
+	}
+	revision, err := c.ds.GetBlurayRevision(ctx, blurayID, number)
+	if err != nil {
+		return nil, errors.New(i18n.T("revision.notFound"))
+	}
+	return revision, nil
+}
+
+// DiffBlurayRevisions lists the fields that changed from one revision of a
+// bluray to another
+func (c *Controller) DiffBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID, from, to int) (*models.BlurayRevisionDiff, error) {
+	fromRevision, err := c.GetBlurayRevision(ctx, blurayID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := c.GetBlurayRevision(ctx, blurayID, to)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := auditChanges(&fromRevision.Bluray, &toRevision.Bluray)
+	if err != nil {
+		return nil, err
+	}
+	return &models.BlurayRevisionDiff{From: from, To: to, Changes: changes}, nil
+}
+
+// RestoreBlurayRevision puts a bluray back the way it was at a revision. The
+// restore is an update like any other: it is validated the same way and
+// saved as a new revision.
+func (c *Controller) RestoreBlurayRevision(ctx context.Context, blurayID primitive.ObjectID, number int) (*models.Bluray, error) {
+	revision, err := c.GetBlurayRevision(ctx, blurayID, number)
+	if err != nil {
+		return nil, err
+	}
+	bluray := revision.Bluray
+	bluray.ID = blurayID
+	if err := c.UpdateBluray(ctx, &bluray); err != nil {
+		return nil, err
+	}
+	return &bluray, nil
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+)
+
+// roleNamePattern restricts role names to what fits in a JWT claim and a URL
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// GetRole returns the named role. Built-in roles that were never stored have
+// their default permissions, and admin always holds every permission.
+func (c *Controller) GetRole(ctx context.Context, name models.UserRole) (*models.Role, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	role, err := c.ds.GetRoleByName(ctx, name)
+	if err != nil {
+		role = nil
+		for _, builtIn := range models.DefaultRoles() {
+			if builtIn.Name == name {
+				role = builtIn
+				break
+			}
+		}
+		if role == nil {
+			return nil, errors.New(i18n.T("role.notFound"))
+		}
+	}
+
+	if role.Name == models.RoleAdmin {
+		role.Permissions = append([]models.Permission{}, models.AllPermissions...)
+	}
+	return role, nil
+}
+
+// HasPermission reports whether the named role grants the permission
+func (c *Controller) HasPermission(ctx context.Context, name models.UserRole, permission models.Permission) (bool, error) {
+	role, err := c.GetRole(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return role.Has(permission), nil
+}
+
+// RoleGrantError is returned when a user tries to give a role with
+// permissions they do not hold themselves
+type RoleGrantError struct {
+	message string
+}
+
+func (e *RoleGrantError) Error() string {
+	return e.message
+}
+
+// CanGrantRole checks that the granter role holds every permission of role,
+// so that managing users never leads to more rights than one already has
+func (c *Controller) CanGrantRole(ctx context.Context, granter, role models.UserRole) error {
+	granted, err := c.GetRole(ctx, role)
+	if err != nil {
+		return err
+	}
+	return c.CanGrantPermissions(ctx, granter, granted.Permissions)
+}
+
+// CanGrantPermissions checks that the granter role holds every permission,
+// so that editing roles never leads to more rights than one already has
+func (c *Controller) CanGrantPermissions(ctx context.Context, granter models.UserRole, permissions []models.Permission) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	own, err := c.GetRole(ctx, granter)
+	if err != nil {
+		return &RoleGrantError{message: i18n.T("role.cannotGrant")}
+	}
+	for _, permission := range permissions {
+		if !permission.IsValid() {
+			return errors.New(i18n.T("role.invalidPermission"))
+		}
+		if !own.Has(permission) {
+			return &RoleGrantError{message: i18n.T("role.cannotGrant")}
+		}
+	}
+	return nil
+}
+
+// ListRoles returns the built-in roles followed by the custom ones
+func (c *Controller) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	if err := c.ds.EnsureDefaultRoles(ctx); err != nil {
+		return nil, err
+	}
+	roles, err := c.ds.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		if role.Name == models.RoleAdmin {
+			role.Permissions = append([]models.Permission{}, models.AllPermissions...)
+		}
+	}
+	return roles, nil
+}
+
+func (c *Controller) CreateRole(ctx context.Context, role *models.Role) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	role.Name = models.UserRole(strings.ToLower(strings.TrimSpace(string(role.Name))))
+	if !roleNamePattern.MatchString(string(role.Name)) {
+		return errors.New(i18n.T("role.invalidName"))
+	}
+	if _, err := c.GetRole(ctx, role.Name); err == nil {
+		return errors.New(i18n.T("role.duplicateName"))
+	}
+	if err := validateRole(ctx, role); err != nil {
+		return err
+	}
+
+	role.BuiltIn = false
+	if err := c.ds.CreateRole(ctx, role); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditCreate, nil, role)
+	return nil
+}
+
+// UpdateRole changes the description and permissions of a role. The admin
+// role cannot be changed, so that there is always someone able to fix roles.
+func (c *Controller) UpdateRole(ctx context.Context, role *models.Role) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if role.Name == models.RoleAdmin {
+		return errors.New(i18n.T("role.adminLocked"))
+	}
+	if err := validateRole(ctx, role); err != nil {
+		return err
+	}
+
+	before, err := c.GetRole(ctx, role.Name)
+	if err != nil {
+		return err
+	}
+
+	// Built-in roles are stored the first time they are edited
+	if err := c.ds.EnsureDefaultRoles(ctx); err != nil {
+		return err
+	}
+	if err := c.ds.UpdateRole(ctx, role); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditUpdate, before, role)
+	return nil
+}
+
+// DeleteRole removes a custom role nobody holds anymore
+func (c *Controller) DeleteRole(ctx context.Context, role *models.Role) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if role.BuiltIn {
+		return errors.New(i18n.T("role.cannotDeleteBuiltIn"))
+	}
+
+	users, err := c.ds.ListUsers(ctx, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.Role == role.Name {
+			return errors.New(i18n.T("role.inUse"))
+		}
+	}
+	collections, err := c.ds.ListCollections(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		for _, member := range collection.Members {
+			if member.Role == role.Name {
+				return errors.New(i18n.T("role.inUse"))
+			}
+		}
+	}
+
+	if err := c.ds.DeleteRole(ctx, role.Name); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditDelete, role, nil)
+	return nil
+}
+
+// validateRole checks and deduplicates the permissions of a role
+func validateRole(ctx context.Context, role *models.Role) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	role.Description = strings.TrimSpace(role.Description)
+	permissions := []models.Permission{}
+	seen := make(map[models.Permission]bool)
+	for _, permission := range role.Permissions {
+		if !permission.IsValid() {
+			return errors.New(i18n.T("role.invalidPermission"))
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	role.Permissions = permissions
+	return nil
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"eylexander/bluraymanager/datastore"
+	"eylexander/bluraymanager/models"
+)
+
+func TestCanGrantRole(t *testing.T) {
+	ctx := context.Background()
+	c := NewController(datastore.NewMemoryDatastore())
+	staff := &models.Role{Name: "staff", Permissions: []models.Permission{models.PermUserManage, models.PermRatingWrite, models.PermWatchLog}}
+	if err := c.CreateRole(ctx, staff); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+
+	for _, tt := range []struct {
+		granter, role models.UserRole
+		allowed       bool
+	}{
+		{models.RoleAdmin, models.RoleAdmin, true},
+		{models.RoleAdmin, "staff", true},
+		{"staff", models.RoleUser, true},
+		{"staff", models.RoleGuest, true},
+		{"staff", "staff", true},
+		{"staff", models.RoleContributor, false},
+		{"staff", models.RoleModerator, false},
+		{"staff", models.RoleAdmin, false},
+		{"wizard", models.RoleGuest, false},
+	} {
+		err := c.CanGrantRole(ctx, tt.granter, tt.role)
+		var grantErr *RoleGrantError
+		if tt.allowed && err != nil {
+			t.Errorf("CanGrantRole(%s, %s) = %v, want nil", tt.granter, tt.role, err)
+		}
+		if !tt.allowed && !errors.As(err, &grantErr) {
+			t.Errorf("CanGrantRole(%s, %s) = %v, want a RoleGrantError", tt.granter, tt.role, err)
+		}
+	}
+
+	// Unknown roles cannot be given by anyone
+	var grantErr *RoleGrantError
+	if err := c.CanGrantRole(ctx, models.RoleAdmin, "wizard"); err == nil || errors.As(err, &grantErr) {
+		t.Errorf("CanGrantRole(admin, wizard) = %v, want role not found", err)
+	}
+}
+
+func TestTwoFactorRequired(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMemoryDatastore()
+	c := NewController(ds)
+	for _, role := range []*models.Role{
+		{Name: "staff", Permissions: []models.Permission{models.PermUserManage}},
+		{Name: "editor", Permissions: []models.Permission{models.PermBlurayCreate, models.PermBlurayUpdate}},
+	} {
+		if err := c.CreateRole(ctx, role); err != nil {
+			t.Fatalf("CreateRole %s: %v", role.Name, err)
+		}
+	}
+	if err := ds.UpdateSettings(ctx, &models.Settings{RequireTwoFactorForStaff: true}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	// Staff status comes from the permissions of the role, not its name
+	for role, want := range map[models.UserRole]bool{
+		models.RoleAdmin:       true,
+		models.RoleModerator:   true,
+		"staff":                true,
+		models.RoleContributor: false,
+		"editor":               false,
+		models.RoleUser:        false,
+		"wizard":               false,
+	} {
+		if got, err := c.TwoFactorRequired(ctx, role); err != nil || got != want {
+			t.Errorf("TwoFactorRequired(%s) = %v, %v, want %v", role, got, err, want)
+		}
+	}
+}
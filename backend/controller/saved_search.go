@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListSavedSearches returns the searches the user saved and the smart
+// collections of the active collection, pinned first and then by name, with
+// the number of blurays each finds
+func (c *Controller) ListSavedSearches(ctx context.Context, userID primitive.ObjectID) ([]*models.SavedSearch, error) {
+	var collectionID *primitive.ObjectID
+	if id, ok := CollectionFromContext(ctx); ok {
+		collectionID = &id
+	}
+	searches, err := c.ds.ListSavedSearches(ctx, userID, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, search := range searches {
+		_, total, err := c.ds.PageSearchBlurays(ctx, search.Query, collectionFilter(ctx, nil), newestPage(1))
+		if err != nil {
+			// Searches are checked when saved, so this is not the user's doing
+			log.Printf("ERROR ListSavedSearches %s: %v", search.ID.Hex(), err)
+			continue
+		}
+		search.Count = total
+	}
+	sort.SliceStable(searches, func(i, j int) bool {
+		if searches[i].Pinned != searches[j].Pinned {
+			return searches[i].Pinned
+		}
+		return strings.ToLower(searches[i].Name) < strings.ToLower(searches[j].Name)
+	})
+	if searches == nil {
+		searches = []*models.SavedSearch{}
+	}
+	return searches, nil
+}
+
+// GetSavedSearch returns a search the user saved, or a smart collection of
+// the active collection
+func (c *Controller) GetSavedSearch(ctx context.Context, userID, id primitive.ObjectID) (*models.SavedSearch, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	search, err := c.ds.GetSavedSearchByID(ctx, id)
+	if err != nil {
+		return nil, errors.New(i18n.T("savedSearch.notFound"))
+	}
+	if search.Shared {
+		if search.CollectionID == nil || !inActiveCollection(ctx, *search.CollectionID) {
+			return nil, errors.New(i18n.T("savedSearch.notFound"))
+		}
+	} else if search.UserID != userID {
+		return nil, errors.New(i18n.T("savedSearch.notFound"))
+	}
+	return search, nil
+}
+
+// CreateSavedSearch saves a search for the user, or shares it with every
+// user of the active collection as a smart collection
+func (c *Controller) CreateSavedSearch(ctx context.Context, userID primitive.ObjectID, req *models.CreateSavedSearchRequest, shared bool) (*models.SavedSearch, error) {
+	search := &models.SavedSearch{
+		UserID: userID,
+		Name:   req.Name,
+		Query:  req.Query,
+		Sort:   req.Sort,
+		Pinned: req.Pinned,
+		Shared: shared,
+	}
+	if id, ok := CollectionFromContext(ctx); ok && shared {
+		search.CollectionID = &id
+	}
+	if err := c.validateSavedSearch(ctx, search); err != nil {
+		return nil, err
+	}
+	if err := c.ds.CreateSavedSearch(ctx, search); err != nil {
+		return nil, err
+	}
+	return search, nil
+}
+
+// UpdateSavedSearch applies the changes of the request to a saved search
+func (c *Controller) UpdateSavedSearch(ctx context.Context, search *models.SavedSearch, req *models.UpdateSavedSearchRequest) error {
+	if req.Name != nil {
+		search.Name = *req.Name
+	}
+	if req.Query != nil {
+		search.Query = *req.Query
+	}
+	if req.Sort != nil {
+		search.Sort = *req.Sort
+	}
+	if req.Pinned != nil {
+		search.Pinned = *req.Pinned
+	}
+	if err := c.validateSavedSearch(ctx, search); err != nil {
+		return err
+	}
+	return c.ds.UpdateSavedSearch(ctx, search)
+}
+
+func (c *Controller) DeleteSavedSearch(ctx context.Context, id primitive.ObjectID) error {
+	return c.ds.DeleteSavedSearch(ctx, id)
+}
+
+// RunSavedSearch returns a page of the blurays of the active collection a
+// saved search finds, in the order it was saved with, and sets its count
+func (c *Controller) RunSavedSearch(ctx context.Context, search *models.SavedSearch, opts *models.QueryOptions) ([]*models.Bluray, *models.PageInfo, error) {
+	opts.Sort = search.Sort
+	blurays, page, err := c.SearchBlurays(ctx, search.Query, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	search.Count = page.Total
+	return blurays, page, nil
+}
+
+// validateSavedSearch checks the name and sort of a saved search, and that
+// its query parses
+func (c *Controller) validateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	search.Name = strings.TrimSpace(search.Name)
+	search.Query = strings.TrimSpace(search.Query)
+	if search.Name == "" {
+		return errors.New(i18n.T("savedSearch.nameRequired"))
+	}
+	if search.Query == "" {
+		return errors.New(i18n.T("savedSearch.queryRequired"))
+	}
+	if !search.Sort.IsValid() {
+		return errors.New(i18n.T("query.invalidSort"))
+	}
+	if _, _, err := c.ds.PageSearchBlurays(ctx, search.Query, collectionFilter(ctx, nil), newestPage(1)); err != nil {
+		return searchQueryError(ctx, err)
+	}
+	return nil
+}
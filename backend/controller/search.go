@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"eylexander/bluraymanager/datastore"
+	"eylexander/bluraymanager/i18n"
+)
+
+// SearchQueryError is returned for search queries that do not parse. Code
+// names the problem and Position is where it is, in characters from 0.
+type SearchQueryError struct {
+	Code     string
+	Position int
+	Token    string
+	message  string
+}
+
+func (e *SearchQueryError) Error() string {
+	return e.message
+}
+
+// searchQueryError translates the syntax errors of search queries, leaving
+// other errors as they are
+func searchQueryError(ctx context.Context, err error) error {
+	var queryErr *datastore.SearchQueryError
+	if !errors.As(err, &queryErr) {
+		return err
+	}
+	i18n := i18n.GetI18nFromContext(ctx)
+	message := i18n.T("search." + queryErr.Code)
+	switch queryErr.Code {
+	case "unclosedQuote", "unclosedParenthesis", "missingOperand":
+		message = fmt.Sprintf(message, queryErr.Position+1)
+	default:
+		message = fmt.Sprintf(message, queryErr.Token, queryErr.Position+1)
+	}
+	return &SearchQueryError{Code: queryErr.Code, Position: queryErr.Position, Token: queryErr.Token, message: message}
+}
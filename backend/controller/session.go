@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/datastore"
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StartSession opens a session for a user who just signed in and returns
+// its first pair of tokens
+func (c *Controller) StartSession(ctx context.Context, user *models.User, userAgent, ipAddress string) (*models.AuthTokens, error) {
+	secret, err := newTokenSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(secret),
+		UserAgent:        userAgent,
+		IPAddress:        ipAddress,
+		ExpiresAt:        time.Now().Add(refreshTokenTTL()),
+	}
+	if err := c.ds.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return c.issueTokens(user, session, secret)
+}
+
+// RefreshSession exchanges a refresh token for a new pair of tokens. The
+// refresh token is rotated: presenting the one it replaced again, or the
+// same one twice at once, means it leaked, so the whole session is revoked.
+func (c *Controller) RefreshSession(ctx context.Context, refreshToken, userAgent, ipAddress string) (*models.AuthTokens, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	session, secret, err := c.lookupRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := c.ds.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		return nil, errors.New(i18n.T("session.invalidRefreshToken"))
+	}
+
+	if secret, err = newTokenSecret(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	previous := session.RefreshTokenHash
+	session.RotatedTokenHash = previous
+	session.RefreshTokenHash = hashToken(secret)
+	session.UserAgent = userAgent
+	session.IPAddress = ipAddress
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(refreshTokenTTL())
+	if err := c.ds.UpdateSession(ctx, session, previous); errors.Is(err, datastore.ErrSessionChanged) {
+		// Another refresh with the same token got there first
+		if err := c.revokeSession(ctx, session); err != nil {
+			return nil, err
+		}
+		return nil, errors.New(i18n.T("session.invalidRefreshToken"))
+	} else if err != nil {
+		return nil, err
+	}
+	return c.issueTokens(user, session, secret)
+}
+
+// EndSession signs out of the session the refresh token belongs to
+func (c *Controller) EndSession(ctx context.Context, refreshToken string) error {
+	session, _, err := c.lookupRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	return c.revokeSession(ctx, session)
+}
+
+// CheckSession verifies that the session an access token was issued for is
+// still active, and that the role in the token is still the role of the
+// user. It returns the user.
+func (c *Controller) CheckSession(ctx context.Context, claims *Claims) (*models.User, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return nil, errors.New(i18n.T("jwt.sessionRevoked"))
+	}
+	session, err := c.ds.GetSessionByID(ctx, sessionID)
+	if err != nil || !session.IsActive(time.Now()) || session.UserID.Hex() != claims.UserID {
+		return nil, errors.New(i18n.T("jwt.sessionRevoked"))
+	}
+
+	user, err := c.ds.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		return nil, errors.New(i18n.T("jwt.sessionRevoked"))
+	}
+	if user.Role != claims.Role {
+		return nil, errors.New(i18n.T("jwt.roleChanged"))
+	}
+	return user, nil
+}
+
+// ListSessions returns the active sessions of the user, most recently used
+// first, marking the one the request was made from
+func (c *Controller) ListSessions(ctx context.Context, userID, currentID primitive.ObjectID) ([]*models.Session, error) {
+	sessions, err := c.ds.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := []*models.Session{}
+	for _, session := range sessions {
+		if session.IsActive(now) {
+			session.Current = session.ID == currentID
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// RevokeSession signs the user out of one of their sessions
+func (c *Controller) RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	session, err := c.ds.GetSessionByID(ctx, sessionID)
+	if err != nil || session.UserID != userID || !session.IsActive(time.Now()) {
+		return errors.New(i18n.T("session.notFound"))
+	}
+	return c.revokeSession(ctx, session)
+}
+
+// RevokeOtherSessions signs the user out everywhere but the given session.
+// Pass primitive.NilObjectID to sign them out everywhere.
+func (c *Controller) RevokeOtherSessions(ctx context.Context, userID, currentID primitive.ObjectID) error {
+	return c.ds.RevokeUserSessions(ctx, userID, currentID)
+}
+
+// WatchExpiredSessions deletes expired sessions every interval until ctx is
+// done
+func (c *Controller) WatchExpiredSessions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.ds.DeleteExpiredSessions(ctx, time.Now()); err != nil {
+			log.Printf("ERROR DeleteExpiredSessions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// revokeSession revokes the session, reading it again when its refresh
+// token was rotated in the meantime
+func (c *Controller) revokeSession(ctx context.Context, session *models.Session) error {
+	for {
+		stored, err := c.ds.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			return err
+		}
+		if stored.RevokedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		stored.RevokedAt = &now
+		err = c.ds.UpdateSession(ctx, stored, stored.RefreshTokenHash)
+		if !errors.Is(err, datastore.ErrSessionChanged) {
+			return err
+		}
+	}
+}
+
+// lookupRefreshToken returns the active session a refresh token belongs to,
+// along with the secret part of the token
+func (c *Controller) lookupRefreshToken(ctx context.Context, refreshToken string) (*models.Session, string, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	invalid := errors.New(i18n.T("session.invalidRefreshToken"))
+
+	sessionHex, secret, found := strings.Cut(refreshToken, ".")
+	if !found {
+		return nil, "", invalid
+	}
+	sessionID, err := primitive.ObjectIDFromHex(sessionHex)
+	if err != nil {
+		return nil, "", invalid
+	}
+	session, err := c.ds.GetSessionByID(ctx, sessionID)
+	if err != nil || !session.IsActive(time.Now()) {
+		return nil, "", invalid
+	}
+
+	hash := []byte(hashToken(secret))
+	if subtle.ConstantTimeCompare(hash, []byte(session.RefreshTokenHash)) == 1 {
+		return session, secret, nil
+	}
+	// A refresh token that was already rotated is being replayed. Other
+	// wrong secrets leave the session alone, as its ID is no secret.
+	if session.RotatedTokenHash != "" && subtle.ConstantTimeCompare(hash, []byte(session.RotatedTokenHash)) == 1 {
+		if err := c.revokeSession(ctx, session); err != nil {
+			return nil, "", err
+		}
+	}
+	return nil, "", invalid
+}
+
+func (c *Controller) issueTokens(user *models.User, session *models.Session, secret string) (*models.AuthTokens, error) {
+	accessToken, err := c.generateAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: session.ID.Hex() + "." + secret,
+		ExpiresIn:    int(accessTokenTTL().Seconds()),
+	}, nil
+}
+
+func newTokenSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// hashToken is what gets stored of refresh and API tokens, so that a copy
+// of the database cannot be used to sign in
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
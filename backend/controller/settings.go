@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+)
+
+func (c *Controller) GetSettings(ctx context.Context) (*models.Settings, error) {
+	return c.ds.GetSettings(ctx)
+}
+
+// UpdateSettings applies the fields set in the request
+func (c *Controller) UpdateSettings(ctx context.Context, req *models.UpdateSettingsRequest) (*models.Settings, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if req.AuditRetentionDays != nil && *req.AuditRetentionDays < 0 {
+		return nil, errors.New(i18n.T("settings.invalidAuditRetention"))
+	}
+	if req.TrashRetentionDays != nil && *req.TrashRetentionDays < 0 {
+		return nil, errors.New(i18n.T("settings.invalidTrashRetention"))
+	}
+
+	settings, err := c.ds.GetSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.RequireTwoFactorForStaff != nil {
+		settings.RequireTwoFactorForStaff = *req.RequireTwoFactorForStaff
+	}
+	if req.AuditRetentionDays != nil {
+		settings.AuditRetentionDays = *req.AuditRetentionDays
+	}
+	if req.TrashRetentionDays != nil {
+		settings.TrashRetentionDays = *req.TrashRetentionDays
+	}
+	if err := c.ds.UpdateSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
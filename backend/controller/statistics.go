@@ -4,12 +4,20 @@ import (
 	"context"
 
 	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func (c *Controller) GetStatistics(ctx context.Context) (*models.Statistics, error) {
-	return c.ds.GetStatistics(ctx)
+// GetStatistics returns the collection statistics. The rating statistics are
+// those of the user when ratedBy is set, and of the household otherwise.
+func (c *Controller) GetStatistics(ctx context.Context, ratedBy *primitive.ObjectID) (*models.Statistics, error) {
+	stats, err := c.ds.GetStatistics(ctx, collectionFilter(ctx, nil))
+	if err != nil {
+		return nil, err
+	}
+	return stats, c.fillRatingStatistics(ctx, stats, ratedBy)
 }
 
 func (c *Controller) GetSimplifiedStatistics(ctx context.Context) (*models.SimplifiedStatistics, error) {
-	return c.ds.GetSimplifiedStatistics(ctx)
+	return c.ds.GetSimplifiedStatistics(ctx, collectionFilter(ctx, nil))
 }
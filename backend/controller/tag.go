@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"errors"
+	"time"
 
 	"eylexander/bluraymanager/i18n"
 	"eylexander/bluraymanager/models"
@@ -15,19 +16,48 @@ func (c *Controller) CreateTag(ctx context.Context, tag *models.Tag) error {
 	if tag.Name == "" {
 		return errors.New(i18n.T("tag.nameRequired"))
 	}
-	// Check if tag already exists
-	if _, err := c.ds.GetTagByName(ctx, tag.Name); err == nil {
+	tag.CollectionID, _ = CollectionFromContext(ctx)
+	tag.DeletedAt = nil
+
+	// Check if tag already exists, names stay taken while in the trash
+	if existing, err := c.ds.GetTagByName(ctx, tag.CollectionID, tag.Name); err == nil {
+		if existing.DeletedAt != nil {
+			return errors.New(i18n.T("tag.inTrash"))
+		}
 		return errors.New(i18n.T("tag.duplicateTagName"))
 	}
-	return c.ds.CreateTag(ctx, tag)
+	if err := c.ds.CreateTag(ctx, tag); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditCreate, nil, tag)
+	return nil
 }
 
 func (c *Controller) GetTagByID(ctx context.Context, id primitive.ObjectID) (*models.Tag, error) {
-	return c.ds.GetTagByID(ctx, id)
+	i18n := i18n.GetI18nFromContext(ctx)
+	tag, err := c.ds.GetTagByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Tags of other collections and those in the trash do not exist for
+	// the caller
+	if !inActiveCollection(ctx, tag.CollectionID) || tag.DeletedAt != nil {
+		return nil, errors.New(i18n.T("tag.notFound"))
+	}
+	return tag, nil
 }
 
 func (c *Controller) GetTagByName(ctx context.Context, name string) (*models.Tag, error) {
-	return c.ds.GetTagByName(ctx, name)
+	i18n := i18n.GetI18nFromContext(ctx)
+	collectionID, _ := CollectionFromContext(ctx)
+	tag, err := c.ds.GetTagByName(ctx, collectionID, name)
+	if err != nil {
+		return nil, err
+	}
+	if tag.DeletedAt != nil {
+		return nil, errors.New(i18n.T("tag.notFound"))
+	}
+	return tag, nil
 }
 
 func (c *Controller) UpdateTag(ctx context.Context, tag *models.Tag) error {
@@ -35,13 +65,50 @@ func (c *Controller) UpdateTag(ctx context.Context, tag *models.Tag) error {
 	if tag.Name == "" {
 		return errors.New(i18n.T("tag.nameRequired"))
 	}
-	return c.ds.UpdateTag(ctx, tag)
+	existing, err := c.GetTagByID(ctx, tag.ID)
+	if err != nil {
+		return err
+	}
+	tag.CollectionID = existing.CollectionID
+	if err := c.ds.UpdateTag(ctx, tag); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditUpdate, existing, tag)
+	return nil
 }
 
+// DeleteTag moves a tag to the trash, from where it can be restored until it
+// is purged
 func (c *Controller) DeleteTag(ctx context.Context, id primitive.ObjectID) error {
-	return c.ds.DeleteTag(ctx, id)
+	existing, err := c.GetTagByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	deletedAt := time.Now()
+	if err := c.ds.SetTagDeletedAt(ctx, id, &deletedAt); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditDelete, existing, nil)
+	return nil
 }
 
+// ListTags returns the tags of the active collection, leaving out the trash
 func (c *Controller) ListTags(ctx context.Context) ([]*models.Tag, error) {
-	return c.ds.ListTags(ctx)
+	tags, err := c.collectionTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := make([]*models.Tag, 0, len(tags))
+	for _, tag := range tags {
+		if tag.DeletedAt == nil {
+			live = append(live, tag)
+		}
+	}
+	return live, nil
+}
+
+// collectionTags returns every tag of the active collection, in the trash
+// or not
+func (c *Controller) collectionTags(ctx context.Context) ([]*models.Tag, error) {
+	return c.ds.ListTags(ctx, activeCollection(ctx))
 }
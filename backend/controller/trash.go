@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListTrashedBlurays returns the blurays in the trash of the active
+// collection, most recently deleted first
+func (c *Controller) ListTrashedBlurays(ctx context.Context) ([]*models.Bluray, error) {
+	filter := models.BlurayFilter{CollectionID: activeCollection(ctx), Trashed: true}
+	blurays, _, err := c.ds.PageBlurays(ctx, filter, newestPage(0))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(blurays, func(i, j int) bool {
+		return blurays[i].DeletedAt.After(*blurays[j].DeletedAt)
+	})
+	return blurays, nil
+}
+
+// ListTrashedTags returns the tags in the trash of the active collection,
+// most recently deleted first
+func (c *Controller) ListTrashedTags(ctx context.Context) ([]*models.Tag, error) {
+	tags, err := c.collectionTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trashed := []*models.Tag{}
+	for _, tag := range tags {
+		if tag.DeletedAt != nil {
+			trashed = append(trashed, tag)
+		}
+	}
+	sort.SliceStable(trashed, func(i, j int) bool {
+		return trashed[i].DeletedAt.After(*trashed[j].DeletedAt)
+	})
+	return trashed, nil
+}
+
+// GetTrashedBluray returns a bluray in the trash of the active collection
+func (c *Controller) GetTrashedBluray(ctx context.Context, id primitive.ObjectID) (*models.Bluray, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	bluray, err := c.ds.GetBlurayByID(ctx, id)
+	if err != nil || bluray.DeletedAt == nil || !inActiveCollection(ctx, bluray.CollectionID) {
+		return nil, errors.New(i18n.T("bluray.notFound"))
+	}
+	return bluray, nil
+}
+
+// GetTrashedTag returns a tag in the trash of the active collection
+func (c *Controller) GetTrashedTag(ctx context.Context, id primitive.ObjectID) (*models.Tag, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	tag, err := c.ds.GetTagByID(ctx, id)
+	if err != nil || tag.DeletedAt == nil || !inActiveCollection(ctx, tag.CollectionID) {
+		return nil, errors.New(i18n.T("tag.notFound"))
+	}
+	return tag, nil
+}
+
+// RestoreBluray brings a bluray back from the trash, unless a bluray with
+// the same TMDB ID was added in the meantime
+func (c *Controller) RestoreBluray(ctx context.Context, id primitive.ObjectID) (*models.Bluray, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	trashed, err := c.GetTrashedBluray(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if trashed.TMDBID != "" {
+		filter := collectionFilter(ctx, map[string]interface{}{"tmdb_id": trashed.TMDBID})
+		existing, _, err := c.ds.PageBlurays(ctx, filter, newestPage(1))
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > 0 {
+			return nil, errors.New(i18n.T("bluray.restoreDuplicateTMDBID"))
+		}
+	}
+
+	if err := c.ds.SetBlurayDeletedAt(ctx, id, nil); err != nil {
+		return nil, err
+	}
+	restored := *trashed
+	restored.DeletedAt = nil
+	c.audit(ctx, models.AuditRestore, trashed, &restored)
+	return &restored, c.annotateBlurays(ctx, &restored)
+}
+
+// PurgeBluray removes a bluray in the trash for good
+func (c *Controller) PurgeBluray(ctx context.Context, id primitive.ObjectID) error {
+	bluray, err := c.GetTrashedBluray(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.purgeBluray(ctx, bluray)
+}
+
+// purgeBluray deletes a bluray along with its loans, watch events, revisions
+// and ratings
+func (c *Controller) purgeBluray(ctx context.Context, bluray *models.Bluray) error {
+	if err := c.ds.DeleteBluray(ctx, bluray.ID); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditPurge, bluray, nil)
+
+	// Remove the loan history along with the disc
+	loans, err := c.ds.ListBlurayLoans(ctx, bluray.ID)
+	if err != nil {
+		return err
+	}
+	for _, loan := range loans {
+		if err := c.ds.DeleteLoan(ctx, loan.ID); err != nil {
+			return err
+		}
+	}
+	if err := c.ds.DeleteBlurayWatchEvents(ctx, bluray.ID); err != nil {
+		return err
+	}
+	if err := c.ds.DeleteBlurayRevisions(ctx, bluray.ID); err != nil {
+		return err
+	}
+	return c.ds.DeleteBlurayRatings(ctx, bluray.ID)
+}
+
+// RestoreTag brings a tag back from the trash. Tag names stay taken while
+// in the trash, so it cannot clash with a tag created since.
+func (c *Controller) RestoreTag(ctx context.Context, id primitive.ObjectID) (*models.Tag, error) {
+	trashed, err := c.GetTrashedTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ds.SetTagDeletedAt(ctx, id, nil); err != nil {
+		return nil, err
+	}
+	restored := *trashed
+	restored.DeletedAt = nil
+	c.audit(ctx, models.AuditRestore, trashed, &restored)
+	return &restored, nil
+}
+
+// PurgeTag removes a tag in the trash for good
+func (c *Controller) PurgeTag(ctx context.Context, id primitive.ObjectID) error {
+	tag, err := c.GetTrashedTag(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.purgeTag(ctx, tag)
+}
+
+func (c *Controller) purgeTag(ctx context.Context, tag *models.Tag) error {
+	if err := c.ds.RemoveTagFromBlurays(ctx, tag.ID); err != nil {
+		return err
+	}
+	if err := c.ds.DeleteTag(ctx, tag.ID); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditPurge, tag, nil)
+	return nil
+}
+
+// WatchTrash purges the blurays and tags that stayed in the trash longer
+// than the retention period of the settings every interval until ctx is
+// done. A period of zero keeps them until they are purged by hand.
+func (c *Controller) WatchTrash(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.PurgeExpiredTrash(ctx, time.Now()); err != nil {
+			log.Printf("ERROR PurgeExpiredTrash: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpiredTrash purges the blurays and tags of every collection that are
+// past the retention period at the given time
+func (c *Controller) PurgeExpiredTrash(ctx context.Context, now time.Time) error {
+	settings, err := c.ds.GetSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if settings.TrashRetentionDays <= 0 {
+		return nil
+	}
+	cutoff := now.AddDate(0, 0, -settings.TrashRetentionDays)
+
+	blurays, _, err := c.ds.PageBlurays(ctx, models.BlurayFilter{Trashed: true}, newestPage(0))
+	if err != nil {
+		return err
+	}
+	for _, bluray := range blurays {
+		if bluray.DeletedAt.Before(cutoff) {
+			if err := c.purgeBluray(ctx, bluray); err != nil {
+				return err
+			}
+		}
+	}
+
+	tags, err := c.ds.ListTags(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if tag.DeletedAt != nil && tag.DeletedAt.Before(cutoff) {
+			if err := c.purgeTag(ctx, tag); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets at once
+	recoveryCodeCount = 10
+	// twoFactorChallengeTTL is how long the second step of a login can wait
+	twoFactorChallengeTTL = 5 * time.Minute
+	// twoFactorAudience marks the tokens that only prove the password was
+	// right, so they are never mistaken for access tokens
+	twoFactorAudience = "two-factor"
+)
+
+// TwoFactorStatus reports whether the user has two-factor authentication and
+// whether they must
+func (c *Controller) TwoFactorStatus(ctx context.Context, user *models.User) (*models.TwoFactorStatus, error) {
+	required, err := c.TwoFactorRequired(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorStatus{
+		Enabled:           user.TwoFactor.Enabled,
+		Required:          required,
+		RecoveryCodesLeft: len(user.TwoFactor.RecoveryCodes),
+	}, nil
+}
+
+// TwoFactorRequired reports whether the settings make the role use
+// two-factor authentication
+func (c *Controller) TwoFactorRequired(ctx context.Context, name models.UserRole) (bool, error) {
+	role, err := c.GetRole(ctx, name)
+	if err != nil || !role.IsStaff() {
+		// A user whose role was removed holds no permission
+		return false, nil
+	}
+	settings, err := c.ds.GetSettings(ctx)
+	if err != nil {
+		return false, err
+	}
+	return settings.RequireTwoFactorForStaff, nil
+}
+
+// EnrollTwoFactor gives the user a new TOTP secret. Two-factor
+// authentication stays off until ConfirmTwoFactor sees a code from it.
+func (c *Controller) EnrollTwoFactor(ctx context.Context, user *models.User) (*models.TwoFactorEnrolment, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if user.TwoFactor.Enabled {
+		return nil, errors.New(i18n.T("twoFactor.alreadyEnabled"))
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactor = models.TwoFactor{Secret: secret}
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorEnrolment{Secret: secret, URI: totpURI(user.Email, secret)}, nil
+}
+
+// ConfirmTwoFactor turns two-factor authentication on once the user proved
+// their app is set up, and returns their recovery codes
+func (c *Controller) ConfirmTwoFactor(ctx context.Context, user *models.User, code string) ([]string, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if user.TwoFactor.Enabled {
+		return nil, errors.New(i18n.T("twoFactor.alreadyEnabled"))
+	}
+	if user.TwoFactor.Secret == "" {
+		return nil, errors.New(i18n.T("twoFactor.notEnrolled"))
+	}
+
+	step, ok := matchTOTP(user.TwoFactor.Secret, code, time.Now(), 0)
+	if !ok {
+		return nil, errors.New(i18n.T("twoFactor.invalidCode"))
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.TwoFactor.Enabled = true
+	user.TwoFactor.EnabledAt = &now
+	user.TwoFactor.LastUsedStep = step
+	user.TwoFactor.RecoveryCodes = hashes
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off, unless the settings
+// require it for the role of the user
+func (c *Controller) DisableTwoFactor(ctx context.Context, user *models.User, password, code string) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if !user.TwoFactor.Enabled {
+		return errors.New(i18n.T("twoFactor.notEnabled"))
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return errors.New(i18n.T("api.invalidCurrentPassword"))
+	}
+	required, err := c.TwoFactorRequired(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.New(i18n.T("twoFactor.requiredByPolicy"))
+	}
+	if err := c.verifySecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+
+	user.TwoFactor = models.TwoFactor{}
+	return c.ds.UpdateUser(ctx, user)
+}
+
+// ResetTwoFactor turns two-factor authentication off for a user who lost
+// their authenticator app and recovery codes
+func (c *Controller) ResetTwoFactor(ctx context.Context, user *models.User) error {
+	before := *user
+	user.TwoFactor = models.TwoFactor{}
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditUpdate, &before, user)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user. It needs a
+// code from the authenticator app.
+func (c *Controller) RegenerateRecoveryCodes(ctx context.Context, user *models.User, code string) ([]string, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if !user.TwoFactor.Enabled {
+		return nil, errors.New(i18n.T("twoFactor.notEnabled"))
+	}
+	step, ok := matchTOTP(user.TwoFactor.Secret, code, time.Now(), user.TwoFactor.LastUsedStep)
+	if !ok {
+		return nil, errors.New(i18n.T("twoFactor.invalidCode"))
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactor.LastUsedStep = step
+	user.TwoFactor.RecoveryCodes = hashes
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// NewTwoFactorChallenge returns the token that carries a login from the
+// password step to the code step
+func (c *Controller) NewTwoFactorChallenge(user *models.User) (string, error) {
+	now := time.Now()
+	claims := &jwt.RegisteredClaims{
+		Subject:   user.ID.Hex(),
+		Audience:  jwt.ClaimStrings{twoFactorAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(twoFactorChallengeTTL)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret())
+}
+
+// CompleteTwoFactorLogin checks the code of the second login step, either
+// from the authenticator app or a recovery code, and returns the user
+func (c *Controller) CompleteTwoFactorLogin(ctx context.Context, challengeToken, code string) (*models.User, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	expired := errors.New(i18n.T("twoFactor.invalidChallenge"))
+
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(challengeToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret(), nil
+	}, jwt.WithAudience(twoFactorAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, expired
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return nil, expired
+	}
+	user, err := c.ds.GetUserByID(ctx, userID)
+	if err != nil || !user.TwoFactor.Enabled {
+		return nil, expired
+	}
+
+	if user.Lockout.IsLocked(time.Now()) {
+		return nil, lockedError(ctx, user)
+	}
+	if err := c.verifySecondFactor(ctx, user, code); err != nil {
+		if err := c.recordFailedLogin(ctx, user); err != nil {
+			return nil, err
+		}
+		// The password was right, so the lock can be told
+		if user.Lockout.IsLocked(time.Now()) {
+			return nil, lockedError(ctx, user)
+		}
+		return nil, err
+	}
+	if err := c.recordSuccessfulLogin(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// verifySecondFactor accepts a code from the authenticator app or an unused
+// recovery code, and records its use
+func (c *Controller) verifySecondFactor(ctx context.Context, user *models.User, code string) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	if step, ok := matchTOTP(user.TwoFactor.Secret, code, time.Now(), user.TwoFactor.LastUsedStep); ok {
+		user.TwoFactor.LastUsedStep = step
+		return c.ds.UpdateUser(ctx, user)
+	}
+
+	hash := hashToken(normalizeRecoveryCode(code))
+	for i, recoveryCode := range user.TwoFactor.RecoveryCodes {
+		if recoveryCode == hash {
+			user.TwoFactor.RecoveryCodes = append(user.TwoFactor.RecoveryCodes[:i], user.TwoFactor.RecoveryCodes[i+1:]...)
+			return c.ds.UpdateUser(ctx, user)
+		}
+	}
+	return errors.New(i18n.T("twoFactor.invalidCode"))
+}
+
+// newRecoveryCodes returns fresh recovery codes, formatted like
+// "3f9a1-c07e2", and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for len(codes) < recoveryCodeCount {
+		secret, err := newTokenSecret()
+		if err != nil {
+			return nil, nil, err
+		}
+		code := secret[:5] + "-" + secret[5:10]
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"eylexander/bluraymanager/i18n"
 	"eylexander/bluraymanager/models"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
@@ -19,6 +20,9 @@ func (c *Controller) RegisterUser(ctx context.Context, username, email, password
 	if _, err := c.ds.GetUserByUsername(ctx, username); err == nil {
 		return nil, errors.New(i18n.T("user.usernameAlreadyTaken"))
 	}
+	if _, err := c.GetRole(ctx, role); err != nil {
+		return nil, err
+	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -36,6 +40,7 @@ func (c *Controller) RegisterUser(ctx context.Context, username, email, password
 	if err := c.ds.CreateUser(ctx, user); err != nil {
 		return nil, err
 	}
+	c.audit(ctx, models.AuditCreate, nil, user)
 
 	return user, nil
 }
@@ -54,11 +59,29 @@ func (c *Controller) Login(ctx context.Context, identifier, password string) (*m
 		}
 	}
 
-	// Check password
+	// Check password. Locked accounts only say so once the password checks
+	// out, so that they look like unknown ones to someone guessing, and
+	// their failures do not extend the lock.
+	locked := user.Lockout.IsLocked(time.Now())
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		if !locked {
+			if err := c.recordFailedLogin(ctx, user); err != nil {
+				return nil, err
+			}
+		}
 		return nil, errors.New(i18n.T("user.invalidCredentials"))
 	}
+	if locked {
+		return nil, lockedError(ctx, user)
+	}
 
+	// With two-factor authentication, failed codes keep counting until the
+	// second step succeeds
+	if !user.TwoFactor.Enabled {
+		if err := c.recordSuccessfulLogin(ctx, user); err != nil {
+			return nil, err
+		}
+	}
 	return user, nil
 }
 
@@ -67,7 +90,20 @@ func (c *Controller) GetUserByID(ctx context.Context, id primitive.ObjectID) (*m
 }
 
 func (c *Controller) UpdateUser(ctx context.Context, user *models.User) error {
-	return c.ds.UpdateUser(ctx, user)
+	if _, err := c.GetRole(ctx, user.Role); err != nil {
+		return err
+	}
+	// The caller changed user in place, so the stored version is the one
+	// from before
+	before, err := c.ds.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditUpdate, before, user)
+	return nil
 }
 
 func (c *Controller) VerifyPassword(user *models.User, password string) error {
@@ -80,7 +116,11 @@ func (c *Controller) UpdatePassword(ctx context.Context, user *models.User, newP
 		return err
 	}
 	user.PasswordHash = string(hash)
-	return c.ds.UpdateUser(ctx, user)
+	if err := c.ds.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	c.auditSecret(ctx, user, "password")
+	return nil
 }
 
 func (c *Controller) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
@@ -88,9 +128,32 @@ func (c *Controller) GetUserByUsername(ctx context.Context, username string) (*m
 }
 
 func (c *Controller) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
-	return c.ds.DeleteUser(ctx, id)
+	existing, err := c.ds.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := c.ds.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	c.audit(ctx, models.AuditDelete, existing, nil)
+	if err := c.ds.RevokeUserSessions(ctx, id, primitive.NilObjectID); err != nil {
+		return err
+	}
+	if err := c.ds.DeleteUserAPITokens(ctx, id); err != nil {
+		return err
+	}
+	if err := c.ds.DeleteUserSavedSearches(ctx, id); err != nil {
+		return err
+	}
+	return c.removeUserMemberships(ctx, id)
 }
 
-func (c *Controller) ListUsers(ctx context.Context, skip, limit int) ([]*models.User, error) {
-	return c.ds.ListUsers(ctx, skip, limit)
+// ListUsers returns the page of the options of every user, in the order they
+// registered
+func (c *Controller) ListUsers(ctx context.Context, opts *models.QueryOptions) ([]*models.User, *models.PageInfo, error) {
+	return offsetPage(ctx, opts, func(skip, limit int) ([]*models.User, error) {
+		return c.ds.ListUsers(ctx, skip, limit)
+	}, func() (int, error) {
+		return c.ds.CountUsers(ctx)
+	})
 }
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// watchDateTolerance absorbs the clock difference between the client that
+// logs a viewing and the server
+const watchDateTolerance = 5 * time.Minute
+
+// LogWatchEvent records that the user watched a bluray, or one of its
+// seasons or episodes. The time spent is taken from the runtime known at
+// that moment.
+func (c *Controller) LogWatchEvent(ctx context.Context, userID primitive.ObjectID, req *models.CreateWatchEventRequest) (*models.WatchEvent, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	blurayID, err := primitive.ObjectIDFromHex(req.BlurayID)
+	if err != nil {
+		return nil, errors.New(i18n.T("watch.blurayNotFound"))
+	}
+	bluray, err := c.getBluray(ctx, blurayID)
+	if err != nil {
+		return nil, errors.New(i18n.T("watch.blurayNotFound"))
+	}
+
+	event := &models.WatchEvent{
+		UserID:        userID,
+		BlurayID:      bluray.ID,
+		CollectionID:  bluray.CollectionID,
+		SeasonNumber:  req.SeasonNumber,
+		EpisodeNumber: req.EpisodeNumber,
+		Rating:        req.Rating,
+		Notes:         req.Notes,
+	}
+	if req.WatchedAt != nil {
+		event.WatchedAt = *req.WatchedAt
+	} else {
+		event.WatchedAt = time.Now()
+	}
+
+	runtime, err := watchedRuntime(ctx, bluray, event.SeasonNumber, event.EpisodeNumber)
+	if err != nil {
+		return nil, err
+	}
+	event.RuntimeMinutes = runtime
+
+	if err := validateWatchEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	if err := c.ds.CreateWatchEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	event.BlurayTitle = bluray.Title
+	return event, nil
+}
+
+func (c *Controller) GetWatchEventByID(ctx context.Context, id primitive.ObjectID) (*models.WatchEvent, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	event, err := c.ds.GetWatchEventByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Viewings of other collections do not exist for the caller
+	if !inActiveCollection(ctx, event.CollectionID) {
+		return nil, errors.New(i18n.T("watch.notFound"))
+	}
+	return event, nil
+}
+
+func (c *Controller) UpdateWatchEvent(ctx context.Context, event *models.WatchEvent) error {
+	if err := validateWatchEvent(ctx, event); err != nil {
+		return err
+	}
+	return c.ds.UpdateWatchEvent(ctx, event)
+}
+
+func (c *Controller) DeleteWatchEvent(ctx context.Context, id primitive.ObjectID) error {
+	return c.ds.DeleteWatchEvent(ctx, id)
+}
+
+// ListWatchEvents returns the page of the options of the history of the user
+// in the active collection, most recent viewing first, optionally restricted
+// to one bluray
+func (c *Controller) ListWatchEvents(ctx context.Context, userID primitive.ObjectID, blurayID *primitive.ObjectID, opts *models.QueryOptions) ([]*models.WatchEvent, *models.PageInfo, error) {
+	events, page, err := offsetPage(ctx, opts, func(skip, limit int) ([]*models.WatchEvent, error) {
+		return c.ds.ListWatchEvents(ctx, userID, activeCollection(ctx), blurayID, skip, limit)
+	}, func() (int, error) {
+		return c.ds.CountWatchEvents(ctx, userID, activeCollection(ctx), blurayID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	titles := make(map[primitive.ObjectID]string)
+	for _, event := range events {
+		title, ok := titles[event.BlurayID]
+		if !ok {
+			if bluray, err := c.ds.GetBlurayByID(ctx, event.BlurayID); err == nil {
+				title = bluray.Title
+			}
+			titles[event.BlurayID] = title
+		}
+		event.BlurayTitle = title
+	}
+	return events, page, nil
+}
+
+// FilterWatched restricts a bluray listing to the discs the user has
+// watched at least once, or to those they never watched
+func (c *Controller) FilterWatched(ctx context.Context, filters map[string]interface{}, userID primitive.ObjectID, watched bool) error {
+	ids, err := c.ds.ListWatchedBlurayIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if ids == nil {
+		ids = []primitive.ObjectID{}
+	}
+
+	if watched {
+		filters["_id"] = bson.M{"$in": ids}
+	} else {
+		filters["_id"] = bson.M{"$nin": ids}
+	}
+	return nil
+}
+
+// GetWatchStatistics summarizes the history of the user in the active
+// collection: time spent per month and the discs they never watched
+func (c *Controller) GetWatchStatistics(ctx context.Context, userID primitive.ObjectID) (*models.WatchStatistics, error) {
+	events, err := c.ds.ListWatchEvents(ctx, userID, activeCollection(ctx), nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.WatchStatistics{
+		NeverWatched:        []models.BlurayStats{},
+		HoursWatchedByMonth: []models.MonthlyWatch{},
+	}
+
+	minutesByMonth := make(map[string]int)
+	watched := make(map[primitive.ObjectID]bool)
+	for _, event := range events {
+		stats.TotalEvents++
+		stats.TotalMinutes += event.RuntimeMinutes
+		minutesByMonth[event.WatchedAt.Format("2006-01")] += event.RuntimeMinutes
+		watched[event.BlurayID] = true
+	}
+	stats.WatchedBlurays = len(watched)
+
+	for month, minutes := range minutesByMonth {
+		stats.HoursWatchedByMonth = append(stats.HoursWatchedByMonth, models.MonthlyWatch{
+			Month:   month,
+			Minutes: minutes,
+			Hours:   float64(minutes) / 60,
+		})
+	}
+	sort.Slice(stats.HoursWatchedByMonth, func(i, j int) bool {
+		return stats.HoursWatchedByMonth[i].Month < stats.HoursWatchedByMonth[j].Month
+	})
+
+	filters := make(map[string]interface{})
+	if err := c.FilterWatched(ctx, filters, userID, false); err != nil {
+		return nil, err
+	}
+	neverWatched, _, err := c.ds.PageSimplifiedBlurays(ctx, collectionFilter(ctx, filters), newestPage(0))
+	if err != nil {
+		return nil, err
+	}
+	for _, bluray := range neverWatched {
+		stats.NeverWatched = append(stats.NeverWatched, models.BlurayStats{
+			ID:          bluray.ID.Hex(),
+			Title:       bluray.Title,
+			Type:        string(bluray.Type),
+			ReleaseYear: bluray.ReleaseYear,
+		})
+	}
+	stats.NeverWatchedCount = len(stats.NeverWatched)
+
+	return stats, nil
+}
+
+// watchedRuntime returns the minutes spent watching the episode, the season
+// or the whole bluray, and checks that the season and episode exist
+func watchedRuntime(ctx context.Context, bluray *models.Bluray, seasonNumber, episodeNumber int) (int, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	if seasonNumber == 0 {
+		if episodeNumber != 0 {
+			return 0, errors.New(i18n.T("watch.episodeWithoutSeason"))
+		}
+		runtime := bluray.Runtime
+		for i := range bluray.Seasons {
+			runtime += bluray.Seasons[i].RuntimeMinutes()
+		}
+		return runtime, nil
+	}
+
+	for i := range bluray.Seasons {
+		season := &bluray.Seasons[i]
+		if season.Number != seasonNumber {
+			continue
+		}
+		if episodeNumber == 0 {
+			return season.RuntimeMinutes(), nil
+		}
+		for _, episode := range season.Episodes {
+			if episode.Number == episodeNumber {
+				return episode.Runtime, nil
+			}
+		}
+		// Episodes beyond a known count may simply not be listed
+		if len(season.Episodes) == 0 && episodeNumber > 0 && episodeNumber <= season.EpisodeCount {
+			return 0, nil
+		}
+		return 0, errors.New(i18n.T("watch.episodeNotFound"))
+	}
+	return 0, errors.New(i18n.T("watch.seasonNotFound"))
+}
+
+func validateWatchEvent(ctx context.Context, event *models.WatchEvent) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	if event.Rating < 0 || event.Rating > 10 {
+		return errors.New(i18n.T("watch.invalidRating"))
+	}
+	if event.WatchedAt.After(time.Now().Add(watchDateTolerance)) {
+		return errors.New(i18n.T("watch.futureDate"))
+	}
+	event.Notes = strings.TrimSpace(event.Notes)
+	return nil
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/i18n"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (c *Controller) CreateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	if item.Type == "" {
+		item.Type = models.MediaTypeMovie
+	}
+	if item.Priority == 0 {
+		item.Priority = models.WishlistPriorityDefault
+	}
+	if err := validateWishlistItem(ctx, item); err != nil {
+		return err
+	}
+
+	item.CollectionID, _ = CollectionFromContext(ctx)
+	item.AcquiredAt = nil
+	item.BlurayID = nil
+	return c.ds.CreateWishlistItem(ctx, item)
+}
+
+func (c *Controller) GetWishlistItemByID(ctx context.Context, id primitive.ObjectID) (*models.WishlistItem, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	item, err := c.ds.GetWishlistItemByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Items of other collections do not exist for the caller
+	if !inActiveCollection(ctx, item.CollectionID) {
+		return nil, errors.New(i18n.T("wishlist.notFound"))
+	}
+	return item, nil
+}
+
+func (c *Controller) UpdateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	if err := validateWishlistItem(ctx, item); err != nil {
+		return err
+	}
+	return c.ds.UpdateWishlistItem(ctx, item)
+}
+
+func (c *Controller) DeleteWishlistItem(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := c.GetWishlistItemByID(ctx, id); err != nil {
+		return err
+	}
+	return c.ds.DeleteWishlistItem(ctx, id)
+}
+
+// ListWishlistItems returns the items of the active collection still wanted,
+// most wanted first, or the acquired ones, most recently bought first
+func (c *Controller) ListWishlistItems(ctx context.Context, acquired bool) ([]*models.WishlistItem, error) {
+	return c.ds.ListWishlistItems(ctx, activeCollection(ctx), acquired)
+}
+
+// AcquireWishlistItem adds the bought copy to the collection: to the bluray
+// with the same TMDB ID when there is one, or to a new bluray made from the
+// item otherwise. The item is kept, linked to the bluray, as history.
+func (c *Controller) AcquireWishlistItem(ctx context.Context, item *models.WishlistItem, req *models.AcquireWishlistItemRequest, addedBy primitive.ObjectID) (*models.Bluray, error) {
+	i18n := i18n.GetI18nFromContext(ctx)
+	if item.IsAcquired() {
+		return nil, errors.New(i18n.T("wishlist.alreadyAcquired"))
+	}
+
+	cp := models.Copy{
+		Format:        req.Format,
+		Edition:       req.Edition,
+		PurchasePrice: req.PurchasePrice,
+		Condition:     req.Condition,
+		Notes:         req.Notes,
+	}
+	if cp.Format == "" {
+		cp.Format = item.DesiredFormat
+	}
+	if cp.Edition == "" {
+		cp.Edition = item.DesiredEdition
+	}
+	if req.PurchaseDate != nil {
+		cp.PurchaseDate = *req.PurchaseDate
+	} else {
+		cp.PurchaseDate = time.Now()
+	}
+
+	var bluray *models.Bluray
+	if item.TMDBID != "" {
+		existing, _, err := c.ds.PageBlurays(ctx, collectionFilter(ctx, map[string]interface{}{"tmdb_id": item.TMDBID}), newestPage(1))
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > 0 {
+			if bluray, err = c.AddCopy(ctx, existing[0].ID, &cp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if bluray == nil {
+		bluray = &models.Bluray{
+			Title:         item.Title,
+			Type:          item.Type,
+			ReleaseYear:   item.ReleaseYear,
+			Director:      item.Director,
+			Description:   item.Description,
+			Genre:         item.Genre,
+			CoverImageURL: item.CoverImageURL,
+			BackdropURL:   item.BackdropURL,
+			TMDBID:        item.TMDBID,
+			Copies:        []models.Copy{cp},
+			AddedBy:       addedBy,
+		}
+		if err := c.CreateBluray(ctx, bluray); err != nil {
+			return nil, err
+		}
+	}
+
+	acquiredAt := time.Now()
+	item.AcquiredAt = &acquiredAt
+	item.BlurayID = &bluray.ID
+	if err := c.ds.UpdateWishlistItem(ctx, item); err != nil {
+		return nil, err
+	}
+	return bluray, nil
+}
+
+// validateWishlistItem checks the item and brings its desired format to its
+// canonical form
+func validateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	i18n := i18n.GetI18nFromContext(ctx)
+
+	item.Title = strings.TrimSpace(item.Title)
+	if item.Title == "" {
+		return errors.New(i18n.T("wishlist.titleRequired"))
+	}
+	if item.Type != models.MediaTypeMovie && item.Type != models.MediaTypeSeries {
+		return errors.New(i18n.T("wishlist.invalidType"))
+	}
+	if item.DesiredFormat != "" {
+		format, ok := models.ParseReleaseFormat(string(item.DesiredFormat))
+		if !ok {
+			return errors.New(i18n.T("copy.invalidFormat"))
+		}
+		item.DesiredFormat = format
+	}
+	if item.MaxPrice < 0 {
+		return errors.New(i18n.T("wishlist.invalidMaxPrice"))
+	}
+	if item.Priority < models.WishlistPriorityMin || item.Priority > models.WishlistPriorityMax {
+		return errors.New(i18n.T("wishlist.invalidPriority"))
+	}
+	item.DesiredEdition = strings.TrimSpace(item.DesiredEdition)
+	return nil
+}
@@ -2,13 +2,19 @@ package datastore
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"eylexander/bluraymanager/models"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrSessionChanged is returned by UpdateSession when the refresh token of
+// the session was rotated, or the session deleted, since it was read
+var ErrSessionChanged = errors.New("session changed since it was read")
+
 // Datastore defines the interface for all database operations
 type Datastore interface {
 	// User operations
@@ -16,38 +22,163 @@ type Datastore interface {
 	GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByExternalIdentity(ctx context.Context, identity models.ExternalIdentity) (*models.User, error)
 	UpdateUser(ctx context.Context, user *models.User) error
 	DeleteUser(ctx context.Context, id primitive.ObjectID) error
 	ListUsers(ctx context.Context, skip, limit int) ([]*models.User, error)
+	CountUsers(ctx context.Context) (int, error)
 	EnsureGuestUser(ctx context.Context) (bool, error)
 
+	// Role operations
+	CreateRole(ctx context.Context, role *models.Role) error
+	GetRoleByName(ctx context.Context, name models.UserRole) (*models.Role, error)
+	UpdateRole(ctx context.Context, role *models.Role) error
+	DeleteRole(ctx context.Context, name models.UserRole) error
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	EnsureDefaultRoles(ctx context.Context) error
+
 	// Bluray operations
 	CreateBluray(ctx context.Context, bluray *models.Bluray) error
 	GetBlurayByID(ctx context.Context, id primitive.ObjectID) (*models.Bluray, error)
 	UpdateBluray(ctx context.Context, bluray *models.Bluray) error
 	DeleteBluray(ctx context.Context, id primitive.ObjectID) error
-	ListBlurays(ctx context.Context, filters map[string]interface{}, skip, limit int) ([]*models.Bluray, error)
-	SearchBlurays(ctx context.Context, query string, skip, limit int) ([]*models.Bluray, error)
-	ListSimplifiedBlurays(ctx context.Context, filters map[string]interface{}, skip, limit int) ([]*models.SimplifiedBluray, error)
+	// SetBlurayDeletedAt moves a bluray to the trash, or out of it with nil
+	SetBlurayDeletedAt(ctx context.Context, id primitive.ObjectID, deletedAt *time.Time) error
+	// PageBlurays, PageSearchBlurays and PageSimplifiedBlurays return a page
+	// of the matching blurays sorted by a stored field, with how many match
+	// in all. Searches with free words score the blurays of the page.
+	PageBlurays(ctx context.Context, filter models.BlurayFilter, page *BlurayPage) ([]*models.Bluray, int, error)
+	PageSearchBlurays(ctx context.Context, query string, filter models.BlurayFilter, page *BlurayPage) ([]*models.Bluray, int, error)
+	PageSimplifiedBlurays(ctx context.Context, filter models.BlurayFilter, page *BlurayPage) ([]*models.SimplifiedBluray, int, error)
+
+	// Bluray revision operations. CreateBlurayRevision numbers the revision
+	// after the last one of its bluray; revisions are listed newest first.
+	CreateBlurayRevision(ctx context.Context, revision *models.BlurayRevision) error
+	GetBlurayRevision(ctx context.Context, blurayID primitive.ObjectID, number int) (*models.BlurayRevision, error)
+	ListBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID, skip, limit int) ([]*models.BlurayRevision, error)
+	CountBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID) (int, error)
+	DeleteBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID) error
+
+	// Collection operations
+	CreateCollection(ctx context.Context, collection *models.Collection) error
+	GetCollectionByID(ctx context.Context, id primitive.ObjectID) (*models.Collection, error)
+	EnsureDefaultCollection(ctx context.Context) (*models.Collection, error)
+	UpdateCollection(ctx context.Context, collection *models.Collection) error
+	DeleteCollection(ctx context.Context, id primitive.ObjectID) error
+	ListCollections(ctx context.Context, memberID *primitive.ObjectID) ([]*models.Collection, error)
 
 	// Tag operations
 	CreateTag(ctx context.Context, tag *models.Tag) error
 	GetTagByID(ctx context.Context, id primitive.ObjectID) (*models.Tag, error)
-	GetTagByName(ctx context.Context, name string) (*models.Tag, error)
+	GetTagByName(ctx context.Context, collectionID primitive.ObjectID, name string) (*models.Tag, error)
 	UpdateTag(ctx context.Context, tag *models.Tag) error
 	DeleteTag(ctx context.Context, id primitive.ObjectID) error
-	ListTags(ctx context.Context) ([]*models.Tag, error)
+	// RemoveTagFromBlurays takes the tag off every bluray carrying it
+	RemoveTagFromBlurays(ctx context.Context, id primitive.ObjectID) error
+	// SetTagDeletedAt moves a tag to the trash, or out of it with nil
+	SetTagDeletedAt(ctx context.Context, id primitive.ObjectID, deletedAt *time.Time) error
+	ListTags(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Tag, error)
+
+	// Location operations
+	CreateLocation(ctx context.Context, location *models.Location) error
+	GetLocationByID(ctx context.Context, id primitive.ObjectID) (*models.Location, error)
+	UpdateLocation(ctx context.Context, location *models.Location) error
+	DeleteLocation(ctx context.Context, id primitive.ObjectID) error
+	// ListLocations and CountBluraysByLocation look into every collection
+	// when collectionID is nil. Blurays in the trash are not counted.
+	ListLocations(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Location, error)
+	CountBluraysByLocation(ctx context.Context, collectionID *primitive.ObjectID) (map[primitive.ObjectID]int, error)
 
 	// Statistics operations
-	GetStatistics(ctx context.Context) (*models.Statistics, error)
-	GetSimplifiedStatistics(ctx context.Context) (*models.SimplifiedStatistics, error)
+	GetStatistics(ctx context.Context, filter models.BlurayFilter) (*models.Statistics, error)
+	GetSimplifiedStatistics(ctx context.Context, filter models.BlurayFilter) (*models.SimplifiedStatistics, error)
 
 	// Notification operations
 	CreateNotification(ctx context.Context, notification *models.Notification) error
-	GetUserNotifications(ctx context.Context, userID primitive.ObjectID, limit int) ([]*models.Notification, error)
+	GetUserNotifications(ctx context.Context, userID primitive.ObjectID, collectionID *primitive.ObjectID, limit int) ([]*models.Notification, error)
 	MarkNotificationAsRead(ctx context.Context, notificationID primitive.ObjectID) error
 	MarkAllNotificationsAsRead(ctx context.Context, userID primitive.ObjectID) error
 
+	// Loan operations
+	CreateLoan(ctx context.Context, loan *models.Loan) error
+	GetLoanByID(ctx context.Context, id primitive.ObjectID) (*models.Loan, error)
+	UpdateLoan(ctx context.Context, loan *models.Loan) error
+	DeleteLoan(ctx context.Context, id primitive.ObjectID) error
+	ListBlurayLoans(ctx context.Context, blurayID primitive.ObjectID) ([]*models.Loan, error)
+	// ListActiveLoans lists the loans still out, longest out first, leaving
+	// out those of blurays that are gone or in the trash. It lists the loans of every
+	// collection when collectionID is nil.
+	ListActiveLoans(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Loan, error)
+
+	// Wishlist operations
+	CreateWishlistItem(ctx context.Context, item *models.WishlistItem) error
+	GetWishlistItemByID(ctx context.Context, id primitive.ObjectID) (*models.WishlistItem, error)
+	UpdateWishlistItem(ctx context.Context, item *models.WishlistItem) error
+	DeleteWishlistItem(ctx context.Context, id primitive.ObjectID) error
+	// ListWishlistItems lists the items of every collection when collectionID is nil
+	ListWishlistItems(ctx context.Context, collectionID *primitive.ObjectID, acquired bool) ([]*models.WishlistItem, error)
+
+	// Watch log operations
+	CreateWatchEvent(ctx context.Context, event *models.WatchEvent) error
+	GetWatchEventByID(ctx context.Context, id primitive.ObjectID) (*models.WatchEvent, error)
+	UpdateWatchEvent(ctx context.Context, event *models.WatchEvent) error
+	DeleteWatchEvent(ctx context.Context, id primitive.ObjectID) error
+	DeleteBlurayWatchEvents(ctx context.Context, blurayID primitive.ObjectID) error
+	// ListWatchEvents lists the history of the user in every collection when
+	// collectionID is nil
+	ListWatchEvents(ctx context.Context, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID, skip, limit int) ([]*models.WatchEvent, error)
+	CountWatchEvents(ctx context.Context, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID) (int, error)
+	ListWatchedBlurayIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error)
+
+	// Personal rating operations
+	SetUserRating(ctx context.Context, rating *models.UserRating) error
+	GetUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) (*models.UserRating, error)
+	DeleteUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) error
+	DeleteBlurayRatings(ctx context.Context, blurayID primitive.ObjectID) error
+	ListUserRatings(ctx context.Context, userID *primitive.ObjectID) ([]*models.UserRating, error)
+
+	// Session operations
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSessionByID(ctx context.Context, id primitive.ObjectID) (*models.Session, error)
+	// UpdateSession stores the session only while its refresh token hash is
+	// still refreshTokenHash, returning ErrSessionChanged otherwise
+	UpdateSession(ctx context.Context, session *models.Session, refreshTokenHash string) error
+	ListUserSessions(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error)
+	// RevokeUserSessions revokes every active session of the user except
+	// the given one; pass primitive.NilObjectID to revoke them all
+	RevokeUserSessions(ctx context.Context, userID, except primitive.ObjectID) error
+	DeleteExpiredSessions(ctx context.Context, before time.Time) error
+
+	// Settings operations. GetSettings returns the defaults until settings
+	// are first saved.
+	GetSettings(ctx context.Context) (*models.Settings, error)
+	UpdateSettings(ctx context.Context, settings *models.Settings) error
+
+	// Personal API token operations
+	CreateAPIToken(ctx context.Context, token *models.APIToken) error
+	GetAPITokenByID(ctx context.Context, id primitive.ObjectID) (*models.APIToken, error)
+	GetAPITokenByHash(ctx context.Context, hash string) (*models.APIToken, error)
+	ListUserAPITokens(ctx context.Context, userID primitive.ObjectID) ([]*models.APIToken, error)
+	TouchAPIToken(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error
+	DeleteAPIToken(ctx context.Context, id primitive.ObjectID) error
+	DeleteUserAPITokens(ctx context.Context, userID primitive.ObjectID) error
+
+	// Saved search operations. ListSavedSearches returns the searches the
+	// user saved along with the smart collections shared in the collection,
+	// oldest first. DeleteUserSavedSearches leaves smart collections alone.
+	CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error
+	GetSavedSearchByID(ctx context.Context, id primitive.ObjectID) (*models.SavedSearch, error)
+	UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) error
+	DeleteSavedSearch(ctx context.Context, id primitive.ObjectID) error
+	ListSavedSearches(ctx context.Context, userID primitive.ObjectID, collectionID *primitive.ObjectID) ([]*models.SavedSearch, error)
+	DeleteUserSavedSearches(ctx context.Context, userID primitive.ObjectID) error
+
+	// Audit log operations. Entries are listed newest first.
+	CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter, skip, limit int) ([]*models.AuditEntry, error)
+	CountAuditEntries(ctx context.Context, filter models.AuditFilter) (int, error)
+	DeleteAuditEntriesBefore(ctx context.Context, before time.Time) error
+
 	// Password reset operations
 	CreatePasswordResetToken(userID, token string, expiresAt time.Time) error
 	VerifyPasswordResetToken(token string) (string, error)
@@ -57,3 +188,17 @@ type Datastore interface {
 	// Close connection
 	Close(ctx context.Context) error
 }
+
+// blurayConditions turns a bluray filter into the field conditions the
+// datastores match documents against
+func blurayConditions(filter models.BlurayFilter) map[string]interface{} {
+	conditions := make(map[string]interface{}, len(filter.Fields)+2)
+	for key, value := range filter.Fields {
+		conditions[key] = value
+	}
+	conditions["deleted_at"] = bson.M{"$exists": filter.Trashed}
+	if filter.CollectionID != nil {
+		conditions["collection_id"] = *filter.CollectionID
+	}
+	return conditions
+}
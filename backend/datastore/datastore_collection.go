@@ -0,0 +1,35 @@
+package datastore
+
+import (
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultCollectionName is the name given to the default collection when it
+// is created. Admins can rename it afterwards.
+const defaultCollectionName = "Main collection"
+
+// newDefaultCollection returns the default collection to create when there
+// is none yet
+func newDefaultCollection() *models.Collection {
+	now := time.Now()
+	return &models.Collection{
+		ID:        primitive.NewObjectID(),
+		Name:      defaultCollectionName,
+		IsDefault: true,
+		Members:   []models.CollectionMember{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// collectionKey returns the value stored for the collection of a record,
+// where records outside any collection have an empty key
+func collectionKey(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
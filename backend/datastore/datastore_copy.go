@@ -0,0 +1,42 @@
+package datastore
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// copyFields are the purchase and release fields that moved from blurays
+// into their copies
+var copyFields = []string{
+	"purchase_price", "purchase_date", "format", "edition", "packaging", "publisher",
+	"region_code", "barcode", "disc_count", "audio_tracks", "subtitle_tracks",
+}
+
+// splitFirstCopy moves the purchase and release fields of a bluray stored
+// before copies existed into a single copy
+func splitFirstCopy(doc bson.M) {
+	first := bson.M{"_id": primitive.NewObjectID(), "created_at": doc["created_at"]}
+	for _, field := range copyFields {
+		if value, ok := doc[field]; ok {
+			first[field] = value
+			delete(doc, field)
+		}
+	}
+	doc["copies"] = bson.A{first}
+}
+
+// mergeFirstCopy is the reverse of splitFirstCopy. Only the first copy can
+// be kept; the others are lost.
+func mergeFirstCopy(doc bson.M) {
+	copies, _ := doc["copies"].(bson.A)
+	delete(doc, "copies")
+	if len(copies) == 0 {
+		return
+	}
+	first, _ := copies[0].(bson.M)
+	for _, field := range copyFields {
+		if value, ok := first[field]; ok {
+			doc[field] = value
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package datastore
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Full-text search works the same way on every datastore. Text is folded to
+// lowercase without accents and split into words. A word of the query matches
+// a word of a bluray when they are equal, share an English or French stem,
+// when the query word is a prefix of it, or when they are a typo apart. The
+// words of each bluray are stored with it so that the datastores can narrow
+// the candidates down before they are ranked here.
+
+const (
+	// Boosts of the fields a word is found in
+	titleBoost       = 3.0
+	peopleBoost      = 1.5
+	descriptionBoost = 1.0
+
+	// snippetLength is the length past which highlighted text is cut
+	// around the first match
+	snippetLength = 160
+)
+
+// textToken is a folded word of a text, with its byte offsets in the text
+type textToken struct {
+	Term       string
+	Start, End int
+}
+
+// searchField is a text of a bluray searched by full-text queries
+type searchField struct {
+	Name  string
+	Text  string
+	Boost float64
+}
+
+var (
+	// accentFolds maps accented letters to their unaccented spelling
+	accentFolds = map[rune]string{}
+	// accentVariants lists the accented letters, in both cases, folded to
+	// each plain letter
+	accentVariants = map[rune]string{}
+)
+
+func init() {
+	for plain, accented := range map[string]string{
+		"a": "àáâãäåā", "c": "çćč", "e": "èéêëēėę", "i": "ìíîïī", "n": "ñń",
+		"o": "òóôõöøō", "u": "ùúûüū", "y": "ýÿ", "s": "šś", "z": "žźż",
+		"oe": "œ", "ae": "æ", "ss": "ß",
+	} {
+		for _, r := range accented {
+			accentFolds[r] = plain
+		}
+		if len(plain) == 1 {
+			accentVariants[rune(plain[0])] = accented + strings.ToUpper(accented)
+		}
+	}
+}
+
+// stopWords are the English and French words left out of queries, unless the
+// query is made of nothing else
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"the": true, "to": true, "with": true,
+	"au": true, "aux": true, "avec": true, "ce": true, "ces": true, "d": true, "dans": true, "de": true,
+	"des": true, "du": true, "en": true, "est": true, "et": true, "l": true, "la": true, "le": true,
+	"les": true, "ou": true, "par": true, "pour": true, "qui": true, "sur": true, "un": true, "une": true,
+}
+
+// foldRune returns the lowercase unaccented spelling of a letter or digit,
+// and an empty string for word separators
+func foldRune(r rune) string {
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return ""
+	}
+	r = unicode.ToLower(r)
+	if folded, ok := accentFolds[r]; ok {
+		return folded
+	}
+	return string(r)
+}
+
+// analyzeText splits text into folded words
+func analyzeText(text string) []textToken {
+	var tokens []textToken
+	var term strings.Builder
+	start := -1
+	for i, r := range text {
+		folded := foldRune(r)
+		if folded == "" {
+			if start >= 0 {
+				tokens = append(tokens, textToken{Term: term.String(), Start: start, End: i})
+				term.Reset()
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		term.WriteString(folded)
+	}
+	if start >= 0 {
+		tokens = append(tokens, textToken{Term: term.String(), Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// searchFields returns the texts of a bluray that full-text queries search
+func searchFields(b *models.Bluray) []searchField {
+	return []searchField{
+		{Name: "title", Text: b.Title, Boost: titleBoost},
+		{Name: "director", Text: b.Director, Boost: peopleBoost},
+		{Name: "genre.en-US", Text: strings.Join(b.Genre.En, ", "), Boost: peopleBoost},
+		{Name: "genre.fr-FR", Text: strings.Join(b.Genre.Fr, ", "), Boost: peopleBoost},
+		{Name: "description.en-US", Text: b.Description.En, Boost: descriptionBoost},
+		{Name: "description.fr-FR", Text: b.Description.Fr, Boost: descriptionBoost},
+	}
+}
+
+// searchTerms returns the distinct words of the searchable fields of a
+// bluray, which the datastores store with it. Tags are matched by name when
+// searching instead, as they can be renamed.
+func searchTerms(b *models.Bluray) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, field := range searchFields(b) {
+		for _, token := range analyzeText(field.Text) {
+			if !seen[token.Term] {
+				seen[token.Term] = true
+				terms = append(terms, token.Term)
+			}
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// indexSearchTerms stores the search terms of a stored bluray document. A
+// document that cannot be decoded is left as it is.
+func indexSearchTerms(doc bson.M) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return
+	}
+	var bluray models.Bluray
+	if err := bson.Unmarshal(data, &bluray); err != nil {
+		return
+	}
+	doc["search_terms"] = searchTerms(&bluray)
+}
+
+// stemEnglish strips the common English inflections of a folded word
+func stemEnglish(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		word = word[:len(word)-1]
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		stem := strings.TrimSuffix(word, suffix)
+		if stem != word && len(stem) >= 3 && strings.ContainsAny(stem, "aeiouy") {
+			return undouble(stem)
+		}
+	}
+	return word
+}
+
+// stemFrench strips the common French plurals, feminines and suffixes of a
+// folded word
+func stemFrench(word string) string {
+	if len(word) <= 4 {
+		return word
+	}
+	if strings.HasSuffix(word, "aux") {
+		word = word[:len(word)-3] + "al"
+	} else if strings.HasSuffix(word, "s") || strings.HasSuffix(word, "x") {
+		word = word[:len(word)-1]
+	}
+	for _, suffix := range []string{"issement", "atrice", "ateur", "ation", "ement", "ment", "euse", "ique", "isme", "iste", "eur", "ite", "ive", "if"} {
+		stem := strings.TrimSuffix(word, suffix)
+		if stem != word && len(stem) >= 3 {
+			word = stem
+			break
+		}
+	}
+	if strings.HasSuffix(word, "e") && len(word) > 4 {
+		word = word[:len(word)-1]
+	}
+	return undouble(word)
+}
+
+// undouble drops the last letter of a word ending with a doubled consonant
+func undouble(word string) string {
+	n := len(word)
+	if n >= 2 && word[n-1] == word[n-2] && strings.IndexByte("bdfgklmnprt", word[n-1]) >= 0 {
+		return word[:n-1]
+	}
+	return word
+}
+
+// typoLimit returns how many typos a query word may have
+func typoLimit(query string) int {
+	switch n := len([]rune(query)); {
+	case n >= 9:
+		return 2
+	case n >= 5:
+		return 1
+	}
+	return 0
+}
+
+// termMatch returns how well a folded word of a bluray matches a query word,
+// from 1 for the same word down to 0 for no match
+func termMatch(query, term string) float64 {
+	switch {
+	case term == query:
+		return 1
+	case stemEnglish(term) == stemEnglish(query) || stemFrench(term) == stemFrench(query):
+		return 0.8
+	case len(query) >= 3 && strings.HasPrefix(term, query):
+		return 0.6
+	case typoLimit(query) > 0 && editDistance(query, term, typoLimit(query)) <= typoLimit(query):
+		return 0.4
+	}
+	return 0
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions turning a into b, or max+1 when there are more than max
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return max + 1
+	}
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+// expandTerm returns the stored words matched by a query word
+func expandTerm(query string, vocabulary []string) []string {
+	var terms []string
+	for _, term := range vocabulary {
+		if termMatch(query, term) > 0 {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// matchingTagIDs returns the IDs of the tags outside of the trash with a
+// name matching a query word
+func matchingTagIDs(query string, tags []*models.Tag) []string {
+	var ids []string
+	for _, tag := range tags {
+		if tag.DeletedAt != nil {
+			continue
+		}
+		for _, token := range analyzeText(tag.Name) {
+			if termMatch(query, token.Term) > 0 {
+				ids = append(ids, tag.ID.Hex())
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// scoreBlurays sets the score and highlights of the blurays found by a search
+// for the given words
+func scoreBlurays(blurays []*models.Bluray, terms []string, tags []*models.Tag) {
+	tagNames := map[string]string{}
+	for _, tag := range tags {
+		if tag.DeletedAt == nil {
+			tagNames[tag.ID.Hex()] = tag.Name
+		}
+	}
+
+	for _, bluray := range blurays {
+		var names []string
+		for _, id := range bluray.Tags {
+			if name, ok := tagNames[id]; ok {
+				names = append(names, name)
+			}
+		}
+		fields := append(searchFields(bluray), searchField{Name: "tags", Text: strings.Join(names, ", "), Boost: peopleBoost})
+		bluray.Score, bluray.Highlights = scoreFields(fields, terms)
+	}
+}
+
+// scoreFields scores the fields of a bluray against the query words. Each
+// word counts for its best field, weighted by the field boost, how well it
+// matched and how often. Titles holding the whole query get the title boost
+// on top.
+func scoreFields(fields []searchField, terms []string) (float64, map[string]string) {
+	tokens := make([][]textToken, len(fields))
+	matched := make([][]bool, len(fields))
+	for i, field := range fields {
+		tokens[i] = analyzeText(field.Text)
+		matched[i] = make([]bool, len(tokens[i]))
+	}
+
+	score := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for i, field := range fields {
+			weight, hits := 0.0, 0
+			for j, token := range tokens[i] {
+				if w := termMatch(term, token.Term); w > 0 {
+					matched[i][j] = true
+					weight = math.Max(weight, w)
+					hits++
+				}
+			}
+			if hits > 0 {
+				best = math.Max(best, field.Boost*weight*(1+math.Log(float64(hits))))
+			}
+		}
+		score += best
+	}
+
+	var titleTerms []string
+	for _, token := range tokens[0] {
+		if !stopWords[token.Term] {
+			titleTerms = append(titleTerms, token.Term)
+		}
+	}
+	if strings.Contains(" "+strings.Join(titleTerms, " ")+" ", " "+strings.Join(terms, " ")+" ") {
+		score += titleBoost
+	}
+
+	highlights := map[string]string{}
+	for i, field := range fields {
+		if snippet := highlightText(field.Text, tokens[i], matched[i]); snippet != "" {
+			highlights[field.Name] = snippet
+		}
+	}
+	return math.Round(score*1000) / 1000, highlights
+}
+
+// highlightText escapes text for HTML and wraps its matched words in <mark>
+// tags, cutting long text around the first match. It returns an empty string
+// when no word matched.
+func highlightText(text string, tokens []textToken, matched []bool) string {
+	first := -1
+	for i := range tokens {
+		if matched[i] {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start, end := 0, len(text)
+	if len(text) > snippetLength {
+		from := max(tokens[first].Start-snippetLength/4, 0)
+		for _, token := range tokens {
+			if token.Start >= from {
+				start = token.Start
+				break
+			}
+		}
+		if from == 0 {
+			start = 0
+		}
+		end = tokens[first].End
+		for _, token := range tokens {
+			if token.End <= start+snippetLength && token.End > end {
+				end = token.End
+			}
+		}
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+	pos := start
+	for i, token := range tokens {
+		if !matched[i] || token.Start < start || token.End > end {
+			continue
+		}
+		snippet.WriteString(html.EscapeString(text[pos:token.Start]))
+		snippet.WriteString("<mark>" + html.EscapeString(text[token.Start:token.End]) + "</mark>")
+		pos = token.End
+	}
+	snippet.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		snippet.WriteString("…")
+	}
+	return snippet.String()
+}
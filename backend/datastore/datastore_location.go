@@ -0,0 +1,44 @@
+package datastore
+
+import (
+	"regexp"
+
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchLocationSubtree returns the IDs of the locations whose slug or name
+// matches pattern (case-insensitively), along with all of their
+// sub-locations, so that "location:living-room" finds every disc in the room
+func matchLocationSubtree(locations []*models.Location, pattern string) ([]primitive.ObjectID, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[primitive.ObjectID][]primitive.ObjectID{}
+	var queue []primitive.ObjectID
+	for _, location := range locations {
+		if location.ParentID != nil {
+			children[*location.ParentID] = append(children[*location.ParentID], location.ID)
+		}
+		if re.MatchString(location.Slug) || re.MatchString(location.Name) {
+			queue = append(queue, location.ID)
+		}
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	var ids []primitive.ObjectID
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		queue = append(queue, children[id]...)
+	}
+	return ids, nil
+}
@@ -0,0 +1,232 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryDatastore is a thread-safe, non-persistent Datastore implementation
+// meant for tests and throwaway instances.
+//
+// Records are copied through BSON on the way in and out, so callers never
+// share memory with the store and get the same time precision as MongoDB.
+type MemoryDatastore struct {
+	mu            sync.RWMutex
+	users         []*models.User
+	blurays       []*models.Bluray
+	collections   []*models.Collection
+	roles         []*models.Role
+	tags          []*models.Tag
+	notifications []*models.Notification
+	resetTokens   []*models.PasswordResetToken
+	sessions      []*models.Session
+	apiTokens     []*models.APIToken
+	savedSearches []*models.SavedSearch
+	settings      *models.Settings
+	auditLog      []*models.AuditEntry
+	revisions     []*models.BlurayRevision
+	loans         []*models.Loan
+	locations     []*models.Location
+	wishlist      []*models.WishlistItem
+	watchEvents   []*models.WatchEvent
+	ratings       []*models.UserRating
+}
+
+func NewMemoryDatastore() *MemoryDatastore {
+	return &MemoryDatastore{}
+}
+
+func (ds *MemoryDatastore) Close(ctx context.Context) error {
+	return nil
+}
+
+// convertDocument round-trips src through BSON into a new T, the same way a
+// document is decoded when read back from MongoDB
+func convertDocument[T any](src interface{}) *T {
+	data, err := bson.Marshal(src)
+	if err != nil {
+		panic(fmt.Sprintf("datastore: cannot encode %T: %v", src, err))
+	}
+	dst := new(T)
+	if err := bson.Unmarshal(data, dst); err != nil {
+		panic(fmt.Sprintf("datastore: cannot decode %T: %v", dst, err))
+	}
+	return dst
+}
+
+// cloneDocument returns a deep copy of src
+func cloneDocument[T any](src *T) *T {
+	return convertDocument[T](src)
+}
+
+// paginate applies skip and limit, where a limit of 0 means no limit and a
+// negative skip skips nothing
+func paginate[T any](items []*T, skip, limit int) []*T {
+	skip = max(skip, 0)
+	if skip >= len(items) {
+		return nil
+	}
+	items = items[skip:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// sortBluraysByNewest returns blurays ordered by creation date, newest first
+func sortBluraysByNewest(blurays []*models.Bluray) []*models.Bluray {
+	sorted := make([]*models.Bluray, 0, len(blurays))
+	for i := len(blurays) - 1; i >= 0; i-- {
+		sorted = append(sorted, blurays[i])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+	return sorted
+}
+
+// matchesFilters reports whether doc satisfies the equality filters with the
+// semantics of a MongoDB find: dotted keys descend into sub-documents and
+// array fields match when any element is equal to the value.
+func matchesFilters(doc interface{}, filters map[string]interface{}) (bool, error) {
+	if len(filters) == 0 {
+		return true, nil
+	}
+
+	fields := *convertDocument[bson.M](doc)
+	for key, want := range filters {
+		if operators, ok := want.(bson.M); ok {
+			matched, err := matchesFilterOperators(lookupField(fields, key), operators)
+			if err != nil {
+				return false, fmt.Errorf("unsupported filter value for %q: %w", key, err)
+			}
+			if !matched {
+				return false, nil
+			}
+			continue
+		}
+		wantValue, err := normalizeFilterValue(want)
+		if err != nil {
+			return false, fmt.Errorf("unsupported filter value for %q: %w", key, err)
+		}
+		if !matchesFilterValue(lookupField(fields, key), wantValue) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lookupField resolves a dotted key. Like MongoDB, a key going through an
+// array of documents (e.g. "copies.format") collects the value of every element.
+func lookupField(fields bson.M, key string) interface{} {
+	var current interface{} = fields
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if array, ok := current.(bson.A); ok {
+			rest := strings.Join(parts[i:], ".")
+			values := bson.A{}
+			for _, element := range array {
+				if doc, ok := element.(bson.M); ok {
+					values = append(values, lookupField(doc, rest))
+				}
+			}
+			return values
+		}
+		doc, ok := current.(bson.M)
+		if !ok {
+			return nil
+		}
+		current = doc[part]
+	}
+	return current
+}
+
+func matchesFilterValue(value, want interface{}) bool {
+	if array, ok := value.(bson.A); ok {
+		for _, element := range array {
+			if matchesFilterValue(element, want) {
+				return true
+			}
+		}
+		return false
+	}
+	normalized, err := normalizeFilterValue(value)
+	return err == nil && normalized == want
+}
+
+// matchesFilterOperators evaluates the $in, $nin and $exists operators, the
+// only ones the datastores share
+func matchesFilterOperators(value interface{}, operators bson.M) (bool, error) {
+	for operator, operand := range operators {
+		if operator == "$exists" {
+			exists, ok := operand.(bool)
+			if !ok {
+				return false, fmt.Errorf("operand of %s is %T", operator, operand)
+			}
+			if (value != nil) != exists {
+				return false, nil
+			}
+			continue
+		}
+		rv := reflect.ValueOf(operand)
+		if rv.Kind() != reflect.Slice {
+			return false, fmt.Errorf("operand of %s is %T", operator, operand)
+		}
+		found := false
+		for i := 0; i < rv.Len(); i++ {
+			want, err := normalizeFilterValue(rv.Index(i).Interface())
+			if err != nil {
+				return false, err
+			}
+			if matchesFilterValue(value, want) {
+				found = true
+				break
+			}
+		}
+		switch operator {
+		case "$in":
+			if !found {
+				return false, nil
+			}
+		case "$nin":
+			if found {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("operator %s", operator)
+		}
+	}
+	return true, nil
+}
+
+// normalizeFilterValue maps named and sized scalar types onto a comparable
+// representation, so that int32(2010) equals int(2010) and MediaType equals string
+func normalizeFilterValue(value interface{}) (interface{}, error) {
+	if id, ok := value.(primitive.ObjectID); ok {
+		return id, nil
+	}
+	if value == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	}
+	return nil, fmt.Errorf("type %T", value)
+}
@@ -0,0 +1,104 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, existing := range ds.apiTokens {
+		if existing.TokenHash == token.TokenHash {
+			return errors.New("duplicate key: token_hash")
+		}
+	}
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+	ds.apiTokens = append(ds.apiTokens, cloneDocument(token))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetAPITokenByID(ctx context.Context, id primitive.ObjectID) (*models.APIToken, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, token := range ds.apiTokens {
+		if token.ID == id {
+			return cloneDocument(token), nil
+		}
+	}
+	return nil, errors.New("api token not found")
+}
+
+func (ds *MemoryDatastore) GetAPITokenByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, token := range ds.apiTokens {
+		if token.TokenHash == hash {
+			return cloneDocument(token), nil
+		}
+	}
+	return nil, errors.New("api token not found")
+}
+
+func (ds *MemoryDatastore) ListUserAPITokens(ctx context.Context, userID primitive.ObjectID) ([]*models.APIToken, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var tokens []*models.APIToken
+	for _, token := range ds.apiTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, cloneDocument(token))
+		}
+	}
+	return tokens, nil
+}
+
+func (ds *MemoryDatastore) TouchAPIToken(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, token := range ds.apiTokens {
+		if token.ID == id {
+			touched := *token
+			touched.LastUsedAt = &usedAt
+			ds.apiTokens[i] = cloneDocument(&touched)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteAPIToken(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, token := range ds.apiTokens {
+		if token.ID == id {
+			ds.apiTokens = append(ds.apiTokens[:i], ds.apiTokens[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteUserAPITokens(ctx context.Context, userID primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	kept := ds.apiTokens[:0]
+	for _, token := range ds.apiTokens {
+		if token.UserID != userID {
+			kept = append(kept, token)
+		}
+	}
+	ds.apiTokens = kept
+	return nil
+}
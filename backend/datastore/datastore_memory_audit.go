@@ -0,0 +1,60 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+	ds.auditLog = append(ds.auditLog, cloneDocument(entry))
+	return nil
+}
+
+func (ds *MemoryDatastore) ListAuditEntries(ctx context.Context, filter models.AuditFilter, skip, limit int) ([]*models.AuditEntry, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	// Entries are appended in order, so the newest are at the end
+	var entries []*models.AuditEntry
+	for i := len(ds.auditLog) - 1; i >= 0; i-- {
+		if filter.Matches(ds.auditLog[i]) {
+			entries = append(entries, cloneDocument(ds.auditLog[i]))
+		}
+	}
+	return paginate(entries, skip, limit), nil
+}
+
+func (ds *MemoryDatastore) CountAuditEntries(ctx context.Context, filter models.AuditFilter) (int, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	count := 0
+	for _, entry := range ds.auditLog {
+		if filter.Matches(entry) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (ds *MemoryDatastore) DeleteAuditEntriesBefore(ctx context.Context, before time.Time) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	kept := ds.auditLog[:0]
+	for _, entry := range ds.auditLog {
+		if !entry.CreatedAt.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	ds.auditLog = kept
+	return nil
+}
@@ -0,0 +1,420 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateBluray(ctx context.Context, bluray *models.Bluray) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	bluray.ID = primitive.NewObjectID()
+	bluray.CreatedAt = time.Now()
+	bluray.UpdatedAt = time.Now()
+	bluray.SearchTerms = searchTerms(bluray)
+	bluray.SortKeys = sortKeys(bluray)
+	ds.blurays = append(ds.blurays, cloneDocument(bluray))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetBlurayByID(ctx context.Context, id primitive.ObjectID) (*models.Bluray, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, bluray := range ds.blurays {
+		if bluray.ID == id {
+			return cloneDocument(bluray), nil
+		}
+	}
+	return nil, errors.New("bluray not found")
+}
+
+func (ds *MemoryDatastore) UpdateBluray(ctx context.Context, bluray *models.Bluray) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	bluray.UpdatedAt = time.Now()
+	bluray.SearchTerms = searchTerms(bluray)
+	bluray.SortKeys = sortKeys(bluray)
+	for i, existing := range ds.blurays {
+		if existing.ID == bluray.ID {
+			// Preserve the original creation metadata and the trash marker
+			updated := cloneDocument(bluray)
+			updated.AddedBy = existing.AddedBy
+			updated.CreatedAt = existing.CreatedAt
+			updated.DeletedAt = existing.DeletedAt
+			ds.blurays[i] = updated
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) SetBlurayDeletedAt(ctx context.Context, id primitive.ObjectID, deletedAt *time.Time) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, bluray := range ds.blurays {
+		if bluray.ID == id {
+			updated := *bluray
+			updated.DeletedAt = deletedAt
+			ds.blurays[i] = cloneDocument(&updated)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteBluray(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, bluray := range ds.blurays {
+		if bluray.ID == id {
+			ds.blurays = append(ds.blurays[:i], ds.blurays[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// filterBlurays returns the blurays matching the filter, newest first. The
+// caller must hold the lock.
+func (ds *MemoryDatastore) filterBlurays(filter models.BlurayFilter) ([]*models.Bluray, error) {
+	conditions := blurayConditions(filter)
+	var matches []*models.Bluray
+	for _, bluray := range ds.blurays {
+		ok, err := matchesFilters(bluray, conditions)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, bluray)
+		}
+	}
+	return sortBluraysByNewest(matches), nil
+}
+
+func (ds *MemoryDatastore) PageBlurays(ctx context.Context, filter models.BlurayFilter, page *BlurayPage) ([]*models.Bluray, int, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	matches, err := ds.filterBlurays(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var blurays []*models.Bluray
+	for _, bluray := range pageBlurays(matches, page) {
+		blurays = append(blurays, cloneDocument(bluray))
+	}
+	return blurays, len(matches), nil
+}
+
+func (ds *MemoryDatastore) PageSearchBlurays(ctx context.Context, query string, filter models.BlurayFilter, page *BlurayPage) ([]*models.Bluray, int, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	matches, root, err := ds.searchMatches(query, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var blurays []*models.Bluray
+	for _, bluray := range pageBlurays(matches, page) {
+		blurays = append(blurays, cloneDocument(bluray))
+	}
+	if terms := positiveTerms(root); len(terms) > 0 {
+		scoreBlurays(blurays, terms, ds.tags)
+	}
+	return blurays, len(matches), nil
+}
+
+// searchMatches returns the blurays matching the filter that a search finds,
+// newest first, with the parsed query. The caller must hold the lock.
+func (ds *MemoryDatastore) searchMatches(query string, filter models.BlurayFilter) ([]*models.Bluray, *searchNode, error) {
+	// Parse the query (e.g., "title:inception (tag:action OR year:>=2010)")
+	root, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	lookup := &searchLookup{Tags: ds.tags, Ratings: ratingAverages(ds.ratings)}
+	condition, err := ds.searchCondition(root, lookup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conditions := blurayConditions(filter)
+	var matches []*models.Bluray
+	for _, bluray := range ds.blurays {
+		ok, err := matchesFilters(bluray, conditions)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok && condition(bluray) {
+			matches = append(matches, bluray)
+		}
+	}
+	return sortBluraysByNewest(matches), root, nil
+}
+
+// searchCondition translates a parsed search query into a condition on
+// blurays. The caller must hold the lock.
+func (ds *MemoryDatastore) searchCondition(node *searchNode, lookup *searchLookup) (func(*models.Bluray) bool, error) {
+	switch node.Kind {
+	case nodeAnd, nodeOr:
+		conditions := make([]func(*models.Bluray) bool, len(node.Children))
+		for i, child := range node.Children {
+			condition, err := ds.searchCondition(child, lookup)
+			if err != nil {
+				return nil, err
+			}
+			conditions[i] = condition
+		}
+		// AND stops at the first miss and OR at the first match
+		all := node.Kind == nodeAnd
+		return func(b *models.Bluray) bool {
+			for _, condition := range conditions {
+				if condition(b) != all {
+					return !all
+				}
+			}
+			return all
+		}, nil
+	case nodeNot:
+		condition, err := ds.searchCondition(node.Children[0], lookup)
+		if err != nil {
+			return nil, err
+		}
+		return func(b *models.Bluray) bool { return !condition(b) }, nil
+	case nodeWords:
+		var conditions []func(*models.Bluray) bool
+		for _, term := range node.Terms {
+			tagIDs := matchingTagIDs(term, lookup.Tags)
+			conditions = append(conditions, func(b *models.Bluray) bool {
+				for _, stored := range b.SearchTerms {
+					if termMatch(term, stored) > 0 {
+						return true
+					}
+				}
+				return hasAnyTagID(b, tagIDs)
+			})
+		}
+		return func(b *models.Bluray) bool { return matchesConditions(b, conditions) }, nil
+	case nodePhrase:
+		if len(node.Terms) == 0 {
+			return matchAll, nil
+		}
+		re := regexp.MustCompile("(?i)" + phrasePattern(node.Terms))
+		tagIDs := phraseTagIDs(re, lookup.Tags)
+		return func(b *models.Bluray) bool {
+			for _, field := range searchFields(b) {
+				if re.MatchString(field.Text) {
+					return true
+				}
+			}
+			return hasAnyTagID(b, tagIDs)
+		}, nil
+	}
+	return ds.fieldCondition(node, lookup)
+}
+
+// fieldCondition translates a field filter into a condition on blurays.
+// The caller must hold the lock.
+func (ds *MemoryDatastore) fieldCondition(f *searchNode, lookup *searchLookup) (func(*models.Bluray) bool, error) {
+	switch f.Field {
+	case "title", "director", "genre", "description", "edition", "publisher", "region", "audio", "subtitle":
+		re, err := regexp.Compile("(?i)" + f.Value)
+		if err != nil {
+			return nil, err
+		}
+		field := f.Field
+		return func(b *models.Bluray) bool {
+			return matchesAnyText(re, blurayTextField(b, field)...)
+		}, nil
+	case "tag":
+		// Search for tags by name first, then search blurays by tag IDs
+		matchingTags, err := ds.searchTagsByName(f.Value)
+		if err != nil || len(matchingTags) == 0 {
+			// If no tags found, nothing can match
+			return matchNone, nil
+		}
+		return func(b *models.Bluray) bool {
+			return hasAnyTag(b, matchingTags)
+		}, nil
+	case "year":
+		return func(b *models.Bluray) bool {
+			return f.Range.Contains(float64(b.ReleaseYear))
+		}, nil
+	case "rating":
+		return func(b *models.Bluray) bool {
+			return f.Range.Contains(lookup.Ratings[b.ID])
+		}, nil
+	case "price":
+		return func(b *models.Bluray) bool {
+			return hasAnyCopy(b, func(c *models.Copy) bool { return f.Range.Contains(c.PurchasePrice) })
+		}, nil
+	case "purchased":
+		return func(b *models.Bluray) bool {
+			return hasAnyCopy(b, func(c *models.Copy) bool {
+				return !c.PurchaseDate.IsZero() && f.Range.Contains(float64(c.PurchaseDate.UnixMilli()))
+			})
+		}, nil
+	case "type":
+		mediaType := models.MediaType(f.Value)
+		return func(b *models.Bluray) bool {
+			return b.Type == mediaType
+		}, nil
+	case "format":
+		format, ok := models.ParseReleaseFormat(f.Value)
+		if !ok {
+			// Unknown formats cannot match anything
+			return matchNone, nil
+		}
+		return func(b *models.Bluray) bool {
+			return hasAnyCopy(b, func(c *models.Copy) bool { return c.Format == format })
+		}, nil
+	case "packaging":
+		packaging, ok := models.ParsePackaging(f.Value)
+		if !ok {
+			// Unknown packagings cannot match anything
+			return matchNone, nil
+		}
+		return func(b *models.Bluray) bool {
+			return hasAnyCopy(b, func(c *models.Copy) bool { return c.Packaging == packaging })
+		}, nil
+	case "barcode":
+		barcode := f.Value
+		return func(b *models.Bluray) bool {
+			return hasAnyCopy(b, func(c *models.Copy) bool { return c.Barcode == barcode })
+		}, nil
+	case "location":
+		// Match the location and everything stored inside it
+		locationIDs, err := matchLocationSubtree(ds.locations, f.Value)
+		if err != nil || len(locationIDs) == 0 {
+			// If no locations found, nothing can match
+			return matchNone, nil
+		}
+		return func(b *models.Bluray) bool {
+			return b.LocationID != nil && containsObjectID(locationIDs, *b.LocationID)
+		}, nil
+	}
+	return nil, unknownSearchField(f.Field)
+}
+
+func matchAll(*models.Bluray) bool  { return true }
+func matchNone(*models.Bluray) bool { return false }
+
+// matchesConditions requires every condition. An empty AND matches
+// everything, like an empty $and.
+func matchesConditions(b *models.Bluray, conditions []func(*models.Bluray) bool) bool {
+	for _, condition := range conditions {
+		if !condition(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// blurayTextField returns the searchable text values behind a search field
+func blurayTextField(b *models.Bluray, field string) []string {
+	switch field {
+	case "title":
+		return []string{b.Title}
+	case "director":
+		return []string{b.Director}
+	case "genre":
+		return append(append([]string{}, b.Genre.En...), b.Genre.Fr...)
+	case "description":
+		return []string{b.Description.En, b.Description.Fr}
+	}
+
+	// The release fields come from every copy
+	var values []string
+	for _, c := range b.Copies {
+		switch field {
+		case "edition":
+			values = append(values, c.Edition)
+		case "publisher":
+			values = append(values, c.Publisher)
+		case "region":
+			values = append(values, c.RegionCode)
+		case "audio":
+			values = append(values, c.AudioTracks...)
+		case "subtitle":
+			values = append(values, c.SubtitleTracks...)
+		}
+	}
+	return values
+}
+
+func hasAnyCopy(b *models.Bluray, match func(*models.Copy) bool) bool {
+	for i := range b.Copies {
+		if match(&b.Copies[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyText(re *regexp.Regexp, values ...string) bool {
+	for _, value := range values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyTagID(b *models.Bluray, tagIDs []string) bool {
+	for _, tagID := range b.Tags {
+		for _, id := range tagIDs {
+			if id == tagID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasAnyTag(b *models.Bluray, tags []*models.Tag) bool {
+	for _, tagID := range b.Tags {
+		for _, tag := range tags {
+			if tag.ID.Hex() == tagID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsObjectID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (ds *MemoryDatastore) PageSimplifiedBlurays(ctx context.Context, filter models.BlurayFilter, page *BlurayPage) ([]*models.SimplifiedBluray, int, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	matches, err := ds.filterBlurays(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var blurays []*models.SimplifiedBluray
+	for _, bluray := range pageBlurays(matches, page) {
+		blurays = append(blurays, convertDocument[models.SimplifiedBluray](bluray))
+	}
+	return blurays, len(matches), nil
+}
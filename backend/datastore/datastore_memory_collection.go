@@ -0,0 +1,93 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	collection.ID = primitive.NewObjectID()
+	collection.IsDefault = false
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = collection.CreatedAt
+	ds.collections = append(ds.collections, cloneDocument(collection))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetCollectionByID(ctx context.Context, id primitive.ObjectID) (*models.Collection, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, collection := range ds.collections {
+		if collection.ID == id {
+			return cloneDocument(collection), nil
+		}
+	}
+	return nil, errors.New("collection not found")
+}
+
+func (ds *MemoryDatastore) EnsureDefaultCollection(ctx context.Context) (*models.Collection, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, collection := range ds.collections {
+		if collection.IsDefault {
+			return cloneDocument(collection), nil
+		}
+	}
+
+	collection := newDefaultCollection()
+	ds.collections = append(ds.collections, cloneDocument(collection))
+	return collection, nil
+}
+
+func (ds *MemoryDatastore) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	collection.UpdatedAt = time.Now()
+	for i, existing := range ds.collections {
+		if existing.ID == collection.ID {
+			updated := cloneDocument(collection)
+			updated.IsDefault = existing.IsDefault
+			updated.CreatedBy = existing.CreatedBy
+			updated.CreatedAt = existing.CreatedAt
+			ds.collections[i] = updated
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteCollection(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, collection := range ds.collections {
+		if collection.ID == id {
+			ds.collections = append(ds.collections[:i], ds.collections[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) ListCollections(ctx context.Context, memberID *primitive.ObjectID) ([]*models.Collection, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var collections []*models.Collection
+	for _, collection := range ds.collections {
+		if memberID == nil || collection.Member(*memberID) != nil {
+			collections = append(collections, cloneDocument(collection))
+		}
+	}
+	return collections, nil
+}
@@ -0,0 +1,103 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	loan.ID = primitive.NewObjectID()
+	loan.CreatedAt = time.Now()
+	loan.UpdatedAt = time.Now()
+	ds.loans = append(ds.loans, cloneDocument(loan))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetLoanByID(ctx context.Context, id primitive.ObjectID) (*models.Loan, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, loan := range ds.loans {
+		if loan.ID == id {
+			return cloneDocument(loan), nil
+		}
+	}
+	return nil, errors.New("loan not found")
+}
+
+func (ds *MemoryDatastore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	loan.UpdatedAt = time.Now()
+	for i, existing := range ds.loans {
+		if existing.ID == loan.ID {
+			ds.loans[i] = cloneDocument(loan)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteLoan(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, loan := range ds.loans {
+		if loan.ID == id {
+			ds.loans = append(ds.loans[:i], ds.loans[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) ListBlurayLoans(ctx context.Context, blurayID primitive.ObjectID) ([]*models.Loan, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var loans []*models.Loan
+	for _, loan := range ds.loans {
+		if loan.BlurayID == blurayID {
+			loans = append(loans, cloneDocument(loan))
+		}
+	}
+	sort.SliceStable(loans, func(i, j int) bool {
+		return loans[i].LentAt.After(loans[j].LentAt)
+	})
+	return loans, nil
+}
+
+func (ds *MemoryDatastore) ListActiveLoans(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Loan, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	blurays := make(map[primitive.ObjectID]*models.Bluray, len(ds.blurays))
+	for _, bluray := range ds.blurays {
+		blurays[bluray.ID] = bluray
+	}
+
+	var loans []*models.Loan
+	for _, loan := range ds.loans {
+		bluray := blurays[loan.BlurayID]
+		if !loan.IsActive() || bluray == nil || bluray.DeletedAt != nil || (collectionID != nil && bluray.CollectionID != *collectionID) {
+			continue
+		}
+		active := cloneDocument(loan)
+		active.BlurayTitle = bluray.Title
+		active.CollectionID = bluray.CollectionID
+		loans = append(loans, active)
+	}
+	sort.SliceStable(loans, func(i, j int) bool {
+		return loans[i].LentAt.Before(loans[j].LentAt)
+	})
+	return loans, nil
+}
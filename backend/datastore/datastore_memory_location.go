@@ -0,0 +1,90 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateLocation(ctx context.Context, location *models.Location) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	location.ID = primitive.NewObjectID()
+	location.CreatedAt = time.Now()
+	location.UpdatedAt = time.Now()
+	ds.locations = append(ds.locations, cloneDocument(location))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetLocationByID(ctx context.Context, id primitive.ObjectID) (*models.Location, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, location := range ds.locations {
+		if location.ID == id {
+			return cloneDocument(location), nil
+		}
+	}
+	return nil, errors.New("location not found")
+}
+
+func (ds *MemoryDatastore) UpdateLocation(ctx context.Context, location *models.Location) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	location.UpdatedAt = time.Now()
+	for i, existing := range ds.locations {
+		if existing.ID == location.ID {
+			ds.locations[i] = cloneDocument(location)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteLocation(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, location := range ds.locations {
+		if location.ID == id {
+			ds.locations = append(ds.locations[:i], ds.locations[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) ListLocations(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Location, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var locations []*models.Location
+	for _, location := range ds.locations {
+		if collectionID == nil || location.CollectionID == *collectionID {
+			locations = append(locations, cloneDocument(location))
+		}
+	}
+	sort.SliceStable(locations, func(i, j int) bool {
+		return locations[i].Name < locations[j].Name
+	})
+	return locations, nil
+}
+
+func (ds *MemoryDatastore) CountBluraysByLocation(ctx context.Context, collectionID *primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	counts := map[primitive.ObjectID]int{}
+	for _, bluray := range ds.blurays {
+		if bluray.LocationID != nil && bluray.DeletedAt == nil && (collectionID == nil || bluray.CollectionID == *collectionID) {
+			counts[*bluray.LocationID]++
+		}
+	}
+	return counts, nil
+}
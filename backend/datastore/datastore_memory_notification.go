@@ -0,0 +1,69 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateNotification(ctx context.Context, notification *models.Notification) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	notification.ID = primitive.NewObjectID()
+	notification.CreatedAt = time.Now()
+	notification.Read = false
+	ds.notifications = append(ds.notifications, cloneDocument(notification))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetUserNotifications(ctx context.Context, userID primitive.ObjectID, collectionID *primitive.ObjectID, limit int) ([]*models.Notification, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	// Walk backwards so that notifications created within the same
+	// millisecond still come out newest first
+	var notifications []*models.Notification
+	for i := len(ds.notifications) - 1; i >= 0; i-- {
+		notification := ds.notifications[i]
+		if notification.UserID != userID {
+			continue
+		}
+		if collectionID == nil || notification.CollectionID.IsZero() || notification.CollectionID == *collectionID {
+			notifications = append(notifications, cloneDocument(ds.notifications[i]))
+		}
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+
+	return paginate(notifications, 0, limit), nil
+}
+
+func (ds *MemoryDatastore) MarkNotificationAsRead(ctx context.Context, notificationID primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, notification := range ds.notifications {
+		if notification.ID == notificationID {
+			notification.Read = true
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) MarkAllNotificationsAsRead(ctx context.Context, userID primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, notification := range ds.notifications {
+		if notification.UserID == userID {
+			notification.Read = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (ds *MemoryDatastore) CreatePasswordResetToken(userID, token string, expiresAt time.Time) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.resetTokens = append(ds.resetTokens, cloneDocument(&models.PasswordResetToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}))
+	return nil
+}
+
+func (ds *MemoryDatastore) VerifyPasswordResetToken(token string) (string, error) {
+	ds.mu.RLock()
+	var resetToken *models.PasswordResetToken
+	for _, t := range ds.resetTokens {
+		if t.Token == token {
+			resetToken = t
+			break
+		}
+	}
+	ds.mu.RUnlock()
+
+	if resetToken == nil {
+		return "", errors.New("invalid token")
+	}
+
+	// Check if token is expired
+	if time.Now().After(resetToken.ExpiresAt) {
+		ds.DeletePasswordResetToken(token)
+		return "", errors.New("token expired")
+	}
+
+	return resetToken.UserID, nil
+}
+
+func (ds *MemoryDatastore) DeletePasswordResetToken(token string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, t := range ds.resetTokens {
+		if t.Token == token {
+			ds.resetTokens = append(ds.resetTokens[:i], ds.resetTokens[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) UpdateUserPassword(userID, newPassword string) error {
+	ctx := context.Background()
+
+	// Hash the new password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	user, err := ds.GetUserByID(ctx, objID)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = string(hashedPassword)
+	return ds.UpdateUser(ctx, user)
+}
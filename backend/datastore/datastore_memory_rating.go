@@ -0,0 +1,86 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) SetUserRating(ctx context.Context, rating *models.UserRating) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	rating.UpdatedAt = time.Now()
+	for i, existing := range ds.ratings {
+		if existing.UserID == rating.UserID && existing.BlurayID == rating.BlurayID {
+			rating.ID = existing.ID
+			rating.CreatedAt = existing.CreatedAt
+			ds.ratings[i] = cloneDocument(rating)
+			return nil
+		}
+	}
+
+	rating.ID = primitive.NewObjectID()
+	rating.CreatedAt = rating.UpdatedAt
+	ds.ratings = append(ds.ratings, cloneDocument(rating))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) (*models.UserRating, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, rating := range ds.ratings {
+		if rating.UserID == userID && rating.BlurayID == blurayID {
+			return cloneDocument(rating), nil
+		}
+	}
+	return nil, errors.New("rating not found")
+}
+
+func (ds *MemoryDatastore) DeleteUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, rating := range ds.ratings {
+		if rating.UserID == userID && rating.BlurayID == blurayID {
+			ds.ratings = append(ds.ratings[:i], ds.ratings[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteBlurayRatings(ctx context.Context, blurayID primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	kept := ds.ratings[:0]
+	for _, rating := range ds.ratings {
+		if rating.BlurayID != blurayID {
+			kept = append(kept, rating)
+		}
+	}
+	ds.ratings = kept
+	return nil
+}
+
+func (ds *MemoryDatastore) ListUserRatings(ctx context.Context, userID *primitive.ObjectID) ([]*models.UserRating, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var ratings []*models.UserRating
+	for _, rating := range ds.ratings {
+		if userID == nil || rating.UserID == *userID {
+			ratings = append(ratings, cloneDocument(rating))
+		}
+	}
+	sort.SliceStable(ratings, func(i, j int) bool {
+		return ratings[i].UpdatedAt.After(ratings[j].UpdatedAt)
+	})
+	return ratings, nil
+}
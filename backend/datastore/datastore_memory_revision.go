@@ -0,0 +1,79 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateBlurayRevision(ctx context.Context, revision *models.BlurayRevision) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	revision.Number = 1
+	for _, existing := range ds.revisions {
+		if existing.BlurayID == revision.BlurayID && existing.Number >= revision.Number {
+			revision.Number = existing.Number + 1
+		}
+	}
+	revision.ID = primitive.NewObjectID()
+	revision.CreatedAt = time.Now()
+	ds.revisions = append(ds.revisions, cloneDocument(revision))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetBlurayRevision(ctx context.Context, blurayID primitive.ObjectID, number int) (*models.BlurayRevision, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, revision := range ds.revisions {
+		if revision.BlurayID == blurayID && revision.Number == number {
+			return cloneDocument(revision), nil
+		}
+	}
+	return nil, errors.New("revision not found")
+}
+
+func (ds *MemoryDatastore) ListBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID, skip, limit int) ([]*models.BlurayRevision, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	// Revisions are appended in order, so the newest are at the end
+	var revisions []*models.BlurayRevision
+	for i := len(ds.revisions) - 1; i >= 0; i-- {
+		if ds.revisions[i].BlurayID == blurayID {
+			revisions = append(revisions, cloneDocument(ds.revisions[i]))
+		}
+	}
+	return paginate(revisions, skip, limit), nil
+}
+
+func (ds *MemoryDatastore) CountBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID) (int, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	count := 0
+	for _, revision := range ds.revisions {
+		if revision.BlurayID == blurayID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (ds *MemoryDatastore) DeleteBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	kept := ds.revisions[:0]
+	for _, revision := range ds.revisions {
+		if revision.BlurayID != blurayID {
+			kept = append(kept, revision)
+		}
+	}
+	ds.revisions = kept
+	return nil
+}
@@ -0,0 +1,101 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateRole(ctx context.Context, role *models.Role) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.roleIndex(role.Name) >= 0 {
+		return errors.New("duplicate key: name")
+	}
+	ds.insertRole(role)
+	return nil
+}
+
+// roleIndex returns the position of the named role, or -1. The caller holds
+// the lock.
+func (ds *MemoryDatastore) roleIndex(name models.UserRole) int {
+	for i, role := range ds.roles {
+		if role.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertRole stores a new role. The caller holds the lock.
+func (ds *MemoryDatastore) insertRole(role *models.Role) {
+	role.ID = primitive.NewObjectID()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = role.CreatedAt
+	ds.roles = append(ds.roles, cloneDocument(role))
+}
+
+func (ds *MemoryDatastore) GetRoleByName(ctx context.Context, name models.UserRole) (*models.Role, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	if i := ds.roleIndex(name); i >= 0 {
+		return cloneDocument(ds.roles[i]), nil
+	}
+	return nil, errors.New("role not found")
+}
+
+func (ds *MemoryDatastore) UpdateRole(ctx context.Context, role *models.Role) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	i := ds.roleIndex(role.Name)
+	if i < 0 {
+		return nil
+	}
+	existing := ds.roles[i]
+	role.ID = existing.ID
+	role.BuiltIn = existing.BuiltIn
+	role.CreatedAt = existing.CreatedAt
+	role.UpdatedAt = time.Now()
+	ds.roles[i] = cloneDocument(role)
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteRole(ctx context.Context, name models.UserRole) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if i := ds.roleIndex(name); i >= 0 {
+		ds.roles = append(ds.roles[:i], ds.roles[i+1:]...)
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	roles := make([]*models.Role, 0, len(ds.roles))
+	for _, role := range ds.roles {
+		roles = append(roles, cloneDocument(role))
+	}
+	return roles, nil
+}
+
+func (ds *MemoryDatastore) EnsureDefaultRoles(ctx context.Context) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	// An existing role keeps the permissions admins gave it
+	for _, role := range models.DefaultRoles() {
+		if ds.roleIndex(role.Name) < 0 {
+			ds.insertRole(role)
+		}
+	}
+	return nil
+}
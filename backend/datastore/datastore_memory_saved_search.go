@@ -0,0 +1,88 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	search.ID = primitive.NewObjectID()
+	search.CreatedAt = time.Now()
+	search.UpdatedAt = time.Now()
+	ds.savedSearches = append(ds.savedSearches, cloneDocument(search))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetSavedSearchByID(ctx context.Context, id primitive.ObjectID) (*models.SavedSearch, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, search := range ds.savedSearches {
+		if search.ID == id {
+			return cloneDocument(search), nil
+		}
+	}
+	return nil, errors.New("saved search not found")
+}
+
+func (ds *MemoryDatastore) UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	search.UpdatedAt = time.Now()
+	for i, existing := range ds.savedSearches {
+		if existing.ID == search.ID {
+			ds.savedSearches[i] = cloneDocument(search)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteSavedSearch(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, search := range ds.savedSearches {
+		if search.ID == id {
+			ds.savedSearches = append(ds.savedSearches[:i], ds.savedSearches[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) ListSavedSearches(ctx context.Context, userID primitive.ObjectID, collectionID *primitive.ObjectID) ([]*models.SavedSearch, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var searches []*models.SavedSearch
+	for _, search := range ds.savedSearches {
+		if search.Shared && collectionID != nil && search.CollectionID != nil && *search.CollectionID == *collectionID ||
+			!search.Shared && search.UserID == userID {
+			searches = append(searches, cloneDocument(search))
+		}
+	}
+	return searches, nil
+}
+
+func (ds *MemoryDatastore) DeleteUserSavedSearches(ctx context.Context, userID primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	kept := ds.savedSearches[:0]
+	for _, search := range ds.savedSearches {
+		if search.Shared || search.UserID != userID {
+			kept = append(kept, search)
+		}
+	}
+	ds.savedSearches = kept
+	return nil
+}
@@ -0,0 +1,92 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateSession(ctx context.Context, session *models.Session) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	ds.sessions = append(ds.sessions, cloneDocument(session))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetSessionByID(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, session := range ds.sessions {
+		if session.ID == id {
+			return cloneDocument(session), nil
+		}
+	}
+	return nil, errors.New("session not found")
+}
+
+func (ds *MemoryDatastore) UpdateSession(ctx context.Context, session *models.Session, refreshTokenHash string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, existing := range ds.sessions {
+		if existing.ID == session.ID && existing.RefreshTokenHash == refreshTokenHash {
+			ds.sessions[i] = cloneDocument(session)
+			return nil
+		}
+	}
+	return ErrSessionChanged
+}
+
+func (ds *MemoryDatastore) ListUserSessions(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var sessions []*models.Session
+	for _, session := range ds.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, cloneDocument(session))
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (ds *MemoryDatastore) RevokeUserSessions(ctx context.Context, userID, except primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	now := time.Now()
+	for i, session := range ds.sessions {
+		if session.UserID == userID && session.ID != except && session.RevokedAt == nil {
+			revoked := *session
+			revoked.RevokedAt = &now
+			ds.sessions[i] = cloneDocument(&revoked)
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteExpiredSessions(ctx context.Context, before time.Time) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	kept := ds.sessions[:0]
+	for _, session := range ds.sessions {
+		if !session.ExpiresAt.Before(before) {
+			kept = append(kept, session)
+		}
+	}
+	ds.sessions = kept
+	return nil
+}
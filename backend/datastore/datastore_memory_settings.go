@@ -0,0 +1,26 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+	"time"
+)
+
+func (ds *MemoryDatastore) GetSettings(ctx context.Context) (*models.Settings, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	if ds.settings == nil {
+		return &models.Settings{}, nil
+	}
+	return cloneDocument(ds.settings), nil
+}
+
+func (ds *MemoryDatastore) UpdateSettings(ctx context.Context, settings *models.Settings) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	settings.UpdatedAt = time.Now()
+	ds.settings = cloneDocument(settings)
+	return nil
+}
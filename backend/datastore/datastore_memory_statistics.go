@@ -0,0 +1,45 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+)
+
+func (ds *MemoryDatastore) GetStatistics(ctx context.Context, filter models.BlurayFilter) (*models.Statistics, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	blurays, err := ds.statisticsBlurays(filter)
+	if err != nil {
+		return nil, err
+	}
+	return computeStatistics(blurays), nil
+}
+
+func (ds *MemoryDatastore) GetSimplifiedStatistics(ctx context.Context, filter models.BlurayFilter) (*models.SimplifiedStatistics, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	blurays, err := ds.statisticsBlurays(filter)
+	if err != nil {
+		return nil, err
+	}
+	return computeSimplifiedStatistics(blurays), nil
+}
+
+// statisticsBlurays returns the blurays matching the filter, oldest first like
+// the other backends. The caller must hold the lock.
+func (ds *MemoryDatastore) statisticsBlurays(filter models.BlurayFilter) ([]*models.Bluray, error) {
+	conditions := blurayConditions(filter)
+	var blurays []*models.Bluray
+	for _, bluray := range ds.blurays {
+		ok, err := matchesFilters(bluray, conditions)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			blurays = append(blurays, bluray)
+		}
+	}
+	return blurays, nil
+}
@@ -0,0 +1,155 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"regexp"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateTag(ctx context.Context, tag *models.Tag) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if err := ds.checkUniqueTag(tag); err != nil {
+		return err
+	}
+
+	tag.ID = primitive.NewObjectID()
+	tag.CreatedAt = time.Now()
+	tag.UpdatedAt = time.Now()
+	ds.tags = append(ds.tags, cloneDocument(tag))
+	return nil
+}
+
+// checkUniqueTag enforces the unique tag name index of each collection
+func (ds *MemoryDatastore) checkUniqueTag(tag *models.Tag) error {
+	for _, existing := range ds.tags {
+		if existing.ID != tag.ID && existing.CollectionID == tag.CollectionID && existing.Name == tag.Name {
+			return errors.New("duplicate key: collection_id, name")
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) findTag(match func(*models.Tag) bool) (*models.Tag, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, tag := range ds.tags {
+		if match(tag) {
+			return cloneDocument(tag), nil
+		}
+	}
+	return nil, errors.New("tag not found")
+}
+
+func (ds *MemoryDatastore) GetTagByID(ctx context.Context, id primitive.ObjectID) (*models.Tag, error) {
+	return ds.findTag(func(t *models.Tag) bool { return t.ID == id })
+}
+
+func (ds *MemoryDatastore) GetTagByName(ctx context.Context, collectionID primitive.ObjectID, name string) (*models.Tag, error) {
+	return ds.findTag(func(t *models.Tag) bool { return t.CollectionID == collectionID && t.Name == name })
+}
+
+func (ds *MemoryDatastore) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	tag.UpdatedAt = time.Now()
+	for i, existing := range ds.tags {
+		if existing.ID == tag.ID {
+			if err := ds.checkUniqueTag(tag); err != nil {
+				return err
+			}
+			ds.tags[i] = cloneDocument(tag)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) SetTagDeletedAt(ctx context.Context, id primitive.ObjectID, deletedAt *time.Time) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, tag := range ds.tags {
+		if tag.ID == id {
+			updated := *tag
+			updated.DeletedAt = deletedAt
+			ds.tags[i] = cloneDocument(&updated)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteTag(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, tag := range ds.tags {
+		if tag.ID == id {
+			ds.tags = append(ds.tags[:i], ds.tags[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) RemoveTagFromBlurays(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, bluray := range ds.blurays {
+		if !slices.Contains(bluray.Tags, id.Hex()) {
+			continue
+		}
+		updated := *bluray
+		updated.Tags = slices.DeleteFunc(slices.Clone(bluray.Tags), func(tag string) bool { return tag == id.Hex() })
+		ds.blurays[i] = cloneDocument(&updated)
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) ListTags(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Tag, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var tags []*models.Tag
+	for _, tag := range ds.tags {
+		if collectionID == nil || tag.CollectionID == *collectionID {
+			tags = append(tags, cloneDocument(tag))
+		}
+	}
+	return tags, nil
+}
+
+// SearchTagsByName searches for tags by name pattern (case-insensitive),
+// leaving out the tags in the trash
+func (ds *MemoryDatastore) SearchTagsByName(ctx context.Context, pattern string) ([]*models.Tag, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.searchTagsByName(pattern)
+}
+
+// searchTagsByName expects the caller to hold the lock
+func (ds *MemoryDatastore) searchTagsByName(pattern string) ([]*models.Tag, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []*models.Tag
+	for _, tag := range ds.tags {
+		if tag.DeletedAt == nil && re.MatchString(tag.Name) {
+			tags = append(tags, cloneDocument(tag))
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,24 @@
+package datastore
+
+import "testing"
+
+func TestPaginate(t *testing.T) {
+	one, two, three := 1, 2, 3
+	items := []*int{&one, &two, &three}
+	for _, tt := range []struct {
+		skip, limit int
+		want        int
+	}{
+		{0, 0, 3},
+		{-1, 0, 3},
+		{-1, 2, 2},
+		{1, 0, 2},
+		{2, 5, 1},
+		{3, 1, 0},
+		{10, 0, 0},
+	} {
+		if got := paginate(items, tt.skip, tt.limit); len(got) != tt.want {
+			t.Errorf("paginate(skip=%d, limit=%d) returned %d items, want %d", tt.skip, tt.limit, len(got), tt.want)
+		}
+	}
+}
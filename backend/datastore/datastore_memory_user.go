@@ -0,0 +1,125 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateUser(ctx context.Context, user *models.User) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if err := ds.checkUniqueUser(user); err != nil {
+		return err
+	}
+
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+	ds.users = append(ds.users, cloneDocument(user))
+	return nil
+}
+
+// checkUniqueUser enforces the unique email and username indexes
+func (ds *MemoryDatastore) checkUniqueUser(user *models.User) error {
+	for _, existing := range ds.users {
+		if existing.ID == user.ID {
+			continue
+		}
+		if existing.Email == user.Email {
+			return errors.New("duplicate key: email")
+		}
+		if existing.Username == user.Username {
+			return errors.New("duplicate key: username")
+		}
+		if existing.OIDC != nil && user.OIDC != nil && *existing.OIDC == *user.OIDC {
+			return errors.New("duplicate key: oidc")
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) findUser(match func(*models.User) bool) (*models.User, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, user := range ds.users {
+		if match(user) {
+			return cloneDocument(user), nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (ds *MemoryDatastore) GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return ds.findUser(func(u *models.User) bool { return u.ID == id })
+}
+
+func (ds *MemoryDatastore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return ds.findUser(func(u *models.User) bool { return u.Email == email })
+}
+
+func (ds *MemoryDatastore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return ds.findUser(func(u *models.User) bool { return u.Username == username })
+}
+
+func (ds *MemoryDatastore) GetUserByExternalIdentity(ctx context.Context, identity models.ExternalIdentity) (*models.User, error) {
+	return ds.findUser(func(u *models.User) bool { return u.OIDC != nil && *u.OIDC == identity })
+}
+
+func (ds *MemoryDatastore) UpdateUser(ctx context.Context, user *models.User) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	user.UpdatedAt = time.Now()
+	for i, existing := range ds.users {
+		if existing.ID == user.ID {
+			if err := ds.checkUniqueUser(user); err != nil {
+				return err
+			}
+			ds.users[i] = cloneDocument(user)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, user := range ds.users {
+		if user.ID == id {
+			ds.users = append(ds.users[:i], ds.users[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) ListUsers(ctx context.Context, skip, limit int) ([]*models.User, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var users []*models.User
+	for _, user := range paginate(ds.users, skip, limit) {
+		users = append(users, cloneDocument(user))
+	}
+	return users, nil
+}
+
+func (ds *MemoryDatastore) CountUsers(ctx context.Context) (int, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return len(ds.users), nil
+}
+
+// EnsureGuestUser creates a guest user if it doesn't exist, or migrates
+// an existing guest user's locale to the current format.
+func (ds *MemoryDatastore) EnsureGuestUser(ctx context.Context) (bool, error) {
+	return ensureGuestUser(ctx, ds)
+}
@@ -0,0 +1,130 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateWatchEvent(ctx context.Context, event *models.WatchEvent) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	event.ID = primitive.NewObjectID()
+	event.CreatedAt = time.Now()
+	event.UpdatedAt = time.Now()
+	ds.watchEvents = append(ds.watchEvents, cloneDocument(event))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetWatchEventByID(ctx context.Context, id primitive.ObjectID) (*models.WatchEvent, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, event := range ds.watchEvents {
+		if event.ID == id {
+			return cloneDocument(event), nil
+		}
+	}
+	return nil, errors.New("watch event not found")
+}
+
+func (ds *MemoryDatastore) UpdateWatchEvent(ctx context.Context, event *models.WatchEvent) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	event.UpdatedAt = time.Now()
+	for i, existing := range ds.watchEvents {
+		if existing.ID == event.ID {
+			ds.watchEvents[i] = cloneDocument(event)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteWatchEvent(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, event := range ds.watchEvents {
+		if event.ID == id {
+			ds.watchEvents = append(ds.watchEvents[:i], ds.watchEvents[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteBlurayWatchEvents(ctx context.Context, blurayID primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	kept := ds.watchEvents[:0]
+	for _, event := range ds.watchEvents {
+		if event.BlurayID != blurayID {
+			kept = append(kept, event)
+		}
+	}
+	ds.watchEvents = kept
+	return nil
+}
+
+func (ds *MemoryDatastore) ListWatchEvents(ctx context.Context, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID, skip, limit int) ([]*models.WatchEvent, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var matches []*models.WatchEvent
+	for _, event := range ds.watchEvents {
+		if watchEventMatches(event, userID, collectionID, blurayID) {
+			matches = append(matches, event)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].WatchedAt.After(matches[j].WatchedAt)
+	})
+
+	var events []*models.WatchEvent
+	for _, event := range paginate(matches, skip, limit) {
+		events = append(events, cloneDocument(event))
+	}
+	return events, nil
+}
+
+func (ds *MemoryDatastore) CountWatchEvents(ctx context.Context, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID) (int, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	count := 0
+	for _, event := range ds.watchEvents {
+		if watchEventMatches(event, userID, collectionID, blurayID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// watchEventMatches reports whether the event is in the history of the user
+func watchEventMatches(event *models.WatchEvent, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID) bool {
+	return event.UserID == userID && (collectionID == nil || event.CollectionID == *collectionID) &&
+		(blurayID == nil || event.BlurayID == *blurayID)
+}
+
+func (ds *MemoryDatastore) ListWatchedBlurayIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	seen := map[primitive.ObjectID]bool{}
+	var ids []primitive.ObjectID
+	for _, event := range ds.watchEvents {
+		if event.UserID == userID && !seen[event.BlurayID] {
+			seen[event.BlurayID] = true
+			ids = append(ids, event.BlurayID)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,83 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *MemoryDatastore) CreateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	item.ID = primitive.NewObjectID()
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = time.Now()
+	ds.wishlist = append(ds.wishlist, cloneDocument(item))
+	return nil
+}
+
+func (ds *MemoryDatastore) GetWishlistItemByID(ctx context.Context, id primitive.ObjectID) (*models.WishlistItem, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, item := range ds.wishlist {
+		if item.ID == id {
+			return cloneDocument(item), nil
+		}
+	}
+	return nil, errors.New("wishlist item not found")
+}
+
+func (ds *MemoryDatastore) UpdateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	item.UpdatedAt = time.Now()
+	for i, existing := range ds.wishlist {
+		if existing.ID == item.ID {
+			ds.wishlist[i] = cloneDocument(item)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) DeleteWishlistItem(ctx context.Context, id primitive.ObjectID) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i, item := range ds.wishlist {
+		if item.ID == id {
+			ds.wishlist = append(ds.wishlist[:i], ds.wishlist[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDatastore) ListWishlistItems(ctx context.Context, collectionID *primitive.ObjectID, acquired bool) ([]*models.WishlistItem, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var items []*models.WishlistItem
+	for _, item := range ds.wishlist {
+		if item.IsAcquired() == acquired && (collectionID == nil || item.CollectionID == *collectionID) {
+			items = append(items, cloneDocument(item))
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if acquired {
+			return items[i].AcquiredAt.After(*items[j].AcquiredAt)
+		}
+		if items[i].Priority != items[j].Priority {
+			return items[i].Priority > items[j].Priority
+		}
+		return items[i].CreatedAt.After(items[j].CreatedAt)
+	})
+	return items, nil
+}
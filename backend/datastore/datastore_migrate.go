@@ -0,0 +1,216 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrPendingMigrations is returned by CheckMigrations when the database
+// schema is older than this release expects
+var ErrPendingMigrations = errors.New("database has pending migrations")
+
+// migration is a single, ordered schema change of a datastore backend
+type migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context) error
+	Down        func(ctx context.Context) error
+}
+
+// MigrationStatus describes one migration and whether it has been applied
+type MigrationStatus struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	Applied     bool      `json:"applied"`
+	AppliedAt   time.Time `json:"applied_at,omitempty"`
+	// Unknown is set for versions recorded in the database that this
+	// release does not know about, i.e. applied by a newer release
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// Migrator is implemented by datastores whose schema is versioned
+type Migrator interface {
+	// MigrateUp applies up to steps pending migrations in order (all of them
+	// when steps <= 0) and returns the ones that were applied
+	MigrateUp(ctx context.Context, steps int) ([]MigrationStatus, error)
+	// MigrateDown reverts up to steps applied migrations, newest first (one
+	// when steps <= 0), and returns the ones that were reverted
+	MigrateDown(ctx context.Context, steps int) ([]MigrationStatus, error)
+	// MigrationStatus lists every known migration in order
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+}
+
+// migrationLog persists which migrations have been applied, in a
+// schema_migrations collection or table
+type migrationLog interface {
+	appliedMigrations(ctx context.Context) (map[int]MigrationStatus, error)
+	recordMigration(ctx context.Context, m migration) error
+	forgetMigration(ctx context.Context, version int) error
+}
+
+func migrationStatus(ctx context.Context, log migrationLog, migrations []migration) ([]MigrationStatus, error) {
+	applied, err := log.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		status := MigrationStatus{Version: m.Version, Description: m.Description}
+		if record, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	for version, record := range applied {
+		if !known[version] {
+			record.Applied = true
+			record.Unknown = true
+			statuses = append(statuses, record)
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+func runMigrationsUp(ctx context.Context, log migrationLog, migrations []migration, steps int) ([]MigrationStatus, error) {
+	applied, err := log.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []MigrationStatus{}
+	for _, m := range migrations {
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		if err := m.Up(ctx); err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		if err := log.recordMigration(ctx, m); err != nil {
+			return done, fmt.Errorf("recording migration %d: %w", m.Version, err)
+		}
+		done = append(done, MigrationStatus{Version: m.Version, Description: m.Description, Applied: true, AppliedAt: time.Now()})
+	}
+	return done, nil
+}
+
+func runMigrationsDown(ctx context.Context, log migrationLog, migrations []migration, steps int) ([]MigrationStatus, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	applied, err := log.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []MigrationStatus{}
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		if err := m.Down(ctx); err != nil {
+			return done, fmt.Errorf("reverting migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		if err := log.forgetMigration(ctx, m.Version); err != nil {
+			return done, fmt.Errorf("unrecording migration %d: %w", m.Version, err)
+		}
+		done = append(done, MigrationStatus{Version: m.Version, Description: m.Description})
+	}
+	return done, nil
+}
+
+// CheckMigrations returns an error wrapping ErrPendingMigrations when some
+// migrations have not been applied, or an error when the database was
+// migrated by a newer release than this one
+func CheckMigrations(ctx context.Context, m Migrator) error {
+	statuses, err := m.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		if status.Unknown {
+			return fmt.Errorf("database schema version %d is newer than this release", status.Version)
+		}
+		if !status.Applied {
+			pending = append(pending, fmt.Sprintf("%d (%s)", status.Version, status.Description))
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s", ErrPendingMigrations, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// PrepareSchema is run at startup. Datastores without a versioned schema are
+// left alone. A brand-new database (nothing recorded and no users yet) is
+// migrated straight to the latest version; an existing one is only migrated
+// when autoMigrate is set and must otherwise already be up to date.
+func PrepareSchema(ctx context.Context, ds Datastore, autoMigrate bool) ([]MigrationStatus, error) {
+	migrator, ok := ds.(Migrator)
+	if !ok {
+		return nil, nil
+	}
+
+	if !autoMigrate {
+		statuses, err := migrator.MigrationStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		autoMigrate = isUnversioned(statuses) && isEmpty(ctx, ds)
+	}
+
+	var applied []MigrationStatus
+	if autoMigrate {
+		var err error
+		if applied, err = migrator.MigrateUp(ctx, 0); err != nil {
+			return applied, err
+		}
+	}
+
+	return applied, CheckMigrations(ctx, migrator)
+}
+
+func isUnversioned(statuses []MigrationStatus) bool {
+	for _, status := range statuses {
+		if status.Applied {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmpty reports whether the datastore holds no users. Every install that
+// has been started once has at least the guest user. A store whose tables do
+// not exist yet cannot be listed and is empty as well.
+func isEmpty(ctx context.Context, ds Datastore) bool {
+	users, err := ds.ListUsers(ctx, 0, 1)
+	return err != nil || len(users) == 0
+}
+
+// duplicateTagError stops rolling collections back while tags of different
+// collections share a name, which would no longer be unique
+func duplicateTagError(name string) error {
+	return fmt.Errorf("tag %q is in more than one collection: rename or delete the extra tags before rolling back", name)
+}
@@ -0,0 +1,433 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeMigrationLog keeps applied migrations in memory
+type fakeMigrationLog map[int]MigrationStatus
+
+func (l fakeMigrationLog) appliedMigrations(ctx context.Context) (map[int]MigrationStatus, error) {
+	applied := make(map[int]MigrationStatus, len(l))
+	for version, status := range l {
+		applied[version] = status
+	}
+	return applied, nil
+}
+
+func (l fakeMigrationLog) recordMigration(ctx context.Context, m migration) error {
+	l[m.Version] = MigrationStatus{Version: m.Version, Description: m.Description, Applied: true}
+	return nil
+}
+
+func (l fakeMigrationLog) forgetMigration(ctx context.Context, version int) error {
+	delete(l, version)
+	return nil
+}
+
+func TestMigrationRunner(t *testing.T) {
+	ctx := context.Background()
+	log := fakeMigrationLog{}
+
+	var calls []string
+	step := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	migrations := []migration{
+		{Version: 1, Description: "one", Up: step("up 1"), Down: step("down 1")},
+		{Version: 2, Description: "two", Up: step("up 2"), Down: step("down 2")},
+		{Version: 3, Description: "three", Up: step("up 3"), Down: step("down 3")},
+	}
+
+	if _, err := runMigrationsUp(ctx, log, migrations, 2); err != nil {
+		t.Fatalf("runMigrationsUp: %v", err)
+	}
+	if _, err := runMigrationsUp(ctx, log, migrations, 0); err != nil {
+		t.Fatalf("runMigrationsUp: %v", err)
+	}
+	if _, err := runMigrationsDown(ctx, log, migrations, 0); err != nil {
+		t.Fatalf("runMigrationsDown: %v", err)
+	}
+
+	want := []string{"up 1", "up 2", "up 3", "down 3"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	statuses, err := migrationStatus(ctx, log, migrations)
+	if err != nil {
+		t.Fatalf("migrationStatus: %v", err)
+	}
+	applied := []bool{}
+	for _, status := range statuses {
+		applied = append(applied, status.Applied)
+	}
+	if !reflect.DeepEqual(applied, []bool{true, true, false}) {
+		t.Errorf("applied = %v, want [true true false]", applied)
+	}
+
+	// A failing migration stops the run and is not recorded
+	migrations[2].Up = func(context.Context) error { return errors.New("boom") }
+	if _, err := runMigrationsUp(ctx, log, migrations, 0); err == nil {
+		t.Error("runMigrationsUp succeeded with a failing migration")
+	}
+	if _, ok := log[3]; ok {
+		t.Error("failed migration was recorded")
+	}
+
+	// Versions recorded by a newer release are reported as unknown
+	log[4] = MigrationStatus{Version: 4, Description: "four", Applied: true}
+	statuses, _ = migrationStatus(ctx, log, migrations)
+	if last := statuses[len(statuses)-1]; last.Version != 4 || !last.Unknown {
+		t.Errorf("last status = %+v, want unknown version 4", last)
+	}
+}
+
+func TestPrepareSchemaSQLite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	ds, err := NewSQLiteDatastore(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLiteDatastore: %v", err)
+	}
+	defer ds.Close(ctx)
+
+	if err := CheckMigrations(ctx, ds); !errors.Is(err, ErrPendingMigrations) {
+		t.Fatalf("CheckMigrations on a new database = %v, want ErrPendingMigrations", err)
+	}
+
+	// A brand-new database is migrated on startup
+	applied, err := PrepareSchema(ctx, ds, false)
+	if err != nil {
+		t.Fatalf("PrepareSchema: %v", err)
+	}
+	if len(applied) != len(ds.migrations()) {
+		t.Errorf("PrepareSchema applied %d migrations, want %d", len(applied), len(ds.migrations()))
+	}
+	if err := ds.CreateUser(ctx, &models.User{Username: "admin", Email: "admin@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// An existing database with a pending migration is refused
+	if _, err := ds.MigrateDown(ctx, len(ds.migrations())); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if err := ds.execStatements(ctx, sqliteSchema...); err != nil {
+		t.Fatalf("recreating schema: %v", err)
+	}
+	if err := ds.CreateUser(ctx, &models.User{Username: "admin", Email: "admin@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := PrepareSchema(ctx, ds, false); !errors.Is(err, ErrPendingMigrations) {
+		t.Fatalf("PrepareSchema on an unmigrated database = %v, want ErrPendingMigrations", err)
+	}
+
+	// ... unless automatic migrations are enabled
+	if _, err := PrepareSchema(ctx, ds, true); err != nil {
+		t.Fatalf("PrepareSchema with autoMigrate: %v", err)
+	}
+	if _, err := ds.GetUserByEmail(ctx, "admin@example.com"); err != nil {
+		t.Errorf("GetUserByEmail after migrating: %v", err)
+	}
+}
+
+func TestPrepareSchemaMemory(t *testing.T) {
+	applied, err := PrepareSchema(context.Background(), NewMemoryDatastore(), false)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("PrepareSchema(memory) = %v, %v, want nothing to do", applied, err)
+	}
+}
+
+func TestSQLiteCopyMigration(t *testing.T) {
+	ctx := context.Background()
+	ds, err := NewSQLiteDatastore(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteDatastore: %v", err)
+	}
+	defer ds.Close(ctx)
+
+	if _, err := ds.MigrateUp(ctx, 4); err != nil {
+		t.Fatalf("MigrateUp to version 4: %v", err)
+	}
+
+	// A bluray as stored before it could have several copies
+	id := primitive.NewObjectID()
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO blurays (id, data, created_at) VALUES (?, ?, 0)`, id.Hex(),
+		`{"_id": {"$oid": "`+id.Hex()+`"}, "title": "Heat", "type": "movie", "purchase_price": 12.5, "format": "4k", "barcode": "5051889023586"}`)
+	if err != nil {
+		t.Fatalf("inserting legacy bluray: %v", err)
+	}
+
+	if _, err := ds.MigrateUp(ctx, 1); err != nil {
+		t.Fatalf("MigrateUp to version 5: %v", err)
+	}
+	bluray, err := ds.GetBlurayByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetBlurayByID: %v", err)
+	}
+	if len(bluray.Copies) != 1 || bluray.Copies[0].ID.IsZero() || bluray.Copies[0].PurchasePrice != 12.5 ||
+		bluray.Copies[0].Format != models.Format4K || bluray.Copies[0].Barcode != "5051889023586" {
+		t.Errorf("migrated copies = %+v, want the former purchase and release fields", bluray.Copies)
+	}
+
+	if _, err := ds.MigrateDown(ctx, 1); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	var data string
+	if err := ds.db.QueryRowContext(ctx, `SELECT data FROM blurays WHERE id = ?`, id.Hex()).Scan(&data); err != nil {
+		t.Fatalf("reading bluray: %v", err)
+	}
+	var doc bson.M
+	if err := unmarshalDocument(data, &doc); err != nil {
+		t.Fatalf("decoding bluray: %v", err)
+	}
+	if _, ok := doc["copies"]; ok || doc["purchase_price"] != 12.5 || doc["format"] != "4k" {
+		t.Errorf("reverted bluray = %v, want the fields of its first copy back", doc)
+	}
+}
+
+func TestSQLiteRatingMigration(t *testing.T) {
+	ctx := context.Background()
+	ds, err := NewSQLiteDatastore(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteDatastore: %v", err)
+	}
+	defer ds.Close(ctx)
+
+	if _, err := ds.MigrateUp(ctx, 7); err != nil {
+		t.Fatalf("MigrateUp to version 7: %v", err)
+	}
+
+	// Blurays as stored when they had a single shared rating
+	addedBy := primitive.NewObjectID()
+	rated := primitive.NewObjectID()
+	unrated := primitive.NewObjectID()
+	for id, rating := range map[primitive.ObjectID]string{rated: "8.5", unrated: "0"} {
+		_, err = ds.db.ExecContext(ctx, `INSERT INTO blurays (id, data, created_at) VALUES (?, ?, 0)`, id.Hex(),
+			`{"_id": {"$oid": "`+id.Hex()+`"}, "title": "Heat", "type": "movie", "rating": `+rating+`, "added_by": {"$oid": "`+addedBy.Hex()+`"}, "copies": []}`)
+		if err != nil {
+			t.Fatalf("inserting legacy bluray: %v", err)
+		}
+	}
+
+	if _, err := ds.MigrateUp(ctx, 1); err != nil {
+		t.Fatalf("MigrateUp to version 8: %v", err)
+	}
+	rating, err := ds.GetUserRating(ctx, addedBy, rated)
+	if err != nil || rating.Score != 8.5 {
+		t.Errorf("migrated rating = %+v, %v, want 8.5 by whoever added the bluray", rating, err)
+	}
+	if _, err := ds.GetUserRating(ctx, addedBy, unrated); err == nil {
+		t.Error("an unrated bluray got a rating")
+	}
+	var remaining int
+	if err := ds.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM blurays WHERE json_type(data, '$.rating') IS NOT NULL`).Scan(&remaining); err != nil {
+		t.Fatalf("counting shared ratings: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("%d blurays kept their shared rating, want none", remaining)
+	}
+
+	if _, err := ds.MigrateDown(ctx, 1); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	var data string
+	if err := ds.db.QueryRowContext(ctx, `SELECT data FROM blurays WHERE id = ?`, rated.Hex()).Scan(&data); err != nil {
+		t.Fatalf("reading bluray: %v", err)
+	}
+	var doc bson.M
+	if err := unmarshalDocument(data, &doc); err != nil {
+		t.Fatalf("decoding bluray: %v", err)
+	}
+	if doc["rating"] != 8.5 {
+		t.Errorf("reverted bluray = %v, want its rating back", doc)
+	}
+}
+
+func TestSQLiteCollectionMigration(t *testing.T) {
+	ctx := context.Background()
+	ds, err := NewSQLiteDatastore(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteDatastore: %v", err)
+	}
+	defer ds.Close(ctx)
+
+	if _, err := ds.MigrateUp(ctx, 8); err != nil {
+		t.Fatalf("MigrateUp to version 8: %v", err)
+	}
+
+	// Data as stored before collections existed
+	blurayID := primitive.NewObjectID()
+	tagID := primitive.NewObjectID()
+	userID := primitive.NewObjectID()
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO blurays (id, data, created_at) VALUES (?, ?, 0)`, []interface{}{blurayID.Hex(),
+			`{"_id": {"$oid": "` + blurayID.Hex() + `"}, "title": "Heat", "type": "movie", "copies": []}`}},
+		{`INSERT INTO tags (id, data) VALUES (?, ?)`, []interface{}{tagID.Hex(),
+			`{"_id": {"$oid": "` + tagID.Hex() + `"}, "name": "Noir"}`}},
+		{`INSERT INTO notifications (id, user_id, data, created_at) VALUES (?, ?, ?, 0)`, []interface{}{primitive.NewObjectID().Hex(), userID.Hex(),
+			`{"_id": {"$oid": "` + primitive.NewObjectID().Hex() + `"}, "user_id": {"$oid": "` + userID.Hex() + `"}, "message": "Heat"}`}},
+	}
+	for _, s := range statements {
+		if _, err := ds.db.ExecContext(ctx, s.query, s.args...); err != nil {
+			t.Fatalf("inserting legacy data: %v", err)
+		}
+	}
+
+	if _, err := ds.MigrateUp(ctx, 1); err != nil {
+		t.Fatalf("MigrateUp to version 9: %v", err)
+	}
+	collections, err := ds.ListCollections(ctx, nil)
+	if err != nil || len(collections) != 1 || !collections[0].IsDefault {
+		t.Fatalf("collections after migration = %+v, %v, want the default collection", collections, err)
+	}
+	def := collections[0].ID
+
+	bluray, err := ds.GetBlurayByID(ctx, blurayID)
+	if err != nil || bluray.CollectionID != def {
+		t.Errorf("migrated bluray = %+v, %v, want it in the default collection", bluray, err)
+	}
+	if _, err := ds.GetTagByName(ctx, def, "Noir"); err != nil {
+		t.Errorf("GetTagByName in the default collection: %v", err)
+	}
+	notifications, err := ds.GetUserNotifications(ctx, userID, &def, 0)
+	if err != nil || len(notifications) != 1 || notifications[0].CollectionID != def {
+		t.Errorf("migrated notifications = %+v, %v, want one in the default collection", notifications, err)
+	}
+
+	// Tag names are now only unique within a collection
+	other := &models.Tag{Name: "Noir", CollectionID: primitive.NewObjectID()}
+	if err := ds.CreateTag(ctx, other); err != nil {
+		t.Errorf("CreateTag in another collection: %v", err)
+	}
+	if err := ds.CreateTag(ctx, &models.Tag{Name: "Noir", CollectionID: def}); err == nil {
+		t.Error("CreateTag with a name taken in the default collection returned no error")
+	}
+
+	// Rolling back needs tag names to be unique again
+	if _, err := ds.MigrateDown(ctx, 1); err == nil || !strings.Contains(err.Error(), `"Noir"`) {
+		t.Fatalf("MigrateDown with the same tag name in two collections error = %v, want the tag named", err)
+	}
+	if bluray, err := ds.GetBlurayByID(ctx, blurayID); err != nil || bluray.CollectionID != def {
+		t.Errorf("bluray after a refused rollback = %+v, %v, want it left in the default collection", bluray, err)
+	}
+	if err := ds.DeleteTag(ctx, other.ID); err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	if _, err := ds.MigrateDown(ctx, 1); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	var scoped int
+	if err := ds.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM blurays WHERE json_type(data, '$.collection_id') IS NOT NULL`).Scan(&scoped); err != nil {
+		t.Fatalf("counting scoped blurays: %v", err)
+	}
+	if scoped != 0 {
+		t.Errorf("%d blurays kept their collection, want none", scoped)
+	}
+	if _, err := ds.db.ExecContext(ctx, `SELECT 1 FROM collections`); err == nil {
+		t.Error("the collections table survived the rollback")
+	}
+}
+
+func TestSQLiteSearchTermsMigration(t *testing.T) {
+	ctx := context.Background()
+	ds, err := NewSQLiteDatastore(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteDatastore: %v", err)
+	}
+	defer ds.Close(ctx)
+
+	if _, err := ds.MigrateUp(ctx, 16); err != nil {
+		t.Fatalf("MigrateUp to version 16: %v", err)
+	}
+
+	// A bluray stored before search terms were kept
+	id := primitive.NewObjectID()
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO blurays (id, data, created_at) VALUES (?, ?, 0)`, id.Hex(),
+		`{"_id": {"$oid": "`+id.Hex()+`"}, "title": "Amélie", "type": "movie", "description": {"fr-FR": "Une serveuse à Montmartre"}, "copies": []}`)
+	if err != nil {
+		t.Fatalf("inserting a legacy bluray: %v", err)
+	}
+
+	if _, err := ds.MigrateUp(ctx, 1); err != nil {
+		t.Fatalf("MigrateUp to version 17: %v", err)
+	}
+	bluray, err := ds.GetBlurayByID(ctx, id)
+	if err != nil || !reflect.DeepEqual(bluray.SearchTerms, []string{"a", "amelie", "montmartre", "serveuse", "une"}) {
+		t.Fatalf("migrated bluray = %+v, %v, want its search terms", bluray, err)
+	}
+	found, _, err := ds.PageSearchBlurays(ctx, "amelie", models.BlurayFilter{}, &BlurayPage{Field: models.SortAdded, Desc: true})
+	if err != nil || len(found) != 1 {
+		t.Errorf("SearchBlurays after the migration = %d blurays, %v, want the legacy bluray", len(found), err)
+	}
+
+	if _, err := ds.MigrateDown(ctx, 1); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if bluray, err = ds.GetBlurayByID(ctx, id); err != nil || bluray.SearchTerms != nil {
+		t.Errorf("reverted bluray = %+v, %v, want no search terms", bluray, err)
+	}
+}
+
+func TestSQLiteSortKeysMigration(t *testing.T) {
+	ctx := context.Background()
+	ds, err := NewSQLiteDatastore(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteDatastore: %v", err)
+	}
+	defer ds.Close(ctx)
+
+	if _, err := ds.MigrateUp(ctx, 18); err != nil {
+		t.Fatalf("MigrateUp to version 18: %v", err)
+	}
+
+	// Two blurays stored before sort keys were kept
+	cheap, dear := primitive.NewObjectID(), primitive.NewObjectID()
+	for _, legacy := range []struct {
+		id    primitive.ObjectID
+		title string
+		price string
+	}{{cheap, "Zodiac", "5"}, {dear, "Alien", "12.5"}} {
+		_, err = ds.db.ExecContext(ctx, `INSERT INTO blurays (id, data, created_at) VALUES (?, ?, 0)`, legacy.id.Hex(),
+			`{"_id": {"$oid": "`+legacy.id.Hex()+`"}, "title": "`+legacy.title+`", "type": "movie", "copies": [{"purchase_price": `+legacy.price+`}]}`)
+		if err != nil {
+			t.Fatalf("inserting a legacy bluray: %v", err)
+		}
+	}
+
+	if _, err := ds.MigrateUp(ctx, 1); err != nil {
+		t.Fatalf("MigrateUp to version 19: %v", err)
+	}
+	bluray, err := ds.GetBlurayByID(ctx, dear)
+	if err != nil || bluray.SortKeys != (models.BluraySortKeys{Title: "alien", Price: 12.5}) {
+		t.Fatalf("migrated bluray = %+v, %v, want its sort keys", bluray, err)
+	}
+	sorted, _, err := ds.PageBlurays(ctx, models.BlurayFilter{}, &BlurayPage{Field: models.SortPrice, Desc: true})
+	if err != nil || len(sorted) != 2 || sorted[0].ID != dear {
+		t.Errorf("PageBlurays by price after the migration = %d blurays, %v, want the dearest first", len(sorted), err)
+	}
+
+	if _, err := ds.MigrateDown(ctx, 1); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if bluray, err = ds.GetBlurayByID(ctx, dear); err != nil || bluray.SortKeys != (models.BluraySortKeys{}) {
+		t.Errorf("reverted bluray = %+v, %v, want no sort keys", bluray, err)
+	}
+}
@@ -3,6 +3,8 @@ package datastore
 import (
 	"context"
 
+	"eylexander/bluraymanager/models"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -13,10 +15,25 @@ type MongoDatastore struct {
 	db            *mongo.Database
 	users         *mongo.Collection
 	blurays       *mongo.Collection
+	collections   *mongo.Collection
+	roles         *mongo.Collection
 	tags          *mongo.Collection
 	notifications *mongo.Collection
+	loans         *mongo.Collection
+	locations     *mongo.Collection
+	wishlist      *mongo.Collection
+	watchEvents   *mongo.Collection
+	ratings       *mongo.Collection
+	sessions      *mongo.Collection
+	apiTokens     *mongo.Collection
+	savedSearches *mongo.Collection
+	settings      *mongo.Collection
+	auditLog      *mongo.Collection
+	revisions     *mongo.Collection
 }
 
+// NewMongoDatastore connects to the database. The schema is versioned, see
+// MigrateUp and PrepareSchema.
 func NewMongoDatastore(ctx context.Context, uri, dbName string) (*MongoDatastore, error) {
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
@@ -34,53 +51,31 @@ func NewMongoDatastore(ctx context.Context, uri, dbName string) (*MongoDatastore
 		db:            db,
 		users:         db.Collection("users"),
 		blurays:       db.Collection("blurays"),
+		collections:   db.Collection("collections"),
+		roles:         db.Collection("roles"),
 		tags:          db.Collection("tags"),
 		notifications: db.Collection("notifications"),
-	}
-
-	if err := ds.createIndexes(ctx); err != nil {
-		return nil, err
+		loans:         db.Collection("loans"),
+		locations:     db.Collection("locations"),
+		wishlist:      db.Collection("wishlist"),
+		watchEvents:   db.Collection("watch_events"),
+		ratings:       db.Collection("ratings"),
+		sessions:      db.Collection("sessions"),
+		apiTokens:     db.Collection("api_tokens"),
+		savedSearches: db.Collection("saved_searches"),
+		settings:      db.Collection("settings"),
+		auditLog:      db.Collection("audit_log"),
+		revisions:     db.Collection("bluray_revisions"),
 	}
 
 	return ds, nil
 }
 
-func (ds *MongoDatastore) createIndexes(ctx context.Context) error {
-	_, err := ds.users.Indexes().CreateMany(ctx, []mongo.IndexModel{
-		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
-		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
-	})
-	if err != nil {
-		return err
-	}
-
-	_, err = ds.blurays.Indexes().CreateMany(ctx, []mongo.IndexModel{
-		{Keys: bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}}},
-		{Keys: bson.D{{Key: "type", Value: 1}}},
-		{Keys: bson.D{{Key: "tags", Value: 1}}},
-		{Keys: bson.D{{Key: "genre", Value: 1}}},
-	})
-	if err != nil {
-		return err
-	}
-
-	_, err = ds.tags.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys:    bson.D{{Key: "name", Value: 1}},
-		Options: options.Index().SetUnique(true),
-	})
-	if err != nil {
-		return err
-	}
-
-	_, err = ds.notifications.Indexes().CreateMany(ctx, []mongo.IndexModel{
-		{Keys: bson.D{{Key: "user_id", Value: 1}}},
-		{Keys: bson.D{{Key: "created_at", Value: -1}}},
-		{Keys: bson.D{{Key: "read", Value: 1}}},
-	})
-
-	return err
-}
-
 func (ds *MongoDatastore) Close(ctx context.Context) error {
 	return ds.client.Disconnect(ctx)
 }
+
+// mongoFilter turns a bluray filter into a query document
+func mongoFilter(filter models.BlurayFilter) bson.M {
+	return bson.M(blurayConditions(filter))
+}
@@ -0,0 +1,67 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+	_, err := ds.apiTokens.InsertOne(ctx, token)
+	return err
+}
+
+func (ds *MongoDatastore) GetAPITokenByID(ctx context.Context, id primitive.ObjectID) (*models.APIToken, error) {
+	return ds.findAPIToken(ctx, bson.M{"_id": id})
+}
+
+func (ds *MongoDatastore) GetAPITokenByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	return ds.findAPIToken(ctx, bson.M{"token_hash": hash})
+}
+
+func (ds *MongoDatastore) findAPIToken(ctx context.Context, filter bson.M) (*models.APIToken, error) {
+	var token models.APIToken
+	err := ds.apiTokens.FindOne(ctx, filter).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("api token not found")
+	}
+	return &token, err
+}
+
+func (ds *MongoDatastore) ListUserAPITokens(ctx context.Context, userID primitive.ObjectID) ([]*models.APIToken, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := ds.apiTokens.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tokens []*models.APIToken
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (ds *MongoDatastore) TouchAPIToken(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	_, err := ds.apiTokens.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	return err
+}
+
+func (ds *MongoDatastore) DeleteAPIToken(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.apiTokens.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (ds *MongoDatastore) DeleteUserAPITokens(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := ds.apiTokens.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,74 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+	_, err := ds.auditLog.InsertOne(ctx, entry)
+	return err
+}
+
+func (ds *MongoDatastore) ListAuditEntries(ctx context.Context, filter models.AuditFilter, skip, limit int) ([]*models.AuditEntry, error) {
+	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := ds.auditLog.Find(ctx, auditQuery(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []*models.AuditEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (ds *MongoDatastore) CountAuditEntries(ctx context.Context, filter models.AuditFilter) (int, error) {
+	count, err := ds.auditLog.CountDocuments(ctx, auditQuery(filter))
+	return int(count), err
+}
+
+// auditQuery returns the query matching the entries of the filter
+func auditQuery(filter models.AuditFilter) bson.M {
+	query := bson.M{}
+	if filter.ActorID != nil {
+		query["actor_id"] = *filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.EntityType != "" {
+		query["entity_type"] = filter.EntityType
+	}
+	if filter.EntityID != "" {
+		query["entity_id"] = filter.EntityID
+	}
+	if filter.CollectionID != nil {
+		query["collection_id"] = *filter.CollectionID
+	}
+	if filter.Since != nil || filter.Until != nil {
+		createdAt := bson.M{}
+		if filter.Since != nil {
+			createdAt["$gte"] = *filter.Since
+		}
+		if filter.Until != nil {
+			createdAt["$lt"] = *filter.Until
+		}
+		query["created_at"] = createdAt
+	}
+	return query
+}
+
+func (ds *MongoDatastore) DeleteAuditEntriesBefore(ctx context.Context, before time.Time) error {
+	_, err := ds.auditLog.DeleteMany(ctx, bson.M{"created_at": bson.M{"$lt": before}})
+	return err
+}
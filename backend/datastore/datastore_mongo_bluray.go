@@ -4,20 +4,20 @@ import (
 	"context"
 	"errors"
 	"eylexander/bluraymanager/models"
-	"strconv"
-	"strings"
+	"regexp"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func (ds *MongoDatastore) CreateBluray(ctx context.Context, bluray *models.Bluray) error {
 	bluray.ID = primitive.NewObjectID()
 	bluray.CreatedAt = time.Now()
 	bluray.UpdatedAt = time.Now()
+	bluray.SearchTerms = searchTerms(bluray)
+	bluray.SortKeys = sortKeys(bluray)
 	_, err := ds.blurays.InsertOne(ctx, bluray)
 	return err
 }
@@ -33,6 +33,8 @@ func (ds *MongoDatastore) GetBlurayByID(ctx context.Context, id primitive.Object
 
 func (ds *MongoDatastore) UpdateBluray(ctx context.Context, bluray *models.Bluray) error {
 	bluray.UpdatedAt = time.Now()
+	bluray.SearchTerms = searchTerms(bluray)
+	bluray.SortKeys = sortKeys(bluray)
 
 	// Build update document excluding created_at to preserve original creation time
 	update := bson.M{
@@ -47,177 +49,382 @@ func (ds *MongoDatastore) UpdateBluray(ctx context.Context, bluray *models.Blura
 		"genre":           bluray.Genre,
 		"cover_image_url": bluray.CoverImageURL,
 		"backdrop_url":    bluray.BackdropURL,
-		"purchase_price":  bluray.PurchasePrice,
-		"purchase_date":   bluray.PurchaseDate,
 		"tags":            bluray.Tags,
-		"rating":          bluray.Rating,
 		"tmdb_id":         bluray.TMDBID,
+		"copies":          bluray.Copies,
+		"search_terms":    bluray.SearchTerms,
+		"sort_keys":       bluray.SortKeys,
 		"updated_at":      bluray.UpdatedAt,
 	}
 
-	_, err := ds.blurays.UpdateOne(ctx, bson.M{"_id": bluray.ID}, bson.M{"$set": update})
+	changes := bson.M{"$set": update}
+	if bluray.LocationID != nil {
+		update["location_id"] = bluray.LocationID
+	} else {
+		changes["$unset"] = bson.M{"location_id": ""}
+	}
+
+	_, err := ds.blurays.UpdateOne(ctx, bson.M{"_id": bluray.ID}, changes)
 	return err
 }
 
+func (ds *MongoDatastore) SetBlurayDeletedAt(ctx context.Context, id primitive.ObjectID, deletedAt *time.Time) error {
+	return setDeletedAt(ctx, ds.blurays, id, deletedAt)
+}
+
 func (ds *MongoDatastore) DeleteBluray(ctx context.Context, id primitive.ObjectID) error {
 	_, err := ds.blurays.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
 
-func (ds *MongoDatastore) ListBlurays(ctx context.Context, filters map[string]interface{}, skip, limit int) ([]*models.Bluray, error) {
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
+func (ds *MongoDatastore) PageBlurays(ctx context.Context, filter models.BlurayFilter, page *BlurayPage) ([]*models.Bluray, int, error) {
+	return mongoPage[models.Bluray](ctx, ds.blurays, mongoFilter(filter), page)
+}
 
-	cursor, err := ds.blurays.Find(ctx, filters, opts)
+func (ds *MongoDatastore) PageSearchBlurays(ctx context.Context, query string, filter models.BlurayFilter, page *BlurayPage) ([]*models.Bluray, int, error) {
+	match, root, lookup, err := ds.searchFilter(ctx, query, filter)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	blurays, total, err := mongoPage[models.Bluray](ctx, ds.blurays, match, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	if terms := positiveTerms(root); len(terms) > 0 {
+		scoreBlurays(blurays, terms, lookup.Tags)
+	}
+	return blurays, total, nil
+}
+
+// searchFilter parses a search query and translates it, on top of the
+// listing filters, into a filter
+func (ds *MongoDatastore) searchFilter(ctx context.Context, query string, filter models.BlurayFilter) (bson.M, *searchNode, *searchLookup, error) {
+	// Parse the query (e.g., "title:inception (tag:action OR year:>=2010)")
+	root, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The listing filters apply on top of the search
+	andConditions := []bson.M{mongoFilter(filter)}
+
+	lookup := &searchLookup{}
+	if root.has(nodeWords, "") {
+		if lookup.Vocabulary, err = ds.searchVocabulary(ctx, mongoConditions(andConditions)); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if root.has(nodeWords, "") || root.has(nodePhrase, "") {
+		if lookup.Tags, err = ds.ListTags(ctx, nil); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if root.has(nodeField, "rating") {
+		ratings, err := ds.ListUserRatings(ctx, nil)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		lookup.Ratings = ratingAverages(ratings)
+	}
+
+	condition, err := ds.searchCondition(ctx, root, lookup)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	andConditions = append(andConditions, condition)
+	return mongoConditions(andConditions), root, lookup, nil
+}
+
+// mongoSortKeys are the paths of the stored sort fields, with the value of
+// blurays without one
+var mongoSortKeys = map[models.SortField]struct {
+	path string
+	none interface{}
+}{
+	models.SortTitle:     {"$sort_keys.title", ""},
+	models.SortYear:      {"$release_year", 0},
+	models.SortPrice:     {"$sort_keys.price", 0},
+	models.SortPurchased: {"$sort_keys.purchased", 0},
+}
+
+// mongoPage finds a page of the blurays matching filter, in the order of the
+// page, and counts the matching blurays
+func mongoPage[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, page *BlurayPage) ([]*T, int, error) {
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cursor, err := collection.Aggregate(ctx, mongoPagePipeline(filter, page))
+	if err != nil {
+		return nil, 0, err
 	}
 	defer cursor.Close(ctx)
+	var documents []*T
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, 0, err
+	}
+	return documents, int(total), nil
+}
 
-	var blurays []*models.Bluray
-	if err := cursor.All(ctx, &blurays); err != nil {
-		return nil, err
+// mongoPagePipeline builds the aggregation finding the page of the blurays
+// matching filter
+func mongoPagePipeline(filter bson.M, page *BlurayPage) mongo.Pipeline {
+	// The fields blurays are sorted by in turn, with the value of the
+	// position the page starts after
+	var after SortPosition
+	if page.After != nil {
+		after = *page.After
 	}
+	type field struct {
+		name  string
+		desc  bool
+		after interface{}
+	}
+	var fields []field
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	if page.keyed() {
+		key := mongoSortKeys[page.Field]
+		value := bson.M{"$ifNull": bson.A{key.path, key.none}}
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{
+			"sort_missing": bson.M{"$eq": bson.A{value, key.none}},
+			"sort_value":   value,
+		}}})
+		var afterValue interface{} = after.Key.Number
+		if page.Field == models.SortTitle {
+			afterValue = after.Key.Text
+		}
+		fields = append(fields,
+			field{name: "sort_missing", after: after.Key.Missing},
+			field{name: "sort_value", desc: page.Desc, after: afterValue},
+		)
+	}
+	newest := page.newest()
+	fields = append(fields,
+		field{name: "created_at", desc: newest, after: time.UnixMilli(after.Created)},
+		field{name: "_id", desc: newest, after: after.ID},
+	)
 
-	return blurays, nil
-}
-
-func (ds *MongoDatastore) SearchBlurays(ctx context.Context, query string, skip, limit int) ([]*models.Bluray, error) {
-	// Parse search parameters (e.g., "title:inception tag:action")
-	filters := parseSearchQuery(query)
-
-	// Build the MongoDB filter
-	var filter bson.M
-	if len(filters) > 0 {
-		// Advanced search with parameters
-		andConditions := []bson.M{}
-
-		for _, f := range filters {
-			regexPattern := bson.M{"$regex": primitive.Regex{Pattern: f.Value, Options: "i"}}
-
-			switch f.Field {
-			case "title":
-				andConditions = append(andConditions, bson.M{"title": regexPattern})
-			case "director":
-				andConditions = append(andConditions, bson.M{"director": regexPattern})
-			case "tag":
-				// Search for tags by name first, then search blurays by tag IDs
-				matchingTags, err := ds.SearchTagsByName(ctx, f.Value)
-				if err == nil && len(matchingTags) > 0 {
-					tagIDs := make([]string, len(matchingTags))
-					for i, tag := range matchingTags {
-						tagIDs[i] = tag.ID.Hex()
-					}
-					andConditions = append(andConditions, bson.M{"tags": bson.M{"$in": tagIDs}})
-				} else {
-					// If no tags found, add an impossible condition to return no results
-					andConditions = append(andConditions, bson.M{"_id": primitive.NilObjectID})
-				}
-			case "genre":
-				andConditions = append(andConditions, bson.M{
-					"$or": []bson.M{
-						{"genre.en-US": regexPattern},
-						{"genre.fr-FR": regexPattern},
-					},
-				})
-			case "year":
-				if year, err := strconv.Atoi(f.Value); err == nil {
-					andConditions = append(andConditions, bson.M{"release_year": year})
-				}
-			case "type":
-				andConditions = append(andConditions, bson.M{"type": f.Value})
-			case "description":
-				andConditions = append(andConditions, bson.M{
-					"$or": []bson.M{
-						{"description.en-US": regexPattern},
-						{"description.fr-FR": regexPattern},
-					},
-				})
+	// The page starts with the blurays sorted after the position on the
+	// first field that differs from it
+	if page.After != nil {
+		clauses := make([]bson.M, len(fields))
+		for i, f := range fields {
+			clause := bson.M{}
+			for _, previous := range fields[:i] {
+				clause[previous.name] = previous.after
 			}
+			operator := "$gt"
+			if f.desc {
+				operator = "$lt"
+			}
+			clause[f.name] = bson.M{operator: f.after}
+			clauses[i] = clause
 		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": clauses}}})
+	}
 
-		if len(andConditions) > 0 {
-			filter = bson.M{"$and": andConditions}
-		} else {
-			filter = bson.M{}
+	order := bson.D{}
+	for _, f := range fields {
+		direction := 1
+		if f.desc {
+			direction = -1
 		}
-	} else {
-		// Simple search across all fields (backward compatibility)
-		regexPattern := bson.M{"$regex": primitive.Regex{Pattern: query, Options: "i"}}
-
-		// For simple search, also check if query matches any tag names
-		matchingTags, _ := ds.SearchTagsByName(ctx, query)
-		tagIDs := make([]string, 0)
-		if len(matchingTags) > 0 {
-			for _, tag := range matchingTags {
-				tagIDs = append(tagIDs, tag.ID.Hex())
+		order = append(order, bson.E{Key: f.name, Value: direction})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: order}})
+	if page.Skip > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: page.Skip}})
+	}
+	if page.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: page.Limit}})
+	}
+	if page.keyed() {
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.M{"sort_missing": 0, "sort_value": 0}}})
+	}
+	return pipeline
+}
+
+// matchNothing is a condition no bluray matches
+var matchNothing = bson.M{"_id": primitive.NilObjectID}
+
+// searchCondition translates a parsed search query into a filter
+func (ds *MongoDatastore) searchCondition(ctx context.Context, node *searchNode, lookup *searchLookup) (bson.M, error) {
+	switch node.Kind {
+	case nodeAnd, nodeOr:
+		conditions := make([]bson.M, len(node.Children))
+		for i, child := range node.Children {
+			condition, err := ds.searchCondition(ctx, child, lookup)
+			if err != nil {
+				return nil, err
 			}
+			conditions[i] = condition
 		}
-
-		orConditions := []bson.M{
+		if node.Kind == nodeOr {
+			return bson.M{"$or": conditions}, nil
+		}
+		return mongoConditions(conditions), nil
+	case nodeNot:
+		condition, err := ds.searchCondition(ctx, node.Children[0], lookup)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$nor": []bson.M{condition}}, nil
+	case nodeWords:
+		// Every word needs a matching search term or tag
+		conditions := make([]bson.M, len(node.Terms))
+		for i, term := range node.Terms {
+			// $in needs an array even when nothing matched
+			conditions[i] = bson.M{"$or": []bson.M{
+				{"search_terms": bson.M{"$in": append([]string{}, expandTerm(term, lookup.Vocabulary)...)}},
+				{"tags": bson.M{"$in": append([]string{}, matchingTagIDs(term, lookup.Tags)...)}},
+			}}
+		}
+		return mongoConditions(conditions), nil
+	case nodePhrase:
+		if len(node.Terms) == 0 {
+			return bson.M{}, nil
+		}
+		pattern := phrasePattern(node.Terms)
+		regexPattern := bson.M{"$regex": primitive.Regex{Pattern: pattern, Options: "i"}}
+		tagIDs := phraseTagIDs(regexp.MustCompile("(?i)"+pattern), lookup.Tags)
+		return bson.M{"$or": []bson.M{
 			{"title": regexPattern},
 			{"director": regexPattern},
 			{"genre.en-US": regexPattern},
 			{"genre.fr-FR": regexPattern},
 			{"description.en-US": regexPattern},
 			{"description.fr-FR": regexPattern},
-		}
+			{"tags": bson.M{"$in": append([]string{}, tagIDs...)}},
+		}}, nil
+	}
+	return ds.fieldCondition(ctx, node, lookup)
+}
 
-		// Add tag search if we found matching tags
-		if len(tagIDs) > 0 {
-			orConditions = append(orConditions, bson.M{"tags": bson.M{"$in": tagIDs}})
-		}
+// fieldCondition translates a field filter into a filter
+func (ds *MongoDatastore) fieldCondition(ctx context.Context, f *searchNode, lookup *searchLookup) (bson.M, error) {
+	regexPattern := bson.M{"$regex": primitive.Regex{Pattern: f.Value, Options: "i"}}
 
-		filter = bson.M{"$or": orConditions}
+	switch f.Field {
+	case "title":
+		return bson.M{"title": regexPattern}, nil
+	case "director":
+		return bson.M{"director": regexPattern}, nil
+	case "tag":
+		// Search for tags by name first, then search blurays by tag IDs
+		matchingTags, err := ds.SearchTagsByName(ctx, f.Value)
+		if err == nil && len(matchingTags) > 0 {
+			tagIDs := make([]string, len(matchingTags))
+			for i, tag := range matchingTags {
+				tagIDs[i] = tag.ID.Hex()
+			}
+			return bson.M{"tags": bson.M{"$in": tagIDs}}, nil
+		}
+		// If no tags found, add an impossible condition to return no results
+		return matchNothing, nil
+	case "genre":
+		return bson.M{
+			"$or": []bson.M{
+				{"genre.en-US": regexPattern},
+				{"genre.fr-FR": regexPattern},
+			},
+		}, nil
+	case "year":
+		return bson.M{"release_year": mongoRange(f.Range, func(v float64) interface{} { return v })}, nil
+	case "rating":
+		return bson.M{"_id": bson.M{"$in": ratedBlurayIDs(lookup.Ratings, f.Range)}}, nil
+	case "price":
+		return bson.M{"copies": bson.M{"$elemMatch": bson.M{
+			"purchase_price": mongoRange(f.Range, func(v float64) interface{} { return v }),
+		}}}, nil
+	case "purchased":
+		return bson.M{"copies": bson.M{"$elemMatch": bson.M{
+			"purchase_date": mongoRange(f.Range, func(v float64) interface{} { return searchTime(v) }),
+		}}}, nil
+	case "type":
+		return bson.M{"type": f.Value}, nil
+	case "format":
+		if format, ok := models.ParseReleaseFormat(f.Value); ok {
+			return bson.M{"copies.format": format}, nil
+		}
+		// Unknown formats cannot match anything
+		return matchNothing, nil
+	case "packaging":
+		if packaging, ok := models.ParsePackaging(f.Value); ok {
+			return bson.M{"copies.packaging": packaging}, nil
+		}
+		// Unknown packagings cannot match anything
+		return matchNothing, nil
+	case "edition":
+		return bson.M{"copies.edition": regexPattern}, nil
+	case "publisher":
+		return bson.M{"copies.publisher": regexPattern}, nil
+	case "region":
+		return bson.M{"copies.region_code": regexPattern}, nil
+	case "barcode":
+		return bson.M{"copies.barcode": f.Value}, nil
+	case "audio":
+		return bson.M{"copies.audio_tracks": regexPattern}, nil
+	case "subtitle":
+		return bson.M{"copies.subtitle_tracks": regexPattern}, nil
+	case "location":
+		// Match the location and everything stored inside it
+		locationIDs, err := ds.searchLocationIDs(ctx, f.Value)
+		if err == nil && len(locationIDs) > 0 {
+			return bson.M{"location_id": bson.M{"$in": locationIDs}}, nil
+		}
+		// If no locations found, add an impossible condition to return no results
+		return matchNothing, nil
+	case "description":
+		return bson.M{
+			"$or": []bson.M{
+				{"description.en-US": regexPattern},
+				{"description.fr-FR": regexPattern},
+			},
+		}, nil
 	}
+	return nil, unknownSearchField(f.Field)
+}
 
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
-	cursor, err := ds.blurays.Find(ctx, filter, opts)
-	if err != nil {
-		return nil, err
+// mongoRange returns the comparisons of a range, with bounds converted by value
+func mongoRange(r *searchRange, value func(float64) interface{}) bson.M {
+	condition := bson.M{"$gte": value(r.Min)}
+	if r.MinOpen {
+		condition = bson.M{"$gt": value(r.Min)}
 	}
-	defer cursor.Close(ctx)
-	var blurays []*models.Bluray
-	if err := cursor.All(ctx, &blurays); err != nil {
-		return nil, err
+	if r.HasMax {
+		if r.MaxOpen {
+			condition["$lt"] = value(r.Max)
+		} else {
+			condition["$lte"] = value(r.Max)
+		}
 	}
-	return blurays, nil
-}
-
-// SearchFilter represents a parsed search parameter
-type SearchFilter struct {
-	Field string
-	Value string
+	return condition
 }
 
-func parseSearchQuery(query string) []SearchFilter {
-	var filters []SearchFilter
-	words := strings.Fields(query)
-
-	for _, word := range words {
-		if strings.Contains(word, ":") {
-			parts := strings.SplitN(word, ":", 2)
-			if len(parts) == 2 && parts[1] != "" {
-				filters = append(filters, SearchFilter{
-					Field: strings.ToLower(parts[0]),
-					Value: parts[1],
-				})
-			}
-		}
+// mongoConditions joins search conditions with $and
+func mongoConditions(conditions []bson.M) bson.M {
+	if len(conditions) == 0 {
+		return bson.M{}
 	}
-
-	return filters
+	return bson.M{"$and": conditions}
 }
 
-func (ds *MongoDatastore) ListSimplifiedBlurays(ctx context.Context, filters map[string]interface{}, skip, limit int) ([]*models.SimplifiedBluray, error) {
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "created_at", Value: -1}})
-	cursor, err := ds.blurays.Find(ctx, filters, opts)
+// searchVocabulary returns the distinct search terms of the blurays matching filter
+func (ds *MongoDatastore) searchVocabulary(ctx context.Context, filter bson.M) ([]string, error) {
+	values, err := ds.blurays.Distinct(ctx, "search_terms", filter)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-	var blurays []*models.SimplifiedBluray
-	if err := cursor.All(ctx, &blurays); err != nil {
-		return nil, err
+	vocabulary := make([]string, 0, len(values))
+	for _, value := range values {
+		if term, ok := value.(string); ok {
+			vocabulary = append(vocabulary, term)
+		}
 	}
-	return blurays, nil
+	return vocabulary, nil
+}
+
+func (ds *MongoDatastore) PageSimplifiedBlurays(ctx context.Context, filter models.BlurayFilter, page *BlurayPage) ([]*models.SimplifiedBluray, int, error) {
+	return mongoPage[models.SimplifiedBluray](ctx, ds.blurays, mongoFilter(filter), page)
 }
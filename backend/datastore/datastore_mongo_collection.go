@@ -0,0 +1,93 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	collection.ID = primitive.NewObjectID()
+	collection.IsDefault = false
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = collection.CreatedAt
+	_, err := ds.collections.InsertOne(ctx, collection)
+	return err
+}
+
+func (ds *MongoDatastore) GetCollectionByID(ctx context.Context, id primitive.ObjectID) (*models.Collection, error) {
+	var collection models.Collection
+	err := ds.collections.FindOne(ctx, bson.M{"_id": id}).Decode(&collection)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("collection not found")
+	}
+	return &collection, err
+}
+
+func (ds *MongoDatastore) EnsureDefaultCollection(ctx context.Context) (*models.Collection, error) {
+	var collection models.Collection
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := ds.collections.FindOneAndUpdate(ctx,
+		bson.M{"is_default": true},
+		bson.M{"$setOnInsert": newDefaultCollection()},
+		opts,
+	).Decode(&collection)
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func (ds *MongoDatastore) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	collection.UpdatedAt = time.Now()
+	_, err := ds.collections.UpdateOne(ctx,
+		bson.M{"_id": collection.ID},
+		bson.M{"$set": bson.M{
+			"name":        collection.Name,
+			"description": collection.Description,
+			"members":     collection.Members,
+			"updated_at":  collection.UpdatedAt,
+		}},
+	)
+	return err
+}
+
+func (ds *MongoDatastore) DeleteCollection(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.collections.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (ds *MongoDatastore) ListCollections(ctx context.Context, memberID *primitive.ObjectID) ([]*models.Collection, error) {
+	filter := bson.M{}
+	if memberID != nil {
+		filter["members.user_id"] = *memberID
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := ds.collections.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var collections []*models.Collection
+	if err := cursor.All(ctx, &collections); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// mongoCollectionFilter matches the records of a collection, where the zero
+// ID stands for the records outside any collection
+func mongoCollectionFilter(id primitive.ObjectID) interface{} {
+	if id.IsZero() {
+		return bson.M{"$exists": false}
+	}
+	return id
+}
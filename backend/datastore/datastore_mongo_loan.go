@@ -0,0 +1,98 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	loan.ID = primitive.NewObjectID()
+	loan.CreatedAt = time.Now()
+	loan.UpdatedAt = time.Now()
+	_, err := ds.loans.InsertOne(ctx, loan)
+	return err
+}
+
+func (ds *MongoDatastore) GetLoanByID(ctx context.Context, id primitive.ObjectID) (*models.Loan, error) {
+	var loan models.Loan
+	err := ds.loans.FindOne(ctx, bson.M{"_id": id}).Decode(&loan)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("loan not found")
+	}
+	return &loan, err
+}
+
+func (ds *MongoDatastore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	loan.UpdatedAt = time.Now()
+	_, err := ds.loans.ReplaceOne(ctx, bson.M{"_id": loan.ID}, loan)
+	return err
+}
+
+func (ds *MongoDatastore) DeleteLoan(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.loans.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (ds *MongoDatastore) ListBlurayLoans(ctx context.Context, blurayID primitive.ObjectID) ([]*models.Loan, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "lent_at", Value: -1}})
+	return ds.findLoans(ctx, bson.M{"bluray_id": blurayID}, opts)
+}
+
+func (ds *MongoDatastore) ListActiveLoans(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Loan, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"returned_at": nil}}},
+		{{Key: "$lookup", Value: bson.M{"from": ds.blurays.Name(), "localField": "bluray_id", "foreignField": "_id", "as": "bluray"}}},
+		{{Key: "$unwind", Value: "$bluray"}},
+		{{Key: "$match", Value: bson.M{"bluray.deleted_at": bson.M{"$exists": false}}}},
+	}
+	if collectionID != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"bluray.collection_id": mongoCollectionFilter(*collectionID)}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "lent_at", Value: 1}}}})
+
+	cursor, err := ds.loans.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		models.Loan `bson:",inline"`
+		Bluray      struct {
+			Title        string             `bson:"title"`
+			CollectionID primitive.ObjectID `bson:"collection_id"`
+		} `bson:"bluray"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	loans := make([]*models.Loan, len(results))
+	for i := range results {
+		loans[i] = &results[i].Loan
+		loans[i].BlurayTitle = results[i].Bluray.Title
+		loans[i].CollectionID = results[i].Bluray.CollectionID
+	}
+	return loans, nil
+}
+
+func (ds *MongoDatastore) findLoans(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*models.Loan, error) {
+	cursor, err := ds.loans.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var loans []*models.Loan
+	if err := cursor.All(ctx, &loans); err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
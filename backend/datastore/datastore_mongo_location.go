@@ -0,0 +1,99 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateLocation(ctx context.Context, location *models.Location) error {
+	location.ID = primitive.NewObjectID()
+	location.CreatedAt = time.Now()
+	location.UpdatedAt = time.Now()
+	_, err := ds.locations.InsertOne(ctx, location)
+	return err
+}
+
+func (ds *MongoDatastore) GetLocationByID(ctx context.Context, id primitive.ObjectID) (*models.Location, error) {
+	var location models.Location
+	err := ds.locations.FindOne(ctx, bson.M{"_id": id}).Decode(&location)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("location not found")
+	}
+	return &location, err
+}
+
+func (ds *MongoDatastore) UpdateLocation(ctx context.Context, location *models.Location) error {
+	location.UpdatedAt = time.Now()
+	_, err := ds.locations.ReplaceOne(ctx, bson.M{"_id": location.ID}, location)
+	return err
+}
+
+func (ds *MongoDatastore) DeleteLocation(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.locations.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (ds *MongoDatastore) ListLocations(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Location, error) {
+	filter := bson.M{}
+	if collectionID != nil {
+		filter["collection_id"] = mongoCollectionFilter(*collectionID)
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := ds.locations.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var locations []*models.Location
+	if err := cursor.All(ctx, &locations); err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+func (ds *MongoDatastore) CountBluraysByLocation(ctx context.Context, collectionID *primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	match := bson.M{"location_id": bson.M{"$type": "objectId"}, "deleted_at": bson.M{"$exists": false}}
+	if collectionID != nil {
+		match["collection_id"] = mongoCollectionFilter(*collectionID)
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$location_id", "count": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := ds.blurays.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Count int                `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[primitive.ObjectID]int, len(results))
+	for _, result := range results {
+		counts[result.ID] = result.Count
+	}
+	return counts, nil
+}
+
+// searchLocationIDs resolves a location: search value, see matchLocationSubtree
+func (ds *MongoDatastore) searchLocationIDs(ctx context.Context, pattern string) ([]primitive.ObjectID, error) {
+	locations, err := ds.ListLocations(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return matchLocationSubtree(locations, pattern)
+}
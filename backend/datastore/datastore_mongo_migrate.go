@@ -0,0 +1,657 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoMigrationRecord is a document of the schema_migrations collection
+type mongoMigrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+func (ds *MongoDatastore) MigrateUp(ctx context.Context, steps int) ([]MigrationStatus, error) {
+	return runMigrationsUp(ctx, ds, ds.migrations(), steps)
+}
+
+func (ds *MongoDatastore) MigrateDown(ctx context.Context, steps int) ([]MigrationStatus, error) {
+	return runMigrationsDown(ctx, ds, ds.migrations(), steps)
+}
+
+func (ds *MongoDatastore) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return migrationStatus(ctx, ds, ds.migrations())
+}
+
+func (ds *MongoDatastore) appliedMigrations(ctx context.Context) (map[int]MigrationStatus, error) {
+	cursor, err := ds.db.Collection("schema_migrations").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []mongoMigrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]MigrationStatus, len(records))
+	for _, record := range records {
+		applied[record.Version] = MigrationStatus{
+			Version:     record.Version,
+			Description: record.Description,
+			Applied:     true,
+			AppliedAt:   record.AppliedAt,
+		}
+	}
+	return applied, nil
+}
+
+func (ds *MongoDatastore) recordMigration(ctx context.Context, m migration) error {
+	_, err := ds.db.Collection("schema_migrations").InsertOne(ctx, mongoMigrationRecord{
+		Version:     m.Version,
+		Description: m.Description,
+		AppliedAt:   time.Now(),
+	})
+	return err
+}
+
+func (ds *MongoDatastore) forgetMigration(ctx context.Context, version int) error {
+	_, err := ds.db.Collection("schema_migrations").DeleteOne(ctx, bson.M{"_id": version})
+	return err
+}
+
+// migrations lists the schema changes of the MongoDB collections. Versions
+// are never reused or reordered; append new migrations at the end.
+func (ds *MongoDatastore) migrations() []migration {
+	return []migration{
+		{
+			Version:     1,
+			Description: "create initial indexes",
+			Up:          ds.createIndexes,
+			Down: func(ctx context.Context) error {
+				if err := dropIndexes(ctx, ds.users, "email_1", "username_1"); err != nil {
+					return err
+				}
+				if err := dropIndexes(ctx, ds.blurays, "title_text_description_text", "type_1", "tags_1", "genre_1"); err != nil {
+					return err
+				}
+				if err := dropIndexes(ctx, ds.tags, "name_1"); err != nil {
+					return err
+				}
+				return dropIndexes(ctx, ds.notifications, "user_id_1", "created_at_-1", "read_1")
+			},
+		},
+		{
+			// Genres used to be stored as a plain string or a list of
+			// untranslated strings, which are kept as the English values
+			Version:     2,
+			Description: "store bluray genres as localized arrays",
+			Up: func(ctx context.Context) error {
+				_, err := ds.blurays.UpdateMany(ctx,
+					bson.M{"genre": bson.M{"$type": "array"}},
+					mongo.Pipeline{{{Key: "$set", Value: bson.M{"genre": bson.M{"en-US": "$genre"}}}}},
+				)
+				if err != nil {
+					return err
+				}
+				_, err = ds.blurays.UpdateMany(ctx,
+					bson.M{"genre": bson.M{"$type": "string"}},
+					mongo.Pipeline{{{Key: "$set", Value: bson.M{"genre": bson.M{"en-US": bson.A{"$genre"}}}}}},
+				)
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				_, err := ds.blurays.UpdateMany(ctx,
+					bson.M{"genre": bson.M{"$type": "object"}},
+					mongo.Pipeline{{{Key: "$set", Value: bson.M{"genre": bson.M{"$ifNull": bson.A{"$genre.en-US", bson.A{}}}}}}},
+				)
+				return err
+			},
+		},
+		{
+			Version:     3,
+			Description: "store bluray descriptions as localized text",
+			Up: func(ctx context.Context) error {
+				_, err := ds.blurays.UpdateMany(ctx,
+					bson.M{"description": bson.M{"$type": "string"}},
+					mongo.Pipeline{{{Key: "$set", Value: bson.M{"description": bson.M{"en-US": "$description"}}}}},
+				)
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				_, err := ds.blurays.UpdateMany(ctx,
+					bson.M{"description": bson.M{"$type": "object"}},
+					mongo.Pipeline{{{Key: "$set", Value: bson.M{"description": bson.M{"$ifNull": bson.A{"$description.en-US", ""}}}}}},
+				)
+				return err
+			},
+		},
+		{
+			Version:     4,
+			Description: "use BCP-47 tags for user languages",
+			Up: func(ctx context.Context) error {
+				return ds.renameLanguages(ctx, map[string]string{"en": "en-US", "fr": "fr-FR"})
+			},
+			Down: func(ctx context.Context) error {
+				return ds.renameLanguages(ctx, map[string]string{"en-US": "en", "fr-FR": "fr"})
+			},
+		},
+		{
+			Version:     5,
+			Description: "create loan indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.loans.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "bluray_id", Value: 1}, {Key: "lent_at", Value: -1}}},
+					{Keys: bson.D{{Key: "returned_at", Value: 1}}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return dropIndexes(ctx, ds.loans, "bluray_id_1_lent_at_-1", "returned_at_1")
+			},
+		},
+		{
+			Version:     6,
+			Description: "create location indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.locations.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys: bson.D{{Key: "parent_id", Value: 1}},
+				})
+				if err != nil {
+					return err
+				}
+				_, err = ds.blurays.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys: bson.D{{Key: "location_id", Value: 1}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				if err := dropIndexes(ctx, ds.locations, "parent_id_1"); err != nil {
+					return err
+				}
+				return dropIndexes(ctx, ds.blurays, "location_id_1")
+			},
+		},
+		{
+			Version:     7,
+			Description: "create release indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.blurays.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "format", Value: 1}}},
+					{Keys: bson.D{{Key: "barcode", Value: 1}}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return dropIndexes(ctx, ds.blurays, "format_1", "barcode_1")
+			},
+		},
+		{
+			// A bluray used to be a single owned disc; its purchase and
+			// release fields become its first copy
+			Version:     8,
+			Description: "move purchase and release fields into bluray copies",
+			Up: func(ctx context.Context) error {
+				err := ds.rewriteBlurays(ctx, bson.M{"copies": bson.M{"$exists": false}}, splitFirstCopy)
+				if err != nil {
+					return err
+				}
+				if err := dropIndexes(ctx, ds.blurays, "format_1", "barcode_1"); err != nil {
+					return err
+				}
+				_, err = ds.blurays.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "copies.format", Value: 1}}},
+					{Keys: bson.D{{Key: "copies.barcode", Value: 1}}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				err := ds.rewriteBlurays(ctx, bson.M{"copies": bson.M{"$exists": true}}, mergeFirstCopy)
+				if err != nil {
+					return err
+				}
+				if err := dropIndexes(ctx, ds.blurays, "copies.format_1", "copies.barcode_1"); err != nil {
+					return err
+				}
+				_, err = ds.blurays.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "format", Value: 1}}},
+					{Keys: bson.D{{Key: "barcode", Value: 1}}},
+				})
+				return err
+			},
+		},
+		{
+			Version:     9,
+			Description: "create wishlist indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.wishlist.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys: bson.D{{Key: "acquired_at", Value: 1}, {Key: "priority", Value: -1}, {Key: "created_at", Value: -1}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return dropIndexes(ctx, ds.wishlist, "acquired_at_1_priority_-1_created_at_-1")
+			},
+		},
+		{
+			Version:     10,
+			Description: "create watch event indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.watchEvents.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "watched_at", Value: -1}}},
+					{Keys: bson.D{{Key: "bluray_id", Value: 1}}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return dropIndexes(ctx, ds.watchEvents, "user_id_1_watched_at_-1", "bluray_id_1")
+			},
+		},
+		{
+			// Blurays used to have a single rating shared by everyone; it
+			// becomes the personal rating of whoever added the bluray
+			Version:     11,
+			Description: "move bluray ratings into personal ratings",
+			Up: func(ctx context.Context) error {
+				_, err := ds.ratings.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "bluray_id", Value: 1}},
+						Options: options.Index().SetUnique(true),
+					},
+					{Keys: bson.D{{Key: "bluray_id", Value: 1}}},
+				})
+				if err != nil {
+					return err
+				}
+				if err := ds.moveBlurayRatings(ctx); err != nil {
+					return err
+				}
+				_, err = ds.blurays.UpdateMany(ctx,
+					bson.M{"rating": bson.M{"$exists": true}},
+					bson.M{"$unset": bson.M{"rating": ""}},
+				)
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				cursor, err := ds.ratings.Find(ctx, bson.M{})
+				if err != nil {
+					return err
+				}
+				var ratings []*models.UserRating
+				if err := cursor.All(ctx, &ratings); err != nil {
+					return err
+				}
+				scores := make(map[ratingKey]float64, len(ratings))
+				for _, rating := range ratings {
+					scores[ratingKey{UserID: rating.UserID, BlurayID: rating.BlurayID}] = rating.Score
+				}
+
+				if err := ds.rewriteBlurays(ctx, bson.M{}, func(doc bson.M) { restoreBlurayRating(doc, scores) }); err != nil {
+					return err
+				}
+				return ds.ratings.Drop(ctx)
+			},
+		},
+		{
+			// Everything stored before collections existed goes into the
+			// default collection
+			Version:     12,
+			Description: "move existing data into a default collection",
+			Up: func(ctx context.Context) error {
+				_, err := ds.collections.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{
+						Keys: bson.D{{Key: "is_default", Value: 1}},
+						Options: options.Index().SetUnique(true).
+							SetPartialFilterExpression(bson.M{"is_default": true}),
+					},
+					{Keys: bson.D{{Key: "members.user_id", Value: 1}}},
+				})
+				if err != nil {
+					return err
+				}
+				collection, err := ds.EnsureDefaultCollection(ctx)
+				if err != nil {
+					return err
+				}
+
+				for _, records := range []*mongo.Collection{ds.blurays, ds.tags, ds.notifications, ds.locations, ds.wishlist, ds.watchEvents} {
+					_, err := records.UpdateMany(ctx,
+						bson.M{"collection_id": bson.M{"$exists": false}},
+						bson.M{"$set": bson.M{"collection_id": collection.ID}},
+					)
+					if err != nil {
+						return err
+					}
+				}
+
+				if err := dropIndexes(ctx, ds.tags, "name_1"); err != nil {
+					return err
+				}
+				_, err = ds.tags.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "collection_id", Value: 1}, {Key: "name", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				})
+				if err != nil {
+					return err
+				}
+				_, err = ds.blurays.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys: bson.D{{Key: "collection_id", Value: 1}, {Key: "created_at", Value: -1}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				// Tag names must be unique again once merged, which is
+				// checked before anything changes
+				cursor, err := ds.tags.Aggregate(ctx, mongo.Pipeline{
+					{{Key: "$group", Value: bson.M{"_id": "$name", "count": bson.M{"$sum": 1}}}},
+					{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+					{{Key: "$limit", Value: 1}},
+				})
+				if err != nil {
+					return err
+				}
+				var duplicates []struct {
+					Name string `bson:"_id"`
+				}
+				if err := cursor.All(ctx, &duplicates); err != nil {
+					return err
+				}
+				if len(duplicates) > 0 {
+					return duplicateTagError(duplicates[0].Name)
+				}
+
+				if err := dropIndexes(ctx, ds.blurays, "collection_id_1_created_at_-1"); err != nil {
+					return err
+				}
+				if err := dropIndexes(ctx, ds.tags, "collection_id_1_name_1"); err != nil {
+					return err
+				}
+				for _, records := range []*mongo.Collection{ds.blurays, ds.tags, ds.notifications, ds.locations, ds.wishlist, ds.watchEvents} {
+					_, err := records.UpdateMany(ctx,
+						bson.M{"collection_id": bson.M{"$exists": true}},
+						bson.M{"$unset": bson.M{"collection_id": ""}},
+					)
+					if err != nil {
+						return err
+					}
+				}
+				_, err = ds.tags.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "name", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				})
+				if err != nil {
+					return err
+				}
+				return ds.collections.Drop(ctx)
+			},
+		},
+		{
+			Version:     13,
+			Description: "add roles defined as permission sets",
+			Up: func(ctx context.Context) error {
+				_, err := ds.roles.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "name", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				})
+				if err != nil {
+					return err
+				}
+				return ds.EnsureDefaultRoles(ctx)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.roles.Drop(ctx)
+			},
+		},
+		{
+			Version:     14,
+			Description: "create session indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.sessions.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_used_at", Value: -1}}},
+					{Keys: bson.D{{Key: "expires_at", Value: 1}}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return ds.sessions.Drop(ctx)
+			},
+		},
+		{
+			Version:     15,
+			Description: "create personal API token indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.apiTokens.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+					{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return ds.apiTokens.Drop(ctx)
+			},
+		},
+		{
+			Version:     16,
+			Description: "index single sign-on identities of users",
+			Up: func(ctx context.Context) error {
+				_, err := ds.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "oidc.issuer", Value: 1}, {Key: "oidc.subject", Value: 1}},
+					Options: options.Index().SetName("oidc_identity").SetUnique(true).SetSparse(true),
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				_, err := ds.users.Indexes().DropOne(ctx, "oidc_identity")
+				return err
+			},
+		},
+		{
+			Version:     17,
+			Description: "create audit log indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.auditLog.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "created_at", Value: -1}}},
+					{Keys: bson.D{{Key: "entity_type", Value: 1}, {Key: "entity_id", Value: 1}, {Key: "created_at", Value: -1}}},
+					{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return ds.auditLog.Drop(ctx)
+			},
+		},
+		{
+			Version:     18,
+			Description: "create bluray revision indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "bluray_id", Value: 1}, {Key: "number", Value: -1}},
+					Options: options.Index().SetUnique(true),
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return ds.revisions.Drop(ctx)
+			},
+		},
+		{
+			// Full-text search matches the stored search terms, which
+			// replace the text index it never used
+			Version:     19,
+			Description: "store and index the search terms of blurays",
+			Up: func(ctx context.Context) error {
+				if err := ds.rewriteBlurays(ctx, bson.M{}, indexSearchTerms); err != nil {
+					return err
+				}
+				_, err := ds.blurays.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys: bson.D{{Key: "search_terms", Value: 1}},
+				})
+				if err != nil {
+					return err
+				}
+				return dropIndexes(ctx, ds.blurays, "title_text_description_text")
+			},
+			Down: func(ctx context.Context) error {
+				if err := dropIndexes(ctx, ds.blurays, "search_terms_1"); err != nil {
+					return err
+				}
+				_, err := ds.blurays.UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"search_terms": ""}})
+				if err != nil {
+					return err
+				}
+				_, err = ds.blurays.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys: bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+				})
+				return err
+			},
+		},
+		{
+			Version:     20,
+			Description: "create saved search indexes",
+			Up: func(ctx context.Context) error {
+				_, err := ds.savedSearches.Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}}},
+					{Keys: bson.D{{Key: "collection_id", Value: 1}, {Key: "created_at", Value: 1}}},
+				})
+				return err
+			},
+			Down: func(ctx context.Context) error {
+				return ds.savedSearches.Drop(ctx)
+			},
+		},
+		{
+			// Blurays are sorted and paged by the store
+			Version:     21,
+			Description: "store the sort keys of blurays",
+			Up: func(ctx context.Context) error {
+				return ds.rewriteBlurays(ctx, bson.M{}, indexSortKeys)
+			},
+			Down: func(ctx context.Context) error {
+				_, err := ds.blurays.UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"sort_keys": ""}})
+				return err
+			},
+		},
+	}
+}
+
+func (ds *MongoDatastore) createIndexes(ctx context.Context) error {
+	_, err := ds.users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = ds.blurays.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}}},
+		{Keys: bson.D{{Key: "type", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "genre", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = ds.tags.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = ds.notifications.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "read", Value: 1}}},
+	})
+
+	return err
+}
+
+// dropIndexes drops the named indexes, ignoring the ones that do not exist
+func dropIndexes(ctx context.Context, collection *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		_, err := collection.Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27) {
+			// NamespaceNotFound or IndexNotFound
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteBlurays replaces every bluray matching filter with its rewritten document
+func (ds *MongoDatastore) rewriteBlurays(ctx context.Context, filter bson.M, rewrite func(bson.M)) error {
+	cursor, err := ds.blurays.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		rewrite(doc)
+		if _, err := ds.blurays.ReplaceOne(ctx, bson.M{"_id": doc["_id"]}, doc); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// moveBlurayRatings copies the shared rating of every bluray into the ratings
+// collection. Ratings already copied are left as they are.
+func (ds *MongoDatastore) moveBlurayRatings(ctx context.Context) error {
+	cursor, err := ds.blurays.Find(ctx, bson.M{"rating": bson.M{"$gt": 0}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		rating, ok, err := legacyBlurayRating(doc)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if _, err := ds.ratings.InsertOne(ctx, rating); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (ds *MongoDatastore) renameLanguages(ctx context.Context, languages map[string]string) error {
+	for from, to := range languages {
+		_, err := ds.users.UpdateMany(ctx,
+			bson.M{"settings.language": from},
+			bson.M{"$set": bson.M{"settings.language": to}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
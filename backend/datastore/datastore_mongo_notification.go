@@ -18,9 +18,14 @@ func (ds *MongoDatastore) CreateNotification(ctx context.Context, notification *
 	return err
 }
 
-func (ds *MongoDatastore) GetUserNotifications(ctx context.Context, userID primitive.ObjectID, limit int) ([]*models.Notification, error) {
+func (ds *MongoDatastore) GetUserNotifications(ctx context.Context, userID primitive.ObjectID, collectionID *primitive.ObjectID, limit int) ([]*models.Notification, error) {
+	filter := bson.M{"user_id": userID}
+	if collectionID != nil {
+		filter["collection_id"] = bson.M{"$in": bson.A{*collectionID, nil}}
+	}
+
 	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
-	cursor, err := ds.notifications.Find(ctx, bson.M{"user_id": userID}, opts)
+	cursor, err := ds.notifications.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
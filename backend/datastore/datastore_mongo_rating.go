@@ -0,0 +1,65 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) SetUserRating(ctx context.Context, rating *models.UserRating) error {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return ds.ratings.FindOneAndUpdate(ctx,
+		bson.M{"user_id": rating.UserID, "bluray_id": rating.BlurayID},
+		bson.M{
+			"$set":         bson.M{"score": rating.Score, "review": rating.Review, "updated_at": now},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "created_at": now},
+		},
+		opts,
+	).Decode(rating)
+}
+
+func (ds *MongoDatastore) GetUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) (*models.UserRating, error) {
+	var rating models.UserRating
+	err := ds.ratings.FindOne(ctx, bson.M{"user_id": userID, "bluray_id": blurayID}).Decode(&rating)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("rating not found")
+	}
+	return &rating, err
+}
+
+func (ds *MongoDatastore) DeleteUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) error {
+	_, err := ds.ratings.DeleteOne(ctx, bson.M{"user_id": userID, "bluray_id": blurayID})
+	return err
+}
+
+func (ds *MongoDatastore) DeleteBlurayRatings(ctx context.Context, blurayID primitive.ObjectID) error {
+	_, err := ds.ratings.DeleteMany(ctx, bson.M{"bluray_id": blurayID})
+	return err
+}
+
+func (ds *MongoDatastore) ListUserRatings(ctx context.Context, userID *primitive.ObjectID) ([]*models.UserRating, error) {
+	filter := bson.M{}
+	if userID != nil {
+		filter["user_id"] = *userID
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	cursor, err := ds.ratings.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var ratings []*models.UserRating
+	if err := cursor.All(ctx, &ratings); err != nil {
+		return nil, err
+	}
+	return ratings, nil
+}
@@ -0,0 +1,63 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateBlurayRevision(ctx context.Context, revision *models.BlurayRevision) error {
+	var last models.BlurayRevision
+	opts := options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}})
+	err := ds.revisions.FindOne(ctx, bson.M{"bluray_id": revision.BlurayID}, opts).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	// The unique index on the number turns a concurrent save into an error
+	revision.ID = primitive.NewObjectID()
+	revision.Number = last.Number + 1
+	revision.CreatedAt = time.Now()
+	_, err = ds.revisions.InsertOne(ctx, revision)
+	return err
+}
+
+func (ds *MongoDatastore) GetBlurayRevision(ctx context.Context, blurayID primitive.ObjectID, number int) (*models.BlurayRevision, error) {
+	var revision models.BlurayRevision
+	err := ds.revisions.FindOne(ctx, bson.M{"bluray_id": blurayID, "number": number}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("revision not found")
+	}
+	return &revision, err
+}
+
+func (ds *MongoDatastore) ListBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID, skip, limit int) ([]*models.BlurayRevision, error) {
+	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "number", Value: -1}})
+	cursor, err := ds.revisions.Find(ctx, bson.M{"bluray_id": blurayID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var revisions []*models.BlurayRevision
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (ds *MongoDatastore) CountBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID) (int, error) {
+	count, err := ds.revisions.CountDocuments(ctx, bson.M{"bluray_id": blurayID})
+	return int(count), err
+}
+
+func (ds *MongoDatastore) DeleteBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID) error {
+	_, err := ds.revisions.DeleteMany(ctx, bson.M{"bluray_id": blurayID})
+	return err
+}
@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateRole(ctx context.Context, role *models.Role) error {
+	role.ID = primitive.NewObjectID()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = role.CreatedAt
+	_, err := ds.roles.InsertOne(ctx, role)
+	return err
+}
+
+func (ds *MongoDatastore) GetRoleByName(ctx context.Context, name models.UserRole) (*models.Role, error) {
+	var role models.Role
+	err := ds.roles.FindOne(ctx, bson.M{"name": name}).Decode(&role)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("role not found")
+	}
+	return &role, err
+}
+
+func (ds *MongoDatastore) UpdateRole(ctx context.Context, role *models.Role) error {
+	role.UpdatedAt = time.Now()
+	_, err := ds.roles.UpdateOne(ctx,
+		bson.M{"name": role.Name},
+		bson.M{"$set": bson.M{
+			"description": role.Description,
+			"permissions": role.Permissions,
+			"updated_at":  role.UpdatedAt,
+		}},
+	)
+	return err
+}
+
+func (ds *MongoDatastore) DeleteRole(ctx context.Context, name models.UserRole) error {
+	_, err := ds.roles.DeleteOne(ctx, bson.M{"name": name})
+	return err
+}
+
+func (ds *MongoDatastore) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := ds.roles.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var roles []*models.Role
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (ds *MongoDatastore) EnsureDefaultRoles(ctx context.Context) error {
+	// An existing role keeps the permissions admins gave it
+	for _, role := range models.DefaultRoles() {
+		role.ID = primitive.NewObjectID()
+		role.CreatedAt = time.Now()
+		role.UpdatedAt = role.CreatedAt
+		_, err := ds.roles.UpdateOne(ctx,
+			bson.M{"name": role.Name},
+			bson.M{"$setOnInsert": role},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	search.ID = primitive.NewObjectID()
+	search.CreatedAt = time.Now()
+	search.UpdatedAt = time.Now()
+	_, err := ds.savedSearches.InsertOne(ctx, search)
+	return err
+}
+
+func (ds *MongoDatastore) GetSavedSearchByID(ctx context.Context, id primitive.ObjectID) (*models.SavedSearch, error) {
+	var search models.SavedSearch
+	err := ds.savedSearches.FindOne(ctx, bson.M{"_id": id}).Decode(&search)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("saved search not found")
+	}
+	return &search, err
+}
+
+func (ds *MongoDatastore) UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	search.UpdatedAt = time.Now()
+	_, err := ds.savedSearches.ReplaceOne(ctx, bson.M{"_id": search.ID}, search)
+	return err
+}
+
+func (ds *MongoDatastore) DeleteSavedSearch(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.savedSearches.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (ds *MongoDatastore) ListSavedSearches(ctx context.Context, userID primitive.ObjectID, collectionID *primitive.ObjectID) ([]*models.SavedSearch, error) {
+	conditions := []bson.M{{"shared": false, "user_id": userID}}
+	if collectionID != nil {
+		conditions = append(conditions, bson.M{"shared": true, "collection_id": *collectionID})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := ds.savedSearches.Find(ctx, bson.M{"$or": conditions}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var searches []*models.SavedSearch
+	if err := cursor.All(ctx, &searches); err != nil {
+		return nil, err
+	}
+	return searches, nil
+}
+
+func (ds *MongoDatastore) DeleteUserSavedSearches(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := ds.savedSearches.DeleteMany(ctx, bson.M{"shared": false, "user_id": userID})
+	return err
+}
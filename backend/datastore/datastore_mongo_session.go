@@ -0,0 +1,69 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateSession(ctx context.Context, session *models.Session) error {
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	_, err := ds.sessions.InsertOne(ctx, session)
+	return err
+}
+
+func (ds *MongoDatastore) GetSessionByID(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	var session models.Session
+	err := ds.sessions.FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("session not found")
+	}
+	return &session, err
+}
+
+func (ds *MongoDatastore) UpdateSession(ctx context.Context, session *models.Session, refreshTokenHash string) error {
+	result, err := ds.sessions.ReplaceOne(ctx, bson.M{"_id": session.ID, "refresh_token_hash": refreshTokenHash}, session)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSessionChanged
+	}
+	return nil
+}
+
+func (ds *MongoDatastore) ListUserSessions(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}, {Key: "_id", Value: 1}})
+	cursor, err := ds.sessions.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []*models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (ds *MongoDatastore) RevokeUserSessions(ctx context.Context, userID, except primitive.ObjectID) error {
+	_, err := ds.sessions.UpdateMany(ctx,
+		bson.M{"user_id": userID, "_id": bson.M{"$ne": except}, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
+
+func (ds *MongoDatastore) DeleteExpiredSessions(ctx context.Context, before time.Time) error {
+	_, err := ds.sessions.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$lt": before}})
+	return err
+}
@@ -0,0 +1,34 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoSettingsID is the _id of the single document of the settings
+// collection
+const mongoSettingsID = "settings"
+
+func (ds *MongoDatastore) GetSettings(ctx context.Context) (*models.Settings, error) {
+	var settings models.Settings
+	err := ds.settings.FindOne(ctx, bson.M{"_id": mongoSettingsID}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return &models.Settings{}, nil
+	}
+	return &settings, err
+}
+
+func (ds *MongoDatastore) UpdateSettings(ctx context.Context, settings *models.Settings) error {
+	settings.UpdatedAt = time.Now()
+	_, err := ds.settings.UpdateOne(ctx,
+		bson.M{"_id": mongoSettingsID},
+		bson.M{"$set": settings},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
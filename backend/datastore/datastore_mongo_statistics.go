@@ -8,35 +8,76 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func (ds *MongoDatastore) GetStatistics(ctx context.Context) (*models.Statistics, error) {
+func (ds *MongoDatastore) GetStatistics(ctx context.Context, filter models.BlurayFilter) (*models.Statistics, error) {
 	stats := &models.Statistics{
 		GenreDistribution: make(map[string]int),
 		TagDistribution:   make(map[string]int),
 		TopRated:          []models.BlurayStats{},
 	}
 
+	// Titles stored before copies existed count as a single copy
+	copyCount := bson.M{"$max": bson.A{1, bson.M{"$size": bson.M{"$ifNull": bson.A{"$copies", bson.A{}}}}}}
+
 	pipeline := []bson.M{
+		{"$match": mongoFilter(filter)},
 		{
 			"$addFields": bson.M{
+				"copyCount":   copyCount,
 				"seasonCount": bson.M{"$size": bson.M{"$ifNull": bson.A{"$seasons", bson.A{}}}},
 				"seriesPhysicalCount": bson.M{
 					"$cond": bson.A{
 						bson.M{"$eq": bson.A{"$type", "series"}},
-						bson.M{"$max": bson.A{1, bson.M{"$size": bson.M{"$ifNull": bson.A{"$seasons", bson.A{}}}}}},
+						bson.M{"$multiply": bson.A{
+							bson.M{"$max": bson.A{1, bson.M{"$size": bson.M{"$ifNull": bson.A{"$seasons", bson.A{}}}}}},
+							copyCount,
+						}},
 						0,
 					},
 				},
 				"moviePhysicalCount": bson.M{
 					"$cond": bson.A{
 						bson.M{"$eq": bson.A{"$type", "movie"}},
-						1,
+						copyCount,
 						0,
 					},
 				},
-				"totalSeriesEpisodes": bson.M{
-					"$sum": "$seasons.episode_count",
+				// Episode lists, when known, take precedence over the counts
+				"totalSeriesEpisodes": bson.M{"$sum": bson.M{"$map": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$seasons", bson.A{}}},
+					"as":    "season",
+					"in": bson.M{"$cond": bson.A{
+						bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$$season.episodes", bson.A{}}}}, 0}},
+						bson.M{"$size": "$$season.episodes"},
+						bson.M{"$ifNull": bson.A{"$$season.episode_count", 0}},
+					}},
+				}}},
+				// Episode runtimes, when known, take precedence over the runtime
+				"totalRuntime": bson.M{"$let": bson.M{
+					"vars": bson.M{"episodes": bson.M{"$sum": bson.M{"$map": bson.M{
+						"input": bson.M{"$ifNull": bson.A{"$seasons", bson.A{}}},
+						"as":    "season",
+						"in":    bson.M{"$sum": "$$season.episodes.runtime"},
+					}}}},
+					"in": bson.M{"$cond": bson.A{
+						bson.M{"$gt": bson.A{"$$episodes", 0}},
+						"$$episodes",
+						bson.M{"$ifNull": bson.A{"$runtime", 0}},
+					}},
+				}},
+				// Copies without a purchase price are counted at a flat estimate
+				"spent": bson.M{
+					"$cond": bson.A{
+						bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$copies", bson.A{}}}}, 0}},
+						bson.M{"$sum": bson.M{"$map": bson.M{
+							"input": "$copies",
+							"as":    "copy",
+							"in":    bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$$copy.purchase_price", 0}}, "$$copy.purchase_price", 4}},
+						}}},
+						4,
+					},
 				},
-				"genres": "$genre.en-US",
+				"maxPrice": bson.M{"$ifNull": bson.A{bson.M{"$max": "$copies.purchase_price"}, 0}},
+				"genres":   "$genre.en-US",
 			},
 		},
 		{
@@ -45,14 +86,13 @@ func (ds *MongoDatastore) GetStatistics(ctx context.Context) (*models.Statistics
 					bson.M{"$group": bson.M{
 						"_id":           nil,
 						"totalBlurays":  bson.M{"$sum": bson.M{"$add": bson.A{"$seriesPhysicalCount", "$moviePhysicalCount"}}},
+						"totalCopies":   bson.M{"$sum": "$copyCount"},
 						"totalMovies":   bson.M{"$sum": "$moviePhysicalCount"},
 						"totalSeries":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$type", "series"}}, 1, 0}}},
 						"totalSeasons":  bson.M{"$sum": "$seriesPhysicalCount"},
 						"totalEpisodes": bson.M{"$sum": "$totalSeriesEpisodes"},
-						"totalSpent":    bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$purchase_price", 0}}, "$purchase_price", 4}}},
-						"totalRating":   bson.M{"$sum": "$rating"},
-						"ratingCount":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$rating", 0}}, 1, 0}}},
-						"totalRuntime":  bson.M{"$sum": "$runtime"}, // Only movies have runtime field usually populated at root, check model
+						"totalSpent":    bson.M{"$sum": "$spent"},
+						"totalRuntime":  bson.M{"$sum": "$totalRuntime"},
 						"seriesFactor":  bson.M{"$sum": "$seriesPhysicalCount"},
 						"movieFactor":   bson.M{"$sum": "$moviePhysicalCount"},
 					}},
@@ -78,15 +118,9 @@ func (ds *MongoDatastore) GetStatistics(ctx context.Context) (*models.Statistics
 					bson.M{"$project": bson.M{"_id": 1, "title": 1, "type": 1, "release_year": 1, "purchase_date": 1}},
 				},
 				"mostExpensive": bson.A{
-					bson.M{"$sort": bson.M{"purchase_price": -1}},
+					bson.M{"$sort": bson.M{"maxPrice": -1}},
 					bson.M{"$limit": 1},
-					bson.M{"$project": bson.M{"_id": 1, "title": 1, "type": 1, "purchase_price": 1}},
-				},
-				"topRated": bson.A{
-					bson.M{"$match": bson.M{"rating": bson.M{"$gt": 0}}},
-					bson.M{"$sort": bson.M{"rating": -1}},
-					bson.M{"$limit": 10},
-					bson.M{"$project": bson.M{"_id": 1, "title": 1, "type": 1, "rating": 1}},
+					bson.M{"$project": bson.M{"_id": 1, "title": 1, "type": 1, "purchase_price": "$maxPrice"}},
 				},
 			},
 		},
@@ -105,13 +139,12 @@ func (ds *MongoDatastore) GetStatistics(ctx context.Context) (*models.Statistics
 	var result struct {
 		Counts []struct {
 			TotalBlurays  int     `bson:"totalBlurays"`
+			TotalCopies   int     `bson:"totalCopies"`
 			TotalMovies   int     `bson:"totalMovies"`
 			TotalSeries   int     `bson:"totalSeries"`
 			TotalSeasons  int     `bson:"totalSeasons"`
 			TotalEpisodes int     `bson:"totalEpisodes"`
 			TotalSpent    float64 `bson:"totalSpent"`
-			TotalRating   float64 `bson:"totalRating"`
-			RatingCount   int     `bson:"ratingCount"`
 			TotalRuntime  int     `bson:"totalRuntime"`
 			SeriesFactor  int     `bson:"seriesFactor"`
 			MovieFactor   int     `bson:"movieFactor"`
@@ -142,12 +175,6 @@ func (ds *MongoDatastore) GetStatistics(ctx context.Context) (*models.Statistics
 			Type          string             `bson:"type"`
 			PurchasePrice float64            `bson:"purchase_price"`
 		} `bson:"mostExpensive"`
-		TopRated []struct {
-			ID     primitive.ObjectID `bson:"_id"`
-			Title  string             `bson:"title"`
-			Type   string             `bson:"type"`
-			Rating float64            `bson:"rating"`
-		} `bson:"topRated"`
 	}
 
 	if err := cursor.Decode(&result); err != nil {
@@ -158,6 +185,7 @@ func (ds *MongoDatastore) GetStatistics(ctx context.Context) (*models.Statistics
 	if len(result.Counts) > 0 {
 		c := result.Counts[0]
 		stats.TotalBlurays = c.TotalBlurays
+		stats.TotalCopies = c.TotalCopies
 		stats.TotalMovies = c.TotalMovies
 		stats.TotalSeries = c.TotalSeries
 		stats.TotalSeasons = c.TotalSeasons
@@ -166,10 +194,7 @@ func (ds *MongoDatastore) GetStatistics(ctx context.Context) (*models.Statistics
 		stats.TotalRuntimeMinutes = c.TotalRuntime
 
 		if c.TotalBlurays > 0 {
-			stats.AveragePrice = c.TotalSpent / float64(c.TotalMovies+c.TotalSeries)
-		}
-		if c.RatingCount > 0 {
-			stats.AverageRating = c.TotalRating / float64(c.RatingCount)
+			stats.AveragePrice = c.TotalSpent / float64(c.TotalCopies)
 		}
 
 		// Calculate storage and volume
@@ -208,50 +233,19 @@ func (ds *MongoDatastore) GetStatistics(ctx context.Context) (*models.Statistics
 			PurchasePrice: result.MostExpensive[0].PurchasePrice,
 		}
 	}
-	for _, b := range result.TopRated {
-		stats.TopRated = append(stats.TopRated, models.BlurayStats{
-			ID:     b.ID.Hex(),
-			Title:  b.Title,
-			Type:   b.Type,
-			Rating: b.Rating,
-		})
-	}
 
 	return stats, nil
 }
 
-func (ds *MongoDatastore) GetSimplifiedStatistics(ctx context.Context) (*models.SimplifiedStatistics, error) {
-	stats := &models.SimplifiedStatistics{}
-
-	movieCount, _ := ds.blurays.CountDocuments(ctx, bson.M{"type": "movie"})
-	seriesCount, _ := ds.blurays.CountDocuments(ctx, bson.M{"type": "series"})
-	stats.TotalMovies = int(movieCount)
-	stats.TotalSeries = int(seriesCount)
-
-	cursor, err := ds.blurays.Find(ctx, bson.M{})
+func (ds *MongoDatastore) GetSimplifiedStatistics(ctx context.Context, filter models.BlurayFilter) (*models.SimplifiedStatistics, error) {
+	cursor, err := ds.blurays.Find(ctx, mongoFilter(filter))
 	if err != nil {
-		return stats, nil
+		return &models.SimplifiedStatistics{}, nil
 	}
 	defer cursor.Close(ctx)
 
 	var blurays []*models.Bluray
 	cursor.All(ctx, &blurays)
 
-	var physicalBlurayCount int
-	for _, b := range blurays {
-		if b.Type == models.MediaTypeSeries {
-			seasonCount := len(b.Seasons)
-			if seasonCount == 0 {
-				seasonCount = 1 // Count series with no seasons as 1 bluray
-			}
-			stats.TotalSeasons += seasonCount
-			physicalBlurayCount += seasonCount // Each season is 1 physical bluray
-		} else {
-			physicalBlurayCount++ // Each movie is 1 physical bluray
-		}
-	}
-
-	stats.TotalBlurays = physicalBlurayCount
-
-	return stats, nil
+	return computeSimplifiedStatistics(blurays), nil
 }
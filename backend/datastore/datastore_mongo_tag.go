@@ -28,9 +28,9 @@ func (ds *MongoDatastore) GetTagByID(ctx context.Context, id primitive.ObjectID)
 	return &tag, err
 }
 
-func (ds *MongoDatastore) GetTagByName(ctx context.Context, name string) (*models.Tag, error) {
+func (ds *MongoDatastore) GetTagByName(ctx context.Context, collectionID primitive.ObjectID, name string) (*models.Tag, error) {
 	var tag models.Tag
-	err := ds.tags.FindOne(ctx, bson.M{"name": name}).Decode(&tag)
+	err := ds.tags.FindOne(ctx, bson.M{"collection_id": mongoCollectionFilter(collectionID), "name": name}).Decode(&tag)
 	if err == mongo.ErrNoDocuments {
 		return nil, errors.New("tag not found")
 	}
@@ -43,13 +43,36 @@ func (ds *MongoDatastore) UpdateTag(ctx context.Context, tag *models.Tag) error
 	return err
 }
 
+func (ds *MongoDatastore) SetTagDeletedAt(ctx context.Context, id primitive.ObjectID, deletedAt *time.Time) error {
+	return setDeletedAt(ctx, ds.tags, id, deletedAt)
+}
+
+// setDeletedAt sets or, with nil, removes the trash marker of a document
+func setDeletedAt(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID, deletedAt *time.Time) error {
+	update := bson.M{"$unset": bson.M{"deleted_at": ""}}
+	if deletedAt != nil {
+		update = bson.M{"$set": bson.M{"deleted_at": *deletedAt}}
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
 func (ds *MongoDatastore) DeleteTag(ctx context.Context, id primitive.ObjectID) error {
 	_, err := ds.tags.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
 
-func (ds *MongoDatastore) ListTags(ctx context.Context) ([]*models.Tag, error) {
-	cursor, err := ds.tags.Find(ctx, bson.M{})
+func (ds *MongoDatastore) RemoveTagFromBlurays(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.blurays.UpdateMany(ctx, bson.M{"tags": id.Hex()}, bson.M{"$pull": bson.M{"tags": id.Hex()}})
+	return err
+}
+
+func (ds *MongoDatastore) ListTags(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Tag, error) {
+	filter := bson.M{}
+	if collectionID != nil {
+		filter["collection_id"] = mongoCollectionFilter(*collectionID)
+	}
+	cursor, err := ds.tags.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -61,10 +84,11 @@ func (ds *MongoDatastore) ListTags(ctx context.Context) ([]*models.Tag, error) {
 	return tags, nil
 }
 
-// SearchTagsByName searches for tags by name pattern (case-insensitive)
+// SearchTagsByName searches for tags by name pattern (case-insensitive),
+// leaving out the tags in the trash
 func (ds *MongoDatastore) SearchTagsByName(ctx context.Context, pattern string) ([]*models.Tag, error) {
 	regexPattern := bson.M{"$regex": primitive.Regex{Pattern: pattern, Options: "i"}}
-	cursor, err := ds.tags.Find(ctx, bson.M{"name": regexPattern})
+	cursor, err := ds.tags.Find(ctx, bson.M{"name": regexPattern, "deleted_at": bson.M{"$exists": false}})
 	if err != nil {
 		return nil, err
 	}
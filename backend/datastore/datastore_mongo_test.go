@@ -0,0 +1,82 @@
+package datastore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMongoPagePipeline(t *testing.T) {
+	filter := bson.M{"type": "movie"}
+	id := primitive.NewObjectID()
+	created := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC).UnixMilli()
+	after := &SortPosition{Key: SortKey{Text: "heat"}, Created: created, ID: id}
+
+	// By title descending, the page after a position holds the blurays whose
+	// title is missing or below it, and those of the same title added before
+	got := mongoPagePipeline(filter, &BlurayPage{Field: models.SortTitle, Desc: true, After: after, Skip: 2, Limit: 5})
+	title := bson.M{"$ifNull": bson.A{"$sort_keys.title", ""}}
+	want := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$addFields", Value: bson.M{
+			"sort_missing": bson.M{"$eq": bson.A{title, ""}},
+			"sort_value":   title,
+		}}},
+		{{Key: "$match", Value: bson.M{"$or": []bson.M{
+			{"sort_missing": bson.M{"$gt": false}},
+			{"sort_missing": false, "sort_value": bson.M{"$lt": "heat"}},
+			{"sort_missing": false, "sort_value": "heat", "created_at": bson.M{"$lt": time.UnixMilli(created)}},
+			{"sort_missing": false, "sort_value": "heat", "created_at": time.UnixMilli(created), "_id": bson.M{"$lt": id}},
+		}}}},
+		{{Key: "$sort", Value: bson.D{
+			{Key: "sort_missing", Value: 1},
+			{Key: "sort_value", Value: -1},
+			{Key: "created_at", Value: -1},
+			{Key: "_id", Value: -1},
+		}}},
+		{{Key: "$skip", Value: 2}},
+		{{Key: "$limit", Value: 5}},
+		{{Key: "$project", Value: bson.M{"sort_missing": 0, "sort_value": 0}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pipeline by title = %v, want %v", got, want)
+	}
+
+	// Numbers are compared as numbers
+	got = mongoPagePipeline(filter, &BlurayPage{Field: models.SortPrice, After: &SortPosition{Key: SortKey{Number: 12.5}, Created: created, ID: id}})
+	clauses := got[2][0].Value.(bson.M)["$or"].([]bson.M)
+	if len(clauses) != 4 || !reflect.DeepEqual(clauses[1]["sort_value"], bson.M{"$gt": 12.5}) {
+		t.Errorf("after clauses by price = %v, want sort_value above 12.5", clauses)
+	}
+
+	// By date added ascending, ties are oldest first and nothing is added
+	got = mongoPagePipeline(filter, &BlurayPage{Field: models.SortAdded, After: after})
+	want = mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$match", Value: bson.M{"$or": []bson.M{
+			{"created_at": bson.M{"$gt": time.UnixMilli(created)}},
+			{"created_at": time.UnixMilli(created), "_id": bson.M{"$gt": id}},
+		}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pipeline by date added = %v, want %v", got, want)
+	}
+
+	// The first page has no after clause
+	got = mongoPagePipeline(filter, &BlurayPage{Field: models.SortAdded, Desc: true, Limit: 10})
+	want = mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$limit", Value: 10}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("first page = %v, want %v", got, want)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func (ds *MongoDatastore) CreateUser(ctx context.Context, user *models.User) error {
@@ -48,6 +47,15 @@ func (ds *MongoDatastore) GetUserByUsername(ctx context.Context, username string
 	return &user, err
 }
 
+func (ds *MongoDatastore) GetUserByExternalIdentity(ctx context.Context, identity models.ExternalIdentity) (*models.User, error) {
+	var user models.User
+	err := ds.users.FindOne(ctx, bson.M{"oidc.issuer": identity.Issuer, "oidc.subject": identity.Subject}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("user not found")
+	}
+	return &user, err
+}
+
 func (ds *MongoDatastore) UpdateUser(ctx context.Context, user *models.User) error {
 	user.UpdatedAt = time.Now()
 	_, err := ds.users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": user})
@@ -73,44 +81,14 @@ func (ds *MongoDatastore) ListUsers(ctx context.Context, skip, limit int) ([]*mo
 	return users, nil
 }
 
+func (ds *MongoDatastore) CountUsers(ctx context.Context) (int, error) {
+	count, err := ds.users.CountDocuments(ctx, bson.M{})
+	return int(count), err
+}
+
 // EnsureGuestUser creates a guest user if it doesn't exist, or migrates
 // an existing guest user's locale to the current format (e.g. "en" → "en-US").
 // Returns true if the user was created, false if it already existed.
 func (ds *MongoDatastore) EnsureGuestUser(ctx context.Context) (bool, error) {
-	// Locale migration map: old short codes → new BCP-47 tags
-	localeMap := map[string]string{
-		"en": "en-US",
-		"fr": "fr-FR",
-	}
-
-	// Check if guest user already exists
-	existingUser, err := ds.GetUserByEmail(ctx, "guest@bluray-manager.local")
-	if err == nil {
-		// Migrate outdated locale format if necessary
-		if newLocale, outdated := localeMap[existingUser.Settings.Language]; outdated {
-			existingUser.Settings.Language = newLocale
-			_ = ds.UpdateUser(ctx, existingUser)
-		}
-		return false, nil
-	}
-
-	// Create guest user
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("guest"), bcrypt.DefaultCost)
-	if err != nil {
-		return false, err
-	}
-
-	guestUser := &models.User{
-		Username:     "guest",
-		Email:        "guest@bluray-manager.local",
-		PasswordHash: string(hashedPassword),
-		Role:         models.RoleGuest,
-		Settings: models.UserSettings{
-			Theme:    "dark",
-			Language: "en-US",
-		},
-	}
-
-	err = ds.CreateUser(ctx, guestUser)
-	return err == nil, err
+	return ensureGuestUser(ctx, ds)
 }
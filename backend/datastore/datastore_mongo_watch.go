@@ -0,0 +1,94 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateWatchEvent(ctx context.Context, event *models.WatchEvent) error {
+	event.ID = primitive.NewObjectID()
+	event.CreatedAt = time.Now()
+	event.UpdatedAt = time.Now()
+	_, err := ds.watchEvents.InsertOne(ctx, event)
+	return err
+}
+
+func (ds *MongoDatastore) GetWatchEventByID(ctx context.Context, id primitive.ObjectID) (*models.WatchEvent, error) {
+	var event models.WatchEvent
+	err := ds.watchEvents.FindOne(ctx, bson.M{"_id": id}).Decode(&event)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("watch event not found")
+	}
+	return &event, err
+}
+
+func (ds *MongoDatastore) UpdateWatchEvent(ctx context.Context, event *models.WatchEvent) error {
+	event.UpdatedAt = time.Now()
+	_, err := ds.watchEvents.ReplaceOne(ctx, bson.M{"_id": event.ID}, event)
+	return err
+}
+
+func (ds *MongoDatastore) DeleteWatchEvent(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.watchEvents.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (ds *MongoDatastore) DeleteBlurayWatchEvents(ctx context.Context, blurayID primitive.ObjectID) error {
+	_, err := ds.watchEvents.DeleteMany(ctx, bson.M{"bluray_id": blurayID})
+	return err
+}
+
+func (ds *MongoDatastore) ListWatchEvents(ctx context.Context, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID, skip, limit int) ([]*models.WatchEvent, error) {
+	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.D{{Key: "watched_at", Value: -1}})
+
+	cursor, err := ds.watchEvents.Find(ctx, watchEventsFilter(userID, collectionID, blurayID), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []*models.WatchEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (ds *MongoDatastore) CountWatchEvents(ctx context.Context, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID) (int, error) {
+	count, err := ds.watchEvents.CountDocuments(ctx, watchEventsFilter(userID, collectionID, blurayID))
+	return int(count), err
+}
+
+// watchEventsFilter returns the filter of the history of the user
+func watchEventsFilter(userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID) bson.M {
+	filter := bson.M{"user_id": userID}
+	if collectionID != nil {
+		filter["collection_id"] = mongoCollectionFilter(*collectionID)
+	}
+	if blurayID != nil {
+		filter["bluray_id"] = *blurayID
+	}
+	return filter
+}
+
+func (ds *MongoDatastore) ListWatchedBlurayIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	values, err := ds.watchEvents.Distinct(ctx, "bluray_id", bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,65 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (ds *MongoDatastore) CreateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	item.ID = primitive.NewObjectID()
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = time.Now()
+	_, err := ds.wishlist.InsertOne(ctx, item)
+	return err
+}
+
+func (ds *MongoDatastore) GetWishlistItemByID(ctx context.Context, id primitive.ObjectID) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	err := ds.wishlist.FindOne(ctx, bson.M{"_id": id}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("wishlist item not found")
+	}
+	return &item, err
+}
+
+func (ds *MongoDatastore) UpdateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	item.UpdatedAt = time.Now()
+	_, err := ds.wishlist.ReplaceOne(ctx, bson.M{"_id": item.ID}, item)
+	return err
+}
+
+func (ds *MongoDatastore) DeleteWishlistItem(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.wishlist.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (ds *MongoDatastore) ListWishlistItems(ctx context.Context, collectionID *primitive.ObjectID, acquired bool) ([]*models.WishlistItem, error) {
+	filter := bson.M{"acquired_at": nil}
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "created_at", Value: -1}})
+	if acquired {
+		filter = bson.M{"acquired_at": bson.M{"$ne": nil}}
+		opts.SetSort(bson.D{{Key: "acquired_at", Value: -1}})
+	}
+	if collectionID != nil {
+		filter["collection_id"] = mongoCollectionFilter(*collectionID)
+	}
+
+	cursor, err := ds.wishlist.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []*models.WishlistItem
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
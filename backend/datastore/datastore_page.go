@@ -0,0 +1,184 @@
+package datastore
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Blurays sorted by a stored field are paged by the datastores. Each bluray
+// has its own place in the order: blurays without a value for the field come
+// last either way, and ties are broken by creation time and then ID, newest
+// first unless sorting by date added in ascending order. A page starts right
+// after the position of the last bluray of the previous one, wherever
+// blurays added or deleted since then moved it.
+
+// BlurayPage asks for a page of blurays sorted by a stored field
+type BlurayPage struct {
+	Field models.SortField
+	Desc  bool
+	// After is the position the page starts after, nil for the first page
+	After *SortPosition
+	// Skip skips blurays past After, and a Limit of 0 returns every bluray
+	Skip  int
+	Limit int
+}
+
+// newest tells whether ties are broken newest first
+func (p *BlurayPage) newest() bool {
+	return p.Field != models.SortAdded || p.Desc
+}
+
+// keyed tells whether the blurays are sorted by a value before the tie
+// breakers, which sorting by date added is not
+func (p *BlurayPage) keyed() bool {
+	return p.Field != models.SortAdded
+}
+
+// IsStoredSortField tells whether the datastores can sort blurays by a field
+// themselves. Ratings and relevance are worked out after the blurays are read.
+func IsStoredSortField(field models.SortField) bool {
+	switch field {
+	case models.SortTitle, models.SortYear, models.SortPrice, models.SortPurchased, models.SortAdded:
+		return true
+	}
+	return false
+}
+
+// SortKey is the value a bluray is sorted by
+type SortKey struct {
+	Text    string
+	Number  float64
+	Missing bool
+}
+
+func (k SortKey) compare(other SortKey) int {
+	if c := strings.Compare(k.Text, other.Text); c != 0 {
+		return c
+	}
+	return cmp.Compare(k.Number, other.Number)
+}
+
+// SortPosition is where a bluray stands in a sorted list: its sort key, then
+// its creation time in Unix milliseconds and its ID
+type SortPosition struct {
+	Key     SortKey
+	Created int64
+	ID      primitive.ObjectID
+}
+
+// Compare orders two positions, with the sort key descending if desc and
+// ties newest first if newest
+func (p SortPosition) Compare(other SortPosition, desc, newest bool) int {
+	if p.Key.Missing != other.Key.Missing {
+		if p.Key.Missing {
+			return 1
+		}
+		return -1
+	}
+	if c := p.Key.compare(other.Key); c != 0 {
+		if desc {
+			return -c
+		}
+		return c
+	}
+	c := cmp.Compare(p.Created, other.Created)
+	if c == 0 {
+		c = bytes.Compare(p.ID[:], other.ID[:])
+	}
+	if newest {
+		return -c
+	}
+	return c
+}
+
+// StoredSortKey returns the value of a stored sort field for a bluray with
+// the given fields. Sorting by date added has no value but the tie breakers.
+func StoredSortKey(field models.SortField, title string, year int, copies []models.Copy) SortKey {
+	keys := copySortKeys(title, copies)
+	switch field {
+	case models.SortTitle:
+		return SortKey{Text: keys.Title, Missing: keys.Title == ""}
+	case models.SortYear:
+		return SortKey{Number: float64(year), Missing: year == 0}
+	case models.SortPrice:
+		return SortKey{Number: keys.Price, Missing: keys.Price == 0}
+	case models.SortPurchased:
+		return SortKey{Number: float64(keys.Purchased), Missing: keys.Purchased == 0}
+	}
+	return SortKey{}
+}
+
+// blurayPosition returns where a bluray stands when sorted by a stored field
+func blurayPosition(b *models.Bluray, field models.SortField) SortPosition {
+	return SortPosition{
+		Key:     StoredSortKey(field, b.Title, b.ReleaseYear, b.Copies),
+		Created: b.CreatedAt.UnixMilli(),
+		ID:      b.ID,
+	}
+}
+
+// sortKeys works out the sort keys stored with a bluray
+func sortKeys(b *models.Bluray) models.BluraySortKeys {
+	return copySortKeys(b.Title, b.Copies)
+}
+
+func copySortKeys(title string, copies []models.Copy) models.BluraySortKeys {
+	keys := models.BluraySortKeys{Title: strings.ToLower(title)}
+	var last time.Time
+	for _, copy := range copies {
+		keys.Price += copy.PurchasePrice
+		if copy.PurchaseDate.After(last) {
+			last = copy.PurchaseDate
+		}
+	}
+	if !last.IsZero() {
+		keys.Purchased = last.UnixMilli()
+	}
+	return keys
+}
+
+// indexSortKeys stores the sort keys of a stored bluray document. A document
+// that cannot be decoded is left as it is.
+func indexSortKeys(doc bson.M) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return
+	}
+	var bluray models.Bluray
+	if err := bson.Unmarshal(data, &bluray); err != nil {
+		return
+	}
+	doc["sort_keys"] = sortKeys(&bluray)
+}
+
+// pageBlurays sorts blurays for a page and returns the blurays of the page
+func pageBlurays(blurays []*models.Bluray, page *BlurayPage) []*models.Bluray {
+	desc, newest := page.Desc, page.newest()
+	positions := make(map[*models.Bluray]SortPosition, len(blurays))
+	for _, bluray := range blurays {
+		positions[bluray] = blurayPosition(bluray, page.Field)
+	}
+	sorted := slices.Clone(blurays)
+	slices.SortFunc(sorted, func(a, b *models.Bluray) int {
+		return positions[a].Compare(positions[b], desc, newest)
+	})
+
+	start := 0
+	if page.After != nil {
+		start, _ = slices.BinarySearchFunc(sorted, *page.After, func(b *models.Bluray, after SortPosition) int {
+			if positions[b].Compare(after, desc, newest) > 0 {
+				return 1
+			}
+			return -1
+		})
+	}
+	return paginate(sorted[start:], page.Skip, page.Limit)
+}
@@ -0,0 +1,73 @@
+package datastore
+
+import (
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// legacyRatedBluray holds the fields of a bluray stored when it had a single
+// shared rating
+type legacyRatedBluray struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	AddedBy   primitive.ObjectID `bson:"added_by"`
+	Rating    float64            `bson:"rating"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+// ratingKey identifies the rating of a bluray by a user
+type ratingKey struct {
+	UserID   primitive.ObjectID
+	BlurayID primitive.ObjectID
+}
+
+// legacyBlurayRating turns the shared rating of a bluray document into the
+// personal rating of whoever added the bluray. It reports false for a bluray
+// that was not rated.
+func legacyBlurayRating(doc bson.M) (*models.UserRating, bool, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	var legacy legacyRatedBluray
+	if err := bson.Unmarshal(data, &legacy); err != nil {
+		return nil, false, err
+	}
+	if legacy.Rating <= 0 {
+		return nil, false, nil
+	}
+
+	return &models.UserRating{
+		ID:        primitive.NewObjectID(),
+		UserID:    legacy.AddedBy,
+		BlurayID:  legacy.ID,
+		Score:     legacy.Rating,
+		CreatedAt: legacy.UpdatedAt,
+		UpdatedAt: legacy.UpdatedAt,
+	}, true, nil
+}
+
+// restoreBlurayRating is the reverse of legacyBlurayRating. Only the rating
+// of whoever added the bluray can be put back; the others are lost.
+func restoreBlurayRating(doc bson.M, scores map[ratingKey]float64) {
+	blurayID, _ := doc["_id"].(primitive.ObjectID)
+	addedBy, _ := doc["added_by"].(primitive.ObjectID)
+	doc["rating"] = scores[ratingKey{UserID: addedBy, BlurayID: blurayID}]
+}
+
+// ratingAverages returns the household average rating of every rated bluray
+func ratingAverages(ratings []*models.UserRating) map[primitive.ObjectID]float64 {
+	sums := map[primitive.ObjectID]float64{}
+	counts := map[primitive.ObjectID]int{}
+	for _, rating := range ratings {
+		sums[rating.BlurayID] += rating.Score
+		counts[rating.BlurayID]++
+	}
+	averages := make(map[primitive.ObjectID]float64, len(sums))
+	for id, sum := range sums {
+		averages[id] = sum / float64(counts[id])
+	}
+	return averages
+}
@@ -0,0 +1,623 @@
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Search queries combine free words, "quoted phrases" and field:value
+// filters with AND (the default between terms), OR, NOT (or a leading -)
+// and parentheses, e.g.
+//
+//	(title:alien OR director:"ridley scott") year:1979..1986 -tag:seen
+//
+// Text field values are case-insensitive patterns, taken literally when
+// quoted. The numeric fields take a value, a range such as 1990..1999 or a
+// comparison such as >=8, and purchase dates take a year, a month or a day
+// (2024, 2024-03, 2024-03-15) in the same forms. A letters-only prefix
+// before a colon names a field, and unknown fields are errors; quote text
+// such as "Mission:Impossible" to search for it.
+
+// nodeKind is the kind of a node of a parsed search query
+type nodeKind int
+
+const (
+	nodeAnd nodeKind = iota
+	nodeOr
+	nodeNot
+	nodeWords
+	nodePhrase
+	nodeField
+)
+
+// searchNode is a node of a parsed search query
+type searchNode struct {
+	Kind     nodeKind
+	Children []*searchNode
+
+	// Terms are the folded words of free text and phrases
+	Terms []string
+
+	// Field filters hold a pattern, a value or a range depending on the field
+	Field string
+	Value string
+	Range *searchRange
+}
+
+// searchRange bounds a numeric field, or a date as Unix milliseconds. There
+// is always a lower bound, 0 by default, so that empty fields never match.
+type searchRange struct {
+	Min, Max         float64
+	MinOpen, MaxOpen bool
+	HasMax           bool
+}
+
+// Contains reports whether v is within the range
+func (r *searchRange) Contains(v float64) bool {
+	if v < r.Min || (r.MinOpen && v == r.Min) {
+		return false
+	}
+	return !r.HasMax || v < r.Max || (!r.MaxOpen && v == r.Max)
+}
+
+// SearchQueryError is a syntax error in a search query. Position counts
+// characters from the start of the query.
+type SearchQueryError struct {
+	Code     string
+	Position int
+	Token    string
+}
+
+func (e *SearchQueryError) Error() string {
+	return fmt.Sprintf("search query: %s at position %d", e.Code, e.Position)
+}
+
+// Codes of search query errors
+const (
+	queryUnclosedQuote       = "unclosedQuote"
+	queryUnclosedParenthesis = "unclosedParenthesis"
+	queryUnexpectedToken     = "unexpectedToken"
+	queryMissingOperand      = "missingOperand"
+	queryMissingValue        = "missingValue"
+	queryInvalidValue        = "invalidValue"
+	queryInvalidRange        = "invalidRange"
+	queryInvalidPattern      = "invalidPattern"
+	queryUnknownField        = "unknownField"
+)
+
+// Fields of search queries by how their values are read
+var (
+	patternSearchFields = map[string]bool{
+		"title": true, "director": true, "genre": true, "description": true, "edition": true,
+		"publisher": true, "region": true, "audio": true, "subtitle": true, "tag": true, "location": true,
+	}
+	valueSearchFields = map[string]bool{"type": true, "format": true, "packaging": true, "barcode": true}
+	rangeSearchFields = map[string]bool{"year": true, "rating": true, "price": true}
+	dateSearchFields  = map[string]bool{"purchased": true}
+)
+
+func knownSearchField(field string) bool {
+	return patternSearchFields[field] || valueSearchFields[field] || rangeSearchFields[field] || dateSearchFields[field]
+}
+
+// isFieldName reports whether the text before a colon names a field, so
+// that words such as 3:10 stay free text
+func isFieldName(name string) bool {
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// unknownSearchField is returned by the datastores for filters on fields
+// they cannot translate, which the parser never lets through
+func unknownSearchField(field string) error {
+	return fmt.Errorf("search query: unknown field %q", field)
+}
+
+type queryTokenKind int
+
+const (
+	tokenEnd queryTokenKind = iota
+	tokenWord
+	tokenPhrase
+	tokenField
+	tokenOpen
+	tokenClose
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type queryToken struct {
+	Kind queryTokenKind
+	Text string
+	Pos  int
+
+	// Field tokens
+	Field  string
+	Quoted bool
+}
+
+// lexSearchQuery splits a search query into tokens
+func lexSearchQuery(query string) ([]queryToken, error) {
+	runes := []rune(query)
+	var tokens []queryToken
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{Kind: tokenOpen, Text: "(", Pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{Kind: tokenClose, Text: ")", Pos: i})
+			i++
+		case r == '"':
+			text, end, err := lexQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{Kind: tokenPhrase, Text: text, Pos: i})
+			i = end
+		case r == '-' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '(' || unicode.IsLetter(runes[i+1])):
+			tokens = append(tokens, queryToken{Kind: tokenNot, Text: "-", Pos: i})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()"`, runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			token := queryToken{Kind: tokenWord, Text: word, Pos: start}
+			switch word {
+			case "AND":
+				token.Kind = tokenAnd
+			case "OR":
+				token.Kind = tokenOr
+			case "NOT":
+				token.Kind = tokenNot
+			}
+
+			if name, value, ok := strings.Cut(word, ":"); ok && isFieldName(name) {
+				field := strings.ToLower(name)
+				if value == "" && i < len(runes) && runes[i] == '"' {
+					quoted, end, err := lexQuoted(runes, i)
+					if err != nil {
+						return nil, err
+					}
+					value, i = quoted, end
+					token.Quoted = true
+				}
+				switch {
+				case value != "" && !knownSearchField(field):
+					return nil, &SearchQueryError{Code: queryUnknownField, Position: start, Token: string(runes[start:i])}
+				case value != "":
+					token.Kind, token.Field, token.Text = tokenField, field, value
+				case knownSearchField(field):
+					return nil, &SearchQueryError{Code: queryMissingValue, Position: start, Token: word}
+				}
+			}
+			tokens = append(tokens, token)
+		}
+	}
+	return append(tokens, queryToken{Kind: tokenEnd, Pos: len(runes)}), nil
+}
+
+// lexQuoted reads the quoted text starting at runes[start], returning it
+// and the position after the closing quote
+func lexQuoted(runes []rune, start int) (string, int, error) {
+	for end := start + 1; end < len(runes); end++ {
+		if runes[end] == '"' {
+			return string(runes[start+1 : end]), end + 1, nil
+		}
+	}
+	return "", 0, &SearchQueryError{Code: queryUnclosedQuote, Position: start, Token: `"`}
+}
+
+// queryParser reads tokens into a query tree by recursive descent:
+//
+//	or      = and { "OR" and }
+//	and     = unary { ["AND"] unary }
+//	unary   = ("NOT" | "-") unary | primary
+//	primary = "(" or ")" | word | phrase | field
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// parseSearchQuery parses a search query. An empty query matches everything.
+func parseSearchQuery(query string) (*searchNode, error) {
+	tokens, err := lexSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	if p.peek().Kind == tokenEnd {
+		return &searchNode{Kind: nodeAnd}, nil
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.Kind != tokenEnd {
+		return nil, &SearchQueryError{Code: queryUnexpectedToken, Position: token.Pos, Token: token.Text}
+	}
+	return node, nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.pos]
+	if token.Kind != tokenEnd {
+		p.pos++
+	}
+	return token
+}
+
+// startsOperand reports whether a token can start an operand
+func startsOperand(token queryToken) bool {
+	switch token.Kind {
+	case tokenWord, tokenPhrase, tokenField, tokenOpen, tokenNot:
+		return true
+	}
+	return false
+}
+
+// expectOperand fails unless the next token starts an operand
+func (p *queryParser) expectOperand() error {
+	token := p.peek()
+	switch {
+	case startsOperand(token):
+		return nil
+	case token.Kind == tokenEnd || token.Kind == tokenClose:
+		return &SearchQueryError{Code: queryMissingOperand, Position: token.Pos, Token: token.Text}
+	}
+	return &SearchQueryError{Code: queryUnexpectedToken, Position: token.Pos, Token: token.Text}
+}
+
+func (p *queryParser) parseOr() (*searchNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*searchNode{node}
+	for p.peek().Kind == tokenOr {
+		p.next()
+		if err := p.expectOperand(); err != nil {
+			return nil, err
+		}
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &searchNode{Kind: nodeOr, Children: children}, nil
+}
+
+func (p *queryParser) parseAnd() (*searchNode, error) {
+	if err := p.expectOperand(); err != nil {
+		return nil, err
+	}
+	var children []*searchNode
+	for {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+
+		if p.peek().Kind == tokenAnd {
+			p.next()
+			if err := p.expectOperand(); err != nil {
+				return nil, err
+			}
+		} else if !startsOperand(p.peek()) {
+			break
+		}
+	}
+
+	// Stop words only count when there is nothing else to look for
+	var kept []*searchNode
+	for _, child := range children {
+		if !isStopWords(child) {
+			kept = append(kept, child)
+		}
+	}
+	if len(kept) > 0 {
+		children = kept
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &searchNode{Kind: nodeAnd, Children: children}, nil
+}
+
+func (p *queryParser) parseUnary() (*searchNode, error) {
+	if p.peek().Kind != tokenNot {
+		return p.parsePrimary()
+	}
+	p.next()
+	if err := p.expectOperand(); err != nil {
+		return nil, err
+	}
+	child, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &searchNode{Kind: nodeNot, Children: []*searchNode{child}}, nil
+}
+
+func (p *queryParser) parsePrimary() (*searchNode, error) {
+	token := p.next()
+	switch token.Kind {
+	case tokenOpen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().Kind != tokenClose {
+			return nil, &SearchQueryError{Code: queryUnclosedParenthesis, Position: token.Pos, Token: token.Text}
+		}
+		p.next()
+		return node, nil
+	case tokenWord:
+		return &searchNode{Kind: nodeWords, Terms: wordTerms(token.Text)}, nil
+	case tokenPhrase:
+		return &searchNode{Kind: nodePhrase, Terms: wordTerms(token.Text)}, nil
+	case tokenField:
+		return parseFieldValue(token)
+	}
+	return nil, &SearchQueryError{Code: queryUnexpectedToken, Position: token.Pos, Token: token.Text}
+}
+
+// wordTerms returns the folded words of free text, stop words included
+func wordTerms(text string) []string {
+	var terms []string
+	for _, token := range analyzeText(text) {
+		terms = append(terms, token.Term)
+	}
+	return terms
+}
+
+// isStopWords reports whether a node is free text made of stop words only
+func isStopWords(node *searchNode) bool {
+	if node.Kind != nodeWords {
+		return false
+	}
+	for _, term := range node.Terms {
+		if !stopWords[term] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFieldValue reads the value of a field filter the way its field needs
+func parseFieldValue(token queryToken) (*searchNode, error) {
+	node := &searchNode{Kind: nodeField, Field: token.Field, Value: token.Text}
+	invalid := func(code string) error {
+		return &SearchQueryError{Code: code, Position: token.Pos, Token: token.Field + ":" + token.Text}
+	}
+
+	var err error
+	switch {
+	case patternSearchFields[token.Field]:
+		if token.Quoted {
+			node.Value = regexp.QuoteMeta(token.Text)
+		}
+		if _, err := regexp.Compile("(?i)" + node.Value); err != nil {
+			return nil, invalid(queryInvalidPattern)
+		}
+	case rangeSearchFields[token.Field]:
+		node.Range, err = parseRange(token.Text, func(s string) (float64, float64, bool) {
+			v, err := strconv.ParseFloat(s, 64)
+			return v, v, err == nil && !math.IsInf(v, 0) && !math.IsNaN(v)
+		})
+	case dateSearchFields[token.Field]:
+		node.Range, err = parseRange(token.Text, parseDatePeriod)
+	}
+	if err != nil {
+		return nil, invalid(err.Error())
+	}
+	return node, nil
+}
+
+// parseRange reads a value, a range lo..hi (either end may be left out) or
+// a comparison. Values may stand for a period, which parse returns the
+// start and end of: a period value matches the whole period, and ranges and
+// comparisons take in or leave out whole periods.
+func parseRange(text string, parse func(string) (float64, float64, bool)) (*searchRange, error) {
+	value := func(s string) (float64, float64, error) {
+		start, end, ok := parse(s)
+		if !ok {
+			return 0, 0, errors.New(queryInvalidValue)
+		}
+		return start, end, nil
+	}
+
+	if lo, hi, ok := strings.Cut(text, ".."); ok {
+		if lo == "" && hi == "" {
+			return nil, errors.New(queryInvalidRange)
+		}
+		r := &searchRange{MinOpen: true}
+		if lo != "" {
+			start, _, err := value(lo)
+			if err != nil {
+				return nil, err
+			}
+			r.Min, r.MinOpen = start, false
+		}
+		if hi != "" {
+			start, end, err := value(hi)
+			if err != nil {
+				return nil, err
+			}
+			r.Max, r.MaxOpen, r.HasMax = end, start != end, true
+		}
+		if r.HasMax && r.Max < r.Min {
+			return nil, errors.New(queryInvalidRange)
+		}
+		return r, nil
+	}
+
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(text, candidate) {
+			op = candidate
+			break
+		}
+	}
+	start, end, err := value(strings.TrimPrefix(text, op))
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case ">=":
+		return &searchRange{Min: start}, nil
+	case ">":
+		return &searchRange{Min: end, MinOpen: start == end}, nil
+	case "<=":
+		return &searchRange{MinOpen: true, Max: end, MaxOpen: start != end, HasMax: true}, nil
+	case "<":
+		return &searchRange{MinOpen: true, Max: start, MaxOpen: true, HasMax: true}, nil
+	}
+	return &searchRange{Min: start, Max: end, MaxOpen: start != end, HasMax: true}, nil
+}
+
+// parseDatePeriod reads a year, a month or a day, returning the Unix
+// milliseconds of its start and end
+func parseDatePeriod(s string) (float64, float64, bool) {
+	for _, layout := range []struct {
+		format string
+		years  int
+		months int
+		days   int
+	}{{"2006", 1, 0, 0}, {"2006-01", 0, 1, 0}, {"2006-01-02", 0, 0, 1}} {
+		if len(s) != len(layout.format) {
+			continue
+		}
+		t, err := time.Parse(layout.format, s)
+		if err != nil {
+			return 0, 0, false
+		}
+		end := t.AddDate(layout.years, layout.months, layout.days)
+		return float64(t.UnixMilli()), float64(end.UnixMilli()), true
+	}
+	return 0, 0, false
+}
+
+// searchTime converts a date bound of a range back to a time
+func searchTime(ms float64) time.Time {
+	return time.UnixMilli(int64(ms)).UTC()
+}
+
+// positiveTerms returns the free words a bluray is ranked by: those of the
+// words and phrases outside of NOT
+func positiveTerms(node *searchNode) []string {
+	var terms []string
+	seen := map[string]bool{}
+	var walk func(*searchNode)
+	walk = func(n *searchNode) {
+		switch n.Kind {
+		case nodeNot:
+			return
+		case nodeWords, nodePhrase:
+			for _, term := range n.Terms {
+				if !seen[term] && !(n.Kind == nodeWords && stopWords[term] && len(n.Terms) > 1) {
+					seen[term] = true
+					terms = append(terms, term)
+				}
+			}
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(node)
+	return terms
+}
+
+// phrasePattern returns a case-insensitive pattern matching the words of a
+// phrase in a row, whatever their accents
+func phrasePattern(terms []string) string {
+	words := make([]string, len(terms))
+	for i, term := range terms {
+		var word strings.Builder
+		for _, r := range term {
+			if variants := accentVariants[r]; variants != "" {
+				word.WriteString("[" + string(r) + variants + "]")
+			} else {
+				word.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		words[i] = word.String()
+	}
+	return `(^|[^\p{L}\p{N}])` + strings.Join(words, `[^\p{L}\p{N}]+`) + `($|[^\p{L}\p{N}])`
+}
+
+// has reports whether the query holds a node of the given kind, or a filter
+// on the given field
+func (n *searchNode) has(kind nodeKind, field string) bool {
+	if n.Kind == kind && (kind != nodeField || n.Field == field) {
+		return true
+	}
+	for _, child := range n.Children {
+		if child.has(kind, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchLookup holds what the datastores look up once to translate a query:
+// the search terms of the searched blurays for free words, the tags for free
+// words and phrases, and the household average ratings for rating filters
+type searchLookup struct {
+	Vocabulary []string
+	Tags       []*models.Tag
+	Ratings    map[primitive.ObjectID]float64
+}
+
+// phraseTagIDs returns the IDs of the tags outside of the trash with a name
+// matching a phrase pattern
+func phraseTagIDs(re *regexp.Regexp, tags []*models.Tag) []string {
+	var ids []string
+	for _, tag := range tags {
+		if tag.DeletedAt == nil && re.MatchString(tag.Name) {
+			ids = append(ids, tag.ID.Hex())
+		}
+	}
+	return ids
+}
+
+// ratedBlurayIDs returns the blurays with an average rating within r
+func ratedBlurayIDs(averages map[primitive.ObjectID]float64, r *searchRange) []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for id, average := range averages {
+		if r.Contains(average) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
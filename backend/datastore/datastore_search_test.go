@@ -0,0 +1,191 @@
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// formatSearchNode writes a parsed query in a compact prefix form
+func formatSearchNode(n *searchNode) string {
+	switch n.Kind {
+	case nodeAnd, nodeOr, nodeNot:
+		name := map[nodeKind]string{nodeAnd: "and", nodeOr: "or", nodeNot: "not"}[n.Kind]
+		children := make([]string, len(n.Children))
+		for i, child := range n.Children {
+			children[i] = formatSearchNode(child)
+		}
+		return name + "(" + strings.Join(children, " ") + ")"
+	case nodeWords:
+		return "words[" + strings.Join(n.Terms, " ") + "]"
+	case nodePhrase:
+		return `"` + strings.Join(n.Terms, " ") + `"`
+	}
+	if n.Range != nil {
+		return fmt.Sprintf("%s:%+v", n.Field, *n.Range)
+	}
+	return n.Field + ":" + n.Value
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{"", "and()"},
+		{"inception", "words[inception]"},
+		{"Christopher NOLAN", "and(words[christopher] words[nolan])"},
+		{"the matrix", "words[matrix]"},
+		{"the", "words[the]"},
+		{`"hear you scream"`, `"hear you scream"`},
+		{`"Amélie"`, `"amelie"`},
+		{"alien OR heat", "or(words[alien] words[heat])"},
+		{"alien or heat", "and(words[alien] words[heat])"},
+		{"alien heat OR dune", "or(and(words[alien] words[heat]) words[dune])"},
+		{"alien AND heat OR dune AND ronin", "or(and(words[alien] words[heat]) and(words[dune] words[ronin]))"},
+		{"alien (heat OR dune)", "and(words[alien] or(words[heat] words[dune]))"},
+		{"NOT alien heat", "and(not(words[alien]) words[heat])"},
+		{"-alien -(heat OR dune)", "and(not(words[alien]) not(or(words[heat] words[dune])))"},
+		{"NOT NOT alien", "not(not(words[alien]))"},
+		{"spider-man", "words[spider man]"},
+		{"-3", "words[3]"},
+		{"Title:Alien", "title:Alien"},
+		{`director:"ridley scott"`, `director:ridley scott`},
+		{`title:"a.b"`, `title:a\.b`},
+		{"title:a.b", "title:a.b"},
+		{"3:10 to yuma", "and(words[3 10] words[yuma])"},
+		{"mission: impossible", "and(words[mission] words[impossible])"},
+		{`"Mission:Impossible"`, `"mission impossible"`},
+		{"year:1999", "year:{Min:1999 Max:1999 MinOpen:false MaxOpen:false HasMax:true}"},
+		{"year:1990..1999", "year:{Min:1990 Max:1999 MinOpen:false MaxOpen:false HasMax:true}"},
+		{"year:1990..", "year:{Min:1990 Max:0 MinOpen:false MaxOpen:false HasMax:false}"},
+		{"year:..1999", "year:{Min:0 Max:1999 MinOpen:true MaxOpen:false HasMax:true}"},
+		{"rating:>=8", "rating:{Min:8 Max:0 MinOpen:false MaxOpen:false HasMax:false}"},
+		{"rating:>8", "rating:{Min:8 Max:0 MinOpen:true MaxOpen:false HasMax:false}"},
+		{"price:<20", "price:{Min:0 Max:20 MinOpen:true MaxOpen:true HasMax:true}"},
+		{"price:<=19.99", "price:{Min:0 Max:19.99 MinOpen:true MaxOpen:false HasMax:true}"},
+	} {
+		node, err := parseSearchQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseSearchQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if got := formatSearchNode(node); got != tt.want {
+			t.Errorf("parseSearchQuery(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseSearchQueryDates(t *testing.T) {
+	ms := func(date string) float64 {
+		d, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return float64(d.UnixMilli())
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  searchRange
+	}{
+		{"purchased:2024", searchRange{Min: ms("2024-01-01"), Max: ms("2025-01-01"), MaxOpen: true, HasMax: true}},
+		{"purchased:2024-03", searchRange{Min: ms("2024-03-01"), Max: ms("2024-04-01"), MaxOpen: true, HasMax: true}},
+		{"purchased:2024-03-15", searchRange{Min: ms("2024-03-15"), Max: ms("2024-03-16"), MaxOpen: true, HasMax: true}},
+		{"purchased:>=2024", searchRange{Min: ms("2024-01-01")}},
+		{"purchased:>2024", searchRange{Min: ms("2025-01-01")}},
+		{"purchased:<2024-03", searchRange{Max: ms("2024-03-01"), MinOpen: true, MaxOpen: true, HasMax: true}},
+		{"purchased:<=2024-03", searchRange{Max: ms("2024-04-01"), MinOpen: true, MaxOpen: true, HasMax: true}},
+		{"purchased:2023..2024-02", searchRange{Min: ms("2023-01-01"), Max: ms("2024-03-01"), MaxOpen: true, HasMax: true}},
+	} {
+		node, err := parseSearchQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseSearchQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if node.Range == nil || *node.Range != tt.want {
+			t.Errorf("parseSearchQuery(%q) range = %+v, want %+v", tt.query, node.Range, tt.want)
+		}
+	}
+}
+
+func TestSearchRangeContains(t *testing.T) {
+	below := &searchRange{MinOpen: true, Max: 20, MaxOpen: true, HasMax: true}
+	for v, want := range map[float64]bool{0: false, 0.01: true, 19.99: true, 20: false} {
+		if got := below.Contains(v); got != want {
+			t.Errorf("price:<20 contains %v = %v, want %v", v, got, want)
+		}
+	}
+	from := &searchRange{Min: 8}
+	for v, want := range map[float64]bool{7.9: false, 8: true, 10: true} {
+		if got := from.Contains(v); got != want {
+			t.Errorf("rating:>=8 contains %v = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	for _, tt := range []struct {
+		query    string
+		code     string
+		position int
+		token    string
+	}{
+		{`"hear you`, queryUnclosedQuote, 0, `"`},
+		{`title:"alien`, queryUnclosedQuote, 6, `"`},
+		{`(alien OR heat`, queryUnclosedParenthesis, 0, "("},
+		{`a ((b)`, queryUnclosedParenthesis, 2, "("},
+		{`alien )`, queryUnexpectedToken, 6, ")"},
+		{`OR alien`, queryUnexpectedToken, 0, "OR"},
+		{`alien OR`, queryMissingOperand, 8, ""},
+		{`alien AND`, queryMissingOperand, 9, ""},
+		{`()`, queryMissingOperand, 1, ")"},
+		{`NOT`, queryMissingOperand, 3, ""},
+		{`alien OR OR heat`, queryUnexpectedToken, 9, "OR"},
+		{`heat year:`, queryMissingValue, 5, "year:"},
+		{`Amélie year:199x`, queryInvalidValue, 7, "year:199x"},
+		{`price:cheap`, queryInvalidValue, 0, "price:cheap"},
+		{`purchased:2024-13`, queryInvalidValue, 0, "purchased:2024-13"},
+		{`purchased:03/2024`, queryInvalidValue, 0, "purchased:03/2024"},
+		{`year:..`, queryInvalidRange, 0, "year:.."},
+		{`year:1999..1990`, queryInvalidRange, 0, "year:1999..1990"},
+		{`title:[a`, queryInvalidPattern, 0, "title:[a"},
+		{`titel:alien`, queryUnknownField, 0, "titel:alien"},
+		{`Mission:Impossible`, queryUnknownField, 0, "Mission:Impossible"},
+		{`alien http://example`, queryUnknownField, 6, "http://example"},
+		{`heat (cast:"al pacino")`, queryUnknownField, 6, `cast:"al pacino"`},
+	} {
+		_, err := parseSearchQuery(tt.query)
+		var queryErr *SearchQueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("parseSearchQuery(%q) error = %v, want %s", tt.query, err, tt.code)
+			continue
+		}
+		if queryErr.Code != tt.code || queryErr.Position != tt.position || queryErr.Token != tt.token {
+			t.Errorf("parseSearchQuery(%q) error = %s at %d (%q), want %s at %d (%q)",
+				tt.query, queryErr.Code, queryErr.Position, queryErr.Token, tt.code, tt.position, tt.token)
+		}
+	}
+}
+
+func TestPhrasePattern(t *testing.T) {
+	for _, tt := range []struct {
+		terms []string
+		text  string
+		want  bool
+	}{
+		{[]string{"hear", "you", "scream"}, "No one can hear you scream", true},
+		{[]string{"hear", "you", "scream"}, "hear, you... SCREAM!", true},
+		{[]string{"you", "hear"}, "No one can hear you scream", false},
+		{[]string{"amelie"}, "Le Fabuleux Destin d'Amélie Poulain", true},
+		{[]string{"ame"}, "Amélie", false},
+	} {
+		re := regexp.MustCompile("(?i)" + phrasePattern(tt.terms))
+		if got := re.MatchString(tt.text); got != tt.want {
+			t.Errorf("phrasePattern(%v) matches %q = %v, want %v", tt.terms, tt.text, got, tt.want)
+		}
+	}
+}
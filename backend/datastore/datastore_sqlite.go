@@ -0,0 +1,368 @@
+package datastore
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sqliteDriverName is the database/sql driver registered with the REGEXP
+// function the search queries rely on.
+const sqliteDriverName = "sqlite3_bluraymanager"
+
+var registerSQLiteDriver sync.Once
+
+// SQLiteDatastore is a file-based Datastore implementation.
+//
+// Every record is stored as a relaxed extended JSON document using the same
+// field names as the MongoDB collections, so filters and search fields are
+// shared between both backends.
+type SQLiteDatastore struct {
+	db *sql.DB
+}
+
+// sqliteSchema is the initial schema, created by the first migration
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (json_extract(data, '$.email'))`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (json_extract(data, '$.username'))`,
+
+	`CREATE TABLE IF NOT EXISTS blurays (
+		id TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_blurays_created_at ON blurays (created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_blurays_type ON blurays (json_extract(data, '$.type'))`,
+	`CREATE INDEX IF NOT EXISTS idx_blurays_tmdb_id ON blurays (json_extract(data, '$.tmdb_id'))`,
+
+	`CREATE TABLE IF NOT EXISTS tags (
+		id TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (json_extract(data, '$.name'))`,
+
+	`CREATE TABLE IF NOT EXISTS notifications (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		data TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id, created_at)`,
+
+	`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id TEXT PRIMARY KEY,
+		token TEXT NOT NULL UNIQUE,
+		data TEXT NOT NULL
+	)`,
+}
+
+// NewSQLiteDatastore opens (or creates) the SQLite database at path. The
+// tables are created by MigrateUp. Use ":memory:" for a throwaway database.
+func NewSQLiteDatastore(ctx context.Context, path string) (*SQLiteDatastore, error) {
+	registerSQLiteDriver.Do(func() {
+		sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				return conn.RegisterFunc("regexp", sqliteRegexp, true)
+			},
+		})
+	})
+
+	dsn := path
+	if path != ":memory:" {
+		dsn = "file:" + path + "?_busy_timeout=5000&_journal_mode=WAL"
+	}
+
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite serializes writers anyway, and a single connection keeps
+	// in-memory databases from being split across the pool.
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteDatastore{db: db}, nil
+}
+
+func (ds *SQLiteDatastore) Close(ctx context.Context) error {
+	return ds.db.Close()
+}
+
+// sqliteRegexpCacheSize bounds the compiled patterns kept by sqliteRegexp.
+// Patterns come from user searches, so the least recently used are dropped.
+const sqliteRegexpCacheSize = 256
+
+var sqliteRegexps = &regexpCache{entries: map[string]*list.Element{}, order: list.New()}
+
+// regexpCache is a least recently used cache of compiled patterns
+type regexpCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type regexpCacheEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// compile returns the compiled case-insensitive pattern
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*regexpCacheEntry).re, nil
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	c.entries[pattern] = c.order.PushFront(&regexpCacheEntry{pattern: pattern, re: re})
+	if c.order.Len() > sqliteRegexpCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*regexpCacheEntry).pattern)
+	}
+	return re, nil
+}
+
+// sqliteRegexp backs the REGEXP operator. Patterns are matched
+// case-insensitively, like the "i" option used in the MongoDB queries.
+func sqliteRegexp(pattern, value string) (bool, error) {
+	re, err := sqliteRegexps.compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(value), nil
+}
+
+// marshalDocument encodes a model into the JSON document stored in the data column
+func marshalDocument(v interface{}) (string, error) {
+	data, err := bson.MarshalExtJSON(v, false, false)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// unmarshalDocument decodes a data column back into a model
+func unmarshalDocument(data string, v interface{}) error {
+	return bson.UnmarshalExtJSON([]byte(data), false, v)
+}
+
+// jsonPath converts a dotted document key (e.g. "genre.en-US") into a SQLite JSON path
+func jsonPath(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = `"` + part + `"`
+	}
+	return "$." + strings.Join(parts, ".")
+}
+
+// sqliteDocumentArrays lists the fields holding arrays of embedded documents,
+// which filters can reach into with dotted keys such as "copies.format"
+var sqliteDocumentArrays = map[string]bool{"copies": true}
+
+// sqliteFilter translates the field conditions of a bluray filter into a
+// WHERE clause. Like MongoDB, a filter on an array field matches when
+// any element is equal to the value.
+func sqliteFilter(filters map[string]interface{}) (string, []interface{}, error) {
+	if len(filters) == 0 {
+		return "1", nil, nil
+	}
+
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	clauses := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, len(keys)*2)
+	for _, key := range keys {
+		value := filters[key]
+
+		if key == "_id" {
+			if operators, ok := value.(bson.M); ok {
+				clause, ids, err := sqliteIDOperators(operators)
+				if err != nil {
+					return "", nil, fmt.Errorf("unsupported filter value for %q: %w", key, err)
+				}
+				clauses = append(clauses, clause)
+				args = append(args, ids...)
+				continue
+			}
+			id, ok := value.(primitive.ObjectID)
+			if !ok {
+				return "", nil, fmt.Errorf("unsupported filter value for %q", key)
+			}
+			clauses = append(clauses, "id = ?")
+			args = append(args, id.Hex())
+			continue
+		}
+
+		path := jsonPath(key)
+		if operators, ok := value.(bson.M); ok {
+			clause, err := sqliteExistsOperator(operators)
+			if err != nil {
+				return "", nil, fmt.Errorf("unsupported filter value for %q: %w", key, err)
+			}
+			clauses = append(clauses, clause)
+			args = append(args, path)
+			continue
+		}
+
+		var arg interface{}
+		if id, ok := value.(primitive.ObjectID); ok {
+			path += `."$oid"`
+			arg = id.Hex()
+		} else {
+			rv := reflect.ValueOf(value)
+			switch rv.Kind() {
+			case reflect.String:
+				arg = rv.String()
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				arg = rv.Int()
+			case reflect.Float32, reflect.Float64:
+				arg = rv.Float()
+			case reflect.Bool:
+				arg = rv.Bool()
+			default:
+				return "", nil, fmt.Errorf("unsupported filter value for %q", key)
+			}
+		}
+
+		// Keys going through an array of documents match any element
+		if parts := strings.SplitN(key, ".", 2); len(parts) == 2 && sqliteDocumentArrays[parts[0]] {
+			clauses = append(clauses, "EXISTS (SELECT 1 FROM json_each(data, ?) AS e, json_each(e.value, ?) AS v WHERE v.value = ?)")
+			args = append(args, jsonPath(parts[0]), "$"+strings.TrimPrefix(path, jsonPath(parts[0])), arg)
+			continue
+		}
+
+		clauses = append(clauses, "EXISTS (SELECT 1 FROM json_each(data, ?) WHERE value = ?)")
+		args = append(args, path, arg)
+	}
+
+	return strings.Join(clauses, " AND "), args, nil
+}
+
+// sqliteExistsOperator translates {"$exists": bool}, the only operator
+// supported on fields other than _id, into a clause taking the JSON path
+func sqliteExistsOperator(operators bson.M) (string, error) {
+	exists, ok := operators["$exists"].(bool)
+	if !ok || len(operators) != 1 {
+		return "", fmt.Errorf("only $exists is supported")
+	}
+	if exists {
+		return "json_type(data, ?) IS NOT NULL", nil
+	}
+	return "json_type(data, ?) IS NULL", nil
+}
+
+// sqliteLimit converts the MongoDB convention of limit 0 meaning "no limit"
+func sqliteLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
+
+// queryCount runs a query selecting a single count
+func queryCount(ctx context.Context, db *sql.DB, query string, args ...interface{}) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count, err
+}
+
+// queryDocuments runs a query selecting a single data column and decodes every row
+func queryDocuments[T any](ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]*T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []*T
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		document := new(T)
+		if err := unmarshalDocument(data, document); err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, rows.Err()
+}
+
+// queryDocument decodes the single row returned by query, returning
+// sql.ErrNoRows when there is none
+func queryDocument[T any](ctx context.Context, db *sql.DB, query string, args ...interface{}) (*T, error) {
+	var data string
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&data); err != nil {
+		return nil, err
+	}
+	document := new(T)
+	if err := unmarshalDocument(data, document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// sqliteIDOperators turns $in and $nin operators on document IDs into SQL
+func sqliteIDOperators(operators bson.M) (string, []interface{}, error) {
+	clauses := make([]string, 0, len(operators))
+	var args []interface{}
+	for operator, operand := range operators {
+		if operator != "$in" && operator != "$nin" {
+			return "", nil, fmt.Errorf("operator %s", operator)
+		}
+		ids, ok := operand.([]primitive.ObjectID)
+		if !ok {
+			return "", nil, fmt.Errorf("operand of %s is %T", operator, operand)
+		}
+		if len(ids) == 0 {
+			// Nothing is in an empty list
+			if operator == "$in" {
+				clauses = append(clauses, "0")
+			}
+			continue
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		if operator == "$in" {
+			clauses = append(clauses, "id IN ("+placeholders+")")
+		} else {
+			clauses = append(clauses, "id NOT IN ("+placeholders+")")
+		}
+		for _, id := range ids {
+			args = append(args, id.Hex())
+		}
+	}
+	if len(clauses) == 0 {
+		return "1", nil, nil
+	}
+	return strings.Join(clauses, " AND "), args, nil
+}
@@ -0,0 +1,71 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+	data, err := marshalDocument(token)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO api_tokens (id, user_id, token_hash, data, created_at) VALUES (?, ?, ?, ?, ?)`,
+		token.ID.Hex(), token.UserID.Hex(), token.TokenHash, data, token.CreatedAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetAPITokenByID(ctx context.Context, id primitive.ObjectID) (*models.APIToken, error) {
+	token, err := queryDocument[models.APIToken](ctx, ds.db, `SELECT data FROM api_tokens WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("api token not found")
+	}
+	return token, err
+}
+
+func (ds *SQLiteDatastore) GetAPITokenByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	token, err := queryDocument[models.APIToken](ctx, ds.db, `SELECT data FROM api_tokens WHERE token_hash = ?`, hash)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("api token not found")
+	}
+	return token, err
+}
+
+func (ds *SQLiteDatastore) ListUserAPITokens(ctx context.Context, userID primitive.ObjectID) ([]*models.APIToken, error) {
+	return queryDocuments[models.APIToken](ctx, ds.db,
+		`SELECT data FROM api_tokens WHERE user_id = ? ORDER BY created_at, rowid`, userID.Hex())
+}
+
+func (ds *SQLiteDatastore) TouchAPIToken(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	token, err := queryDocument[models.APIToken](ctx, ds.db, `SELECT data FROM api_tokens WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token.LastUsedAt = &usedAt
+	data, err := marshalDocument(token)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE api_tokens SET data = ? WHERE id = ?`, data, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteAPIToken(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteUserAPITokens(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = ?`, userID.Hex())
+	return err
+}
@@ -0,0 +1,83 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+	data, err := marshalDocument(entry)
+	if err != nil {
+		return err
+	}
+	var actorID, collectionID interface{}
+	if entry.ActorID != nil {
+		actorID = entry.ActorID.Hex()
+	}
+	if entry.CollectionID != nil {
+		collectionID = entry.CollectionID.Hex()
+	}
+	_, err = ds.db.ExecContext(ctx,
+		`INSERT INTO audit_log (id, actor_id, action, entity_type, entity_id, collection_id, data, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID.Hex(), actorID, string(entry.Action), string(entry.EntityType), entry.EntityID, collectionID, data, entry.CreatedAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListAuditEntries(ctx context.Context, filter models.AuditFilter, skip, limit int) ([]*models.AuditEntry, error) {
+	where, args := auditWhere(filter)
+	return queryDocuments[models.AuditEntry](ctx, ds.db,
+		`SELECT data FROM audit_log WHERE `+where+` ORDER BY created_at DESC, rowid DESC LIMIT ? OFFSET ?`,
+		append(args, sqliteLimit(limit), skip)...)
+}
+
+func (ds *SQLiteDatastore) CountAuditEntries(ctx context.Context, filter models.AuditFilter) (int, error) {
+	where, args := auditWhere(filter)
+	return queryCount(ctx, ds.db, `SELECT COUNT(*) FROM audit_log WHERE `+where, args...)
+}
+
+// auditWhere returns the condition matching the entries of the filter, with
+// its arguments
+func auditWhere(filter models.AuditFilter) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	if filter.ActorID != nil {
+		conditions = append(conditions, "actor_id = ?")
+		args = append(args, filter.ActorID.Hex())
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, string(filter.Action))
+	}
+	if filter.EntityType != "" {
+		conditions = append(conditions, "entity_type = ?")
+		args = append(args, string(filter.EntityType))
+	}
+	if filter.EntityID != "" {
+		conditions = append(conditions, "entity_id = ?")
+		args = append(args, filter.EntityID)
+	}
+	if filter.CollectionID != nil {
+		conditions = append(conditions, "collection_id = ?")
+		args = append(args, filter.CollectionID.Hex())
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UnixNano())
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UnixNano())
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func (ds *SQLiteDatastore) DeleteAuditEntriesBefore(ctx context.Context, before time.Time) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM audit_log WHERE created_at < ?`, before.UnixNano())
+	return err
+}
@@ -0,0 +1,485 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateBluray(ctx context.Context, bluray *models.Bluray) error {
+	bluray.ID = primitive.NewObjectID()
+	bluray.CreatedAt = time.Now()
+	bluray.UpdatedAt = time.Now()
+	bluray.SearchTerms = searchTerms(bluray)
+	bluray.SortKeys = sortKeys(bluray)
+	data, err := marshalDocument(bluray)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO blurays (id, data, created_at) VALUES (?, ?, ?)`,
+		bluray.ID.Hex(), data, bluray.CreatedAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetBlurayByID(ctx context.Context, id primitive.ObjectID) (*models.Bluray, error) {
+	bluray, err := queryDocument[models.Bluray](ctx, ds.db, `SELECT data FROM blurays WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("bluray not found")
+	}
+	return bluray, err
+}
+
+func (ds *SQLiteDatastore) UpdateBluray(ctx context.Context, bluray *models.Bluray) error {
+	bluray.UpdatedAt = time.Now()
+	bluray.SearchTerms = searchTerms(bluray)
+	bluray.SortKeys = sortKeys(bluray)
+
+	existing, err := ds.GetBlurayByID(ctx, bluray.ID)
+	if err != nil {
+		// Updating a missing document is a no-op, as with MongoDB
+		return nil
+	}
+
+	// Preserve the original creation metadata and the trash marker
+	updated := *bluray
+	updated.AddedBy = existing.AddedBy
+	updated.CreatedAt = existing.CreatedAt
+	updated.DeletedAt = existing.DeletedAt
+
+	data, err := marshalDocument(&updated)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE blurays SET data = ? WHERE id = ?`, data, bluray.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) SetBlurayDeletedAt(ctx context.Context, id primitive.ObjectID, deletedAt *time.Time) error {
+	bluray, err := ds.GetBlurayByID(ctx, id)
+	if err != nil {
+		// Updating a missing document is a no-op, as with MongoDB
+		return nil
+	}
+	bluray.DeletedAt = deletedAt
+	data, err := marshalDocument(bluray)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE blurays SET data = ? WHERE id = ?`, data, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteBluray(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM blurays WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) PageBlurays(ctx context.Context, filter models.BlurayFilter, page *BlurayPage) ([]*models.Bluray, int, error) {
+	where, args, err := sqliteFilter(blurayConditions(filter))
+	if err != nil {
+		return nil, 0, err
+	}
+	return sqlitePage[models.Bluray](ctx, ds.db, where, args, page)
+}
+
+func (ds *SQLiteDatastore) PageSearchBlurays(ctx context.Context, query string, filter models.BlurayFilter, page *BlurayPage) ([]*models.Bluray, int, error) {
+	where, args, root, lookup, err := ds.searchWhere(ctx, query, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	blurays, total, err := sqlitePage[models.Bluray](ctx, ds.db, where, args, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	if terms := positiveTerms(root); len(terms) > 0 {
+		scoreBlurays(blurays, terms, lookup.Tags)
+	}
+	return blurays, total, nil
+}
+
+// searchWhere parses a search query and translates it, after the listing
+// filters, into a WHERE clause
+func (ds *SQLiteDatastore) searchWhere(ctx context.Context, query string, filter models.BlurayFilter) (string, []interface{}, *searchNode, *searchLookup, error) {
+	// Parse the query (e.g., "title:inception (tag:action OR year:>=2010)")
+	root, err := parseSearchQuery(query)
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+
+	// The search conditions come after the listing filters
+	where, args, err := sqliteFilter(blurayConditions(filter))
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+
+	lookup := &searchLookup{}
+	if root.has(nodeWords, "") {
+		if lookup.Vocabulary, err = ds.searchVocabulary(ctx, where, args); err != nil {
+			return "", nil, nil, nil, err
+		}
+	}
+	if root.has(nodeWords, "") || root.has(nodePhrase, "") {
+		if lookup.Tags, err = ds.ListTags(ctx, nil); err != nil {
+			return "", nil, nil, nil, err
+		}
+	}
+	if root.has(nodeField, "rating") {
+		ratings, err := ds.ListUserRatings(ctx, nil)
+		if err != nil {
+			return "", nil, nil, nil, err
+		}
+		lookup.Ratings = ratingAverages(ratings)
+	}
+
+	clause, searchArgs, err := ds.searchCondition(ctx, root, lookup)
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+	return where + " AND " + clause, append(args, searchArgs...), root, lookup, nil
+}
+
+// sqliteSortKeys are the expressions of the stored sort fields, with the
+// value of blurays without one
+var sqliteSortKeys = map[models.SortField]struct{ expr, none string }{
+	models.SortTitle:     {`IFNULL(json_extract(data, '$.sort_keys.title'), '')`, `''`},
+	models.SortYear:      {`IFNULL(json_extract(data, '$.release_year'), 0)`, `0`},
+	models.SortPrice:     {`IFNULL(json_extract(data, '$.sort_keys.price'), 0)`, `0`},
+	models.SortPurchased: {`IFNULL(json_extract(data, '$.sort_keys.purchased'), 0)`, `0`},
+}
+
+// sqlitePage selects a page of the blurays matching where, in the order of
+// the page, and counts the matching blurays
+func sqlitePage[T any](ctx context.Context, db *sql.DB, where string, args []interface{}, page *BlurayPage) ([]*T, int, error) {
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM blurays WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// The columns blurays are sorted by in turn, with the value of the
+	// position the page starts after. created_at holds nanoseconds.
+	var after SortPosition
+	if page.After != nil {
+		after = *page.After
+	}
+	type column struct {
+		expr  string
+		desc  bool
+		after interface{}
+	}
+	var columns []column
+	if page.keyed() {
+		key := sqliteSortKeys[page.Field]
+		var value interface{} = after.Key.Number
+		if page.Field == models.SortTitle {
+			value = after.Key.Text
+		}
+		columns = append(columns,
+			column{expr: `(` + key.expr + ` = ` + key.none + `)`, after: after.Key.Missing},
+			column{expr: key.expr, desc: page.Desc, after: value},
+		)
+	}
+	newest := page.newest()
+	columns = append(columns,
+		column{expr: `created_at / 1000000`, desc: newest, after: after.Created},
+		column{expr: `id`, desc: newest, after: after.ID.Hex()},
+	)
+
+	order := make([]string, len(columns))
+	for i, c := range columns {
+		order[i] = c.expr
+		if c.desc {
+			order[i] += " DESC"
+		}
+	}
+
+	// The page starts with the blurays sorted after the position on the
+	// first column that differs from it
+	if page.After != nil {
+		clauses := make([]string, len(columns))
+		for i, c := range columns {
+			var terms []string
+			for _, previous := range columns[:i] {
+				terms = append(terms, previous.expr+` = ?`)
+				args = append(args, previous.after)
+			}
+			if c.desc {
+				terms = append(terms, c.expr+` < ?`)
+			} else {
+				terms = append(terms, c.expr+` > ?`)
+			}
+			args = append(args, c.after)
+			clauses[i] = "(" + strings.Join(terms, " AND ") + ")"
+		}
+		where += " AND (" + strings.Join(clauses, " OR ") + ")"
+	}
+
+	args = append(args, sqliteLimit(page.Limit), max(page.Skip, 0))
+	documents, err := queryDocuments[T](ctx, db,
+		`SELECT data FROM blurays WHERE `+where+` ORDER BY `+strings.Join(order, ", ")+` LIMIT ? OFFSET ?`, args...)
+	return documents, total, err
+}
+
+// searchCondition translates a parsed search query into a WHERE clause
+func (ds *SQLiteDatastore) searchCondition(ctx context.Context, node *searchNode, lookup *searchLookup) (string, []interface{}, error) {
+	switch node.Kind {
+	case nodeAnd, nodeOr:
+		if len(node.Children) == 0 {
+			return `1`, nil, nil
+		}
+		clauses := make([]string, len(node.Children))
+		var args []interface{}
+		for i, child := range node.Children {
+			clause, childArgs, err := ds.searchCondition(ctx, child, lookup)
+			if err != nil {
+				return "", nil, err
+			}
+			clauses[i] = clause
+			args = append(args, childArgs...)
+		}
+		operator := " AND "
+		if node.Kind == nodeOr {
+			operator = " OR "
+		}
+		return "(" + strings.Join(clauses, operator) + ")", args, nil
+	case nodeNot:
+		clause, args, err := ds.searchCondition(ctx, node.Children[0], lookup)
+		if err != nil {
+			return "", nil, err
+		}
+		// Missing values make conditions NULL rather than false
+		return `NOT COALESCE(` + clause + `, 0)`, args, nil
+	case nodeWords:
+		if len(node.Terms) == 0 {
+			return `1`, nil, nil
+		}
+		clauses := make([]string, len(node.Terms))
+		var args []interface{}
+		for i, term := range node.Terms {
+			clause, termArgs := sqliteTermCondition(expandTerm(term, lookup.Vocabulary), matchingTagIDs(term, lookup.Tags))
+			clauses[i] = clause
+			args = append(args, termArgs...)
+		}
+		return "(" + strings.Join(clauses, " AND ") + ")", args, nil
+	case nodePhrase:
+		if len(node.Terms) == 0 {
+			return `1`, nil, nil
+		}
+		pattern := phrasePattern(node.Terms)
+		clauses := []string{
+			`COALESCE(json_extract(data, '$.title'), '') REGEXP ?`,
+			`COALESCE(json_extract(data, '$.director'), '') REGEXP ?`,
+			sqliteArrayRegexp("genre.en-US"),
+			sqliteArrayRegexp("genre.fr-FR"),
+			`COALESCE(json_extract(data, '$.description."en-US"'), '') REGEXP ?`,
+			`COALESCE(json_extract(data, '$.description."fr-FR"'), '') REGEXP ?`,
+		}
+		args := []interface{}{pattern, pattern, pattern, pattern, pattern, pattern}
+		tagIDs := phraseTagIDs(regexp.MustCompile("(?i)"+pattern), lookup.Tags)
+		if len(tagIDs) > 0 {
+			clause, tagArgs := sqliteTermCondition(nil, tagIDs)
+			clauses = append(clauses, clause)
+			args = append(args, tagArgs...)
+		}
+		return "(" + strings.Join(clauses, " OR ") + ")", args, nil
+	}
+	return ds.fieldCondition(ctx, node, lookup)
+}
+
+// fieldCondition translates a field filter into a WHERE clause
+func (ds *SQLiteDatastore) fieldCondition(ctx context.Context, f *searchNode, lookup *searchLookup) (string, []interface{}, error) {
+	switch f.Field {
+	case "title":
+		return `COALESCE(json_extract(data, '$.title'), '') REGEXP ?`, []interface{}{f.Value}, nil
+	case "director":
+		return `COALESCE(json_extract(data, '$.director'), '') REGEXP ?`, []interface{}{f.Value}, nil
+	case "tag":
+		// Search for tags by name first, then search blurays by tag IDs
+		matchingTags, err := ds.SearchTagsByName(ctx, f.Value)
+		if err == nil && len(matchingTags) > 0 {
+			clause, args := sqliteTagCondition(matchingTags)
+			return clause, args, nil
+		}
+		// If no tags found, add an impossible condition to return no results
+		return `0`, nil, nil
+	case "genre":
+		return `(` + sqliteArrayRegexp("genre.en-US") + ` OR ` + sqliteArrayRegexp("genre.fr-FR") + `)`, []interface{}{f.Value, f.Value}, nil
+	case "year":
+		clause, args := sqliteRangeCondition(`json_extract(data, '$.release_year')`, f.Range)
+		return clause, args, nil
+	case "rating":
+		clause, args := sqliteIDCondition(ratedBlurayIDs(lookup.Ratings, f.Range))
+		return clause, args, nil
+	case "price":
+		clause, args := sqliteRangeCondition(`json_extract(c.value, '$.purchase_price')`, f.Range)
+		return sqliteCopyCondition(clause), args, nil
+	case "purchased":
+		// Dates within 1970-9999 are stored as ISO strings, others such as
+		// the zero date as objects which julianday reads as NULL
+		clause, args := sqliteRangeCondition(`ROUND((julianday(json_extract(c.value, '$.purchase_date."$date"')) - 2440587.5) * 86400000)`, f.Range)
+		return sqliteCopyCondition(clause), args, nil
+	case "type":
+		return `json_extract(data, '$.type') = ?`, []interface{}{f.Value}, nil
+	case "format":
+		if format, ok := models.ParseReleaseFormat(f.Value); ok {
+			return sqliteCopyCondition(`json_extract(c.value, '$.format') = ?`), []interface{}{string(format)}, nil
+		}
+		// Unknown formats cannot match anything
+		return `0`, nil, nil
+	case "packaging":
+		if packaging, ok := models.ParsePackaging(f.Value); ok {
+			return sqliteCopyCondition(`json_extract(c.value, '$.packaging') = ?`), []interface{}{string(packaging)}, nil
+		}
+		// Unknown packagings cannot match anything
+		return `0`, nil, nil
+	case "edition":
+		return sqliteCopyCondition(`COALESCE(json_extract(c.value, '$.edition'), '') REGEXP ?`), []interface{}{f.Value}, nil
+	case "publisher":
+		return sqliteCopyCondition(`COALESCE(json_extract(c.value, '$.publisher'), '') REGEXP ?`), []interface{}{f.Value}, nil
+	case "region":
+		return sqliteCopyCondition(`COALESCE(json_extract(c.value, '$.region_code'), '') REGEXP ?`), []interface{}{f.Value}, nil
+	case "barcode":
+		return sqliteCopyCondition(`json_extract(c.value, '$.barcode') = ?`), []interface{}{f.Value}, nil
+	case "audio":
+		return sqliteCopyCondition(`EXISTS (SELECT 1 FROM json_each(c.value, '$.audio_tracks') WHERE value REGEXP ?)`), []interface{}{f.Value}, nil
+	case "subtitle":
+		return sqliteCopyCondition(`EXISTS (SELECT 1 FROM json_each(c.value, '$.subtitle_tracks') WHERE value REGEXP ?)`), []interface{}{f.Value}, nil
+	case "location":
+		// Match the location and everything stored inside it
+		locationIDs, err := ds.searchLocationIDs(ctx, f.Value)
+		if err == nil && len(locationIDs) > 0 {
+			clause, args := sqliteLocationCondition(locationIDs)
+			return clause, args, nil
+		}
+		// If no locations found, add an impossible condition to return no results
+		return `0`, nil, nil
+	case "description":
+		return `(COALESCE(json_extract(data, '$.description."en-US"'), '') REGEXP ? OR COALESCE(json_extract(data, '$.description."fr-FR"'), '') REGEXP ?)`, []interface{}{f.Value, f.Value}, nil
+	}
+	return "", nil, unknownSearchField(f.Field)
+}
+
+// sqliteRangeCondition matches when the value of expr is within r, missing
+// values counting as 0
+func sqliteRangeCondition(expr string, r *searchRange) (string, []interface{}) {
+	expr = `COALESCE(` + expr + `, 0)`
+	clause := expr + ` >= ?`
+	if r.MinOpen {
+		clause = expr + ` > ?`
+	}
+	args := []interface{}{r.Min}
+	if r.HasMax {
+		if r.MaxOpen {
+			clause += ` AND ` + expr + ` < ?`
+		} else {
+			clause += ` AND ` + expr + ` <= ?`
+		}
+		args = append(args, r.Max)
+	}
+	return `(` + clause + `)`, args
+}
+
+// sqliteIDCondition matches the blurays with the given IDs
+func sqliteIDCondition(ids []primitive.ObjectID) (string, []interface{}) {
+	if len(ids) == 0 {
+		return `0`, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id.Hex()
+	}
+	return `id IN (` + strings.Join(placeholders, ", ") + `)`, args
+}
+
+// searchVocabulary returns the distinct search terms of the blurays matching where
+func (ds *SQLiteDatastore) searchVocabulary(ctx context.Context, where string, args []interface{}) ([]string, error) {
+	rows, err := ds.db.QueryContext(ctx,
+		`SELECT DISTINCT t.value FROM blurays, json_each(blurays.data, '$.search_terms') AS t WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vocabulary []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, err
+		}
+		vocabulary = append(vocabulary, term)
+	}
+	return vocabulary, rows.Err()
+}
+
+// sqliteTermCondition matches blurays holding one of the given search terms
+// or carrying one of the given tags
+func sqliteTermCondition(terms, tagIDs []string) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	for _, list := range []struct {
+		key    string
+		values []string
+	}{{"search_terms", terms}, {"tags", tagIDs}} {
+		if len(list.values) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(list.values))
+		for i, value := range list.values {
+			placeholders[i] = "?"
+			args = append(args, value)
+		}
+		clauses = append(clauses, `EXISTS (SELECT 1 FROM json_each(data, '$.`+list.key+`') WHERE value IN (`+strings.Join(placeholders, ", ")+`))`)
+	}
+	if len(clauses) == 0 {
+		return `0`, nil
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+// sqliteArrayRegexp matches when any element of the array at key matches the bound pattern
+func sqliteArrayRegexp(key string) string {
+	return `EXISTS (SELECT 1 FROM json_each(data, '` + jsonPath(key) + `') WHERE value REGEXP ?)`
+}
+
+// sqliteCopyCondition matches blurays with at least one copy for which
+// condition holds, the copy being available as c.value
+func sqliteCopyCondition(condition string) string {
+	return `EXISTS (SELECT 1 FROM json_each(data, '$.copies') AS c WHERE ` + condition + `)`
+}
+
+// sqliteTagCondition matches blurays carrying at least one of the given tags
+func sqliteTagCondition(tags []*models.Tag) (string, []interface{}) {
+	placeholders := make([]string, len(tags))
+	args := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		placeholders[i] = "?"
+		args[i] = tag.ID.Hex()
+	}
+	return `EXISTS (SELECT 1 FROM json_each(data, '$.tags') WHERE value IN (` + strings.Join(placeholders, ", ") + `))`, args
+}
+
+// sqliteLocationCondition matches blurays stored in one of the given locations
+func sqliteLocationCondition(ids []primitive.ObjectID) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id.Hex()
+	}
+	return `json_extract(data, '$.location_id."$oid"') IN (` + strings.Join(placeholders, ", ") + `)`, args
+}
+
+func (ds *SQLiteDatastore) PageSimplifiedBlurays(ctx context.Context, filter models.BlurayFilter, page *BlurayPage) ([]*models.SimplifiedBluray, int, error) {
+	where, args, err := sqliteFilter(blurayConditions(filter))
+	if err != nil {
+		return nil, 0, err
+	}
+	return sqlitePage[models.SimplifiedBluray](ctx, ds.db, where, args, page)
+}
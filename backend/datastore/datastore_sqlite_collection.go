@@ -0,0 +1,82 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	collection.ID = primitive.NewObjectID()
+	collection.IsDefault = false
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = collection.CreatedAt
+	return ds.insertCollection(ctx, `INSERT`, collection)
+}
+
+// insertCollection stores a new collection with the given INSERT statement
+func (ds *SQLiteDatastore) insertCollection(ctx context.Context, insert string, collection *models.Collection) error {
+	data, err := marshalDocument(collection)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, insert+` INTO collections (id, data, is_default, created_at) VALUES (?, ?, ?, ?)`,
+		collection.ID.Hex(), data, collection.IsDefault, collection.CreatedAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetCollectionByID(ctx context.Context, id primitive.ObjectID) (*models.Collection, error) {
+	collection, err := queryDocument[models.Collection](ctx, ds.db, `SELECT data FROM collections WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("collection not found")
+	}
+	return collection, err
+}
+
+func (ds *SQLiteDatastore) EnsureDefaultCollection(ctx context.Context) (*models.Collection, error) {
+	// The unique index on is_default keeps concurrent callers from creating two
+	if err := ds.insertCollection(ctx, `INSERT OR IGNORE`, newDefaultCollection()); err != nil {
+		return nil, err
+	}
+	return queryDocument[models.Collection](ctx, ds.db, `SELECT data FROM collections WHERE is_default = 1`)
+}
+
+func (ds *SQLiteDatastore) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	existing, err := queryDocument[models.Collection](ctx, ds.db, `SELECT data FROM collections WHERE id = ?`, collection.ID.Hex())
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	collection.IsDefault = existing.IsDefault
+	collection.CreatedBy = existing.CreatedBy
+	collection.CreatedAt = existing.CreatedAt
+	collection.UpdatedAt = time.Now()
+	data, err := marshalDocument(collection)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE collections SET data = ? WHERE id = ?`, data, collection.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteCollection(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM collections WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListCollections(ctx context.Context, memberID *primitive.ObjectID) ([]*models.Collection, error) {
+	if memberID != nil {
+		return queryDocuments[models.Collection](ctx, ds.db,
+			`SELECT data FROM collections WHERE EXISTS (
+				SELECT 1 FROM json_each(data, '$.members') AS m WHERE json_extract(m.value, '$.user_id."$oid"') = ?
+			) ORDER BY created_at, rowid`, memberID.Hex())
+	}
+	return queryDocuments[models.Collection](ctx, ds.db, `SELECT data FROM collections ORDER BY created_at, rowid`)
+}
@@ -0,0 +1,88 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	loan.ID = primitive.NewObjectID()
+	loan.CreatedAt = time.Now()
+	loan.UpdatedAt = time.Now()
+	data, err := marshalDocument(loan)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO loans (id, bluray_id, data, lent_at) VALUES (?, ?, ?, ?)`,
+		loan.ID.Hex(), loan.BlurayID.Hex(), data, loan.LentAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetLoanByID(ctx context.Context, id primitive.ObjectID) (*models.Loan, error) {
+	loan, err := queryDocument[models.Loan](ctx, ds.db, `SELECT data FROM loans WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("loan not found")
+	}
+	return loan, err
+}
+
+func (ds *SQLiteDatastore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	loan.UpdatedAt = time.Now()
+	data, err := marshalDocument(loan)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE loans SET bluray_id = ?, data = ?, lent_at = ? WHERE id = ?`,
+		loan.BlurayID.Hex(), data, loan.LentAt.UnixNano(), loan.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteLoan(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM loans WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListBlurayLoans(ctx context.Context, blurayID primitive.ObjectID) ([]*models.Loan, error) {
+	return queryDocuments[models.Loan](ctx, ds.db,
+		`SELECT data FROM loans WHERE bluray_id = ? ORDER BY lent_at DESC`, blurayID.Hex())
+}
+
+func (ds *SQLiteDatastore) ListActiveLoans(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Loan, error) {
+	query := `SELECT l.data, COALESCE(json_extract(b.data, '$.title'), ''), COALESCE(json_extract(b.data, '$.collection_id."$oid"'), '')
+		FROM loans l JOIN blurays b ON b.id = l.bluray_id
+		WHERE json_extract(l.data, '$.returned_at') IS NULL AND json_type(b.data, '$.deleted_at') IS NULL`
+	var args []interface{}
+	if collectionID != nil {
+		query += ` AND json_extract(b.data, '$.collection_id."$oid"') = ?`
+		args = append(args, collectionID.Hex())
+	}
+	rows, err := ds.db.QueryContext(ctx, query+` ORDER BY l.lent_at ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []*models.Loan
+	for rows.Next() {
+		var data, collection string
+		loan := &models.Loan{}
+		if err := rows.Scan(&data, &loan.BlurayTitle, &collection); err != nil {
+			return nil, err
+		}
+		if err := unmarshalDocument(data, loan); err != nil {
+			return nil, err
+		}
+		if collection != "" {
+			if loan.CollectionID, err = primitive.ObjectIDFromHex(collection); err != nil {
+				return nil, err
+			}
+		}
+		loans = append(loans, loan)
+	}
+	return loans, rows.Err()
+}
@@ -0,0 +1,93 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateLocation(ctx context.Context, location *models.Location) error {
+	location.ID = primitive.NewObjectID()
+	location.CreatedAt = time.Now()
+	location.UpdatedAt = time.Now()
+	data, err := marshalDocument(location)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO locations (id, data) VALUES (?, ?)`, location.ID.Hex(), data)
+	return err
+}
+
+func (ds *SQLiteDatastore) GetLocationByID(ctx context.Context, id primitive.ObjectID) (*models.Location, error) {
+	location, err := queryDocument[models.Location](ctx, ds.db, `SELECT data FROM locations WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("location not found")
+	}
+	return location, err
+}
+
+func (ds *SQLiteDatastore) UpdateLocation(ctx context.Context, location *models.Location) error {
+	location.UpdatedAt = time.Now()
+	data, err := marshalDocument(location)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE locations SET data = ? WHERE id = ?`, data, location.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteLocation(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM locations WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListLocations(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Location, error) {
+	if collectionID != nil {
+		return queryDocuments[models.Location](ctx, ds.db,
+			`SELECT data FROM locations WHERE `+sqliteCollection+` = ? ORDER BY json_extract(data, '$.name')`, collectionKey(*collectionID))
+	}
+	return queryDocuments[models.Location](ctx, ds.db,
+		`SELECT data FROM locations ORDER BY json_extract(data, '$.name')`)
+}
+
+func (ds *SQLiteDatastore) CountBluraysByLocation(ctx context.Context, collectionID *primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	where, args := `json_extract(data, '$.location_id."$oid"') IS NOT NULL AND json_type(data, '$.deleted_at') IS NULL`, []interface{}{}
+	if collectionID != nil {
+		where += ` AND ` + sqliteCollection + ` = ?`
+		args = append(args, collectionKey(*collectionID))
+	}
+	rows, err := ds.db.QueryContext(ctx, `SELECT json_extract(data, '$.location_id."$oid"'), COUNT(*)
+		FROM blurays WHERE `+where+` GROUP BY 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[primitive.ObjectID]int{}
+	for rows.Next() {
+		var hex string
+		var count int
+		if err := rows.Scan(&hex, &count); err != nil {
+			return nil, err
+		}
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+// searchLocationIDs resolves a location: search value, see matchLocationSubtree
+func (ds *SQLiteDatastore) searchLocationIDs(ctx context.Context, pattern string) ([]primitive.ObjectID, error) {
+	locations, err := ds.ListLocations(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return matchLocationSubtree(locations, pattern)
+}
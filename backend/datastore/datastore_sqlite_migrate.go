@@ -0,0 +1,591 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func (ds *SQLiteDatastore) MigrateUp(ctx context.Context, steps int) ([]MigrationStatus, error) {
+	return runMigrationsUp(ctx, ds, ds.migrations(), steps)
+}
+
+func (ds *SQLiteDatastore) MigrateDown(ctx context.Context, steps int) ([]MigrationStatus, error) {
+	return runMigrationsDown(ctx, ds, ds.migrations(), steps)
+}
+
+func (ds *SQLiteDatastore) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return migrationStatus(ctx, ds, ds.migrations())
+}
+
+func (ds *SQLiteDatastore) appliedMigrations(ctx context.Context) (map[int]MigrationStatus, error) {
+	_, err := ds.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := ds.db.QueryContext(ctx, `SELECT version, description, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]MigrationStatus{}
+	for rows.Next() {
+		var status MigrationStatus
+		var appliedAt int64
+		if err := rows.Scan(&status.Version, &status.Description, &appliedAt); err != nil {
+			return nil, err
+		}
+		status.Applied = true
+		status.AppliedAt = time.Unix(0, appliedAt)
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
+
+func (ds *SQLiteDatastore) recordMigration(ctx context.Context, m migration) error {
+	_, err := ds.db.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Description, time.Now().UnixNano(),
+	)
+	return err
+}
+
+func (ds *SQLiteDatastore) forgetMigration(ctx context.Context, version int) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, version)
+	return err
+}
+
+// migrations lists the schema changes of the SQLite database. Versions are
+// never reused or reordered; append new migrations at the end.
+func (ds *SQLiteDatastore) migrations() []migration {
+	return []migration{
+		{
+			Version:     1,
+			Description: "create initial schema",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx, sqliteSchema...)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`DROP TABLE IF EXISTS password_reset_tokens`,
+					`DROP TABLE IF EXISTS notifications`,
+					`DROP TABLE IF EXISTS tags`,
+					`DROP TABLE IF EXISTS blurays`,
+					`DROP TABLE IF EXISTS users`,
+				)
+			},
+		},
+		{
+			Version:     2,
+			Description: "create loans table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS loans (
+						id TEXT PRIMARY KEY,
+						bluray_id TEXT NOT NULL,
+						data TEXT NOT NULL,
+						lent_at INTEGER NOT NULL
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_loans_bluray_id ON loans (bluray_id, lent_at)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS loans`)
+			},
+		},
+		{
+			Version:     3,
+			Description: "create locations table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS locations (
+						id TEXT PRIMARY KEY,
+						data TEXT NOT NULL
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_blurays_location_id ON blurays (json_extract(data, '$.location_id."$oid"'))`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`DROP INDEX IF EXISTS idx_blurays_location_id`,
+					`DROP TABLE IF EXISTS locations`,
+				)
+			},
+		},
+		{
+			Version:     4,
+			Description: "create release indexes",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE INDEX IF NOT EXISTS idx_blurays_format ON blurays (json_extract(data, '$.format'))`,
+					`CREATE INDEX IF NOT EXISTS idx_blurays_barcode ON blurays (json_extract(data, '$.barcode'))`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`DROP INDEX IF EXISTS idx_blurays_barcode`,
+					`DROP INDEX IF EXISTS idx_blurays_format`,
+				)
+			},
+		},
+		{
+			// A bluray used to be a single owned disc; its purchase and
+			// release fields become its first copy
+			Version:     5,
+			Description: "move purchase and release fields into bluray copies",
+			Up: func(ctx context.Context) error {
+				if err := ds.rewriteBlurays(ctx, `json_type(data, '$.copies') IS NULL`, splitFirstCopy); err != nil {
+					return err
+				}
+				return ds.execStatements(ctx,
+					`DROP INDEX IF EXISTS idx_blurays_barcode`,
+					`DROP INDEX IF EXISTS idx_blurays_format`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				if err := ds.rewriteBlurays(ctx, `json_type(data, '$.copies') IS NOT NULL`, mergeFirstCopy); err != nil {
+					return err
+				}
+				return ds.execStatements(ctx,
+					`CREATE INDEX IF NOT EXISTS idx_blurays_format ON blurays (json_extract(data, '$.format'))`,
+					`CREATE INDEX IF NOT EXISTS idx_blurays_barcode ON blurays (json_extract(data, '$.barcode'))`,
+				)
+			},
+		},
+		{
+			Version:     6,
+			Description: "create wishlist table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS wishlist (
+						id TEXT PRIMARY KEY,
+						data TEXT NOT NULL,
+						priority INTEGER NOT NULL,
+						created_at INTEGER NOT NULL,
+						acquired_at INTEGER
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_wishlist_priority ON wishlist (acquired_at, priority, created_at)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS wishlist`)
+			},
+		},
+		{
+			Version:     7,
+			Description: "create watch events table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS watch_events (
+						id TEXT PRIMARY KEY,
+						user_id TEXT NOT NULL,
+						bluray_id TEXT NOT NULL,
+						data TEXT NOT NULL,
+						watched_at INTEGER NOT NULL
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_watch_events_user_id ON watch_events (user_id, watched_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_watch_events_bluray_id ON watch_events (bluray_id)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS watch_events`)
+			},
+		},
+		{
+			// Blurays used to have a single rating shared by everyone; it
+			// becomes the personal rating of whoever added the bluray
+			Version:     8,
+			Description: "move bluray ratings into personal ratings",
+			Up: func(ctx context.Context) error {
+				err := ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS ratings (
+						id TEXT PRIMARY KEY,
+						user_id TEXT NOT NULL,
+						bluray_id TEXT NOT NULL,
+						data TEXT NOT NULL,
+						updated_at INTEGER NOT NULL,
+						UNIQUE (user_id, bluray_id)
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_ratings_bluray_id ON ratings (bluray_id)`,
+				)
+				if err != nil {
+					return err
+				}
+				if err := ds.moveBlurayRatings(ctx); err != nil {
+					return err
+				}
+				return ds.rewriteBlurays(ctx, `json_type(data, '$.rating') IS NOT NULL`, func(doc bson.M) { delete(doc, "rating") })
+			},
+			Down: func(ctx context.Context) error {
+				ratings, err := queryDocuments[models.UserRating](ctx, ds.db, `SELECT data FROM ratings`)
+				if err != nil {
+					return err
+				}
+				scores := make(map[ratingKey]float64, len(ratings))
+				for _, rating := range ratings {
+					scores[ratingKey{UserID: rating.UserID, BlurayID: rating.BlurayID}] = rating.Score
+				}
+
+				if err := ds.rewriteBlurays(ctx, `1`, func(doc bson.M) { restoreBlurayRating(doc, scores) }); err != nil {
+					return err
+				}
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS ratings`)
+			},
+		},
+		{
+			// Everything stored before collections existed goes into the
+			// default collection
+			Version:     9,
+			Description: "move existing data into a default collection",
+			Up: func(ctx context.Context) error {
+				err := ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS collections (
+						id TEXT PRIMARY KEY,
+						data TEXT NOT NULL,
+						is_default INTEGER NOT NULL,
+						created_at INTEGER NOT NULL
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS idx_collections_default ON collections (is_default) WHERE is_default = 1`,
+				)
+				if err != nil {
+					return err
+				}
+				collection, err := ds.EnsureDefaultCollection(ctx)
+				if err != nil {
+					return err
+				}
+
+				statements := []string{
+					`DROP INDEX IF EXISTS idx_tags_name`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_collection_name ON tags (` + sqliteTagCollection + `, json_extract(data, '$.name'))`,
+					`CREATE INDEX IF NOT EXISTS idx_blurays_collection_id ON blurays (json_extract(data, '$.collection_id."$oid"'))`,
+				}
+				for _, table := range []string{"blurays", "tags", "notifications", "locations", "wishlist", "watch_events"} {
+					statements = append(statements, `UPDATE `+table+` SET data = json_set(data, '$.collection_id', json_object('$oid', '`+collection.ID.Hex()+`'))
+						WHERE json_type(data, '$.collection_id') IS NULL`)
+				}
+				return ds.execStatements(ctx, statements...)
+			},
+			Down: func(ctx context.Context) error {
+				var name string
+				err := ds.db.QueryRowContext(ctx,
+					`SELECT json_extract(data, '$.name') FROM tags GROUP BY json_extract(data, '$.name') HAVING COUNT(*) > 1 LIMIT 1`).Scan(&name)
+				if err == nil {
+					return duplicateTagError(name)
+				}
+				if err != sql.ErrNoRows {
+					return err
+				}
+
+				statements := []string{
+					`DROP INDEX IF EXISTS idx_blurays_collection_id`,
+					`DROP INDEX IF EXISTS idx_tags_collection_name`,
+				}
+				for _, table := range []string{"blurays", "tags", "notifications", "locations", "wishlist", "watch_events"} {
+					statements = append(statements, `UPDATE `+table+` SET data = json_remove(data, '$.collection_id')`)
+				}
+				return ds.execStatements(ctx, append(statements,
+					`CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (json_extract(data, '$.name'))`,
+					`DROP TABLE IF EXISTS collections`,
+				)...)
+			},
+		},
+		{
+			Version:     10,
+			Description: "add roles defined as permission sets",
+			Up: func(ctx context.Context) error {
+				err := ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS roles (
+						id TEXT PRIMARY KEY,
+						name TEXT NOT NULL UNIQUE,
+						data TEXT NOT NULL,
+						created_at INTEGER NOT NULL
+					)`,
+				)
+				if err != nil {
+					return err
+				}
+				return ds.EnsureDefaultRoles(ctx)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS roles`)
+			},
+		},
+		{
+			Version:     11,
+			Description: "create sessions table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS sessions (
+						id TEXT PRIMARY KEY,
+						user_id TEXT NOT NULL,
+						data TEXT NOT NULL,
+						last_used_at INTEGER NOT NULL,
+						expires_at INTEGER NOT NULL
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id, last_used_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS sessions`)
+			},
+		},
+		{
+			Version:     12,
+			Description: "create personal API tokens table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS api_tokens (
+						id TEXT PRIMARY KEY,
+						user_id TEXT NOT NULL,
+						token_hash TEXT NOT NULL UNIQUE,
+						data TEXT NOT NULL,
+						created_at INTEGER NOT NULL
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens (user_id, created_at)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS api_tokens`)
+			},
+		},
+		{
+			Version:     13,
+			Description: "create settings table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS settings (
+						id TEXT PRIMARY KEY,
+						data TEXT NOT NULL
+					)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS settings`)
+			},
+		},
+		{
+			Version:     14,
+			Description: "index single sign-on identities of users",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc ON users (json_extract(data, '$.oidc.issuer'), json_extract(data, '$.oidc.subject'))`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP INDEX IF EXISTS idx_users_oidc`)
+			},
+		},
+		{
+			Version:     15,
+			Description: "create audit log table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS audit_log (
+						id TEXT PRIMARY KEY,
+						actor_id TEXT,
+						action TEXT NOT NULL,
+						entity_type TEXT NOT NULL,
+						entity_id TEXT NOT NULL,
+						collection_id TEXT,
+						data TEXT NOT NULL,
+						created_at INTEGER NOT NULL
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity_type, entity_id, created_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log (actor_id, created_at)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS audit_log`)
+			},
+		},
+		{
+			Version:     16,
+			Description: "create bluray revisions table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS bluray_revisions (
+						id TEXT PRIMARY KEY,
+						bluray_id TEXT NOT NULL,
+						number INTEGER NOT NULL,
+						data TEXT NOT NULL,
+						created_at INTEGER NOT NULL
+					)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS idx_bluray_revisions_number ON bluray_revisions (bluray_id, number)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS bluray_revisions`)
+			},
+		},
+		{
+			Version:     17,
+			Description: "store the search terms of blurays",
+			Up: func(ctx context.Context) error {
+				return ds.rewriteBlurays(ctx, "1", indexSearchTerms)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.rewriteBlurays(ctx, "json_type(data, '$.search_terms') IS NOT NULL", func(doc bson.M) {
+					delete(doc, "search_terms")
+				})
+			},
+		},
+		{
+			Version:     18,
+			Description: "create saved searches table",
+			Up: func(ctx context.Context) error {
+				return ds.execStatements(ctx,
+					`CREATE TABLE IF NOT EXISTS saved_searches (
+						id TEXT PRIMARY KEY,
+						user_id TEXT NOT NULL,
+						collection_id TEXT,
+						data TEXT NOT NULL,
+						created_at INTEGER NOT NULL
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_saved_searches_user_id ON saved_searches (user_id, created_at)`,
+					`CREATE INDEX IF NOT EXISTS idx_saved_searches_collection_id ON saved_searches (collection_id, created_at)`,
+				)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.execStatements(ctx, `DROP TABLE IF EXISTS saved_searches`)
+			},
+		},
+		{
+			Version:     19,
+			Description: "store the sort keys of blurays",
+			Up: func(ctx context.Context) error {
+				return ds.rewriteBlurays(ctx, "1", indexSortKeys)
+			},
+			Down: func(ctx context.Context) error {
+				return ds.rewriteBlurays(ctx, "json_type(data, '$.sort_keys') IS NOT NULL", func(doc bson.M) {
+					delete(doc, "sort_keys")
+				})
+			},
+		},
+	}
+}
+
+// rewriteBlurays replaces, in a single transaction, the data of every bluray
+// matching where with its rewritten document
+func (ds *SQLiteDatastore) rewriteBlurays(ctx context.Context, where string, rewrite func(bson.M)) error {
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, data FROM blurays WHERE `+where)
+	if err != nil {
+		return err
+	}
+	documents := map[string]bson.M{}
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		var doc bson.M
+		if err := unmarshalDocument(data, &doc); err != nil {
+			rows.Close()
+			return err
+		}
+		documents[id] = doc
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, doc := range documents {
+		rewrite(doc)
+		data, err := marshalDocument(doc)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE blurays SET data = ? WHERE id = ?`, data, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// moveBlurayRatings copies the shared rating of every bluray into the ratings
+// table, in a single transaction. Ratings already copied are left as they are.
+func (ds *SQLiteDatastore) moveBlurayRatings(ctx context.Context) error {
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT data FROM blurays WHERE json_extract(data, '$.rating') > 0`)
+	if err != nil {
+		return err
+	}
+	var ratings []*models.UserRating
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return err
+		}
+		var doc bson.M
+		if err := unmarshalDocument(data, &doc); err != nil {
+			rows.Close()
+			return err
+		}
+		rating, ok, err := legacyBlurayRating(doc)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if ok {
+			ratings = append(ratings, rating)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, rating := range ratings {
+		data, err := marshalDocument(rating)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO ratings (id, user_id, bluray_id, data, updated_at) VALUES (?, ?, ?, ?, ?)`,
+			rating.ID.Hex(), rating.UserID.Hex(), rating.BlurayID.Hex(), data, rating.UpdatedAt.UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// execStatements runs statements in a single transaction
+func (ds *SQLiteDatastore) execStatements(ctx context.Context, statements ...string) error {
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,45 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateNotification(ctx context.Context, notification *models.Notification) error {
+	notification.ID = primitive.NewObjectID()
+	notification.CreatedAt = time.Now()
+	notification.Read = false
+	data, err := marshalDocument(notification)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO notifications (id, user_id, data, created_at) VALUES (?, ?, ?, ?)`,
+		notification.ID.Hex(), notification.UserID.Hex(), data, notification.CreatedAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetUserNotifications(ctx context.Context, userID primitive.ObjectID, collectionID *primitive.ObjectID, limit int) ([]*models.Notification, error) {
+	if collectionID != nil {
+		return queryDocuments[models.Notification](ctx, ds.db,
+			`SELECT data FROM notifications WHERE user_id = ?
+				AND COALESCE(json_extract(data, '$.collection_id."$oid"'), ?) = ?
+			ORDER BY created_at DESC LIMIT ?`, userID.Hex(), collectionID.Hex(), collectionID.Hex(), sqliteLimit(limit))
+	}
+	return queryDocuments[models.Notification](ctx, ds.db,
+		`SELECT data FROM notifications WHERE user_id = ? ORDER BY created_at DESC LIMIT ?`, userID.Hex(), sqliteLimit(limit))
+}
+
+func (ds *SQLiteDatastore) MarkNotificationAsRead(ctx context.Context, notificationID primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `UPDATE notifications SET data = json_set(data, '$.read', json('true')) WHERE id = ?`,
+		notificationID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) MarkAllNotificationsAsRead(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `UPDATE notifications SET data = json_set(data, '$.read', json('true'))
+		WHERE user_id = ? AND json_extract(data, '$.read') = 0`, userID.Hex())
+	return err
+}
@@ -0,0 +1,80 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (ds *SQLiteDatastore) CreatePasswordResetToken(userID, token string, expiresAt time.Time) error {
+	ctx := context.Background()
+	resetToken := models.PasswordResetToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	data, err := marshalDocument(resetToken)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO password_reset_tokens (id, token, data) VALUES (?, ?, ?)`,
+		resetToken.ID.Hex(), token, data)
+	return err
+}
+
+func (ds *SQLiteDatastore) VerifyPasswordResetToken(token string) (string, error) {
+	ctx := context.Background()
+
+	resetToken, err := queryDocument[models.PasswordResetToken](ctx, ds.db,
+		`SELECT data FROM password_reset_tokens WHERE token = ?`, token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New("invalid token")
+		}
+		return "", err
+	}
+
+	// Check if token is expired
+	if time.Now().After(resetToken.ExpiresAt) {
+		ds.DeletePasswordResetToken(token)
+		return "", errors.New("token expired")
+	}
+
+	return resetToken.UserID, nil
+}
+
+func (ds *SQLiteDatastore) DeletePasswordResetToken(token string) error {
+	_, err := ds.db.ExecContext(context.Background(), `DELETE FROM password_reset_tokens WHERE token = ?`, token)
+	return err
+}
+
+func (ds *SQLiteDatastore) UpdateUserPassword(userID, newPassword string) error {
+	ctx := context.Background()
+
+	// Hash the new password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	user, err := ds.GetUserByID(ctx, objID)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = string(hashedPassword)
+	return ds.UpdateUser(ctx, user)
+}
@@ -0,0 +1,77 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) SetUserRating(ctx context.Context, rating *models.UserRating) error {
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Updating keeps the ID and creation date of the existing rating
+	var existing string
+	err = tx.QueryRowContext(ctx, `SELECT data FROM ratings WHERE user_id = ? AND bluray_id = ?`,
+		rating.UserID.Hex(), rating.BlurayID.Hex()).Scan(&existing)
+	switch {
+	case err == nil:
+		var previous models.UserRating
+		if err := unmarshalDocument(existing, &previous); err != nil {
+			return err
+		}
+		rating.ID = previous.ID
+		rating.CreatedAt = previous.CreatedAt
+	case err == sql.ErrNoRows:
+		rating.ID = primitive.NewObjectID()
+		rating.CreatedAt = time.Now()
+	default:
+		return err
+	}
+	rating.UpdatedAt = time.Now()
+
+	data, err := marshalDocument(rating)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO ratings (id, user_id, bluray_id, data, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		rating.ID.Hex(), rating.UserID.Hex(), rating.BlurayID.Hex(), data, rating.UpdatedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ds *SQLiteDatastore) GetUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) (*models.UserRating, error) {
+	rating, err := queryDocument[models.UserRating](ctx, ds.db,
+		`SELECT data FROM ratings WHERE user_id = ? AND bluray_id = ?`, userID.Hex(), blurayID.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("rating not found")
+	}
+	return rating, err
+}
+
+func (ds *SQLiteDatastore) DeleteUserRating(ctx context.Context, userID, blurayID primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM ratings WHERE user_id = ? AND bluray_id = ?`, userID.Hex(), blurayID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteBlurayRatings(ctx context.Context, blurayID primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM ratings WHERE bluray_id = ?`, blurayID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListUserRatings(ctx context.Context, userID *primitive.ObjectID) ([]*models.UserRating, error) {
+	if userID != nil {
+		return queryDocuments[models.UserRating](ctx, ds.db,
+			`SELECT data FROM ratings WHERE user_id = ? ORDER BY updated_at DESC`, userID.Hex())
+	}
+	return queryDocuments[models.UserRating](ctx, ds.db, `SELECT data FROM ratings ORDER BY updated_at DESC`)
+}
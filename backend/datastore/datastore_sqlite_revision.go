@@ -0,0 +1,64 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateBlurayRevision(ctx context.Context, revision *models.BlurayRevision) error {
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var last int
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(number), 0) FROM bluray_revisions WHERE bluray_id = ?`,
+		revision.BlurayID.Hex()).Scan(&last)
+	if err != nil {
+		return err
+	}
+
+	revision.ID = primitive.NewObjectID()
+	revision.Number = last + 1
+	revision.CreatedAt = time.Now()
+	data, err := marshalDocument(revision)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO bluray_revisions (id, bluray_id, number, data, created_at) VALUES (?, ?, ?, ?, ?)`,
+		revision.ID.Hex(), revision.BlurayID.Hex(), revision.Number, data, revision.CreatedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ds *SQLiteDatastore) GetBlurayRevision(ctx context.Context, blurayID primitive.ObjectID, number int) (*models.BlurayRevision, error) {
+	revision, err := queryDocument[models.BlurayRevision](ctx, ds.db,
+		`SELECT data FROM bluray_revisions WHERE bluray_id = ? AND number = ?`, blurayID.Hex(), number)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("revision not found")
+	}
+	return revision, err
+}
+
+func (ds *SQLiteDatastore) ListBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID, skip, limit int) ([]*models.BlurayRevision, error) {
+	return queryDocuments[models.BlurayRevision](ctx, ds.db,
+		`SELECT data FROM bluray_revisions WHERE bluray_id = ? ORDER BY number DESC LIMIT ? OFFSET ?`,
+		blurayID.Hex(), sqliteLimit(limit), skip)
+}
+
+func (ds *SQLiteDatastore) CountBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID) (int, error) {
+	return queryCount(ctx, ds.db, `SELECT COUNT(*) FROM bluray_revisions WHERE bluray_id = ?`, blurayID.Hex())
+}
+
+func (ds *SQLiteDatastore) DeleteBlurayRevisions(ctx context.Context, blurayID primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM bluray_revisions WHERE bluray_id = ?`, blurayID.Hex())
+	return err
+}
@@ -0,0 +1,77 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateRole(ctx context.Context, role *models.Role) error {
+	return ds.insertRole(ctx, `INSERT`, role)
+}
+
+// insertRole stores a new role with the given INSERT statement
+func (ds *SQLiteDatastore) insertRole(ctx context.Context, insert string, role *models.Role) error {
+	role.ID = primitive.NewObjectID()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = role.CreatedAt
+	data, err := marshalDocument(role)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, insert+` INTO roles (id, name, data, created_at) VALUES (?, ?, ?, ?)`,
+		role.ID.Hex(), string(role.Name), data, role.CreatedAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetRoleByName(ctx context.Context, name models.UserRole) (*models.Role, error) {
+	role, err := queryDocument[models.Role](ctx, ds.db, `SELECT data FROM roles WHERE name = ?`, string(name))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("role not found")
+	}
+	return role, err
+}
+
+func (ds *SQLiteDatastore) UpdateRole(ctx context.Context, role *models.Role) error {
+	existing, err := queryDocument[models.Role](ctx, ds.db, `SELECT data FROM roles WHERE name = ?`, string(role.Name))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	role.ID = existing.ID
+	role.BuiltIn = existing.BuiltIn
+	role.CreatedAt = existing.CreatedAt
+	role.UpdatedAt = time.Now()
+	data, err := marshalDocument(role)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE roles SET data = ? WHERE name = ?`, data, string(role.Name))
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteRole(ctx context.Context, name models.UserRole) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM roles WHERE name = ?`, string(name))
+	return err
+}
+
+func (ds *SQLiteDatastore) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	return queryDocuments[models.Role](ctx, ds.db, `SELECT data FROM roles ORDER BY created_at, rowid`)
+}
+
+func (ds *SQLiteDatastore) EnsureDefaultRoles(ctx context.Context) error {
+	// An existing role keeps the permissions admins gave it
+	for _, role := range models.DefaultRoles() {
+		if err := ds.insertRole(ctx, `INSERT OR IGNORE`, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
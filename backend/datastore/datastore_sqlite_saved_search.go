@@ -0,0 +1,72 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	search.ID = primitive.NewObjectID()
+	search.CreatedAt = time.Now()
+	search.UpdatedAt = time.Now()
+	data, err := marshalDocument(search)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO saved_searches (id, user_id, collection_id, data, created_at) VALUES (?, ?, ?, ?, ?)`,
+		search.ID.Hex(), search.UserID.Hex(), sqliteSharedIn(search), data, search.CreatedAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetSavedSearchByID(ctx context.Context, id primitive.ObjectID) (*models.SavedSearch, error) {
+	search, err := queryDocument[models.SavedSearch](ctx, ds.db, `SELECT data FROM saved_searches WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("saved search not found")
+	}
+	return search, err
+}
+
+func (ds *SQLiteDatastore) UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	search.UpdatedAt = time.Now()
+	data, err := marshalDocument(search)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE saved_searches SET data = ?, user_id = ?, collection_id = ? WHERE id = ?`,
+		data, search.UserID.Hex(), sqliteSharedIn(search), search.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteSavedSearch(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM saved_searches WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListSavedSearches(ctx context.Context, userID primitive.ObjectID, collectionID *primitive.ObjectID) ([]*models.SavedSearch, error) {
+	var shared interface{}
+	if collectionID != nil {
+		shared = collectionID.Hex()
+	}
+	return queryDocuments[models.SavedSearch](ctx, ds.db,
+		`SELECT data FROM saved_searches WHERE (collection_id IS NULL AND user_id = ?) OR collection_id = ? ORDER BY created_at, rowid`,
+		userID.Hex(), shared)
+}
+
+func (ds *SQLiteDatastore) DeleteUserSavedSearches(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM saved_searches WHERE collection_id IS NULL AND user_id = ?`, userID.Hex())
+	return err
+}
+
+// sqliteSharedIn returns the collection column of a saved search: the
+// collection a smart collection is shared in, NULL for personal searches
+func sqliteSharedIn(search *models.SavedSearch) interface{} {
+	if !search.Shared || search.CollectionID == nil {
+		return nil
+	}
+	return search.CollectionID.Hex()
+}
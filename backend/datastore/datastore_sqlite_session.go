@@ -0,0 +1,109 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateSession(ctx context.Context, session *models.Session) error {
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	data, err := marshalDocument(session)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO sessions (id, user_id, data, last_used_at, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		session.ID.Hex(), session.UserID.Hex(), data, session.LastUsedAt.UnixNano(), session.ExpiresAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetSessionByID(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	session, err := queryDocument[models.Session](ctx, ds.db, `SELECT data FROM sessions WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("session not found")
+	}
+	return session, err
+}
+
+func (ds *SQLiteDatastore) UpdateSession(ctx context.Context, session *models.Session, refreshTokenHash string) error {
+	data, err := marshalDocument(session)
+	if err != nil {
+		return err
+	}
+	result, err := ds.db.ExecContext(ctx,
+		`UPDATE sessions SET data = ?, last_used_at = ?, expires_at = ? WHERE id = ? AND json_extract(data, '$.refresh_token_hash') = ?`,
+		data, session.LastUsedAt.UnixNano(), session.ExpiresAt.UnixNano(), session.ID.Hex(), refreshTokenHash)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrSessionChanged
+	}
+	return nil
+}
+
+func (ds *SQLiteDatastore) ListUserSessions(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error) {
+	return queryDocuments[models.Session](ctx, ds.db,
+		`SELECT data FROM sessions WHERE user_id = ? ORDER BY last_used_at DESC, rowid`, userID.Hex())
+}
+
+func (ds *SQLiteDatastore) RevokeUserSessions(ctx context.Context, userID, except primitive.ObjectID) error {
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT data FROM sessions WHERE user_id = ? AND id != ? AND json_type(data, '$.revoked_at') IS NULL`,
+		userID.Hex(), except.Hex())
+	if err != nil {
+		return err
+	}
+	var sessions []*models.Session
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return err
+		}
+		var session models.Session
+		if err := unmarshalDocument(data, &session); err != nil {
+			rows.Close()
+			return err
+		}
+		sessions = append(sessions, &session)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, session := range sessions {
+		session.RevokedAt = &now
+		data, err := marshalDocument(session)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET data = ? WHERE id = ?`, data, session.ID.Hex()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (ds *SQLiteDatastore) DeleteExpiredSessions(ctx context.Context, before time.Time) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < ?`, before.UnixNano())
+	return err
+}
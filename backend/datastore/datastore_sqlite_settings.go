@@ -0,0 +1,30 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"eylexander/bluraymanager/models"
+	"time"
+)
+
+// sqliteSettingsID is the key of the single row of the settings table
+const sqliteSettingsID = "settings"
+
+func (ds *SQLiteDatastore) GetSettings(ctx context.Context) (*models.Settings, error) {
+	settings, err := queryDocument[models.Settings](ctx, ds.db, `SELECT data FROM settings WHERE id = ?`, sqliteSettingsID)
+	if err == sql.ErrNoRows {
+		return &models.Settings{}, nil
+	}
+	return settings, err
+}
+
+func (ds *SQLiteDatastore) UpdateSettings(ctx context.Context, settings *models.Settings) error {
+	settings.UpdatedAt = time.Now()
+	data, err := marshalDocument(settings)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO settings (id, data) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET data = excluded.data`,
+		sqliteSettingsID, data)
+	return err
+}
@@ -0,0 +1,30 @@
+package datastore
+
+import (
+	"context"
+	"eylexander/bluraymanager/models"
+)
+
+func (ds *SQLiteDatastore) GetStatistics(ctx context.Context, filter models.BlurayFilter) (*models.Statistics, error) {
+	where, args, err := sqliteFilter(blurayConditions(filter))
+	if err != nil {
+		return nil, err
+	}
+	blurays, err := queryDocuments[models.Bluray](ctx, ds.db, `SELECT data FROM blurays WHERE `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return computeStatistics(nil), err
+	}
+	return computeStatistics(blurays), nil
+}
+
+func (ds *SQLiteDatastore) GetSimplifiedStatistics(ctx context.Context, filter models.BlurayFilter) (*models.SimplifiedStatistics, error) {
+	where, args, err := sqliteFilter(blurayConditions(filter))
+	if err != nil {
+		return nil, err
+	}
+	blurays, err := queryDocuments[models.Bluray](ctx, ds.db, `SELECT data FROM blurays WHERE `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return &models.SimplifiedStatistics{}, nil
+	}
+	return computeSimplifiedStatistics(blurays), nil
+}
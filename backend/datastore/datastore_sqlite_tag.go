@@ -0,0 +1,99 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateTag(ctx context.Context, tag *models.Tag) error {
+	tag.ID = primitive.NewObjectID()
+	tag.CreatedAt = time.Now()
+	tag.UpdatedAt = time.Now()
+	data, err := marshalDocument(tag)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO tags (id, data) VALUES (?, ?)`, tag.ID.Hex(), data)
+	return err
+}
+
+func (ds *SQLiteDatastore) GetTagByID(ctx context.Context, id primitive.ObjectID) (*models.Tag, error) {
+	tag, err := queryDocument[models.Tag](ctx, ds.db, `SELECT data FROM tags WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("tag not found")
+	}
+	return tag, err
+}
+
+func (ds *SQLiteDatastore) GetTagByName(ctx context.Context, collectionID primitive.ObjectID, name string) (*models.Tag, error) {
+	tag, err := queryDocument[models.Tag](ctx, ds.db,
+		`SELECT data FROM tags WHERE `+sqliteTagCollection+` = ? AND json_extract(data, '$.name') = ?`, collectionKey(collectionID), name)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("tag not found")
+	}
+	return tag, err
+}
+
+func (ds *SQLiteDatastore) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	tag.UpdatedAt = time.Now()
+	data, err := marshalDocument(tag)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE tags SET data = ? WHERE id = ?`, data, tag.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) SetTagDeletedAt(ctx context.Context, id primitive.ObjectID, deletedAt *time.Time) error {
+	tag, err := ds.GetTagByID(ctx, id)
+	if err != nil {
+		// Updating a missing document is a no-op, as with MongoDB
+		return nil
+	}
+	tag.DeletedAt = deletedAt
+	data, err := marshalDocument(tag)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE tags SET data = ? WHERE id = ?`, data, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteTag(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) RemoveTagFromBlurays(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `UPDATE blurays
+		SET data = json_set(data, '$.tags', json((SELECT json_group_array(value) FROM json_each(data, '$.tags') WHERE value != ?1)))
+		WHERE EXISTS (SELECT 1 FROM json_each(data, '$.tags') WHERE value = ?1)`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListTags(ctx context.Context, collectionID *primitive.ObjectID) ([]*models.Tag, error) {
+	if collectionID != nil {
+		return queryDocuments[models.Tag](ctx, ds.db,
+			`SELECT data FROM tags WHERE `+sqliteTagCollection+` = ? ORDER BY rowid`, collectionKey(*collectionID))
+	}
+	return queryDocuments[models.Tag](ctx, ds.db, `SELECT data FROM tags ORDER BY rowid`)
+}
+
+// sqliteTagCollection is the collection of a tag as indexed by
+// idx_tags_collection_name, see collectionKey
+const sqliteTagCollection = sqliteCollection
+
+// sqliteCollection is the collection of a record, compared to collectionKey
+const sqliteCollection = `COALESCE(json_extract(data, '$.collection_id."$oid"'), '')`
+
+// SearchTagsByName searches for tags by name pattern (case-insensitive),
+// leaving out the tags in the trash
+func (ds *SQLiteDatastore) SearchTagsByName(ctx context.Context, pattern string) ([]*models.Tag, error) {
+	return queryDocuments[models.Tag](ctx, ds.db,
+		`SELECT data FROM tags WHERE COALESCE(json_extract(data, '$.name'), '') REGEXP ? AND json_type(data, '$.deleted_at') IS NULL ORDER BY rowid`, pattern)
+}
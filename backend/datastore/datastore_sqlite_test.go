@@ -0,0 +1,141 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"eylexander/bluraymanager/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// openSQLite opens the database at path with its schema up to date, closing
+// it when the test ends
+func openSQLite(t *testing.T, path string) *SQLiteDatastore {
+	t.Helper()
+	ds, err := NewSQLiteDatastore(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewSQLiteDatastore: %v", err)
+	}
+	t.Cleanup(func() { ds.Close(context.Background()) })
+	if _, err := ds.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	return ds
+}
+
+func TestSQLiteKeepsDocumentsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "library.db")
+
+	ds := openSQLite(t, path)
+	user := &models.User{Username: "alice", Email: "alice@example.com", Role: models.RoleAdmin}
+	if err := ds.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	bluray := &models.Bluray{
+		Title:       "Heat",
+		Type:        models.MediaTypeMovie,
+		Description: models.I18nText{En: "Cops and robbers", Fr: "Flics et voleurs"},
+		AddedBy:     user.ID,
+	}
+	if err := ds.CreateBluray(ctx, bluray); err != nil {
+		t.Fatalf("CreateBluray: %v", err)
+	}
+	ds.Close(ctx)
+
+	// Opening the file again keeps the schema and the documents
+	ds = openSQLite(t, path)
+	got, err := ds.GetUserByUsername(ctx, "alice")
+	if err != nil || got.ID != user.ID || got.Email != "alice@example.com" || got.Role != models.RoleAdmin {
+		t.Fatalf("GetUserByUsername after reopening = %+v, %v", got, err)
+	}
+	if _, err := ds.GetUserByEmail(ctx, "alice@example.com"); err != nil {
+		t.Errorf("GetUserByEmail after reopening: %v", err)
+	}
+	stored, err := ds.GetBlurayByID(ctx, bluray.ID)
+	if err != nil || stored.Title != "Heat" || stored.Description.Fr != "Flics et voleurs" || stored.AddedBy != user.ID {
+		t.Fatalf("GetBlurayByID after reopening = %+v, %v", stored, err)
+	}
+
+	stored.Title = "Heat (1995)"
+	if err := ds.UpdateBluray(ctx, stored); err != nil {
+		t.Fatalf("UpdateBluray: %v", err)
+	}
+	if updated, err := ds.GetBlurayByID(ctx, bluray.ID); err != nil || updated.Title != "Heat (1995)" {
+		t.Errorf("GetBlurayByID after update = %+v, %v", updated, err)
+	}
+	if err := ds.DeleteBluray(ctx, bluray.ID); err != nil {
+		t.Fatalf("DeleteBluray: %v", err)
+	}
+	if _, err := ds.GetBlurayByID(ctx, bluray.ID); err == nil {
+		t.Error("GetBlurayByID found a deleted bluray")
+	}
+}
+
+func TestSQLitePasswordResetTokens(t *testing.T) {
+	ctx := context.Background()
+	ds := openSQLite(t, filepath.Join(t.TempDir(), "library.db"))
+
+	user := &models.User{Username: "bob", Email: "bob@example.com", Role: models.RoleUser}
+	if err := ds.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	if err := ds.CreatePasswordResetToken(user.ID.Hex(), "fresh", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordResetToken: %v", err)
+	}
+	if userID, err := ds.VerifyPasswordResetToken("fresh"); err != nil || userID != user.ID.Hex() {
+		t.Errorf("VerifyPasswordResetToken(fresh) = %q, %v, want the user", userID, err)
+	}
+
+	// Expired tokens are refused and dropped
+	if err := ds.CreatePasswordResetToken(user.ID.Hex(), "stale", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("CreatePasswordResetToken: %v", err)
+	}
+	if _, err := ds.VerifyPasswordResetToken("stale"); err == nil || err.Error() != "token expired" {
+		t.Errorf("VerifyPasswordResetToken(stale) error = %v, want token expired", err)
+	}
+	if _, err := ds.VerifyPasswordResetToken("stale"); err == nil || err.Error() != "invalid token" {
+		t.Errorf("VerifyPasswordResetToken(stale) again error = %v, want invalid token", err)
+	}
+
+	if err := ds.UpdateUserPassword(user.ID.Hex(), "new-secret"); err != nil {
+		t.Fatalf("UpdateUserPassword: %v", err)
+	}
+	got, err := ds.GetUserByID(ctx, user.ID)
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(got.PasswordHash), []byte("new-secret")) != nil {
+		t.Errorf("password hash after UpdateUserPassword does not match, err = %v", err)
+	}
+
+	if err := ds.DeletePasswordResetToken("fresh"); err != nil {
+		t.Fatalf("DeletePasswordResetToken: %v", err)
+	}
+	if _, err := ds.VerifyPasswordResetToken("fresh"); err == nil {
+		t.Error("VerifyPasswordResetToken accepted a deleted token")
+	}
+}
+
+func TestRegexpCacheIsBounded(t *testing.T) {
+	for i := 0; i < sqliteRegexpCacheSize*2; i++ {
+		if _, err := sqliteRegexp(fmt.Sprintf("pattern%d", i), "value"); err != nil {
+			t.Fatalf("sqliteRegexp: %v", err)
+		}
+	}
+	matched, err := sqliteRegexp("VALUE", "a value")
+	if err != nil || !matched {
+		t.Errorf("sqliteRegexp(VALUE, a value) = %v, %v, want a case-insensitive match", matched, err)
+	}
+	if n := len(sqliteRegexps.entries); n != sqliteRegexpCacheSize || sqliteRegexps.order.Len() != n {
+		t.Errorf("cache holds %d patterns, want %d", n, sqliteRegexpCacheSize)
+	}
+	if _, ok := sqliteRegexps.entries["pattern0"]; ok {
+		t.Error("the least recently used pattern is still cached")
+	}
+	if _, err := sqliteRegexp("[a", "value"); err == nil {
+		t.Error("sqliteRegexp([a) compiled an invalid pattern")
+	}
+}
@@ -0,0 +1,76 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateUser(ctx context.Context, user *models.User) error {
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+	data, err := marshalDocument(user)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO users (id, data) VALUES (?, ?)`, user.ID.Hex(), data)
+	return err
+}
+
+func (ds *SQLiteDatastore) getUser(ctx context.Context, where string, args ...interface{}) (*models.User, error) {
+	user, err := queryDocument[models.User](ctx, ds.db, `SELECT data FROM users WHERE `+where, args...)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
+	}
+	return user, err
+}
+
+func (ds *SQLiteDatastore) GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return ds.getUser(ctx, `id = ?`, id.Hex())
+}
+
+func (ds *SQLiteDatastore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return ds.getUser(ctx, `json_extract(data, '$.email') = ?`, email)
+}
+
+func (ds *SQLiteDatastore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return ds.getUser(ctx, `json_extract(data, '$.username') = ?`, username)
+}
+
+func (ds *SQLiteDatastore) GetUserByExternalIdentity(ctx context.Context, identity models.ExternalIdentity) (*models.User, error) {
+	return ds.getUser(ctx, `json_extract(data, '$.oidc.issuer') = ? AND json_extract(data, '$.oidc.subject') = ?`, identity.Issuer, identity.Subject)
+}
+
+func (ds *SQLiteDatastore) UpdateUser(ctx context.Context, user *models.User) error {
+	user.UpdatedAt = time.Now()
+	data, err := marshalDocument(user)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE users SET data = ? WHERE id = ?`, data, user.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListUsers(ctx context.Context, skip, limit int) ([]*models.User, error) {
+	return queryDocuments[models.User](ctx, ds.db, `SELECT data FROM users ORDER BY rowid LIMIT ? OFFSET ?`, sqliteLimit(limit), skip)
+}
+
+func (ds *SQLiteDatastore) CountUsers(ctx context.Context) (int, error) {
+	return queryCount(ctx, ds.db, `SELECT COUNT(*) FROM users`)
+}
+
+// EnsureGuestUser creates a guest user if it doesn't exist, or migrates
+// an existing guest user's locale to the current format.
+func (ds *SQLiteDatastore) EnsureGuestUser(ctx context.Context) (bool, error) {
+	return ensureGuestUser(ctx, ds)
+}
@@ -0,0 +1,102 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateWatchEvent(ctx context.Context, event *models.WatchEvent) error {
+	event.ID = primitive.NewObjectID()
+	event.CreatedAt = time.Now()
+	event.UpdatedAt = time.Now()
+	data, err := marshalDocument(event)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO watch_events (id, user_id, bluray_id, data, watched_at) VALUES (?, ?, ?, ?, ?)`,
+		event.ID.Hex(), event.UserID.Hex(), event.BlurayID.Hex(), data, event.WatchedAt.UnixNano())
+	return err
+}
+
+func (ds *SQLiteDatastore) GetWatchEventByID(ctx context.Context, id primitive.ObjectID) (*models.WatchEvent, error) {
+	event, err := queryDocument[models.WatchEvent](ctx, ds.db, `SELECT data FROM watch_events WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("watch event not found")
+	}
+	return event, err
+}
+
+func (ds *SQLiteDatastore) UpdateWatchEvent(ctx context.Context, event *models.WatchEvent) error {
+	event.UpdatedAt = time.Now()
+	data, err := marshalDocument(event)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE watch_events SET user_id = ?, bluray_id = ?, data = ?, watched_at = ? WHERE id = ?`,
+		event.UserID.Hex(), event.BlurayID.Hex(), data, event.WatchedAt.UnixNano(), event.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteWatchEvent(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM watch_events WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteBlurayWatchEvents(ctx context.Context, blurayID primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM watch_events WHERE bluray_id = ?`, blurayID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListWatchEvents(ctx context.Context, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID, skip, limit int) ([]*models.WatchEvent, error) {
+	where, args := watchEventsWhere(userID, collectionID, blurayID)
+	return queryDocuments[models.WatchEvent](ctx, ds.db,
+		`SELECT data FROM watch_events WHERE `+where+` ORDER BY watched_at DESC LIMIT ? OFFSET ?`,
+		append(args, sqliteLimit(limit), skip)...)
+}
+
+func (ds *SQLiteDatastore) CountWatchEvents(ctx context.Context, userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID) (int, error) {
+	where, args := watchEventsWhere(userID, collectionID, blurayID)
+	return queryCount(ctx, ds.db, `SELECT COUNT(*) FROM watch_events WHERE `+where, args...)
+}
+
+// watchEventsWhere returns the condition matching the history of the user,
+// with its arguments
+func watchEventsWhere(userID primitive.ObjectID, collectionID, blurayID *primitive.ObjectID) (string, []interface{}) {
+	where, args := `user_id = ?`, []interface{}{userID.Hex()}
+	if collectionID != nil {
+		where += ` AND ` + sqliteCollection + ` = ?`
+		args = append(args, collectionKey(*collectionID))
+	}
+	if blurayID != nil {
+		where += ` AND bluray_id = ?`
+		args = append(args, blurayID.Hex())
+	}
+	return where, args
+}
+
+func (ds *SQLiteDatastore) ListWatchedBlurayIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	rows, err := ds.db.QueryContext(ctx, `SELECT DISTINCT bluray_id FROM watch_events WHERE user_id = ?`, userID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []primitive.ObjectID
+	for rows.Next() {
+		var hex string
+		if err := rows.Scan(&hex); err != nil {
+			return nil, err
+		}
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,70 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"eylexander/bluraymanager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (ds *SQLiteDatastore) CreateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	item.ID = primitive.NewObjectID()
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = time.Now()
+	data, err := marshalDocument(item)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO wishlist (id, data, priority, created_at, acquired_at) VALUES (?, ?, ?, ?, ?)`,
+		item.ID.Hex(), data, item.Priority, item.CreatedAt.UnixNano(), sqliteAcquiredAt(item))
+	return err
+}
+
+func (ds *SQLiteDatastore) GetWishlistItemByID(ctx context.Context, id primitive.ObjectID) (*models.WishlistItem, error) {
+	item, err := queryDocument[models.WishlistItem](ctx, ds.db, `SELECT data FROM wishlist WHERE id = ?`, id.Hex())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("wishlist item not found")
+	}
+	return item, err
+}
+
+func (ds *SQLiteDatastore) UpdateWishlistItem(ctx context.Context, item *models.WishlistItem) error {
+	item.UpdatedAt = time.Now()
+	data, err := marshalDocument(item)
+	if err != nil {
+		return err
+	}
+	_, err = ds.db.ExecContext(ctx, `UPDATE wishlist SET data = ?, priority = ?, acquired_at = ? WHERE id = ?`,
+		data, item.Priority, sqliteAcquiredAt(item), item.ID.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) DeleteWishlistItem(ctx context.Context, id primitive.ObjectID) error {
+	_, err := ds.db.ExecContext(ctx, `DELETE FROM wishlist WHERE id = ?`, id.Hex())
+	return err
+}
+
+func (ds *SQLiteDatastore) ListWishlistItems(ctx context.Context, collectionID *primitive.ObjectID, acquired bool) ([]*models.WishlistItem, error) {
+	where, args := `1`, []interface{}{}
+	if collectionID != nil {
+		where, args = sqliteCollection+` = ?`, append(args, collectionKey(*collectionID))
+	}
+	if acquired {
+		return queryDocuments[models.WishlistItem](ctx, ds.db,
+			`SELECT data FROM wishlist WHERE `+where+` AND acquired_at IS NOT NULL ORDER BY acquired_at DESC`, args...)
+	}
+	return queryDocuments[models.WishlistItem](ctx, ds.db,
+		`SELECT data FROM wishlist WHERE `+where+` AND acquired_at IS NULL ORDER BY priority DESC, created_at DESC`, args...)
+}
+
+// sqliteAcquiredAt returns the value of the acquired_at column, NULL while
+// the item is still wanted
+func sqliteAcquiredAt(item *models.WishlistItem) interface{} {
+	if item.AcquiredAt == nil {
+		return nil
+	}
+	return item.AcquiredAt.UnixNano()
+}
@@ -0,0 +1,190 @@
+package datastore
+
+import (
+	"eylexander/bluraymanager/models"
+)
+
+// computeStatistics builds the collection statistics from a full list of
+// blurays. It mirrors the aggregation pipeline used by MongoDatastore so that
+// backends without an aggregation engine report the same numbers. Ratings are
+// personal and left to the controller.
+func computeStatistics(blurays []*models.Bluray) *models.Statistics {
+	stats := &models.Statistics{
+		GenreDistribution: make(map[string]int),
+		TagDistribution:   make(map[string]int),
+		TopRated:          []models.BlurayStats{},
+	}
+
+	if len(blurays) == 0 {
+		return stats
+	}
+
+	var seriesFactor, movieFactor, copyCount int
+	var mostExpensivePrice float64
+	var oldest, newest, mostExpensive *models.Bluray
+
+	for _, b := range blurays {
+		// Every copy of a title is on the shelf, a series copy being one
+		// disc per season
+		copies := blurayCopyCount(b)
+		copyCount += copies
+
+		switch b.Type {
+		case models.MediaTypeSeries:
+			seasonCount := len(b.Seasons)
+			if seasonCount == 0 {
+				seasonCount = 1
+			}
+			seriesFactor += seasonCount * copies
+			stats.TotalSeries++
+		case models.MediaTypeMovie:
+			movieFactor += copies
+		}
+
+		// Episode lists, when known, take precedence over the counts, and
+		// their runtimes over the runtime of the bluray
+		episodeRuntime := 0
+		for i := range b.Seasons {
+			stats.TotalEpisodes += b.Seasons[i].EpisodeTotal()
+			episodeRuntime += b.Seasons[i].RuntimeMinutes()
+		}
+		if episodeRuntime > 0 {
+			stats.TotalRuntimeMinutes += episodeRuntime
+		} else {
+			stats.TotalRuntimeMinutes += b.Runtime
+		}
+
+		// Copies without a purchase price are counted at a flat estimate
+		stats.TotalSpent += blurayCopySpent(b)
+		if price := blurayMaxCopyPrice(b); mostExpensive == nil || price > mostExpensivePrice {
+			mostExpensive = b
+			mostExpensivePrice = price
+		}
+
+		for _, genre := range b.Genre.En {
+			stats.GenreDistribution[genre]++
+		}
+		for _, tag := range b.Tags {
+			stats.TagDistribution[tag]++
+		}
+
+		if b.ReleaseYear > 0 {
+			if oldest == nil || b.ReleaseYear < oldest.ReleaseYear {
+				oldest = b
+			}
+			if newest == nil || b.ReleaseYear > newest.ReleaseYear {
+				newest = b
+			}
+		}
+	}
+
+	stats.TotalMovies = movieFactor
+	stats.TotalSeasons = seriesFactor
+	stats.TotalBlurays = seriesFactor + movieFactor
+	stats.TotalCopies = copyCount
+
+	if stats.TotalBlurays > 0 {
+		stats.AveragePrice = stats.TotalSpent / float64(copyCount)
+	}
+
+	// Calculate storage and volume
+	stats.PhysicalVolumeLiters = float64(seriesFactor)*0.3 + float64(movieFactor)*0.3
+	stats.PhysicalStorageGB = float64(seriesFactor)*30.0 + float64(movieFactor)*35.0
+
+	if oldest != nil {
+		stats.OldestBluray = &models.BlurayStats{
+			ID:          oldest.ID.Hex(),
+			Title:       oldest.Title,
+			Type:        string(oldest.Type),
+			ReleaseYear: oldest.ReleaseYear,
+		}
+	}
+	if newest != nil {
+		stats.NewestBluray = &models.BlurayStats{
+			ID:          newest.ID.Hex(),
+			Title:       newest.Title,
+			Type:        string(newest.Type),
+			ReleaseYear: newest.ReleaseYear,
+		}
+	}
+	if mostExpensive != nil {
+		stats.MostExpensive = &models.BlurayStats{
+			ID:            mostExpensive.ID.Hex(),
+			Title:         mostExpensive.Title,
+			Type:          string(mostExpensive.Type),
+			PurchasePrice: mostExpensivePrice,
+		}
+	}
+
+	return stats
+}
+
+// computeSimplifiedStatistics is the in-process counterpart of
+// MongoDatastore.GetSimplifiedStatistics.
+func computeSimplifiedStatistics(blurays []*models.Bluray) *models.SimplifiedStatistics {
+	stats := &models.SimplifiedStatistics{}
+
+	var physicalBlurayCount int
+	for _, b := range blurays {
+		copies := blurayCopyCount(b)
+		stats.TotalCopies += copies
+
+		switch b.Type {
+		case models.MediaTypeMovie:
+			stats.TotalMovies++
+		case models.MediaTypeSeries:
+			stats.TotalSeries++
+		}
+
+		if b.Type == models.MediaTypeSeries {
+			seasonCount := len(b.Seasons)
+			if seasonCount == 0 {
+				seasonCount = 1 // Count series with no seasons as 1 bluray
+			}
+			stats.TotalSeasons += seasonCount
+			physicalBlurayCount += seasonCount * copies // Each season of each copy is 1 physical bluray
+		} else {
+			physicalBlurayCount += copies // Each copy of a movie is 1 physical bluray
+		}
+	}
+
+	stats.TotalBlurays = physicalBlurayCount
+
+	return stats
+}
+
+// blurayCopyCount is the number of copies of a title, titles stored before
+// copies existed counting as one
+func blurayCopyCount(b *models.Bluray) int {
+	if len(b.Copies) == 0 {
+		return 1
+	}
+	return len(b.Copies)
+}
+
+// blurayCopySpent sums the purchase price of the copies of a title, counting
+// copies without a price at a flat estimate of 4
+func blurayCopySpent(b *models.Bluray) float64 {
+	if len(b.Copies) == 0 {
+		return 4
+	}
+	spent := 0.0
+	for _, c := range b.Copies {
+		if c.PurchasePrice > 0 {
+			spent += c.PurchasePrice
+		} else {
+			spent += 4
+		}
+	}
+	return spent
+}
+
+func blurayMaxCopyPrice(b *models.Bluray) float64 {
+	price := 0.0
+	for _, c := range b.Copies {
+		if c.PurchasePrice > price {
+			price = c.PurchasePrice
+		}
+	}
+	return price
+}
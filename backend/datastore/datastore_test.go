@@ -0,0 +1,78 @@
+package datastore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"eylexander/bluraymanager/datastore"
+	"eylexander/bluraymanager/datastore/datastoretest"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	_ datastore.Datastore = (*datastore.MongoDatastore)(nil)
+	_ datastore.Datastore = (*datastore.SQLiteDatastore)(nil)
+	_ datastore.Datastore = (*datastore.MemoryDatastore)(nil)
+
+	_ datastore.Migrator = (*datastore.MongoDatastore)(nil)
+	_ datastore.Migrator = (*datastore.SQLiteDatastore)(nil)
+)
+
+func TestMemoryDatastore(t *testing.T) {
+	datastoretest.Run(t, func(t *testing.T) datastore.Datastore {
+		return datastore.NewMemoryDatastore()
+	})
+}
+
+func TestSQLiteDatastore(t *testing.T) {
+	datastoretest.Run(t, func(t *testing.T) datastore.Datastore {
+		ds, err := datastore.NewSQLiteDatastore(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteDatastore: %v", err)
+		}
+		t.Cleanup(func() { ds.Close(context.Background()) })
+		if _, err := ds.MigrateUp(context.Background(), 0); err != nil {
+			t.Fatalf("MigrateUp: %v", err)
+		}
+		return ds
+	})
+}
+
+// TestMongoDatastore runs the suite against a real server when
+// MONGODB_TEST_URI is set. Each subtest uses its own throwaway database.
+func TestMongoDatastore(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	datastoretest.Run(t, func(t *testing.T) datastore.Datastore {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		dbName := "bluray_manager_test_" + primitive.NewObjectID().Hex()
+		ds, err := datastore.NewMongoDatastore(ctx, uri, dbName)
+		if err != nil {
+			t.Fatalf("NewMongoDatastore: %v", err)
+		}
+
+		t.Cleanup(func() {
+			ctx := context.Background()
+			ds.Close(ctx)
+			if client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri)); err == nil {
+				client.Database(dbName).Drop(ctx)
+				client.Disconnect(ctx)
+			}
+		})
+		if _, err := ds.MigrateUp(ctx, 0); err != nil {
+			t.Fatalf("MigrateUp: %v", err)
+		}
+		return ds
+	})
+}
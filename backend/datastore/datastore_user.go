@@ -0,0 +1,52 @@
+package datastore
+
+import (
+	"context"
+
+	"eylexander/bluraymanager/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const guestUserEmail = "guest@bluray-manager.local"
+
+// ensureGuestUser implements EnsureGuestUser on top of the user operations
+// every backend already provides.
+func ensureGuestUser(ctx context.Context, ds Datastore) (bool, error) {
+	// Locale migration map: old short codes → new BCP-47 tags
+	localeMap := map[string]string{
+		"en": "en-US",
+		"fr": "fr-FR",
+	}
+
+	// Check if guest user already exists
+	existingUser, err := ds.GetUserByEmail(ctx, guestUserEmail)
+	if err == nil {
+		// Migrate outdated locale format if necessary
+		if newLocale, outdated := localeMap[existingUser.Settings.Language]; outdated {
+			existingUser.Settings.Language = newLocale
+			_ = ds.UpdateUser(ctx, existingUser)
+		}
+		return false, nil
+	}
+
+	// Create guest user
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("guest"), bcrypt.DefaultCost)
+	if err != nil {
+		return false, err
+	}
+
+	guestUser := &models.User{
+		Username:     "guest",
+		Email:        guestUserEmail,
+		PasswordHash: string(hashedPassword),
+		Role:         models.RoleGuest,
+		Settings: models.UserSettings{
+			Theme:    "dark",
+			Language: "en-US",
+		},
+	}
+
+	err = ds.CreateUser(ctx, guestUser)
+	return err == nil, err
+}
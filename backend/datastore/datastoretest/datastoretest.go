@@ -0,0 +1,2209 @@
+// Package datastoretest provides a conformance suite that every
+// datastore.Datastore implementation is expected to pass.
+//
+// A backend test only needs to supply a factory returning an empty store:
+//
+//	func TestMyDatastore(t *testing.T) {
+//		datastoretest.Run(t, func(t *testing.T) datastore.Datastore {
+//			return newEmptyStore(t)
+//		})
+//	}
+package datastoretest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"eylexander/bluraymanager/datastore"
+	"eylexander/bluraymanager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Factory returns a fresh, empty datastore. It is called once per subtest and
+// should register any cleanup with t.Cleanup.
+type Factory func(t *testing.T) datastore.Datastore
+
+// Run executes the whole conformance suite against the backend built by newDatastore
+func Run(t *testing.T, newDatastore Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, ds datastore.Datastore)
+	}{
+		{"Users", testUsers},
+		{"GuestUser", testGuestUser},
+		{"Roles", testRoles},
+		{"Blurays", testBlurays},
+		{"BlurayFilters", testBlurayFilters},
+		{"SearchBlurays", testSearchBlurays},
+		{"SearchQueries", testSearchQueries},
+		{"BlurayPages", testBlurayPages},
+		{"Tags", testTags},
+		{"Statistics", testStatistics},
+		{"Notifications", testNotifications},
+		{"Loans", testLoans},
+		{"Locations", testLocations},
+		{"Wishlist", testWishlist},
+		{"WatchEvents", testWatchEvents},
+		{"Ratings", testRatings},
+		{"Collections", testCollections},
+		{"CollectionScoping", testCollectionScoping},
+		{"Trash", testTrash},
+		{"BlurayRevisions", testBlurayRevisions},
+		{"Sessions", testSessions},
+		{"APITokens", testAPITokens},
+		{"SavedSearches", testSavedSearches},
+		{"Settings", testSettings},
+		{"AuditLog", testAuditLog},
+		{"PasswordResetTokens", testPasswordResetTokens},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newDatastore(t))
+		})
+	}
+}
+
+// pause keeps consecutive inserts in distinct milliseconds, the resolution
+// of stored timestamps, so that ordering assertions are deterministic
+func pause() {
+	time.Sleep(3 * time.Millisecond)
+}
+
+func mustNoError(t *testing.T, err error, action string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", action, err)
+	}
+}
+
+func assertFloat(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+// newest asks for the blurays newest first, the order listings default to
+func newest(skip, limit int) *datastore.BlurayPage {
+	return &datastore.BlurayPage{Field: models.SortAdded, Desc: true, Skip: skip, Limit: limit}
+}
+
+// byScore orders search results by their score, as relevance sorts do
+func byScore(blurays []*models.Bluray) []*models.Bluray {
+	sort.SliceStable(blurays, func(i, j int) bool {
+		return blurays[i].Score > blurays[j].Score
+	})
+	return blurays
+}
+
+func titles(blurays []*models.Bluray) []string {
+	result := make([]string, 0, len(blurays))
+	for _, b := range blurays {
+		result = append(result, b.Title)
+	}
+	return result
+}
+
+func assertTitles(t *testing.T, name string, got []*models.Bluray, want ...string) {
+	t.Helper()
+	gotTitles := titles(got)
+	if len(gotTitles) != len(want) {
+		t.Errorf("%s = %v, want %v", name, gotTitles, want)
+		return
+	}
+	for i := range want {
+		if gotTitles[i] != want[i] {
+			t.Errorf("%s = %v, want %v", name, gotTitles, want)
+			return
+		}
+	}
+}
+
+func testUsers(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	user := &models.User{
+		Username:     "alice",
+		Email:        "alice@example.com",
+		PasswordHash: "hash",
+		Role:         models.RoleUser,
+		Settings:     models.UserSettings{Theme: "dark", Language: "fr-FR"},
+	}
+	mustNoError(t, ds.CreateUser(ctx, user), "CreateUser")
+	if user.ID.IsZero() || user.CreatedAt.IsZero() {
+		t.Fatal("CreateUser did not assign an ID and creation time")
+	}
+
+	got, err := ds.GetUserByID(ctx, user.ID)
+	mustNoError(t, err, "GetUserByID")
+	if got.Username != "alice" || got.PasswordHash != "hash" || got.Settings.Language != "fr-FR" {
+		t.Errorf("GetUserByID returned %+v", got)
+	}
+	if _, err := ds.GetUserByEmail(ctx, "alice@example.com"); err != nil {
+		t.Errorf("GetUserByEmail: %v", err)
+	}
+	if _, err := ds.GetUserByUsername(ctx, "alice"); err != nil {
+		t.Errorf("GetUserByUsername: %v", err)
+	}
+	if _, err := ds.GetUserByEmail(ctx, "nobody@example.com"); err == nil {
+		t.Error("GetUserByEmail found a user that does not exist")
+	}
+	if _, err := ds.GetUserByID(ctx, primitive.NewObjectID()); err == nil {
+		t.Error("GetUserByID found a user that does not exist")
+	}
+
+	duplicateEmail := &models.User{Username: "alice2", Email: "alice@example.com"}
+	if err := ds.CreateUser(ctx, duplicateEmail); err == nil {
+		t.Error("CreateUser accepted a duplicate email")
+	}
+	duplicateName := &models.User{Username: "alice", Email: "other@example.com"}
+	if err := ds.CreateUser(ctx, duplicateName); err == nil {
+		t.Error("CreateUser accepted a duplicate username")
+	}
+
+	got.Role = models.RoleModerator
+	got.Username = "alice.b"
+	mustNoError(t, ds.UpdateUser(ctx, got), "UpdateUser")
+	updated, err := ds.GetUserByID(ctx, user.ID)
+	mustNoError(t, err, "GetUserByID after update")
+	if updated.Role != models.RoleModerator || updated.Username != "alice.b" {
+		t.Errorf("UpdateUser did not persist changes: %+v", updated)
+	}
+
+	mustNoError(t, ds.UpdateUserPassword(user.ID.Hex(), "new-password"), "UpdateUserPassword")
+	updated, err = ds.GetUserByID(ctx, user.ID)
+	mustNoError(t, err, "GetUserByID after password update")
+	if updated.PasswordHash == "hash" || updated.PasswordHash == "new-password" {
+		t.Errorf("UpdateUserPassword stored %q, want a bcrypt hash", updated.PasswordHash)
+	}
+
+	// Turning two-factor authentication off clears every field of it
+	updated.TwoFactor = models.TwoFactor{Enabled: true, Secret: "JBSWY3DPEHPK3PXP", RecoveryCodes: []string{"a", "b"}, LastUsedStep: 42}
+	mustNoError(t, ds.UpdateUser(ctx, updated), "UpdateUser enabling two-factor")
+	updated.TwoFactor = models.TwoFactor{}
+	mustNoError(t, ds.UpdateUser(ctx, updated), "UpdateUser clearing two-factor")
+	updated, err = ds.GetUserByID(ctx, user.ID)
+	mustNoError(t, err, "GetUserByID after clearing two-factor")
+	if updated.TwoFactor.Enabled || updated.TwoFactor.Secret != "" || len(updated.TwoFactor.RecoveryCodes) != 0 || updated.TwoFactor.LastUsedStep != 0 {
+		t.Errorf("cleared two-factor = %+v", updated.TwoFactor)
+	}
+
+	// Unlocking an account clears the lock
+	lockedUntil := time.Now().Add(time.Hour)
+	updated.Lockout = models.Lockout{FailedAttempts: 2, LockedUntil: &lockedUntil, UnlockTokenHash: "hash"}
+	mustNoError(t, ds.UpdateUser(ctx, updated), "UpdateUser locking")
+	updated.Lockout = models.Lockout{}
+	mustNoError(t, ds.UpdateUser(ctx, updated), "UpdateUser unlocking")
+	updated, err = ds.GetUserByID(ctx, user.ID)
+	mustNoError(t, err, "GetUserByID after unlocking")
+	if updated.Lockout.LockedUntil != nil || updated.Lockout.FailedAttempts != 0 || updated.Lockout.UnlockTokenHash != "" {
+		t.Errorf("cleared lockout = %+v", updated.Lockout)
+	}
+
+	// Users signing in with an identity provider are found by their account there
+	identity := models.ExternalIdentity{Issuer: "https://id.example.com", Subject: "248289761001"}
+	if _, err := ds.GetUserByExternalIdentity(ctx, identity); err == nil {
+		t.Error("GetUserByExternalIdentity found a user that is not linked")
+	}
+	updated.OIDC = &identity
+	mustNoError(t, ds.UpdateUser(ctx, updated), "UpdateUser linking identity")
+	linked, err := ds.GetUserByExternalIdentity(ctx, identity)
+	mustNoError(t, err, "GetUserByExternalIdentity")
+	if linked.ID != user.ID {
+		t.Errorf("GetUserByExternalIdentity returned user %v, want %v", linked.ID, user.ID)
+	}
+	other := models.ExternalIdentity{Issuer: "https://other.example.com", Subject: identity.Subject}
+	if _, err := ds.GetUserByExternalIdentity(ctx, other); err == nil {
+		t.Error("GetUserByExternalIdentity matched the subject of another issuer")
+	}
+
+	bob := &models.User{Username: "bob", Email: "bob@example.com"}
+	mustNoError(t, ds.CreateUser(ctx, bob), "CreateUser bob")
+
+	users, err := ds.ListUsers(ctx, 0, 10)
+	mustNoError(t, err, "ListUsers")
+	if len(users) != 2 {
+		t.Errorf("ListUsers returned %d users, want 2", len(users))
+	}
+	users, err = ds.ListUsers(ctx, 1, 10)
+	mustNoError(t, err, "ListUsers with skip")
+	if len(users) != 1 {
+		t.Errorf("ListUsers(skip=1) returned %d users, want 1", len(users))
+	}
+	if count, err := ds.CountUsers(ctx); err != nil || count != 2 {
+		t.Errorf("CountUsers = %d, %v, want 2", count, err)
+	}
+
+	mustNoError(t, ds.DeleteUser(ctx, user.ID), "DeleteUser")
+	if _, err := ds.GetUserByID(ctx, user.ID); err == nil {
+		t.Error("GetUserByID found a deleted user")
+	}
+}
+
+func testGuestUser(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	created, err := ds.EnsureGuestUser(ctx)
+	mustNoError(t, err, "EnsureGuestUser")
+	if !created {
+		t.Error("EnsureGuestUser did not report creating the guest user")
+	}
+
+	guest, err := ds.GetUserByUsername(ctx, "guest")
+	mustNoError(t, err, "GetUserByUsername guest")
+	if guest.Role != models.RoleGuest {
+		t.Errorf("guest role = %q, want %q", guest.Role, models.RoleGuest)
+	}
+
+	// Outdated locales are migrated on the next start
+	guest.Settings.Language = "fr"
+	mustNoError(t, ds.UpdateUser(ctx, guest), "UpdateUser guest")
+
+	created, err = ds.EnsureGuestUser(ctx)
+	mustNoError(t, err, "EnsureGuestUser again")
+	if created {
+		t.Error("EnsureGuestUser created a second guest user")
+	}
+	guest, err = ds.GetUserByUsername(ctx, "guest")
+	mustNoError(t, err, "GetUserByUsername guest again")
+	if guest.Settings.Language != "fr-FR" {
+		t.Errorf("guest language = %q, want fr-FR", guest.Settings.Language)
+	}
+}
+
+func testRoles(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	// Migrated stores already hold the built-in roles, fresh ones get them now
+	mustNoError(t, ds.EnsureDefaultRoles(ctx), "EnsureDefaultRoles")
+	mustNoError(t, ds.EnsureDefaultRoles(ctx), "EnsureDefaultRoles again")
+	roles, err := ds.ListRoles(ctx)
+	mustNoError(t, err, "ListRoles")
+	defaults := models.DefaultRoles()
+	if len(roles) != len(defaults) {
+		t.Fatalf("ListRoles returned %d roles, want the %d built-in ones", len(roles), len(defaults))
+	}
+	for i, role := range roles {
+		if role.Name != defaults[i].Name || !role.BuiltIn || role.ID.IsZero() {
+			t.Errorf("role %d = %+v, want built-in %s", i, role, defaults[i].Name)
+		}
+	}
+
+	archivist := &models.Role{
+		Name:        "archivist",
+		Description: "Keeps the shelves tidy",
+		Permissions: []models.Permission{models.PermLocationManage},
+	}
+	mustNoError(t, ds.CreateRole(ctx, archivist), "CreateRole")
+	if archivist.ID.IsZero() || archivist.CreatedAt.IsZero() {
+		t.Fatal("CreateRole did not set the ID and timestamps")
+	}
+	if err := ds.CreateRole(ctx, &models.Role{Name: "archivist"}); err == nil {
+		t.Error("CreateRole with a taken name returned no error")
+	}
+
+	got, err := ds.GetRoleByName(ctx, "archivist")
+	mustNoError(t, err, "GetRoleByName")
+	if got.ID != archivist.ID || got.BuiltIn || !got.Has(models.PermLocationManage) || got.Has(models.PermBlurayDelete) {
+		t.Errorf("GetRoleByName returned %+v", got)
+	}
+	if _, err := ds.GetRoleByName(ctx, "nobody"); err == nil {
+		t.Error("GetRoleByName on a missing role returned no error")
+	}
+
+	// Editing a built-in role keeps it built-in, and ensuring the defaults
+	// again does not undo the edit
+	guest, err := ds.GetRoleByName(ctx, models.RoleGuest)
+	mustNoError(t, err, "GetRoleByName guest")
+	guest.Permissions = []models.Permission{models.PermWatchLog}
+	guest.BuiltIn = false
+	pause()
+	mustNoError(t, ds.UpdateRole(ctx, guest), "UpdateRole")
+	mustNoError(t, ds.EnsureDefaultRoles(ctx), "EnsureDefaultRoles after update")
+	guest, err = ds.GetRoleByName(ctx, models.RoleGuest)
+	mustNoError(t, err, "GetRoleByName guest after update")
+	if !guest.BuiltIn || !guest.Has(models.PermWatchLog) || !guest.UpdatedAt.After(guest.CreatedAt) {
+		t.Errorf("updated guest role = %+v", guest)
+	}
+
+	mustNoError(t, ds.DeleteRole(ctx, "archivist"), "DeleteRole")
+	if _, err := ds.GetRoleByName(ctx, "archivist"); err == nil {
+		t.Error("GetRoleByName after delete returned no error")
+	}
+	roles, err = ds.ListRoles(ctx)
+	mustNoError(t, err, "ListRoles after delete")
+	if len(roles) != len(defaults) {
+		t.Errorf("ListRoles after delete returned %d roles, want %d", len(roles), len(defaults))
+	}
+}
+
+func testBlurays(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	addedBy := primitive.NewObjectID()
+	purchaseDate := time.Date(2023, 5, 17, 0, 0, 0, 0, time.UTC)
+
+	bluray := &models.Bluray{
+		Title:       "Inception",
+		Type:        models.MediaTypeMovie,
+		ReleaseYear: 2010,
+		Director:    "Christopher Nolan",
+		Runtime:     148,
+		Description: models.I18nText{En: "Dreams within dreams", Fr: "Des rêves dans des rêves"},
+		Genre:       models.I18nTextArray{En: []string{"Science Fiction"}, Fr: []string{"Science-Fiction"}},
+		Tags:        []string{"tag-1"},
+		TMDBID:      "27205",
+		Copies: []models.Copy{{
+			ID:            primitive.NewObjectID(),
+			Format:        models.FormatBluray,
+			Edition:       "Collector",
+			Packaging:     models.PackagingSteelbook,
+			Barcode:       "5051889023586",
+			DiscCount:     2,
+			AudioTracks:   []string{"English DTS-HD MA 5.1", "Français DTS 5.1"},
+			PurchasePrice: 12.99,
+			PurchaseDate:  purchaseDate,
+			Condition:     models.ConditionMint,
+		}},
+		AddedBy: addedBy,
+	}
+	mustNoError(t, ds.CreateBluray(ctx, bluray), "CreateBluray")
+	if bluray.ID.IsZero() || bluray.CreatedAt.IsZero() {
+		t.Fatal("CreateBluray did not assign an ID and creation time")
+	}
+
+	got, err := ds.GetBlurayByID(ctx, bluray.ID)
+	mustNoError(t, err, "GetBlurayByID")
+	if got.Title != "Inception" || got.Director != "Christopher Nolan" || got.Runtime != 148 ||
+		got.Description.Fr != "Des rêves dans des rêves" || len(got.Genre.Fr) != 1 ||
+		got.TMDBID != "27205" || got.AddedBy != addedBy {
+		t.Errorf("GetBlurayByID returned %+v", got)
+	}
+	if len(got.Copies) != 1 {
+		t.Fatalf("GetBlurayByID returned %d copies, want 1", len(got.Copies))
+	}
+	if c := got.Copies[0]; c.ID != bluray.Copies[0].ID || c.Format != models.FormatBluray || c.Edition != "Collector" ||
+		c.Packaging != models.PackagingSteelbook || c.Barcode != "5051889023586" || c.DiscCount != 2 ||
+		len(c.AudioTracks) != 2 || c.PurchasePrice != 12.99 || !c.PurchaseDate.Equal(purchaseDate) ||
+		c.Condition != models.ConditionMint {
+		t.Errorf("GetBlurayByID lost the copy fields: %+v", c)
+	}
+	if _, err := ds.GetBlurayByID(ctx, primitive.NewObjectID()); err == nil {
+		t.Error("GetBlurayByID found a bluray that does not exist")
+	}
+
+	// Updates must not touch the creation metadata
+	originalCreatedAt := got.CreatedAt
+	update := &models.Bluray{
+		ID:       bluray.ID,
+		Title:    "Inception (4K)",
+		Type:     models.MediaTypeMovie,
+		Seasons:  []models.Season{},
+		Tags:     []string{"tag-1", "tag-2"},
+		TMDBID:   "27205",
+		Director: "Christopher Nolan",
+		Copies:   []models.Copy{bluray.Copies[0], {ID: primitive.NewObjectID(), Format: models.Format4K}},
+	}
+	pause()
+	mustNoError(t, ds.UpdateBluray(ctx, update), "UpdateBluray")
+	got, err = ds.GetBlurayByID(ctx, bluray.ID)
+	mustNoError(t, err, "GetBlurayByID after update")
+	if got.Title != "Inception (4K)" || len(got.Tags) != 2 {
+		t.Errorf("UpdateBluray did not persist changes: %+v", got)
+	}
+	if len(got.Copies) != 2 || got.Copies[1].Format != models.Format4K {
+		t.Errorf("UpdateBluray did not replace the copies: %+v", got.Copies)
+	}
+	if !got.CreatedAt.Equal(originalCreatedAt) || got.AddedBy != addedBy {
+		t.Errorf("UpdateBluray changed creation metadata: created_at %v -> %v, added_by %v -> %v",
+			originalCreatedAt, got.CreatedAt, addedBy, got.AddedBy)
+	}
+	if !got.UpdatedAt.After(originalCreatedAt) {
+		t.Errorf("UpdateBluray did not bump updated_at (%v <= %v)", got.UpdatedAt, originalCreatedAt)
+	}
+
+	mustNoError(t, ds.DeleteBluray(ctx, bluray.ID), "DeleteBluray")
+	if _, err := ds.GetBlurayByID(ctx, bluray.ID); err == nil {
+		t.Error("GetBlurayByID found a deleted bluray")
+	}
+}
+
+func testBlurayFilters(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	darkAirDate := time.Date(2017, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, b := range []*models.Bluray{
+		{Title: "Alien", Type: models.MediaTypeMovie, ReleaseYear: 1979, TMDBID: "348"},
+		{Title: "Aliens", Type: models.MediaTypeMovie, ReleaseYear: 1986, TMDBID: "679"},
+		{Title: "Dark", Type: models.MediaTypeSeries, ReleaseYear: 2017, Seasons: []models.Season{{
+			Number:       1,
+			EpisodeCount: 10,
+			Episodes: []models.Episode{
+				{Number: 1, Title: "Secrets", AirDate: &darkAirDate, Runtime: 51, Overview: "A boy goes missing.", DiscNumber: 1},
+			},
+		}}},
+	} {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+		pause()
+	}
+
+	all, _, err := ds.PageBlurays(ctx, models.BlurayFilter{}, newest(0, 0))
+	mustNoError(t, err, "PageBlurays")
+	assertTitles(t, "PageBlurays (newest first)", all, "Dark", "Aliens", "Alien")
+	if episodes := all[0].Seasons[0].Episodes; len(episodes) != 1 || episodes[0].Title != "Secrets" ||
+		episodes[0].AirDate == nil || !episodes[0].AirDate.Equal(darkAirDate) || episodes[0].Runtime != 51 ||
+		episodes[0].Overview != "A boy goes missing." || episodes[0].DiscNumber != 1 {
+		t.Errorf("PageBlurays lost the episodes: %+v", episodes)
+	}
+
+	page, _, err := ds.PageBlurays(ctx, models.BlurayFilter{}, newest(1, 1))
+	mustNoError(t, err, "PageBlurays page")
+	assertTitles(t, "PageBlurays(skip=1, limit=1)", page, "Aliens")
+
+	// The controller relies on this lookup to reject duplicate TMDB IDs
+	duplicates, _, err := ds.PageBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"tmdb_id": "679"}}, newest(0, 1))
+	mustNoError(t, err, "PageBlurays by tmdb_id")
+	assertTitles(t, "PageBlurays(tmdb_id=679)", duplicates, "Aliens")
+
+	none, _, err := ds.PageBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"tmdb_id": "0"}}, newest(0, 1))
+	mustNoError(t, err, "PageBlurays by unknown tmdb_id")
+	assertTitles(t, "PageBlurays(tmdb_id=0)", none)
+
+	movies, _, err := ds.PageBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"type": "movie"}}, newest(0, 20))
+	mustNoError(t, err, "PageBlurays by type")
+	assertTitles(t, "PageBlurays(type=movie)", movies, "Aliens", "Alien")
+
+	// The CSV import duplicate check filters on title, type and year
+	existing, _, err := ds.PageBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"title": "Alien", "type": "movie", "release_year": 1979}}, newest(0, 1))
+	mustNoError(t, err, "PageBlurays by title, type and year")
+	assertTitles(t, "PageBlurays(title, type, year)", existing, "Alien")
+
+	simplified, _, err := ds.PageSimplifiedBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"type": "series"}}, newest(0, 20))
+	mustNoError(t, err, "PageSimplifiedBlurays")
+	if len(simplified) != 1 || simplified[0].Title != "Dark" || len(simplified[0].Seasons) != 1 {
+		t.Errorf("PageSimplifiedBlurays(type=series) = %+v", simplified)
+	}
+}
+
+func testSearchBlurays(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	action := &models.Tag{Name: "Action"}
+	mustNoError(t, ds.CreateTag(ctx, action), "CreateTag Action")
+	favourite := &models.Tag{Name: "Favourite"}
+	mustNoError(t, ds.CreateTag(ctx, favourite), "CreateTag Favourite")
+
+	for _, b := range []*models.Bluray{
+		{
+			Title:       "Inception",
+			Type:        models.MediaTypeMovie,
+			ReleaseYear: 2010,
+			Director:    "Christopher Nolan",
+			Genre:       models.I18nTextArray{En: []string{"Science Fiction"}, Fr: []string{"Science-Fiction"}},
+			Tags:        []string{action.ID.Hex()},
+			Copies: []models.Copy{
+				{Format: models.FormatBluray},
+				{
+					Format:      models.Format4K,
+					Packaging:   models.PackagingSteelbook,
+					Publisher:   "Warner Bros.",
+					Barcode:     "5051889023586",
+					AudioTracks: []string{"English Dolby Atmos"},
+				},
+			},
+		},
+		{
+			Title:       "Amélie",
+			Type:        models.MediaTypeMovie,
+			ReleaseYear: 2001,
+			Director:    "Jean-Pierre Jeunet",
+			Description: models.I18nText{Fr: "Une jeune serveuse à Montmartre"},
+			Genre:       models.I18nTextArray{En: []string{"Comedy"}, Fr: []string{"Comédie"}},
+			Tags:        []string{favourite.ID.Hex()},
+			Copies: []models.Copy{{
+				Format:         models.FormatBluray,
+				Edition:        "Édition Collector",
+				RegionCode:     "B",
+				SubtitleTracks: []string{"English", "Français"},
+			}},
+		},
+		{
+			Title:       "Dark",
+			Type:        models.MediaTypeSeries,
+			ReleaseYear: 2017,
+			Description: models.I18nText{En: "A missing child sets four families on a hunt"},
+			Genre:       models.I18nTextArray{En: []string{"Drama"}, Fr: []string{"Drame"}},
+		},
+	} {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+		pause()
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"incep", []string{"Inception"}},
+		{"NOLAN", []string{"Inception"}},
+		{"montmartre", []string{"Amélie"}},
+		{"drame", []string{"Dark"}},
+		{"favour", []string{"Amélie"}},
+		{"title:AM", []string{"Amélie"}},
+		{"director:jeunet", []string{"Amélie"}},
+		{"genre:comédie", []string{"Amélie"}},
+		{"description:families", []string{"Dark"}},
+		{"tag:act", []string{"Inception"}},
+		{"tag:unknown", []string{}},
+		{"year:2010", []string{"Inception"}},
+		{"type:movie", []string{"Amélie", "Inception"}},
+		{"type:movie year:2001", []string{"Amélie"}},
+		{"format:4k", []string{"Inception"}},
+		{"format:UHD", []string{"Inception"}},
+		{"format:blu-ray", []string{"Amélie", "Inception"}},
+		{"format:vhs", []string{}},
+		{"packaging:steelbook", []string{"Inception"}},
+		{"edition:collector", []string{"Amélie"}},
+		{"publisher:warner", []string{"Inception"}},
+		{"region:b", []string{"Amélie"}},
+		{"barcode:5051889023586", []string{"Inception"}},
+		{"audio:atmos", []string{"Inception"}},
+		{"subtitle:français", []string{"Amélie"}},
+		{"inception:", []string{"Inception"}},
+		{"nothing-matches-this", []string{}},
+	}
+
+	for _, tt := range tests {
+		got, _, err := ds.PageSearchBlurays(ctx, tt.query, models.BlurayFilter{}, newest(0, 20))
+		mustNoError(t, err, "PageSearchBlurays "+tt.query)
+		assertTitles(t, "PageSearchBlurays("+tt.query+")", got, tt.want...)
+	}
+
+	page, _, err := ds.PageSearchBlurays(ctx, "type:movie", models.BlurayFilter{}, newest(1, 1))
+	mustNoError(t, err, "PageSearchBlurays page")
+	assertTitles(t, "PageSearchBlurays(type:movie, skip=1, limit=1)", page, "Inception")
+
+	// Free text is folded, stemmed and forgives typos
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"amelie", []string{"Amélie"}},
+		{"AMÉLIE", []string{"Amélie"}},
+		{"serveuses", []string{"Amélie"}},
+		{"hunting", []string{"Dark"}},
+		{"family", []string{"Dark"}},
+		{"comedie", []string{"Amélie"}},
+		{"incpetion", []string{"Inception"}},
+		{"christopher nolan", []string{"Inception"}},
+		{"nolan montmartre", []string{}},
+		{"the", []string{}},
+		{"type:series families", []string{"Dark"}},
+		{"type:movie families", []string{}},
+	} {
+		got, _, err := ds.PageSearchBlurays(ctx, tt.query, models.BlurayFilter{}, newest(0, 20))
+		mustNoError(t, err, "PageSearchBlurays "+tt.query)
+		assertTitles(t, "PageSearchBlurays("+tt.query+")", got, tt.want...)
+	}
+
+	// Title matches score higher, however recent the other matches are
+	mustNoError(t, ds.CreateBluray(ctx, &models.Bluray{
+		Title:       "Delicatessen",
+		Type:        models.MediaTypeMovie,
+		Description: models.I18nText{En: "From the director of Amélie, a butcher & his tenants"},
+	}), "CreateBluray Delicatessen")
+	ranked, _, err := ds.PageSearchBlurays(ctx, "amelie", models.BlurayFilter{}, newest(0, 20))
+	byScore(ranked)
+	mustNoError(t, err, "PageSearchBlurays amelie")
+	assertTitles(t, "PageSearchBlurays(amelie)", ranked, "Amélie", "Delicatessen")
+	if len(ranked) == 2 {
+		if ranked[0].Score <= ranked[1].Score || ranked[1].Score <= 0 {
+			t.Errorf("scores = %v, %v, want the title match ahead", ranked[0].Score, ranked[1].Score)
+		}
+		if got := ranked[0].Highlights["title"]; got != "<mark>Amélie</mark>" {
+			t.Errorf("title highlight = %q", got)
+		}
+		if got := ranked[1].Highlights["description.en-US"]; got != "From the director of <mark>Amélie</mark>, a butcher &amp; his tenants" {
+			t.Errorf("description highlight = %q", got)
+		}
+	}
+	tagged, _, err := ds.PageSearchBlurays(ctx, "favourite", models.BlurayFilter{}, newest(0, 20))
+	mustNoError(t, err, "PageSearchBlurays favourite")
+	if assertTitles(t, "PageSearchBlurays(favourite)", tagged, "Amélie"); len(tagged) == 1 && tagged[0].Highlights["tags"] != "<mark>Favourite</mark>" {
+		t.Errorf("tag highlight = %q", tagged[0].Highlights["tags"])
+	}
+}
+
+func testSearchQueries(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	seen := &models.Tag{Name: "Seen"}
+	mustNoError(t, ds.CreateTag(ctx, seen), "CreateTag Seen")
+	purchased := func(date string) time.Time {
+		d, err := time.Parse("2006-01-02", date)
+		mustNoError(t, err, "time.Parse "+date)
+		return d
+	}
+
+	alien := &models.Bluray{
+		Title:       "Alien",
+		Type:        models.MediaTypeMovie,
+		ReleaseYear: 1979,
+		Director:    "Ridley Scott",
+		Description: models.I18nText{En: "In space, no one can hear you scream"},
+		Tags:        []string{seen.ID.Hex()},
+		Copies:      []models.Copy{{Format: models.Format4K, PurchasePrice: 25, PurchaseDate: purchased("2024-03-15")}},
+	}
+	bladeRunner := &models.Bluray{
+		Title:       "Blade Runner",
+		Type:        models.MediaTypeMovie,
+		ReleaseYear: 1982,
+		Director:    "Ridley Scott",
+		Copies:      []models.Copy{{Format: models.FormatBluray, PurchasePrice: 15, PurchaseDate: purchased("2023-11-02")}},
+	}
+	heat := &models.Bluray{
+		Title:       "Heat",
+		Type:        models.MediaTypeMovie,
+		ReleaseYear: 1995,
+		Director:    "Michael Mann",
+		Copies:      []models.Copy{{Format: models.FormatBluray, PurchasePrice: 9.99, PurchaseDate: purchased("2024-01-10")}},
+	}
+	clueless := &models.Bluray{Title: "Clueless", Type: models.MediaTypeMovie, ReleaseYear: 1995}
+	for _, b := range []*models.Bluray{alien, bladeRunner, heat, clueless} {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+		pause()
+	}
+	for _, r := range []*models.UserRating{
+		{UserID: primitive.NewObjectID(), BlurayID: alien.ID, Score: 8},
+		{UserID: primitive.NewObjectID(), BlurayID: alien.ID, Score: 10},
+		{UserID: primitive.NewObjectID(), BlurayID: bladeRunner.ID, Score: 7},
+	} {
+		mustNoError(t, ds.SetUserRating(ctx, r), "SetUserRating")
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{`director:"ridley scott"`, []string{"Blade Runner", "Alien"}},
+		{`title:"blade runner"`, []string{"Blade Runner"}},
+		{`title:"a.ien"`, []string{}},
+		{`title:alien OR title:heat`, []string{"Heat", "Alien"}},
+		{`title:alien or title:heat`, []string{}},
+		{`(title:alien OR director:"ridley scott") year:1979..1981`, []string{"Alien"}},
+		{`director:scott -tag:seen`, []string{"Blade Runner"}},
+		{`director:scott NOT title:blade`, []string{"Alien"}},
+		{`director:scott AND NOT (title:alien OR year:1982)`, []string{}},
+		{`-alien`, []string{"Clueless", "Heat", "Blade Runner"}},
+		{`"hear you scream"`, []string{"Alien"}},
+		{`"you hear"`, []string{}},
+		{`"seen"`, []string{"Alien"}},
+		{`year:1990..1999`, []string{"Clueless", "Heat"}},
+		{`year:>1982`, []string{"Clueless", "Heat"}},
+		{`year:..1982`, []string{"Blade Runner", "Alien"}},
+		{`rating:>=8`, []string{"Alien"}},
+		{`rating:<8`, []string{"Blade Runner"}},
+		{`rating:9`, []string{"Alien"}},
+		{`price:<20`, []string{"Heat", "Blade Runner"}},
+		{`price:10..15`, []string{"Blade Runner"}},
+		{`price:>100`, []string{}},
+		{`purchased:2024`, []string{"Heat", "Alien"}},
+		{`purchased:2024-03`, []string{"Alien"}},
+		{`purchased:2024-01-10`, []string{"Heat"}},
+		{`purchased:<2024`, []string{"Blade Runner"}},
+		{`purchased:>=2024-01-10`, []string{"Heat", "Alien"}},
+		{`purchased:2023..2024-01`, []string{"Heat", "Blade Runner"}},
+	} {
+		got, _, err := ds.PageSearchBlurays(ctx, tt.query, models.BlurayFilter{}, newest(0, 20))
+		mustNoError(t, err, "PageSearchBlurays "+tt.query)
+		assertTitles(t, "PageSearchBlurays("+tt.query+")", got, tt.want...)
+	}
+
+	// Free words still score the results of a boolean query
+	ranked, _, err := ds.PageSearchBlurays(ctx, "space OR mann", models.BlurayFilter{}, newest(0, 20))
+	byScore(ranked)
+	mustNoError(t, err, "PageSearchBlurays space OR mann")
+	assertTitles(t, "PageSearchBlurays(space OR mann)", ranked, "Heat", "Alien")
+
+	for _, tt := range []struct {
+		query    string
+		code     string
+		position int
+	}{
+		{`title:"alien`, "unclosedQuote", 6},
+		{`(alien OR heat`, "unclosedParenthesis", 0},
+		{`alien OR`, "missingOperand", 8},
+		{`alien )`, "unexpectedToken", 6},
+		{`heat year:`, "missingValue", 5},
+		{`year:199x`, "invalidValue", 0},
+		{`year:1999..1990`, "invalidRange", 0},
+		{`title:(alien)`, "missingValue", 0},
+		{`title:[a`, "invalidPattern", 0},
+		{`titel:alien`, "unknownField", 0},
+		{`heat Mission:Impossible`, "unknownField", 5},
+	} {
+		_, _, err := ds.PageSearchBlurays(ctx, tt.query, models.BlurayFilter{}, newest(0, 20))
+		var queryErr *datastore.SearchQueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("PageSearchBlurays(%s) error = %v, want a query error", tt.query, err)
+			continue
+		}
+		if queryErr.Code != tt.code || queryErr.Position != tt.position {
+			t.Errorf("PageSearchBlurays(%s) error = %s at %d, want %s at %d", tt.query, queryErr.Code, queryErr.Position, tt.code, tt.position)
+		}
+	}
+}
+
+func testBlurayPages(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	bought := func(year int, month time.Month) time.Time {
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+	for _, b := range []*models.Bluray{
+		{Title: "Zodiac", ReleaseYear: 2007, Copies: []models.Copy{{PurchasePrice: 10, PurchaseDate: bought(2020, time.January)}}},
+		{Title: "alien", ReleaseYear: 1979, Copies: []models.Copy{
+			{PurchasePrice: 10, PurchaseDate: bought(2019, time.June)},
+			{PurchasePrice: 15, PurchaseDate: bought(2021, time.May)},
+		}},
+		{Title: "Brazil", Copies: []models.Copy{{PurchasePrice: 10}}},
+		{Title: "Heat", ReleaseYear: 1995, Copies: []models.Copy{{PurchaseDate: bought(2018, time.March)}}},
+		{Title: "Ran", ReleaseYear: 1985, Copies: []models.Copy{{PurchasePrice: 40}}},
+	} {
+		b.Type = models.MediaTypeMovie
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+		pause()
+	}
+	// Filtered out of every page
+	mustNoError(t, ds.CreateBluray(ctx, &models.Bluray{Title: "Dark", Type: models.MediaTypeSeries}), "CreateBluray Dark")
+	movies := models.BlurayFilter{Fields: map[string]interface{}{"type": string(models.MediaTypeMovie)}}
+
+	// Blurays without a value come last either way, and ties are newest first
+	tests := []struct {
+		field models.SortField
+		desc  bool
+		want  []string
+	}{
+		{models.SortTitle, false, []string{"alien", "Brazil", "Heat", "Ran", "Zodiac"}},
+		{models.SortTitle, true, []string{"Zodiac", "Ran", "Heat", "Brazil", "alien"}},
+		{models.SortYear, false, []string{"alien", "Ran", "Heat", "Zodiac", "Brazil"}},
+		{models.SortYear, true, []string{"Zodiac", "Heat", "Ran", "alien", "Brazil"}},
+		{models.SortPrice, false, []string{"Brazil", "Zodiac", "alien", "Ran", "Heat"}},
+		{models.SortPrice, true, []string{"Ran", "alien", "Brazil", "Zodiac", "Heat"}},
+		{models.SortPurchased, false, []string{"Heat", "Zodiac", "alien", "Ran", "Brazil"}},
+		{models.SortPurchased, true, []string{"alien", "Zodiac", "Heat", "Ran", "Brazil"}},
+		{models.SortAdded, false, []string{"Zodiac", "alien", "Brazil", "Heat", "Ran"}},
+		{models.SortAdded, true, []string{"Ran", "Heat", "Brazil", "alien", "Zodiac"}},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("PageBlurays(%s, desc=%v)", tt.field, tt.desc)
+		page, total, err := ds.PageBlurays(ctx, movies, &datastore.BlurayPage{Field: tt.field, Desc: tt.desc})
+		mustNoError(t, err, name)
+		assertTitles(t, name, page, tt.want...)
+		if total != 5 {
+			t.Errorf("%s total = %d, want 5", name, total)
+		}
+
+		// Paging two at a time walks the same order
+		var walked []*models.Bluray
+		request := &datastore.BlurayPage{Field: tt.field, Desc: tt.desc, Limit: 2}
+		for range tt.want {
+			page, _, err := ds.PageBlurays(ctx, movies, request)
+			mustNoError(t, err, name+" page")
+			if len(page) == 0 {
+				break
+			}
+			walked = append(walked, page...)
+			last := position(page[len(page)-1], tt.field)
+			request.After = &last
+		}
+		assertTitles(t, name+" two at a time", walked, tt.want...)
+	}
+
+	// A page starts after the cursor even when blurays were added before it
+	// or the bluray it was taken from was deleted
+	first, _, err := ds.PageBlurays(ctx, movies, &datastore.BlurayPage{Field: models.SortTitle, Limit: 2})
+	mustNoError(t, err, "PageBlurays first page")
+	assertTitles(t, "PageBlurays(title, limit=2)", first, "alien", "Brazil")
+	after := position(first[1], models.SortTitle)
+	mustNoError(t, ds.CreateBluray(ctx, &models.Bluray{Title: "Abyss", Type: models.MediaTypeMovie}), "CreateBluray Abyss")
+	mustNoError(t, ds.DeleteBluray(ctx, first[1].ID), "DeleteBluray Brazil")
+	next, total, err := ds.PageBlurays(ctx, movies, &datastore.BlurayPage{Field: models.SortTitle, After: &after, Limit: 2})
+	mustNoError(t, err, "PageBlurays next page")
+	assertTitles(t, "PageBlurays(title, after Brazil, limit=2)", next, "Heat", "Ran")
+	if total != 5 {
+		t.Errorf("PageBlurays total after the changes = %d, want 5", total)
+	}
+	skipped, _, err := ds.PageBlurays(ctx, movies, &datastore.BlurayPage{Field: models.SortTitle, After: &after, Skip: 1, Limit: 5})
+	mustNoError(t, err, "PageBlurays skip")
+	assertTitles(t, "PageBlurays(title, after Brazil, skip=1)", skipped, "Ran", "Zodiac")
+
+	// Searches page the blurays they find and score the page
+	found, total, err := ds.PageSearchBlurays(ctx, "year:>=1980", models.BlurayFilter{}, &datastore.BlurayPage{Field: models.SortTitle, Limit: 2})
+	mustNoError(t, err, "PageSearchBlurays year")
+	assertTitles(t, "PageSearchBlurays(year:>=1980, title, limit=2)", found, "Heat", "Ran")
+	if total != 3 {
+		t.Errorf("PageSearchBlurays(year:>=1980) total = %d, want 3", total)
+	}
+	found, total, err = ds.PageSearchBlurays(ctx, "heat", models.BlurayFilter{}, &datastore.BlurayPage{Field: models.SortYear})
+	mustNoError(t, err, "PageSearchBlurays heat")
+	if assertTitles(t, "PageSearchBlurays(heat)", found, "Heat"); total != 1 || len(found) != 1 || found[0].Score <= 0 || found[0].Highlights["title"] == "" {
+		t.Errorf("PageSearchBlurays(heat) = %+v, total %d, want Heat scored", found, total)
+	}
+
+	simplified, total, err := ds.PageSimplifiedBlurays(ctx, movies, &datastore.BlurayPage{Field: models.SortPrice, Desc: true, Limit: 2})
+	mustNoError(t, err, "PageSimplifiedBlurays")
+	if len(simplified) != 2 || simplified[0].Title != "Ran" || simplified[1].Title != "alien" || total != 5 {
+		t.Errorf("PageSimplifiedBlurays(-price, limit=2) = %d blurays, total %d, want Ran and alien of 5", len(simplified), total)
+	}
+}
+
+// position returns where a bluray stands when sorted by a stored field
+func position(b *models.Bluray, field models.SortField) datastore.SortPosition {
+	return datastore.SortPosition{
+		Key:     datastore.StoredSortKey(field, b.Title, b.ReleaseYear, b.Copies),
+		Created: b.CreatedAt.UnixMilli(),
+		ID:      b.ID,
+	}
+}
+
+func testTags(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	createdBy := primitive.NewObjectID()
+
+	tag := &models.Tag{Name: "Steelbook", Color: "#aabbcc", Description: "Metal cases", CreatedBy: createdBy}
+	mustNoError(t, ds.CreateTag(ctx, tag), "CreateTag")
+	if tag.ID.IsZero() {
+		t.Fatal("CreateTag did not assign an ID")
+	}
+	if err := ds.CreateTag(ctx, &models.Tag{Name: "Steelbook"}); err == nil {
+		t.Error("CreateTag accepted a duplicate name")
+	}
+
+	got, err := ds.GetTagByID(ctx, tag.ID)
+	mustNoError(t, err, "GetTagByID")
+	if got.Name != "Steelbook" || got.Color != "#aabbcc" || got.CreatedBy != createdBy {
+		t.Errorf("GetTagByID returned %+v", got)
+	}
+	if _, err := ds.GetTagByName(ctx, primitive.NilObjectID, "Steelbook"); err != nil {
+		t.Errorf("GetTagByName: %v", err)
+	}
+	if _, err := ds.GetTagByName(ctx, primitive.NilObjectID, "Missing"); err == nil {
+		t.Error("GetTagByName found a tag that does not exist")
+	}
+
+	got.Color = "#000000"
+	mustNoError(t, ds.UpdateTag(ctx, got), "UpdateTag")
+	got, err = ds.GetTagByID(ctx, tag.ID)
+	mustNoError(t, err, "GetTagByID after update")
+	if got.Color != "#000000" {
+		t.Errorf("UpdateTag did not persist the color: %+v", got)
+	}
+
+	mustNoError(t, ds.CreateTag(ctx, &models.Tag{Name: "Box set"}), "CreateTag Box set")
+	tags, err := ds.ListTags(ctx, nil)
+	mustNoError(t, err, "ListTags")
+	if len(tags) != 2 {
+		t.Errorf("ListTags returned %d tags, want 2", len(tags))
+	}
+
+	mustNoError(t, ds.DeleteTag(ctx, tag.ID), "DeleteTag")
+	if _, err := ds.GetTagByID(ctx, tag.ID); err == nil {
+		t.Error("GetTagByID found a deleted tag")
+	}
+}
+
+func testTrash(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	live := models.BlurayFilter{}
+	trashed := models.BlurayFilter{Trashed: true}
+
+	noir := &models.Tag{Name: "Noir"}
+	mustNoError(t, ds.CreateTag(ctx, noir), "CreateTag")
+	shelf := &models.Location{Name: "Shelf", Slug: "shelf", Kind: models.LocationRoom}
+	mustNoError(t, ds.CreateLocation(ctx, shelf), "CreateLocation")
+	heat := &models.Bluray{Title: "Heat", Type: models.MediaTypeMovie, Tags: []string{noir.ID.Hex()}, LocationID: &shelf.ID, Copies: []models.Copy{{PurchasePrice: 10}}}
+	ronin := &models.Bluray{Title: "Ronin", Type: models.MediaTypeMovie, Tags: []string{noir.ID.Hex()}, LocationID: &shelf.ID, Copies: []models.Copy{{PurchasePrice: 20}}}
+	for _, b := range []*models.Bluray{heat, ronin} {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+		pause()
+	}
+
+	deletedAt := time.Now().Add(-time.Hour)
+	mustNoError(t, ds.SetBlurayDeletedAt(ctx, heat.ID, &deletedAt), "SetBlurayDeletedAt")
+	got, err := ds.GetBlurayByID(ctx, heat.ID)
+	mustNoError(t, err, "GetBlurayByID of a trashed bluray")
+	if got.DeletedAt == nil || !got.DeletedAt.Round(time.Second).Equal(deletedAt.Round(time.Second)) {
+		t.Errorf("DeletedAt = %v, want %v", got.DeletedAt, deletedAt)
+	}
+
+	blurays, _, err := ds.PageBlurays(ctx, live, newest(0, 0))
+	mustNoError(t, err, "PageBlurays outside of the trash")
+	assertTitles(t, "PageBlurays(live)", blurays, "Ronin")
+	blurays, _, err = ds.PageBlurays(ctx, trashed, newest(0, 0))
+	mustNoError(t, err, "PageBlurays in the trash")
+	assertTitles(t, "PageBlurays(trashed)", blurays, "Heat")
+	simplified, _, err := ds.PageSimplifiedBlurays(ctx, live, newest(0, 0))
+	mustNoError(t, err, "PageSimplifiedBlurays outside of the trash")
+	if len(simplified) != 1 || simplified[0].Title != "Ronin" {
+		t.Errorf("PageSimplifiedBlurays(live) returned %d blurays, want Ronin", len(simplified))
+	}
+	found, _, err := ds.PageSearchBlurays(ctx, "heat", live, newest(0, 0))
+	mustNoError(t, err, "PageSearchBlurays outside of the trash")
+	assertTitles(t, "PageSearchBlurays(heat, live)", found)
+	stats, err := ds.GetStatistics(ctx, live)
+	mustNoError(t, err, "GetStatistics outside of the trash")
+	if stats.TotalBlurays != 1 {
+		t.Errorf("GetStatistics(live).TotalBlurays = %d, want 1", stats.TotalBlurays)
+	}
+	assertFloat(t, "GetStatistics(live).TotalSpent", stats.TotalSpent, 20)
+	small, err := ds.GetSimplifiedStatistics(ctx, live)
+	mustNoError(t, err, "GetSimplifiedStatistics outside of the trash")
+	if small.TotalBlurays != 1 {
+		t.Errorf("GetSimplifiedStatistics(live).TotalBlurays = %d, want 1", small.TotalBlurays)
+	}
+	counts, err := ds.CountBluraysByLocation(ctx, nil)
+	mustNoError(t, err, "CountBluraysByLocation outside of the trash")
+	if counts[shelf.ID] != 1 {
+		t.Errorf("CountBluraysByLocation = %v, want 1 on the shelf", counts)
+	}
+
+	// Updates leave the trash marker alone
+	got.Title = "Heat (1995)"
+	got.DeletedAt = nil
+	mustNoError(t, ds.UpdateBluray(ctx, got), "UpdateBluray of a trashed bluray")
+	if got, err = ds.GetBlurayByID(ctx, heat.ID); err != nil || got.DeletedAt == nil {
+		t.Errorf("UpdateBluray took the bluray out of the trash: %+v, %v", got, err)
+	}
+
+	mustNoError(t, ds.SetBlurayDeletedAt(ctx, heat.ID, nil), "SetBlurayDeletedAt nil")
+	blurays, _, err = ds.PageBlurays(ctx, live, newest(0, 0))
+	mustNoError(t, err, "PageBlurays after restoring")
+	assertTitles(t, "PageBlurays(live) after restoring", blurays, "Ronin", "Heat (1995)")
+
+	// Trashed tags no longer match tag searches
+	found, _, err = ds.PageSearchBlurays(ctx, "tag:noir", models.BlurayFilter{}, newest(0, 0))
+	mustNoError(t, err, "PageSearchBlurays by tag")
+	assertTitles(t, "PageSearchBlurays(tag:noir)", found, "Ronin", "Heat (1995)")
+	mustNoError(t, ds.SetTagDeletedAt(ctx, noir.ID, &deletedAt), "SetTagDeletedAt")
+	tag, err := ds.GetTagByID(ctx, noir.ID)
+	mustNoError(t, err, "GetTagByID of a trashed tag")
+	if tag.DeletedAt == nil {
+		t.Error("SetTagDeletedAt did not set DeletedAt")
+	}
+	found, _, err = ds.PageSearchBlurays(ctx, "tag:noir", models.BlurayFilter{}, newest(0, 0))
+	mustNoError(t, err, "PageSearchBlurays by a trashed tag")
+	assertTitles(t, "PageSearchBlurays(tag:noir) with the tag trashed", found)
+
+	mustNoError(t, ds.SetTagDeletedAt(ctx, noir.ID, nil), "SetTagDeletedAt nil")
+	if tag, err = ds.GetTagByID(ctx, noir.ID); err != nil || tag.DeletedAt != nil {
+		t.Errorf("SetTagDeletedAt(nil) left %+v, %v", tag, err)
+	}
+
+	// Purged tags are taken off the blurays, the other tags stay
+	got, err = ds.GetBlurayByID(ctx, ronin.ID)
+	mustNoError(t, err, "GetBlurayByID")
+	got.Tags = append(got.Tags, "other")
+	mustNoError(t, ds.UpdateBluray(ctx, got), "UpdateBluray with another tag")
+	mustNoError(t, ds.RemoveTagFromBlurays(ctx, noir.ID), "RemoveTagFromBlurays")
+	for _, id := range []primitive.ObjectID{heat.ID, ronin.ID} {
+		got, err = ds.GetBlurayByID(ctx, id)
+		mustNoError(t, err, "GetBlurayByID after RemoveTagFromBlurays")
+		want := map[primitive.ObjectID]string{heat.ID: "", ronin.ID: "other"}[id]
+		if strings.Join(got.Tags, ",") != want {
+			t.Errorf("tags of %s after RemoveTagFromBlurays = %v, want %q", got.Title, got.Tags, want)
+		}
+	}
+}
+
+func testBlurayRevisions(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	heat := primitive.NewObjectID()
+	ronin := primitive.NewObjectID()
+	editor := primitive.NewObjectID()
+
+	for _, title := range []string{"Heat", "Heat (1995)", "Heat (Director's Cut)"} {
+		revision := &models.BlurayRevision{BlurayID: heat, EditedBy: &editor, EditedByName: "admin", Bluray: models.Bluray{ID: heat, Title: title}}
+		mustNoError(t, ds.CreateBlurayRevision(ctx, revision), "CreateBlurayRevision "+title)
+		if revision.ID.IsZero() || revision.CreatedAt.IsZero() {
+			t.Errorf("CreateBlurayRevision did not set the ID and creation time: %+v", revision)
+		}
+		pause()
+	}
+	other := &models.BlurayRevision{BlurayID: ronin, Bluray: models.Bluray{ID: ronin, Title: "Ronin"}}
+	mustNoError(t, ds.CreateBlurayRevision(ctx, other), "CreateBlurayRevision Ronin")
+	if other.Number != 1 {
+		t.Errorf("first revision of another bluray got number %d, want 1", other.Number)
+	}
+
+	second, err := ds.GetBlurayRevision(ctx, heat, 2)
+	mustNoError(t, err, "GetBlurayRevision")
+	if second.Number != 2 || second.Bluray.Title != "Heat (1995)" || second.EditedByName != "admin" || second.EditedBy == nil || *second.EditedBy != editor {
+		t.Errorf("GetBlurayRevision(2) = %+v", second)
+	}
+	if _, err := ds.GetBlurayRevision(ctx, heat, 4); err == nil {
+		t.Error("GetBlurayRevision of a missing revision: expected an error")
+	}
+	if _, err := ds.GetBlurayRevision(ctx, ronin, 2); err == nil {
+		t.Error("GetBlurayRevision of another bluray's number: expected an error")
+	}
+
+	revisions, err := ds.ListBlurayRevisions(ctx, heat, 0, 0)
+	mustNoError(t, err, "ListBlurayRevisions")
+	numbers := []int{}
+	for _, revision := range revisions {
+		numbers = append(numbers, revision.Number)
+	}
+	if fmt.Sprint(numbers) != "[3 2 1]" {
+		t.Errorf("ListBlurayRevisions numbers = %v, want newest first", numbers)
+	}
+	page, err := ds.ListBlurayRevisions(ctx, heat, 1, 1)
+	mustNoError(t, err, "ListBlurayRevisions page")
+	if len(page) != 1 || page[0].Number != 2 {
+		t.Errorf("ListBlurayRevisions(skip 1, limit 1) = %+v, want revision 2", page)
+	}
+	if count, err := ds.CountBlurayRevisions(ctx, heat); err != nil || count != 3 {
+		t.Errorf("CountBlurayRevisions = %d, %v, want 3", count, err)
+	}
+
+	mustNoError(t, ds.DeleteBlurayRevisions(ctx, heat), "DeleteBlurayRevisions")
+	if revisions, err = ds.ListBlurayRevisions(ctx, heat, 0, 0); err != nil || len(revisions) != 0 {
+		t.Errorf("ListBlurayRevisions after deleting = %d revisions, %v", len(revisions), err)
+	}
+	if revisions, err = ds.ListBlurayRevisions(ctx, ronin, 0, 0); err != nil || len(revisions) != 1 {
+		t.Errorf("DeleteBlurayRevisions removed the revisions of another bluray: %d left, %v", len(revisions), err)
+	}
+}
+
+func testStatistics(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	empty, err := ds.GetStatistics(ctx, models.BlurayFilter{})
+	mustNoError(t, err, "GetStatistics on an empty store")
+	if empty.TotalBlurays != 0 || empty.TopRated == nil || empty.GenreDistribution == nil {
+		t.Errorf("GetStatistics on an empty store = %+v", empty)
+	}
+
+	blurays := []*models.Bluray{
+		{
+			// Two copies: both are on the shelf and were paid for
+			Title: "Heat", Type: models.MediaTypeMovie, ReleaseYear: 1995, Runtime: 170,
+			Copies: []models.Copy{{PurchasePrice: 10}, {PurchasePrice: 25, Format: models.Format4K}},
+			Genre:  models.I18nTextArray{En: []string{"Crime", "Drama"}},
+			Tags:   []string{"tag-a"},
+		},
+		{
+			// No price: counted at the flat estimate of 4
+			Title: "Ronin", Type: models.MediaTypeMovie, ReleaseYear: 1998, Runtime: 122,
+			Genre: models.I18nTextArray{En: []string{"Action"}},
+			Tags:  []string{"tag-a", "tag-b"},
+		},
+		{
+			// The runtimes of the listed episodes win over the runtime
+			Title: "The Wire", Type: models.MediaTypeSeries, ReleaseYear: 2002, Runtime: 60,
+			Copies: []models.Copy{{PurchasePrice: 30}},
+			// Season 2 lists its episodes, which win over its episode count
+			Seasons: []models.Season{
+				{Number: 1, EpisodeCount: 13},
+				{Number: 2, EpisodeCount: 12, Episodes: []models.Episode{
+					{Number: 1, Title: "Ebb Tide", Runtime: 60},
+					{Number: 2, Title: "Collateral Damage", Runtime: 58, DiscNumber: 1},
+					{Number: 3, Title: "Hot Shots"},
+				}},
+			},
+			Genre: models.I18nTextArray{En: []string{"Crime"}},
+		},
+		{
+			// A series without seasons still counts as one disc, and its
+			// runtime is used without episode runtimes
+			Title: "Shogun", Type: models.MediaTypeSeries, Runtime: 55, Copies: []models.Copy{{PurchasePrice: 20}},
+		},
+	}
+	for _, b := range blurays {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+		pause()
+	}
+
+	stats, err := ds.GetStatistics(ctx, models.BlurayFilter{})
+	mustNoError(t, err, "GetStatistics")
+
+	checks := []struct {
+		name      string
+		got, want int
+	}{
+		{"TotalBlurays", stats.TotalBlurays, 6},
+		{"TotalCopies", stats.TotalCopies, 5},
+		{"TotalMovies", stats.TotalMovies, 3},
+		{"TotalSeries", stats.TotalSeries, 2},
+		{"TotalSeasons", stats.TotalSeasons, 3},
+		{"TotalEpisodes", stats.TotalEpisodes, 16},
+		{"TotalRuntimeMinutes", stats.TotalRuntimeMinutes, 465},
+		{"GenreDistribution[Crime]", stats.GenreDistribution["Crime"], 2},
+		{"GenreDistribution[Action]", stats.GenreDistribution["Action"], 1},
+		{"TagDistribution[tag-a]", stats.TagDistribution["tag-a"], 2},
+		{"TagDistribution[tag-b]", stats.TagDistribution["tag-b"], 1},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+
+	assertFloat(t, "TotalSpent", stats.TotalSpent, 89)
+	assertFloat(t, "AveragePrice", stats.AveragePrice, 17.8)
+	assertFloat(t, "PhysicalVolumeLiters", stats.PhysicalVolumeLiters, 1.8)
+	assertFloat(t, "PhysicalStorageGB", stats.PhysicalStorageGB, 195)
+
+	if stats.OldestBluray == nil || stats.OldestBluray.Title != "Heat" {
+		t.Errorf("OldestBluray = %+v, want Heat", stats.OldestBluray)
+	}
+	if stats.NewestBluray == nil || stats.NewestBluray.Title != "The Wire" {
+		t.Errorf("NewestBluray = %+v, want The Wire", stats.NewestBluray)
+	}
+	if stats.MostExpensive == nil || stats.MostExpensive.Title != "The Wire" {
+		t.Errorf("MostExpensive = %+v, want The Wire", stats.MostExpensive)
+	}
+	// Ratings are personal and computed by the controller
+	if stats.TopRated == nil || len(stats.TopRated) != 0 || stats.AverageRating != 0 {
+		t.Errorf("rating statistics = %v, %v, want none from the datastore", stats.TopRated, stats.AverageRating)
+	}
+
+	simplified, err := ds.GetSimplifiedStatistics(ctx, models.BlurayFilter{})
+	mustNoError(t, err, "GetSimplifiedStatistics")
+	if simplified.TotalBlurays != 6 || simplified.TotalCopies != 5 || simplified.TotalMovies != 2 ||
+		simplified.TotalSeries != 2 || simplified.TotalSeasons != 3 {
+		t.Errorf("GetSimplifiedStatistics = %+v", simplified)
+	}
+}
+
+func testNotifications(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+
+	var created []*models.Notification
+	for _, message := range []string{"first", "second", "third"} {
+		n := &models.Notification{UserID: alice, Type: models.NotificationBlurayAdded, Message: message, Read: true}
+		mustNoError(t, ds.CreateNotification(ctx, n), "CreateNotification "+message)
+		if n.Read {
+			t.Error("CreateNotification kept read=true on a new notification")
+		}
+		created = append(created, n)
+		pause()
+	}
+	mustNoError(t, ds.CreateNotification(ctx, &models.Notification{UserID: bob, Message: "bob's"}), "CreateNotification bob")
+
+	notifications, err := ds.GetUserNotifications(ctx, alice, nil, 50)
+	mustNoError(t, err, "GetUserNotifications")
+	if len(notifications) != 3 {
+		t.Fatalf("GetUserNotifications returned %d notifications, want 3", len(notifications))
+	}
+	for i, want := range []string{"third", "second", "first"} {
+		if notifications[i].Message != want {
+			t.Errorf("notification %d = %q, want %q (newest first)", i, notifications[i].Message, want)
+		}
+	}
+
+	limited, err := ds.GetUserNotifications(ctx, alice, nil, 2)
+	mustNoError(t, err, "GetUserNotifications with limit")
+	if len(limited) != 2 || limited[0].Message != "third" {
+		t.Errorf("GetUserNotifications(limit=2) = %d notifications", len(limited))
+	}
+
+	mustNoError(t, ds.MarkNotificationAsRead(ctx, created[0].ID), "MarkNotificationAsRead")
+	notifications, err = ds.GetUserNotifications(ctx, alice, nil, 50)
+	mustNoError(t, err, "GetUserNotifications after read")
+	if !notifications[2].Read || notifications[0].Read {
+		t.Error("MarkNotificationAsRead did not mark exactly the requested notification")
+	}
+
+	mustNoError(t, ds.MarkAllNotificationsAsRead(ctx, alice), "MarkAllNotificationsAsRead")
+	notifications, err = ds.GetUserNotifications(ctx, alice, nil, 50)
+	mustNoError(t, err, "GetUserNotifications after read-all")
+	for _, n := range notifications {
+		if !n.Read {
+			t.Errorf("notification %q is still unread", n.Message)
+		}
+	}
+
+	bobs, err := ds.GetUserNotifications(ctx, bob, nil, 50)
+	mustNoError(t, err, "GetUserNotifications bob")
+	if len(bobs) != 1 || bobs[0].Read {
+		t.Error("MarkAllNotificationsAsRead touched another user's notifications")
+	}
+}
+
+func testLoans(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	home, cabin := primitive.NewObjectID(), primitive.NewObjectID()
+	heatBluray := &models.Bluray{Title: "Heat", Type: models.MediaTypeMovie, CollectionID: home}
+	alienBluray := &models.Bluray{Title: "Alien", Type: models.MediaTypeMovie, CollectionID: cabin}
+	for _, b := range []*models.Bluray{heatBluray, alienBluray} {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+	}
+	heat, alien := heatBluray.ID, alienBluray.ID
+	lender := primitive.NewObjectID()
+	borrower := primitive.NewObjectID()
+
+	lentAt := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+	dueAt := lentAt.AddDate(0, 0, 14)
+	returnedAt := lentAt.AddDate(0, 0, 10)
+
+	first := &models.Loan{BlurayID: heat, BorrowerName: "Sam", LentAt: lentAt, ReturnedAt: &returnedAt, LentBy: lender}
+	mustNoError(t, ds.CreateLoan(ctx, first), "CreateLoan returned")
+	if first.ID.IsZero() || first.CreatedAt.IsZero() {
+		t.Fatal("CreateLoan did not set the ID and timestamps")
+	}
+
+	second := &models.Loan{
+		BlurayID:        heat,
+		BorrowerName:    "Alex",
+		BorrowerContact: "alex@example.com",
+		BorrowerID:      &borrower,
+		LentAt:          lentAt.AddDate(0, 1, 0),
+		DueAt:           &dueAt,
+		Notes:           "with the slipcover",
+		LentBy:          lender,
+	}
+	mustNoError(t, ds.CreateLoan(ctx, second), "CreateLoan active")
+	third := &models.Loan{BlurayID: alien, BorrowerName: "Kim", LentAt: lentAt, LentBy: lender}
+	mustNoError(t, ds.CreateLoan(ctx, third), "CreateLoan other bluray")
+
+	got, err := ds.GetLoanByID(ctx, second.ID)
+	mustNoError(t, err, "GetLoanByID")
+	if got.BorrowerName != "Alex" || got.BorrowerContact != "alex@example.com" || got.Notes != "with the slipcover" ||
+		got.BorrowerID == nil || *got.BorrowerID != borrower || got.LentBy != lender || got.ReturnedAt != nil {
+		t.Errorf("GetLoanByID returned %+v", got)
+	}
+	if got.DueAt == nil || !got.DueAt.Equal(dueAt) || !got.LentAt.Equal(second.LentAt) {
+		t.Errorf("GetLoanByID dates = lent %v, due %v", got.LentAt, got.DueAt)
+	}
+	if _, err := ds.GetLoanByID(ctx, primitive.NewObjectID()); err == nil {
+		t.Error("GetLoanByID on a missing ID returned no error")
+	}
+
+	history, err := ds.ListBlurayLoans(ctx, heat)
+	mustNoError(t, err, "ListBlurayLoans")
+	if len(history) != 2 || history[0].ID != second.ID || history[1].ID != first.ID {
+		t.Errorf("ListBlurayLoans returned %d loans, want the 2 loans of the bluray, most recently lent first", len(history))
+	}
+
+	gone := &models.Loan{BlurayID: primitive.NewObjectID(), BorrowerName: "Lee", LentAt: lentAt, LentBy: lender}
+	mustNoError(t, ds.CreateLoan(ctx, gone), "CreateLoan of a bluray that is gone")
+
+	active, err := ds.ListActiveLoans(ctx, nil)
+	mustNoError(t, err, "ListActiveLoans")
+	if len(active) != 2 || active[0].ID != third.ID || active[1].ID != second.ID {
+		t.Fatalf("ListActiveLoans returned %d loans, want the 2 unreturned loans of stored blurays, longest out first", len(active))
+	}
+	if active[0].BlurayTitle != "Alien" || active[0].CollectionID != cabin || active[1].BlurayTitle != "Heat" || active[1].CollectionID != home {
+		t.Errorf("ListActiveLoans returned %+v, want the title and collection of their bluray", active)
+	}
+	active, err = ds.ListActiveLoans(ctx, &home)
+	mustNoError(t, err, "ListActiveLoans of a collection")
+	if len(active) != 1 || active[0].ID != second.ID {
+		t.Errorf("ListActiveLoans(home) returned %d loans, want the loan of Heat", len(active))
+	}
+
+	trashedAt := time.Now()
+	mustNoError(t, ds.SetBlurayDeletedAt(ctx, alien, &trashedAt), "SetBlurayDeletedAt")
+	active, err = ds.ListActiveLoans(ctx, nil)
+	mustNoError(t, err, "ListActiveLoans with a trashed bluray")
+	if len(active) != 1 || active[0].ID != second.ID {
+		t.Errorf("ListActiveLoans with Alien in the trash returned %d loans, want the loan of Heat", len(active))
+	}
+	mustNoError(t, ds.SetBlurayDeletedAt(ctx, alien, nil), "SetBlurayDeletedAt restore")
+
+	now := time.Now()
+	got.ReturnedAt = &now
+	mustNoError(t, ds.UpdateLoan(ctx, got), "UpdateLoan")
+	active, err = ds.ListActiveLoans(ctx, nil)
+	mustNoError(t, err, "ListActiveLoans after return")
+	if len(active) != 1 || active[0].ID != third.ID {
+		t.Errorf("ListActiveLoans after return returned %d loans, want 1", len(active))
+	}
+
+	mustNoError(t, ds.DeleteLoan(ctx, first.ID), "DeleteLoan")
+	history, err = ds.ListBlurayLoans(ctx, heat)
+	mustNoError(t, err, "ListBlurayLoans after delete")
+	if len(history) != 1 || history[0].ReturnedAt == nil {
+		t.Errorf("ListBlurayLoans after delete returned %d loans, want the returned one", len(history))
+	}
+}
+
+func testWishlist(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	addedBy := primitive.NewObjectID()
+
+	low := &models.WishlistItem{Title: "Ronin", Type: models.MediaTypeMovie, Priority: 1, AddedBy: addedBy}
+	mustNoError(t, ds.CreateWishlistItem(ctx, low), "CreateWishlistItem low")
+	if low.ID.IsZero() || low.CreatedAt.IsZero() {
+		t.Fatal("CreateWishlistItem did not set the ID and timestamps")
+	}
+	pause()
+	high := &models.WishlistItem{
+		TMDBID:         "949",
+		Title:          "Heat",
+		Type:           models.MediaTypeMovie,
+		ReleaseYear:    1995,
+		DesiredFormat:  models.Format4K,
+		DesiredEdition: "Director's Definitive Edition",
+		MaxPrice:       25,
+		Priority:       5,
+		Notes:          "steelbook if possible",
+		AddedBy:        addedBy,
+	}
+	mustNoError(t, ds.CreateWishlistItem(ctx, high), "CreateWishlistItem high")
+	pause()
+	newer := &models.WishlistItem{Title: "Collateral", Type: models.MediaTypeMovie, Priority: 1, AddedBy: addedBy}
+	mustNoError(t, ds.CreateWishlistItem(ctx, newer), "CreateWishlistItem newer")
+
+	got, err := ds.GetWishlistItemByID(ctx, high.ID)
+	mustNoError(t, err, "GetWishlistItemByID")
+	if got.TMDBID != "949" || got.Title != "Heat" || got.DesiredFormat != models.Format4K ||
+		got.DesiredEdition != "Director's Definitive Edition" || got.MaxPrice != 25 || got.Priority != 5 ||
+		got.Notes != "steelbook if possible" || got.AddedBy != addedBy || got.IsAcquired() {
+		t.Errorf("GetWishlistItemByID returned %+v", got)
+	}
+	if _, err := ds.GetWishlistItemByID(ctx, primitive.NewObjectID()); err == nil {
+		t.Error("GetWishlistItemByID on a missing ID returned no error")
+	}
+
+	wanted, err := ds.ListWishlistItems(ctx, nil, false)
+	mustNoError(t, err, "ListWishlistItems")
+	if len(wanted) != 3 || wanted[0].ID != high.ID || wanted[1].ID != newer.ID || wanted[2].ID != low.ID {
+		t.Errorf("ListWishlistItems returned %d items, want the 3 items by priority, then newest first", len(wanted))
+	}
+
+	acquiredAt := time.Now()
+	blurayID := primitive.NewObjectID()
+	got.AcquiredAt = &acquiredAt
+	got.BlurayID = &blurayID
+	mustNoError(t, ds.UpdateWishlistItem(ctx, got), "UpdateWishlistItem")
+
+	wanted, err = ds.ListWishlistItems(ctx, nil, false)
+	mustNoError(t, err, "ListWishlistItems after acquiring")
+	if len(wanted) != 2 || wanted[0].ID != newer.ID {
+		t.Errorf("ListWishlistItems after acquiring returned %d items, want 2", len(wanted))
+	}
+	acquired, err := ds.ListWishlistItems(ctx, nil, true)
+	mustNoError(t, err, "ListWishlistItems acquired")
+	if len(acquired) != 1 || acquired[0].ID != high.ID || acquired[0].BlurayID == nil || *acquired[0].BlurayID != blurayID ||
+		!acquired[0].AcquiredAt.Equal(acquiredAt.Truncate(time.Millisecond)) {
+		t.Errorf("ListWishlistItems(acquired) returned %+v, want the acquired item linked to its bluray", acquired)
+	}
+
+	mustNoError(t, ds.DeleteWishlistItem(ctx, low.ID), "DeleteWishlistItem")
+	if _, err := ds.GetWishlistItemByID(ctx, low.ID); err == nil {
+		t.Error("GetWishlistItemByID after delete returned no error")
+	}
+}
+
+func testWatchEvents(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+
+	heat := &models.Bluray{Title: "Heat", Type: models.MediaTypeMovie}
+	mustNoError(t, ds.CreateBluray(ctx, heat), "CreateBluray heat")
+	pause()
+	dark := &models.Bluray{Title: "Dark", Type: models.MediaTypeSeries}
+	mustNoError(t, ds.CreateBluray(ctx, dark), "CreateBluray dark")
+	pause()
+	ronin := &models.Bluray{Title: "Ronin", Type: models.MediaTypeMovie}
+	mustNoError(t, ds.CreateBluray(ctx, ronin), "CreateBluray ronin")
+
+	january := time.Date(2024, time.January, 12, 21, 0, 0, 0, time.UTC)
+	first := &models.WatchEvent{UserID: alice, BlurayID: heat.ID, WatchedAt: january, Rating: 9, Notes: "director's cut", RuntimeMinutes: 170}
+	mustNoError(t, ds.CreateWatchEvent(ctx, first), "CreateWatchEvent first")
+	if first.ID.IsZero() || first.CreatedAt.IsZero() {
+		t.Fatal("CreateWatchEvent did not set the ID and timestamps")
+	}
+	episode := &models.WatchEvent{UserID: alice, BlurayID: dark.ID, SeasonNumber: 1, EpisodeNumber: 2, WatchedAt: january.AddDate(0, 1, 0), RuntimeMinutes: 45}
+	mustNoError(t, ds.CreateWatchEvent(ctx, episode), "CreateWatchEvent episode")
+	again := &models.WatchEvent{UserID: alice, BlurayID: heat.ID, WatchedAt: january.AddDate(0, 2, 0), RuntimeMinutes: 170}
+	mustNoError(t, ds.CreateWatchEvent(ctx, again), "CreateWatchEvent again")
+	other := &models.WatchEvent{UserID: bob, BlurayID: ronin.ID, WatchedAt: january, RuntimeMinutes: 122}
+	mustNoError(t, ds.CreateWatchEvent(ctx, other), "CreateWatchEvent other")
+
+	got, err := ds.GetWatchEventByID(ctx, episode.ID)
+	mustNoError(t, err, "GetWatchEventByID")
+	if got.UserID != alice || got.BlurayID != dark.ID || got.SeasonNumber != 1 || got.EpisodeNumber != 2 ||
+		!got.WatchedAt.Equal(episode.WatchedAt) || got.RuntimeMinutes != 45 {
+		t.Errorf("GetWatchEventByID returned %+v", got)
+	}
+	if _, err := ds.GetWatchEventByID(ctx, primitive.NewObjectID()); err == nil {
+		t.Error("GetWatchEventByID on a missing ID returned no error")
+	}
+
+	history, err := ds.ListWatchEvents(ctx, alice, nil, nil, 0, 0)
+	mustNoError(t, err, "ListWatchEvents")
+	if len(history) != 3 || history[0].ID != again.ID || history[1].ID != episode.ID || history[2].ID != first.ID {
+		t.Errorf("ListWatchEvents returned %d events, want the 3 events of the user, latest viewing first", len(history))
+	}
+	history, err = ds.ListWatchEvents(ctx, alice, nil, &heat.ID, 1, 1)
+	mustNoError(t, err, "ListWatchEvents of a bluray")
+	if len(history) != 1 || history[0].ID != first.ID || history[0].Notes != "director's cut" || history[0].Rating != 9 {
+		t.Errorf("ListWatchEvents(heat, skip 1, limit 1) returned %+v, want the first viewing", history)
+	}
+	if count, err := ds.CountWatchEvents(ctx, alice, nil, &heat.ID); err != nil || count != 2 {
+		t.Errorf("CountWatchEvents(heat) = %d, %v, want 2", count, err)
+	}
+
+	got.Rating = 8
+	got.WatchedAt = january.AddDate(0, 3, 0)
+	mustNoError(t, ds.UpdateWatchEvent(ctx, got), "UpdateWatchEvent")
+	history, err = ds.ListWatchEvents(ctx, alice, nil, nil, 0, 1)
+	mustNoError(t, err, "ListWatchEvents after update")
+	if len(history) != 1 || history[0].ID != episode.ID || history[0].Rating != 8 {
+		t.Errorf("ListWatchEvents after update returned %+v, want the rescheduled episode first", history)
+	}
+
+	watched, err := ds.ListWatchedBlurayIDs(ctx, alice)
+	mustNoError(t, err, "ListWatchedBlurayIDs")
+	if len(watched) != 2 {
+		t.Errorf("ListWatchedBlurayIDs returned %v, want the 2 distinct blurays", watched)
+	}
+
+	blurays, _, err := ds.PageBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"_id": bson.M{"$in": watched}}}, newest(0, 0))
+	mustNoError(t, err, "PageBlurays $in")
+	assertTitles(t, "PageBlurays(_id $in watched)", blurays, "Dark", "Heat")
+	blurays, _, err = ds.PageBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"_id": bson.M{"$nin": watched}}}, newest(0, 0))
+	mustNoError(t, err, "PageBlurays $nin")
+	assertTitles(t, "PageBlurays(_id $nin watched)", blurays, "Ronin")
+	blurays, _, err = ds.PageBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"_id": bson.M{"$in": []primitive.ObjectID{}}}}, newest(0, 0))
+	mustNoError(t, err, "PageBlurays $in nothing")
+	assertTitles(t, "PageBlurays(_id $in [])", blurays)
+	simplified, _, err := ds.PageSimplifiedBlurays(ctx, models.BlurayFilter{Fields: map[string]interface{}{"_id": bson.M{"$nin": []primitive.ObjectID{}}, "type": "movie"}}, newest(0, 0))
+	mustNoError(t, err, "PageSimplifiedBlurays $nin nothing")
+	if len(simplified) != 2 {
+		t.Errorf("PageSimplifiedBlurays(_id $nin [], movies) returned %d blurays, want 2", len(simplified))
+	}
+
+	mustNoError(t, ds.DeleteWatchEvent(ctx, first.ID), "DeleteWatchEvent")
+	if _, err := ds.GetWatchEventByID(ctx, first.ID); err == nil {
+		t.Error("GetWatchEventByID after delete returned no error")
+	}
+
+	mustNoError(t, ds.DeleteBlurayWatchEvents(ctx, heat.ID), "DeleteBlurayWatchEvents")
+	history, err = ds.ListWatchEvents(ctx, alice, nil, nil, 0, 0)
+	mustNoError(t, err, "ListWatchEvents after deleting the bluray history")
+	if len(history) != 1 || history[0].ID != episode.ID {
+		t.Errorf("ListWatchEvents after DeleteBlurayWatchEvents returned %d events, want only the episode", len(history))
+	}
+	history, err = ds.ListWatchEvents(ctx, bob, nil, nil, 0, 0)
+	mustNoError(t, err, "ListWatchEvents of another user")
+	if len(history) != 1 || history[0].ID != other.ID {
+		t.Errorf("ListWatchEvents(bob) returned %d events, want 1", len(history))
+	}
+}
+
+func testRatings(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+	heat := primitive.NewObjectID()
+	ronin := primitive.NewObjectID()
+
+	first := &models.UserRating{UserID: alice, BlurayID: heat, Score: 8, Review: "Long but worth it"}
+	mustNoError(t, ds.SetUserRating(ctx, first), "SetUserRating")
+	if first.ID.IsZero() || first.CreatedAt.IsZero() || first.UpdatedAt.IsZero() {
+		t.Fatal("SetUserRating did not set the ID and timestamps")
+	}
+	pause()
+	mustNoError(t, ds.SetUserRating(ctx, &models.UserRating{UserID: bob, BlurayID: heat, Score: 6}), "SetUserRating bob")
+	pause()
+	mustNoError(t, ds.SetUserRating(ctx, &models.UserRating{UserID: alice, BlurayID: ronin, Score: 7}), "SetUserRating ronin")
+	pause()
+
+	// Rating again replaces the rating of the same user and bluray
+	again := &models.UserRating{UserID: alice, BlurayID: heat, Score: 9.5}
+	mustNoError(t, ds.SetUserRating(ctx, again), "SetUserRating again")
+	if again.ID != first.ID || !again.CreatedAt.Equal(first.CreatedAt.Truncate(time.Millisecond)) {
+		t.Errorf("SetUserRating again = %+v, want the ID and creation date of %+v", again, first)
+	}
+
+	got, err := ds.GetUserRating(ctx, alice, heat)
+	mustNoError(t, err, "GetUserRating")
+	if got.ID != first.ID || got.Score != 9.5 || got.Review != "" || !got.UpdatedAt.After(got.CreatedAt) {
+		t.Errorf("GetUserRating returned %+v, want the replaced rating", got)
+	}
+	if _, err := ds.GetUserRating(ctx, bob, ronin); err == nil {
+		t.Error("GetUserRating on a missing rating returned no error")
+	}
+
+	ratings, err := ds.ListUserRatings(ctx, nil)
+	mustNoError(t, err, "ListUserRatings")
+	if len(ratings) != 3 || ratings[0].ID != first.ID {
+		t.Errorf("ListUserRatings returned %d ratings, want 3, latest first", len(ratings))
+	}
+	ratings, err = ds.ListUserRatings(ctx, &alice)
+	mustNoError(t, err, "ListUserRatings of a user")
+	if len(ratings) != 2 || ratings[0].BlurayID != heat || ratings[1].BlurayID != ronin {
+		t.Errorf("ListUserRatings(alice) returned %+v, want Heat then Ronin", ratings)
+	}
+
+	mustNoError(t, ds.DeleteUserRating(ctx, alice, ronin), "DeleteUserRating")
+	if _, err := ds.GetUserRating(ctx, alice, ronin); err == nil {
+		t.Error("GetUserRating after delete returned no error")
+	}
+
+	mustNoError(t, ds.DeleteBlurayRatings(ctx, heat), "DeleteBlurayRatings")
+	ratings, err = ds.ListUserRatings(ctx, nil)
+	mustNoError(t, err, "ListUserRatings after deleting the bluray ratings")
+	if len(ratings) != 0 {
+		t.Errorf("ListUserRatings after DeleteBlurayRatings returned %d ratings, want none", len(ratings))
+	}
+}
+
+func testLocations(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	room := &models.Location{Name: "Living room", Slug: "living-room", Kind: models.LocationRoom}
+	mustNoError(t, ds.CreateLocation(ctx, room), "CreateLocation room")
+	if room.ID.IsZero() || room.CreatedAt.IsZero() {
+		t.Fatal("CreateLocation did not set the ID and timestamps")
+	}
+	unit := &models.Location{Name: "Billy", Slug: "billy", Kind: models.LocationShelfUnit, ParentID: &room.ID}
+	mustNoError(t, ds.CreateLocation(ctx, unit), "CreateLocation unit")
+	shelf := &models.Location{Name: "Shelf 2", Slug: "shelf-2", Kind: models.LocationShelf, ParentID: &unit.ID, Capacity: 40}
+	mustNoError(t, ds.CreateLocation(ctx, shelf), "CreateLocation shelf")
+	office := &models.Location{Name: "Office", Slug: "office", Kind: models.LocationRoom}
+	mustNoError(t, ds.CreateLocation(ctx, office), "CreateLocation office")
+
+	got, err := ds.GetLocationByID(ctx, shelf.ID)
+	mustNoError(t, err, "GetLocationByID")
+	if got.Name != "Shelf 2" || got.Kind != models.LocationShelf || got.Capacity != 40 ||
+		got.ParentID == nil || *got.ParentID != unit.ID {
+		t.Errorf("GetLocationByID returned %+v", got)
+	}
+	if _, err := ds.GetLocationByID(ctx, primitive.NewObjectID()); err == nil {
+		t.Error("GetLocationByID on a missing ID returned no error")
+	}
+
+	locations, err := ds.ListLocations(ctx, nil)
+	mustNoError(t, err, "ListLocations")
+	var names []string
+	for _, location := range locations {
+		names = append(names, location.Name)
+	}
+	if strings.Join(names, ",") != "Billy,Living room,Office,Shelf 2" {
+		t.Errorf("ListLocations = %v, want sorted by name", names)
+	}
+
+	for _, b := range []*models.Bluray{
+		{Title: "Heat", Type: models.MediaTypeMovie, LocationID: &shelf.ID},
+		{Title: "Alien", Type: models.MediaTypeMovie, LocationID: &shelf.ID},
+		{Title: "Fargo", Type: models.MediaTypeSeries, LocationID: &office.ID},
+		{Title: "Brazil", Type: models.MediaTypeMovie},
+	} {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+		pause()
+	}
+
+	counts, err := ds.CountBluraysByLocation(ctx, nil)
+	mustNoError(t, err, "CountBluraysByLocation")
+	if len(counts) != 2 || counts[shelf.ID] != 2 || counts[office.ID] != 1 {
+		t.Errorf("CountBluraysByLocation = %v, want 2 on the shelf and 1 in the office", counts)
+	}
+
+	// A room matches everything stored in its units and shelves
+	found, _, err := ds.PageSearchBlurays(ctx, "location:living-room", models.BlurayFilter{}, newest(0, 0))
+	mustNoError(t, err, "PageSearchBlurays location:living-room")
+	assertTitles(t, "location:living-room", found, "Alien", "Heat")
+	found, _, err = ds.PageSearchBlurays(ctx, "location:office type:series", models.BlurayFilter{}, newest(0, 0))
+	mustNoError(t, err, "PageSearchBlurays location:office")
+	assertTitles(t, "location:office type:series", found, "Fargo")
+	found, _, err = ds.PageSearchBlurays(ctx, "location:garage", models.BlurayFilter{}, newest(0, 0))
+	mustNoError(t, err, "PageSearchBlurays location:garage")
+	assertTitles(t, "location:garage", found)
+
+	got.Capacity = 50
+	got.Name = "Top shelf"
+	mustNoError(t, ds.UpdateLocation(ctx, got), "UpdateLocation")
+	got, err = ds.GetLocationByID(ctx, shelf.ID)
+	mustNoError(t, err, "GetLocationByID after update")
+	if got.Name != "Top shelf" || got.Capacity != 50 {
+		t.Errorf("UpdateLocation did not persist, got %+v", got)
+	}
+
+	mustNoError(t, ds.DeleteLocation(ctx, office.ID), "DeleteLocation")
+	if _, err := ds.GetLocationByID(ctx, office.ID); err == nil {
+		t.Error("GetLocationByID found a deleted location")
+	}
+}
+
+func testCollections(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+
+	def, err := ds.EnsureDefaultCollection(ctx)
+	mustNoError(t, err, "EnsureDefaultCollection")
+	if def.ID.IsZero() || !def.IsDefault || def.Name == "" {
+		t.Fatalf("EnsureDefaultCollection returned %+v", def)
+	}
+	again, err := ds.EnsureDefaultCollection(ctx)
+	mustNoError(t, err, "EnsureDefaultCollection again")
+	if again.ID != def.ID {
+		t.Errorf("EnsureDefaultCollection created a second default collection %s, want %s", again.ID.Hex(), def.ID.Hex())
+	}
+	pause()
+
+	// Only the default collection may carry the flag
+	family := &models.Collection{
+		Name:      "Family",
+		IsDefault: true,
+		Members:   []models.CollectionMember{{UserID: alice, Role: models.RoleAdmin}},
+	}
+	mustNoError(t, ds.CreateCollection(ctx, family), "CreateCollection")
+	if family.ID.IsZero() || family.CreatedAt.IsZero() || family.IsDefault {
+		t.Fatalf("CreateCollection returned %+v", family)
+	}
+
+	got, err := ds.GetCollectionByID(ctx, family.ID)
+	mustNoError(t, err, "GetCollectionByID")
+	if got.Name != "Family" || len(got.Members) != 1 || got.Members[0].UserID != alice || got.Members[0].Role != models.RoleAdmin {
+		t.Errorf("GetCollectionByID returned %+v", got)
+	}
+	if _, err := ds.GetCollectionByID(ctx, primitive.NewObjectID()); err == nil {
+		t.Error("GetCollectionByID on a missing collection returned no error")
+	}
+
+	got.Description = "Upstairs"
+	got.IsDefault = true
+	got.Members = append(got.Members, models.CollectionMember{UserID: bob, Role: models.RoleGuest})
+	mustNoError(t, ds.UpdateCollection(ctx, got), "UpdateCollection")
+	got, err = ds.GetCollectionByID(ctx, family.ID)
+	mustNoError(t, err, "GetCollectionByID after update")
+	if got.Description != "Upstairs" || got.IsDefault || len(got.Members) != 2 {
+		t.Errorf("GetCollectionByID after update returned %+v", got)
+	}
+
+	all, err := ds.ListCollections(ctx, nil)
+	mustNoError(t, err, "ListCollections")
+	if len(all) != 2 || all[0].ID != def.ID || all[1].ID != family.ID {
+		t.Errorf("ListCollections returned %d collections, want the default one then Family", len(all))
+	}
+	mine, err := ds.ListCollections(ctx, &bob)
+	mustNoError(t, err, "ListCollections of a member")
+	if len(mine) != 1 || mine[0].ID != family.ID {
+		t.Errorf("ListCollections(bob) returned %+v, want Family", mine)
+	}
+
+	mustNoError(t, ds.DeleteCollection(ctx, family.ID), "DeleteCollection")
+	if _, err := ds.GetCollectionByID(ctx, family.ID); err == nil {
+		t.Error("GetCollectionByID after delete returned no error")
+	}
+}
+
+func testCollectionScoping(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	home := primitive.NewObjectID()
+	cabin := primitive.NewObjectID()
+	alice := primitive.NewObjectID()
+
+	for _, b := range []*models.Bluray{
+		{Title: "Heat", Type: models.MediaTypeMovie, CollectionID: home, Copies: []models.Copy{{PurchasePrice: 10}}},
+		{Title: "Heat", Type: models.MediaTypeMovie, CollectionID: cabin, Copies: []models.Copy{{PurchasePrice: 20}}},
+		{Title: "Ronin", Type: models.MediaTypeMovie, CollectionID: cabin, Copies: []models.Copy{{PurchasePrice: 30}}},
+	} {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+		pause()
+	}
+
+	blurays, _, err := ds.PageBlurays(ctx, models.BlurayFilter{CollectionID: &cabin}, newest(0, 0))
+	mustNoError(t, err, "PageBlurays of a collection")
+	if got := titles(blurays); len(got) != 2 || got[0] != "Ronin" || got[1] != "Heat" {
+		t.Errorf("PageBlurays(cabin) = %v, want [Ronin Heat]", got)
+	}
+	found, _, err := ds.PageSearchBlurays(ctx, "heat", models.BlurayFilter{CollectionID: &home}, newest(0, 0))
+	mustNoError(t, err, "PageSearchBlurays in a collection")
+	if len(found) != 1 || found[0].CollectionID != home {
+		t.Errorf("PageSearchBlurays(heat, home) returned %d blurays, want the one of home", len(found))
+	}
+
+	stats, err := ds.GetStatistics(ctx, models.BlurayFilter{CollectionID: &cabin})
+	mustNoError(t, err, "GetStatistics of a collection")
+	if stats.TotalBlurays != 2 {
+		t.Errorf("GetStatistics(cabin).TotalBlurays = %d, want 2", stats.TotalBlurays)
+	}
+	assertFloat(t, "GetStatistics(cabin).TotalSpent", stats.TotalSpent, 50)
+	simplified, err := ds.GetSimplifiedStatistics(ctx, models.BlurayFilter{CollectionID: &home})
+	mustNoError(t, err, "GetSimplifiedStatistics of a collection")
+	if simplified.TotalBlurays != 1 {
+		t.Errorf("GetSimplifiedStatistics(home).TotalBlurays = %d, want 1", simplified.TotalBlurays)
+	}
+
+	// The trash of a collection is listed apart from its other blurays
+	deletedAt := time.Now()
+	mustNoError(t, ds.SetBlurayDeletedAt(ctx, blurays[0].ID, &deletedAt), "SetBlurayDeletedAt in a collection")
+	trashed, _, err := ds.PageBlurays(ctx, models.BlurayFilter{CollectionID: &cabin, Trashed: true}, newest(0, 0))
+	mustNoError(t, err, "PageBlurays in the trash of a collection")
+	assertTitles(t, "PageBlurays(cabin, trashed)", trashed, "Ronin")
+	trashed, _, err = ds.PageBlurays(ctx, models.BlurayFilter{CollectionID: &home, Trashed: true}, newest(0, 0))
+	mustNoError(t, err, "PageBlurays in the trash of another collection")
+	assertTitles(t, "PageBlurays(home, trashed)", trashed)
+	mustNoError(t, ds.SetBlurayDeletedAt(ctx, blurays[0].ID, nil), "SetBlurayDeletedAt nil in a collection")
+
+	// Tag names are unique within a collection only
+	mustNoError(t, ds.CreateTag(ctx, &models.Tag{Name: "Noir", CollectionID: home}), "CreateTag home")
+	mustNoError(t, ds.CreateTag(ctx, &models.Tag{Name: "Noir", CollectionID: cabin}), "CreateTag cabin")
+	if err := ds.CreateTag(ctx, &models.Tag{Name: "Noir", CollectionID: cabin}); err == nil {
+		t.Error("CreateTag with a name taken in the same collection returned no error")
+	}
+	tag, err := ds.GetTagByName(ctx, cabin, "Noir")
+	mustNoError(t, err, "GetTagByName in a collection")
+	if tag.CollectionID != cabin {
+		t.Errorf("GetTagByName(cabin) returned the tag of %s", tag.CollectionID.Hex())
+	}
+	tags, err := ds.ListTags(ctx, &home)
+	mustNoError(t, err, "ListTags of a collection")
+	if len(tags) != 1 || tags[0].CollectionID != home {
+		t.Errorf("ListTags(home) returned %d tags, want 1", len(tags))
+	}
+
+	// Notifications without a collection show in every collection
+	for _, n := range []*models.Notification{
+		{UserID: alice, Type: models.NotificationBlurayAdded, Message: "home", CollectionID: home},
+		{UserID: alice, Type: models.NotificationBlurayAdded, Message: "cabin", CollectionID: cabin},
+		{UserID: alice, Type: models.NotificationCollectionInvite, Message: "invite"},
+	} {
+		mustNoError(t, ds.CreateNotification(ctx, n), "CreateNotification "+n.Message)
+		pause()
+	}
+	notifications, err := ds.GetUserNotifications(ctx, alice, &home, 0)
+	mustNoError(t, err, "GetUserNotifications of a collection")
+	if len(notifications) != 2 || notifications[0].Message != "invite" || notifications[1].Message != "home" {
+		t.Errorf("GetUserNotifications(home) returned %d notifications, want invite then home", len(notifications))
+	}
+	notifications, err = ds.GetUserNotifications(ctx, alice, nil, 0)
+	mustNoError(t, err, "GetUserNotifications")
+	if len(notifications) != 3 {
+		t.Errorf("GetUserNotifications returned %d notifications, want 3", len(notifications))
+	}
+
+	// Locations, the wishlist and the watch history belong to a collection
+	shelf := &models.Location{Name: "Shelf", Slug: "shelf", Kind: models.LocationRoom, CollectionID: home}
+	mustNoError(t, ds.CreateLocation(ctx, shelf), "CreateLocation home")
+	attic := &models.Location{Name: "Attic", Slug: "attic", Kind: models.LocationRoom, CollectionID: cabin}
+	mustNoError(t, ds.CreateLocation(ctx, attic), "CreateLocation cabin")
+	locations, err := ds.ListLocations(ctx, &cabin)
+	mustNoError(t, err, "ListLocations of a collection")
+	if len(locations) != 1 || locations[0].ID != attic.ID {
+		t.Errorf("ListLocations(cabin) returned %d locations, want the attic", len(locations))
+	}
+
+	for _, b := range []*models.Bluray{
+		{Title: "Thief", Type: models.MediaTypeMovie, CollectionID: home, LocationID: &shelf.ID},
+		{Title: "Manhunter", Type: models.MediaTypeMovie, CollectionID: cabin, LocationID: &shelf.ID},
+	} {
+		mustNoError(t, ds.CreateBluray(ctx, b), "CreateBluray "+b.Title)
+	}
+	counts, err := ds.CountBluraysByLocation(ctx, &home)
+	mustNoError(t, err, "CountBluraysByLocation of a collection")
+	if len(counts) != 1 || counts[shelf.ID] != 1 {
+		t.Errorf("CountBluraysByLocation(home) = %v, want 1 on the shelf", counts)
+	}
+	counts, err = ds.CountBluraysByLocation(ctx, nil)
+	mustNoError(t, err, "CountBluraysByLocation")
+	if counts[shelf.ID] != 2 {
+		t.Errorf("CountBluraysByLocation = %v, want 2 on the shelf", counts)
+	}
+
+	mustNoError(t, ds.CreateWishlistItem(ctx, &models.WishlistItem{Title: "Collateral", Type: models.MediaTypeMovie, CollectionID: home}), "CreateWishlistItem home")
+	mustNoError(t, ds.CreateWishlistItem(ctx, &models.WishlistItem{Title: "Ronin", Type: models.MediaTypeMovie, CollectionID: cabin}), "CreateWishlistItem cabin")
+	items, err := ds.ListWishlistItems(ctx, &home, false)
+	mustNoError(t, err, "ListWishlistItems of a collection")
+	if len(items) != 1 || items[0].Title != "Collateral" {
+		t.Errorf("ListWishlistItems(home) returned %d items, want Collateral", len(items))
+	}
+
+	watchedAt := time.Date(2024, time.March, 1, 20, 0, 0, 0, time.UTC)
+	for _, event := range []*models.WatchEvent{
+		{UserID: alice, BlurayID: blurays[0].ID, CollectionID: cabin, WatchedAt: watchedAt},
+		{UserID: alice, BlurayID: blurays[1].ID, CollectionID: cabin, WatchedAt: watchedAt.Add(time.Hour)},
+		{UserID: alice, BlurayID: found[0].ID, CollectionID: home, WatchedAt: watchedAt},
+	} {
+		mustNoError(t, ds.CreateWatchEvent(ctx, event), "CreateWatchEvent")
+	}
+	events, err := ds.ListWatchEvents(ctx, alice, &cabin, nil, 0, 0)
+	mustNoError(t, err, "ListWatchEvents of a collection")
+	if len(events) != 2 || events[0].CollectionID != cabin || events[1].CollectionID != cabin {
+		t.Errorf("ListWatchEvents(cabin) returned %d events, want the 2 of cabin", len(events))
+	}
+	events, err = ds.ListWatchEvents(ctx, alice, &cabin, &blurays[1].ID, 0, 0)
+	mustNoError(t, err, "ListWatchEvents of a bluray in a collection")
+	if len(events) != 1 {
+		t.Errorf("ListWatchEvents(cabin, bluray) returned %d events, want 1", len(events))
+	}
+	if count, err := ds.CountWatchEvents(ctx, alice, &cabin, nil); err != nil || count != 2 {
+		t.Errorf("CountWatchEvents(cabin) = %d, %v, want 2", count, err)
+	}
+}
+
+func testSessions(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+	now := time.Now()
+
+	laptop := &models.Session{UserID: alice, RefreshTokenHash: "laptop", UserAgent: "Firefox", ExpiresAt: now.Add(time.Hour)}
+	mustNoError(t, ds.CreateSession(ctx, laptop), "CreateSession laptop")
+	if laptop.ID.IsZero() || laptop.CreatedAt.IsZero() || !laptop.LastUsedAt.Equal(laptop.CreatedAt) {
+		t.Fatalf("CreateSession did not set the ID and timestamps: %+v", laptop)
+	}
+	pause()
+	phone := &models.Session{UserID: alice, RefreshTokenHash: "phone", ExpiresAt: now.Add(time.Hour)}
+	mustNoError(t, ds.CreateSession(ctx, phone), "CreateSession phone")
+	expired := &models.Session{UserID: alice, RefreshTokenHash: "expired", ExpiresAt: now.Add(-time.Minute)}
+	mustNoError(t, ds.CreateSession(ctx, expired), "CreateSession expired")
+	other := &models.Session{UserID: bob, RefreshTokenHash: "other", ExpiresAt: now.Add(time.Hour)}
+	mustNoError(t, ds.CreateSession(ctx, other), "CreateSession other")
+
+	got, err := ds.GetSessionByID(ctx, laptop.ID)
+	mustNoError(t, err, "GetSessionByID")
+	if got.UserID != alice || got.RefreshTokenHash != "laptop" || got.UserAgent != "Firefox" || !got.IsActive(now) {
+		t.Errorf("GetSessionByID returned %+v", got)
+	}
+	if _, err := ds.GetSessionByID(ctx, primitive.NewObjectID()); err == nil {
+		t.Error("GetSessionByID on a missing session returned no error")
+	}
+
+	// Rotating the refresh token moves the session to the top of the list
+	pause()
+	got.RefreshTokenHash = "laptop-rotated"
+	got.LastUsedAt = time.Now()
+	got.ExpiresAt = got.LastUsedAt.Add(2 * time.Hour)
+	mustNoError(t, ds.UpdateSession(ctx, got, "laptop"), "UpdateSession")
+	sessions, err := ds.ListUserSessions(ctx, alice)
+	mustNoError(t, err, "ListUserSessions")
+	if len(sessions) != 3 || sessions[0].ID != laptop.ID || sessions[0].RefreshTokenHash != "laptop-rotated" {
+		t.Fatalf("ListUserSessions = %+v, want the rotated laptop session first among 3", sessions)
+	}
+
+	// Of two rotations of the same token, only the first one is stored
+	raced := *got
+	raced.RefreshTokenHash = "laptop-raced"
+	if err := ds.UpdateSession(ctx, &raced, "laptop"); !errors.Is(err, datastore.ErrSessionChanged) {
+		t.Errorf("UpdateSession of a rotated token error = %v, want ErrSessionChanged", err)
+	}
+	if err := ds.UpdateSession(ctx, &models.Session{ID: primitive.NewObjectID()}, ""); !errors.Is(err, datastore.ErrSessionChanged) {
+		t.Errorf("UpdateSession of a missing session error = %v, want ErrSessionChanged", err)
+	}
+	stored, err := ds.GetSessionByID(ctx, laptop.ID)
+	mustNoError(t, err, "GetSessionByID after a lost race")
+	if stored.RefreshTokenHash != "laptop-rotated" {
+		t.Errorf("refresh token hash after a lost race = %q, want laptop-rotated", stored.RefreshTokenHash)
+	}
+
+	// Revoking the other sessions keeps the current one and other users' ones
+	mustNoError(t, ds.RevokeUserSessions(ctx, alice, laptop.ID), "RevokeUserSessions")
+	for id, wantActive := range map[primitive.ObjectID]bool{laptop.ID: true, phone.ID: false, other.ID: true} {
+		session, err := ds.GetSessionByID(ctx, id)
+		mustNoError(t, err, "GetSessionByID after revoke")
+		if (session.RevokedAt == nil) != wantActive {
+			t.Errorf("session %s revoked_at = %v, want active %v", id.Hex(), session.RevokedAt, wantActive)
+		}
+	}
+	mustNoError(t, ds.RevokeUserSessions(ctx, alice, primitive.NilObjectID), "RevokeUserSessions all")
+	if session, _ := ds.GetSessionByID(ctx, laptop.ID); session.IsActive(time.Now()) {
+		t.Error("RevokeUserSessions with no exception left a session active")
+	}
+
+	mustNoError(t, ds.DeleteExpiredSessions(ctx, now), "DeleteExpiredSessions")
+	if _, err := ds.GetSessionByID(ctx, expired.ID); err == nil {
+		t.Error("DeleteExpiredSessions kept an expired session")
+	}
+	sessions, err = ds.ListUserSessions(ctx, alice)
+	mustNoError(t, err, "ListUserSessions after purge")
+	if len(sessions) != 2 {
+		t.Errorf("ListUserSessions after purge returned %d sessions, want 2", len(sessions))
+	}
+}
+
+func testAPITokens(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+
+	scanner := &models.APIToken{UserID: alice, Name: "Scanner", TokenHash: "hash-scanner", Prefix: "bmt_0123", Scopes: []models.TokenScope{models.ScopeWrite}}
+	mustNoError(t, ds.CreateAPIToken(ctx, scanner), "CreateAPIToken")
+	if scanner.ID.IsZero() || scanner.CreatedAt.IsZero() {
+		t.Fatal("CreateAPIToken did not set the ID and creation time")
+	}
+	if err := ds.CreateAPIToken(ctx, &models.APIToken{UserID: bob, TokenHash: "hash-scanner"}); err == nil {
+		t.Error("CreateAPIToken with a taken hash returned no error")
+	}
+	pause()
+	mustNoError(t, ds.CreateAPIToken(ctx, &models.APIToken{UserID: alice, Name: "Backup", TokenHash: "hash-backup", Scopes: []models.TokenScope{models.ScopeRead}}), "CreateAPIToken backup")
+	mustNoError(t, ds.CreateAPIToken(ctx, &models.APIToken{UserID: bob, Name: "Other", TokenHash: "hash-other"}), "CreateAPIToken other")
+
+	got, err := ds.GetAPITokenByHash(ctx, "hash-scanner")
+	mustNoError(t, err, "GetAPITokenByHash")
+	if got.ID != scanner.ID || got.Name != "Scanner" || !got.HasScope(models.ScopeWrite) || got.LastUsedAt != nil {
+		t.Errorf("GetAPITokenByHash returned %+v", got)
+	}
+	if _, err := ds.GetAPITokenByHash(ctx, "hash-unknown"); err == nil {
+		t.Error("GetAPITokenByHash on an unknown hash returned no error")
+	}
+
+	usedAt := time.Now()
+	mustNoError(t, ds.TouchAPIToken(ctx, scanner.ID, usedAt), "TouchAPIToken")
+	got, err = ds.GetAPITokenByID(ctx, scanner.ID)
+	mustNoError(t, err, "GetAPITokenByID")
+	if got.LastUsedAt == nil || got.LastUsedAt.Sub(usedAt).Abs() > time.Millisecond {
+		t.Errorf("last used at = %v, want %v", got.LastUsedAt, usedAt)
+	}
+	mustNoError(t, ds.TouchAPIToken(ctx, primitive.NewObjectID(), usedAt), "TouchAPIToken on a missing token")
+
+	tokens, err := ds.ListUserAPITokens(ctx, alice)
+	mustNoError(t, err, "ListUserAPITokens")
+	if len(tokens) != 2 || tokens[0].Name != "Scanner" || tokens[1].Name != "Backup" {
+		t.Fatalf("ListUserAPITokens = %+v, want Scanner then Backup", tokens)
+	}
+
+	mustNoError(t, ds.DeleteAPIToken(ctx, scanner.ID), "DeleteAPIToken")
+	if _, err := ds.GetAPITokenByID(ctx, scanner.ID); err == nil {
+		t.Error("GetAPITokenByID after delete returned no error")
+	}
+	mustNoError(t, ds.DeleteUserAPITokens(ctx, alice), "DeleteUserAPITokens")
+	tokens, err = ds.ListUserAPITokens(ctx, alice)
+	mustNoError(t, err, "ListUserAPITokens after delete")
+	if len(tokens) != 0 {
+		t.Errorf("ListUserAPITokens after DeleteUserAPITokens = %+v", tokens)
+	}
+	if _, err := ds.GetAPITokenByHash(ctx, "hash-other"); err != nil {
+		t.Errorf("DeleteUserAPITokens removed another user's token: %v", err)
+	}
+}
+
+func testSavedSearches(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	alice := primitive.NewObjectID()
+	bob := primitive.NewObjectID()
+	home := primitive.NewObjectID()
+	office := primitive.NewObjectID()
+
+	unwatched := &models.SavedSearch{UserID: alice, Name: "Unwatched 4K", Query: "format:4k", Sort: "-year", Pinned: true}
+	mustNoError(t, ds.CreateSavedSearch(ctx, unwatched), "CreateSavedSearch")
+	if unwatched.ID.IsZero() || unwatched.CreatedAt.IsZero() {
+		t.Fatal("CreateSavedSearch did not set the ID and creation time")
+	}
+	pause()
+	for _, search := range []*models.SavedSearch{
+		{UserID: bob, Name: "Bob's", Query: "heat"},
+		{UserID: bob, Name: "Horror", Query: "genre:horror", Shared: true, CollectionID: &home},
+		{UserID: alice, Name: "Office", Query: "tag:work", Shared: true, CollectionID: &office},
+	} {
+		mustNoError(t, ds.CreateSavedSearch(ctx, search), "CreateSavedSearch "+search.Name)
+		pause()
+	}
+
+	names := func(searches []*models.SavedSearch) []string {
+		names := make([]string, len(searches))
+		for i, search := range searches {
+			names[i] = search.Name
+		}
+		return names
+	}
+	searches, err := ds.ListSavedSearches(ctx, alice, &home)
+	mustNoError(t, err, "ListSavedSearches")
+	if got := names(searches); fmt.Sprint(got) != "[Unwatched 4K Horror]" {
+		t.Errorf("ListSavedSearches(alice, home) = %v, want [Unwatched 4K Horror]", got)
+	}
+	searches, err = ds.ListSavedSearches(ctx, alice, nil)
+	mustNoError(t, err, "ListSavedSearches without a collection")
+	if got := names(searches); fmt.Sprint(got) != "[Unwatched 4K]" {
+		t.Errorf("ListSavedSearches(alice, nil) = %v, want [Unwatched 4K]", got)
+	}
+
+	got, err := ds.GetSavedSearchByID(ctx, unwatched.ID)
+	mustNoError(t, err, "GetSavedSearchByID")
+	if got.Name != "Unwatched 4K" || got.Query != "format:4k" || got.Sort != "-year" || !got.Pinned || got.Shared {
+		t.Errorf("GetSavedSearchByID returned %+v", got)
+	}
+	got.Query = "format:4k -tag:seen"
+	got.Pinned = false
+	mustNoError(t, ds.UpdateSavedSearch(ctx, got), "UpdateSavedSearch")
+	got, err = ds.GetSavedSearchByID(ctx, unwatched.ID)
+	mustNoError(t, err, "GetSavedSearchByID after update")
+	if got.Query != "format:4k -tag:seen" || got.Pinned {
+		t.Errorf("UpdateSavedSearch did not persist the changes: %+v", got)
+	}
+
+	// Smart collections outlive the user who saved them
+	mustNoError(t, ds.DeleteUserSavedSearches(ctx, bob), "DeleteUserSavedSearches")
+	searches, err = ds.ListSavedSearches(ctx, bob, &home)
+	mustNoError(t, err, "ListSavedSearches after DeleteUserSavedSearches")
+	if got := names(searches); fmt.Sprint(got) != "[Horror]" {
+		t.Errorf("ListSavedSearches(bob, home) after DeleteUserSavedSearches = %v, want [Horror]", got)
+	}
+
+	mustNoError(t, ds.DeleteSavedSearch(ctx, unwatched.ID), "DeleteSavedSearch")
+	if _, err := ds.GetSavedSearchByID(ctx, unwatched.ID); err == nil {
+		t.Error("GetSavedSearchByID after delete returned no error")
+	}
+	searches, err = ds.ListSavedSearches(ctx, alice, &office)
+	mustNoError(t, err, "ListSavedSearches after delete")
+	if got := names(searches); fmt.Sprint(got) != "[Office]" {
+		t.Errorf("ListSavedSearches(alice, office) after delete = %v, want [Office]", got)
+	}
+}
+
+func testSettings(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+
+	settings, err := ds.GetSettings(ctx)
+	mustNoError(t, err, "GetSettings")
+	if settings.RequireTwoFactorForStaff {
+		t.Errorf("default settings = %+v, want two-factor authentication optional", settings)
+	}
+
+	settings.RequireTwoFactorForStaff = true
+	mustNoError(t, ds.UpdateSettings(ctx, settings), "UpdateSettings")
+	settings, err = ds.GetSettings(ctx)
+	mustNoError(t, err, "GetSettings after update")
+	if !settings.RequireTwoFactorForStaff || settings.UpdatedAt.IsZero() {
+		t.Errorf("saved settings = %+v", settings)
+	}
+
+	settings.RequireTwoFactorForStaff = false
+	settings.AuditRetentionDays = 90
+	mustNoError(t, ds.UpdateSettings(ctx, settings), "UpdateSettings again")
+	settings, err = ds.GetSettings(ctx)
+	mustNoError(t, err, "GetSettings after second update")
+	if settings.RequireTwoFactorForStaff || settings.AuditRetentionDays != 90 {
+		t.Errorf("settings after second update = %+v", settings)
+	}
+}
+
+func testAuditLog(t *testing.T, ds datastore.Datastore) {
+	ctx := context.Background()
+	actorID := primitive.NewObjectID()
+	collectionID := primitive.NewObjectID()
+	blurayID := primitive.NewObjectID().Hex()
+
+	created := &models.AuditEntry{
+		ActorID:      &actorID,
+		ActorName:    "alice",
+		Action:       models.AuditCreate,
+		EntityType:   models.AuditEntityBluray,
+		EntityID:     blurayID,
+		EntityName:   "Alien",
+		CollectionID: &collectionID,
+		Changes:      []models.AuditChange{{Field: "title", After: json.RawMessage(`"Alien"`)}},
+		Request:      models.AuditRequest{IPAddress: "192.0.2.1", Method: "POST", Path: "/api/v1/blurays"},
+	}
+	mustNoError(t, ds.CreateAuditEntry(ctx, created), "CreateAuditEntry")
+	if created.ID.IsZero() || created.CreatedAt.IsZero() {
+		t.Fatalf("created entry = %+v, want an ID and a creation time", created)
+	}
+	pause()
+	updated := &models.AuditEntry{
+		ActorID:      &actorID,
+		Action:       models.AuditUpdate,
+		EntityType:   models.AuditEntityBluray,
+		EntityID:     blurayID,
+		CollectionID: &collectionID,
+		Changes:      []models.AuditChange{{Field: "copies", Before: json.RawMessage(`[{"format":"bluray"}]`), After: json.RawMessage(`[]`)}},
+	}
+	mustNoError(t, ds.CreateAuditEntry(ctx, updated), "CreateAuditEntry update")
+	pause()
+	registered := &models.AuditEntry{
+		Action:     models.AuditCreate,
+		EntityType: models.AuditEntityUser,
+		EntityID:   primitive.NewObjectID().Hex(),
+	}
+	mustNoError(t, ds.CreateAuditEntry(ctx, registered), "CreateAuditEntry user")
+
+	entries, err := ds.ListAuditEntries(ctx, models.AuditFilter{}, 0, 0)
+	mustNoError(t, err, "ListAuditEntries")
+	if len(entries) != 3 || entries[0].ID != registered.ID || entries[2].ID != created.ID {
+		t.Fatalf("entries = %+v, want the 3 entries newest first", entries)
+	}
+	got := entries[2]
+	if got.ActorID == nil || *got.ActorID != actorID || got.ActorName != "alice" || got.Request.IPAddress != "192.0.2.1" ||
+		len(got.Changes) != 1 || string(got.Changes[0].After) != `"Alien"` || got.Changes[0].Before != nil {
+		t.Errorf("stored entry = %+v", got)
+	}
+	if entries[1].Changes[0].Field != "copies" || string(entries[1].Changes[0].Before) != `[{"format":"bluray"}]` {
+		t.Errorf("stored changes = %+v", entries[1].Changes)
+	}
+	// The stored time, which may be less precise than the one set on create
+	updatedAt := entries[1].CreatedAt
+
+	filters := []struct {
+		name   string
+		filter models.AuditFilter
+		want   int
+	}{
+		{"actor", models.AuditFilter{ActorID: &actorID}, 2},
+		{"action", models.AuditFilter{Action: models.AuditCreate}, 2},
+		{"entity type", models.AuditFilter{EntityType: models.AuditEntityUser}, 1},
+		{"entity", models.AuditFilter{EntityType: models.AuditEntityBluray, EntityID: blurayID}, 2},
+		{"collection", models.AuditFilter{CollectionID: &collectionID, Action: models.AuditUpdate}, 1},
+		{"since", models.AuditFilter{Since: &updatedAt}, 2},
+		{"until", models.AuditFilter{Until: &updatedAt}, 1},
+	}
+	for _, tt := range filters {
+		entries, err := ds.ListAuditEntries(ctx, tt.filter, 0, 0)
+		mustNoError(t, err, "ListAuditEntries by "+tt.name)
+		if len(entries) != tt.want {
+			t.Errorf("entries by %s = %d, want %d", tt.name, len(entries), tt.want)
+		}
+		if count, err := ds.CountAuditEntries(ctx, tt.filter); err != nil || count != tt.want {
+			t.Errorf("CountAuditEntries by %s = %d, %v, want %d", tt.name, count, err, tt.want)
+		}
+	}
+
+	page, err := ds.ListAuditEntries(ctx, models.AuditFilter{}, 1, 1)
+	mustNoError(t, err, "ListAuditEntries page")
+	if len(page) != 1 || page[0].ID != updated.ID {
+		t.Errorf("second page = %+v, want the update entry", page)
+	}
+
+	mustNoError(t, ds.DeleteAuditEntriesBefore(ctx, updatedAt), "DeleteAuditEntriesBefore")
+	entries, err = ds.ListAuditEntries(ctx, models.AuditFilter{}, 0, 0)
+	mustNoError(t, err, "ListAuditEntries after purge")
+	if len(entries) != 2 || entries[1].ID != updated.ID {
+		t.Errorf("entries after purge = %+v, want the 2 newest", entries)
+	}
+}
+
+func testPasswordResetTokens(t *testing.T, ds datastore.Datastore) {
+	userID := primitive.NewObjectID().Hex()
+
+	mustNoError(t, ds.CreatePasswordResetToken(userID, "valid-token", time.Now().Add(time.Hour)), "CreatePasswordResetToken")
+	got, err := ds.VerifyPasswordResetToken("valid-token")
+	mustNoError(t, err, "VerifyPasswordResetToken")
+	if got != userID {
+		t.Errorf("VerifyPasswordResetToken = %q, want %q", got, userID)
+	}
+
+	mustNoError(t, ds.DeletePasswordResetToken("valid-token"), "DeletePasswordResetToken")
+	if _, err := ds.VerifyPasswordResetToken("valid-token"); err == nil {
+		t.Error("VerifyPasswordResetToken accepted a deleted token")
+	}
+
+	// Expired tokens are rejected and removed on first use
+	mustNoError(t, ds.CreatePasswordResetToken(userID, "expired-token", time.Now().Add(-time.Minute)), "CreatePasswordResetToken expired")
+	if _, err := ds.VerifyPasswordResetToken("expired-token"); err == nil || err.Error() != "token expired" {
+		t.Errorf("VerifyPasswordResetToken(expired) error = %v, want token expired", err)
+	}
+	if _, err := ds.VerifyPasswordResetToken("expired-token"); err == nil || err.Error() != "invalid token" {
+		t.Errorf("VerifyPasswordResetToken(expired, again) error = %v, want invalid token", err)
+	}
+
+	if _, err := ds.VerifyPasswordResetToken("unknown-token"); err == nil {
+		t.Error("VerifyPasswordResetToken accepted an unknown token")
+	}
+}
@@ -81,13 +81,77 @@ var Messages = map[string]map[string]string{
 		"notification.bluray_added":               "Bluray '%s' has been added to your collection.",
 		"notification.bluray_updated":             "Bluray '%s' has been updated.",
 		"notification.bluray_deleted":             "Bluray '%s' has been deleted from your collection.",
-		"bluray.duplicateTMDBID":                  "A bluray with the same TMDB ID already exists.",
+		"notification.bluray_reverted":            "Bluray '%s' has been restored to revision %d.",
+		"notification.loan_overdue":               "Bluray '%s' lent to %s was due back on %s.",
+		"notification.collection_invite":          "You have been added to the collection '%s'.",
+		"bluray.duplicateTMDBID":                  "A bluray with the same TMDB ID already exists; add a copy to it instead.",
 		"bluray.titleRequired":                    "Title is required.",
+		"bluray.notFound":                         "Bluray not found.",
+		"bluray.restoredSuccessfully":             "Bluray restored successfully.",
+		"bluray.purgedSuccessfully":               "Bluray deleted for good.",
+		"bluray.restoreDuplicateTMDBID":           "A bluray with the same TMDB ID was added since this one was deleted.",
+		"revision.notFound":                       "Revision not found.",
+		"revision.invalidNumber":                  "Revision numbers are whole numbers from 1.",
+		"savedSearch.notFound":                    "Saved search not found.",
+		"savedSearch.nameRequired":                "Saved searches need a name.",
+		"savedSearch.queryRequired":               "Saved searches need a query.",
+		"savedSearch.deletedSuccessfully":         "Saved search deleted successfully.",
+		"query.invalidSort":                       "Unknown sort; sort by title, year, rating, price, purchased or added, with a leading - for descending order.",
+		"query.invalidCursor":                     "Invalid cursor; start again from the first page.",
+		"search.unclosedQuote":                    "Search query: the quote at character %d is never closed.",
+		"search.unclosedParenthesis":              "Search query: the parenthesis at character %d is never closed.",
+		"search.unexpectedToken":                  "Search query: unexpected %q at character %d.",
+		"search.missingOperand":                   "Search query: something to search for is missing at character %d.",
+		"search.missingValue":                     "Search query: the filter %q at character %d needs a value.",
+		"search.invalidValue":                     "Search query: the filter %q at character %d has an invalid value.",
+		"search.invalidRange":                     "Search query: the filter %q at character %d has an invalid range.",
+		"search.invalidPattern":                   "Search query: the filter %q at character %d has an invalid pattern.",
+		"search.unknownField":                     "Search query: the filter %q at character %d is on an unknown field. Quote it to search for the text.",
+		"copy.invalidBarcode":                     "Barcode must be an EAN-13 or UPC-A code.",
+		"copy.invalidCondition":                   "Condition must be one of mint, good, fair, poor or damaged.",
+		"copy.invalidDiscCount":                   "Disc count cannot be negative.",
+		"copy.invalidFormat":                      "Format must be one of bluray, 4k, dvd or 3d.",
+		"copy.invalidPackaging":                   "Packaging must be one of keepcase, steelbook, digibook, mediabook or box_set.",
+		"copy.invalidPrice":                       "Purchase price cannot be negative.",
+		"copy.lastCopy":                           "A bluray needs at least one copy; delete the bluray instead.",
+		"copy.notFound":                           "Copy not found.",
+		"copy.onLoan":                             "This copy is lent out; mark it returned first.",
+		"copy.deletedSuccessfully":                "Copy deleted successfully.",
 		"jwt.invalid":                             "Invalid JWT token.",
 		"jwt.authorizationHeaderRequired":         "Authorization header is required.",
 		"jwt.invalidAuthorizationHeaderFormat":    "Invalid authorization header format.",
 		"jwt.unauthorized":                        "Unauthorized.",
 		"jwt.insufficientPermissions":             "Insufficient permissions.",
+		"jwt.sessionRevoked":                      "Your session has ended, please sign in again.",
+		"jwt.roleChanged":                         "Your role has changed, please refresh your session.",
+		"session.notFound":                        "Session not found.",
+		"session.invalidRefreshToken":             "Invalid or expired refresh token.",
+		"session.revokedSuccessfully":             "Session revoked successfully.",
+		"session.loggedOut":                       "Signed out successfully.",
+		"apiToken.notFound":                       "API token not found.",
+		"apiToken.invalid":                        "Invalid API token.",
+		"apiToken.nameRequired":                   "API token name is required.",
+		"apiToken.invalidScope":                   "Invalid API token scope, use read, write or admin.",
+		"apiToken.insufficientScope":              "This API token does not have the scope needed for this request.",
+		"apiToken.sessionRequired":                "This action needs a signed-in session and cannot be done with an API token.",
+		"apiToken.revokedSuccessfully":            "API token revoked successfully.",
+		"twoFactor.alreadyEnabled":                "Two-factor authentication is already enabled.",
+		"twoFactor.notEnabled":                    "Two-factor authentication is not enabled.",
+		"twoFactor.notEnrolled":                   "Start setting up two-factor authentication first.",
+		"twoFactor.invalidCode":                   "Invalid authentication code.",
+		"twoFactor.invalidChallenge":              "The login attempt expired, please sign in again.",
+		"twoFactor.requiredByPolicy":              "Two-factor authentication is required for your role.",
+		"twoFactor.setupRequired":                 "Set up two-factor authentication to continue.",
+		"twoFactor.disabled":                      "Two-factor authentication disabled.",
+		"twoFactor.reset":                         "Two-factor authentication reset for this user.",
+		"oidc.notConfigured":                      "Single sign-on is not configured.",
+		"oidc.unavailable":                        "The identity provider cannot be reached.",
+		"oidc.invalidState":                       "The sign-on attempt expired, please try again.",
+		"oidc.loginFailed":                        "The identity provider did not confirm your identity.",
+		"oidc.emailRequired":                      "The identity provider did not share your email address.",
+		"oidc.emailNotVerified":                   "An account already uses this email address, but the identity provider did not verify it.",
+		"oidc.alreadyLinked":                      "This account is already linked to another identity.",
+		"oidc.signupDisabled":                     "No account matches this identity and automatic sign-up is disabled.",
 		"passwordReset.invalidRequest":            "Invalid password reset request.",
 		"passwordReset.emailServiceNotConfigured": "Email service is not configured.",
 		"passwordReset.resetLinkSent":             "If an account with that email exists, a reset link has been sent.",
@@ -100,12 +164,77 @@ var Messages = map[string]map[string]string{
 		"tag.duplicateTagName":                    "A tag with that name already exists.",
 		"tag.notFound":                            "Tag not found.",
 		"tag.deletedSuccessfully":                 "Tag deleted successfully.",
+		"tag.inTrash":                             "A tag with that name is in the trash; restore it instead.",
+		"tag.restoredSuccessfully":                "Tag restored successfully.",
+		"tag.purgedSuccessfully":                  "Tag deleted for good.",
+		"collection.notFound":                     "Collection not found.",
+		"collection.nameRequired":                 "Collection name is required.",
+		"collection.memberNotFound":               "This user is not a member of the collection.",
+		"collection.cannotDeleteDefault":          "The default collection cannot be deleted.",
+		"collection.notEmpty":                     "This collection still contains blurays.",
+		"collection.loansOut":                     "Some blurays of this collection are still lent out.",
+		"collection.deletedSuccessfully":          "Collection deleted successfully.",
+		"collection.memberRemoved":                "Member removed from the collection.",
+		"role.notFound":                           "Role not found.",
+		"role.invalidName":                        "Role names are 2 to 32 lowercase letters, digits, dashes or underscores.",
+		"role.duplicateName":                      "A role with that name already exists.",
+		"role.invalidPermission":                  "Unknown permission.",
+		"role.adminLocked":                        "The admin role always has every permission.",
+		"role.cannotDeleteBuiltIn":                "Built-in roles cannot be deleted.",
+		"role.inUse":                              "This role is still given to users or collection members.",
+		"role.deletedSuccessfully":                "Role deleted successfully.",
+		"role.cannotGrant":                        "You cannot give or take away a role with permissions you do not have.",
+		"loan.borrowerRequired":                   "Borrower name or user is required.",
+		"loan.alreadyLent":                        "This bluray or copy is already lent out.",
+		"loan.alreadyReturned":                    "This loan has already been returned.",
+		"loan.dueBeforeLent":                      "Due date cannot be before the lent date.",
+		"loan.returnedBeforeLent":                 "Return date cannot be before the lent date.",
+		"loan.notFound":                           "Loan not found.",
+		"loan.deletedSuccessfully":                "Loan deleted successfully.",
+		"location.nameRequired":                   "Location name is required.",
+		"location.invalidKind":                    "Location kind must be 'room', 'shelf_unit', 'shelf' or 'slot'.",
+		"location.invalidCapacity":                "Capacity cannot be negative.",
+		"location.invalidParent":                  "Rooms contain shelf units, shelf units contain shelves and shelves contain slots.",
+		"location.duplicateName":                  "A location with that name already exists here.",
+		"location.hasChildren":                    "This location still contains other locations.",
+		"location.notEmpty":                       "This location still contains blurays.",
+		"location.notFound":                       "Location not found.",
+		"location.deletedSuccessfully":            "Location deleted successfully.",
+		"wishlist.titleRequired":                  "Title is required.",
+		"wishlist.invalidType":                    "Type must be 'movie' or 'series'.",
+		"wishlist.invalidMaxPrice":                "Maximum price cannot be negative.",
+		"wishlist.invalidPriority":                "Priority must be between 1 and 5.",
+		"wishlist.alreadyAcquired":                "This wishlist item has already been acquired.",
+		"wishlist.notFound":                       "Wishlist item not found.",
+		"wishlist.deletedSuccessfully":            "Wishlist item deleted successfully.",
+		"watch.blurayNotFound":                    "Bluray not found.",
+		"watch.seasonNotFound":                    "This series has no such season.",
+		"watch.episodeNotFound":                   "This season has no such episode.",
+		"watch.episodeWithoutSeason":              "An episode needs a season number.",
+		"watch.invalidRating":                     "Rating must be between 0 and 10.",
+		"watch.futureDate":                        "The viewing date cannot be in the future.",
+		"watch.notFound":                          "Watch event not found.",
+		"watch.deletedSuccessfully":               "Watch event deleted successfully.",
+		"rating.invalidScore":                     "Score must be greater than 0 and at most 10.",
+		"rating.reviewTooLong":                    "Review cannot be longer than 500 characters.",
+		"rating.notFound":                         "You have not rated this bluray.",
+		"rating.deletedSuccessfully":              "Rating deleted successfully.",
 		"user.emailAlreadyRegistered":             "Email is already registered.",
 		"user.usernameAlreadyTaken":               "Username is already taken.",
 		"user.invalidCredentials":                 "Invalid credentials.",
+		"user.accountLocked":                      "Too many failed login attempts. The account is locked for now; try again later or ask for an unlock email.",
+		"lockout.unlockLinkSent":                  "If this account is locked, an unlock link has been sent to its email address.",
+		"lockout.failedToSendUnlockEmail":         "Failed to send unlock email.",
+		"lockout.invalidUnlockToken":              "Invalid or expired unlock link.",
+		"lockout.accountUnlocked":                 "Account unlocked.",
+		"rateLimit.tooManyRequests":               "Too many requests, please slow down.",
+		"audit.invalidFilter":                     "Invalid audit log filter.",
+		"settings.invalidAuditRetention":          "The audit log retention must be a number of days, or 0 to keep entries forever.",
+		"settings.invalidTrashRetention":          "The trash retention must be a number of days, or 0 to keep deleted items until they are purged.",
 		"user.notFound":                           "User not found.",
 		"api.invalidUserID":                       "Invalid user ID.",
 		"api.invalidID":                           "Invalid ID.",
+		"api.invalidPage":                         "skip and limit must be zero or more.",
 		"api.identifierRequired":                  "Identifier is required.",
 		"api.failedToGenerateToken":               "Failed to generate token.",
 		"api.settingsUpdatedSuccessfully":         "Settings updated successfully.",
@@ -130,13 +259,77 @@ var Messages = map[string]map[string]string{
 		"notification.bluray_added":                "Le Bluray '%s' a été ajouté à votre collection.",
 		"notification.bluray_updated":              "Le Bluray '%s' a été mis à jour.",
 		"notification.bluray_deleted":              "Le Bluray '%s' a été supprimé de votre collection.",
-		"bluray.duplicateTMDBID":                   "Un Bluray avec le même ID TMDB existe déjà.",
+		"notification.bluray_reverted":             "Le Bluray '%s' a été restauré à la révision %d.",
+		"notification.loan_overdue":                "Le Bluray '%s' prêté à %s devait être rendu le %s.",
+		"notification.collection_invite":           "Vous avez été ajouté à la collection '%s'.",
+		"bluray.duplicateTMDBID":                   "Un Bluray avec le même ID TMDB existe déjà ; ajoutez-lui plutôt un exemplaire.",
 		"bluray.titleRequired":                     "Le titre est obligatoire.",
+		"bluray.notFound":                          "Bluray non trouvé.",
+		"bluray.restoredSuccessfully":              "Bluray restauré avec succès.",
+		"bluray.purgedSuccessfully":                "Bluray supprimé définitivement.",
+		"bluray.restoreDuplicateTMDBID":            "Un Bluray avec le même ID TMDB a été ajouté depuis la suppression de celui-ci.",
+		"revision.notFound":                        "Révision non trouvée.",
+		"revision.invalidNumber":                   "Les numéros de révision sont des nombres entiers à partir de 1.",
+		"savedSearch.notFound":                     "Recherche enregistrée non trouvée.",
+		"savedSearch.nameRequired":                 "Les recherches enregistrées doivent avoir un nom.",
+		"savedSearch.queryRequired":                "Les recherches enregistrées doivent avoir une requête.",
+		"query.invalidSort":                        "Tri inconnu ; triez par title, year, rating, price, purchased ou added, précédé d'un - pour l'ordre décroissant.",
+		"query.invalidCursor":                      "Curseur invalide ; recommencez depuis la première page.",
+		"savedSearch.deletedSuccessfully":          "Recherche enregistrée supprimée avec succès.",
+		"search.unclosedQuote":                     "Requête de recherche : le guillemet au caractère %d n'est jamais fermé.",
+		"search.unclosedParenthesis":               "Requête de recherche : la parenthèse au caractère %d n'est jamais fermée.",
+		"search.unexpectedToken":                   "Requête de recherche : %q inattendu au caractère %d.",
+		"search.missingOperand":                    "Requête de recherche : il manque un terme à rechercher au caractère %d.",
+		"search.missingValue":                      "Requête de recherche : le filtre %q au caractère %d a besoin d'une valeur.",
+		"search.invalidValue":                      "Requête de recherche : le filtre %q au caractère %d a une valeur invalide.",
+		"search.invalidRange":                      "Requête de recherche : le filtre %q au caractère %d a un intervalle invalide.",
+		"search.invalidPattern":                    "Requête de recherche : le filtre %q au caractère %d a un motif invalide.",
+		"search.unknownField":                      "Requête de recherche : le filtre %q au caractère %d porte sur un champ inconnu. Mettez-le entre guillemets pour rechercher ce texte.",
+		"copy.invalidBarcode":                      "Le code-barres doit être un code EAN-13 ou UPC-A.",
+		"copy.invalidCondition":                    "L'état doit être mint, good, fair, poor ou damaged.",
+		"copy.invalidDiscCount":                    "Le nombre de disques ne peut pas être négatif.",
+		"copy.invalidFormat":                       "Le format doit être bluray, 4k, dvd ou 3d.",
+		"copy.invalidPackaging":                    "Le boîtier doit être keepcase, steelbook, digibook, mediabook ou box_set.",
+		"copy.invalidPrice":                        "Le prix d'achat ne peut pas être négatif.",
+		"copy.lastCopy":                            "Un Bluray doit avoir au moins un exemplaire ; supprimez plutôt le Bluray.",
+		"copy.notFound":                            "Exemplaire introuvable.",
+		"copy.onLoan":                              "Cet exemplaire est prêté ; marquez-le comme rendu d'abord.",
+		"copy.deletedSuccessfully":                 "Exemplaire supprimé avec succès.",
 		"jwt.invalid":                              "Jeton JWT invalide.",
 		"jwt.authorizationHeaderRequired":          "L'en-tête d'autorisation est requis.",
 		"jwt.jwt.invalidAuthorizationHeaderFormat": "Format d'en-tête d'autorisation invalide.",
 		"jwt.unauthorized":                         "Non autorisé.",
 		"jwt.insufficientPermissions":              "Permissions insuffisantes.",
+		"jwt.sessionRevoked":                       "Votre session a pris fin, veuillez vous reconnecter.",
+		"jwt.roleChanged":                          "Votre rôle a changé, veuillez rafraîchir votre session.",
+		"session.notFound":                         "Session non trouvée.",
+		"session.invalidRefreshToken":              "Jeton de rafraîchissement invalide ou expiré.",
+		"session.revokedSuccessfully":              "Session révoquée avec succès.",
+		"session.loggedOut":                        "Déconnexion réussie.",
+		"apiToken.notFound":                        "Jeton d'API non trouvé.",
+		"apiToken.invalid":                         "Jeton d'API invalide.",
+		"apiToken.nameRequired":                    "Le nom du jeton d'API est requis.",
+		"apiToken.invalidScope":                    "Portée de jeton d'API invalide, utilisez read, write ou admin.",
+		"apiToken.insufficientScope":               "Ce jeton d'API n'a pas la portée nécessaire pour cette requête.",
+		"apiToken.sessionRequired":                 "Cette action nécessite une session connectée et ne peut pas être faite avec un jeton d'API.",
+		"apiToken.revokedSuccessfully":             "Jeton d'API révoqué avec succès.",
+		"twoFactor.alreadyEnabled":                 "L'authentification à deux facteurs est déjà activée.",
+		"twoFactor.notEnabled":                     "L'authentification à deux facteurs n'est pas activée.",
+		"twoFactor.notEnrolled":                    "Commencez d'abord la configuration de l'authentification à deux facteurs.",
+		"twoFactor.invalidCode":                    "Code d'authentification invalide.",
+		"twoFactor.invalidChallenge":               "La tentative de connexion a expiré, veuillez vous reconnecter.",
+		"twoFactor.requiredByPolicy":               "L'authentification à deux facteurs est obligatoire pour votre rôle.",
+		"twoFactor.setupRequired":                  "Configurez l'authentification à deux facteurs pour continuer.",
+		"twoFactor.disabled":                       "Authentification à deux facteurs désactivée.",
+		"twoFactor.reset":                          "Authentification à deux facteurs réinitialisée pour cet utilisateur.",
+		"oidc.notConfigured":                       "L'authentification unique n'est pas configurée.",
+		"oidc.unavailable":                         "Le fournisseur d'identité est injoignable.",
+		"oidc.invalidState":                        "La tentative de connexion a expiré, veuillez réessayer.",
+		"oidc.loginFailed":                         "Le fournisseur d'identité n'a pas confirmé votre identité.",
+		"oidc.emailRequired":                       "Le fournisseur d'identité n'a pas communiqué votre adresse e-mail.",
+		"oidc.emailNotVerified":                    "Un compte utilise déjà cette adresse e-mail, mais le fournisseur d'identité ne l'a pas vérifiée.",
+		"oidc.alreadyLinked":                       "Ce compte est déjà lié à une autre identité.",
+		"oidc.signupDisabled":                      "Aucun compte ne correspond à cette identité et l'inscription automatique est désactivée.",
 		"passwordReset.invalidRequest":             "Demande de réinitialisation du mot de passe invalide.",
 		"passwordReset.emailServiceNotConfigured":  "Le service de messagerie n'est pas configuré.",
 		"passwordReset.resetLinkSent":              "Si un compte avec cet email existe, un lien de réinitialisation a été envoyé.",
@@ -149,12 +342,77 @@ var Messages = map[string]map[string]string{
 		"tag.duplicateTagName":                     "Une balise avec ce nom existe déjà.",
 		"tag.notFound":                             "Balise non trouvée.",
 		"tag.deletedSuccessfully":                  "Balise supprimée avec succès.",
+		"tag.inTrash":                              "Une balise avec ce nom est dans la corbeille ; restaurez-la plutôt.",
+		"tag.restoredSuccessfully":                 "Balise restaurée avec succès.",
+		"tag.purgedSuccessfully":                   "Balise supprimée définitivement.",
+		"collection.notFound":                      "Collection non trouvée.",
+		"collection.nameRequired":                  "Le nom de la collection est obligatoire.",
+		"collection.memberNotFound":                "Cet utilisateur n'est pas membre de la collection.",
+		"collection.cannotDeleteDefault":           "La collection par défaut ne peut pas être supprimée.",
+		"collection.notEmpty":                      "Cette collection contient encore des blurays.",
+		"collection.loansOut":                      "Des blurays de cette collection sont encore prêtés.",
+		"collection.deletedSuccessfully":           "Collection supprimée avec succès.",
+		"collection.memberRemoved":                 "Membre retiré de la collection.",
+		"role.notFound":                            "Rôle non trouvé.",
+		"role.invalidName":                         "Le nom d'un rôle compte 2 à 32 lettres minuscules, chiffres, tirets ou tirets bas.",
+		"role.duplicateName":                       "Un rôle avec ce nom existe déjà.",
+		"role.invalidPermission":                   "Permission inconnue.",
+		"role.adminLocked":                         "Le rôle admin a toujours toutes les permissions.",
+		"role.cannotDeleteBuiltIn":                 "Les rôles intégrés ne peuvent pas être supprimés.",
+		"role.inUse":                               "Ce rôle est encore attribué à des utilisateurs ou des membres de collection.",
+		"role.deletedSuccessfully":                 "Rôle supprimé avec succès.",
+		"role.cannotGrant":                         "Vous ne pouvez pas attribuer ou retirer un rôle ayant des permissions que vous n'avez pas.",
+		"loan.borrowerRequired":                    "Le nom ou l'utilisateur emprunteur est obligatoire.",
+		"loan.alreadyLent":                         "Ce Bluray ou cet exemplaire est déjà prêté.",
+		"loan.alreadyReturned":                     "Ce prêt a déjà été rendu.",
+		"loan.dueBeforeLent":                       "La date de retour prévue ne peut pas précéder la date de prêt.",
+		"loan.returnedBeforeLent":                  "La date de retour ne peut pas précéder la date de prêt.",
+		"loan.notFound":                            "Prêt non trouvé.",
+		"loan.deletedSuccessfully":                 "Prêt supprimé avec succès.",
+		"location.nameRequired":                    "Le nom de l'emplacement est obligatoire.",
+		"location.invalidKind":                     "Le type d'emplacement doit être 'room', 'shelf_unit', 'shelf' ou 'slot'.",
+		"location.invalidCapacity":                 "La capacité ne peut pas être négative.",
+		"location.invalidParent":                   "Les pièces contiennent des meubles, les meubles des étagères et les étagères des emplacements.",
+		"location.duplicateName":                   "Un emplacement avec ce nom existe déjà ici.",
+		"location.hasChildren":                     "Cet emplacement contient encore d'autres emplacements.",
+		"location.notEmpty":                        "Cet emplacement contient encore des Blurays.",
+		"location.notFound":                        "Emplacement non trouvé.",
+		"location.deletedSuccessfully":             "Emplacement supprimé avec succès.",
+		"wishlist.titleRequired":                   "Le titre est obligatoire.",
+		"wishlist.invalidType":                     "Le type doit être 'movie' ou 'series'.",
+		"wishlist.invalidMaxPrice":                 "Le prix maximum ne peut pas être négatif.",
+		"wishlist.invalidPriority":                 "La priorité doit être comprise entre 1 et 5.",
+		"wishlist.alreadyAcquired":                 "Cet élément de la liste de souhaits a déjà été acheté.",
+		"wishlist.notFound":                        "Élément de la liste de souhaits introuvable.",
+		"wishlist.deletedSuccessfully":             "Élément de la liste de souhaits supprimé avec succès.",
+		"watch.blurayNotFound":                     "Bluray introuvable.",
+		"watch.seasonNotFound":                     "Cette série n'a pas cette saison.",
+		"watch.episodeNotFound":                    "Cette saison n'a pas cet épisode.",
+		"watch.episodeWithoutSeason":               "Un épisode nécessite un numéro de saison.",
+		"watch.invalidRating":                      "La note doit être comprise entre 0 et 10.",
+		"watch.futureDate":                         "La date de visionnage ne peut pas être dans le futur.",
+		"watch.notFound":                           "Visionnage introuvable.",
+		"watch.deletedSuccessfully":                "Visionnage supprimé avec succès.",
+		"rating.invalidScore":                      "La note doit être supérieure à 0 et au plus 10.",
+		"rating.reviewTooLong":                     "La critique ne peut pas dépasser 500 caractères.",
+		"rating.notFound":                          "Vous n'avez pas noté ce Bluray.",
+		"rating.deletedSuccessfully":               "Note supprimée avec succès.",
 		"user.emailAlreadyRegistered":              "L'email est déjà enregistré.",
 		"user.usernameAlreadyTaken":                "Le nom d'utilisateur est déjà pris.",
 		"user.invalidCredentials":                  "Identifiants invalides.",
+		"user.accountLocked":                       "Trop de tentatives de connexion échouées. Le compte est verrouillé pour le moment ; réessayez plus tard ou demandez un email de déverrouillage.",
+		"lockout.unlockLinkSent":                   "Si ce compte est verrouillé, un lien de déverrouillage a été envoyé à son adresse email.",
+		"lockout.failedToSendUnlockEmail":          "Échec de l'envoi de l'email de déverrouillage.",
+		"lockout.invalidUnlockToken":               "Lien de déverrouillage invalide ou expiré.",
+		"lockout.accountUnlocked":                  "Compte déverrouillé.",
+		"rateLimit.tooManyRequests":                "Trop de requêtes, veuillez ralentir.",
+		"audit.invalidFilter":                      "Filtre du journal d'audit invalide.",
+		"settings.invalidAuditRetention":           "La durée de conservation du journal d'audit doit être un nombre de jours, ou 0 pour garder les entrées indéfiniment.",
+		"settings.invalidTrashRetention":           "La durée de conservation de la corbeille doit être un nombre de jours, ou 0 pour garder les éléments supprimés jusqu'à leur purge.",
 		"user.notFound":                            "Utilisateur non trouvé.",
 		"api.invalidUserID":                        "ID utilisateur invalide.",
 		"api.invalidID":                            "ID invalide.",
+		"api.invalidPage":                          "skip et limit doivent être positifs ou nuls.",
 		"api.identifierRequired":                   "L'identifiant est requis.",
 		"api.failedToGenerateToken":                "Échec de la génération du jeton.",
 		"api.settingsUpdatedSuccessfully":          "Paramètres mis à jour avec succès.",
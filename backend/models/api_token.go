@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenScope limits what a personal API token can do, on top of the
+// permissions of the role of its owner
+type TokenScope string
+
+const (
+	// ScopeRead allows read-only requests
+	ScopeRead TokenScope = "read"
+	// ScopeWrite also allows changes to the library
+	ScopeWrite TokenScope = "write"
+	// ScopeAdmin allows the administration permissions, from managing users,
+	// roles and collections to reading the audit log
+	ScopeAdmin TokenScope = "admin"
+)
+
+// IsValid reports whether s is one of the known scopes
+func (s TokenScope) IsValid() bool {
+	switch s {
+	case ScopeRead, ScopeWrite, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// APIToken is a long-lived personal access token for scripts. Only a hash
+// of the token is stored; the token itself is shown once, when it is created.
+type APIToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name      string             `bson:"name" json:"name"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	// Prefix is the start of the token, to tell tokens apart in listings
+	Prefix     string       `bson:"prefix" json:"prefix"`
+	Scopes     []TokenScope `bson:"scopes" json:"scopes"`
+	LastUsedAt *time.Time   `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time    `bson:"created_at" json:"created_at"`
+}
+
+// HasScope reports whether the token was given the scope
+func (t *APIToken) HasScope(scope TokenScope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPITokenRequest is the request body for creating a personal API
+// token. Tokens created without scopes are read-only.
+type CreateAPITokenRequest struct {
+	Name   string       `json:"name" binding:"required,max=64"`
+	Scopes []TokenScope `json:"scopes"`
+}
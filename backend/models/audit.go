@@ -0,0 +1,107 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditAction is what was done to an audited record
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+	// AuditRestore brings a bluray or tag back from the trash and
+	// AuditPurge removes it from the trash for good
+	AuditRestore AuditAction = "restore"
+	AuditPurge   AuditAction = "purge"
+)
+
+// AuditEntityType is the kind of record an audit entry is about
+type AuditEntityType string
+
+const (
+	AuditEntityBluray AuditEntityType = "bluray"
+	AuditEntityTag    AuditEntityType = "tag"
+	AuditEntityUser   AuditEntityType = "user"
+	AuditEntityRole   AuditEntityType = "role"
+)
+
+// AuditEntry records one change to the library or to the accounts. Entries
+// are never changed once written; they only go away with the retention
+// period.
+type AuditEntry struct {
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	// ActorID is empty for changes made by no signed-in user, such as
+	// registering or the background jobs
+	ActorID    *primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	ActorName  string              `bson:"actor_name,omitempty" json:"actor_name,omitempty"`
+	Action     AuditAction         `bson:"action" json:"action"`
+	EntityType AuditEntityType     `bson:"entity_type" json:"entity_type"`
+	// EntityID is the hex ID of the record, or the name of a role
+	EntityID     string              `bson:"entity_id" json:"entity_id"`
+	EntityName   string              `bson:"entity_name,omitempty" json:"entity_name,omitempty"`
+	CollectionID *primitive.ObjectID `bson:"collection_id,omitempty" json:"collection_id,omitempty"`
+	Changes      []AuditChange       `bson:"changes" json:"changes"`
+	Request      AuditRequest        `bson:"request" json:"request"`
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// AuditChange is the value of one field before and after the change. Only
+// After is set on creation and only Before on deletion. Secrets, such as a
+// new password, are recorded without their values.
+type AuditChange struct {
+	Field  string          `bson:"field" json:"field"`
+	Before json.RawMessage `bson:"before,omitempty" json:"before,omitempty"`
+	After  json.RawMessage `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditRequest describes the HTTP request that made the change
+type AuditRequest struct {
+	IPAddress  string              `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+	UserAgent  string              `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Method     string              `bson:"method,omitempty" json:"method,omitempty"`
+	Path       string              `bson:"path,omitempty" json:"path,omitempty"`
+	APITokenID *primitive.ObjectID `bson:"api_token_id,omitempty" json:"api_token_id,omitempty"`
+}
+
+// AuditFilter narrows down a listing of the audit log. Zero fields match
+// every entry.
+type AuditFilter struct {
+	ActorID      *primitive.ObjectID
+	Action       AuditAction
+	EntityType   AuditEntityType
+	EntityID     string
+	CollectionID *primitive.ObjectID
+	Since        *time.Time
+	Until        *time.Time
+}
+
+// Matches reports whether the entry passes the filter
+func (f *AuditFilter) Matches(entry *AuditEntry) bool {
+	if f.ActorID != nil && (entry.ActorID == nil || *entry.ActorID != *f.ActorID) {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.EntityType != "" && entry.EntityType != f.EntityType {
+		return false
+	}
+	if f.EntityID != "" && entry.EntityID != f.EntityID {
+		return false
+	}
+	if f.CollectionID != nil && (entry.CollectionID == nil || *entry.CollectionID != *f.CollectionID) {
+		return false
+	}
+	if f.Since != nil && entry.CreatedAt.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && !entry.CreatedAt.Before(*f.Until) {
+		return false
+	}
+	return true
+}
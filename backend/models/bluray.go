@@ -45,18 +45,58 @@ type Bluray struct {
 	Genre         I18nTextArray `bson:"genre" json:"genre"`
 	CoverImageURL string        `bson:"cover_image_url" json:"cover_image_url"`
 	BackdropURL   string        `bson:"backdrop_url" json:"backdrop_url"`
-	PurchasePrice float64       `bson:"purchase_price" json:"purchase_price"`
-	PurchaseDate  time.Time     `bson:"purchase_date" json:"purchase_date"`
 	Tags          []string      `bson:"tags" json:"tags"`
-	Rating        float64       `bson:"rating" json:"rating"` // Personal rating
+
+	// Household average of the personal ratings, filled in on read. Updates
+	// ignore it: personal ratings are set through the rating endpoints.
+	Rating      float64 `bson:"-" json:"rating"`
+	RatingCount int     `bson:"-" json:"rating_count"`
 
 	// External IDs
 	TMDBID string `bson:"tmdb_id,omitempty" json:"tmdb_id,omitempty"`
 
+	// Owned copies of the title, each with its own release and purchase
+	Copies []Copy `bson:"copies" json:"copies"`
+
+	// Physical location, shared by the copies of the title
+	LocationID   *primitive.ObjectID `bson:"location_id,omitempty" json:"location_id,omitempty"`
+	LocationPath string              `bson:"-" json:"location_path,omitempty"`
+
+	// Loan status, filled in from the loans on read: OnLoan once no copy is
+	// left, and CurrentLoan is the loan out the longest. Copies say which of
+	// them are out.
+	OnLoan      bool  `bson:"-" json:"on_loan"`
+	CurrentLoan *Loan `bson:"-" json:"current_loan,omitempty"`
+
+	// Relevance to a full-text search and the matched text of each field,
+	// with the matches wrapped in <mark> tags, filled in on search
+	Score      float64           `bson:"-" json:"score,omitempty"`
+	Highlights map[string]string `bson:"-" json:"highlights,omitempty"`
+
+	// Folded words of the searchable fields, kept by the datastore
+	SearchTerms []string `bson:"search_terms,omitempty" json:"-"`
+	// Values of the sort fields worked out from other fields, kept by the
+	// datastore so that it can sort and page blurays itself
+	SortKeys BluraySortKeys `bson:"sort_keys" json:"-"`
+
 	// Metadata
-	AddedBy   primitive.ObjectID `bson:"added_by" json:"added_by"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	CollectionID primitive.ObjectID `bson:"collection_id,omitempty" json:"collection_id"`
+	AddedBy      primitive.ObjectID `bson:"added_by" json:"added_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	// DeletedAt is set while the bluray is in the trash
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+}
+
+// BluraySortKeys holds the sort values of a bluray that are not fields of
+// their own. The zero value of each means the bluray has none.
+type BluraySortKeys struct {
+	// Title folded to lowercase
+	Title string `bson:"title"`
+	// Total paid for the copies
+	Price float64 `bson:"price"`
+	// Last time a copy was bought, in Unix milliseconds
+	Purchased int64 `bson:"purchased"`
 }
 
 // SimplifiedBluray is a simplified version of Bluray for listings
@@ -77,51 +117,72 @@ type SimplifiedBluray struct {
 	Genre         I18nTextArray `bson:"genre" json:"genre"`
 	CoverImageURL string        `bson:"cover_image_url" json:"cover_image_url"`
 	BackdropURL   string        `bson:"backdrop_url" json:"backdrop_url"`
-	Rating        float64       `bson:"rating" json:"rating"`
 	Tags          []string      `bson:"tags" json:"tags"`
+
+	// Household average of the personal ratings, filled in on read
+	Rating      float64 `bson:"-" json:"rating"`
+	RatingCount int     `bson:"-" json:"rating_count"`
+
+	// Owned copies of the title
+	Copies []Copy `bson:"copies" json:"copies"`
+
+	// Physical location, shared by the copies of the title
+	LocationID   *primitive.ObjectID `bson:"location_id,omitempty" json:"location_id,omitempty"`
+	LocationPath string              `bson:"-" json:"location_path,omitempty"`
+
+	// Loan status, filled in from the loans on read
+	OnLoan bool `bson:"-" json:"on_loan"`
+
+	// Metadata
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 }
 
 // Season represents a season in a series
 type Season struct {
-	Number       int `bson:"number" json:"number"`
-	EpisodeCount int `bson:"episode_count" json:"episode_count"`
-	Year         int `bson:"year,omitempty" json:"year,omitempty"`
+	Number       int       `bson:"number" json:"number"`
+	EpisodeCount int       `bson:"episode_count" json:"episode_count"`
+	Year         int       `bson:"year,omitempty" json:"year,omitempty"`
+	Episodes     []Episode `bson:"episodes,omitempty" json:"episodes,omitempty"`
+}
+
+// Episode represents an episode of a season, as listed by TMDB
+type Episode struct {
+	Number   int        `bson:"number" json:"number"`
+	Title    string     `bson:"title,omitempty" json:"title,omitempty"`
+	AirDate  *time.Time `bson:"air_date,omitempty" json:"air_date,omitempty"`
+	Runtime  int        `bson:"runtime,omitempty" json:"runtime,omitempty"` // in minutes
+	Overview string     `bson:"overview,omitempty" json:"overview,omitempty"`
+
+	// DiscNumber is the disc of the box set holding the episode
+	DiscNumber int `bson:"disc_number,omitempty" json:"disc_number,omitempty"`
+}
+
+// EpisodeTotal returns the number of episodes of the season, counting the
+// listed episodes when there are any
+func (s *Season) EpisodeTotal() int {
+	if len(s.Episodes) > 0 {
+		return len(s.Episodes)
+	}
+	return s.EpisodeCount
 }
 
-// CreateBlurayRequest is the request body for creating a bluray
-type CreateBlurayRequest struct {
-	Title         string    `json:"title" binding:"required"`
-	Type          MediaType `json:"type" binding:"required"`
-	ReleaseYear   int       `json:"release_year,omitempty"`
-	Director      string    `json:"director,omitempty"`
-	Runtime       int       `json:"runtime,omitempty"`
-	Seasons       []Season  `json:"seasons,omitempty"`
-	Description   I18nText  `json:"description"`
-	Genre         []string  `json:"genre"`
-	CoverImageURL string    `json:"cover_image_url"`
-	BackdropURL   string    `json:"backdrop_url"`
-	PurchasePrice float64   `json:"purchase_price"`
-	PurchaseDate  time.Time `json:"purchase_date"`
-	Tags          []string  `json:"tags"`
-	Rating        float64   `json:"rating"`
-	TMDBID        string    `json:"tmdb_id,omitempty"`
+// RuntimeMinutes returns the summed runtime of the listed episodes
+func (s *Season) RuntimeMinutes() int {
+	total := 0
+	for _, episode := range s.Episodes {
+		total += episode.Runtime
+	}
+	return total
 }
 
-// UpdateBlurayRequest is the request body for updating a bluray
-type UpdateBlurayRequest struct {
-	Title         *string    `json:"title,omitempty"`
-	Type          *MediaType `json:"type,omitempty"`
-	ReleaseYear   *int       `json:"release_year,omitempty"`
-	Director      *string    `json:"director,omitempty"`
-	Runtime       *int       `json:"runtime,omitempty"`
-	Seasons       *[]Season  `json:"seasons,omitempty"`
-	Description   *I18nText  `json:"description,omitempty"`
-	Genre         *[]string  `json:"genre,omitempty"`
-	CoverImageURL *string    `json:"cover_image_url,omitempty"`
-	BackdropURL   *string    `json:"backdrop_url,omitempty"`
-	PurchasePrice *float64   `json:"purchase_price,omitempty"`
-	PurchaseDate  *time.Time `json:"purchase_date,omitempty"`
-	Tags          *[]string  `json:"tags,omitempty"`
-	Rating        *float64   `json:"rating,omitempty"`
-	TMDBID        *string    `json:"tmdb_id,omitempty"`
+// BlurayFilter selects the blurays of a listing. The zero filter matches
+// every bluray outside the trash.
+type BlurayFilter struct {
+	// CollectionID restricts the listing to a collection, nil for all of them
+	CollectionID *primitive.ObjectID
+	// Trashed lists the blurays in the trash instead of the others
+	Trashed bool
+	// Fields are equality conditions on stored fields. Dotted keys reach into
+	// the copies, and _id also takes $in and $nin lists.
+	Fields map[string]interface{}
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection is an independent library of blurays, such as the discs of one
+// household, with its own members. Blurays, tags and notifications belong to
+// exactly one collection.
+type Collection struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+
+	// The default collection holds the data from before collections
+	// existed. Users who are members of no collection work in it with their
+	// own role.
+	IsDefault bool `bson:"is_default" json:"is_default"`
+
+	Members []CollectionMember `bson:"members" json:"members"`
+
+	// Metadata
+	CreatedBy primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// CollectionMember is a user invited into a collection, with their role in it
+type CollectionMember struct {
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Username  string             `bson:"-" json:"username,omitempty"`
+	Role      UserRole           `bson:"role" json:"role"`
+	InvitedBy primitive.ObjectID `bson:"invited_by,omitempty" json:"invited_by,omitempty"`
+	AddedAt   time.Time          `bson:"added_at" json:"added_at"`
+}
+
+// Member returns the membership of the user, or nil when they are not a member
+func (c *Collection) Member(userID primitive.ObjectID) *CollectionMember {
+	for i := range c.Members {
+		if c.Members[i].UserID == userID {
+			return &c.Members[i]
+		}
+	}
+	return nil
+}
+
+// CreateCollectionRequest is the request body for creating a collection
+type CreateCollectionRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// UpdateCollectionRequest is the request body for updating a collection
+type UpdateCollectionRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// CollectionMemberRequest is the request body for inviting a user into a
+// collection or changing their role in it
+type CollectionMemberRequest struct {
+	UserID string   `json:"user_id"`
+	Role   UserRole `json:"role" binding:"required"`
+}
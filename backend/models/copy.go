@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CopyCondition defines the state of an owned copy
+type CopyCondition string
+
+const (
+	ConditionMint    CopyCondition = "mint"
+	ConditionGood    CopyCondition = "good"
+	ConditionFair    CopyCondition = "fair"
+	ConditionPoor    CopyCondition = "poor"
+	ConditionDamaged CopyCondition = "damaged"
+)
+
+// IsValid reports whether the condition is one of the known conditions
+func (c CopyCondition) IsValid() bool {
+	switch c {
+	case ConditionMint, ConditionGood, ConditionFair, ConditionPoor, ConditionDamaged:
+		return true
+	}
+	return false
+}
+
+// Copy is one physical copy of a title, e.g. the regular Blu-ray or the 4K
+// steelbook of a film. Copies are stored inside their bluray.
+type Copy struct {
+	ID primitive.ObjectID `bson:"_id" json:"id"`
+
+	// Physical release
+	Format         ReleaseFormat `bson:"format,omitempty" json:"format,omitempty"`
+	Edition        string        `bson:"edition,omitempty" json:"edition,omitempty"`
+	Packaging      Packaging     `bson:"packaging,omitempty" json:"packaging,omitempty"`
+	Publisher      string        `bson:"publisher,omitempty" json:"publisher,omitempty"`
+	RegionCode     string        `bson:"region_code,omitempty" json:"region_code,omitempty"`
+	Barcode        string        `bson:"barcode,omitempty" json:"barcode,omitempty"` // EAN-13 or UPC-A
+	DiscCount      int           `bson:"disc_count,omitempty" json:"disc_count,omitempty"`
+	AudioTracks    []string      `bson:"audio_tracks,omitempty" json:"audio_tracks,omitempty"`
+	SubtitleTracks []string      `bson:"subtitle_tracks,omitempty" json:"subtitle_tracks,omitempty"`
+
+	// Purchase
+	PurchasePrice float64       `bson:"purchase_price" json:"purchase_price"`
+	PurchaseDate  time.Time     `bson:"purchase_date" json:"purchase_date"`
+	Condition     CopyCondition `bson:"condition,omitempty" json:"condition,omitempty"`
+	Notes         string        `bson:"notes,omitempty" json:"notes,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+
+	// OnLoan is filled in from the loans on read
+	OnLoan bool `bson:"-" json:"on_loan"`
+}
+
+// SameRelease reports whether two copies describe the same purchase of the
+// same release, which the CSV import uses to skip copies it already has
+func (c *Copy) SameRelease(other *Copy) bool {
+	return c.Format == other.Format && c.Edition == other.Edition && c.Packaging == other.Packaging &&
+		c.Barcode == other.Barcode && c.PurchasePrice == other.PurchasePrice &&
+		c.PurchaseDate.Equal(other.PurchaseDate)
+}
+
+// CopyByID returns the copy with the given ID, or nil
+func (b *Bluray) CopyByID(id primitive.ObjectID) *Copy {
+	for i := range b.Copies {
+		if b.Copies[i].ID == id {
+			return &b.Copies[i]
+		}
+	}
+	return nil
+}
+
+// DropsCopies reports whether replacing the copies of the bluray with the
+// given ones would remove any of them
+func (b *Bluray) DropsCopies(copies []Copy) bool {
+	kept := make(map[primitive.ObjectID]bool, len(copies))
+	for _, cp := range copies {
+		kept[cp.ID] = true
+	}
+	for _, cp := range b.Copies {
+		if !kept[cp.ID] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Loan records a bluray lent to someone, either a registered user or anyone
+// identified by name and contact details. A loan is of one copy of the
+// bluray, or of every copy when CopyID is nil.
+type Loan struct {
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	BlurayID        primitive.ObjectID  `bson:"bluray_id" json:"bluray_id"`
+	CopyID          *primitive.ObjectID `bson:"copy_id,omitempty" json:"copy_id,omitempty"`
+	BorrowerName    string              `bson:"borrower_name" json:"borrower_name"`
+	BorrowerContact string              `bson:"borrower_contact,omitempty" json:"borrower_contact,omitempty"`
+	BorrowerID      *primitive.ObjectID `bson:"borrower_id,omitempty" json:"borrower_id,omitempty"`
+	LentAt          time.Time           `bson:"lent_at" json:"lent_at"`
+	DueAt           *time.Time          `bson:"due_at,omitempty" json:"due_at,omitempty"`
+	ReturnedAt      *time.Time          `bson:"returned_at,omitempty" json:"returned_at,omitempty"`
+	Notes           string              `bson:"notes,omitempty" json:"notes,omitempty"`
+
+	// OverdueNotifiedAt is set once the overdue notification has been sent
+	OverdueNotifiedAt *time.Time `bson:"overdue_notified_at,omitempty" json:"overdue_notified_at,omitempty"`
+
+	// BlurayTitle and CollectionID are those of the bluray, filled in when
+	// listing the loans still out
+	BlurayTitle  string             `bson:"-" json:"bluray_title,omitempty"`
+	CollectionID primitive.ObjectID `bson:"-" json:"-"`
+
+	// Metadata
+	LentBy    primitive.ObjectID `bson:"lent_by" json:"lent_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Covers reports whether the loan takes the given copy out
+func (l *Loan) Covers(copyID primitive.ObjectID) bool {
+	return l.CopyID == nil || *l.CopyID == copyID
+}
+
+// Overlaps reports whether two loans take out a copy in common
+func (l *Loan) Overlaps(other *Loan) bool {
+	return l.CopyID == nil || other.Covers(*l.CopyID)
+}
+
+// IsActive reports whether the bluray has not been returned yet
+func (l *Loan) IsActive() bool {
+	return l.ReturnedAt == nil
+}
+
+// IsOverdue reports whether the loan is still active past its due date
+func (l *Loan) IsOverdue(now time.Time) bool {
+	return l.IsActive() && l.DueAt != nil && l.DueAt.Before(now)
+}
+
+// CreateLoanRequest is the request body for lending a bluray
+type CreateLoanRequest struct {
+	CopyID          string     `json:"copy_id"`
+	BorrowerName    string     `json:"borrower_name"`
+	BorrowerContact string     `json:"borrower_contact"`
+	BorrowerID      string     `json:"borrower_id"`
+	LentAt          *time.Time `json:"lent_at,omitempty"`
+	DueAt           *time.Time `json:"due_at,omitempty"`
+	Notes           string     `json:"notes"`
+}
+
+// UpdateLoanRequest is the request body for updating a loan
+type UpdateLoanRequest struct {
+	BorrowerName    *string    `json:"borrower_name,omitempty"`
+	BorrowerContact *string    `json:"borrower_contact,omitempty"`
+	LentAt          *time.Time `json:"lent_at,omitempty"`
+	DueAt           *time.Time `json:"due_at,omitempty"`
+	Notes           *string    `json:"notes,omitempty"`
+}
+
+// ReturnLoanRequest is the optional request body for returning a bluray
+type ReturnLoanRequest struct {
+	ReturnedAt *time.Time `json:"returned_at,omitempty"`
+}
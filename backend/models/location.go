@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LocationKind is a level of the physical storage hierarchy
+type LocationKind string
+
+const (
+	LocationRoom      LocationKind = "room"
+	LocationShelfUnit LocationKind = "shelf_unit"
+	LocationShelf     LocationKind = "shelf"
+	LocationSlot      LocationKind = "slot"
+)
+
+// ParentKind returns the kind a location of this kind must be placed in, or
+// an empty kind for rooms, which are top-level
+func (k LocationKind) ParentKind() LocationKind {
+	switch k {
+	case LocationShelfUnit:
+		return LocationRoom
+	case LocationShelf:
+		return LocationShelfUnit
+	case LocationSlot:
+		return LocationShelf
+	}
+	return ""
+}
+
+// IsValid reports whether k is one of the known kinds
+func (k LocationKind) IsValid() bool {
+	switch k {
+	case LocationRoom, LocationShelfUnit, LocationShelf, LocationSlot:
+		return true
+	}
+	return false
+}
+
+// Location is a place where discs are stored: a room, a shelf unit in a
+// room, a shelf of a unit or a slot on a shelf
+type Location struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Name        string              `bson:"name" json:"name" binding:"required"`
+	Slug        string              `bson:"slug" json:"slug"` // e.g. "living-room", used by location: searches
+	Kind        LocationKind        `bson:"kind" json:"kind" binding:"required"`
+	ParentID    *primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	Capacity    int                 `bson:"capacity,omitempty" json:"capacity,omitempty"` // in discs, 0 if unknown
+	Description string              `bson:"description,omitempty" json:"description,omitempty"`
+
+	// Path is the full name of the location, e.g. "Living room > Billy > Shelf 2"
+	Path string `bson:"-" json:"path,omitempty"`
+
+	// CollectionID is the collection whose discs the location holds
+	CollectionID primitive.ObjectID `bson:"collection_id,omitempty" json:"collection_id"`
+
+	// Metadata
+	CreatedBy primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// LocationReport is the capacity and fill level of a location
+type LocationReport struct {
+	ID       primitive.ObjectID  `json:"id"`
+	Name     string              `json:"name"`
+	Kind     LocationKind        `json:"kind"`
+	ParentID *primitive.ObjectID `json:"parent_id,omitempty"`
+	Path     string              `json:"path"`
+
+	// BlurayCount counts the discs stored directly in the location and
+	// TotalBlurayCount includes the ones stored in its sub-locations
+	BlurayCount      int `json:"bluray_count"`
+	TotalBlurayCount int `json:"total_bluray_count"`
+
+	// Capacity is the location's own capacity, or the sum of its
+	// sub-locations' when it has none. FillRate is 0 when it is unknown.
+	Capacity int     `json:"capacity"`
+	FillRate float64 `json:"fill_rate"`
+}
@@ -10,17 +10,23 @@ import (
 type NotificationType string
 
 const (
-	NotificationBlurayAdded   NotificationType = "bluray_added"
-	NotificationBlurayRemoved NotificationType = "bluray_removed"
+	NotificationBlurayAdded      NotificationType = "bluray_added"
+	NotificationBlurayRemoved    NotificationType = "bluray_removed"
+	NotificationBlurayReverted   NotificationType = "bluray_reverted"
+	NotificationLoanOverdue      NotificationType = "loan_overdue"
+	NotificationCollectionInvite NotificationType = "collection_invite"
 )
 
 // Notification represents a system notification
 type Notification struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Type      NotificationType   `bson:"type" json:"type"`
-	Message   string             `bson:"message" json:"message"`
-	BlurayID  primitive.ObjectID `bson:"bluray_id,omitempty" json:"bluray_id,omitempty"`
-	Read      bool               `bson:"read" json:"read"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Type     NotificationType   `bson:"type" json:"type"`
+	Message  string             `bson:"message" json:"message"`
+	BlurayID primitive.ObjectID `bson:"bluray_id,omitempty" json:"bluray_id,omitempty"`
+	// CollectionID is the collection the notification is about. Notifications
+	// without one are shown in every collection.
+	CollectionID primitive.ObjectID `bson:"collection_id,omitempty" json:"collection_id,omitempty"`
+	Read         bool               `bson:"read" json:"read"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
 }
@@ -0,0 +1,18 @@
+package models
+
+// QueryOptions sort and page the items of a list or search. Cursor is the
+// next_cursor of the previous page, and Skip skips items past it. A Limit of
+// 0 returns every item. Only the bluray lists can be sorted.
+type QueryOptions struct {
+	Sort   SearchSort
+	Cursor string
+	Skip   int
+	Limit  int
+}
+
+// PageInfo tells where a page stands in the whole list: how many items
+// there are and the cursor of the next page, empty on the last page
+type PageInfo struct {
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor"`
+}
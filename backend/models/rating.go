@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Personal ratings are scores out of 10 with an optional short review
+const (
+	RatingMaxScore     = 10
+	RatingMaxReviewLen = 500
+)
+
+// UserRating is the personal rating of a bluray by one user. There is at most
+// one rating per user and bluray.
+type UserRating struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	BlurayID  primitive.ObjectID `bson:"bluray_id" json:"bluray_id"`
+	Score     float64            `bson:"score" json:"score"`
+	Review    string             `bson:"review,omitempty" json:"review,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// SetUserRatingRequest is the request body for rating a bluray
+type SetUserRatingRequest struct {
+	Score  float64 `json:"score" binding:"required"`
+	Review string  `json:"review"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "strings"
+
+// ReleaseFormat defines the disc format of a physical release
+type ReleaseFormat string
+
+const (
+	FormatBluray ReleaseFormat = "bluray"
+	Format4K     ReleaseFormat = "4k"
+	FormatDVD    ReleaseFormat = "dvd"
+	Format3D     ReleaseFormat = "3d"
+)
+
+// Packaging defines the case a physical release comes in
+type Packaging string
+
+const (
+	PackagingKeepcase  Packaging = "keepcase"
+	PackagingSteelbook Packaging = "steelbook"
+	PackagingDigibook  Packaging = "digibook"
+	PackagingMediabook Packaging = "mediabook"
+	PackagingBoxSet    Packaging = "box_set"
+)
+
+// releaseFormatAliases maps the spellings found on sleeves and in the DVDFr
+// catalogue to a format
+var releaseFormatAliases = map[string]ReleaseFormat{
+	"bluray":     FormatBluray,
+	"blu-ray":    FormatBluray,
+	"bd":         FormatBluray,
+	"brd":        FormatBluray,
+	"4k":         Format4K,
+	"uhd":        Format4K,
+	"4k uhd":     Format4K,
+	"4k-uhd":     Format4K,
+	"uhd-4k":     Format4K,
+	"brd-4k":     Format4K,
+	"dvd":        FormatDVD,
+	"3d":         Format3D,
+	"bluray3d":   Format3D,
+	"blu-ray 3d": Format3D,
+	"bd3d":       Format3D,
+	"brd-3d":     Format3D,
+}
+
+// ParseReleaseFormat returns the format matching value, case-insensitively
+// and accepting the usual aliases ("Blu-ray", "UHD", ...), or false when the
+// format is unknown
+func ParseReleaseFormat(value string) (ReleaseFormat, bool) {
+	format, ok := releaseFormatAliases[strings.ToLower(strings.TrimSpace(value))]
+	return format, ok
+}
+
+// ParsePackaging returns the packaging matching value, case-insensitively and
+// ignoring spaces and dashes ("Box set", "box-set"), or false when unknown
+func ParsePackaging(value string) (Packaging, bool) {
+	normalized := strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(value)))
+	switch packaging := Packaging(normalized); packaging {
+	case PackagingKeepcase, PackagingSteelbook, PackagingDigibook, PackagingMediabook, PackagingBoxSet:
+		return packaging, true
+	}
+	return "", false
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BlurayRevision is a snapshot of a bluray as saved by a write. Revisions
+// of a bluray are numbered from 1 in the order they were saved.
+type BlurayRevision struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BlurayID primitive.ObjectID `bson:"bluray_id" json:"bluray_id"`
+	Number   int                `bson:"number" json:"number"`
+	// EditedBy is empty for revisions saved by no signed-in user, such as
+	// the first revision of a bluray added before revisions were kept
+	EditedBy     *primitive.ObjectID `bson:"edited_by,omitempty" json:"edited_by,omitempty"`
+	EditedByName string              `bson:"edited_by_name,omitempty" json:"edited_by_name,omitempty"`
+	Bluray       Bluray              `bson:"bluray" json:"bluray"`
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// BlurayRevisionDiff lists the fields that differ from one revision of a
+// bluray to another
+type BlurayRevisionDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []AuditChange `json:"changes"`
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Permission names one action a role may allow
+type Permission string
+
+const (
+	PermBlurayCreate     Permission = "bluray.create"
+	PermBlurayUpdate     Permission = "bluray.update"
+	PermBlurayDelete     Permission = "bluray.delete"
+	PermImportRun        Permission = "import.run"
+	PermTagManage        Permission = "tag.manage"
+	PermSearchShare      Permission = "search.share"
+	PermLoanManage       Permission = "loan.manage"
+	PermLocationManage   Permission = "location.manage"
+	PermWishlistManage   Permission = "wishlist.manage"
+	PermTMDBSearch       Permission = "tmdb.search"
+	PermRatingWrite      Permission = "rating.write"
+	PermWatchLog         Permission = "watch.log"
+	PermUserManage       Permission = "user.manage"
+	PermRoleManage       Permission = "role.manage"
+	PermCollectionManage Permission = "collection.manage"
+	PermAuditRead        Permission = "audit.read"
+	PermSettingsManage   Permission = "settings.manage"
+)
+
+// AllPermissions lists every permission, in the order they are shown to admins
+var AllPermissions = []Permission{
+	PermBlurayCreate,
+	PermBlurayUpdate,
+	PermBlurayDelete,
+	PermImportRun,
+	PermTagManage,
+	PermSearchShare,
+	PermLoanManage,
+	PermLocationManage,
+	PermWishlistManage,
+	PermTMDBSearch,
+	PermRatingWrite,
+	PermWatchLog,
+	PermUserManage,
+	PermRoleManage,
+	PermCollectionManage,
+	PermAuditRead,
+	PermSettingsManage,
+}
+
+// IsValid reports whether p is one of the known permissions
+func (p Permission) IsValid() bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether p is one of the permissions that administer the
+// install rather than the library
+func (p Permission) IsAdmin() bool {
+	return p == PermUserManage || p == PermRoleManage || p == PermCollectionManage || p == PermAuditRead ||
+		p == PermSettingsManage
+}
+
+// Role is a named set of permissions. Users and collection members refer to
+// roles by name.
+type Role struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        UserRole           `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Permissions []Permission       `bson:"permissions" json:"permissions"`
+	// Built-in roles can be edited but not deleted, except admin which
+	// always holds every permission
+	BuiltIn   bool      `bson:"built_in" json:"built_in"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Has reports whether the role grants the permission
+func (r *Role) Has(permission Permission) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaff reports whether the role administers the install or can delete
+// from the library, the roles admins can require two-factor authentication
+// for. Admins and moderators are staff, and so is any custom role holding
+// one of their permissions.
+func (r *Role) IsStaff() bool {
+	for _, p := range r.Permissions {
+		if p.IsAdmin() || p == PermBlurayDelete {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRoles returns the built-in roles with their initial permissions
+func DefaultRoles() []*Role {
+	moderator := []Permission{}
+	for _, p := range AllPermissions {
+		if !p.IsAdmin() {
+			moderator = append(moderator, p)
+		}
+	}
+
+	return []*Role{
+		{Name: RoleAdmin, Description: "Full access, including users, roles and collections", Permissions: append([]Permission{}, AllPermissions...), BuiltIn: true},
+		{Name: RoleModerator, Description: "Manages the whole library", Permissions: moderator, BuiltIn: true},
+		{Name: RoleContributor, Description: "Adds and edits discs but cannot delete them", Permissions: []Permission{
+			PermBlurayCreate, PermBlurayUpdate, PermImportRun, PermTagManage, PermSearchShare, PermTMDBSearch, PermRatingWrite, PermWatchLog,
+		}, BuiltIn: true},
+		{Name: RoleUser, Description: "Browses the library, rates and logs viewings", Permissions: []Permission{PermRatingWrite, PermWatchLog}, BuiltIn: true},
+		{Name: RoleGuest, Description: "Read-only access", Permissions: []Permission{}, BuiltIn: true},
+	}
+}
+
+// CreateRoleRequest is the request body for creating a custom role
+type CreateRoleRequest struct {
+	Name        UserRole     `json:"name" binding:"required"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// UpdateRoleRequest is the request body for editing a role. Omitted fields
+// are left unchanged.
+type UpdateRoleRequest struct {
+	Description *string       `json:"description"`
+	Permissions *[]Permission `json:"permissions"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SortField names what blurays can be sorted by
+type SortField string
+
+const (
+	SortTitle     SortField = "title"
+	SortYear      SortField = "year"
+	SortRating    SortField = "rating"
+	SortPrice     SortField = "price"
+	SortPurchased SortField = "purchased"
+	SortAdded     SortField = "added"
+)
+
+// SortFields lists the fields blurays can be sorted by
+var SortFields = []SortField{SortTitle, SortYear, SortRating, SortPrice, SortPurchased, SortAdded}
+
+// SearchSort orders blurays by a sort field, in descending order when it
+// starts with "-" (e.g. "-year"). The empty sort orders by relevance to the
+// free words of a search, and newest first otherwise.
+type SearchSort string
+
+// Field returns the sort field and whether the order is descending
+func (s SearchSort) Field() (SortField, bool) {
+	if field, ok := strings.CutPrefix(string(s), "-"); ok {
+		return SortField(field), true
+	}
+	return SortField(s), false
+}
+
+// IsValid reports whether s is empty or sorts by a known field
+func (s SearchSort) IsValid() bool {
+	if s == "" {
+		return true
+	}
+	field, _ := s.Field()
+	for _, known := range SortFields {
+		if field == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SavedSearch is a search query kept under a name. Saved searches belong to
+// the user who saved them, except smart collections: searches shared with
+// every user of a collection.
+type SavedSearch struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name   string             `bson:"name" json:"name"`
+	Query  string             `bson:"query" json:"query"`
+	Sort   SearchSort         `bson:"sort,omitempty" json:"sort,omitempty"`
+	Pinned bool               `bson:"pinned" json:"pinned"`
+
+	// Smart collections are shared in the collection they were saved in
+	Shared       bool                `bson:"shared" json:"shared"`
+	CollectionID *primitive.ObjectID `bson:"collection_id,omitempty" json:"collection_id,omitempty"`
+
+	// Count is the number of blurays the search finds, filled in when the
+	// saved searches are listed
+	Count int `bson:"-" json:"count"`
+
+	// Metadata
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// CreateSavedSearchRequest is the request body for saving a search or a
+// smart collection
+type CreateSavedSearchRequest struct {
+	Name   string     `json:"name" binding:"required,max=64"`
+	Query  string     `json:"query" binding:"required"`
+	Sort   SearchSort `json:"sort"`
+	Pinned bool       `json:"pinned"`
+}
+
+// UpdateSavedSearchRequest is the request body for editing a saved search.
+// Omitted fields are left unchanged.
+type UpdateSavedSearchRequest struct {
+	Name   *string     `json:"name,omitempty"`
+	Query  *string     `json:"query,omitempty"`
+	Sort   *SearchSort `json:"sort,omitempty"`
+	Pinned *bool       `json:"pinned,omitempty"`
+}
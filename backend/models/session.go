@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a signed-in device. Access tokens name the session they were
+// issued for, and the session holds the hash of its current refresh token,
+// which changes every time it is used, along with the hash of the one it
+// replaced so that replays of it can be told from wrong tokens.
+type Session struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RefreshTokenHash string             `bson:"refresh_token_hash" json:"-"`
+	RotatedTokenHash string             `bson:"rotated_token_hash,omitempty" json:"-"`
+	UserAgent        string             `bson:"user_agent" json:"user_agent"`
+	IPAddress        string             `bson:"ip_address" json:"ip_address"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt       time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt        time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt        *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+
+	// Current marks the session of the request listing the sessions
+	Current bool `bson:"-" json:"current"`
+}
+
+// IsActive reports whether the session can still be used at the given time
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// AuthTokens are returned when signing in and when refreshing a session
+type AuthTokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the lifetime of the access token, in seconds
+	ExpiresIn int `json:"expires_in"`
+}
+
+// RefreshTokenRequest is the request body for refreshing a session or
+// signing out
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
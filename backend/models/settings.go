@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Settings are the install-wide options admins can change at runtime
+type Settings struct {
+	// RequireTwoFactorForStaff makes staff, see Role.IsStaff, set up
+	// two-factor authentication before they can do anything else
+	RequireTwoFactorForStaff bool `bson:"require_two_factor_for_staff" json:"require_two_factor_for_staff"`
+	// AuditRetentionDays is how long audit entries are kept, 0 keeps them
+	// forever
+	AuditRetentionDays int `bson:"audit_retention_days" json:"audit_retention_days"`
+	// TrashRetentionDays is how long deleted blurays and tags stay in the
+	// trash before they are purged, 0 keeps them until purged by hand
+	TrashRetentionDays int       `bson:"trash_retention_days" json:"trash_retention_days"`
+	UpdatedAt          time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// UpdateSettingsRequest is the request body for changing settings. Omitted
+// fields are left unchanged.
+type UpdateSettingsRequest struct {
+	RequireTwoFactorForStaff *bool `json:"require_two_factor_for_staff"`
+	AuditRetentionDays       *int  `json:"audit_retention_days"`
+	TrashRetentionDays       *int  `json:"trash_retention_days"`
+}
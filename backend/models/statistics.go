@@ -3,6 +3,7 @@ package models
 // Statistics represents collection statistics
 type Statistics struct {
 	TotalBlurays         int            `json:"total_blurays"`
+	TotalCopies          int            `json:"total_copies"`
 	TotalMovies          int            `json:"total_movies"`
 	TotalSeries          int            `json:"total_series"`
 	TotalSeasons         int            `json:"total_seasons"`
@@ -18,11 +19,13 @@ type Statistics struct {
 	GenreDistribution    map[string]int `json:"genre_distribution"`
 	TagDistribution      map[string]int `json:"tag_distribution"`
 	AverageRating        float64        `json:"average_rating"`
+	RatingCount          int            `json:"rating_count"`
 	TopRated             []BlurayStats  `json:"top_rated"`
 }
 
 type SimplifiedStatistics struct {
 	TotalBlurays int `json:"total_blurays"`
+	TotalCopies  int `json:"total_copies"`
 	TotalMovies  int `json:"total_movies"`
 	TotalSeries  int `json:"total_series"`
 	TotalSeasons int `json:"total_seasons"`
@@ -12,9 +12,13 @@ type Tag struct {
 	Name        string             `bson:"name" json:"name" binding:"required"`
 	Color       string             `bson:"color" json:"color"` // Hex color code
 	Description string             `bson:"description" json:"description"`
-	CreatedBy   primitive.ObjectID `bson:"created_by" json:"created_by"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	// CollectionID is the collection the tag belongs to; names are unique within it
+	CollectionID primitive.ObjectID `bson:"collection_id,omitempty" json:"collection_id"`
+	CreatedBy    primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	// DeletedAt is set while the tag is in the trash
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
 }
 
 // CreateTagRequest is the request body for creating a tag
@@ -6,14 +6,16 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// UserRole defines the different types of users in the system
+// UserRole is the name of the role of a user. The built-in roles below always
+// exist; admins can define more, see Role.
 type UserRole string
 
 const (
-	RoleAdmin     UserRole = "admin"
-	RoleModerator UserRole = "moderator"
-	RoleUser      UserRole = "user"
-	RoleGuest     UserRole = "guest"
+	RoleAdmin       UserRole = "admin"
+	RoleModerator   UserRole = "moderator"
+	RoleContributor UserRole = "contributor"
+	RoleUser        UserRole = "user"
+	RoleGuest       UserRole = "guest"
 )
 
 // User represents a user in the system
@@ -24,8 +26,46 @@ type User struct {
 	PasswordHash string             `bson:"password_hash" json:"-"`
 	Role         UserRole           `bson:"role" json:"role"`
 	Settings     UserSettings       `bson:"settings" json:"settings"`
-	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	TwoFactor    TwoFactor          `bson:"two_factor" json:"two_factor"`
+	Lockout      Lockout            `bson:"lockout" json:"lockout"`
+	// OIDC links the user to their account at the identity provider
+	OIDC      *ExternalIdentity `bson:"oidc,omitempty" json:"oidc,omitempty"`
+	CreatedAt time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time         `bson:"updated_at" json:"updated_at"`
+}
+
+// TwoFactor is the TOTP second factor of a user. The secret is set when the
+// user starts enrolling, and Enabled once they proved their authenticator
+// app produces the right codes.
+type TwoFactor struct {
+	Enabled   bool       `bson:"enabled" json:"enabled"`
+	EnabledAt *time.Time `bson:"enabled_at" json:"enabled_at,omitempty"`
+	Secret    string     `bson:"secret" json:"-"`
+	// RecoveryCodes holds the hashes of the unused recovery codes
+	RecoveryCodes []string `bson:"recovery_codes" json:"-"`
+	// LastUsedStep is the time step of the last accepted code, so that a
+	// code cannot be replayed
+	LastUsedStep int64 `bson:"last_used_step" json:"-"`
+}
+
+// Lockout counts the failed logins of a user. Too many in a row lock the
+// account for a while.
+type Lockout struct {
+	FailedAttempts int        `bson:"failed_attempts" json:"failed_attempts"`
+	LockedUntil    *time.Time `bson:"locked_until" json:"locked_until,omitempty"`
+	// UnlockTokenHash is the hash of the token of the last unlock email
+	UnlockTokenHash string `bson:"unlock_token_hash" json:"-"`
+}
+
+// IsLocked reports whether the account is locked at the given time
+func (l *Lockout) IsLocked(now time.Time) bool {
+	return l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
+
+// ExternalIdentity names an account at an OpenID Connect provider
+type ExternalIdentity struct {
+	Issuer  string `bson:"issuer" json:"issuer"`
+	Subject string `bson:"subject" json:"subject"`
 }
 
 // UserSettings stores user preferences
@@ -77,3 +117,49 @@ type PasswordResetToken struct {
 	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 }
+
+// TwoFactorEnrolment is what an authenticator app needs to be set up
+type TwoFactorEnrolment struct {
+	Secret string `json:"secret"`
+	// URI is an otpauth:// URI, usually shown as a QR code
+	URI string `json:"otpauth_uri"`
+}
+
+// TwoFactorStatus describes the two-factor authentication of the current user
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorCodeRequest carries a code from an authenticator app, or a
+// recovery code where those are accepted
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorLoginRequest is the second step of signing in with two-factor
+// authentication
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest is the request body for turning two-factor
+// authentication off
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// OIDCCallbackRequest hands the result of the identity provider login over
+// to the backend
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// UnlockAccountRequest carries the token of an unlock email
+type UnlockAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
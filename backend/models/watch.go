@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WatchEvent records a user watching a bluray, or one season or episode of
+// a series. Every user has their own history.
+type WatchEvent struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	BlurayID      primitive.ObjectID `bson:"bluray_id" json:"bluray_id"`
+	SeasonNumber  int                `bson:"season_number,omitempty" json:"season_number,omitempty"`
+	EpisodeNumber int                `bson:"episode_number,omitempty" json:"episode_number,omitempty"`
+	WatchedAt     time.Time          `bson:"watched_at" json:"watched_at"`
+	Rating        float64            `bson:"rating,omitempty" json:"rating,omitempty"` // Personal rating out of 10
+	Notes         string             `bson:"notes,omitempty" json:"notes,omitempty"`
+
+	// RuntimeMinutes is the time spent watching, taken from the movie,
+	// season or episode runtime when the event is logged
+	RuntimeMinutes int `bson:"runtime_minutes" json:"runtime_minutes"`
+
+	// CollectionID is the collection of the bluray, which the history is
+	// listed by
+	CollectionID primitive.ObjectID `bson:"collection_id,omitempty" json:"collection_id"`
+
+	// BlurayTitle is filled in when listing a history
+	BlurayTitle string `bson:"-" json:"bluray_title,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// CreateWatchEventRequest is the request body for logging a viewing
+type CreateWatchEventRequest struct {
+	BlurayID      string     `json:"bluray_id"`
+	SeasonNumber  int        `json:"season_number"`
+	EpisodeNumber int        `json:"episode_number"`
+	WatchedAt     *time.Time `json:"watched_at,omitempty"`
+	Rating        float64    `json:"rating"`
+	Notes         string     `json:"notes"`
+}
+
+// UpdateWatchEventRequest is the request body for updating a viewing
+type UpdateWatchEventRequest struct {
+	WatchedAt *time.Time `json:"watched_at,omitempty"`
+	Rating    *float64   `json:"rating,omitempty"`
+	Notes     *string    `json:"notes,omitempty"`
+}
+
+// WatchStatistics summarizes the viewing history of a user
+type WatchStatistics struct {
+	TotalEvents         int            `json:"total_events"`
+	TotalMinutes        int            `json:"total_minutes"`
+	WatchedBlurays      int            `json:"watched_blurays"`
+	NeverWatchedCount   int            `json:"never_watched_count"`
+	NeverWatched        []BlurayStats  `json:"never_watched"`
+	HoursWatchedByMonth []MonthlyWatch `json:"hours_watched_by_month"`
+}
+
+// MonthlyWatch is the time spent watching during a month, formatted YYYY-MM
+type MonthlyWatch struct {
+	Month   string  `json:"month"`
+	Minutes int     `json:"minutes"`
+	Hours   float64 `json:"hours"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Wishlist priorities, from 1 (nice to have) to 5 (must have)
+const (
+	WishlistPriorityMin     = 1
+	WishlistPriorityDefault = 3
+	WishlistPriorityMax     = 5
+)
+
+// WishlistItem is a disc we want but do not own yet. The title fields come
+// from TMDB, like those of a bluray, so that acquiring the item can turn it
+// into one.
+type WishlistItem struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TMDBID        string             `bson:"tmdb_id,omitempty" json:"tmdb_id,omitempty"`
+	Title         string             `bson:"title" json:"title"`
+	Type          MediaType          `bson:"type" json:"type"`
+	ReleaseYear   int                `bson:"release_year,omitempty" json:"release_year,omitempty"`
+	Director      string             `bson:"director,omitempty" json:"director,omitempty"`
+	Description   I18nText           `bson:"description" json:"description"`
+	Genre         I18nTextArray      `bson:"genre" json:"genre"`
+	CoverImageURL string             `bson:"cover_image_url,omitempty" json:"cover_image_url,omitempty"`
+	BackdropURL   string             `bson:"backdrop_url,omitempty" json:"backdrop_url,omitempty"`
+
+	// What we are looking for
+	DesiredFormat  ReleaseFormat `bson:"desired_format,omitempty" json:"desired_format,omitempty"`
+	DesiredEdition string        `bson:"desired_edition,omitempty" json:"desired_edition,omitempty"`
+	MaxPrice       float64       `bson:"max_price,omitempty" json:"max_price,omitempty"`
+	Priority       int           `bson:"priority" json:"priority"`
+	Notes          string        `bson:"notes,omitempty" json:"notes,omitempty"`
+
+	// Set once the item has been bought and added to the collection
+	AcquiredAt *time.Time          `bson:"acquired_at,omitempty" json:"acquired_at,omitempty"`
+	BlurayID   *primitive.ObjectID `bson:"bluray_id,omitempty" json:"bluray_id,omitempty"`
+
+	// CollectionID is the collection the item is wanted for
+	CollectionID primitive.ObjectID `bson:"collection_id,omitempty" json:"collection_id"`
+
+	// Metadata
+	AddedBy   primitive.ObjectID `bson:"added_by" json:"added_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// IsAcquired reports whether the item has been added to the collection
+func (w *WishlistItem) IsAcquired() bool {
+	return w.AcquiredAt != nil
+}
+
+// UpdateWishlistItemRequest is the request body for updating a wishlist item
+type UpdateWishlistItemRequest struct {
+	DesiredFormat  *ReleaseFormat `json:"desired_format,omitempty"`
+	DesiredEdition *string        `json:"desired_edition,omitempty"`
+	MaxPrice       *float64       `json:"max_price,omitempty"`
+	Priority       *int           `json:"priority,omitempty"`
+	Notes          *string        `json:"notes,omitempty"`
+}
+
+// AcquireWishlistItemRequest is the request body for marking a wishlist item
+// as acquired. It describes the copy that was bought.
+type AcquireWishlistItemRequest struct {
+	PurchasePrice float64       `json:"purchase_price"`
+	PurchaseDate  *time.Time    `json:"purchase_date,omitempty"`
+	Format        ReleaseFormat `json:"format,omitempty"`
+	Edition       string        `json:"edition,omitempty"`
+	Condition     CopyCondition `json:"condition,omitempty"`
+	Notes         string        `json:"notes,omitempty"`
+}
@@ -1,6 +1,12 @@
 package server
 
 import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
 	"eylexander/bluraymanager/api"
 	"eylexander/bluraymanager/controller"
 	"eylexander/bluraymanager/datastore"
@@ -16,6 +22,7 @@ type Server struct {
 	api                  *api.API
 	ctrl                 *controller.Controller
 	passwordResetHandler *controller.PasswordResetHandler
+	accountUnlockHandler *controller.AccountUnlockHandler
 }
 
 // NewServer creates a new server
@@ -26,22 +33,52 @@ func NewServer(ds datastore.Datastore) *Server {
 	apiHandler := api.NewAPI(ctrl)
 	emailService := services.NewEmailService()
 	passwordResetHandler := ctrl.NewPasswordResetHandler(ds, emailService)
+	accountUnlockHandler := ctrl.NewAccountUnlockHandler(emailService)
+	ctrl.SetOIDCProvider(services.NewOIDCProvider())
 
 	router := gin.Default()
+	// Rate limits count on the client IP, which X-Forwarded-For may only
+	// set when the request comes through a trusted proxy
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Printf("WARNING TRUSTED_PROXIES: %v", err)
+	}
 	router.Use(ctrl.CORSMiddleware())
 	router.Use(ctrl.LocaleMiddleware())
+	router.Use(ctrl.AuditMiddleware())
 
 	s := &Server{
 		router:               router,
 		api:                  apiHandler,
 		ctrl:                 ctrl,
 		passwordResetHandler: passwordResetHandler,
+		accountUnlockHandler: accountUnlockHandler,
 	}
 
 	s.setupRoutes()
 	return s
 }
 
+// trustedProxies reads TRUSTED_PROXIES, a comma-separated list of proxy
+// addresses or networks, or "none". By default only loopback is trusted,
+// which covers a reverse proxy on the same host; proxies elsewhere, such as
+// in a private network, have to be listed.
+func trustedProxies() []string {
+	value := os.Getenv("TRUSTED_PROXIES")
+	switch value {
+	case "":
+		return []string{"127.0.0.0/8", "::1/128"}
+	case "none":
+		return nil
+	}
+	var proxies []string
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func (s *Server) setupRoutes() {
 	// Health check
 	s.router.GET("/api/health", func(c *gin.Context) {
@@ -58,30 +95,84 @@ func (s *Server) setupRoutes() {
 			setup.POST("/install", s.api.InitialSetup)
 		}
 
-		// Public routes
+		// Public routes. Everything that checks credentials or sends email
+		// is rate limited per client and per account.
+		authLimit := s.ctrl.RateLimit(controller.RateLimitRule{
+			Name:       "auth",
+			PerIP:      controller.Every(time.Minute, 30),
+			PerAccount: controller.Every(time.Minute, 10),
+		})
+		// Refresh tokens are long random strings, so the client limit is
+		// enough to keep them from being guessed
+		refreshLimit := s.ctrl.RateLimit(controller.RateLimitRule{
+			Name:  "refresh",
+			PerIP: controller.Every(time.Minute, 30),
+		})
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/register", s.api.Register)
-			auth.POST("/login", s.api.Login)
-			auth.POST("/forgot-password", s.passwordResetHandler.RequestPasswordReset)
-			auth.POST("/reset-password", s.passwordResetHandler.ResetPassword)
+			auth.POST("/register", authLimit, s.api.Register)
+			auth.POST("/login", authLimit, s.api.Login)
+			auth.POST("/login/2fa", authLimit, s.api.LoginTwoFactor)
+			auth.GET("/oidc", s.api.GetOIDCConfig)
+			auth.GET("/oidc/login", s.api.StartOIDCLogin)
+			auth.POST("/oidc/callback", authLimit, s.api.CompleteOIDCLogin)
+			auth.POST("/refresh", refreshLimit, s.api.RefreshSession)
+			auth.POST("/logout", s.api.Logout)
+			auth.POST("/forgot-password", authLimit, s.passwordResetHandler.RequestPasswordReset)
+			auth.POST("/reset-password", authLimit, s.passwordResetHandler.ResetPassword)
+			auth.POST("/unlock/request", authLimit, s.accountUnlockHandler.RequestUnlock)
+			auth.POST("/unlock", authLimit, s.accountUnlockHandler.Unlock)
 		}
 
 		// Protected routes
 		protected := v1.Group("")
 		protected.Use(s.ctrl.AuthMiddleware())
 		{
+			// Account routes staff members can reach before setting up
+			// two-factor authentication
+			account := protected.Group("/user")
+			{
+				account.GET("/me", s.api.GetCurrentUser)
+				account.GET("/2fa", s.api.GetTwoFactorStatus)
+				account.POST("/2fa/enroll", s.ctrl.RequireSession(), s.api.EnrollTwoFactor)
+				account.POST("/2fa/verify", s.ctrl.RequireSession(), s.api.ConfirmTwoFactor)
+			}
+
+			// Everything else waits for two-factor authentication when the
+			// settings require it
+			protected.Use(s.ctrl.RequireTwoFactor())
+
 			// User routes
 			user := protected.Group("/user")
 			{
-				user.GET("/me", s.api.GetCurrentUser)
+				user.POST("/2fa/disable", s.ctrl.RequireSession(), s.api.DisableTwoFactor)
+				user.POST("/2fa/recovery-codes", s.ctrl.RequireSession(), s.api.RegenerateRecoveryCodes)
 				user.PUT("/settings", s.api.UpdateUserSettings)
 				user.PUT("/username", s.api.UpdateUsername)
-				user.PUT("/password", s.api.UpdatePassword)
+				user.PUT("/password", s.ctrl.RequireSession(), s.api.UpdatePassword)
+
+				// Devices the user is signed in on
+				user.GET("/sessions", s.api.ListSessions)
+				user.DELETE("/sessions", s.ctrl.RequireSession(), s.api.RevokeOtherSessions)
+				user.DELETE("/sessions/:id", s.ctrl.RequireSession(), s.api.RevokeSession)
+
+				// Personal API tokens for scripts
+				user.GET("/tokens", s.api.ListAPITokens)
+				user.POST("/tokens", s.ctrl.RequireSession(), s.api.CreateAPIToken)
+				user.DELETE("/tokens/:id", s.ctrl.RequireSession(), s.api.RevokeAPIToken)
 			}
 
+			// Collections the user can switch between
+			protected.GET("/collections", s.api.ListCollections)
+			protected.GET("/collections/current", s.ctrl.CollectionMiddleware(), s.api.GetCurrentCollection)
+
+			// Library routes work on the collection picked with the
+			// X-Collection-ID header, the default collection otherwise
+			library := protected.Group("")
+			library.Use(s.ctrl.CollectionMiddleware())
+
 			// Bluray routes (all users can view)
-			blurays := protected.Group("/blurays")
+			blurays := library.Group("/blurays")
 			{
 				blurays.GET("", s.api.ListBlurays)
 				blurays.GET("/simplified", s.api.ListSimplifiedBlurays)
@@ -89,57 +180,161 @@ func (s *Server) setupRoutes() {
 				blurays.GET("/:id", s.api.GetBluray)
 				blurays.GET("/export", s.api.ExportBlurays)
 
-				// Only admins and moderators can create/update/delete
-				blurays.POST("", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.CreateBluray)
-				blurays.POST("/import", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.ImportBlurays)
-				blurays.PUT("/:id", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.UpdateBluray)
-				blurays.PUT("/:id/tags", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.UpdateBlurayTags)
-				blurays.DELETE("/:id", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.DeleteBluray)
+				// Changes need the matching bluray permission
+				blurays.POST("", s.ctrl.RequirePermission(models.PermBlurayCreate), s.api.CreateBluray)
+				blurays.POST("/import", s.ctrl.RequirePermission(models.PermImportRun), s.api.ImportBlurays)
+				blurays.PUT("/:id", s.ctrl.RequirePermission(models.PermBlurayUpdate), s.api.UpdateBluray)
+				blurays.PUT("/:id/tags", s.ctrl.RequirePermission(models.PermBlurayUpdate), s.api.UpdateBlurayTags)
+				blurays.DELETE("/:id", s.ctrl.RequirePermission(models.PermBlurayDelete), s.api.DeleteBluray)
+
+				// Copy routes, adding a copy adds a disc and removing one deletes it
+				blurays.POST("/:id/copies", s.ctrl.RequirePermission(models.PermBlurayCreate), s.api.AddCopy)
+				blurays.PUT("/:id/copies/:copy_id", s.ctrl.RequirePermission(models.PermBlurayUpdate), s.api.UpdateCopy)
+				blurays.DELETE("/:id/copies/:copy_id", s.ctrl.RequirePermission(models.PermBlurayDelete), s.api.DeleteCopy)
+
+				// Loans (all users can view, lending needs loan.manage)
+				blurays.GET("/:id/loans", s.api.ListBlurayLoans)
+				blurays.POST("/:id/loans", s.ctrl.RequirePermission(models.PermLoanManage), s.api.CreateLoan)
+				blurays.PUT("/:id/loans/:loan_id", s.ctrl.RequirePermission(models.PermLoanManage), s.api.UpdateLoan)
+				blurays.POST("/:id/loans/:loan_id/return", s.ctrl.RequirePermission(models.PermLoanManage), s.api.ReturnLoan)
+				blurays.DELETE("/:id/loans/:loan_id", s.ctrl.RequirePermission(models.PermLoanManage), s.api.DeleteLoan)
+
+				// Personal ratings (every user with rating.write rates for themselves)
+				blurays.GET("/:id/rating", s.api.GetMyRating)
+				blurays.PUT("/:id/rating", s.ctrl.RequirePermission(models.PermRatingWrite), s.api.SetMyRating)
+				blurays.DELETE("/:id/rating", s.ctrl.RequirePermission(models.PermRatingWrite), s.api.DeleteMyRating)
+
+				// Revisions, saved on every change; restoring one is an update
+				blurays.GET("/:id/revisions", s.api.ListBlurayRevisions)
+				blurays.GET("/:id/revisions/diff", s.api.DiffBlurayRevisions)
+				blurays.GET("/:id/revisions/:number", s.api.GetBlurayRevision)
+				blurays.POST("/:id/revisions/:number/restore", s.ctrl.RequirePermission(models.PermBlurayUpdate), s.api.RestoreBlurayRevision)
 			}
 
+			// Blurays currently lent out
+			library.GET("/loans", s.api.ListActiveLoans)
+
 			// Tag routes
-			tags := protected.Group("/tags")
+			tags := library.Group("/tags")
 			{
 				tags.GET("", s.api.ListTags)
 				tags.GET("/:id", s.api.GetTag)
 
-				// Only and admins and moderators can create/update/delete tags
-				tags.POST("", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.CreateTag)
-				tags.PUT("/:id", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.UpdateTag)
-				tags.DELETE("/:id", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.DeleteTag)
+				// Changing tags needs tag.manage
+				tags.POST("", s.ctrl.RequirePermission(models.PermTagManage), s.api.CreateTag)
+				tags.PUT("/:id", s.ctrl.RequirePermission(models.PermTagManage), s.api.UpdateTag)
+				tags.DELETE("/:id", s.ctrl.RequirePermission(models.PermTagManage), s.api.DeleteTag)
+			}
+
+			// Trash routes, deleted blurays and tags wait there until they
+			// are restored or purged
+			trash := library.Group("/trash")
+			{
+				trash.GET("", s.api.ListTrash)
+				trash.POST("/blurays/:id/restore", s.ctrl.RequirePermission(models.PermBlurayDelete), s.api.RestoreBluray)
+				trash.DELETE("/blurays/:id", s.ctrl.RequirePermission(models.PermBlurayDelete), s.api.PurgeBluray)
+				trash.POST("/tags/:id/restore", s.ctrl.RequirePermission(models.PermTagManage), s.api.RestoreTag)
+				trash.DELETE("/tags/:id", s.ctrl.RequirePermission(models.PermTagManage), s.api.PurgeTag)
+			}
+
+			// Saved search routes, each user keeps their own searches and
+			// sees the smart collections shared with everyone
+			savedSearches := library.Group("/saved-searches")
+			{
+				savedSearches.GET("", s.api.ListSavedSearches)
+				savedSearches.POST("", s.api.CreateSavedSearch)
+				savedSearches.GET("/:id", s.api.GetSavedSearch)
+				savedSearches.GET("/:id/results", s.api.RunSavedSearch)
+				savedSearches.PUT("/:id", s.api.UpdateSavedSearch)
+				savedSearches.DELETE("/:id", s.api.DeleteSavedSearch)
+			}
+
+			// Smart collections are listed and run with the saved searches;
+			// changing them needs search.share
+			smartCollections := library.Group("/smart-collections")
+			{
+				smartCollections.POST("", s.ctrl.RequirePermission(models.PermSearchShare), s.api.CreateSmartCollection)
+				smartCollections.PUT("/:id", s.ctrl.RequirePermission(models.PermSearchShare), s.api.UpdateSmartCollection)
+				smartCollections.DELETE("/:id", s.ctrl.RequirePermission(models.PermSearchShare), s.api.DeleteSmartCollection)
+			}
+
+			// Location routes
+			locations := library.Group("/locations")
+			{
+				locations.GET("", s.api.ListLocations)
+				locations.GET("/report", s.api.GetLocationReport)
+				locations.GET("/:id", s.api.GetLocation)
+
+				// Changing locations needs location.manage
+				locations.POST("", s.ctrl.RequirePermission(models.PermLocationManage), s.api.CreateLocation)
+				locations.PUT("/:id", s.ctrl.RequirePermission(models.PermLocationManage), s.api.UpdateLocation)
+				locations.DELETE("/:id", s.ctrl.RequirePermission(models.PermLocationManage), s.api.DeleteLocation)
+			}
+
+			// Wishlist routes
+			wishlist := library.Group("/wishlist")
+			{
+				wishlist.GET("", s.api.ListWishlistItems)
+				wishlist.GET("/:id", s.api.GetWishlistItem)
+
+				// Changing the wishlist and buying from it needs wishlist.manage
+				wishlist.POST("", s.ctrl.RequirePermission(models.PermWishlistManage), s.api.CreateWishlistItem)
+				wishlist.PUT("/:id", s.ctrl.RequirePermission(models.PermWishlistManage), s.api.UpdateWishlistItem)
+				wishlist.DELETE("/:id", s.ctrl.RequirePermission(models.PermWishlistManage), s.api.DeleteWishlistItem)
+				wishlist.POST("/:id/acquire", s.ctrl.RequirePermission(models.PermWishlistManage), s.api.AcquireWishlistItem)
+			}
+
+			// Watch log routes, each user sees and changes only their own history
+			watch := library.Group("/watch")
+			{
+				watch.GET("", s.api.ListWatchEvents)
+
+				// Logging viewings needs watch.log
+				watch.POST("", s.ctrl.RequirePermission(models.PermWatchLog), s.api.LogWatchEvent)
+				watch.PUT("/:id", s.ctrl.RequirePermission(models.PermWatchLog), s.api.UpdateWatchEvent)
+				watch.DELETE("/:id", s.ctrl.RequirePermission(models.PermWatchLog), s.api.DeleteWatchEvent)
 			}
 
 			// Statistics routes (all authenticated users can view)
-			stats := protected.Group("/statistics")
+			stats := library.Group("/statistics")
 			{
 				stats.GET("", s.api.GetStatistics)
 				stats.GET("/simplified", s.api.GetSimplifiedStatistics)
+				stats.GET("/watch", s.api.GetWatchStatistics)
 			}
 
-			// TMDB routes (only authenticated users who can add blurays can use)
-			tmdb := protected.Group("/tmdb")
+			// Lookups call upstream APIs with quotas, so they are rate limited
+			lookup := library.Group("")
+			lookup.Use(s.ctrl.RateLimit(controller.RateLimitRule{
+				Name:       "lookup",
+				PerIP:      controller.Every(time.Minute, 120),
+				PerAccount: controller.Every(time.Minute, 60),
+			}))
+
+			// TMDB routes (need tmdb.search)
+			tmdb := lookup.Group("/tmdb")
 			{
-				tmdb.GET("/search", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.SearchTMDB)
-				tmdb.GET("/find/:external_id", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.FindByExternalID)
-				tmdb.GET("/:type/:id", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.GetTMDBDetails)
+				tmdb.GET("/search", s.ctrl.RequirePermission(models.PermTMDBSearch), s.api.SearchTMDB)
+				tmdb.GET("/find/:external_id", s.ctrl.RequirePermission(models.PermTMDBSearch), s.api.FindByExternalID)
+				tmdb.GET("/:type/:id", s.ctrl.RequirePermission(models.PermTMDBSearch), s.api.GetTMDBDetails)
 			}
 
-			// Barcode lookup route (only authenticated users who can add blurays can use)
-			protected.GET("/barcode/:barcode", s.ctrl.RequireRole(models.RoleAdmin, models.RoleModerator), s.api.LookupBarcode)
+			// Barcode lookup route (needs tmdb.search)
+			lookup.GET("/barcode/:barcode", s.ctrl.RequirePermission(models.PermTMDBSearch), s.api.LookupBarcode)
 
 			// Notification routes
-			notifications := protected.Group("/notifications")
+			notifications := library.Group("/notifications")
 			{
 				notifications.GET("", s.api.GetNotifications)
 				notifications.PUT("/:id/read", s.api.MarkNotificationRead)
 				notifications.PUT("/read-all", s.api.MarkAllNotificationsRead)
 			}
 
-			// Admin-only routes
+			// Administration routes, each group needs its own permission
 			admin := protected.Group("/admin")
-			admin.Use(s.ctrl.RequireRole("admin"))
 			{
 				users := admin.Group("/users")
+				users.Use(s.ctrl.RequirePermission(models.PermUserManage))
 				{
 					users.GET("", s.api.ListUsers)
 					users.GET("/:id", s.api.GetUser)
@@ -147,7 +342,42 @@ func (s *Server) setupRoutes() {
 					users.PUT("/:id", s.api.UpdateUser)
 					users.DELETE("/:id", s.api.DeleteUser)
 					users.PUT("/:id/role", s.api.UpdateUserRole)
+					users.DELETE("/:id/2fa", s.api.ResetUserTwoFactor)
+					users.POST("/:id/unlock", s.api.UnlockUser)
 				}
+
+				// Install-wide settings, such as requiring two-factor
+				// authentication for staff
+				settings := admin.Group("/settings")
+				settings.Use(s.ctrl.RequirePermission(models.PermSettingsManage))
+				{
+					settings.GET("", s.api.GetSettings)
+					settings.PUT("", s.api.UpdateSettings)
+				}
+
+				roles := admin.Group("/roles")
+				roles.Use(s.ctrl.RequirePermission(models.PermRoleManage))
+				{
+					roles.GET("", s.api.ListRoles)
+					roles.GET("/:name", s.api.GetRole)
+					roles.POST("", s.api.CreateRole)
+					roles.PUT("/:name", s.api.UpdateRole)
+					roles.DELETE("/:name", s.api.DeleteRole)
+				}
+
+				collections := admin.Group("/collections")
+				collections.Use(s.ctrl.RequirePermission(models.PermCollectionManage))
+				{
+					collections.POST("", s.api.CreateCollection)
+					collections.PUT("/:id", s.api.UpdateCollection)
+					collections.DELETE("/:id", s.api.DeleteCollection)
+					collections.POST("/:id/members", s.api.AddCollectionMember)
+					collections.PUT("/:id/members/:user_id", s.api.UpdateCollectionMember)
+					collections.DELETE("/:id/members/:user_id", s.api.RemoveCollectionMember)
+				}
+
+				// Who changed what in the library and the accounts
+				admin.GET("/audit", s.ctrl.RequirePermission(models.PermAuditRead), s.api.ListAuditEntries)
 			}
 		}
 	}
@@ -155,8 +385,13 @@ func (s *Server) setupRoutes() {
 	s.router.NoRoute(s.DefaultResponse)
 }
 
-// Start starts the HTTP server
+// Start starts the background jobs and the HTTP server
 func (s *Server) Start(port string) error {
+	go s.ctrl.WatchOverdueLoans(context.Background(), time.Hour)
+	go s.ctrl.WatchExpiredSessions(context.Background(), time.Hour)
+	go s.ctrl.WatchAuditRetention(context.Background(), time.Hour)
+	go s.ctrl.WatchTrash(context.Background(), time.Hour)
+
 	return s.router.Run(":" + port)
 }
 
@@ -0,0 +1,2661 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"eylexander/bluraymanager/datastore"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testClient drives the full router against an in-memory datastore. It
+// keeps the cookies it is sent, as a browser would.
+type testClient struct {
+	t       *testing.T
+	server  *Server
+	token   string
+	cookies map[string]*http.Cookie
+}
+
+func newTestClient(t *testing.T) (*testClient, datastore.Datastore) {
+	t.Helper()
+	ds := datastore.NewMemoryDatastore()
+	return &testClient{t: t, server: NewServer(ds)}, ds
+}
+
+func (tc *testClient) do(method, path string, body interface{}) (int, map[string]interface{}) {
+	tc.t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			tc.t.Fatalf("encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if tc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+	}
+
+	for _, cookie := range tc.cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	tc.server.router.ServeHTTP(rec, req)
+	for _, cookie := range rec.Result().Cookies() {
+		if tc.cookies == nil {
+			tc.cookies = make(map[string]*http.Cookie)
+		}
+		if cookie.MaxAge < 0 {
+			delete(tc.cookies, cookie.Name)
+		} else {
+			tc.cookies[cookie.Name] = cookie
+		}
+	}
+
+	var response map[string]interface{}
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			tc.t.Fatalf("%s %s: decoding response %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code, response
+}
+
+// login authenticates the client for subsequent requests
+func (tc *testClient) login(identifier, password string) {
+	tc.t.Helper()
+	code, body := tc.do(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"identifier": identifier,
+		"password":   password,
+	})
+	if code != http.StatusOK {
+		tc.t.Fatalf("login as %s: status %d, body %v", identifier, code, body)
+	}
+	tc.token = body["token"].(string)
+}
+
+func (tc *testClient) expect(method, path string, body interface{}, wantCode int) map[string]interface{} {
+	tc.t.Helper()
+	code, response := tc.do(method, path, body)
+	if code != wantCode {
+		tc.t.Fatalf("%s %s: status %d, want %d (body %v)", method, path, code, wantCode, response)
+	}
+	return response
+}
+
+// exportCSV downloads the collection as CSV
+func (tc *testClient) exportCSV() []byte {
+	tc.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/blurays/export", nil)
+	req.Header.Set("Authorization", "Bearer "+tc.token)
+	rec := httptest.NewRecorder()
+	tc.server.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		tc.t.Fatalf("export: status %d", rec.Code)
+	}
+	return rec.Body.Bytes()
+}
+
+// importCSV uploads a CSV file and returns the import report
+func (tc *testClient) importCSV(csv []byte) string {
+	tc.t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "bluray-collection.csv")
+	if err != nil {
+		tc.t.Fatal(err)
+	}
+	part.Write(csv)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/blurays/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+tc.token)
+	rec := httptest.NewRecorder()
+	tc.server.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		tc.t.Fatalf("import: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	return rec.Body.String()
+}
+
+func TestSetupAndBlurayLifecycle(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodGet, "/api/health", nil, http.StatusOK)
+	tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusUnauthorized)
+
+	check := tc.expect(http.MethodGet, "/api/v1/setup/check", nil, http.StatusOK)
+	if check["needsSetup"] != true {
+		t.Fatalf("needsSetup = %v, want true", check["needsSetup"])
+	}
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin2",
+		"email":    "admin2@example.com",
+		"password": "secret123",
+	}, http.StatusBadRequest)
+
+	tc.login("admin", "secret123")
+
+	tag := tc.expect(http.MethodPost, "/api/v1/tags", map[string]string{"name": "Action"}, http.StatusCreated)
+	tagID := tag["tag"].(map[string]interface{})["id"].(string)
+
+	created := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":        "Heat",
+		"type":         "movie",
+		"release_year": 1995,
+		"tmdb_id":      "949",
+		"tags":         []string{tagID},
+	}, http.StatusCreated)
+	blurayID := created["bluray"].(map[string]interface{})["id"].(string)
+
+	// Duplicate TMDB IDs are rejected by the controller
+	tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":   "Heat (again)",
+		"type":    "movie",
+		"tmdb_id": "949",
+	}, http.StatusBadRequest)
+
+	list := tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)
+	if n := len(list["blurays"].([]interface{})); n != 1 {
+		t.Errorf("ListBlurays returned %d blurays, want 1", n)
+	}
+	tc.expect(http.MethodGet, "/api/v1/blurays?limit=-5", nil, http.StatusBadRequest)
+	tc.expect(http.MethodGet, "/api/v1/admin/users?skip=-1", nil, http.StatusBadRequest)
+
+	search := tc.expect(http.MethodGet, "/api/v1/blurays/search?q=tag:act", nil, http.StatusOK)
+	if n := len(search["blurays"].([]interface{})); n != 1 {
+		t.Errorf("SearchBlurays(tag:act) returned %d blurays, want 1", n)
+	}
+
+	stats := tc.expect(http.MethodGet, "/api/v1/statistics", nil, http.StatusOK)
+	if total := stats["statistics"].(map[string]interface{})["total_blurays"]; total != float64(1) {
+		t.Errorf("total_blurays = %v, want 1", total)
+	}
+
+	notifications := tc.expect(http.MethodGet, "/api/v1/notifications", nil, http.StatusOK)
+	if n := len(notifications["notifications"].([]interface{})); n != 1 {
+		t.Errorf("got %d notifications after creating a bluray, want 1", n)
+	}
+
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+blurayID, nil, http.StatusOK)
+	tc.expect(http.MethodGet, "/api/v1/blurays/"+blurayID, nil, http.StatusNotFound)
+}
+
+func TestGuestCannotModifyCollection(t *testing.T) {
+	tc, ds := newTestClient(t)
+
+	if _, err := ds.EnsureGuestUser(context.Background()); err != nil {
+		t.Fatalf("EnsureGuestUser: %v", err)
+	}
+	tc.login("guest", "guest")
+
+	tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)
+	tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Heat",
+		"type":  "movie",
+	}, http.StatusForbidden)
+	tc.expect(http.MethodGet, "/api/v1/admin/users", nil, http.StatusForbidden)
+}
+
+func TestLoanLifecycle(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	created := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Heat",
+		"type":  "movie",
+	}, http.StatusCreated)
+	blurayID := created["bluray"].(map[string]interface{})["id"].(string)
+	loansPath := "/api/v1/blurays/" + blurayID + "/loans"
+
+	tc.expect(http.MethodPost, loansPath, map[string]interface{}{}, http.StatusBadRequest)
+
+	lent := tc.expect(http.MethodPost, loansPath, map[string]interface{}{
+		"borrower_name": "Sam",
+		"lent_at":       time.Now().AddDate(0, 0, -20),
+		"due_at":        time.Now().AddDate(0, 0, -6),
+	}, http.StatusCreated)
+	loanID := lent["loan"].(map[string]interface{})["id"].(string)
+
+	// A disc cannot be lent twice
+	tc.expect(http.MethodPost, loansPath, map[string]interface{}{"borrower_name": "Kim"}, http.StatusBadRequest)
+
+	bluray := tc.expect(http.MethodGet, "/api/v1/blurays/"+blurayID, nil, http.StatusOK)["bluray"].(map[string]interface{})
+	if bluray["on_loan"] != true || bluray["current_loan"] == nil {
+		t.Errorf("bluray on_loan = %v, current_loan = %v, want the active loan", bluray["on_loan"], bluray["current_loan"])
+	}
+
+	out := tc.expect(http.MethodGet, "/api/v1/loans?overdue=true", nil, http.StatusOK)["loans"].([]interface{})
+	if len(out) != 1 || out[0].(map[string]interface{})["bluray_title"] != "Heat" {
+		t.Fatalf("overdue loans = %v, want the Heat loan", out)
+	}
+
+	// Overdue loans are notified once
+	for _, want := range []int{1, 0} {
+		n, err := tc.server.ctrl.CheckOverdueLoans(context.Background())
+		if err != nil || n != want {
+			t.Fatalf("CheckOverdueLoans = %d, %v, want %d", n, err, want)
+		}
+	}
+	notifications := tc.expect(http.MethodGet, "/api/v1/notifications", nil, http.StatusOK)["notifications"].([]interface{})
+	if notifications[0].(map[string]interface{})["type"] != "loan_overdue" {
+		t.Errorf("latest notification = %v, want loan_overdue", notifications[0])
+	}
+
+	tc.expect(http.MethodPost, loansPath+"/"+loanID+"/return", nil, http.StatusOK)
+	tc.expect(http.MethodPost, loansPath+"/"+loanID+"/return", nil, http.StatusBadRequest)
+
+	list := tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)["blurays"].([]interface{})
+	if list[0].(map[string]interface{})["on_loan"] != false {
+		t.Error("returned bluray is still on loan")
+	}
+	if out := tc.expect(http.MethodGet, "/api/v1/loans", nil, http.StatusOK)["loans"].([]interface{}); len(out) != 0 {
+		t.Errorf("got %d loans out after the return, want 0", len(out))
+	}
+
+	history := tc.expect(http.MethodGet, loansPath, nil, http.StatusOK)["loans"].([]interface{})
+	if len(history) != 1 || history[0].(map[string]interface{})["returned_at"] == nil {
+		t.Errorf("loan history = %v, want the returned loan", history)
+	}
+
+	tc.expect(http.MethodDelete, loansPath+"/"+primitive.NewObjectID().Hex(), nil, http.StatusNotFound)
+	tc.expect(http.MethodDelete, loansPath+"/"+loanID, nil, http.StatusOK)
+
+	// The copies of a title are lent one at a time
+	bluray = tc.expect(http.MethodPost, "/api/v1/blurays/"+blurayID+"/copies", map[string]interface{}{"format": "4k"}, http.StatusCreated)["bluray"].(map[string]interface{})
+	copies := bluray["copies"].([]interface{})
+	firstCopy := copies[0].(map[string]interface{})["id"].(string)
+	secondCopy := copies[1].(map[string]interface{})["id"].(string)
+
+	tc.expect(http.MethodPost, loansPath, map[string]interface{}{"borrower_name": "Sam", "copy_id": "nope"}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, loansPath, map[string]interface{}{"borrower_name": "Sam", "copy_id": primitive.NewObjectID().Hex()}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, loansPath, map[string]interface{}{"borrower_name": "Sam", "copy_id": firstCopy}, http.StatusCreated)
+	tc.expect(http.MethodPost, loansPath, map[string]interface{}{"borrower_name": "Kim", "copy_id": firstCopy}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, loansPath, map[string]interface{}{"borrower_name": "Kim"}, http.StatusBadRequest)
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+blurayID+"/copies/"+firstCopy, nil, http.StatusBadRequest)
+
+	bluray = tc.expect(http.MethodGet, "/api/v1/blurays/"+blurayID, nil, http.StatusOK)["bluray"].(map[string]interface{})
+	copies = bluray["copies"].([]interface{})
+	if bluray["on_loan"] != false || copies[0].(map[string]interface{})["on_loan"] != true || copies[1].(map[string]interface{})["on_loan"] != false {
+		t.Errorf("with one of two copies lent, bluray on_loan = %v, copies = %v", bluray["on_loan"], copies)
+	}
+
+	tc.expect(http.MethodPost, loansPath, map[string]interface{}{"borrower_name": "Kim", "copy_id": secondCopy}, http.StatusCreated)
+	list = tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)["blurays"].([]interface{})
+	if list[0].(map[string]interface{})["on_loan"] != true {
+		t.Error("bluray with every copy lent is not on loan")
+	}
+}
+
+func TestLocations(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	create := func(body map[string]interface{}, wantCode int) string {
+		t.Helper()
+		response := tc.expect(http.MethodPost, "/api/v1/locations", body, wantCode)
+		if wantCode != http.StatusCreated {
+			return ""
+		}
+		return response["location"].(map[string]interface{})["id"].(string)
+	}
+
+	room := create(map[string]interface{}{"name": "Living Room", "kind": "room"}, http.StatusCreated)
+	unit := create(map[string]interface{}{"name": "Billy", "kind": "shelf_unit", "parent_id": room}, http.StatusCreated)
+	top := create(map[string]interface{}{"name": "Top", "kind": "shelf", "parent_id": unit, "capacity": 2}, http.StatusCreated)
+	create(map[string]interface{}{"name": "Bottom", "kind": "shelf", "parent_id": unit, "capacity": 6}, http.StatusCreated)
+
+	// The hierarchy is enforced
+	create(map[string]interface{}{"name": "Loose shelf", "kind": "shelf", "parent_id": room}, http.StatusBadRequest)
+	create(map[string]interface{}{"name": "Orphan", "kind": "slot"}, http.StatusBadRequest)
+	create(map[string]interface{}{"name": "top", "kind": "shelf", "parent_id": unit}, http.StatusBadRequest)
+
+	created := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":       "Heat",
+		"type":        "movie",
+		"location_id": top,
+	}, http.StatusCreated)
+	blurayID := created["bluray"].(map[string]interface{})["id"].(string)
+	tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":       "Nowhere",
+		"type":        "movie",
+		"location_id": primitive.NewObjectID().Hex(),
+	}, http.StatusBadRequest)
+
+	bluray := tc.expect(http.MethodGet, "/api/v1/blurays/"+blurayID, nil, http.StatusOK)["bluray"].(map[string]interface{})
+	if bluray["location_path"] != "Living Room > Billy > Top" {
+		t.Errorf("location_path = %v", bluray["location_path"])
+	}
+
+	search := tc.expect(http.MethodGet, "/api/v1/blurays/search?q=location:living-room", nil, http.StatusOK)
+	if n := len(search["blurays"].([]interface{})); n != 1 {
+		t.Errorf("SearchBlurays(location:living-room) returned %d blurays, want 1", n)
+	}
+
+	report := tc.expect(http.MethodGet, "/api/v1/locations/report", nil, http.StatusOK)["report"].([]interface{})
+	byPath := map[string]map[string]interface{}{}
+	for _, entry := range report {
+		entry := entry.(map[string]interface{})
+		byPath[entry["path"].(string)] = entry
+	}
+	if r := byPath["Living Room > Billy > Top"]; r["bluray_count"] != float64(1) || r["fill_rate"] != 0.5 {
+		t.Errorf("top shelf report = %v, want 1 disc and half full", r)
+	}
+	if r := byPath["Living Room"]; r["total_bluray_count"] != float64(1) || r["capacity"] != float64(8) {
+		t.Errorf("room report = %v, want 1 disc out of the shelves' 8", r)
+	}
+
+	// Only empty locations can be deleted
+	tc.expect(http.MethodDelete, "/api/v1/locations/"+unit, nil, http.StatusBadRequest)
+	tc.expect(http.MethodDelete, "/api/v1/locations/"+top, nil, http.StatusBadRequest)
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{
+		"title": "Heat",
+		"type":  "movie",
+	}, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/locations/"+top, nil, http.StatusOK)
+}
+
+func TestReleaseMetadata(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	created := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Dune",
+		"type":  "movie",
+		"copies": []map[string]interface{}{{
+			"format":          "UHD",
+			"edition":         "Collector, Limited",
+			"packaging":       "Box set",
+			"publisher":       "Warner",
+			"region_code":     "b",
+			"barcode":         "5051889-023586",
+			"disc_count":      3,
+			"audio_tracks":    []string{"English Dolby Atmos", " "},
+			"subtitle_tracks": []string{"Français"},
+		}},
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	copies := created["copies"].([]interface{})
+	if len(copies) != 1 {
+		t.Fatalf("created bluray has %d copies, want 1", len(copies))
+	}
+	if cp := copies[0].(map[string]interface{}); cp["format"] != "4k" || cp["packaging"] != "box_set" ||
+		cp["region_code"] != "B" || cp["barcode"] != "5051889023586" || len(cp["audio_tracks"].([]interface{})) != 1 {
+		t.Errorf("release fields were not normalized: %v", cp)
+	}
+
+	for _, invalid := range []map[string]interface{}{
+		{"format": "vhs"},
+		{"packaging": "shoebox"},
+		{"barcode": "5051889023585"},
+		{"disc_count": -1},
+	} {
+		tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+			"title":  "Invalid",
+			"type":   "movie",
+			"copies": []map[string]interface{}{invalid},
+		}, http.StatusBadRequest)
+	}
+
+	search := tc.expect(http.MethodGet, "/api/v1/blurays/search?q=format:4k", nil, http.StatusOK)
+	if n := len(search["blurays"].([]interface{})); n != 1 {
+		t.Errorf("SearchBlurays(format:4k) returned %d blurays, want 1", n)
+	}
+	list := tc.expect(http.MethodGet, "/api/v1/blurays?format=dvd", nil, http.StatusOK)
+	if list["blurays"] != nil {
+		t.Errorf("ListBlurays(format=dvd) returned %v, want none", list["blurays"])
+	}
+
+	// The release fields survive a CSV export and import
+	csv := tc.exportCSV()
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+created["id"].(string), nil, http.StatusOK)
+	if body := tc.importCSV(csv); !strings.Contains(body, `"success":1`) {
+		t.Fatalf("import: %s", body)
+	}
+
+	imported := tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)["blurays"].([]interface{})
+	if len(imported) != 1 {
+		t.Fatalf("got %d blurays after import, want 1", len(imported))
+	}
+	bluray := imported[0].(map[string]interface{})
+	cp := bluray["copies"].([]interface{})[0].(map[string]interface{})
+	if cp["format"] != "4k" || cp["edition"] != "Collector, Limited" || cp["packaging"] != "box_set" ||
+		cp["barcode"] != "5051889023586" || cp["disc_count"] != float64(3) ||
+		len(cp["subtitle_tracks"].([]interface{})) != 1 {
+		t.Errorf("imported bluray lost release fields: %v", cp)
+	}
+}
+
+func TestCopies(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	// A title created without copies gets one
+	created := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":   "Heat",
+		"type":    "movie",
+		"tmdb_id": "949",
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	blurayID := created["id"].(string)
+	if n := len(created["copies"].([]interface{})); n != 1 {
+		t.Fatalf("created bluray has %d copies, want 1", n)
+	}
+	firstCopy := created["copies"].([]interface{})[0].(map[string]interface{})["id"].(string)
+
+	// A second purchase of the same title is a copy, not another bluray
+	tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":   "Heat",
+		"type":    "movie",
+		"tmdb_id": "949",
+	}, http.StatusBadRequest)
+	bluray := tc.expect(http.MethodPost, "/api/v1/blurays/"+blurayID+"/copies", map[string]interface{}{
+		"format":         "4k",
+		"packaging":      "steelbook",
+		"purchase_price": 30,
+		"condition":      "mint",
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	copies := bluray["copies"].([]interface{})
+	if len(copies) != 2 {
+		t.Fatalf("bluray has %d copies after AddCopy, want 2", len(copies))
+	}
+
+	tc.expect(http.MethodPost, "/api/v1/blurays/"+blurayID+"/copies", map[string]interface{}{
+		"condition": "scratched",
+	}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/blurays/"+blurayID+"/copies", map[string]interface{}{
+		"purchase_price": -1,
+	}, http.StatusBadRequest)
+
+	bluray = tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID+"/copies/"+firstCopy, map[string]interface{}{
+		"format":         "bluray",
+		"purchase_price": 10,
+		"condition":      "good",
+		"notes":          "Cracked case",
+	}, http.StatusOK)["bluray"].(map[string]interface{})
+	if cp := bluray["copies"].([]interface{})[0].(map[string]interface{}); cp["id"] != firstCopy || cp["notes"] != "Cracked case" {
+		t.Errorf("UpdateCopy returned %v", cp)
+	}
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID+"/copies/"+primitive.NewObjectID().Hex(), map[string]interface{}{}, http.StatusNotFound)
+
+	// Updating the title keeps its copies when none are sent
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{
+		"title": "Heat",
+		"type":  "movie",
+	}, http.StatusOK)
+
+	stats := tc.expect(http.MethodGet, "/api/v1/statistics", nil, http.StatusOK)["statistics"].(map[string]interface{})
+	if stats["total_blurays"] != float64(2) || stats["total_copies"] != float64(2) || stats["total_spent"] != float64(40) {
+		t.Errorf("statistics = %v, want 2 discs and 2 copies worth 40", stats)
+	}
+
+	// Every copy is exported on its own row and imported back into one title
+	csv := tc.exportCSV()
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+blurayID, nil, http.StatusOK)
+	if body := tc.importCSV(csv); !strings.Contains(body, `"success":2`) {
+		t.Fatalf("import: %s", body)
+	}
+	if body := tc.importCSV(csv); !strings.Contains(body, `"skipped":2`) {
+		t.Fatalf("second import: %s", body)
+	}
+	imported := tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)["blurays"].([]interface{})
+	if len(imported) != 1 {
+		t.Fatalf("got %d blurays after import, want 1", len(imported))
+	}
+	bluray = imported[0].(map[string]interface{})
+	blurayID = bluray["id"].(string)
+	copies = bluray["copies"].([]interface{})
+	if len(copies) != 2 || copies[0].(map[string]interface{})["notes"] != "Cracked case" ||
+		copies[1].(map[string]interface{})["condition"] != "mint" {
+		t.Fatalf("imported copies = %v", copies)
+	}
+
+	// The last copy cannot be removed
+	secondCopy := copies[1].(map[string]interface{})["id"].(string)
+	firstCopy = copies[0].(map[string]interface{})["id"].(string)
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+blurayID+"/copies/"+secondCopy, nil, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+blurayID+"/copies/"+firstCopy, nil, http.StatusBadRequest)
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{
+		"title":  "Heat",
+		"type":   "movie",
+		"copies": []interface{}{},
+	}, http.StatusBadRequest)
+
+	// Contributors add copies through updates but cannot drop any
+	tc.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "dana",
+		"email":    "dana@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	dana := &testClient{t: t, server: tc.server}
+	dana.login("dana", "secret123")
+	danaID := dana.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)["user"].(map[string]interface{})["id"].(string)
+	tc.expect(http.MethodPut, "/api/v1/admin/users/"+danaID+"/role", map[string]string{"role": "contributor"}, http.StatusOK)
+	dana.login("dana", "secret123")
+	bluray = dana.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{
+		"title":  "Heat",
+		"type":   "movie",
+		"copies": []interface{}{map[string]interface{}{"id": firstCopy}, map[string]interface{}{"format": "dvd"}},
+	}, http.StatusOK)["bluray"].(map[string]interface{})
+	if n := len(bluray["copies"].([]interface{})); n != 2 {
+		t.Fatalf("bluray has %d copies after adding one, want 2", n)
+	}
+	keep := []interface{}{map[string]interface{}{"id": firstCopy}}
+	dana.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{"title": "Heat", "type": "movie", "copies": keep}, http.StatusForbidden)
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{"title": "Heat", "type": "movie", "copies": keep}, http.StatusOK)
+}
+
+func TestWishlist(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	// Items are made from the TMDB search results
+	item := tc.expect(http.MethodPost, "/api/v1/wishlist", map[string]interface{}{
+		"tmdb_id":         "949",
+		"title":           "Heat",
+		"release_year":    1995,
+		"desired_format":  "UHD",
+		"desired_edition": "Steelbook",
+		"max_price":       25,
+	}, http.StatusCreated)["item"].(map[string]interface{})
+	itemID := item["id"].(string)
+	if item["type"] != "movie" || item["priority"] != float64(3) || item["desired_format"] != "4k" {
+		t.Errorf("created item = %v, want a movie of normal priority wanted in 4k", item)
+	}
+
+	for _, invalid := range []map[string]interface{}{
+		{"title": " "},
+		{"title": "Ronin", "type": "book"},
+		{"title": "Ronin", "priority": 6},
+		{"title": "Ronin", "max_price": -1},
+	} {
+		tc.expect(http.MethodPost, "/api/v1/wishlist", invalid, http.StatusBadRequest)
+	}
+
+	item = tc.expect(http.MethodPut, "/api/v1/wishlist/"+itemID, map[string]interface{}{
+		"priority": 5,
+		"notes":    "Any shop",
+	}, http.StatusOK)["item"].(map[string]interface{})
+	if item["priority"] != float64(5) || item["notes"] != "Any shop" || item["max_price"] != float64(25) {
+		t.Errorf("updated item = %v", item)
+	}
+	tc.expect(http.MethodPut, "/api/v1/wishlist/"+itemID, map[string]interface{}{"priority": 0}, http.StatusBadRequest)
+
+	// Acquiring the item adds it to the collection and keeps it as history
+	acquired := tc.expect(http.MethodPost, "/api/v1/wishlist/"+itemID+"/acquire", map[string]interface{}{
+		"purchase_price": 22.5,
+		"purchase_date":  "2024-05-04T00:00:00Z",
+	}, http.StatusOK)
+	bluray := acquired["bluray"].(map[string]interface{})
+	cp := bluray["copies"].([]interface{})[0].(map[string]interface{})
+	if bluray["title"] != "Heat" || bluray["tmdb_id"] != "949" || cp["purchase_price"] != 22.5 ||
+		cp["format"] != "4k" || cp["edition"] != "Steelbook" || !strings.HasPrefix(cp["purchase_date"].(string), "2024-05-04") {
+		t.Errorf("acquired bluray = %v", bluray)
+	}
+	if item := acquired["item"].(map[string]interface{}); item["bluray_id"] != bluray["id"] || item["acquired_at"] == nil {
+		t.Errorf("acquired item = %v, want it linked to the bluray", item)
+	}
+	tc.expect(http.MethodPost, "/api/v1/wishlist/"+itemID+"/acquire", nil, http.StatusBadRequest)
+
+	wanted := tc.expect(http.MethodGet, "/api/v1/wishlist", nil, http.StatusOK)["items"].([]interface{})
+	history := tc.expect(http.MethodGet, "/api/v1/wishlist?acquired=true", nil, http.StatusOK)["items"].([]interface{})
+	if len(wanted) != 0 || len(history) != 1 {
+		t.Errorf("got %d wanted and %d acquired items, want 0 and 1", len(wanted), len(history))
+	}
+
+	// Buying another edition of a title we own adds a copy to it
+	second := tc.expect(http.MethodPost, "/api/v1/wishlist", map[string]interface{}{
+		"tmdb_id": "949",
+		"title":   "Heat",
+	}, http.StatusCreated)["item"].(map[string]interface{})
+	bluray = tc.expect(http.MethodPost, "/api/v1/wishlist/"+second["id"].(string)+"/acquire", nil, http.StatusOK)["bluray"].(map[string]interface{})
+	if n := len(bluray["copies"].([]interface{})); n != 2 {
+		t.Errorf("bluray has %d copies after acquiring it again, want 2", n)
+	}
+	if list := tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)["blurays"].([]interface{}); len(list) != 1 {
+		t.Errorf("got %d blurays, want 1", len(list))
+	}
+
+	tc.expect(http.MethodDelete, "/api/v1/wishlist/"+itemID, nil, http.StatusOK)
+	tc.expect(http.MethodGet, "/api/v1/wishlist/"+itemID, nil, http.StatusNotFound)
+}
+
+func TestTMDBSeasonEpisodes(t *testing.T) {
+	// The series has specials and 25 seasons, the first with two episodes
+	seasonList := []string{`{"season_number": 0, "episode_count": 5}`}
+	for number := 1; number <= 25; number++ {
+		seasonList = append(seasonList, fmt.Sprintf(`{"season_number": %d, "episode_count": 2}`, number))
+	}
+	var appended []string
+	tmdb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/tv/1399" {
+			http.NotFound(w, r)
+			return
+		}
+		response := `{"id": 1399, "name": "Game of Thrones", "seasons": [` + strings.Join(seasonList, ",") + `]`
+		if seasons := r.URL.Query().Get("append_to_response"); seasons != "" {
+			appended = append(appended, seasons)
+			for _, season := range strings.Split(seasons, ",") {
+				episodes := `[]`
+				if season == "season/1" {
+					episodes = `[
+						{"episode_number": 1, "name": "Winter Is Coming", "air_date": "2011-04-17", "runtime": 62, "overview": "Lord Stark is troubled."},
+						{"episode_number": 2, "name": "The Kingsroad", "air_date": "2011-04-24", "runtime": 56}
+					]`
+				}
+				response += fmt.Sprintf(`, %q: {"episodes": %s}`, season, episodes)
+			}
+		}
+		w.Write([]byte(response + "}"))
+	}))
+	defer tmdb.Close()
+	t.Setenv("TMDB_API_URL", tmdb.URL)
+
+	tc, _ := newTestClient(t)
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	details := tc.expect(http.MethodGet, "/api/v1/tmdb/series/1399", nil, http.StatusOK)
+	seasons := details["seasons"].([]interface{})
+	if _, ok := seasons[0].(map[string]interface{})["episodes"]; ok {
+		t.Error("the specials season was fetched")
+	}
+	if len(appended) != 2 || strings.Count(appended[0], ",") != 19 || !strings.HasPrefix(appended[0], "season/1,") || appended[1] != "season/21,season/22,season/23,season/24,season/25" {
+		t.Errorf("appended seasons = %q, want seasons 1 to 25 in batches of 20", appended)
+	}
+	if _, ok := seasons[25].(map[string]interface{})["episodes"]; !ok {
+		t.Error("the episodes of season 25 were not attached")
+	}
+	episodes := seasons[1].(map[string]interface{})["episodes"].([]interface{})
+	if len(episodes) != 2 {
+		t.Fatalf("season 1 has %d episodes, want 2", len(episodes))
+	}
+	first := episodes[0].(map[string]interface{})
+	if first["number"] != float64(1) || first["title"] != "Winter Is Coming" || first["runtime"] != float64(62) ||
+		!strings.HasPrefix(first["air_date"].(string), "2011-04-17") || first["overview"] != "Lord Stark is troubled." {
+		t.Errorf("first episode = %v", first)
+	}
+
+	// The episodes are stored as returned, the second one on disc 2
+	episodes[1].(map[string]interface{})["disc_number"] = 2
+	bluray := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":   "Game of Thrones",
+		"type":    "series",
+		"seasons": []interface{}{map[string]interface{}{"number": 1, "episodes": episodes}},
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	season := bluray["seasons"].([]interface{})[0].(map[string]interface{})
+	if season["episode_count"] != float64(2) || bluray["total_episodes"] != float64(2) {
+		t.Errorf("created bluray = %v, want the episode counts derived from the episodes", bluray)
+	}
+
+	stats := tc.expect(http.MethodGet, "/api/v1/statistics", nil, http.StatusOK)["statistics"].(map[string]interface{})
+	if stats["total_episodes"] != float64(2) || stats["total_runtime_minutes"] != float64(118) {
+		t.Errorf("statistics = %v, want 2 episodes and 118 minutes", stats)
+	}
+}
+
+func TestWatchLog(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	heat := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":   "Heat",
+		"type":    "movie",
+		"runtime": 170,
+	}, http.StatusCreated)["bluray"].(map[string]interface{})["id"].(string)
+	dark := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Dark",
+		"type":  "series",
+		"seasons": []map[string]interface{}{{
+			"number": 1,
+			"episodes": []map[string]interface{}{
+				{"number": 1, "title": "Secrets", "runtime": 51},
+				{"number": 2, "title": "Lies", "runtime": 44},
+			},
+		}},
+	}, http.StatusCreated)["bluray"].(map[string]interface{})["id"].(string)
+	tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Ronin",
+		"type":  "movie",
+	}, http.StatusCreated)
+
+	// The runtime comes from the movie, season or episode that was watched
+	event := tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{
+		"bluray_id":  heat,
+		"watched_at": "2024-01-12T21:00:00Z",
+		"rating":     9,
+		"notes":      "  still great ",
+	}, http.StatusCreated)["event"].(map[string]interface{})
+	if event["runtime_minutes"] != float64(170) || event["notes"] != "still great" || event["bluray_title"] != "Heat" {
+		t.Errorf("logged event = %v", event)
+	}
+	episode := tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{
+		"bluray_id":      dark,
+		"season_number":  1,
+		"episode_number": 2,
+		"watched_at":     "2024-01-20T21:00:00Z",
+	}, http.StatusCreated)["event"].(map[string]interface{})
+	if episode["runtime_minutes"] != float64(44) {
+		t.Errorf("episode runtime = %v, want 44", episode["runtime_minutes"])
+	}
+	season := tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{
+		"bluray_id":     dark,
+		"season_number": 1,
+		"watched_at":    "2024-02-03T21:00:00Z",
+	}, http.StatusCreated)["event"].(map[string]interface{})
+	if season["runtime_minutes"] != float64(95) {
+		t.Errorf("season runtime = %v, want 95", season["runtime_minutes"])
+	}
+
+	tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{"bluray_id": primitive.NewObjectID().Hex()}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{"bluray_id": dark, "season_number": 2}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{"bluray_id": dark, "season_number": 1, "episode_number": 3}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{"bluray_id": heat, "rating": 11}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{"bluray_id": heat, "watched_at": "2999-01-01T00:00:00Z"}, http.StatusBadRequest)
+
+	history := tc.expect(http.MethodGet, "/api/v1/watch", nil, http.StatusOK)["events"].([]interface{})
+	if len(history) != 3 || history[0].(map[string]interface{})["id"] != season["id"] {
+		t.Fatalf("history = %v, want the 3 events, latest first", history)
+	}
+	history = tc.expect(http.MethodGet, "/api/v1/watch?bluray_id="+heat, nil, http.StatusOK)["events"].([]interface{})
+	if len(history) != 1 || history[0].(map[string]interface{})["bluray_title"] != "Heat" {
+		t.Errorf("history of Heat = %v", history)
+	}
+	if history = tc.expect(http.MethodGet, "/api/v1/watch?skip=5", nil, http.StatusOK)["events"].([]interface{}); len(history) != 0 {
+		t.Errorf("history past the end = %v, want none", history)
+	}
+	tc.expect(http.MethodGet, "/api/v1/watch?skip=-1", nil, http.StatusBadRequest)
+
+	updated := tc.expect(http.MethodPut, "/api/v1/watch/"+episode["id"].(string), map[string]interface{}{
+		"rating": 8,
+	}, http.StatusOK)["event"].(map[string]interface{})
+	if updated["rating"] != float64(8) || updated["runtime_minutes"] != float64(44) {
+		t.Errorf("updated event = %v", updated)
+	}
+
+	watched := tc.expect(http.MethodGet, "/api/v1/blurays?watched=true", nil, http.StatusOK)["blurays"].([]interface{})
+	if len(watched) != 2 {
+		t.Errorf("watched blurays = %d, want 2", len(watched))
+	}
+	unwatched := tc.expect(http.MethodGet, "/api/v1/blurays/simplified?watched=false", nil, http.StatusOK)["blurays"].([]interface{})
+	if len(unwatched) != 1 || unwatched[0].(map[string]interface{})["title"] != "Ronin" {
+		t.Errorf("unwatched blurays = %v, want Ronin", unwatched)
+	}
+
+	stats := tc.expect(http.MethodGet, "/api/v1/statistics/watch", nil, http.StatusOK)["statistics"].(map[string]interface{})
+	if stats["total_events"] != float64(3) || stats["total_minutes"] != float64(309) ||
+		stats["watched_blurays"] != float64(2) || stats["never_watched_count"] != float64(1) {
+		t.Errorf("watch statistics = %v", stats)
+	}
+	months := stats["hours_watched_by_month"].([]interface{})
+	if len(months) != 2 {
+		t.Fatalf("hours by month = %v, want January and February", months)
+	}
+	if january := months[0].(map[string]interface{}); january["month"] != "2024-01" || january["minutes"] != float64(214) {
+		t.Errorf("January = %v, want 214 minutes", january)
+	}
+
+	// Every account has its own history
+	tc.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "bob",
+		"email":    "bob@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	bob := &testClient{t: t, server: tc.server}
+	bob.login("bob", "secret123")
+
+	if history := bob.expect(http.MethodGet, "/api/v1/watch", nil, http.StatusOK)["events"].([]interface{}); len(history) != 0 {
+		t.Errorf("history of another user = %v, want none", history)
+	}
+	bob.expect(http.MethodPut, "/api/v1/watch/"+episode["id"].(string), map[string]interface{}{"rating": 1}, http.StatusNotFound)
+	bob.expect(http.MethodDelete, "/api/v1/watch/"+episode["id"].(string), nil, http.StatusNotFound)
+	if unwatched := bob.expect(http.MethodGet, "/api/v1/blurays?watched=false", nil, http.StatusOK)["blurays"].([]interface{}); len(unwatched) != 3 {
+		t.Errorf("unwatched blurays of another user = %d, want 3", len(unwatched))
+	}
+	bob.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{"bluray_id": heat}, http.StatusCreated)
+	if stats := bob.expect(http.MethodGet, "/api/v1/statistics/watch", nil, http.StatusOK)["statistics"].(map[string]interface{}); stats["total_events"] != float64(1) {
+		t.Errorf("watch statistics of another user = %v", stats)
+	}
+
+	tc.expect(http.MethodDelete, "/api/v1/watch/"+event["id"].(string), nil, http.StatusOK)
+	tc.expect(http.MethodPut, "/api/v1/watch/"+event["id"].(string), map[string]interface{}{"rating": 1}, http.StatusNotFound)
+
+	// Purging a bluray from the trash removes it from every history
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+heat, nil, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/trash/blurays/"+heat, nil, http.StatusOK)
+	if history := bob.expect(http.MethodGet, "/api/v1/watch", nil, http.StatusOK)["events"].([]interface{}); len(history) != 0 {
+		t.Errorf("history after deleting the bluray = %v, want none", history)
+	}
+}
+
+func TestPersonalRatings(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	// A rating given on creation is the personal rating of whoever adds the bluray
+	heat := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":  "Heat",
+		"type":   "movie",
+		"rating": 8,
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	if heat["rating"] != float64(8) || heat["rating_count"] != float64(1) {
+		t.Errorf("created bluray rating = %v (%v), want 8 from 1 rating", heat["rating"], heat["rating_count"])
+	}
+	heatPath := "/api/v1/blurays/" + heat["id"].(string)
+	ronin := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Ronin",
+		"type":  "movie",
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	roninPath := "/api/v1/blurays/" + ronin["id"].(string)
+
+	rating := tc.expect(http.MethodPut, heatPath+"/rating", map[string]interface{}{
+		"score":  9,
+		"review": " Better every time ",
+	}, http.StatusOK)["rating"].(map[string]interface{})
+	if rating["score"] != float64(9) || rating["review"] != "Better every time" {
+		t.Errorf("rating = %v", rating)
+	}
+	tc.expect(http.MethodPut, heatPath+"/rating", map[string]interface{}{"score": 11}, http.StatusBadRequest)
+	tc.expect(http.MethodPut, heatPath+"/rating", map[string]interface{}{"score": 0}, http.StatusBadRequest)
+	tc.expect(http.MethodPut, heatPath+"/rating", map[string]interface{}{"score": 5, "review": strings.Repeat("a", 501)}, http.StatusBadRequest)
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+primitive.NewObjectID().Hex()+"/rating", map[string]interface{}{"score": 5}, http.StatusNotFound)
+	tc.expect(http.MethodGet, roninPath+"/rating", nil, http.StatusNotFound)
+
+	// Every account rates for itself
+	tc.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "bob",
+		"email":    "bob@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	bob := &testClient{t: t, server: tc.server}
+	bob.login("bob", "secret123")
+
+	bob.expect(http.MethodGet, heatPath+"/rating", nil, http.StatusNotFound)
+	bob.expect(http.MethodPut, heatPath+"/rating", map[string]interface{}{"score": 6}, http.StatusOK)
+	bob.expect(http.MethodPut, roninPath+"/rating", map[string]interface{}{"score": 10}, http.StatusOK)
+
+	mine := tc.expect(http.MethodGet, heatPath+"/rating", nil, http.StatusOK)["rating"].(map[string]interface{})
+	if mine["score"] != float64(9) {
+		t.Errorf("own rating = %v, want 9", mine["score"])
+	}
+
+	// Blurays carry the household average
+	got := tc.expect(http.MethodGet, heatPath, nil, http.StatusOK)["bluray"].(map[string]interface{})
+	if got["rating"] != 7.5 || got["rating_count"] != float64(2) {
+		t.Errorf("household rating = %v (%v), want 7.5 from 2 ratings", got["rating"], got["rating_count"])
+	}
+	simplified := tc.expect(http.MethodGet, "/api/v1/blurays/simplified", nil, http.StatusOK)["blurays"].([]interface{})
+	if len(simplified) != 2 || simplified[0].(map[string]interface{})["rating"] != float64(10) {
+		t.Errorf("simplified blurays = %v, want Ronin rated 10 first", simplified)
+	}
+
+	// The shared rating can no longer be overwritten by editing the bluray
+	updated := tc.expect(http.MethodPut, heatPath, map[string]interface{}{
+		"title":  "Heat",
+		"type":   "movie",
+		"rating": 1,
+	}, http.StatusOK)["bluray"].(map[string]interface{})
+	if updated["rating"] != 7.5 {
+		t.Errorf("rating after editing the bluray = %v, want 7.5", updated["rating"])
+	}
+
+	household := tc.expect(http.MethodGet, "/api/v1/statistics", nil, http.StatusOK)["statistics"].(map[string]interface{})
+	if household["rating_count"] != float64(3) || math.Abs(household["average_rating"].(float64)-25.0/3) > 1e-9 {
+		t.Errorf("household rating statistics = %v (%v)", household["average_rating"], household["rating_count"])
+	}
+	topRated := household["top_rated"].([]interface{})
+	if len(topRated) != 2 || topRated[0].(map[string]interface{})["title"] != "Ronin" ||
+		topRated[1].(map[string]interface{})["rating"] != 7.5 {
+		t.Errorf("household top rated = %v, want Ronin then Heat at 7.5", topRated)
+	}
+
+	personal := tc.expect(http.MethodGet, "/api/v1/statistics?user_id=me", nil, http.StatusOK)["statistics"].(map[string]interface{})
+	if personal["average_rating"] != float64(9) || len(personal["top_rated"].([]interface{})) != 1 {
+		t.Errorf("personal rating statistics = %v, %v", personal["average_rating"], personal["top_rated"])
+	}
+	tc.expect(http.MethodGet, "/api/v1/statistics?user_id=nobody", nil, http.StatusBadRequest)
+
+	bob.expect(http.MethodDelete, roninPath+"/rating", nil, http.StatusOK)
+	bob.expect(http.MethodGet, roninPath+"/rating", nil, http.StatusNotFound)
+	if personal := bob.expect(http.MethodGet, "/api/v1/statistics?user_id=me", nil, http.StatusOK)["statistics"].(map[string]interface{}); personal["average_rating"] != float64(6) {
+		t.Errorf("personal average after deleting a rating = %v, want 6", personal["average_rating"])
+	}
+}
+
+func TestCollections(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	// Existing data lives in the default collection
+	heat := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Heat",
+		"type":  "movie",
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	heatPath := "/api/v1/blurays/" + heat["id"].(string)
+	tc.expect(http.MethodPost, "/api/v1/tags", map[string]string{"name": "Noir"}, http.StatusCreated)
+	room := tc.expect(http.MethodPost, "/api/v1/locations", map[string]interface{}{
+		"name": "Living room",
+		"kind": "room",
+	}, http.StatusCreated)["location"].(map[string]interface{})
+	roomPath := "/api/v1/locations/" + room["id"].(string)
+	wanted := tc.expect(http.MethodPost, "/api/v1/wishlist", map[string]interface{}{
+		"title": "Collateral",
+		"type":  "movie",
+	}, http.StatusCreated)["item"].(map[string]interface{})
+	wantedPath := "/api/v1/wishlist/" + wanted["id"].(string)
+	tc.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{"bluray_id": heat["id"]}, http.StatusCreated)
+	tc.expect(http.MethodPost, heatPath+"/loans", map[string]interface{}{"borrower_name": "Kim"}, http.StatusCreated)
+
+	clients := make(map[string]*testClient)
+	userIDs := make(map[string]string)
+	for _, name := range []string{"bob", "carol"} {
+		tc.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+			"username": name,
+			"email":    name + "@example.com",
+			"password": "secret123",
+		}, http.StatusCreated)
+		clients[name] = &testClient{t: t, server: tc.server}
+		clients[name].login(name, "secret123")
+		me := clients[name].expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)["user"].(map[string]interface{})
+		userIDs[name] = me["id"].(string)
+	}
+	bob, carol := clients["bob"], clients["carol"]
+
+	// Only admins manage collections
+	bob.expect(http.MethodPost, "/api/v1/admin/collections", map[string]string{"name": "Cabin"}, http.StatusForbidden)
+	tc.expect(http.MethodPost, "/api/v1/admin/collections", map[string]string{"name": "  "}, http.StatusBadRequest)
+	cabin := tc.expect(http.MethodPost, "/api/v1/admin/collections", map[string]string{
+		"name":        " Cabin ",
+		"description": "The other household",
+	}, http.StatusCreated)["collection"].(map[string]interface{})
+	if cabin["name"] != "Cabin" || cabin["is_default"] != false {
+		t.Errorf("created collection = %v", cabin)
+	}
+	cabinID := cabin["id"].(string)
+	cabinPath := "/api/v1/admin/collections/" + cabinID
+
+	tc.expect(http.MethodPost, cabinPath+"/members", map[string]string{"user_id": userIDs["bob"], "role": "owner"}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, cabinPath+"/members", map[string]string{"user_id": primitive.NewObjectID().Hex(), "role": "user"}, http.StatusBadRequest)
+	cabin = tc.expect(http.MethodPost, cabinPath+"/members", map[string]string{
+		"user_id": userIDs["bob"],
+		"role":    "moderator",
+	}, http.StatusCreated)["collection"].(map[string]interface{})
+	members := cabin["members"].([]interface{})
+	if len(members) != 1 || members[0].(map[string]interface{})["username"] != "bob" {
+		t.Errorf("members = %v, want bob", members)
+	}
+
+	// Invited members are notified and work in their collection by default
+	notifications := bob.expect(http.MethodGet, "/api/v1/notifications", nil, http.StatusOK)["notifications"].([]interface{})
+	if len(notifications) != 1 || notifications[0].(map[string]interface{})["type"] != "collection_invite" {
+		t.Errorf("bob's notifications = %v, want the invite", notifications)
+	}
+	collections := bob.expect(http.MethodGet, "/api/v1/collections", nil, http.StatusOK)["collections"].([]interface{})
+	if len(collections) != 1 || collections[0].(map[string]interface{})["id"] != cabinID {
+		t.Errorf("bob's collections = %v, want Cabin only", collections)
+	}
+	current := bob.expect(http.MethodGet, "/api/v1/collections/current", nil, http.StatusOK)
+	if current["collection"].(map[string]interface{})["id"] != cabinID || current["role"] != "moderator" {
+		t.Errorf("bob's current collection = %v, want Cabin as moderator", current)
+	}
+	collections = tc.expect(http.MethodGet, "/api/v1/collections", nil, http.StatusOK)["collections"].([]interface{})
+	if len(collections) != 2 || collections[0].(map[string]interface{})["is_default"] != true {
+		t.Errorf("admin collections = %v, want the default collection and Cabin", collections)
+	}
+
+	// Each household sees only its own discs and tags
+	if blurays, _ := bob.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)["blurays"].([]interface{}); len(blurays) != 0 {
+		t.Errorf("bob sees %d blurays, want none", len(blurays))
+	}
+	bob.expect(http.MethodGet, heatPath, nil, http.StatusNotFound)
+	bob.expect(http.MethodPut, heatPath, map[string]interface{}{"title": "Mine now", "type": "movie"}, http.StatusNotFound)
+	ronin := bob.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Ronin",
+		"type":  "movie",
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	if ronin["collection_id"] != cabinID {
+		t.Errorf("bob's bluray collection = %v, want %s", ronin["collection_id"], cabinID)
+	}
+	roninPath := "/api/v1/blurays/" + ronin["id"].(string)
+	bob.expect(http.MethodPost, "/api/v1/tags", map[string]string{"name": "Noir"}, http.StatusCreated)
+	if tags := bob.expect(http.MethodGet, "/api/v1/tags", nil, http.StatusOK)["tags"].([]interface{}); len(tags) != 1 {
+		t.Errorf("bob sees %d tags, want 1", len(tags))
+	}
+	stats := bob.expect(http.MethodGet, "/api/v1/statistics", nil, http.StatusOK)["statistics"].(map[string]interface{})
+	if stats["total_blurays"] != float64(1) {
+		t.Errorf("bob's statistics count %v blurays, want 1", stats["total_blurays"])
+	}
+
+	// So do locations, the wishlist and the watch history
+	if locations := bob.expect(http.MethodGet, "/api/v1/locations", nil, http.StatusOK)["locations"].([]interface{}); len(locations) != 0 {
+		t.Errorf("bob sees %d locations, want none", len(locations))
+	}
+	bob.expect(http.MethodGet, roomPath, nil, http.StatusNotFound)
+	bob.expect(http.MethodDelete, roomPath, nil, http.StatusBadRequest)
+	bob.expect(http.MethodPost, "/api/v1/locations", map[string]interface{}{"name": "Living room", "kind": "room"}, http.StatusCreated)
+	bob.expect(http.MethodPut, roninPath, map[string]interface{}{
+		"title":       "Ronin",
+		"type":        "movie",
+		"location_id": room["id"],
+	}, http.StatusBadRequest)
+	if items := bob.expect(http.MethodGet, "/api/v1/wishlist", nil, http.StatusOK)["items"].([]interface{}); len(items) != 0 {
+		t.Errorf("bob sees %d wishlist items, want none", len(items))
+	}
+	bob.expect(http.MethodGet, wantedPath, nil, http.StatusNotFound)
+	if loans := bob.expect(http.MethodGet, "/api/v1/loans", nil, http.StatusOK)["loans"].([]interface{}); len(loans) != 0 {
+		t.Errorf("bob sees %d loans, want none", len(loans))
+	}
+	bob.expect(http.MethodDelete, wantedPath, nil, http.StatusNotFound)
+	bob.expect(http.MethodPost, "/api/v1/watch", map[string]interface{}{"bluray_id": ronin["id"]}, http.StatusCreated)
+	tc.expect(http.MethodPost, "/api/v1/watch?collection_id="+cabinID, map[string]interface{}{"bluray_id": ronin["id"]}, http.StatusCreated)
+	if events := tc.expect(http.MethodGet, "/api/v1/watch", nil, http.StatusOK)["events"].([]interface{}); len(events) != 1 {
+		t.Errorf("admin history of the default collection has %d events, want 1", len(events))
+	}
+
+	blurays := tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)["blurays"].([]interface{})
+	if len(blurays) != 1 || blurays[0].(map[string]interface{})["title"] != "Heat" {
+		t.Errorf("admin default collection = %v, want Heat only", blurays)
+	}
+	blurays = tc.expect(http.MethodGet, "/api/v1/blurays?collection_id="+cabinID, nil, http.StatusOK)["blurays"].([]interface{})
+	if len(blurays) != 1 || blurays[0].(map[string]interface{})["title"] != "Ronin" {
+		t.Errorf("admin view of Cabin = %v, want Ronin only", blurays)
+	}
+
+	// Users outside the collection cannot pick it
+	carol.expect(http.MethodGet, "/api/v1/blurays?collection_id="+cabinID, nil, http.StatusNotFound)
+	carol.expect(http.MethodGet, "/api/v1/blurays?collection_id=nope", nil, http.StatusNotFound)
+	carol.expect(http.MethodGet, roninPath, nil, http.StatusNotFound)
+	carol.expect(http.MethodGet, heatPath, nil, http.StatusOK)
+
+	// The default collection and collections with discs are kept
+	collections = tc.expect(http.MethodGet, "/api/v1/collections", nil, http.StatusOK)["collections"].([]interface{})
+	tc.expect(http.MethodDelete, "/api/v1/admin/collections/"+collections[0].(map[string]interface{})["id"].(string), nil, http.StatusBadRequest)
+	tc.expect(http.MethodDelete, cabinPath, nil, http.StatusBadRequest)
+
+	// Members can be demoted and removed
+	tc.expect(http.MethodPut, cabinPath+"/members/"+userIDs["carol"], map[string]string{"role": "user"}, http.StatusNotFound)
+	tc.expect(http.MethodPut, cabinPath+"/members/"+userIDs["bob"], map[string]string{"role": "guest"}, http.StatusOK)
+	bob.expect(http.MethodDelete, roninPath, nil, http.StatusForbidden)
+	tc.expect(http.MethodDelete, cabinPath+"/members/"+userIDs["bob"], nil, http.StatusOK)
+	tc.expect(http.MethodDelete, cabinPath+"/members/"+userIDs["bob"], nil, http.StatusNotFound)
+	bob.expect(http.MethodGet, heatPath, nil, http.StatusOK)
+
+	// Collection managers only invite members with roles they could grant
+	tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{
+		"name":        "steward",
+		"permissions": []string{"collection.manage"},
+	}, http.StatusCreated)
+	tc.expect(http.MethodPut, "/api/v1/admin/users/"+userIDs["carol"]+"/role", map[string]string{"role": "steward"}, http.StatusOK)
+	carol.login("carol", "secret123")
+	carol.expect(http.MethodPost, cabinPath+"/members", map[string]string{"user_id": userIDs["bob"], "role": "admin"}, http.StatusForbidden)
+	carol.expect(http.MethodPost, cabinPath+"/members", map[string]string{"user_id": userIDs["bob"], "role": "user"}, http.StatusForbidden)
+	carol.expect(http.MethodPost, cabinPath+"/members", map[string]string{"user_id": userIDs["bob"], "role": "guest"}, http.StatusCreated)
+	carol.expect(http.MethodPut, cabinPath+"/members/"+userIDs["bob"], map[string]string{"role": "moderator"}, http.StatusForbidden)
+	tc.expect(http.MethodDelete, cabinPath+"/members/"+userIDs["bob"], nil, http.StatusOK)
+
+	// Collections with discs lent out are kept, even from the trash
+	inCabin := "?collection_id=" + cabinID
+	loan := tc.expect(http.MethodPost, roninPath+"/loans"+inCabin, map[string]interface{}{"borrower_name": "Kim"}, http.StatusCreated)["loan"].(map[string]interface{})
+	tc.expect(http.MethodDelete, roninPath+inCabin, nil, http.StatusOK)
+	tc.expect(http.MethodDelete, cabinPath, nil, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/trash/blurays/"+ronin["id"].(string)+"/restore"+inCabin, nil, http.StatusOK)
+	tc.expect(http.MethodPost, roninPath+"/loans/"+loan["id"].(string)+"/return"+inCabin, nil, http.StatusOK)
+
+	tc.expect(http.MethodDelete, roninPath+inCabin, nil, http.StatusOK)
+	tc.expect(http.MethodPut, cabinPath, map[string]string{"name": "Old cabin"}, http.StatusOK)
+	tc.expect(http.MethodDelete, cabinPath, nil, http.StatusOK)
+	tc.expect(http.MethodGet, "/api/v1/blurays?collection_id="+cabinID, nil, http.StatusNotFound)
+}
+
+func TestRolesAndPermissions(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	listed := tc.expect(http.MethodGet, "/api/v1/admin/roles", nil, http.StatusOK)
+	if roles := listed["roles"].([]interface{}); len(roles) != 5 {
+		t.Errorf("roles = %v, want the 5 built-in roles", roles)
+	}
+	if permissions := listed["permissions"].([]interface{}); len(permissions) != 17 {
+		t.Errorf("permissions = %v, want 17", permissions)
+	}
+
+	carl := tc.expect(http.MethodPost, "/api/v1/admin/users", map[string]string{
+		"username": "carl",
+		"email":    "carl@example.com",
+		"password": "secret123",
+		"role":     "contributor",
+	}, http.StatusCreated)["user"].(map[string]interface{})
+	carlRolePath := "/api/v1/admin/users/" + carl["id"].(string) + "/role"
+	contributor := &testClient{t: t, server: tc.server}
+	contributor.login("carl", "secret123")
+	contributor.expect(http.MethodGet, "/api/v1/admin/roles", nil, http.StatusForbidden)
+	tc.expect(http.MethodPut, carlRolePath, map[string]string{"role": "wizard"}, http.StatusBadRequest)
+
+	// Contributors add and edit discs but cannot delete them
+	heat := contributor.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Heat",
+		"type":  "movie",
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	heatPath := "/api/v1/blurays/" + heat["id"].(string)
+	contributor.expect(http.MethodPut, heatPath, map[string]interface{}{"title": "Heat (1995)", "type": "movie"}, http.StatusOK)
+	contributor.expect(http.MethodDelete, heatPath, nil, http.StatusForbidden)
+	contributor.expect(http.MethodPost, "/api/v1/locations", map[string]interface{}{"name": "Den", "kind": "room"}, http.StatusForbidden)
+	current := contributor.expect(http.MethodGet, "/api/v1/collections/current", nil, http.StatusOK)
+	granted := map[string]bool{}
+	for _, p := range current["permissions"].([]interface{}) {
+		granted[p.(string)] = true
+	}
+	if current["role"] != "contributor" || !granted["bluray.create"] || granted["bluray.delete"] {
+		t.Errorf("contributor permissions = %v", current)
+	}
+
+	// Custom roles
+	tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{"name": "x"}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{"name": "user"}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{"name": "archivist", "permissions": []string{"shelf.dust"}}, http.StatusBadRequest)
+	archivist := tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{
+		"name":        " Archivist ",
+		"description": "Keeps the shelves tidy",
+		"permissions": []string{"location.manage", "location.manage"},
+	}, http.StatusCreated)["role"].(map[string]interface{})
+	if archivist["name"] != "archivist" || len(archivist["permissions"].([]interface{})) != 1 || archivist["built_in"] != false {
+		t.Errorf("created role = %v", archivist)
+	}
+	tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{"name": "archivist"}, http.StatusBadRequest)
+
+	tc.expect(http.MethodPut, carlRolePath, map[string]string{"role": "archivist"}, http.StatusOK)
+	contributor.login("carl", "secret123")
+	contributor.expect(http.MethodPost, "/api/v1/locations", map[string]interface{}{"name": "Den", "kind": "room"}, http.StatusCreated)
+	contributor.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{"title": "Ronin", "type": "movie"}, http.StatusForbidden)
+
+	// Role changes apply right away
+	tc.expect(http.MethodPut, "/api/v1/admin/roles/archivist", map[string]interface{}{
+		"permissions": []string{"location.manage", "bluray.create"},
+	}, http.StatusOK)
+	contributor.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{"title": "Ronin", "type": "movie"}, http.StatusCreated)
+
+	// Admin keeps every permission and built-in roles stay
+	tc.expect(http.MethodPut, "/api/v1/admin/roles/admin", map[string]interface{}{"permissions": []string{}}, http.StatusBadRequest)
+	tc.expect(http.MethodPut, "/api/v1/admin/roles/wizard", map[string]interface{}{"permissions": []string{}}, http.StatusNotFound)
+	tc.expect(http.MethodDelete, "/api/v1/admin/roles/user", nil, http.StatusBadRequest)
+	tc.expect(http.MethodDelete, "/api/v1/admin/roles/archivist", nil, http.StatusBadRequest)
+	tc.expect(http.MethodPut, carlRolePath, map[string]string{"role": "user"}, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/admin/roles/archivist", nil, http.StatusOK)
+	tc.expect(http.MethodGet, "/api/v1/admin/roles/archivist", nil, http.StatusNotFound)
+	role := tc.expect(http.MethodGet, "/api/v1/admin/roles/admin", nil, http.StatusOK)["role"].(map[string]interface{})
+	if len(role["permissions"].([]interface{})) != 17 {
+		t.Errorf("admin permissions = %v, want all 17", role["permissions"])
+	}
+
+	// Managing users only gives out the permissions one holds
+	tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{
+		"name":        "staff",
+		"permissions": []string{"user.manage", "rating.write", "watch.log"},
+	}, http.StatusCreated)
+	tc.expect(http.MethodPut, carlRolePath, map[string]string{"role": "staff"}, http.StatusOK)
+	staff := &testClient{t: t, server: tc.server}
+	staff.login("carl", "secret123")
+	adminID := tc.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)["user"].(map[string]interface{})["id"].(string)
+	adminPath := "/api/v1/admin/users/" + adminID
+	staff.expect(http.MethodPost, "/api/v1/admin/users", map[string]string{
+		"username": "mallory",
+		"email":    "mallory@example.com",
+		"password": "secret123",
+		"role":     "admin",
+	}, http.StatusForbidden)
+	uma := staff.expect(http.MethodPost, "/api/v1/admin/users", map[string]string{
+		"username": "uma",
+		"email":    "uma@example.com",
+		"password": "secret123",
+		"role":     "user",
+	}, http.StatusCreated)["user"].(map[string]interface{})
+	umaPath := "/api/v1/admin/users/" + uma["id"].(string)
+	staff.expect(http.MethodPut, umaPath+"/role", map[string]string{"role": "moderator"}, http.StatusForbidden)
+	staff.expect(http.MethodPut, umaPath, map[string]string{"role": "admin"}, http.StatusForbidden)
+	staff.expect(http.MethodPut, umaPath+"/role", map[string]string{"role": "guest"}, http.StatusOK)
+	staff.expect(http.MethodPut, carlRolePath, map[string]string{"role": "admin"}, http.StatusForbidden)
+	staff.expect(http.MethodPut, adminPath+"/role", map[string]string{"role": "user"}, http.StatusForbidden)
+	staff.expect(http.MethodPut, adminPath, map[string]string{"email": "carl@example.org"}, http.StatusForbidden)
+	staff.expect(http.MethodDelete, adminPath, nil, http.StatusForbidden)
+	staff.expect(http.MethodDelete, adminPath+"/2fa", nil, http.StatusForbidden)
+	staff.expect(http.MethodDelete, umaPath+"/2fa", nil, http.StatusOK)
+	staff.expect(http.MethodPost, adminPath+"/unlock", nil, http.StatusForbidden)
+	staff.expect(http.MethodPost, umaPath+"/unlock", nil, http.StatusOK)
+
+	// Install-wide settings are for admins only
+	staff.expect(http.MethodGet, "/api/v1/admin/settings", nil, http.StatusForbidden)
+	staff.expect(http.MethodPut, "/api/v1/admin/settings", map[string]int{"audit_retention_days": 1}, http.StatusForbidden)
+	staff.expect(http.MethodDelete, umaPath, nil, http.StatusOK)
+
+	// Managing roles only puts in them the permissions one holds
+	tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{
+		"name":        "curator",
+		"permissions": []string{"role.manage", "tag.manage"},
+	}, http.StatusCreated)
+	tc.expect(http.MethodPut, carlRolePath, map[string]string{"role": "curator"}, http.StatusOK)
+	curator := &testClient{t: t, server: tc.server}
+	curator.login("carl", "secret123")
+	curator.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{
+		"name":        "gatekeeper",
+		"permissions": []string{"user.manage"},
+	}, http.StatusForbidden)
+	curator.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{
+		"name":        "tagger",
+		"permissions": []string{"tag.manage"},
+	}, http.StatusCreated)
+	curator.expect(http.MethodPut, "/api/v1/admin/roles/curator", map[string]interface{}{
+		"permissions": []string{"role.manage", "tag.manage", "user.manage"},
+	}, http.StatusForbidden)
+	curator.expect(http.MethodPut, "/api/v1/admin/roles/tagger", map[string]interface{}{
+		"permissions": []string{"tag.manage", "bluray.delete"},
+	}, http.StatusForbidden)
+	curator.expect(http.MethodPut, "/api/v1/admin/roles/moderator", map[string]interface{}{"description": "Demoted"}, http.StatusForbidden)
+	curator.expect(http.MethodPut, "/api/v1/admin/roles/tagger", map[string]interface{}{"permissions": []string{}}, http.StatusOK)
+
+	// Signing up always gives the default role
+	mallory := &testClient{t: t, server: tc.server}
+	registered := mallory.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "mallory",
+		"email":    "mallory@example.com",
+		"password": "secret123",
+		"role":     "admin",
+	}, http.StatusCreated)["user"].(map[string]interface{})
+	if registered["role"] != "user" {
+		t.Errorf("registered role = %v, want user", registered["role"])
+	}
+	mallory.login("mallory", "secret123")
+	mallory.expect(http.MethodGet, "/api/v1/admin/roles", nil, http.StatusForbidden)
+}
+
+func TestSessions(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	dana := tc.expect(http.MethodPost, "/api/v1/admin/users", map[string]string{
+		"username": "dana",
+		"email":    "dana@example.com",
+		"password": "secret123",
+		"role":     "contributor",
+	}, http.StatusCreated)["user"].(map[string]interface{})
+	danaPath := "/api/v1/admin/users/" + dana["id"].(string)
+
+	laptop := &testClient{t: t, server: tc.server}
+	signedIn := laptop.expect(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"identifier": "dana",
+		"password":   "secret123",
+	}, http.StatusOK)
+	laptop.token = signedIn["token"].(string)
+	refreshToken := signedIn["refresh_token"].(string)
+	if signedIn["expires_in"].(float64) != 15*60 || refreshToken == "" {
+		t.Errorf("login response = %v, want a refresh token and a 15 minute access token", signedIn)
+	}
+	phone := &testClient{t: t, server: tc.server}
+	phone.login("dana", "secret123")
+
+	// Refreshing rotates the refresh token
+	refreshed := laptop.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": refreshToken}, http.StatusOK)
+	rotated := refreshed["refresh_token"].(string)
+	if rotated == refreshToken || refreshed["token"] == "" {
+		t.Fatalf("refresh response = %v, want new tokens", refreshed)
+	}
+	laptop.token = refreshed["token"].(string)
+	laptop.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)
+
+	sessions := laptop.expect(http.MethodGet, "/api/v1/user/sessions", nil, http.StatusOK)["sessions"].([]interface{})
+	if len(sessions) != 2 {
+		t.Fatalf("sessions = %v, want laptop and phone", sessions)
+	}
+	current := sessions[0].(map[string]interface{})
+	if current["current"] != true || sessions[1].(map[string]interface{})["current"] != false {
+		t.Errorf("sessions = %v, want the refreshed laptop session first and marked current", sessions)
+	}
+	if _, leaked := current["refresh_token_hash"]; leaked {
+		t.Error("sessions expose the refresh token hash")
+	}
+
+	// Guessing a refresh token for the session does not revoke it
+	sessionHex, _, _ := strings.Cut(rotated, ".")
+	tc.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": sessionHex + ".garbage"}, http.StatusUnauthorized)
+	tc.expect(http.MethodPost, "/api/v1/auth/logout", map[string]string{"refresh_token": sessionHex + ".garbage"}, http.StatusUnauthorized)
+	laptop.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)
+
+	// Replaying a rotated refresh token revokes the session
+	laptop.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": refreshToken}, http.StatusUnauthorized)
+	laptop.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": rotated}, http.StatusUnauthorized)
+	laptop.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusUnauthorized)
+	phone.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)
+
+	// Sessions are revoked one by one or all but the current one
+	laptop.login("dana", "secret123")
+	sessions = phone.expect(http.MethodGet, "/api/v1/user/sessions", nil, http.StatusOK)["sessions"].([]interface{})
+	if len(sessions) != 2 {
+		t.Fatalf("sessions = %v, want laptop and phone", sessions)
+	}
+	var laptopID string
+	for _, s := range sessions {
+		if session := s.(map[string]interface{}); session["current"] == false {
+			laptopID = session["id"].(string)
+		}
+	}
+	tc.expect(http.MethodDelete, "/api/v1/user/sessions/"+laptopID, nil, http.StatusNotFound)
+	phone.expect(http.MethodDelete, "/api/v1/user/sessions/"+laptopID, nil, http.StatusOK)
+	laptop.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusUnauthorized)
+	laptop.login("dana", "secret123")
+	phone.expect(http.MethodDelete, "/api/v1/user/sessions", nil, http.StatusOK)
+	laptop.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusUnauthorized)
+	phone.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)
+
+	// A role change invalidates access tokens until the session is refreshed
+	signedIn = laptop.expect(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"identifier": "dana",
+		"password":   "secret123",
+	}, http.StatusOK)
+	laptop.token = signedIn["token"].(string)
+	tc.expect(http.MethodPut, danaPath+"/role", map[string]string{"role": "guest"}, http.StatusOK)
+	laptop.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{"title": "Heat", "type": "movie"}, http.StatusUnauthorized)
+	refreshed = laptop.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": signedIn["refresh_token"].(string)}, http.StatusOK)
+	laptop.token = refreshed["token"].(string)
+	laptop.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{"title": "Heat", "type": "movie"}, http.StatusForbidden)
+
+	// Logging out works with the refresh token alone
+	laptop.token = ""
+	laptop.expect(http.MethodPost, "/api/v1/auth/logout", map[string]string{"refresh_token": refreshed["refresh_token"].(string)}, http.StatusOK)
+	laptop.expect(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": refreshed["refresh_token"].(string)}, http.StatusUnauthorized)
+
+	// Deleted users are signed out everywhere
+	tc.expect(http.MethodDelete, danaPath, nil, http.StatusOK)
+	phone.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusUnauthorized)
+}
+
+func TestAPITokens(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	tc.expect(http.MethodPost, "/api/v1/user/tokens", map[string]interface{}{"name": "Scanner", "scopes": []string{"root"}}, http.StatusBadRequest)
+	created := tc.expect(http.MethodPost, "/api/v1/user/tokens", map[string]interface{}{"name": " Scanner ", "scopes": []string{"write"}}, http.StatusCreated)
+	scanner := &testClient{t: t, server: tc.server, token: created["token"].(string)}
+	scannerToken := created["api_token"].(map[string]interface{})
+	if !strings.HasPrefix(scanner.token, "bmt_") || scannerToken["name"] != "Scanner" || !strings.HasPrefix(scanner.token, scannerToken["prefix"].(string)) {
+		t.Errorf("created token = %v", created)
+	}
+	readOnly := &testClient{t: t, server: tc.server}
+	readOnly.token = tc.expect(http.MethodPost, "/api/v1/user/tokens", map[string]interface{}{"name": "Dashboard"}, http.StatusCreated)["token"].(string)
+	admin := &testClient{t: t, server: tc.server}
+	admin.token = tc.expect(http.MethodPost, "/api/v1/user/tokens", map[string]interface{}{"name": "Provisioning", "scopes": []string{"write", "admin"}}, http.StatusCreated)["token"].(string)
+
+	// Tokens act as their owner, within their scopes
+	scanner.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{"title": "Heat", "type": "movie"}, http.StatusCreated)
+	scanner.expect(http.MethodGet, "/api/v1/admin/users", nil, http.StatusForbidden)
+	readOnly.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)
+	readOnly.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{"title": "Ronin", "type": "movie"}, http.StatusForbidden)
+	readOnly.expect(http.MethodPut, "/api/v1/user/settings", map[string]string{"theme": "dark"}, http.StatusForbidden)
+	admin.expect(http.MethodGet, "/api/v1/admin/users", nil, http.StatusOK)
+
+	// Tokens cannot manage the account they belong to
+	scanner.expect(http.MethodPost, "/api/v1/user/tokens", map[string]interface{}{"name": "More", "scopes": []string{"admin"}}, http.StatusForbidden)
+	admin.expect(http.MethodPut, "/api/v1/user/password", map[string]string{"current_password": "secret123", "new_password": "hijacked"}, http.StatusForbidden)
+
+	tokens := tc.expect(http.MethodGet, "/api/v1/user/tokens", nil, http.StatusOK)["tokens"].([]interface{})
+	if len(tokens) != 3 {
+		t.Fatalf("tokens = %v, want 3", tokens)
+	}
+	listed := tokens[0].(map[string]interface{})
+	if listed["last_used_at"] == nil || listed["scopes"].([]interface{})[0] != "write" {
+		t.Errorf("listed token = %v, want its scopes and last use", listed)
+	}
+	if _, leaked := listed["token_hash"]; leaked {
+		t.Error("listed tokens expose their hash")
+	}
+
+	tc.expect(http.MethodDelete, "/api/v1/user/tokens/"+primitive.NewObjectID().Hex(), nil, http.StatusNotFound)
+	tc.expect(http.MethodDelete, "/api/v1/user/tokens/"+scannerToken["id"].(string), nil, http.StatusOK)
+	scanner.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusUnauthorized)
+	readOnly.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)
+}
+
+// totp computes the code an authenticator app shows for the secret at a time
+func totp(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decoding TOTP secret %q: %v", secret, err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestTwoFactor(t *testing.T) {
+	// RFC 6238 test vector, truncated to 6 digits
+	rfcSecret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	if code := totp(t, rfcSecret, time.Unix(59, 0)); code != "287082" {
+		t.Fatalf("totp test helper = %s, want 287082", code)
+	}
+
+	tc, _ := newTestClient(t)
+	admin := tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)["user"].(map[string]interface{})
+	tc.login("admin", "secret123")
+
+	status := tc.expect(http.MethodGet, "/api/v1/user/2fa", nil, http.StatusOK)["two_factor"].(map[string]interface{})
+	if status["enabled"] != false || status["required"] != false {
+		t.Errorf("status = %v, want 2FA off and optional", status)
+	}
+
+	// Once required, staff can only set it up
+	tc.expect(http.MethodPut, "/api/v1/admin/settings", map[string]bool{"require_two_factor_for_staff": true}, http.StatusOK)
+	if body := tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusForbidden); body["two_factor_setup_required"] != true {
+		t.Errorf("blocked response = %v", body)
+	}
+	tc.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)
+	tc.expect(http.MethodPost, "/api/v1/user/2fa/verify", map[string]string{"code": "123456"}, http.StatusBadRequest)
+
+	enrolment := tc.expect(http.MethodPost, "/api/v1/user/2fa/enroll", nil, http.StatusOK)
+	secret := enrolment["secret"].(string)
+	uri := enrolment["otpauth_uri"].(string)
+	if !strings.HasPrefix(uri, "otpauth://totp/Bluray%20Manager:admin@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("otpauth URI = %s", uri)
+	}
+	now := time.Now()
+	firstCode := totp(t, secret, now)
+	wrongCode := "000000"
+	if wrongCode == firstCode {
+		wrongCode = "999999"
+	}
+	tc.expect(http.MethodPost, "/api/v1/user/2fa/verify", map[string]string{"code": wrongCode}, http.StatusBadRequest)
+	confirmed := tc.expect(http.MethodPost, "/api/v1/user/2fa/verify", map[string]string{"code": firstCode}, http.StatusOK)
+	recoveryCodes := confirmed["recovery_codes"].([]interface{})
+	if len(recoveryCodes) != 10 {
+		t.Fatalf("recovery codes = %v, want 10", recoveryCodes)
+	}
+	tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)
+
+	// Staff of a collection are held to it too, within that collection
+	bob := &testClient{t: t, server: tc.server}
+	bobID := bob.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "bob",
+		"email":    "bob@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)["user"].(map[string]interface{})["id"].(string)
+	cabinID := tc.expect(http.MethodPost, "/api/v1/admin/collections", map[string]string{"name": "Cabin"}, http.StatusCreated)["collection"].(map[string]interface{})["id"].(string)
+	tc.expect(http.MethodPost, "/api/v1/admin/collections/"+cabinID+"/members", map[string]string{"user_id": bobID, "role": "admin"}, http.StatusCreated)
+	bob.login("bob", "secret123")
+	if body := bob.expect(http.MethodGet, "/api/v1/blurays?collection_id="+cabinID, nil, http.StatusForbidden); body["two_factor_setup_required"] != true {
+		t.Errorf("blocked collection admin response = %v", body)
+	}
+	bob.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)
+	tc.expect(http.MethodPut, "/api/v1/admin/collections/"+cabinID+"/members/"+bobID, map[string]string{"role": "user"}, http.StatusOK)
+	bob.expect(http.MethodGet, "/api/v1/blurays?collection_id="+cabinID, nil, http.StatusOK)
+
+	// Logging in takes the password, then a code
+	phone := &testClient{t: t, server: tc.server}
+	challenge := phone.expect(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"identifier": "admin",
+		"password":   "secret123",
+	}, http.StatusOK)
+	if challenge["two_factor_required"] != true || challenge["token"] != nil {
+		t.Fatalf("login response = %v, want a two-factor challenge only", challenge)
+	}
+	challengeToken := challenge["challenge_token"].(string)
+	phone.token = challengeToken
+	phone.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusUnauthorized)
+	phone.token = ""
+	phone.expect(http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challengeToken, "code": firstCode}, http.StatusUnauthorized)
+	phone.expect(http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": "garbage", "code": totp(t, secret, now.Add(30*time.Second))}, http.StatusUnauthorized)
+	signedIn := phone.expect(http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challengeToken, "code": totp(t, secret, now.Add(30*time.Second))}, http.StatusOK)
+	phone.token = signedIn["token"].(string)
+	phone.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)
+
+	// Recovery codes work once
+	recoveryCode := strings.ToUpper(recoveryCodes[0].(string))
+	phone.expect(http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challengeToken, "code": recoveryCode}, http.StatusOK)
+	phone.expect(http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{"challenge_token": challengeToken, "code": recoveryCode}, http.StatusUnauthorized)
+	status = tc.expect(http.MethodGet, "/api/v1/user/2fa", nil, http.StatusOK)["two_factor"].(map[string]interface{})
+	if status["enabled"] != true || status["required"] != true || status["recovery_codes_left"].(float64) != 9 {
+		t.Errorf("status = %v, want 2FA on, required, with 9 recovery codes left", status)
+	}
+
+	// It cannot be turned off while required
+	disable := map[string]string{"password": "secret123", "code": recoveryCodes[1].(string)}
+	tc.expect(http.MethodPost, "/api/v1/user/2fa/disable", disable, http.StatusBadRequest)
+	tc.expect(http.MethodPut, "/api/v1/admin/settings", map[string]bool{"require_two_factor_for_staff": false}, http.StatusOK)
+	tc.expect(http.MethodPost, "/api/v1/user/2fa/disable", map[string]string{"password": "wrong", "code": recoveryCodes[1].(string)}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/user/2fa/disable", disable, http.StatusOK)
+	tc.login("admin", "secret123")
+
+	// Admins can reset the second factor of a user who lost it
+	secret = tc.expect(http.MethodPost, "/api/v1/user/2fa/enroll", nil, http.StatusOK)["secret"].(string)
+	tc.expect(http.MethodPost, "/api/v1/user/2fa/verify", map[string]string{"code": totp(t, secret, time.Now())}, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/admin/users/"+admin["id"].(string)+"/2fa", nil, http.StatusOK)
+	tc.login("admin", "secret123")
+}
+
+// oidcStub is a local OpenID Connect provider. Logging in at it hands out
+// a code for an ID token with the given claims.
+type oidcStub struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	codes  map[string]jwt.MapClaims
+	// kid is the key ID the ID tokens name, and keyFetches counts the
+	// requests for the signing keys
+	kid        string
+	keyFetches int
+}
+
+func newOIDCStub(t *testing.T) *oidcStub {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &oidcStub{t: t, key: key, codes: map[string]jwt.MapClaims{}, kid: "stub-key"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 stub.server.URL,
+			"authorization_endpoint": stub.server.URL + "/authorize",
+			"token_endpoint":         stub.server.URL + "/token",
+			"jwks_uri":               stub.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		stub.keyFetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "stub-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		claims, ok := stub.codes[r.FormValue("code")]
+		delete(stub.codes, r.FormValue("code"))
+		if clientID != "bluray-manager" || secret != "s3cret" || !ok ||
+			r.FormValue("grant_type") != "authorization_code" || r.FormValue("redirect_uri") != "http://localhost:3000/auth/oidc/callback" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = stub.kid
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Errorf("signing ID token: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idToken})
+	})
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+// login starts a single sign-on at the backend, logs in at the stub with
+// the claims and hands the code back to the backend
+func (stub *oidcStub) login(tc *testClient, claims jwt.MapClaims) (int, map[string]interface{}) {
+	stub.t.Helper()
+	started := tc.expect(http.MethodGet, "/api/v1/auth/oidc/login", nil, http.StatusOK)
+	authURL, err := url.Parse(started["authorization_url"].(string))
+	if err != nil || !strings.HasPrefix(authURL.String(), stub.server.URL+"/authorize?") {
+		stub.t.Fatalf("authorization URL = %v", started["authorization_url"])
+	}
+	query := authURL.Query()
+	if query.Get("state") != started["state"] || query.Get("scope") != "openid email profile" {
+		stub.t.Fatalf("authorization URL = %v, want the state and default scopes", authURL)
+	}
+
+	now := time.Now()
+	token := jwt.MapClaims{
+		"iss":   stub.server.URL,
+		"aud":   "bluray-manager",
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		token[name] = value
+	}
+	code := fmt.Sprintf("code-%d", len(stub.codes)+1)
+	stub.codes[code] = token
+
+	return tc.do(http.MethodPost, "/api/v1/auth/oidc/callback", map[string]string{"code": code, "state": query.Get("state")})
+}
+
+func TestOIDCLogin(t *testing.T) {
+	tc, _ := newTestClient(t)
+	if config := tc.expect(http.MethodGet, "/api/v1/auth/oidc", nil, http.StatusOK); config["enabled"] != false {
+		t.Errorf("OIDC config = %v, want it disabled without an issuer", config)
+	}
+	tc.expect(http.MethodGet, "/api/v1/auth/oidc/login", nil, http.StatusNotFound)
+
+	stub := newOIDCStub(t)
+	t.Setenv("OIDC_ISSUER", stub.server.URL)
+	t.Setenv("OIDC_CLIENT_ID", "bluray-manager")
+	t.Setenv("OIDC_CLIENT_SECRET", "s3cret")
+	t.Setenv("OIDC_REDIRECT_URL", "http://localhost:3000/auth/oidc/callback")
+	t.Setenv("OIDC_PROVIDER_NAME", "Authentik")
+	t.Setenv("OIDC_ROLE_MAPPING", "bluray-admins=admin, staff=moderator")
+	t.Setenv("LOGIN_MAX_FAILURES", "2")
+	t.Setenv("RATE_LIMIT_AUTH_ACCOUNT", "off")
+
+	tc, _ = newTestClient(t)
+	if config := tc.expect(http.MethodGet, "/api/v1/auth/oidc", nil, http.StatusOK); config["enabled"] != true || config["name"] != "Authentik" {
+		t.Errorf("OIDC config = %v", config)
+	}
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+
+	// First sign-on creates the account, with the role of its groups
+	code, body := stub.login(tc, jwt.MapClaims{"sub": "1001", "email": "alice@example.com", "email_verified": true, "preferred_username": "alice", "groups": []string{"staff"}})
+	if code != http.StatusOK {
+		t.Fatalf("first sign-on: status %d, body %v", code, body)
+	}
+	alice := body["user"].(map[string]interface{})
+	if alice["username"] != "alice" || alice["role"] != "moderator" || alice["email"] != "alice@example.com" {
+		t.Errorf("provisioned user = %v", alice)
+	}
+	tc.token = body["token"].(string)
+	tc.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)
+	tc.token = ""
+
+	// Later sign-ons find it by subject and follow the groups
+	code, body = stub.login(tc, jwt.MapClaims{"sub": "1001", "email": "alice@new.example.com", "groups": []string{"staff", "bluray-admins"}})
+	if code != http.StatusOK || body["user"].(map[string]interface{})["id"] != alice["id"] || body["user"].(map[string]interface{})["role"] != "admin" {
+		t.Fatalf("second sign-on: status %d, body %v", code, body)
+	}
+	code, body = stub.login(tc, jwt.MapClaims{"sub": "1001", "groups": []string{"readers"}})
+	if code != http.StatusOK || body["user"].(map[string]interface{})["role"] != "admin" {
+		t.Errorf("sign-on without a mapped group: status %d, body %v, want the role kept", code, body)
+	}
+
+	// Signing on does not get around a lock, and forgets failed logins
+	wrong := map[string]string{"identifier": "alice", "password": "guess"}
+	tc.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	if code, _ = stub.login(tc, jwt.MapClaims{"sub": "1001"}); code != http.StatusOK {
+		t.Errorf("sign-on after a failed login: status %d, want 200", code)
+	}
+	tc.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	tc.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	code, body = stub.login(tc, jwt.MapClaims{"sub": "1001"})
+	if code != http.StatusLocked || body["locked_until"] == nil {
+		t.Errorf("sign-on to a locked account: status %d, body %v, want 423 and when the lock ends", code, body)
+	}
+	tc.login("admin", "secret123")
+	tc.expect(http.MethodPost, "/api/v1/admin/users/"+alice["id"].(string)+"/unlock", nil, http.StatusOK)
+	tc.token = ""
+	if code, _ = stub.login(tc, jwt.MapClaims{"sub": "1001"}); code != http.StatusOK {
+		t.Errorf("sign-on once unlocked: status %d, want 200", code)
+	}
+
+	// Existing accounts are linked by verified email only
+	code, _ = stub.login(tc, jwt.MapClaims{"sub": "1002", "email": "admin@example.com", "email_verified": false, "preferred_username": "root"})
+	if code != http.StatusUnauthorized {
+		t.Errorf("sign-on with an unverified email of an account: status %d, want 401", code)
+	}
+	code, body = stub.login(tc, jwt.MapClaims{"sub": "1002", "email": "admin@example.com", "email_verified": true})
+	if code != http.StatusOK || body["user"].(map[string]interface{})["username"] != "admin" {
+		t.Fatalf("linking sign-on: status %d, body %v", code, body)
+	}
+	tc.login("admin", "secret123")
+	tc.token = ""
+	code, _ = stub.login(tc, jwt.MapClaims{"sub": "1003", "email": "admin@example.com", "email_verified": true})
+	if code != http.StatusUnauthorized {
+		t.Errorf("sign-on of a second identity with the email of a linked account: status %d, want 401", code)
+	}
+
+	// New accounts need a verified email too
+	code, _ = stub.login(tc, jwt.MapClaims{"sub": "1004", "email": "other-admin@example.com", "preferred_username": "admin"})
+	if code != http.StatusUnauthorized {
+		t.Errorf("first sign-on with an unverified email: status %d, want 401", code)
+	}
+
+	// Usernames taken here get a number
+	code, body = stub.login(tc, jwt.MapClaims{"sub": "1004", "email": "other-admin@example.com", "email_verified": true, "preferred_username": "admin"})
+	if code != http.StatusOK || body["user"].(map[string]interface{})["username"] != "admin2" || body["user"].(map[string]interface{})["role"] != "user" {
+		t.Errorf("sign-on with a taken username: status %d, body %v", code, body)
+	}
+
+	// Tampered logins are refused
+	code, _ = stub.login(tc, jwt.MapClaims{"sub": "1001", "nonce": "replayed"})
+	if code != http.StatusUnauthorized {
+		t.Errorf("sign-on with another nonce: status %d, want 401", code)
+	}
+	code, _ = stub.login(tc, jwt.MapClaims{"sub": "1001", "aud": "another-app"})
+	if code != http.StatusUnauthorized {
+		t.Errorf("sign-on with an ID token for another client: status %d, want 401", code)
+	}
+	state := tc.expect(http.MethodGet, "/api/v1/auth/oidc/login", nil, http.StatusOK)["state"].(string)
+	stub.codes["forged"] = jwt.MapClaims{"sub": "1001"}
+	tc.expect(http.MethodPost, "/api/v1/auth/oidc/callback", map[string]string{"code": "forged", "state": state + "x"}, http.StatusUnauthorized)
+	tc.expect(http.MethodPost, "/api/v1/auth/oidc/callback", map[string]string{"code": "unknown", "state": state}, http.StatusUnauthorized)
+
+	// A login started in one browser cannot be finished in another, which
+	// would sign the victim in to the account of the attacker
+	attacker := &testClient{t: t, server: tc.server}
+	started := attacker.expect(http.MethodGet, "/api/v1/auth/oidc/login", nil, http.StatusOK)
+	if cookie := attacker.cookies["oidc_binding"]; cookie == nil || !cookie.HttpOnly || cookie.Value == "" {
+		t.Fatalf("binding cookie = %v, want an HttpOnly secret", cookie)
+	}
+	startedURL, _ := url.Parse(started["authorization_url"].(string))
+	stub.codes["attacker"] = jwt.MapClaims{
+		"iss":            stub.server.URL,
+		"aud":            "bluray-manager",
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"nonce":          startedURL.Query().Get("nonce"),
+		"sub":            "1001",
+		"email_verified": true,
+	}
+	victim := &testClient{t: t, server: tc.server}
+	victim.expect(http.MethodPost, "/api/v1/auth/oidc/callback", map[string]string{"code": "attacker", "state": started["state"].(string)}, http.StatusUnauthorized)
+	attacker.expect(http.MethodPost, "/api/v1/auth/oidc/callback", map[string]string{"code": "attacker", "state": started["state"].(string)}, http.StatusOK)
+	if attacker.cookies["oidc_binding"] != nil {
+		t.Error("binding cookie kept after the callback, want it cleared")
+	}
+
+	// Tokens signed with an unknown key fetch the keys again, but not more
+	// than once a minute
+	tc, _ = newTestClient(t)
+	fetches := stub.keyFetches
+	stub.kid = "made-up"
+	for i := 0; i < 3; i++ {
+		if code, _ = stub.login(tc, jwt.MapClaims{"sub": "1001"}); code != http.StatusUnauthorized {
+			t.Errorf("sign-on with an unknown signing key: status %d, want 401", code)
+		}
+	}
+	stub.kid = "stub-key"
+	if code, _ = stub.login(tc, jwt.MapClaims{"sub": "1001", "email": "alice@example.com", "email_verified": true}); code != http.StatusOK {
+		t.Errorf("sign-on with a known signing key: status %d, want 200", code)
+	}
+	if stub.keyFetches != fetches+1 {
+		t.Errorf("signing keys fetched %d times, want once", stub.keyFetches-fetches)
+	}
+}
+
+// loginFrom tries to log in from a client IP and returns the response
+func (tc *testClient) loginFrom(ip, identifier, password string) *httptest.ResponseRecorder {
+	tc.t.Helper()
+	data, _ := json.Marshal(map[string]string{"identifier": identifier, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":41234"
+	rec := httptest.NewRecorder()
+	tc.server.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiting(t *testing.T) {
+	t.Setenv("RATE_LIMIT_AUTH_IP", "3/1m")
+	t.Setenv("RATE_LIMIT_AUTH_ACCOUNT", "2/1m")
+	t.Setenv("RATE_LIMIT_LOOKUP_ACCOUNT", "1/1h")
+	t.Setenv("RATE_LIMIT_REFRESH_IP", "2/1m")
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	// Per account, whatever the client and the case of the identifier
+	if rec := tc.loginFrom("203.0.113.1", "ADMIN", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("second login of the account: status %d, want 401", rec.Code)
+	}
+	rec := tc.loginFrom("203.0.113.2", "admin", "secret123")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third login of the account: status %d, want 429", rec.Code)
+	}
+	if wait, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || wait < 1 || wait > 30 {
+		t.Errorf("Retry-After = %q, want up to 30 seconds", rec.Header().Get("Retry-After"))
+	}
+
+	// Per client IP, whatever the account
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if rec := tc.loginFrom("203.0.113.3", fmt.Sprintf("user%d", i), "wrong"); rec.Code != want {
+			t.Errorf("login %d from one client: status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+
+	// Clients cannot pick their IP unless they are a trusted proxy
+	data, _ := json.Marshal(map[string]string{"identifier": "user9", "password": "wrong"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	req.RemoteAddr = "203.0.113.3:41234"
+	spoofed := httptest.NewRecorder()
+	tc.server.router.ServeHTTP(spoofed, req)
+	if spoofed.Code != http.StatusTooManyRequests {
+		t.Errorf("login with a forged X-Forwarded-For: status %d, want 429", spoofed.Code)
+	}
+	// Private networks are not trusted unless listed
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", "203.0.113.3")
+	req.RemoteAddr = "10.1.2.3:41234"
+	private := httptest.NewRecorder()
+	tc.server.router.ServeHTTP(private, req)
+	if private.Code != http.StatusUnauthorized {
+		t.Errorf("login through an untrusted private proxy: status %d, want 401", private.Code)
+	}
+
+	// Refreshing sessions is limited per client
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if status, _ := tc.do(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": "guess"}); status != want {
+			t.Errorf("refresh %d: status %d, want %d", i+1, status, want)
+		}
+	}
+
+	// Lookups are limited per user
+	tc.expect(http.MethodGet, "/api/v1/tmdb/search", nil, http.StatusBadRequest)
+	tc.expect(http.MethodGet, "/api/v1/barcode/5051889004578", nil, http.StatusTooManyRequests)
+	tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)
+}
+
+func TestAccountLockout(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "3")
+	t.Setenv("RATE_LIMIT_AUTH_ACCOUNT", "off")
+	tc, ds := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+	bob := &testClient{t: t, server: tc.server}
+	bob.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "bob",
+		"email":    "bob@example.com",
+		"password": "password1",
+	}, http.StatusCreated)
+	wrong := map[string]string{"identifier": "bob", "password": "wrong"}
+
+	// A successful login forgets the failures before it
+	bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	bob.login("bob", "password1")
+
+	bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+
+	// The lock only shows to whoever knows the password, wrong guesses look
+	// like those on unknown accounts
+	unknown := bob.expect(http.MethodPost, "/api/v1/auth/login", map[string]string{"identifier": "nobody", "password": "wrong"}, http.StatusUnauthorized)
+	guessed := bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	if guessed["error"] != unknown["error"] || guessed["locked_until"] != nil {
+		t.Errorf("wrong password on a locked account = %v, want the same as an unknown account %v", guessed, unknown)
+	}
+	rec := bob.loginFrom("192.0.2.1", "bob", "password1")
+	if rec.Code != http.StatusLocked {
+		t.Fatalf("login to a locked account: status %d, want 423", rec.Code)
+	}
+	if wait, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || wait < 890 || wait > 900 {
+		t.Errorf("Retry-After = %q, want the 15 minutes of the lock", rec.Header().Get("Retry-After"))
+	}
+	var locked map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &locked); err != nil || locked["locked_until"] == nil {
+		t.Errorf("locked response = %s, want when the lock ends", rec.Body.String())
+	}
+
+	// Admins can lift the lock
+	user, err := ds.GetUserByUsername(context.Background(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := tc.expect(http.MethodGet, "/api/v1/admin/users/"+user.ID.Hex(), nil, http.StatusOK)["user"].(map[string]interface{})
+	if listed["lockout"].(map[string]interface{})["locked_until"] == nil {
+		t.Errorf("admin view of the user = %v, want the lock", listed)
+	}
+	bob.expect(http.MethodPost, "/api/v1/admin/users/"+user.ID.Hex()+"/unlock", nil, http.StatusForbidden)
+	tc.expect(http.MethodPost, "/api/v1/admin/users/"+user.ID.Hex()+"/unlock", nil, http.StatusOK)
+	bob.login("bob", "password1")
+
+	// So can the link of the unlock email, once
+	bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	bob.expect(http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized)
+	bob.expect(http.MethodPost, "/api/v1/auth/login", map[string]string{"identifier": "bob", "password": "password1"}, http.StatusLocked)
+	bob.expect(http.MethodPost, "/api/v1/auth/unlock/request", map[string]string{"email": "bob@example.com"}, http.StatusServiceUnavailable)
+	user, _ = ds.GetUserByUsername(context.Background(), "bob")
+	token, err := tc.server.ctrl.NewUnlockToken(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.expect(http.MethodPost, "/api/v1/auth/unlock", map[string]string{"token": token + "0"}, http.StatusBadRequest)
+	bob.expect(http.MethodPost, "/api/v1/auth/unlock", map[string]string{"token": token}, http.StatusOK)
+	bob.expect(http.MethodPost, "/api/v1/auth/unlock", map[string]string{"token": token}, http.StatusBadRequest)
+	bob.login("bob", "password1")
+}
+
+func TestAuditLog(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	created := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title": "Alien",
+		"type":  "movie",
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	blurayID := created["id"].(string)
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{
+		"title": "Aliens",
+		"type":  "movie",
+	}, http.StatusOK)
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID+"/tags", map[string]interface{}{"tags": []string{}}, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+blurayID, nil, http.StatusOK)
+
+	// Each change is recorded, newest first; updates that change nothing
+	// are not
+	entries := tc.expect(http.MethodGet, "/api/v1/admin/audit?entity_type=bluray&entity_id="+blurayID, nil, http.StatusOK)["entries"].([]interface{})
+	if len(entries) != 3 {
+		t.Fatalf("bluray entries = %v, want create, update and delete", entries)
+	}
+	deleted := entries[0].(map[string]interface{})
+	updated := entries[1].(map[string]interface{})
+	if deleted["action"] != "delete" || updated["action"] != "update" || entries[2].(map[string]interface{})["action"] != "create" {
+		t.Errorf("bluray entries = %v, want delete, update then create", entries)
+	}
+	if deleted["actor_name"] != "admin" || deleted["entity_name"] != "Aliens" || deleted["collection_id"] == nil {
+		t.Errorf("delete entry = %v", deleted)
+	}
+	request := deleted["request"].(map[string]interface{})
+	if request["method"] != "DELETE" || request["path"] != "/api/v1/blurays/"+blurayID || request["ip_address"] != "192.0.2.1" {
+		t.Errorf("delete entry request = %v", request)
+	}
+	var title map[string]interface{}
+	for _, change := range updated["changes"].([]interface{}) {
+		if change := change.(map[string]interface{}); change["field"] == "title" {
+			title = change
+		}
+	}
+	if title == nil || title["before"] != "Alien" || title["after"] != "Aliens" {
+		t.Errorf("update changes = %v, want the title", updated["changes"])
+	}
+
+	// Accounts: registering has no actor and never records the password
+	bob := &testClient{t: t, server: tc.server}
+	registered := bob.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "bob",
+		"email":    "bob@example.com",
+		"password": "password1",
+	}, http.StatusCreated)["user"].(map[string]interface{})
+	bobID := registered["id"].(string)
+	bob.login("bob", "password1")
+	tc.expect(http.MethodPut, "/api/v1/admin/users/"+bobID+"/role", map[string]string{"role": "contributor"}, http.StatusOK)
+	bob.login("bob", "password1")
+	bob.expect(http.MethodPut, "/api/v1/user/password", map[string]string{
+		"current_password": "password1",
+		"new_password":     "password2",
+	}, http.StatusOK)
+
+	entries = tc.expect(http.MethodGet, "/api/v1/admin/audit?entity_type=user&entity_id="+bobID, nil, http.StatusOK)["entries"].([]interface{})
+	if len(entries) != 3 {
+		t.Fatalf("user entries = %v, want register, role and password", entries)
+	}
+	password := entries[0].(map[string]interface{})
+	if password["actor_name"] != "bob" || password["changes"].([]interface{})[0].(map[string]interface{})["field"] != "password" {
+		t.Errorf("password entry = %v", password)
+	}
+	if change := entries[1].(map[string]interface{})["changes"].([]interface{})[0].(map[string]interface{}); change["field"] != "role" || change["after"] != "contributor" {
+		t.Errorf("role change = %v", change)
+	}
+	register := entries[2].(map[string]interface{})
+	if register["actor_id"] != nil {
+		t.Errorf("register entry = %v, want no actor", register)
+	}
+	for _, change := range register["changes"].([]interface{}) {
+		if field := change.(map[string]interface{})["field"]; field == "password" || field == "password_hash" {
+			t.Errorf("register entry records the password: %v", register)
+		}
+	}
+
+	// Roles and tags are audited too
+	tc.expect(http.MethodPost, "/api/v1/admin/roles", map[string]interface{}{"name": "curator", "permissions": []string{"tag.manage"}}, http.StatusCreated)
+	tc.expect(http.MethodDelete, "/api/v1/admin/roles/curator", nil, http.StatusOK)
+	tag := tc.expect(http.MethodPost, "/api/v1/tags", map[string]string{"name": "Horror"}, http.StatusCreated)["tag"].(map[string]interface{})
+	tc.expect(http.MethodDelete, "/api/v1/tags/"+tag["id"].(string), nil, http.StatusOK)
+	roles := tc.expect(http.MethodGet, "/api/v1/admin/audit?entity_type=role", nil, http.StatusOK)["entries"].([]interface{})
+	tags := tc.expect(http.MethodGet, "/api/v1/admin/audit?entity_type=tag&action=delete", nil, http.StatusOK)["entries"].([]interface{})
+	if len(roles) != 2 || len(tags) != 1 {
+		t.Errorf("role entries = %v, tag deletions = %v", roles, tags)
+	}
+
+	// Only admins read the log, and filters must make sense
+	bob.expect(http.MethodGet, "/api/v1/admin/audit", nil, http.StatusForbidden)
+	tc.expect(http.MethodGet, "/api/v1/admin/audit?since=yesterday", nil, http.StatusBadRequest)
+	adminID := tc.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)["user"].(map[string]interface{})["id"].(string)
+	mine := tc.expect(http.MethodGet, "/api/v1/admin/audit?actor_id="+adminID+"&since=2000-01-01&limit=2", nil, http.StatusOK)["entries"].([]interface{})
+	if len(mine) != 2 {
+		t.Errorf("admin entries = %v, want a page of 2", mine)
+	}
+
+	// Entries past the retention period are purged
+	tc.expect(http.MethodPut, "/api/v1/admin/settings", map[string]int{"audit_retention_days": -1}, http.StatusBadRequest)
+	tc.expect(http.MethodPut, "/api/v1/admin/settings", map[string]int{"audit_retention_days": 30}, http.StatusOK)
+	if err := tc.server.ctrl.PurgeAuditLog(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if entries := tc.expect(http.MethodGet, "/api/v1/admin/audit", nil, http.StatusOK)["entries"].([]interface{}); len(entries) == 0 {
+		t.Error("recent entries were purged")
+	}
+	if err := tc.server.ctrl.PurgeAuditLog(context.Background(), time.Now().AddDate(0, 0, 31)); err != nil {
+		t.Fatal(err)
+	}
+	if entries := tc.expect(http.MethodGet, "/api/v1/admin/audit", nil, http.StatusOK)["entries"].([]interface{}); len(entries) != 0 {
+		t.Errorf("entries after the retention period = %v", entries)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	tag := tc.expect(http.MethodPost, "/api/v1/tags", map[string]string{"name": "Noir"}, http.StatusCreated)["tag"].(map[string]interface{})
+	tagID := tag["id"].(string)
+	heat := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":   "Heat",
+		"type":    "movie",
+		"tmdb_id": "949",
+		"tags":    []string{tagID},
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	heatID := heat["id"].(string)
+	tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{"title": "Ronin", "type": "movie"}, http.StatusCreated)
+
+	// Deleted blurays and tags leave the library for the trash
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+heatID, nil, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/tags/"+tagID, nil, http.StatusOK)
+	tc.expect(http.MethodGet, "/api/v1/blurays/"+heatID, nil, http.StatusNotFound)
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+heatID, nil, http.StatusNotFound)
+	tc.expect(http.MethodGet, "/api/v1/tags/"+tagID, nil, http.StatusNotFound)
+	if blurays := tc.expect(http.MethodGet, "/api/v1/blurays", nil, http.StatusOK)["blurays"].([]interface{}); len(blurays) != 1 {
+		t.Errorf("listing after deleting = %d blurays, want 1", len(blurays))
+	}
+	if found, _ := tc.expect(http.MethodGet, "/api/v1/blurays/search?q=heat", nil, http.StatusOK)["blurays"].([]interface{}); len(found) != 0 {
+		t.Errorf("search after deleting = %v, want none", found)
+	}
+	if tags := tc.expect(http.MethodGet, "/api/v1/tags", nil, http.StatusOK)["tags"].([]interface{}); len(tags) != 0 {
+		t.Errorf("tags after deleting = %v, want none", tags)
+	}
+	stats := tc.expect(http.MethodGet, "/api/v1/statistics", nil, http.StatusOK)["statistics"].(map[string]interface{})
+	if stats["total_blurays"] != float64(1) {
+		t.Errorf("total_blurays after deleting = %v, want 1", stats["total_blurays"])
+	}
+
+	trash := tc.expect(http.MethodGet, "/api/v1/trash", nil, http.StatusOK)
+	blurays, tags := trash["blurays"].([]interface{}), trash["tags"].([]interface{})
+	if len(blurays) != 1 || blurays[0].(map[string]interface{})["deleted_at"] == nil || len(tags) != 1 {
+		t.Fatalf("trash = %v", trash)
+	}
+
+	// A trashed tag keeps its name
+	if body := tc.expect(http.MethodPost, "/api/v1/tags", map[string]string{"name": "Noir"}, http.StatusBadRequest); !strings.Contains(body["error"].(string), "trash") {
+		t.Errorf("creating a tag named like a trashed one = %v", body)
+	}
+	tc.expect(http.MethodPost, "/api/v1/trash/tags/"+tagID+"/restore", nil, http.StatusOK)
+	tc.expect(http.MethodPost, "/api/v1/trash/tags/"+tagID+"/restore", nil, http.StatusNotFound)
+	tc.expect(http.MethodGet, "/api/v1/tags/"+tagID, nil, http.StatusOK)
+
+	// A bluray cannot come back over one added again since
+	again := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":   "Heat",
+		"type":    "movie",
+		"tmdb_id": "949",
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	tc.expect(http.MethodPost, "/api/v1/trash/blurays/"+heatID+"/restore", nil, http.StatusConflict)
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+again["id"].(string), nil, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/trash/blurays/"+again["id"].(string), nil, http.StatusOK)
+	restored := tc.expect(http.MethodPost, "/api/v1/trash/blurays/"+heatID+"/restore", nil, http.StatusOK)["bluray"].(map[string]interface{})
+	if restored["deleted_at"] != nil || restored["title"] != "Heat" {
+		t.Errorf("restored bluray = %v", restored)
+	}
+	tc.expect(http.MethodGet, "/api/v1/blurays/"+heatID, nil, http.StatusOK)
+
+	entries := tc.expect(http.MethodGet, "/api/v1/admin/audit?entity_id="+heatID, nil, http.StatusOK)["entries"].([]interface{})
+	if len(entries) != 3 || entries[0].(map[string]interface{})["action"] != "restore" {
+		t.Errorf("audit entries of the bluray = %v, want create, delete and restore", entries)
+	}
+
+	// Readers see the trash but cannot empty it
+	tc.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "bob",
+		"email":    "bob@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	bob := &testClient{t: t, server: tc.server}
+	bob.login("bob", "secret123")
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+heatID, nil, http.StatusOK)
+	bob.expect(http.MethodGet, "/api/v1/trash", nil, http.StatusOK)
+	bob.expect(http.MethodDelete, "/api/v1/trash/blurays/"+heatID, nil, http.StatusForbidden)
+	bob.expect(http.MethodPost, "/api/v1/trash/blurays/"+heatID+"/restore", nil, http.StatusForbidden)
+
+	// Items past the retention period are purged
+	tc.expect(http.MethodPut, "/api/v1/admin/settings", map[string]int{"trash_retention_days": -1}, http.StatusBadRequest)
+	tc.expect(http.MethodPut, "/api/v1/admin/settings", map[string]int{"trash_retention_days": 30}, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/tags/"+tagID, nil, http.StatusOK)
+	if err := tc.server.ctrl.PurgeExpiredTrash(context.Background(), time.Now()); err != nil {
+		t.Fatalf("PurgeExpiredTrash: %v", err)
+	}
+	if trash := tc.expect(http.MethodGet, "/api/v1/trash", nil, http.StatusOK); len(trash["blurays"].([]interface{})) != 1 {
+		t.Errorf("trash before the retention period = %v", trash)
+	}
+	if err := tc.server.ctrl.PurgeExpiredTrash(context.Background(), time.Now().AddDate(0, 0, 31)); err != nil {
+		t.Fatalf("PurgeExpiredTrash: %v", err)
+	}
+	trash = tc.expect(http.MethodGet, "/api/v1/trash", nil, http.StatusOK)
+	if len(trash["blurays"].([]interface{})) != 0 || len(trash["tags"].([]interface{})) != 0 {
+		t.Errorf("trash after the retention period = %v", trash)
+	}
+	tc.expect(http.MethodPost, "/api/v1/trash/blurays/"+heatID+"/restore", nil, http.StatusNotFound)
+}
+
+func TestBlurayRevisions(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	room := tc.expect(http.MethodPost, "/api/v1/locations", map[string]interface{}{"name": "Den", "kind": "room"}, http.StatusCreated)["location"].(map[string]interface{})["id"].(string)
+	created := tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{
+		"title":       "Amélie",
+		"type":        "movie",
+		"description": map[string]string{"en-US": "A shy waitress.", "fr-FR": "Une serveuse timide."},
+	}, http.StatusCreated)["bluray"].(map[string]interface{})
+	blurayID := created["id"].(string)
+	path := "/api/v1/blurays/" + blurayID + "/revisions"
+
+	// A careless edit drops the French description
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{
+		"title":       "Amelie",
+		"type":        "movie",
+		"description": map[string]string{"en-US": "A shy waitress."},
+	}, http.StatusOK)
+	// Saving the same fields again makes no revision
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID+"/tags", map[string]interface{}{"tags": []string{}}, http.StatusOK)
+
+	revisions := tc.expect(http.MethodGet, path, nil, http.StatusOK)["revisions"].([]interface{})
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions, want 2", len(revisions))
+	}
+	latest := revisions[0].(map[string]interface{})
+	if latest["number"] != float64(2) || latest["edited_by_name"] != "admin" || latest["bluray"].(map[string]interface{})["title"] != "Amelie" {
+		t.Errorf("latest revision = %v", latest)
+	}
+	first := tc.expect(http.MethodGet, path+"/1", nil, http.StatusOK)["revision"].(map[string]interface{})
+	if first["bluray"].(map[string]interface{})["title"] != "Amélie" {
+		t.Errorf("first revision = %v", first)
+	}
+	tc.expect(http.MethodGet, path+"/3", nil, http.StatusNotFound)
+	tc.expect(http.MethodGet, path+"/zero", nil, http.StatusBadRequest)
+
+	diff := tc.expect(http.MethodGet, path+"/diff?from=1&to=2", nil, http.StatusOK)["diff"].(map[string]interface{})
+	fields := []string{}
+	for _, change := range diff["changes"].([]interface{}) {
+		fields = append(fields, change.(map[string]interface{})["field"].(string))
+	}
+	if strings.Join(fields, ",") != "description,title" {
+		t.Errorf("changed fields = %v, want description and title", fields)
+	}
+	tc.expect(http.MethodGet, path+"/diff?from=1", nil, http.StatusBadRequest)
+	tc.expect(http.MethodGet, path+"/diff?from=1&to=9", nil, http.StatusNotFound)
+
+	// Restoring saves a new revision and notifies
+	restored := tc.expect(http.MethodPost, path+"/1/restore", nil, http.StatusOK)["bluray"].(map[string]interface{})
+	if restored["title"] != "Amélie" || restored["description"].(map[string]interface{})["fr-FR"] != "Une serveuse timide." {
+		t.Errorf("restored bluray = %v", restored)
+	}
+	if revisions := tc.expect(http.MethodGet, path, nil, http.StatusOK)["revisions"].([]interface{}); len(revisions) != 3 {
+		t.Errorf("got %d revisions after restoring, want 3", len(revisions))
+	}
+	notifications := tc.expect(http.MethodGet, "/api/v1/notifications", nil, http.StatusOK)["notifications"].([]interface{})
+	if notifications[0].(map[string]interface{})["type"] != "bluray_reverted" {
+		t.Errorf("latest notification = %v, want bluray_reverted", notifications[0])
+	}
+
+	// Restores are validated like updates: the location of revision 4 is gone
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{"title": "Amélie", "type": "movie", "location_id": room}, http.StatusOK)
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+blurayID, map[string]interface{}{"title": "Amélie", "type": "movie"}, http.StatusOK)
+	tc.expect(http.MethodDelete, "/api/v1/locations/"+room, nil, http.StatusOK)
+	tc.expect(http.MethodPost, path+"/4/restore", nil, http.StatusBadRequest)
+
+	// Readers see the history but cannot restore it
+	tc.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "bob",
+		"email":    "bob@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	bob := &testClient{t: t, server: tc.server}
+	bob.login("bob", "secret123")
+	bob.expect(http.MethodGet, path, nil, http.StatusOK)
+	bob.expect(http.MethodPost, path+"/1/restore", nil, http.StatusForbidden)
+
+	// Revisions go when the bluray is purged
+	tc.expect(http.MethodDelete, "/api/v1/blurays/"+blurayID, nil, http.StatusOK)
+	tc.expect(http.MethodGet, path, nil, http.StatusNotFound)
+	tc.expect(http.MethodDelete, "/api/v1/trash/blurays/"+blurayID, nil, http.StatusOK)
+}
+
+func TestSearchQueries(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	for _, b := range []map[string]interface{}{
+		{"title": "Alien", "type": "movie", "release_year": 1979, "director": "Ridley Scott", "rating": 9},
+		{"title": "Blade Runner", "type": "movie", "release_year": 1982, "director": "Ridley Scott", "rating": 7},
+		{"title": "Heat", "type": "movie", "release_year": 1995, "director": "Michael Mann"},
+	} {
+		tc.expect(http.MethodPost, "/api/v1/blurays", b, http.StatusCreated)
+	}
+	search := func(query string) []string {
+		found := tc.expect(http.MethodGet, "/api/v1/blurays/search?q="+url.QueryEscape(query), nil, http.StatusOK)["blurays"].([]interface{})
+		titles := make([]string, len(found))
+		for i, b := range found {
+			titles[i] = b.(map[string]interface{})["title"].(string)
+		}
+		return titles
+	}
+
+	for query, want := range map[string]string{
+		`director:"ridley scott" -title:blade`:       "Alien",
+		`(title:heat OR rating:>=8) year:1990..1999`: "Heat",
+		`rating:<8`: "Blade Runner",
+	} {
+		if got := search(query); len(got) != 1 || got[0] != want {
+			t.Errorf("search %q = %v, want %s", query, got, want)
+		}
+	}
+
+	// Queries that do not parse say what is wrong and where
+	bad := tc.expect(http.MethodGet, "/api/v1/blurays/search?q="+url.QueryEscape(`alien year:19x9`), nil, http.StatusBadRequest)
+	if bad["code"] != "invalidValue" || bad["position"] != float64(6) || bad["token"] != "year:19x9" {
+		t.Errorf("invalid value error = %v", bad)
+	}
+	if bad["error"] != `Search query: the filter "year:19x9" at character 7 has an invalid value.` {
+		t.Errorf("invalid value message = %v", bad["error"])
+	}
+	bad = tc.expect(http.MethodGet, "/api/v1/blurays/search?q="+url.QueryEscape(`(alien OR heat`), nil, http.StatusBadRequest)
+	if bad["code"] != "unclosedParenthesis" || bad["position"] != float64(0) {
+		t.Errorf("unclosed parenthesis error = %v", bad)
+	}
+
+	// Filters on unknown fields are errors rather than matching everything
+	bad = tc.expect(http.MethodGet, "/api/v1/blurays/search?q="+url.QueryEscape(`titel:alien`), nil, http.StatusBadRequest)
+	if bad["code"] != "unknownField" || bad["position"] != float64(0) || bad["token"] != "titel:alien" {
+		t.Errorf("unknown field error = %v", bad)
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+	bob := &testClient{t: t, server: tc.server}
+	bob.expect(http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"username": "bob",
+		"email":    "bob@example.com",
+		"password": "password1",
+	}, http.StatusCreated)
+	bob.login("bob", "password1")
+
+	for _, b := range []map[string]interface{}{
+		{"title": "Alien", "type": "movie", "release_year": 1979, "copies": []map[string]interface{}{{"format": "4k", "purchase_price": 25}}},
+		{"title": "Heat", "type": "movie", "release_year": 1995, "copies": []map[string]interface{}{{"format": "4k", "purchase_price": 30}}},
+		{"title": "Ronin", "type": "movie", "release_year": 1998, "copies": []map[string]interface{}{{"format": "bluray"}}},
+	} {
+		tc.expect(http.MethodPost, "/api/v1/blurays", b, http.StatusCreated)
+	}
+	titles := func(body map[string]interface{}) []string {
+		var titles []string
+		for _, b := range body["blurays"].([]interface{}) {
+			titles = append(titles, b.(map[string]interface{})["title"].(string))
+		}
+		return titles
+	}
+
+	// Saved searches keep their sort, and are checked when saved
+	search := tc.expect(http.MethodPost, "/api/v1/saved-searches", map[string]interface{}{
+		"name":  "4K by price",
+		"query": "format:4k",
+		"sort":  "-price",
+	}, http.StatusCreated)["saved_search"].(map[string]interface{})
+	searchPath := "/api/v1/saved-searches/" + search["id"].(string)
+	bad := tc.expect(http.MethodPost, "/api/v1/saved-searches", map[string]interface{}{"name": "Broken", "query": "year:19x9"}, http.StatusBadRequest)
+	if bad["code"] != "invalidValue" {
+		t.Errorf("saving an invalid query = %v", bad)
+	}
+	tc.expect(http.MethodPost, "/api/v1/saved-searches", map[string]interface{}{"name": "Odd", "query": "heat", "sort": "runtime"}, http.StatusBadRequest)
+	tc.expect(http.MethodPost, "/api/v1/saved-searches", map[string]interface{}{
+		"name":   "Nineties",
+		"query":  "year:1990..1999",
+		"sort":   "title",
+		"pinned": true,
+	}, http.StatusCreated)
+
+	results := tc.expect(http.MethodGet, searchPath+"/results", nil, http.StatusOK)
+	if got := titles(results); fmt.Sprint(got) != "[Heat Alien]" {
+		t.Errorf("4K by price = %v, want [Heat Alien]", got)
+	}
+	if results["saved_search"].(map[string]interface{})["count"] != float64(2) {
+		t.Errorf("run saved search = %v, want a count of 2", results["saved_search"])
+	}
+	tc.expect(http.MethodPut, searchPath, map[string]interface{}{"sort": "price"}, http.StatusOK)
+	if got := titles(tc.expect(http.MethodGet, searchPath+"/results?limit=1", nil, http.StatusOK)); fmt.Sprint(got) != "[Alien]" {
+		t.Errorf("4K by price, ascending, first page = %v, want [Alien]", got)
+	}
+
+	// Smart collections need search.share and every user sees them, with
+	// live counts
+	bob.expect(http.MethodPost, "/api/v1/smart-collections", map[string]interface{}{"name": "Blu-ray", "query": "format:bluray"}, http.StatusForbidden)
+	smart := tc.expect(http.MethodPost, "/api/v1/smart-collections", map[string]interface{}{"name": "Blu-ray", "query": "format:bluray"}, http.StatusCreated)["saved_search"].(map[string]interface{})
+	if smart["shared"] != true {
+		t.Errorf("smart collection = %v, want it shared", smart)
+	}
+	bob.expect(http.MethodPost, "/api/v1/saved-searches", map[string]interface{}{"name": "Mine", "query": "ronin"}, http.StatusCreated)
+
+	listed := tc.expect(http.MethodGet, "/api/v1/saved-searches", nil, http.StatusOK)["saved_searches"].([]interface{})
+	var summary []string
+	for _, s := range listed {
+		s := s.(map[string]interface{})
+		summary = append(summary, fmt.Sprintf("%s=%v", s["name"], s["count"]))
+	}
+	if fmt.Sprint(summary) != "[Nineties=2 4K by price=2 Blu-ray=1]" {
+		t.Errorf("admin saved searches = %v", summary)
+	}
+	listed = bob.expect(http.MethodGet, "/api/v1/saved-searches", nil, http.StatusOK)["saved_searches"].([]interface{})
+	if len(listed) != 2 || listed[0].(map[string]interface{})["name"] != "Blu-ray" || listed[1].(map[string]interface{})["name"] != "Mine" {
+		t.Errorf("bob's saved searches = %v, want Blu-ray then Mine", listed)
+	}
+
+	// Others' searches stay private, and smart collections change through
+	// their own routes
+	bob.expect(http.MethodGet, searchPath, nil, http.StatusNotFound)
+	bob.expect(http.MethodGet, searchPath+"/results", nil, http.StatusNotFound)
+	bob.expect(http.MethodDelete, searchPath, nil, http.StatusNotFound)
+	smartPath := "/api/v1/saved-searches/" + smart["id"].(string)
+	if got := titles(bob.expect(http.MethodGet, smartPath+"/results", nil, http.StatusOK)); fmt.Sprint(got) != "[Ronin]" {
+		t.Errorf("smart collection results = %v, want [Ronin]", got)
+	}
+	tc.expect(http.MethodDelete, smartPath, nil, http.StatusNotFound)
+	tc.expect(http.MethodPut, "/api/v1/smart-collections/"+smart["id"].(string), map[string]interface{}{"pinned": true}, http.StatusOK)
+
+	// Managing tags does not share searches
+	me := bob.expect(http.MethodGet, "/api/v1/user/me", nil, http.StatusOK)["user"].(map[string]interface{})
+	for _, role := range []map[string]interface{}{
+		{"name": "tagger", "permissions": []string{"tag.manage"}},
+		{"name": "curator", "permissions": []string{"search.share"}},
+	} {
+		tc.expect(http.MethodPost, "/api/v1/admin/roles", role, http.StatusCreated)
+	}
+	tc.expect(http.MethodPut, "/api/v1/admin/users/"+me["id"].(string)+"/role", map[string]string{"role": "tagger"}, http.StatusOK)
+	bob.login("bob", "password1")
+	bob.expect(http.MethodPut, "/api/v1/smart-collections/"+smart["id"].(string), map[string]interface{}{"pinned": false}, http.StatusForbidden)
+	tc.expect(http.MethodPut, "/api/v1/admin/users/"+me["id"].(string)+"/role", map[string]string{"role": "curator"}, http.StatusOK)
+	bob.login("bob", "password1")
+	bob.expect(http.MethodPut, "/api/v1/smart-collections/"+smart["id"].(string), map[string]interface{}{"pinned": false}, http.StatusOK)
+	bob.expect(http.MethodDelete, "/api/v1/smart-collections/"+smart["id"].(string), nil, http.StatusOK)
+	tc.expect(http.MethodDelete, searchPath, nil, http.StatusOK)
+	tc.expect(http.MethodGet, searchPath, nil, http.StatusNotFound)
+}
+
+func TestListPaging(t *testing.T) {
+	tc, _ := newTestClient(t)
+
+	tc.expect(http.MethodPost, "/api/v1/setup/install", map[string]string{
+		"username": "admin",
+		"email":    "admin@example.com",
+		"password": "secret123",
+	}, http.StatusCreated)
+	tc.login("admin", "secret123")
+
+	ids := map[string]string{}
+	for _, b := range []map[string]interface{}{
+		{"title": "Heat", "type": "movie", "release_year": 1995, "copies": []map[string]interface{}{{"format": "4k", "purchase_price": 30}}},
+		{"title": "alien", "type": "movie", "release_year": 1979, "copies": []map[string]interface{}{{"format": "4k", "purchase_price": 25}}},
+		{"title": "Ronin", "type": "movie", "copies": []map[string]interface{}{{"format": "bluray"}}},
+		{"title": "Dune", "type": "movie", "release_year": 2021, "copies": []map[string]interface{}{{"format": "bluray", "purchase_price": 20}, {"format": "4k", "purchase_price": 15}}},
+	} {
+		created := tc.expect(http.MethodPost, "/api/v1/blurays", b, http.StatusCreated)
+		ids[b["title"].(string)] = created["bluray"].(map[string]interface{})["id"].(string)
+	}
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+ids["Ronin"]+"/rating", map[string]interface{}{"score": 9}, http.StatusOK)
+	tc.expect(http.MethodPut, "/api/v1/blurays/"+ids["Heat"]+"/rating", map[string]interface{}{"score": 7}, http.StatusOK)
+
+	titles := func(body map[string]interface{}) string {
+		var titles []string
+		for _, b := range body["blurays"].([]interface{}) {
+			titles = append(titles, b.(map[string]interface{})["title"].(string))
+		}
+		return fmt.Sprint(titles)
+	}
+
+	// Every sort field, with the blurays missing a value last
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/blurays", "[Dune Ronin alien Heat]"},
+		{"/api/v1/blurays?sort=added", "[Heat alien Ronin Dune]"},
+		{"/api/v1/blurays?sort=title", "[alien Dune Heat Ronin]"},
+		{"/api/v1/blurays?sort=-title", "[Ronin Heat Dune alien]"},
+		{"/api/v1/blurays?sort=year", "[alien Heat Dune Ronin]"},
+		{"/api/v1/blurays?sort=-year", "[Dune Heat alien Ronin]"},
+		{"/api/v1/blurays?sort=-rating", "[Ronin Heat Dune alien]"},
+		{"/api/v1/blurays?sort=price", "[alien Heat Dune Ronin]"},
+		{"/api/v1/blurays/simplified?sort=-price", "[Dune Heat alien Ronin]"},
+		{"/api/v1/blurays/search?q=format:4k&sort=title", "[alien Dune Heat]"},
+	} {
+		body := tc.expect(http.MethodGet, tt.path, nil, http.StatusOK)
+		if got := titles(body); got != tt.want {
+			t.Errorf("GET %s = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+	tc.expect(http.MethodGet, "/api/v1/blurays?sort=runtime", nil, http.StatusBadRequest)
+	tc.expect(http.MethodGet, "/api/v1/blurays?cursor=nonsense", nil, http.StatusBadRequest)
+
+	// Pages follow the cursor and hold their place while blurays are added
+	first := tc.expect(http.MethodGet, "/api/v1/blurays?sort=title&limit=2", nil, http.StatusOK)
+	if got := titles(first); got != "[alien Dune]" || first["total"] != float64(4) {
+		t.Errorf("first page = %s of %v, want [alien Dune] of 4", got, first["total"])
+	}
+	cursor, _ := first["next_cursor"].(string)
+	if cursor == "" {
+		t.Fatalf("first page has no next cursor: %v", first)
+	}
+	tc.expect(http.MethodPost, "/api/v1/blurays", map[string]interface{}{"title": "Aliens", "type": "movie"}, http.StatusCreated)
+	tc.expect(http.MethodGet, "/api/v1/blurays/simplified?sort=title&cursor="+url.QueryEscape(cursor), nil, http.StatusOK)
+	tc.expect(http.MethodGet, "/api/v1/blurays?sort=year&cursor="+url.QueryEscape(cursor), nil, http.StatusBadRequest)
+	second := tc.expect(http.MethodGet, "/api/v1/blurays?sort=title&limit=2&cursor="+url.QueryEscape(cursor), nil, http.StatusOK)
+	if got := titles(second); got != "[Heat Ronin]" || second["total"] != float64(5) || second["next_cursor"] != nil {
+		t.Errorf("second page = %s of %v, next %v, want the last page [Heat Ronin] of 5", got, second["total"], second["next_cursor"])
+	}
+
+	// Searches are by relevance unless sorted, and saved searches are paged
+	// the same way
+	search := tc.expect(http.MethodGet, "/api/v1/blurays/search?q=alien&limit=1", nil, http.StatusOK)
+	if got := titles(search); got != "[alien]" || search["total"] != float64(2) || search["next_cursor"] == nil {
+		t.Errorf("search = %s of %v, next %v, want [alien] of 2 and a next page", got, search["total"], search["next_cursor"])
+	}
+	saved := tc.expect(http.MethodPost, "/api/v1/saved-searches", map[string]interface{}{"name": "4K", "query": "format:4k", "sort": "-price"}, http.StatusCreated)["saved_search"].(map[string]interface{})
+	results := tc.expect(http.MethodGet, "/api/v1/saved-searches/"+saved["id"].(string)+"/results?limit=2", nil, http.StatusOK)
+	if got := titles(results); got != "[Dune Heat]" || results["total"] != float64(3) {
+		t.Errorf("saved search = %s of %v, want [Dune Heat] of 3", got, results["total"])
+	}
+	next := results["next_cursor"].(string)
+	results = tc.expect(http.MethodGet, "/api/v1/saved-searches/"+saved["id"].(string)+"/results?cursor="+url.QueryEscape(next), nil, http.StatusOK)
+	if got := titles(results); got != "[alien]" {
+		t.Errorf("saved search, second page = %s, want [alien]", got)
+	}
+
+	// The other paged lists share the envelope, with cursors that go on
+	// from where the page ended
+	path := "/api/v1/admin/audit?entity_type=bluray&action=create&limit=2"
+	var created []interface{}
+	for cursor := ""; ; {
+		page := tc.expect(http.MethodGet, path+"&cursor="+url.QueryEscape(cursor), nil, http.StatusOK)
+		if page["total"] != float64(5) {
+			t.Fatalf("audit page total = %v, want 5", page["total"])
+		}
+		created = append(created, page["entries"].([]interface{})...)
+		next, ok := page["next_cursor"].(string)
+		if !ok {
+			break
+		}
+		cursor = next
+	}
+	if len(created) != 5 || created[0].(map[string]interface{})["entity_name"] != "Aliens" {
+		t.Errorf("paged audit log = %v, want the 5 creations newest first", created)
+	}
+	tc.expect(http.MethodGet, "/api/v1/admin/audit?cursor=nonsense", nil, http.StatusBadRequest)
+	revisions := tc.expect(http.MethodGet, "/api/v1/blurays/"+ids["Heat"]+"/revisions", nil, http.StatusOK)
+	if revisions["total"] != float64(1) || revisions["next_cursor"] != nil {
+		t.Errorf("revisions = %v, want a single page of 1", revisions)
+	}
+	loans := tc.expect(http.MethodGet, "/api/v1/loans", nil, http.StatusOK)
+	if loans["total"] != float64(0) || loans["next_cursor"] != nil {
+		t.Errorf("loans = %v, want an empty list", loans)
+	}
+}
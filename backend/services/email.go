@@ -160,3 +160,47 @@ func (e *EmailService) SendPasswordResetEmail(to, resetToken, appURL string) err
 
 	return e.SendEmail(to, subject, body)
 }
+
+func (e *EmailService) SendAccountUnlockEmail(to, unlockToken, appURL string) error {
+	unlockLink := fmt.Sprintf("%s/unlock-account?token=%s", strings.TrimSuffix(appURL, "/"), unlockToken)
+
+	subject := "Unlock your account - Bluray Manager"
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%); color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
+        .content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+        .button { display: inline-block; padding: 12px 30px; background: #667eea; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0; }
+        .footer { text-align: center; margin-top: 20px; color: #666; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Account Locked</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p>Your Bluray Manager account was locked after too many failed login attempts. Click the button below to unlock it:</p>
+            <p style="text-align: center;">
+                <a href="%s" class="button">Unlock Account</a>
+            </p>
+            <p>Or copy and paste this link into your browser:</p>
+            <p style="word-break: break-all; color: #667eea;">%s</p>
+            <p><strong>The account unlocks by itself when the lock ends.</strong></p>
+            <p>If the failed attempts were not yours, someone may be guessing your password: consider changing it once you are signed in.</p>
+        </div>
+        <div class="footer">
+            <p>&copy; 2026 Bluray Manager. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, unlockLink, unlockLink)
+
+	return e.SendEmail(to, subject, body)
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProvider signs users in with an OpenID Connect identity provider,
+// using the authorization code flow with a confidential client
+type OIDCProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the page of the frontend the provider sends the user
+	// back to. It hands the code and state over to the callback endpoint.
+	RedirectURL string
+	Scopes      []string
+	// DisplayName is shown on the login button
+	DisplayName string
+	// RoleClaim names the claim holding the groups or roles of the user
+	RoleClaim string
+	// RoleMapping maps values of the role claim to roles, the first match wins
+	RoleMapping []OIDCRoleMapping
+	// DefaultRole is given to provisioned users no mapping applies to
+	DefaultRole string
+	// AutoProvision creates an account for users signing in the first time
+	AutoProvision bool
+
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+	// keysFetched is when the keys were last fetched, whether that worked
+	keysFetched time.Time
+}
+
+// oidcKeysRefetchInterval is how often the signing keys may be fetched again
+// for tokens signed with an unknown key
+const oidcKeysRefetchInterval = time.Minute
+
+// OIDCRoleMapping gives Role to users whose role claim contains Value
+type OIDCRoleMapping struct {
+	Value string
+	Role  string
+}
+
+// OIDCIdentity is what the provider told about the user who signed in
+type OIDCIdentity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Claims            map[string]interface{}
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewOIDCProvider() *OIDCProvider {
+	scopes := strings.Fields(strings.ReplaceAll(os.Getenv("OIDC_SCOPES"), ",", " "))
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	displayName := os.Getenv("OIDC_PROVIDER_NAME")
+	if displayName == "" {
+		displayName = "Single sign-on"
+	}
+
+	roleClaim := os.Getenv("OIDC_ROLE_CLAIM")
+	if roleClaim == "" {
+		roleClaim = "groups"
+	}
+
+	defaultRole := os.Getenv("OIDC_DEFAULT_ROLE")
+	if defaultRole == "" {
+		defaultRole = "user"
+	}
+
+	// OIDC_ROLE_MAPPING looks like "bluray-admins=admin,bluray-staff=moderator"
+	var mapping []OIDCRoleMapping
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAPPING"), ",") {
+		value, role, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(value) == "" || strings.TrimSpace(role) == "" {
+			continue
+		}
+		mapping = append(mapping, OIDCRoleMapping{Value: strings.TrimSpace(value), Role: strings.TrimSpace(role)})
+	}
+
+	return &OIDCProvider{
+		Issuer:        strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:        scopes,
+		DisplayName:   displayName,
+		RoleClaim:     roleClaim,
+		RoleMapping:   mapping,
+		DefaultRole:   defaultRole,
+		AutoProvision: os.Getenv("OIDC_AUTO_PROVISION") != "false",
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *OIDCProvider) IsConfigured() bool {
+	return p.Issuer != "" && p.ClientID != "" && p.RedirectURL != ""
+}
+
+// AuthorizationURL returns the address of the provider's login page. The
+// state and nonce come back with the user and in the ID token.
+func (p *OIDCProvider) AuthorizationURL(ctx context.Context, state, nonce string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.ClientID},
+		"redirect_uri":  {p.RedirectURL},
+		"scope":         {strings.Join(p.Scopes, " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and returns the identity
+// of the verified ID token. The nonce must be the one the login started with.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := p.doJSON(req, &tokens); err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("token response has no ID token")
+	}
+
+	identity, err := p.verifyIDToken(ctx, discovery, tokens.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some providers only put the email in the userinfo response
+	if identity.Email == "" && discovery.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		if err := p.fetchUserinfo(ctx, discovery, tokens.AccessToken, identity); err != nil {
+			return nil, err
+		}
+	}
+	return identity, nil
+}
+
+// MappedRole returns the role the role mapping gives to the identity, or ""
+// when none applies
+func (p *OIDCProvider) MappedRole(identity *OIDCIdentity) string {
+	var values []string
+	switch claim := identity.Claims[p.RoleClaim].(type) {
+	case string:
+		values = strings.Fields(claim)
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	for _, mapping := range p.RoleMapping {
+		for _, value := range values {
+			if value == mapping.Value {
+				return mapping.Role
+			}
+		}
+	}
+	return ""
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, discovery *oidcDiscovery, raw, nonce string) (*OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, discovery, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != p.ClientID {
+		return nil, fmt.Errorf("invalid ID token: issued to %q", azp)
+	}
+
+	identity := &OIDCIdentity{Issuer: discovery.Issuer, Claims: claims}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("invalid ID token: no subject")
+	}
+	identity.setProfile(claims)
+	return identity, nil
+}
+
+func (p *OIDCProvider) fetchUserinfo(ctx context.Context, discovery *oidcDiscovery, accessToken string, identity *OIDCIdentity) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.UserinfoEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	claims := map[string]interface{}{}
+	if err := p.doJSON(req, &claims); err != nil {
+		return fmt.Errorf("userinfo request failed: %w", err)
+	}
+	// The userinfo response must be about the user of the ID token
+	if sub, _ := claims["sub"].(string); sub != identity.Subject {
+		return fmt.Errorf("userinfo subject does not match the ID token")
+	}
+	for name, value := range claims {
+		if _, ok := identity.Claims[name]; !ok {
+			identity.Claims[name] = value
+		}
+	}
+	identity.setProfile(identity.Claims)
+	return nil
+}
+
+func (identity *OIDCIdentity) setProfile(claims map[string]interface{}) {
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.PreferredUsername, _ = claims["preferred_username"].(string)
+	// A few providers send the flag as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+}
+
+// discover fetches the provider metadata once and keeps it
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	if !p.IsConfigured() {
+		return nil, fmt.Errorf("OIDC is not configured")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var discovery oidcDiscovery
+	if err := p.doJSON(req, &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q, want %q", discovery.Issuer, p.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document is incomplete")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// signingKey returns the provider key with the given ID. The keys are fetched
+// again when the ID is unknown, which is how key rotation shows, but at most
+// once a minute so that tokens with made-up IDs cannot hammer the provider.
+func (p *OIDCProvider) signingKey(ctx context.Context, discovery *oidcDiscovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := pickKey(p.keys, kid); key != nil {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < oidcKeysRefetchInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	p.keysFetched = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("fetching signing keys failed: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+
+	if key := pickKey(p.keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// pickKey finds the key with the given ID. Tokens without a key ID can only
+// be checked when the provider has a single key.
+func pickKey(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[kid]
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, v interface{}) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.New("malformed JSON response")
+	}
+	return nil
+}
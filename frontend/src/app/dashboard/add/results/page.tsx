@@ -55,9 +55,12 @@ const buildBlurayData = (
     backdrop_url: details.backdrop_path
       ? `https://image.tmdb.org/t/p/original${details.backdrop_path}`
       : null,
-    purchase_date: purchaseDate ? new Date(purchaseDate).toISOString() : null,
-    purchase_price: purchasePrice ? parseFloat(purchasePrice) : null,
-    rating: details.vote_average || 0,
+    copies: [
+      {
+        purchase_date: purchaseDate ? new Date(purchaseDate).toISOString() : null,
+        purchase_price: purchasePrice ? parseFloat(purchasePrice) : null,
+      },
+    ],
     tags: selectedTags,
     tmdb_id: details.id?.toString(),
   };
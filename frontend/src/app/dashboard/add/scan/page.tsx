@@ -399,10 +399,13 @@ export default function AddScanPage() {
           backdrop_url: tmdbDetails.backdrop_path
             ? `https://image.tmdb.org/t/p/original${tmdbDetails.backdrop_path}`
             : null,
-          purchase_date: purchaseDate
-            ? new Date(purchaseDate).toISOString()
-            : null,
-          rating: tmdbDetails.vote_average || 0,
+          copies: [
+            {
+              purchase_date: purchaseDate
+                ? new Date(purchaseDate).toISOString()
+                : null,
+            },
+          ],
           tags,
           tmdb_id: tmdbDetails.id?.toString(),
           seasons: selectedSeasonsData,
@@ -466,10 +469,13 @@ export default function AddScanPage() {
         backdrop_url: details.backdrop_path
           ? `https://image.tmdb.org/t/p/original${details.backdrop_path}`
           : null,
-        purchase_date: purchaseDate
-          ? new Date(purchaseDate).toISOString()
-          : null,
-        rating: details.vote_average || 0,
+        copies: [
+          {
+            purchase_date: purchaseDate
+              ? new Date(purchaseDate).toISOString()
+              : null,
+          },
+        ],
         tags,
         tmdb_id: details.id?.toString(),
         release_year: details.release_date
@@ -618,8 +624,7 @@ export default function AddScanPage() {
                   backdrop_url: details.backdrop_path
                     ? `https://image.tmdb.org/t/p/original${details.backdrop_path}`
                     : null,
-                  purchase_date: purchaseDate ? new Date(purchaseDate).toISOString() : null,
-                  rating: details.vote_average || 0,
+                  copies: [{ purchase_date: purchaseDate ? new Date(purchaseDate).toISOString() : null }],
                   tags,
                   tmdb_id: details.id?.toString(),
                   release_year: details.release_date
@@ -690,8 +695,7 @@ export default function AddScanPage() {
                     backdrop_url: details.backdrop_path
                       ? `https://image.tmdb.org/t/p/original${details.backdrop_path}`
                       : null,
-                    purchase_date: purchaseDate ? new Date(purchaseDate).toISOString() : null,
-                    rating: details.vote_average || 0,
+                    copies: [{ purchase_date: purchaseDate ? new Date(purchaseDate).toISOString() : null }],
                     tags,
                     tmdb_id: details.id?.toString(),
                     seasons: seasonsToAdd,
@@ -109,10 +109,13 @@ export default function AddSearchPage() {
         backdrop_url: details.backdrop_path
           ? `https://image.tmdb.org/t/p/original${details.backdrop_path}`
           : null,
-        purchase_date: purchaseDate
-          ? new Date(purchaseDate).toISOString()
-          : null,
-        rating: details.vote_average || 0,
+        copies: [
+          {
+            purchase_date: purchaseDate
+              ? new Date(purchaseDate).toISOString()
+              : null,
+          },
+        ],
         tags,
         tmdb_id: details.id?.toString(),
         ...(type === "movie" && {
@@ -125,8 +125,7 @@ export default function BlurayDetailPage() {
       const preservedData = {
         tags: bluray.tags,
         seasons: bluray.seasons,
-        purchase_price: bluray.purchase_price,
-        purchase_date: bluray.purchase_date,
+        copies: bluray.copies,
       };
 
       // Fetch fresh data from TMDB through the backend
@@ -146,7 +145,6 @@ export default function BlurayDetailPage() {
         director: tmdbData.director || bluray.director,
         runtime:
           tmdbData.runtime || tmdbData.episode_run_time?.[0] || bluray.runtime,
-        rating: tmdbData.vote_average || bluray.rating,
         description: {
           "en-US": tmdbData.overview || "",
           "fr-FR": tmdbData.fr?.overview || tmdbData.overview || "",
@@ -224,6 +222,9 @@ export default function BlurayDetailPage() {
     );
   }
 
+  // The purchase info shown is that of the first copy
+  const purchase = bluray.copies?.[0];
+
   return (
     <div className="relative min-h-screen text-slate-200 pb-20">
       {/* Background Ambient Effects */}
@@ -258,20 +259,12 @@ export default function BlurayDetailPage() {
           />
         )}
 
-        {editingPurchaseInfo && (
+        {editingPurchaseInfo && purchase && (
           <PurchaseInfoModal
             blurayId={params.id as string}
-            bluray={bluray}
+            copy={purchase}
             onClose={() => setEditingPurchaseInfo(false)}
-            onSave={(price, date) => {
-              if (bluray) {
-                setBluray({
-                  ...bluray,
-                  purchase_price: price,
-                  purchase_date: date,
-                });
-              }
-            }}
+            onSave={(updated) => setBluray(updated)}
           />
         )}
 
@@ -464,7 +457,7 @@ export default function BlurayDetailPage() {
                       </div>
                       <div className="flex items-baseline gap-1">
                         <span className="text-gray-900 dark:text-white font-black text-xl leading-none">
-                          {bluray.rating}
+                          {bluray.rating.toFixed(1)}
                         </span>
                         <span className="text-gray-500 dark:text-slate-500 text-xs font-bold uppercase tracking-tighter">
                           / 10
@@ -624,8 +617,8 @@ export default function BlurayDetailPage() {
                       {t("details.purchasePrice")}
                     </span>
                     <span className="text-emerald-600 dark:text-emerald-400 font-mono font-bold text-lg">
-                      {bluray.purchase_price > 0
-                        ? `€${bluray.purchase_price.toFixed(2)}`
+                      {purchase && purchase.purchase_price > 0
+                        ? `€${purchase.purchase_price.toFixed(2)}`
                         : "-"}
                     </span>
                   </div>
@@ -637,8 +630,8 @@ export default function BlurayDetailPage() {
                       {t("details.purchaseDate")}
                     </span>
                     <span className="text-gray-900 dark:text-white font-medium">
-                      {isValidPurchaseDate(bluray.purchase_date)
-                        ? formatPurchaseDate(bluray.purchase_date)
+                      {isValidPurchaseDate(purchase?.purchase_date)
+                        ? formatPurchaseDate(purchase?.purchase_date)
                         : "-"}
                     </span>
                   </div>
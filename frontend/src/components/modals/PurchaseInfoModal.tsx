@@ -6,26 +6,26 @@ import { useTranslations } from 'next-intl';
 import { apiClient } from '@/lib/api-client';
 import toast from 'react-hot-toast';
 import { Button } from '@/components/common';
-import { Bluray } from '@/types/bluray';
+import { Bluray, Copy } from '@/types/bluray';
 import { normalizePurchaseDateForInput } from '@/lib/bluray-utils';
 
 interface PurchaseInfoModalProps {
   blurayId: string;
-  bluray: Bluray;
+  copy: Copy;
   onClose: () => void;
-  onSave: (price: number, date: string) => void;
+  onSave: (bluray: Bluray) => void;
 }
 
 export default function PurchaseInfoModal({
   blurayId,
-  bluray,
+  copy,
   onClose,
   onSave,
 }: PurchaseInfoModalProps) {
   const t = useTranslations();
 
-  const [price, setPrice] = useState<number>(bluray.purchase_price || 0);
-  const [date, setDate] = useState<string>(normalizePurchaseDateForInput(bluray.purchase_date));
+  const [price, setPrice] = useState<number>(copy.purchase_price || 0);
+  const [date, setDate] = useState<string>(normalizePurchaseDateForInput(copy.purchase_date));
   const [loading, setLoading] = useState(false);
 
   useEffect(() => {
@@ -46,15 +46,15 @@ export default function PurchaseInfoModal({
       // Format the date to ISO string for API
       const isoDate = date ? new Date(date).toISOString() : null;
       
-      // Send the full bluray object with updated purchase info to satisfy backend validation
-      await apiClient.updateBluray(blurayId, {
-        ...bluray,
+      // Send the full copy with updated purchase info, as the copy is replaced
+      const response = await apiClient.updateCopy(blurayId, copy.id, {
+        ...copy,
         purchase_price: price,
         purchase_date: isoDate,
       });
 
       toast.success(t('details.purchaseInfoUpdated'));
-      onSave(price, isoDate || '');
+      onSave(response.bluray);
       onClose();
     } catch (error) {
       console.error('Failed to update purchase info:', error);
@@ -147,6 +147,11 @@ class ApiClient {
     return response.data;
   }
 
+  async updateCopy(blurayId: string, copyId: string, data: any) {
+    const response = await this.client.put(`/blurays/${blurayId}/copies/${copyId}`, data);
+    return response.data;
+  }
+
   async updateBlurayTags(id: string, data: { title: string; tags: string[] }) {
     const response = await this.client.put(`/blurays/${id}/tags`, data);
     return response.data;
@@ -217,10 +222,7 @@ class ApiClient {
         genre: current.genre,
         cover_image_url: current.cover_image_url,
         backdrop_url: current.backdrop_url,
-        purchase_price: current.purchase_price,
-        purchase_date: current.purchase_date,
         tags: current.tags,
-        rating: current.rating,
         tmdb_id: current.tmdb_id,
         release_year: current.release_year,
         seasons: mergedSeasons,
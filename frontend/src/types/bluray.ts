@@ -16,6 +16,24 @@ export interface Season {
   year?: number;
 }
 
+// Copy is one physical copy of a title, with its own release and purchase
+export interface Copy {
+  id: string;
+  format?: string;
+  edition?: string;
+  packaging?: string;
+  publisher?: string;
+  region_code?: string;
+  barcode?: string;
+  disc_count?: number;
+  purchase_price: number;
+  purchase_date: string;
+  condition?: string;
+  notes?: string;
+  created_at: string;
+  on_loan: boolean;
+}
+
 export interface Bluray {
   id: string;
   title: string;
@@ -29,10 +47,11 @@ export interface Bluray {
   genre: I18nTextArray;
   cover_image_url: string;
   backdrop_url: string;
-  purchase_price: number;
-  purchase_date: string;
+  copies: Copy[];
   tags: string[];
+  // Household average of the personal ratings, set through the rating endpoints
   rating: number;
+  rating_count: number;
   tmdb_id?: string;
   added_by: string;
   created_at: string;
@@ -50,11 +69,12 @@ export interface CreateBlurayRequest {
   genre: I18nTextArray;
   cover_image_url: string;
   backdrop_url: string;
-  purchase_price: number;
-  purchase_date: string;
+  // The copies bought; a title is created with a single copy when none is given
+  copies?: Partial<Copy>[];
   tags: string[];
-  rating: number;
+  // Personal rating of whoever adds the title
+  rating?: number;
   tmdb_id?: string;
 }
 
-export interface UpdateBlurayRequest extends Partial<CreateBlurayRequest> {}
+export interface UpdateBlurayRequest extends Partial<Omit<CreateBlurayRequest, 'rating'>> {}